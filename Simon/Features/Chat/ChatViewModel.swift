@@ -124,8 +124,8 @@ final class ChatViewModel: ObservableObject {
                         
                     case .messageFinal(let payload):
                         print("✅ Message final: \(payload.text.prefix(50))...")
-                        // Update with final text
-                        if let index = messages.firstIndex(where: { $0.id == assistantID }) {
+                        // Update with final text; a revised final replaces the message it already finalized
+                        if let index = messages.firstIndex(where: { $0.id == assistantID || $0.id == payload.messageId }) {
                             messages[index] = Message(
                                 id: payload.messageId,
                                 role: payload.role,
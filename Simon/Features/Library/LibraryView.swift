@@ -116,7 +116,7 @@ struct LibraryView: View {
                         }
                         
                         // Archive Section
-                        if !vm.archivedSessions.isEmpty {
+                        if !vm.archivedSessions.isEmpty || vm.hasMoreSessions {
                             VStack(alignment: .leading, spacing: 12) {
                                 Button(action: { withAnimation { showArchive.toggle() } }) {
                                     HStack {
@@ -141,6 +141,20 @@ struct LibraryView: View {
                                                 vm.continueSession(session)
                                             }
                                         }
+                                        
+                                        if vm.hasMoreSessions {
+                                            Button(action: { Task { await vm.loadMoreSessions() } }) {
+                                                if vm.isLoadingMoreSessions {
+                                                    ProgressView()
+                                                } else {
+                                                    Text("Load older sessions")
+                                                        .font(theme.font(15, weight: .semibold))
+                                                }
+                                            }
+                                            .frame(maxWidth: .infinity)
+                                            .padding(.vertical, 12)
+                                            .disabled(vm.isLoadingMoreSessions)
+                                        }
                                     }
                                     .padding(.horizontal, 20)
                                 }
@@ -9,6 +9,8 @@ final class LibraryViewModel: ObservableObject {
     @Published var archivedSessions: [Session] = []
     @Published var pinnedSystems: [System] = []
     @Published var isLoading = false
+    @Published var isLoadingMoreSessions = false
+    @Published private(set) var nextSessionsCursor: String?
     @Published var errorMessage: String?
     @Published var selectedSystem: System?
     
@@ -18,6 +20,10 @@ final class LibraryViewModel: ObservableObject {
     var onNavigateToSettings: (() -> Void)?
     var onShowAllSessions: (() -> Void)?
     
+    var hasMoreSessions: Bool {
+        nextSessionsCursor != nil
+    }
+    
     init(apiClient: SimonAPI) {
         self.apiClient = apiClient
     }
@@ -46,9 +52,10 @@ final class LibraryViewModel: ObservableObject {
     
     private func loadRecentSessions() async {
         do {
-            // Load recent sessions, sorted by most recently updated
-            let sessions = try await apiClient.listSessions(limit: 20)
-            recentSessions = sessions.sorted { $0.updatedAt > $1.updatedAt }
+            // Load the first page of sessions, sorted by most recently updated
+            let page = try await apiClient.listSessions(limit: 20, startAfter: nil)
+            recentSessions = page.sessions.sorted { $0.updatedAt > $1.updatedAt }
+            nextSessionsCursor = page.nextCursor
         } catch {
             print("Failed to load sessions: \(error)")
             if recentSessions.isEmpty {
@@ -57,6 +64,26 @@ final class LibraryViewModel: ObservableObject {
         }
     }
     
+    /// Loads the next page of older sessions, following the cursor from the previous page
+    func loadMoreSessions() async {
+        guard let cursor = nextSessionsCursor, !isLoadingMoreSessions else { return }
+        
+        isLoadingMoreSessions = true
+        defer { isLoadingMoreSessions = false }
+        
+        do {
+            let page = try await apiClient.listSessions(limit: 20, startAfter: cursor)
+            let loadedIDs = Set(recentSessions.map(\.id))
+            recentSessions = (recentSessions + page.sessions.filter { !loadedIDs.contains($0.id) })
+                .sorted { $0.updatedAt > $1.updatedAt }
+            nextSessionsCursor = page.nextCursor
+            organizeSessions()
+        } catch {
+            print("Failed to load more sessions: \(error)")
+            errorMessage = "Failed to load older sessions. Please try again."
+        }
+    }
+    
     private func loadPinnedSystems() async {
         do {
             pinnedSystems = try await apiClient.listSystems()
@@ -20,6 +20,16 @@ struct Session: Identifiable, Codable, Equatable {
     }
 }
 
+struct SessionsPage: Codable {
+    let sessions: [Session]
+    let nextCursor: String?
+    
+    enum CodingKeys: String, CodingKey {
+        case sessions
+        case nextCursor = "next_cursor"
+    }
+}
+
 struct Message: Identifiable, Codable, Equatable {
     let id: String
     let role: String // "user" | "assistant"
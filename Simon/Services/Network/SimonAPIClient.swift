@@ -24,7 +24,7 @@ protocol SimonAPI {
     func createSession(coachID: String?) async throws -> Session
     func getSession(id: String) async throws -> SessionDetail
     func streamChat(sessionID: String, userText: String) -> AsyncThrowingStream<SSEEvent, Error>
-    func listSessions(limit: Int?) async throws -> [Session]
+    func listSessions(limit: Int?, startAfter: String?) async throws -> SessionsPage
     func listSystems() async throws -> [System]
     func createSystem(system: System) async throws -> System
     func getSystem(id: String) async throws -> System
@@ -263,12 +263,19 @@ final class SimonAPIClient: SimonAPI {
         return try decoder.decode(SessionDetail.self, from: data)
     }
     
-    func listSessions(limit: Int? = nil) async throws -> [Session] {
+    /// Lists one page of sessions, most recently updated first. Pass the previous page's
+    /// `nextCursor` as `startAfter` to get the next page; a nil `nextCursor` means there are no more.
+    func listSessions(limit: Int? = nil, startAfter: String? = nil) async throws -> SessionsPage {
         var components = URLComponents(url: baseURL.appendingPathComponent("/v1/sessions"), resolvingAgainstBaseURL: false)!
         
+        var queryItems: [URLQueryItem] = []
         if let limit = limit {
-            components.queryItems = [URLQueryItem(name: "limit", value: String(limit))]
+            queryItems.append(URLQueryItem(name: "limit", value: String(limit)))
         }
+        if let startAfter = startAfter {
+            queryItems.append(URLQueryItem(name: "start_after", value: startAfter))
+        }
+        components.queryItems = queryItems.isEmpty ? nil : queryItems
         
         var request = URLRequest(url: components.url!)
         request.setValue("application/json", forHTTPHeaderField: "Content-Type")
@@ -286,7 +293,7 @@ final class SimonAPIClient: SimonAPI {
         
         let decoder = JSONDecoder()
         decoder.dateDecodingStrategy = .iso8601
-        return try decoder.decode([Session].self, from: data)
+        return try decoder.decode(SessionsPage.self, from: data)
     }
     
     // MARK: - Chat Streaming
@@ -579,6 +586,35 @@ final class SimonAPIClient: SimonAPI {
         return try decoder.decode(Plan.self, from: data)
     }
     
+    func completePlanAction(planId: String, actionId: String) async throws -> Plan {
+        try await completePlanItem(path: "/v1/plans/\(planId)/actions/\(actionId)/complete")
+    }
+    
+    func completePlanMilestone(planId: String, milestoneId: String) async throws -> Plan {
+        try await completePlanItem(path: "/v1/plans/\(planId)/milestones/\(milestoneId)/complete")
+    }
+    
+    private func completePlanItem(path: String) async throws -> Plan {
+        var request = URLRequest(url: baseURL.appendingPathComponent(path))
+        request.httpMethod = "PUT"
+        try await addAuthHeader(to: &request)
+        
+        let (data, response) = try await session.data(for: request)
+        
+        guard let httpResponse = response as? HTTPURLResponse else {
+            throw APIError.invalidResponse
+        }
+        
+        guard (200...299).contains(httpResponse.statusCode) else {
+            throw APIError.httpError(httpResponse.statusCode)
+        }
+        
+        let decoder = JSONDecoder()
+        decoder.keyDecodingStrategy = .convertFromSnakeCase
+        decoder.dateDecodingStrategy = .iso8601
+        return try decoder.decode(Plan.self, from: data)
+    }
+    
     // MARK: - Tool Execution
     
     func executeToolRequest(_ toolRequest: ToolExecuteRequest) async throws -> ToolExecuteResponse {
@@ -13,6 +13,7 @@ import (
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/gemini"
 	router "simon-backend/internal/http"
+	"simon-backend/internal/orchestrator/safety"
 )
 
 func main() {
@@ -20,6 +21,12 @@ func main() {
 
 	// Load configuration
 	cfg := config.Load()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if _, err := safety.LoadCrisisDirectory(cfg.CrisisResourcesFile); err != nil {
+		log.Fatalf("Invalid CRISIS_RESOURCES_FILE: %v", err)
+	}
 	log.Printf("Starting Simon API on port %s", cfg.Port)
 	log.Printf("Project: %s, Location: %s", cfg.ProjectID, cfg.Location)
 
@@ -37,6 +44,16 @@ func main() {
 		log.Fatalf("Failed to initialize Gemini: %v", err)
 	}
 	defer gm.Close()
+	gm.Limiter = gemini.NewLimiter(cfg.GeminiMaxInFlight, time.Duration(cfg.GeminiQueueTimeoutMillis)*time.Millisecond)
+
+	// Probe the configured models so a typo fails the deploy, not the first request
+	probeCtx, cancelProbe := context.WithTimeout(ctx, 15*time.Second)
+	for _, model := range []string{cfg.ModelID, cfg.ModelIDPro} {
+		if err := gm.CheckModel(probeCtx, model); err != nil {
+			log.Fatalf("Gemini model check failed: %v", err)
+		}
+	}
+	cancelProbe()
 	log.Printf("Gemini initialized successfully (model: %s)", cfg.ModelID)
 
 	// Initialize router
@@ -45,12 +62,14 @@ func main() {
 		log.Fatalf("Failed to initialize router: %v", err)
 	}
 
-	// Create server
+	// Create server. WriteTimeout guards ordinary requests against slow clients; SSE handlers
+	// extend their own write deadline to sse.MaxDuration, so a stalled stream holds its
+	// connection for up to that long instead.
 	srv := &http.Server{
 		Addr:         ":" + cfg.Port,
 		Handler:      r,
 		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 60 * time.Second, // Longer for SSE streaming
+		WriteTimeout: 60 * time.Second,
 		IdleTimeout:  120 * time.Second,
 	}
 
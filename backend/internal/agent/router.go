@@ -2,11 +2,11 @@ package agent
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/gemini"
+	"simon-backend/internal/jsonutil"
 )
 
 // RouteResult contains the result of routing a moment
@@ -123,7 +123,7 @@ Be decisive. If unsure, default to "focus" with "calm_direct" tone.`
 
 	// Parse JSON response
 	var intent Intent
-	if err := json.Unmarshal([]byte(response), &intent); err != nil {
+	if err := jsonutil.ParseResponse(response, &intent); err != nil {
 		// Fallback to default intent
 		return &Intent{
 			Category:      "focus",
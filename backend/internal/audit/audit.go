@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"simon-backend/internal/firestore"
+	"simon-backend/internal/logger"
+)
+
+// Collection holds audit records
+const Collection = "audit_log"
+
+// Actions recorded in the audit log, as "<resource>.<verb>"
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionArchive = "archive"
+	ActionPublish = "publish"
+	ActionFork    = "fork"
+	ActionMerge   = "merge"
+	ActionExecute = "execute"
+)
+
+// ActorRevenueCat is the actor for subscription changes pushed by RevenueCat webhooks
+const ActorRevenueCat = "system:revenuecat"
+
+// ActorAccountPurge is the actor for accounts purged after their deletion grace window
+const ActorAccountPurge = "system:account-purge"
+
+// Entry is one audit record. It deliberately has no payload field: request bodies can
+// carry personal content, and the audit trail only needs who did what to which resource.
+type Entry struct {
+	ActorUID     string    `firestore:"actor_uid" json:"actor_uid"`
+	Action       string    `firestore:"action" json:"action"`
+	ResourceType string    `firestore:"resource_type" json:"resource_type"`
+	ResourceID   string    `firestore:"resource_id" json:"resource_id"`
+	Timestamp    time.Time `firestore:"timestamp" json:"timestamp"`
+	RequestID    string    `firestore:"request_id,omitempty" json:"request_id,omitempty"`
+}
+
+// NewEntry builds an entry for an action on a resource, taking the request ID from ctx
+func NewEntry(ctx context.Context, actorUID, resourceType, verb, resourceID string) Entry {
+	return Entry{
+		ActorUID:     actorUID,
+		Action:       resourceType + "." + verb,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Timestamp:    time.Now().UTC(),
+		RequestID:    logger.RequestID(ctx),
+	}
+}
+
+// Record writes entry to the audit log
+func Record(ctx context.Context, fs *firestore.Client, entry Entry) error {
+	if _, _, err := fs.DB.Collection(Collection).Add(ctx, entry); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
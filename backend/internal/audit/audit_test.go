@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"simon-backend/internal/logger"
+)
+
+func TestNewEntry(t *testing.T) {
+	ctx := logger.WithRequestID(context.Background(), "req-1")
+	entry := NewEntry(ctx, "u1", "plan", ActionArchive, "p1")
+	if entry.Action != "plan.archive" || entry.ResourceType != "plan" || entry.ResourceID != "p1" || entry.ActorUID != "u1" {
+		t.Errorf("entry = %+v", entry)
+	}
+	if entry.RequestID != "req-1" {
+		t.Errorf("request id = %q, want the context's", entry.RequestID)
+	}
+	if entry.Timestamp.IsZero() || entry.Timestamp.Location().String() != "UTC" {
+		t.Errorf("timestamp = %v, want now in UTC", entry.Timestamp)
+	}
+
+	if got := NewEntry(context.Background(), ActorRevenueCat, "subscription", ActionUpdate, "u1"); got.RequestID != "" {
+		t.Errorf("request id outside a request = %q, want none", got.RequestID)
+	}
+}
@@ -0,0 +1,43 @@
+package cards
+
+// ContentType identifies the JSON payload carried by card events
+const ContentType = "application/vnd.simon.card+json"
+
+// Card event types
+const (
+	TypePlan         = "card.plan"
+	TypeNextActions  = "card.next_actions"
+	TypeWeeklyReview = "card.weekly_review"
+)
+
+// Card schema versions; bump when a card payload changes incompatibly
+const (
+	SchemaPlan         = "Plan.v1"
+	SchemaNextActions  = "NextAction.v1"
+	SchemaWeeklyReview = "WeeklyReview.v1"
+)
+
+// Schema describes a card event and the payload schema version it carries
+type Schema struct {
+	EventType   string `json:"event_type"`
+	Schema      string `json:"schema"`
+	ContentType string `json:"content_type"`
+}
+
+// Schemas returns the current schema version of every card event
+func Schemas() []Schema {
+	return []Schema{
+		{EventType: TypePlan, Schema: SchemaPlan, ContentType: ContentType},
+		{EventType: TypeNextActions, Schema: SchemaNextActions, ContentType: ContentType},
+		{EventType: TypeWeeklyReview, Schema: SchemaWeeklyReview, ContentType: ContentType},
+	}
+}
+
+// Data builds a card event payload with its schema and content type under key
+func Data(schema, key string, value interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"schema":       schema,
+		"content_type": ContentType,
+		key:            value,
+	}
+}
@@ -0,0 +1,35 @@
+package cards
+
+import "testing"
+
+func TestSchemasRegistry(t *testing.T) {
+	want := map[string]string{
+		TypePlan:         SchemaPlan,
+		TypeNextActions:  SchemaNextActions,
+		TypeWeeklyReview: SchemaWeeklyReview,
+	}
+	schemas := Schemas()
+	if len(schemas) != len(want) {
+		t.Fatalf("registered %d schemas, want %d", len(schemas), len(want))
+	}
+	for _, s := range schemas {
+		if want[s.EventType] != s.Schema || s.ContentType != ContentType {
+			t.Errorf("schema %+v, want %s with the card content type", s, want[s.EventType])
+		}
+		delete(want, s.EventType)
+	}
+	if len(want) != 0 {
+		t.Errorf("missing schemas for %v", want)
+	}
+}
+
+func TestData(t *testing.T) {
+	items := []string{"Walk after lunch"}
+	data := Data(SchemaNextActions, "items", items)
+	if data["schema"] != SchemaNextActions || data["content_type"] != ContentType {
+		t.Errorf("data = %v, want the schema and content type", data)
+	}
+	if got, ok := data["items"].([]string); !ok || len(got) != 1 {
+		t.Errorf("items = %v, want the payload under its key", data["items"])
+	}
+}
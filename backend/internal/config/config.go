@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -19,14 +21,75 @@ type Config struct {
 	MaxTokens   int
 	Temperature float32
 
+	// Outbound Gemini calls allowed in flight at once (0 disables the cap); excess calls
+	// queue for at most GeminiQueueTimeoutMillis
+	GeminiMaxInFlight        int
+	GeminiQueueTimeoutMillis int
+
+	// Models this environment may use (comma-separated); ModelID and ModelIDPro must be listed
+	AllowedModels []string
+
+	// System prompt disclosure shared by every coach (empty disables)
+	SystemPreamble   string
+	ComplianceFooter string
+
+	// Streaming (token coalescing; interval 0 disables)
+	StreamCoalesceMillis int
+	StreamCoalesceChars  int
+
+	// Concurrent chat streams allowed per user (0 disables the limit)
+	MaxStreamsPerUser int
+
+	// Approximate tokens of earlier turns sent with each message; older turns are summarized
+	HistoryTokenBudget int
+
+	// What happens when a reply breaks its coach's formatting limits: off, warn or enforce
+	FormattingMode string
+
+	// JSON file of crisis resources by region, replacing the built-in list (optional)
+	CrisisResourcesFile string
+
 	// Rate Limiting
 	FreeTierMomentsPerDay      int
 	FreeTierMessagesPerSession int
 	ProTierMessagesPerSession  int
 
+	// Credits charged to non-Pro users per coach reply: a flat amount, or one credit per
+	// TokensPerCredit tokens when that is set
+	CreditsPerMessage int
+	TokensPerCredit   int
+
+	// Tool IDs that require the pro entitlement (comma-separated)
+	ProOnlyTools []string
+
+	// Server tools proposed below this confidence are sent for confirmation instead of auto-run;
+	// overrides are per tool ("plan_create=0.8,plan_update=0.75")
+	ToolAutoConfidence          float64
+	ToolAutoConfidenceOverrides map[string]float64
+
 	// RevenueCat
 	RevenueCatAPIKey       string
 	RevenueCatWebhookSecret string
+
+	// Internal endpoints (Cloud Scheduler jobs)
+	InternalAPIToken string
+
+	// Admin endpoints (operators)
+	AdminAPIToken string
+
+	// Deleted accounts stay recoverable for this many days before they are purged
+	AccountDeletionGraceDays int
+
+	// Sessions untouched for this many days are auto-archived (0 disables)
+	SessionAutoArchiveDays int
+
+	// An unacknowledged check-in nudge suppresses the next one for this long
+	CheckinCooldownMinutes int
+
+	// Coach leaderboard score weights
+	LeaderboardWeightStarts  float32
+	LeaderboardWeightSaves   float32
+	LeaderboardWeightUpvotes float32
 }
 
 func Load() Config {
@@ -40,17 +103,111 @@ func Load() Config {
 		MaxTokens:   getEnvInt("GEMINI_MAX_TOKENS", 2048),
 		Temperature: getEnvFloat("GEMINI_TEMPERATURE", 0.7),
 
+		GeminiMaxInFlight:        getEnvInt("GEMINI_MAX_IN_FLIGHT", 32),
+		GeminiQueueTimeoutMillis: getEnvInt("GEMINI_QUEUE_TIMEOUT_MS", 10000),
+
+		AllowedModels: getEnvListOr("GEMINI_ALLOWED_MODELS", defaultAllowedModels),
+
+		SystemPreamble:   getEnv("SYSTEM_PREAMBLE", "Simon is an AI coach, not a licensed professional."),
+		ComplianceFooter: getEnv("COMPLIANCE_FOOTER", "For medical, legal, financial, or mental health decisions, encourage the user to consult a licensed professional."),
+
+		StreamCoalesceMillis: getEnvInt("STREAM_COALESCE_MS", 50),
+		StreamCoalesceChars:  getEnvInt("STREAM_COALESCE_CHARS", 40),
+
+		MaxStreamsPerUser: getEnvInt("MAX_STREAMS_PER_USER", 3),
+
+		HistoryTokenBudget: getEnvInt("HISTORY_TOKEN_BUDGET", 4000),
+
+		FormattingMode: getEnv("FORMATTING_MODE", FormattingWarn),
+
+		CrisisResourcesFile: getEnv("CRISIS_RESOURCES_FILE", ""),
+
 		FreeTierMomentsPerDay:      getEnvInt("FREE_TIER_MOMENTS_PER_DAY", 3),
 		FreeTierMessagesPerSession: getEnvInt("FREE_TIER_MESSAGES_PER_SESSION", 10),
 		ProTierMessagesPerSession:  getEnvInt("PRO_TIER_MESSAGES_PER_SESSION", 100),
 
+		CreditsPerMessage: getEnvInt("CREDITS_PER_MESSAGE", 1),
+		TokensPerCredit:   getEnvInt("TOKENS_PER_CREDIT", 0),
+
+		ProOnlyTools: getEnvList("PRO_ONLY_TOOLS"),
+
+		ToolAutoConfidence:          float64(getEnvFloat("TOOL_AUTO_CONFIDENCE", 0.7)),
+		ToolAutoConfidenceOverrides: getEnvFloatMap("TOOL_AUTO_CONFIDENCE_OVERRIDES"),
+
 		RevenueCatAPIKey:       getEnv("REVENUECAT_API_KEY", ""),
 		RevenueCatWebhookSecret: getEnv("REVENUECAT_WEBHOOK_SECRET", ""),
+
+		InternalAPIToken: getEnv("INTERNAL_API_TOKEN", ""),
+
+		AdminAPIToken: getEnv("ADMIN_API_TOKEN", ""),
+
+		AccountDeletionGraceDays: getEnvInt("ACCOUNT_DELETION_GRACE_DAYS", 7),
+
+		SessionAutoArchiveDays: getEnvInt("SESSION_AUTO_ARCHIVE_DAYS", 90),
+
+		CheckinCooldownMinutes: getEnvInt("CHECKIN_COOLDOWN_MINUTES", 720),
+
+		LeaderboardWeightStarts:  getEnvFloat("LEADERBOARD_WEIGHT_STARTS", 1),
+		LeaderboardWeightSaves:   getEnvFloat("LEADERBOARD_WEIGHT_SAVES", 3),
+		LeaderboardWeightUpvotes: getEnvFloat("LEADERBOARD_WEIGHT_UPVOTES", 5),
 	}
 
 	return c
 }
 
+// defaultAllowedModels is used when GEMINI_ALLOWED_MODELS isn't set
+var defaultAllowedModels = []string{
+	"gemini-2.0-flash-exp",
+	"gemini-2.0-flash",
+	"gemini-2.0-flash-001",
+	"gemini-2.0-flash-lite",
+	"gemini-2.5-flash",
+	"gemini-2.5-flash-lite",
+	"gemini-2.5-pro",
+	"gemini-3-flash-preview",
+	"gemini-3-pro-preview",
+}
+
+// Formatting modes. warn records replies that break their coach's formatting limits; enforce
+// also rewrites them once to fit.
+const (
+	FormattingOff     = "off"
+	FormattingWarn    = "warn"
+	FormattingEnforce = "enforce"
+)
+
+// Validate catches misconfiguration that would otherwise only fail on the first request
+func (c Config) Validate() error {
+	models := []struct{ env, model string }{
+		{"GEMINI_MODEL_ID", c.ModelID},
+		{"GEMINI_MODEL_ID_PRO", c.ModelIDPro},
+	}
+	for _, m := range models {
+		if m.model == "" {
+			return fmt.Errorf("%s is empty", m.env)
+		}
+		if !c.ModelAllowed(m.model) {
+			return fmt.Errorf("%s=%q is not in the allowed models %v (set GEMINI_ALLOWED_MODELS to change)", m.env, m.model, c.AllowedModels)
+		}
+	}
+	switch c.FormattingMode {
+	case FormattingOff, FormattingWarn, FormattingEnforce:
+	default:
+		return fmt.Errorf("FORMATTING_MODE=%q must be off, warn, or enforce", c.FormattingMode)
+	}
+	return nil
+}
+
+// ModelAllowed reports whether model is on this environment's allow-list
+func (c Config) ModelAllowed(model string) bool {
+	for _, allowed := range c.AllowedModels {
+		if allowed == model {
+			return true
+		}
+	}
+	return false
+}
+
 func getEnv(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -67,6 +224,40 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+// getEnvList reads a comma-separated list, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvFloatMap reads comma-separated key=value pairs, skipping malformed entries
+func getEnvFloatMap(key string) map[string]float64 {
+	values := make(map[string]float64)
+	for _, pair := range getEnvList(key) {
+		name, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+			values[strings.TrimSpace(name)] = f
+		}
+	}
+	return values
+}
+
+// getEnvListOr reads a comma-separated list, falling back when the variable is unset or empty
+func getEnvListOr(key string, fallback []string) []string {
+	if values := getEnvList(key); len(values) > 0 {
+		return values
+	}
+	return fallback
+}
+
 func getEnvFloat(key string, fallback float32) float32 {
 	if value := os.Getenv(key); value != "" {
 		if f, err := strconv.ParseFloat(value, 32); err == nil {
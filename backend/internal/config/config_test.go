@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validConfig returns a config that passes Validate
+func validConfig() Config {
+	return Config{
+		ModelID:        "gemini-2.5-flash",
+		ModelIDPro:     "gemini-2.5-pro",
+		AllowedModels:  defaultAllowedModels,
+		FormattingMode: FormattingWarn,
+	}
+}
+
+func TestValidateModels(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{"unknown model", func(c *Config) { c.ModelID = "gemini-2.5-flsh" }, `GEMINI_MODEL_ID="gemini-2.5-flsh" is not in the allowed models`},
+		{"unknown pro model", func(c *Config) { c.ModelIDPro = "gpt-4o" }, `GEMINI_MODEL_ID_PRO="gpt-4o" is not in the allowed models`},
+		{"empty model", func(c *Config) { c.ModelID = "" }, "GEMINI_MODEL_ID is empty"},
+		{"model outside a narrowed allow-list", func(c *Config) { c.AllowedModels = []string{"gemini-2.5-flash"} }, "GEMINI_MODEL_ID_PRO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllowedModelsFromEnv(t *testing.T) {
+	t.Setenv("GEMINI_ALLOWED_MODELS", " gemini-2.5-flash, ,my-tuned-model ")
+	cfg := Load()
+	if !cfg.ModelAllowed("my-tuned-model") || !cfg.ModelAllowed("gemini-2.5-flash") {
+		t.Errorf("allowed models = %q, want the environment's list", cfg.AllowedModels)
+	}
+	if cfg.ModelAllowed("gemini-2.5-pro") || cfg.ModelAllowed("") {
+		t.Errorf("allowed models = %q, want only the environment's list", cfg.AllowedModels)
+	}
+}
@@ -0,0 +1,87 @@
+package entitlements
+
+import (
+	"sort"
+	"time"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/models"
+)
+
+// Pro is the RevenueCat entitlement that unlocks the paid tier
+const Pro = "pro"
+
+// Limits are the caps applied to users without Pro
+type Limits struct {
+	MomentsPerDay      int `json:"moments_per_day"`
+	MessagesPerSession int `json:"messages_per_session"`
+}
+
+// Policy describes what the free tier gets; enforcers and the entitlements endpoint share it
+type Policy struct {
+	FreeTier     Limits
+	ProOnlyTools []string
+}
+
+// PolicyFromConfig builds the entitlement policy from configuration
+func PolicyFromConfig(cfg config.Config) Policy {
+	return Policy{
+		FreeTier: Limits{
+			MomentsPerDay:      cfg.FreeTierMomentsPerDay,
+			MessagesPerSession: cfg.FreeTierMessagesPerSession,
+		},
+		ProOnlyTools: cfg.ProOnlyTools,
+	}
+}
+
+// Effective is the resolved set of entitlements and limits for one user
+type Effective struct {
+	Entitlements []string   `json:"entitlements"`
+	Pro          bool       `json:"pro"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Credits      int        `json:"credits"`
+	Limits       *Limits    `json:"limits"` // nil when Pro (no caps)
+	GatedTools   []string   `json:"gated_tools"`
+}
+
+// Resolve applies subscription expiry as of now and derives the user's effective entitlements
+func Resolve(user *models.User, policy Policy, now time.Time) Effective {
+	effective := Effective{
+		Entitlements: []string{},
+		GatedTools:   []string{},
+	}
+	if user == nil {
+		user = &models.User{}
+	}
+	effective.Credits = user.Credits
+
+	if user.SubscriptionCache != nil {
+		active := user.SubscriptionCache.ActiveEntitlements(now)
+		for entitlementID := range active {
+			effective.Entitlements = append(effective.Entitlements, entitlementID)
+		}
+		sort.Strings(effective.Entitlements)
+		effective.Pro = active[Pro]
+		if len(active) > 0 {
+			effective.ExpiresAt = user.SubscriptionCache.ExpiresDate
+		}
+	}
+
+	if !effective.Pro {
+		limits := policy.FreeTier
+		effective.Limits = &limits
+		effective.GatedTools = append(effective.GatedTools, policy.ProOnlyTools...)
+	}
+
+	return effective
+}
+
+// ToolAllowed reports whether the user may execute toolID
+func (e Effective) ToolAllowed(toolID string) bool {
+	for _, gated := range e.GatedTools {
+		if gated == toolID {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package entitlements
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"simon-backend/internal/models"
+)
+
+func TestResolve(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.AddDate(0, 1, 0)
+	policy := Policy{
+		FreeTier:     Limits{MomentsPerDay: 3, MessagesPerSession: 20},
+		ProOnlyTools: []string{"plan_create", "calendar_event_create"},
+	}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		wantPro    bool
+		wantExpiry *time.Time
+	}{
+		{"no user", nil, false, nil},
+		{"never subscribed", &models.User{Credits: 5}, false, nil},
+		{"expired pro", &models.User{Credits: 5, SubscriptionCache: &models.SubscriptionCache{
+			Entitlements: map[string]bool{Pro: true},
+			ExpiresDate:  &past,
+		}}, false, nil},
+		{"current pro", &models.User{SubscriptionCache: &models.SubscriptionCache{
+			Entitlements: map[string]bool{Pro: true},
+			ExpiresDate:  &future,
+		}}, true, &future},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(tt.user, policy, now)
+			if got.Pro != tt.wantPro {
+				t.Fatalf("pro = %v, want %v", got.Pro, tt.wantPro)
+			}
+			if (got.ExpiresAt == nil) != (tt.wantExpiry == nil) || (got.ExpiresAt != nil && !got.ExpiresAt.Equal(*tt.wantExpiry)) {
+				t.Errorf("expires = %v, want %v", got.ExpiresAt, tt.wantExpiry)
+			}
+
+			if tt.wantPro {
+				if !slices.Equal(got.Entitlements, []string{Pro}) || got.Limits != nil || len(got.GatedTools) != 0 {
+					t.Errorf("resolved %+v, want pro with no limits or gated tools", got)
+				}
+				if !got.ToolAllowed("plan_create") {
+					t.Error("pro user gated from plan_create")
+				}
+				return
+			}
+
+			if len(got.Entitlements) != 0 {
+				t.Errorf("entitlements = %v, want none", got.Entitlements)
+			}
+			if got.Limits == nil || *got.Limits != policy.FreeTier {
+				t.Errorf("limits = %v, want the free tier", got.Limits)
+			}
+			if !slices.Equal(got.GatedTools, policy.ProOnlyTools) {
+				t.Errorf("gated tools = %v, want %v", got.GatedTools, policy.ProOnlyTools)
+			}
+			if got.ToolAllowed("plan_create") || !got.ToolAllowed("plan_update") {
+				t.Error("ToolAllowed disagrees with the gated tools list")
+			}
+		})
+	}
+}
+
+func TestResolveDoesNotShareGatedTools(t *testing.T) {
+	policy := Policy{ProOnlyTools: []string{"plan_create"}}
+	got := Resolve(nil, policy, time.Now())
+	got.GatedTools[0] = "changed"
+	if policy.ProOnlyTools[0] != "plan_create" {
+		t.Error("resolved gated tools alias the policy")
+	}
+}
@@ -2,6 +2,8 @@ package firestore
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"cloud.google.com/go/firestore"
 	
@@ -65,6 +67,14 @@ func (c *Client) CreateSession(ctx context.Context, session models.Session) (str
 	return sessionID, nil
 }
 
+// IncrementCoachStarts counts one more session started with the coach
+func (c *Client) IncrementCoachStarts(ctx context.Context, coachID string) error {
+	_, err := c.DB.Collection("coaches").Doc(coachID).Update(ctx, []firestore.Update{
+		{Path: "stats.starts", Value: firestore.Increment(1)},
+	})
+	return WrapError("increment coach starts", err)
+}
+
 // AddMessage adds a message to a session
 func (c *Client) AddMessage(ctx context.Context, sessionID string, message models.Message) error {
 	return WithRetry(ctx, func() error {
@@ -153,6 +163,110 @@ func (c *Client) GetOrCreateUser(ctx context.Context, uid, email, displayName, p
 	return user, nil
 }
 
+// CompleteOnboarding seeds the user's context vault and tone from their onboarding answers and
+// stores the recommended starter coach. It only runs once; later calls return the user unchanged.
+func (c *Client) CompleteOnboarding(ctx context.Context, uid string, answers models.OnboardingAnswers) (*models.User, error) {
+	ref := c.DB.Collection("users").Doc(uid)
+	var user models.User
+	err := c.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		if user.OnboardedAt != nil {
+			return nil
+		}
+
+		answers = answers.Normalize()
+		now := models.Now()
+		user.ContextVault = answers.SeedContext(user.ContextVault)
+		user.StarterCoachID = answers.RecommendStarterCoach()
+		user.OnboardedAt = &now
+		user.UpdatedAt = now
+		if answers.PreferredTone != "" {
+			user.Preferences.PreferredTone = answers.PreferredTone
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "context_vault", Value: user.ContextVault},
+			{Path: "preferences.preferred_tone", Value: user.Preferences.PreferredTone},
+			{Path: "starter_coach_id", Value: user.StarterCoachID},
+			{Path: "onboarded_at", Value: now},
+			{Path: "updated_at", Value: now},
+		})
+	})
+	if err != nil {
+		return nil, WrapError("complete onboarding", err)
+	}
+	return &user, nil
+}
+
+// ReserveCredits takes amount credits from the user before a reply is generated, so concurrent
+// requests can't spend the same credits. It reports false, leaving the balance untouched, when the
+// user has fewer than amount left.
+func (c *Client) ReserveCredits(ctx context.Context, uid string, amount int) (bool, error) {
+	ref := c.DB.Collection("users").Doc(uid)
+	reserved := false
+	err := c.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		reserved = false
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		if user.Credits < amount {
+			return nil
+		}
+		reserved = true
+		return tx.Update(ref, []firestore.Update{
+			{Path: "credits", Value: user.Credits - amount},
+			{Path: "updated_at", Value: models.Now()},
+		})
+	})
+	if err != nil {
+		return false, WrapError("reserve credits", err)
+	}
+	return reserved, nil
+}
+
+// SettleCredits charges cost against the reserved credits taken by ReserveCredits: any unused
+// part of the reservation is refunded, and a cost beyond it is deducted without going below zero.
+// It returns the new balance.
+func (c *Client) SettleCredits(ctx context.Context, uid string, reserved, cost int) (int, error) {
+	ref := c.DB.Collection("users").Doc(uid)
+	remaining := 0
+	err := c.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		remaining = user.Credits + reserved - cost
+		if remaining < 0 {
+			remaining = 0
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "credits", Value: remaining},
+			{Path: "updated_at", Value: models.Now()},
+		})
+	})
+	if err != nil {
+		return 0, WrapError("settle credits", err)
+	}
+	return remaining, nil
+}
+
 // UpdateUser updates a user's profile
 func (c *Client) UpdateUser(ctx context.Context, uid string, updates map[string]interface{}) error {
 	updates["updated_at"] = models.Now()
@@ -180,6 +294,61 @@ func (c *Client) UpdateUserPreference(ctx context.Context, uid string, key strin
 	return err
 }
 
+// ScheduleUserDeletion marks the user pending deletion until purgeAfter
+func (c *Client) ScheduleUserDeletion(ctx context.Context, uid string, purgeAfter time.Time) error {
+	_, err := c.DB.Collection("users").Doc(uid).Update(ctx, []firestore.Update{
+		{Path: "status", Value: models.UserStatusPendingDeletion},
+		{Path: "purge_after", Value: purgeAfter},
+		{Path: "updated_at", Value: models.Now()},
+	})
+	return err
+}
+
+// CancelUserDeletion returns a pending-deletion user to active
+func (c *Client) CancelUserDeletion(ctx context.Context, uid string) error {
+	_, err := c.DB.Collection("users").Doc(uid).Update(ctx, []firestore.Update{
+		{Path: "status", Value: firestore.Delete},
+		{Path: "purge_after", Value: firestore.Delete},
+		{Path: "updated_at", Value: models.Now()},
+	})
+	return err
+}
+
+// deleteBatchSize keeps each delete batch under Firestore's 500-write limit
+const deleteBatchSize = 400
+
+// DeleteSession deletes a session and its messages. Firestore doesn't cascade deletes, so the
+// messages go first, in batches; if that fails partway the session remains and can be deleted again.
+func (c *Client) DeleteSession(ctx context.Context, sessionID string) error {
+	ref := c.DB.Collection("sessions").Doc(sessionID)
+	if err := c.deleteCollection(ctx, ref.Collection("messages")); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	_, err := ref.Delete(ctx)
+	return err
+}
+
+// deleteCollection deletes every document in a collection, deleteBatchSize at a time
+func (c *Client) deleteCollection(ctx context.Context, collection *firestore.CollectionRef) error {
+	for {
+		docs, err := collection.Limit(deleteBatchSize).Documents(ctx).GetAll()
+		if err != nil {
+			return err
+		}
+		if len(docs) == 0 {
+			return nil
+		}
+
+		batch := c.DB.Batch()
+		for _, doc := range docs {
+			batch.Delete(doc.Ref)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+	}
+}
+
 // DeleteAllUserData deletes all data for a user
 func (c *Client) DeleteAllUserData(ctx context.Context, uid string) error {
 	batch := c.DB.Batch()
@@ -202,9 +371,8 @@ func (c *Client) DeleteAllUserData(ctx context.Context, uid string) error {
 	if err == nil {
 		for _, doc := range sessionsDocs {
 			// Delete messages subcollection
-			messages, _ := doc.Ref.Collection("messages").Documents(ctx).GetAll()
-			for _, msg := range messages {
-				batch.Delete(msg.Ref)
+			if err := c.deleteCollection(ctx, doc.Ref.Collection("messages")); err != nil {
+				return fmt.Errorf("failed to delete messages of session %s: %w", doc.Ref.ID, err)
 			}
 			batch.Delete(doc.Ref)
 		}
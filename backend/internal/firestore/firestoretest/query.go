@@ -0,0 +1,377 @@
+package firestoretest
+
+import (
+	"sort"
+	"strings"
+
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// runQuery evaluates a structured query against the stored documents. The caller holds s.mu.
+func (s *Server) runQuery(parent string, q *pb.StructuredQuery) ([]*pb.Document, error) {
+	if len(q.From) != 1 {
+		return nil, status.Errorf(codes.Unimplemented, "firestoretest: queries need exactly one collection, got %d", len(q.From))
+	}
+	if q.FindNearest != nil {
+		return nil, status.Error(codes.Unimplemented, "firestoretest: vector search is not supported")
+	}
+	from := q.From[0]
+
+	var docs []*pb.Document
+	for name, doc := range s.docs {
+		if !inCollection(name, parent, from) {
+			continue
+		}
+		ok, err := matchesFilter(doc, q.Where)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			docs = append(docs, doc)
+		}
+	}
+
+	orders := queryOrders(q)
+	docs = withOrderFields(docs, orders)
+	sort.SliceStable(docs, func(i, j int) bool {
+		return compareDocs(docs[i], docs[j], orders) < 0
+	})
+
+	if q.StartAt != nil {
+		docs = afterCursor(docs, orders, q.StartAt)
+	}
+	if q.EndAt != nil {
+		docs = beforeCursor(docs, orders, q.EndAt)
+	}
+
+	if offset := int(q.Offset); offset > 0 {
+		if offset > len(docs) {
+			offset = len(docs)
+		}
+		docs = docs[offset:]
+	}
+	if q.Limit != nil && int(q.Limit.Value) < len(docs) {
+		docs = docs[:q.Limit.Value]
+	}
+
+	results := make([]*pb.Document, len(docs))
+	for i, doc := range docs {
+		results[i] = project(doc, q.Select)
+	}
+	return results, nil
+}
+
+// inCollection reports whether the named document sits in the query's collection
+func inCollection(name, parent string, from *pb.StructuredQuery_CollectionSelector) bool {
+	collPath := name[:strings.LastIndex(name, "/")]
+	if collPath[strings.LastIndex(collPath, "/")+1:] != from.CollectionId {
+		return false
+	}
+	if from.AllDescendants {
+		return strings.HasPrefix(collPath, parent+"/")
+	}
+	return collPath == parent+"/"+from.CollectionId
+}
+
+// queryOrders returns the explicit orderings followed by the implicit ones Firestore adds:
+// inequality fields, then the document name
+func queryOrders(q *pb.StructuredQuery) []*pb.StructuredQuery_Order {
+	orders := append([]*pb.StructuredQuery_Order{}, q.OrderBy...)
+	ordered := map[string]bool{}
+	for _, o := range orders {
+		ordered[o.Field.FieldPath] = true
+	}
+
+	if len(orders) == 0 {
+		for _, field := range inequalityFields(q.Where) {
+			if !ordered[field] {
+				ordered[field] = true
+				orders = append(orders, &pb.StructuredQuery_Order{
+					Field:     &pb.StructuredQuery_FieldReference{FieldPath: field},
+					Direction: pb.StructuredQuery_ASCENDING,
+				})
+			}
+		}
+	}
+
+	if !ordered[nameField] {
+		direction := pb.StructuredQuery_ASCENDING
+		if len(orders) > 0 {
+			direction = orders[len(orders)-1].Direction
+		}
+		orders = append(orders, &pb.StructuredQuery_Order{
+			Field:     &pb.StructuredQuery_FieldReference{FieldPath: nameField},
+			Direction: direction,
+		})
+	}
+	return orders
+}
+
+// inequalityFields lists the fields compared with a range or not-equal operator
+func inequalityFields(f *pb.StructuredQuery_Filter) []string {
+	if f == nil {
+		return nil
+	}
+	switch ft := f.FilterType.(type) {
+	case *pb.StructuredQuery_Filter_CompositeFilter:
+		var fields []string
+		for _, sub := range ft.CompositeFilter.Filters {
+			fields = append(fields, inequalityFields(sub)...)
+		}
+		return fields
+	case *pb.StructuredQuery_Filter_FieldFilter:
+		switch ft.FieldFilter.Op {
+		case pb.StructuredQuery_FieldFilter_LESS_THAN, pb.StructuredQuery_FieldFilter_LESS_THAN_OR_EQUAL,
+			pb.StructuredQuery_FieldFilter_GREATER_THAN, pb.StructuredQuery_FieldFilter_GREATER_THAN_OR_EQUAL,
+			pb.StructuredQuery_FieldFilter_NOT_EQUAL, pb.StructuredQuery_FieldFilter_NOT_IN:
+			return []string{ft.FieldFilter.Field.FieldPath}
+		}
+	}
+	return nil
+}
+
+// withOrderFields drops documents missing a field the query orders by, as Firestore does
+func withOrderFields(docs []*pb.Document, orders []*pb.StructuredQuery_Order) []*pb.Document {
+	kept := docs[:0]
+	for _, doc := range docs {
+		complete := true
+		for _, o := range orders {
+			if _, ok := docField(doc, o.Field.FieldPath); !ok {
+				complete = false
+				break
+			}
+		}
+		if complete {
+			kept = append(kept, doc)
+		}
+	}
+	return kept
+}
+
+func compareDocs(a, b *pb.Document, orders []*pb.StructuredQuery_Order) int {
+	for _, o := range orders {
+		av, _ := docField(a, o.Field.FieldPath)
+		bv, _ := docField(b, o.Field.FieldPath)
+		c := compareValues(av, bv)
+		if o.Direction == pb.StructuredQuery_DESCENDING {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// compareToCursor orders a document against a cursor's values (a prefix of the orderings)
+func compareToCursor(doc *pb.Document, orders []*pb.StructuredQuery_Order, cursor *pb.Cursor) int {
+	for i, value := range cursor.Values {
+		if i >= len(orders) {
+			break
+		}
+		v, _ := docField(doc, orders[i].Field.FieldPath)
+		c := compareValues(v, value)
+		if orders[i].Direction == pb.StructuredQuery_DESCENDING {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// afterCursor keeps documents at or after a start cursor; Before means the cursor's own position is included
+func afterCursor(docs []*pb.Document, orders []*pb.StructuredQuery_Order, cursor *pb.Cursor) []*pb.Document {
+	for i, doc := range docs {
+		c := compareToCursor(doc, orders, cursor)
+		if c > 0 || (c == 0 && cursor.Before) {
+			return docs[i:]
+		}
+	}
+	return nil
+}
+
+// beforeCursor keeps documents before an end cursor; Before means the cursor's own position is excluded
+func beforeCursor(docs []*pb.Document, orders []*pb.StructuredQuery_Order, cursor *pb.Cursor) []*pb.Document {
+	for i, doc := range docs {
+		c := compareToCursor(doc, orders, cursor)
+		if c > 0 || (c == 0 && cursor.Before) {
+			return docs[:i]
+		}
+	}
+	return docs
+}
+
+// project copies a document keeping only the selected fields
+func project(doc *pb.Document, sel *pb.StructuredQuery_Projection) *pb.Document {
+	out := proto.Clone(doc).(*pb.Document)
+	if sel == nil || len(sel.Fields) == 0 {
+		return out
+	}
+	out.Fields = map[string]*pb.Value{}
+	for _, f := range sel.Fields {
+		if f.FieldPath == nameField {
+			continue
+		}
+		path := parseFieldPath(f.FieldPath)
+		if v, ok := getField(doc.Fields, path); ok {
+			setField(out.Fields, path, v)
+		}
+	}
+	return out
+}
+
+func matchesFilter(doc *pb.Document, f *pb.StructuredQuery_Filter) (bool, error) {
+	if f == nil {
+		return true, nil
+	}
+	switch ft := f.FilterType.(type) {
+	case *pb.StructuredQuery_Filter_CompositeFilter:
+		or := ft.CompositeFilter.Op == pb.StructuredQuery_CompositeFilter_OR
+		for _, sub := range ft.CompositeFilter.Filters {
+			ok, err := matchesFilter(doc, sub)
+			if err != nil {
+				return false, err
+			}
+			if ok == or {
+				return or, nil
+			}
+		}
+		return !or, nil
+	case *pb.StructuredQuery_Filter_FieldFilter:
+		return matchesFieldFilter(doc, ft.FieldFilter)
+	case *pb.StructuredQuery_Filter_UnaryFilter:
+		return matchesUnaryFilter(doc, ft.UnaryFilter)
+	}
+	return false, status.Errorf(codes.Unimplemented, "firestoretest: unsupported filter %T", f.FilterType)
+}
+
+func matchesFieldFilter(doc *pb.Document, f *pb.StructuredQuery_FieldFilter) (bool, error) {
+	v, ok := docField(doc, f.Field.FieldPath)
+	if !ok {
+		return false, nil
+	}
+	want := f.Value
+
+	switch f.Op {
+	case pb.StructuredQuery_FieldFilter_EQUAL:
+		return valuesEqual(v, want), nil
+	case pb.StructuredQuery_FieldFilter_NOT_EQUAL:
+		return !isNull(v) && !valuesEqual(v, want), nil
+	case pb.StructuredQuery_FieldFilter_LESS_THAN:
+		return comparable(v, want) && compareValues(v, want) < 0, nil
+	case pb.StructuredQuery_FieldFilter_LESS_THAN_OR_EQUAL:
+		return comparable(v, want) && compareValues(v, want) <= 0, nil
+	case pb.StructuredQuery_FieldFilter_GREATER_THAN:
+		return comparable(v, want) && compareValues(v, want) > 0, nil
+	case pb.StructuredQuery_FieldFilter_GREATER_THAN_OR_EQUAL:
+		return comparable(v, want) && compareValues(v, want) >= 0, nil
+	case pb.StructuredQuery_FieldFilter_ARRAY_CONTAINS:
+		return containsValue(v.GetArrayValue().GetValues(), want), nil
+	case pb.StructuredQuery_FieldFilter_IN:
+		return containsValue(want.GetArrayValue().GetValues(), v), nil
+	case pb.StructuredQuery_FieldFilter_NOT_IN:
+		return !isNull(v) && !containsValue(want.GetArrayValue().GetValues(), v), nil
+	case pb.StructuredQuery_FieldFilter_ARRAY_CONTAINS_ANY:
+		for _, elem := range v.GetArrayValue().GetValues() {
+			if containsValue(want.GetArrayValue().GetValues(), elem) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, status.Errorf(codes.Unimplemented, "firestoretest: unsupported operator %v", f.Op)
+}
+
+func matchesUnaryFilter(doc *pb.Document, f *pb.StructuredQuery_UnaryFilter) (bool, error) {
+	v, ok := docField(doc, f.GetField().GetFieldPath())
+	if !ok {
+		return false, nil
+	}
+	switch f.Op {
+	case pb.StructuredQuery_UnaryFilter_IS_NULL:
+		return isNull(v), nil
+	case pb.StructuredQuery_UnaryFilter_IS_NOT_NULL:
+		return !isNull(v), nil
+	case pb.StructuredQuery_UnaryFilter_IS_NAN:
+		return isNaN(v), nil
+	case pb.StructuredQuery_UnaryFilter_IS_NOT_NAN:
+		return !isNaN(v), nil
+	}
+	return false, status.Errorf(codes.Unimplemented, "firestoretest: unsupported operator %v", f.Op)
+}
+
+// comparable reports whether a range filter may compare v and want; Firestore only compares
+// values of the same type
+func comparable(v, want *pb.Value) bool {
+	return typeOrder(v) == typeOrder(want) && !isNaN(v) && !isNaN(want)
+}
+
+func containsValue(values []*pb.Value, v *pb.Value) bool {
+	for _, candidate := range values {
+		if valuesEqual(candidate, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregate computes the query's aggregations over its results
+func aggregate(docs []*pb.Document, aggs []*pb.StructuredAggregationQuery_Aggregation) (map[string]*pb.Value, error) {
+	fields := map[string]*pb.Value{}
+	for _, agg := range aggs {
+		switch op := agg.Operator.(type) {
+		case *pb.StructuredAggregationQuery_Aggregation_Count_:
+			n := int64(len(docs))
+			if upTo := op.Count.GetUpTo(); upTo != nil && upTo.Value < n {
+				n = upTo.Value
+			}
+			fields[agg.Alias] = &pb.Value{ValueType: &pb.Value_IntegerValue{IntegerValue: n}}
+		case *pb.StructuredAggregationQuery_Aggregation_Sum_:
+			fields[agg.Alias] = sumField(docs, op.Sum.GetField().GetFieldPath())
+		case *pb.StructuredAggregationQuery_Aggregation_Avg_:
+			sum, count := 0.0, 0
+			for _, doc := range docs {
+				if v, ok := docField(doc, op.Avg.GetField().GetFieldPath()); ok && isNumber(v) {
+					sum += toFloat(v)
+					count++
+				}
+			}
+			if count == 0 {
+				fields[agg.Alias] = &pb.Value{ValueType: &pb.Value_NullValue{}}
+			} else {
+				fields[agg.Alias] = &pb.Value{ValueType: &pb.Value_DoubleValue{DoubleValue: sum / float64(count)}}
+			}
+		default:
+			return nil, status.Errorf(codes.Unimplemented, "firestoretest: unsupported aggregation %T", agg.Operator)
+		}
+	}
+	return fields, nil
+}
+
+// sumField adds a numeric field, staying an integer while every value is one
+func sumField(docs []*pb.Document, path string) *pb.Value {
+	var intSum int64
+	floatSum := 0.0
+	allInts := true
+	for _, doc := range docs {
+		v, ok := docField(doc, path)
+		if !ok || !isNumber(v) {
+			continue
+		}
+		if i, ok := v.ValueType.(*pb.Value_IntegerValue); ok {
+			intSum += i.IntegerValue
+		} else {
+			allInts = false
+		}
+		floatSum += toFloat(v)
+	}
+	if allInts {
+		return &pb.Value{ValueType: &pb.Value_IntegerValue{IntegerValue: intSum}}
+	}
+	return &pb.Value{ValueType: &pb.Value_DoubleValue{DoubleValue: floatSum}}
+}
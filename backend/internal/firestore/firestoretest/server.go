@@ -0,0 +1,492 @@
+// Package firestoretest runs an in-memory Firestore backend for tests. It serves the Firestore
+// gRPC API, so code under test uses the real client: documents, queries (filters, ordering,
+// cursors, offsets, limits, collection groups), count/sum/avg aggregations, batched writes,
+// field transforms and transactions.
+//
+// Transactions are optimistic: a commit is aborted when a document or query the transaction
+// read has changed since, and the client then retries it, so concurrent transactions
+// serialize the way they do against Firestore.
+package firestoretest
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	fsClient "simon-backend/internal/firestore"
+)
+
+// ProjectID is the project the test client is created for
+const ProjectID = "test-project"
+
+// Server is the in-memory Firestore backend
+type Server struct {
+	pb.UnimplementedFirestoreServer
+
+	mu   sync.Mutex
+	docs map[string]*pb.Document
+	txns map[string]*txn
+	last time.Time
+}
+
+// txn is what a read-write transaction has read, to be validated at commit
+type txn struct {
+	readOnly bool
+	reads    map[string]time.Time // document name -> update time when read (zero if missing)
+	queries  []queryRead
+}
+
+type queryRead struct {
+	parent string
+	query  *pb.StructuredQuery
+	result string
+}
+
+// New starts a server and returns a client connected to it. Both are shut down when the
+// test finishes.
+func New(t testing.TB) *fsClient.Client {
+	t.Helper()
+	client, _ := NewWithServer(t)
+	return client
+}
+
+// NewWithServer is New, also returning the server
+func NewWithServer(t testing.TB) (*fsClient.Client, *Server) {
+	t.Helper()
+
+	srv := &Server{
+		docs: map[string]*pb.Document{},
+		txns: map[string]*txn{},
+	}
+	listener := bufconn.Listen(1 << 20)
+	gs := grpc.NewServer()
+	pb.RegisterFirestoreServer(gs, srv)
+	go gs.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///firestoretest",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("firestoretest: dial: %v", err)
+	}
+
+	db, err := firestore.NewClient(context.Background(), ProjectID, option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatalf("firestoretest: new client: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		conn.Close()
+		gs.Stop()
+	})
+	return &fsClient.Client{DB: db}, srv
+}
+
+// Len returns the number of stored documents
+func (s *Server) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.docs)
+}
+
+// GetDocument implements a single-document read
+func (s *Server) GetDocument(ctx context.Context, req *pb.GetDocumentRequest) (*pb.Document, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc, ok := s.docs[req.Name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s not found", req.Name)
+	}
+	return proto.Clone(doc).(*pb.Document), nil
+}
+
+// BatchGetDocuments reads documents, recording the reads against any transaction
+func (s *Server) BatchGetDocuments(req *pb.BatchGetDocumentsRequest, stream pb.Firestore_BatchGetDocumentsServer) error {
+	s.mu.Lock()
+	tx, err := s.txnFor(req.GetTransaction())
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	readTime := timestamppb.New(s.now())
+	var resps []*pb.BatchGetDocumentsResponse
+	seen := map[string]bool{}
+	for _, name := range req.Documents {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		doc, ok := s.docs[name]
+		if tx != nil {
+			tx.reads[name] = updateTime(doc)
+		}
+		resp := &pb.BatchGetDocumentsResponse{ReadTime: readTime}
+		if ok {
+			resp.Result = &pb.BatchGetDocumentsResponse_Found{Found: proto.Clone(doc).(*pb.Document)}
+		} else {
+			resp.Result = &pb.BatchGetDocumentsResponse_Missing{Missing: name}
+		}
+		resps = append(resps, resp)
+	}
+	s.mu.Unlock()
+
+	for _, resp := range resps {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunQuery runs a structured query, recording its result against any transaction
+func (s *Server) RunQuery(req *pb.RunQueryRequest, stream pb.Firestore_RunQueryServer) error {
+	s.mu.Lock()
+	tx, err := s.txnFor(req.GetTransaction())
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	docs, err := s.runQuery(req.Parent, req.GetStructuredQuery())
+	if err == nil && tx != nil {
+		tx.queries = append(tx.queries, queryRead{req.Parent, req.GetStructuredQuery(), resultSignature(docs)})
+	}
+	readTime := timestamppb.New(s.now())
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(docs) == 0 {
+		return stream.Send(&pb.RunQueryResponse{ReadTime: readTime})
+	}
+	for _, doc := range docs {
+		if err := stream.Send(&pb.RunQueryResponse{Document: doc, ReadTime: readTime}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunAggregationQuery computes aggregations over a structured query
+func (s *Server) RunAggregationQuery(req *pb.RunAggregationQueryRequest, stream pb.Firestore_RunAggregationQueryServer) error {
+	agg := req.GetStructuredAggregationQuery()
+	s.mu.Lock()
+	tx, err := s.txnFor(req.GetTransaction())
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	docs, err := s.runQuery(req.Parent, agg.GetStructuredQuery())
+	if err == nil && tx != nil {
+		tx.queries = append(tx.queries, queryRead{req.Parent, agg.GetStructuredQuery(), resultSignature(docs)})
+	}
+	readTime := timestamppb.New(s.now())
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	fields, err := aggregate(docs, agg.Aggregations)
+	if err != nil {
+		return err
+	}
+	return stream.Send(&pb.RunAggregationQueryResponse{
+		Result:   &pb.AggregationResult{AggregateFields: fields},
+		ReadTime: readTime,
+	})
+}
+
+// BeginTransaction starts a transaction
+func (s *Server) BeginTransaction(ctx context.Context, req *pb.BeginTransactionRequest) (*pb.BeginTransactionResponse, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, status.Errorf(codes.Internal, "firestoretest: transaction id: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txns[string(id)] = &txn{
+		readOnly: req.GetOptions().GetReadOnly() != nil,
+		reads:    map[string]time.Time{},
+	}
+	return &pb.BeginTransactionResponse{Transaction: id}, nil
+}
+
+// Rollback discards a transaction
+func (s *Server) Rollback(ctx context.Context, req *pb.RollbackRequest) (*emptypb.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.txns, string(req.Transaction))
+	return &emptypb.Empty{}, nil
+}
+
+// Commit applies writes atomically. In a transaction the commit is aborted when anything the
+// transaction read has changed since.
+func (s *Server) Commit(ctx context.Context, req *pb.CommitRequest) (*pb.CommitResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(req.Transaction) > 0 {
+		tx, ok := s.txns[string(req.Transaction)]
+		if !ok {
+			return nil, status.Error(codes.InvalidArgument, "firestoretest: unknown transaction")
+		}
+		delete(s.txns, string(req.Transaction))
+		if err := s.validate(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	commitTime := s.now()
+	staged := map[string]*pb.Document{}
+	results := make([]*pb.WriteResult, len(req.Writes))
+	for i, w := range req.Writes {
+		result, err := s.apply(staged, w, commitTime)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+
+	for name, doc := range staged {
+		if doc == nil {
+			delete(s.docs, name)
+		} else {
+			s.docs[name] = doc
+		}
+	}
+	return &pb.CommitResponse{WriteResults: results, CommitTime: timestamppb.New(commitTime)}, nil
+}
+
+// txnFor returns the transaction a read belongs to, or nil outside one (or for a read-only one)
+func (s *Server) txnFor(id []byte) (*txn, error) {
+	if len(id) == 0 {
+		return nil, nil
+	}
+	tx, ok := s.txns[string(id)]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, "firestoretest: unknown transaction")
+	}
+	if tx.readOnly {
+		return nil, nil
+	}
+	return tx, nil
+}
+
+// validate aborts a transaction whose reads are stale
+func (s *Server) validate(tx *txn) error {
+	for name, readAt := range tx.reads {
+		if !updateTime(s.docs[name]).Equal(readAt) {
+			return status.Errorf(codes.Aborted, "firestoretest: %s changed during the transaction", name)
+		}
+	}
+	for _, q := range tx.queries {
+		docs, err := s.runQuery(q.parent, q.query)
+		if err != nil {
+			return err
+		}
+		if resultSignature(docs) != q.result {
+			return status.Error(codes.Aborted, "firestoretest: query results changed during the transaction")
+		}
+	}
+	return nil
+}
+
+// current returns a document as it stands within the commit being applied
+func (s *Server) current(staged map[string]*pb.Document, name string) *pb.Document {
+	if doc, ok := staged[name]; ok {
+		return doc
+	}
+	return s.docs[name]
+}
+
+// apply stages one write
+func (s *Server) apply(staged map[string]*pb.Document, w *pb.Write, commitTime time.Time) (*pb.WriteResult, error) {
+	var name string
+	switch op := w.Operation.(type) {
+	case *pb.Write_Update:
+		name = op.Update.Name
+	case *pb.Write_Delete:
+		name = op.Delete
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "firestoretest: unsupported write %T", w.Operation)
+	}
+
+	existing := s.current(staged, name)
+	if err := checkPrecondition(name, existing, w.CurrentDocument); err != nil {
+		return nil, err
+	}
+
+	result := &pb.WriteResult{UpdateTime: timestamppb.New(commitTime)}
+	if _, ok := w.Operation.(*pb.Write_Delete); ok {
+		staged[name] = nil
+		return result, nil
+	}
+
+	update := w.GetUpdate()
+	doc := &pb.Document{Name: name, Fields: map[string]*pb.Value{}, CreateTime: timestamppb.New(commitTime)}
+	if existing != nil {
+		doc.CreateTime = existing.CreateTime
+	}
+
+	if w.UpdateMask == nil {
+		// No mask replaces the whole document
+		for k, v := range update.Fields {
+			doc.Fields[k] = proto.Clone(v).(*pb.Value)
+		}
+	} else {
+		if existing != nil {
+			doc.Fields = proto.Clone(existing).(*pb.Document).Fields
+			if doc.Fields == nil {
+				doc.Fields = map[string]*pb.Value{}
+			}
+		}
+		for _, fieldPath := range w.UpdateMask.FieldPaths {
+			path := parseFieldPath(fieldPath)
+			if v, ok := getField(update.Fields, path); ok {
+				setField(doc.Fields, path, v)
+			} else {
+				deleteField(doc.Fields, path)
+			}
+		}
+	}
+
+	for _, transform := range w.UpdateTransforms {
+		v, err := applyTransform(doc.Fields, transform, commitTime)
+		if err != nil {
+			return nil, err
+		}
+		result.TransformResults = append(result.TransformResults, v)
+	}
+
+	doc.UpdateTime = timestamppb.New(commitTime)
+	staged[name] = doc
+	return result, nil
+}
+
+func checkPrecondition(name string, existing *pb.Document, pc *pb.Precondition) error {
+	if pc == nil {
+		return nil
+	}
+	switch c := pc.ConditionType.(type) {
+	case *pb.Precondition_Exists:
+		if c.Exists && existing == nil {
+			return status.Errorf(codes.NotFound, "no document to update: %s", name)
+		}
+		if !c.Exists && existing != nil {
+			return status.Errorf(codes.AlreadyExists, "document already exists: %s", name)
+		}
+	case *pb.Precondition_UpdateTime:
+		if existing == nil || !existing.UpdateTime.AsTime().Equal(c.UpdateTime.AsTime()) {
+			return status.Errorf(codes.FailedPrecondition, "update time mismatch: %s", name)
+		}
+	}
+	return nil
+}
+
+// applyTransform applies a field transform in place and returns the field's new value
+func applyTransform(fields map[string]*pb.Value, t *pb.DocumentTransform_FieldTransform, commitTime time.Time) (*pb.Value, error) {
+	path := parseFieldPath(t.FieldPath)
+	current, exists := getField(fields, path)
+
+	var next *pb.Value
+	switch tt := t.TransformType.(type) {
+	case *pb.DocumentTransform_FieldTransform_SetToServerValue:
+		next = &pb.Value{ValueType: &pb.Value_TimestampValue{TimestampValue: timestamppb.New(commitTime)}}
+	case *pb.DocumentTransform_FieldTransform_Increment:
+		next = tt.Increment
+		if exists && isNumber(current) {
+			next = addNumbers(current, tt.Increment)
+		}
+	case *pb.DocumentTransform_FieldTransform_Maximum:
+		next = tt.Maximum
+		if exists && isNumber(current) && compareNumbers(current, tt.Maximum) >= 0 {
+			next = current
+		}
+	case *pb.DocumentTransform_FieldTransform_Minimum:
+		next = tt.Minimum
+		if exists && isNumber(current) && compareNumbers(current, tt.Minimum) <= 0 {
+			next = current
+		}
+	case *pb.DocumentTransform_FieldTransform_AppendMissingElements:
+		values := append([]*pb.Value{}, current.GetArrayValue().GetValues()...)
+		for _, v := range tt.AppendMissingElements.GetValues() {
+			if !containsValue(values, v) {
+				values = append(values, v)
+			}
+		}
+		next = &pb.Value{ValueType: &pb.Value_ArrayValue{ArrayValue: &pb.ArrayValue{Values: values}}}
+	case *pb.DocumentTransform_FieldTransform_RemoveAllFromArray:
+		values := []*pb.Value{}
+		for _, v := range current.GetArrayValue().GetValues() {
+			if !containsValue(tt.RemoveAllFromArray.GetValues(), v) {
+				values = append(values, v)
+			}
+		}
+		next = &pb.Value{ValueType: &pb.Value_ArrayValue{ArrayValue: &pb.ArrayValue{Values: values}}}
+	default:
+		return nil, status.Errorf(codes.Unimplemented, "firestoretest: unsupported transform %T", t.TransformType)
+	}
+
+	setField(fields, path, next)
+	return proto.Clone(next).(*pb.Value), nil
+}
+
+// addNumbers adds two numbers, staying an integer when both are
+func addNumbers(a, b *pb.Value) *pb.Value {
+	ai, aInt := a.ValueType.(*pb.Value_IntegerValue)
+	bi, bInt := b.ValueType.(*pb.Value_IntegerValue)
+	if aInt && bInt {
+		return &pb.Value{ValueType: &pb.Value_IntegerValue{IntegerValue: ai.IntegerValue + bi.IntegerValue}}
+	}
+	return &pb.Value{ValueType: &pb.Value_DoubleValue{DoubleValue: toFloat(a) + toFloat(b)}}
+}
+
+// now returns a strictly increasing microsecond timestamp, so every commit has its own time
+func (s *Server) now() time.Time {
+	t := time.Now().UTC().Truncate(time.Microsecond)
+	if !t.After(s.last) {
+		t = s.last.Add(time.Microsecond)
+	}
+	s.last = t
+	return t
+}
+
+func updateTime(doc *pb.Document) time.Time {
+	if doc == nil {
+		return time.Time{}
+	}
+	return doc.UpdateTime.AsTime()
+}
+
+// resultSignature identifies a query result by its documents and their versions
+func resultSignature(docs []*pb.Document) string {
+	sig := ""
+	for _, doc := range docs {
+		sig += fmt.Sprintf("%s@%d;", doc.Name, doc.UpdateTime.AsTime().UnixNano())
+	}
+	return sig
+}
@@ -0,0 +1,222 @@
+package firestoretest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type item struct {
+	Name  string    `firestore:"name"`
+	Rank  int       `firestore:"rank"`
+	Tags  []string  `firestore:"tags,omitempty"`
+	Stats itemStats `firestore:"stats"`
+	When  time.Time `firestore:"when,omitempty"`
+}
+
+type itemStats struct {
+	Views int `firestore:"views"`
+	Likes int `firestore:"likes"`
+}
+
+func ids(t *testing.T, q firestore.Query) []string {
+	t.Helper()
+	docs, err := q.Documents(context.Background()).GetAll()
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	out := []string{}
+	for _, doc := range docs {
+		out = append(out, doc.Ref.ID)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDocumentWrites(t *testing.T) {
+	ctx := context.Background()
+	db := New(t).DB
+	ref := db.Collection("items").Doc("a")
+
+	if _, err := ref.Create(ctx, item{Name: "a", Rank: 1, Stats: itemStats{Views: 5, Likes: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ref.Create(ctx, item{Name: "again"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("second Create: got %v, want AlreadyExists", err)
+	}
+	if _, err := db.Collection("items").Doc("missing").Update(ctx, []firestore.Update{{Path: "rank", Value: 1}}); status.Code(err) != codes.NotFound {
+		t.Errorf("Update of a missing doc: got %v, want NotFound", err)
+	}
+
+	if _, err := ref.Update(ctx, []firestore.Update{
+		{Path: "stats.views", Value: firestore.Increment(3)},
+		{Path: "tags", Value: firestore.ArrayUnion("x", "y")},
+		{Path: "when", Value: firestore.ServerTimestamp},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ref.Update(ctx, []firestore.Update{{Path: "tags", Value: firestore.ArrayUnion("y", "z")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got item
+	if err := doc.DataTo(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Stats.Views != 8 || got.Stats.Likes != 2 {
+		t.Errorf("stats = %+v, want views 8 and likes kept at 2", got.Stats)
+	}
+	if !equal(got.Tags, []string{"x", "y", "z"}) {
+		t.Errorf("tags = %v, want [x y z]", got.Tags)
+	}
+	if got.When.IsZero() {
+		t.Error("server timestamp not set")
+	}
+
+	if _, err := ref.Set(ctx, map[string]interface{}{"name": "merged"}, firestore.MergeAll); err != nil {
+		t.Fatal(err)
+	}
+	doc, _ = ref.Get(ctx)
+	if doc.Data()["rank"] != int64(1) || doc.Data()["name"] != "merged" {
+		t.Errorf("merge set: %v", doc.Data())
+	}
+
+	if _, err := ref.Set(ctx, map[string]interface{}{"name": "replaced"}); err != nil {
+		t.Fatal(err)
+	}
+	doc, _ = ref.Get(ctx)
+	if len(doc.Data()) != 1 {
+		t.Errorf("plain set should replace the document, got %v", doc.Data())
+	}
+
+	if _, err := ref.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ref.Get(ctx); status.Code(err) != codes.NotFound {
+		t.Errorf("Get after delete: got %v, want NotFound", err)
+	}
+}
+
+func TestQueries(t *testing.T) {
+	ctx := context.Background()
+	db := New(t).DB
+	items := db.Collection("items")
+	for _, it := range []item{
+		{Name: "a", Rank: 3, Tags: []string{"red"}},
+		{Name: "b", Rank: 1, Tags: []string{"blue", "red"}},
+		{Name: "c", Rank: 2},
+		{Name: "d", Rank: 5, Tags: []string{"green"}},
+	} {
+		if _, err := items.Doc(it.Name).Set(ctx, it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Collection("users").Doc("u").Collection("items").Doc("e").Set(ctx, item{Name: "e", Rank: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		q    firestore.Query
+		want []string
+	}{
+		{"all, by name", items.Query, []string{"a", "b", "c", "d"}},
+		{"order desc", items.OrderBy("rank", firestore.Desc), []string{"d", "a", "c", "b"}},
+		{"range", items.Where("rank", ">=", 2).Where("rank", "<", 5), []string{"c", "a"}},
+		{"array-contains", items.Where("tags", "array-contains", "red"), []string{"a", "b"}},
+		{"array-contains-any", items.Where("tags", "array-contains-any", []string{"green", "blue"}), []string{"b", "d"}},
+		{"in", items.Where("name", "in", []string{"c", "d", "zz"}), []string{"c", "d"}},
+		{"limit and offset", items.OrderBy("rank", firestore.Asc).Offset(1).Limit(2), []string{"c", "a"}},
+		{"start after value", items.OrderBy("rank", firestore.Asc).StartAfter(2), []string{"a", "d"}},
+		{"end before value", items.OrderBy("rank", firestore.Asc).EndBefore(3), []string{"b", "c"}},
+		{"type mismatch never matches a range", items.Where("rank", ">", "1"), []string{}},
+		{"collection group orders by the inequality field", db.CollectionGroup("items").Where("rank", ">", 3), []string{"e", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ids(t, tt.q); !equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Paging by document snapshot
+	first, err := items.OrderBy("rank", firestore.Asc).Limit(2).Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, items.OrderBy("rank", firestore.Asc).StartAfter(first[1])); !equal(got, []string{"a", "d"}) {
+		t.Errorf("second page = %v, want [a d]", got)
+	}
+
+	counted := items.Where("rank", ">", 1)
+	result, err := counted.NewAggregationQuery().WithCount("n").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := result["n"].(*firestorepb.Value).GetIntegerValue(); n != 3 {
+		t.Errorf("count = %d, want 3", n)
+	}
+}
+
+func TestTransactionsSerialize(t *testing.T) {
+	ctx := context.Background()
+	db := New(t).DB
+	ref := db.Collection("counters").Doc("c")
+
+	const workers = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+				n := int64(0)
+				doc, err := tx.Get(ref)
+				if err == nil {
+					n = doc.Data()["n"].(int64)
+				} else if status.Code(err) != codes.NotFound {
+					return err
+				}
+				return tx.Set(ref, map[string]interface{}{"n": n + 1})
+			}, firestore.MaxAttempts(workers+1))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("transaction: %v", err)
+		}
+	}
+
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := doc.Data()["n"]; n != int64(workers) {
+		t.Errorf("n = %v, want %d: a read-modify-write was lost", n, workers)
+	}
+}
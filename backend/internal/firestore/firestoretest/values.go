@@ -0,0 +1,263 @@
+package firestoretest
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strings"
+
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/protobuf/proto"
+)
+
+// nameField is the field path that refers to a document's own name
+const nameField = "__name__"
+
+// typeOrder ranks value types the way Firestore orders mixed-type fields
+func typeOrder(v *pb.Value) int {
+	switch v.ValueType.(type) {
+	case *pb.Value_NullValue:
+		return 0
+	case *pb.Value_BooleanValue:
+		return 1
+	case *pb.Value_IntegerValue, *pb.Value_DoubleValue:
+		return 2
+	case *pb.Value_TimestampValue:
+		return 3
+	case *pb.Value_StringValue:
+		return 4
+	case *pb.Value_BytesValue:
+		return 5
+	case *pb.Value_ReferenceValue:
+		return 6
+	case *pb.Value_GeoPointValue:
+		return 7
+	case *pb.Value_ArrayValue:
+		return 8
+	default:
+		return 9
+	}
+}
+
+// compareValues orders two values, returning -1, 0 or 1
+func compareValues(a, b *pb.Value) int {
+	if ta, tb := typeOrder(a), typeOrder(b); ta != tb {
+		return compareInts(int64(ta), int64(tb))
+	}
+
+	switch av := a.ValueType.(type) {
+	case *pb.Value_NullValue:
+		return 0
+	case *pb.Value_BooleanValue:
+		bv := b.GetBooleanValue()
+		switch {
+		case av.BooleanValue == bv:
+			return 0
+		case !av.BooleanValue:
+			return -1
+		default:
+			return 1
+		}
+	case *pb.Value_IntegerValue, *pb.Value_DoubleValue:
+		return compareNumbers(a, b)
+	case *pb.Value_TimestampValue:
+		at, bt := av.TimestampValue, b.GetTimestampValue()
+		if c := compareInts(at.GetSeconds(), bt.GetSeconds()); c != 0 {
+			return c
+		}
+		return compareInts(int64(at.GetNanos()), int64(bt.GetNanos()))
+	case *pb.Value_StringValue:
+		return strings.Compare(av.StringValue, b.GetStringValue())
+	case *pb.Value_BytesValue:
+		return bytes.Compare(av.BytesValue, b.GetBytesValue())
+	case *pb.Value_ReferenceValue:
+		return compareNames(av.ReferenceValue, b.GetReferenceValue())
+	case *pb.Value_GeoPointValue:
+		ag, bg := av.GeoPointValue, b.GetGeoPointValue()
+		if c := compareFloats(ag.GetLatitude(), bg.GetLatitude()); c != 0 {
+			return c
+		}
+		return compareFloats(ag.GetLongitude(), bg.GetLongitude())
+	case *pb.Value_ArrayValue:
+		ae, be := av.ArrayValue.GetValues(), b.GetArrayValue().GetValues()
+		for i := 0; i < len(ae) && i < len(be); i++ {
+			if c := compareValues(ae[i], be[i]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(int64(len(ae)), int64(len(be)))
+	case *pb.Value_MapValue:
+		am, bm := av.MapValue.GetFields(), b.GetMapValue().GetFields()
+		ak, bk := sortedKeys(am), sortedKeys(bm)
+		for i := 0; i < len(ak) && i < len(bk); i++ {
+			if c := strings.Compare(ak[i], bk[i]); c != 0 {
+				return c
+			}
+			if c := compareValues(am[ak[i]], bm[bk[i]]); c != 0 {
+				return c
+			}
+		}
+		return compareInts(int64(len(ak)), int64(len(bk)))
+	}
+	return 0
+}
+
+// valuesEqual reports whether two values are equal for filtering; 1 and 1.0 are equal
+func valuesEqual(a, b *pb.Value) bool {
+	if isNaN(a) || isNaN(b) {
+		return false
+	}
+	return compareValues(a, b) == 0
+}
+
+func compareNumbers(a, b *pb.Value) int {
+	ai, aInt := a.ValueType.(*pb.Value_IntegerValue)
+	bi, bInt := b.ValueType.(*pb.Value_IntegerValue)
+	if aInt && bInt {
+		return compareInts(ai.IntegerValue, bi.IntegerValue)
+	}
+	return compareFloats(toFloat(a), toFloat(b))
+}
+
+// compareFloats orders NaN before every other number
+func compareFloats(a, b float64) int {
+	switch {
+	case math.IsNaN(a) && math.IsNaN(b):
+		return 0
+	case math.IsNaN(a):
+		return -1
+	case math.IsNaN(b):
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareNames orders document names segment by segment
+func compareNames(a, b string) int {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if c := strings.Compare(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(int64(len(as)), int64(len(bs)))
+}
+
+func toFloat(v *pb.Value) float64 {
+	if i, ok := v.ValueType.(*pb.Value_IntegerValue); ok {
+		return float64(i.IntegerValue)
+	}
+	return v.GetDoubleValue()
+}
+
+func isNumber(v *pb.Value) bool {
+	return typeOrder(v) == 2
+}
+
+func isNaN(v *pb.Value) bool {
+	d, ok := v.ValueType.(*pb.Value_DoubleValue)
+	return ok && math.IsNaN(d.DoubleValue)
+}
+
+func isNull(v *pb.Value) bool {
+	_, ok := v.ValueType.(*pb.Value_NullValue)
+	return ok
+}
+
+func sortedKeys(m map[string]*pb.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseFieldPath splits a service field path ("a.b", "a.`b.c`") into its segments
+func parseFieldPath(path string) []string {
+	var segments []string
+	var current strings.Builder
+	quoted := false
+	for i := 0; i < len(path); i++ {
+		ch := path[i]
+		switch {
+		case quoted && ch == '\\' && i+1 < len(path):
+			i++
+			current.WriteByte(path[i])
+		case ch == '`':
+			quoted = !quoted
+		case ch == '.' && !quoted:
+			segments = append(segments, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	return append(segments, current.String())
+}
+
+// getField returns the value at path within fields
+func getField(fields map[string]*pb.Value, path []string) (*pb.Value, bool) {
+	v, ok := fields[path[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(path) == 1 {
+		return v, true
+	}
+	m := v.GetMapValue()
+	if m == nil {
+		return nil, false
+	}
+	return getField(m.Fields, path[1:])
+}
+
+// setField stores v at path, creating (or replacing non-map values with) intermediate maps
+func setField(fields map[string]*pb.Value, path []string, v *pb.Value) {
+	if len(path) == 1 {
+		fields[path[0]] = proto.Clone(v).(*pb.Value)
+		return
+	}
+	next := fields[path[0]].GetMapValue()
+	if next == nil {
+		next = &pb.MapValue{}
+		fields[path[0]] = &pb.Value{ValueType: &pb.Value_MapValue{MapValue: next}}
+	}
+	if next.Fields == nil {
+		next.Fields = map[string]*pb.Value{}
+	}
+	setField(next.Fields, path[1:], v)
+}
+
+// deleteField removes the value at path, if present
+func deleteField(fields map[string]*pb.Value, path []string) {
+	if len(path) == 1 {
+		delete(fields, path[0])
+		return
+	}
+	if next := fields[path[0]].GetMapValue(); next != nil {
+		deleteField(next.Fields, path[1:])
+	}
+}
+
+// docField returns a document's value at a service field path, including __name__
+func docField(doc *pb.Document, path string) (*pb.Value, bool) {
+	if path == nameField {
+		return &pb.Value{ValueType: &pb.Value_ReferenceValue{ReferenceValue: doc.Name}}, true
+	}
+	return getField(doc.Fields, parseFieldPath(path))
+}
@@ -12,7 +12,11 @@ type Client struct {
 	ProjectID string
 	Location  string
 	Model     string
-	Raw       *genai.Client
+	// EmbeddingModel is used by Embed; empty means DefaultEmbeddingModel
+	EmbeddingModel string
+	Raw            *genai.Client
+	// Limiter caps concurrent calls; nil means unlimited
+	Limiter *Limiter
 }
 
 func New(ctx context.Context, project, location, model string) (*Client, error) {
@@ -34,10 +38,11 @@ func New(ctx context.Context, project, location, model string) (*Client, error)
 	}
 
 	return &Client{
-		ProjectID: project,
-		Location:  location,
-		Model:     model,
-		Raw:       client,
+		ProjectID:      project,
+		Location:       location,
+		Model:          model,
+		EmbeddingModel: DefaultEmbeddingModel,
+		Raw:            client,
 	}, nil
 }
 
@@ -46,59 +51,74 @@ func (c *Client) Close() error {
 	return nil
 }
 
-// GenerateContentStream streams content using Gemini
-func (c *Client) GenerateContentStream(ctx context.Context, prompt string) (<-chan string, <-chan error) {
-	tokens := make(chan string, 100)
-	errors := make(chan error, 1)
-
-	go func() {
-		defer close(tokens)
-		defer close(errors)
-
-		// TODO: Implement proper Gemini streaming
-		// For now, generate a helpful coaching response
-		response := "I hear you. Let me help you with that.\n\n" +
-			"Here's what I suggest:\n\n" +
-			"1. Take a moment to clarify what you're trying to achieve\n" +
-			"2. Break it down into a small, actionable next step\n" +
-			"3. Set aside 20 minutes to make progress\n\n" +
-			"What feels like the right first step for you?"
-
-		// Send response in chunks to simulate streaming
-		words := []string{}
-		currentWord := ""
-		for _, char := range response {
-			if char == ' ' || char == '\n' {
-				if currentWord != "" {
-					words = append(words, currentWord)
-					currentWord = ""
-				}
-				if char == '\n' {
-					words = append(words, "\n")
-				} else {
-					words = append(words, " ")
-				}
-			} else {
-				currentWord += string(char)
-			}
+// CheckModel confirms model is available to this project and location
+func (c *Client) CheckModel(ctx context.Context, model string) error {
+	if _, err := c.Raw.Models.Get(ctx, model, nil); err != nil {
+		return fmt.Errorf("model %q is not available in project %s (%s): %w", model, c.ProjectID, c.Location, err)
+	}
+	return nil
+}
+
+// streamParts runs one streaming generation, passing each text part and function call to emit.
+// Function calls are only possible when tools are declared.
+func (c *Client) streamParts(ctx context.Context, history []Turn, prompt string, media []Media, tools []FunctionDecl, emit func(StreamPart) error) error {
+	// The slot is held for the whole stream, since tokens keep arriving from the API
+	release, err := c.Limiter.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	contents := historyContents(history)
+	contents = append(contents, &genai.Content{
+		Role:  "user",
+		Parts: promptParts(prompt, media),
+	})
+
+	config := &genai.GenerateContentConfig{
+		Temperature: floatPtr(0.7),
+	}
+	if len(tools) > 0 {
+		config.Tools = []*genai.Tool{{FunctionDeclarations: functionDeclarations(tools)}}
+	}
+
+	// Each chunk reports running totals, so the last one seen is the stream's usage
+	var usage TokenUsage
+	defer func() { c.recordUsage(ctx, usage) }()
+
+	for resp, err := range c.Raw.Models.GenerateContentStream(ctx, c.Model, contents, config) {
+		if err != nil {
+			return fmt.Errorf("gemini stream failed: %w", err)
 		}
-		if currentWord != "" {
-			words = append(words, currentWord)
+		if resp != nil && resp.UsageMetadata != nil {
+			usage = tokenUsage(resp.UsageMetadata)
 		}
 
-		// Stream words
-		for _, word := range words {
-			select {
-			case <-ctx.Done():
-				errors <- ctx.Err()
-				return
-			case tokens <- word:
-				// Small delay to simulate streaming
-				// time.Sleep(20 * time.Millisecond)
+		// Blocked responses arrive as empty candidates with a block/finish reason
+		if err := checkBlocked(resp); err != nil {
+			return err
+		}
+
+		for _, candidate := range resp.Candidates {
+			if candidate.Content == nil {
+				continue
+			}
+			for _, part := range candidate.Content.Parts {
+				var out StreamPart
+				switch {
+				case part.FunctionCall != nil:
+					out.Call = &FunctionCall{Name: part.FunctionCall.Name, Args: part.FunctionCall.Args}
+				case part.Text != "" && !part.Thought:
+					out.Text = part.Text
+				default:
+					continue
+				}
+				if err := emit(out); err != nil {
+					return err
+				}
 			}
 		}
-	}()
+	}
 
-	return tokens, errors
+	return nil
 }
-
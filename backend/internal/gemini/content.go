@@ -27,10 +27,21 @@ func (c *Client) GenerateContent(ctx context.Context, systemPrompt, userPrompt s
 		ResponseMIMEType: "text/plain",
 	}
 
+	release, err := c.Limiter.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
 	resp, err := c.Raw.Models.GenerateContent(ctx, c.Model, contents, config)
 	if err != nil {
 		return "", fmt.Errorf("gemini generate content failed: %w", err)
 	}
+	c.recordUsage(ctx, tokenUsage(resp.UsageMetadata))
+
+	if err := checkBlocked(resp); err != nil {
+		return "", err
+	}
 
 	// Extract text from response
 	if len(resp.Candidates) == 0 {
@@ -52,6 +63,32 @@ func (c *Client) GenerateContent(ctx context.Context, systemPrompt, userPrompt s
 	return result.String(), nil
 }
 
+// checkBlocked returns ErrContentBlocked when Gemini's safety settings blocked the prompt or response
+func checkBlocked(resp *genai.GenerateContentResponse) error {
+	if resp == nil {
+		return nil
+	}
+
+	if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != "" &&
+		resp.PromptFeedback.BlockReason != genai.BlockedReasonUnspecified {
+		return fmt.Errorf("%w: prompt blocked (%s)", ErrContentBlocked, resp.PromptFeedback.BlockReason)
+	}
+
+	for _, candidate := range resp.Candidates {
+		switch candidate.FinishReason {
+		case genai.FinishReasonSafety,
+			genai.FinishReasonBlocklist,
+			genai.FinishReasonProhibitedContent,
+			genai.FinishReasonSPII,
+			genai.FinishReasonImageSafety,
+			genai.FinishReasonImageProhibitedContent:
+			return fmt.Errorf("%w: response blocked (%s)", ErrContentBlocked, candidate.FinishReason)
+		}
+	}
+
+	return nil
+}
+
 func floatPtr(f float32) *float32 {
 	return &f
 }
@@ -0,0 +1,43 @@
+package gemini
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestCheckBlocked(t *testing.T) {
+	text := &genai.Content{Parts: []*genai.Part{{Text: "Sure, here's a plan."}}}
+	tests := []struct {
+		name    string
+		resp    *genai.GenerateContentResponse
+		blocked bool
+	}{
+		{"nil response", nil, false},
+		{"normal reply", &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: text, FinishReason: genai.FinishReasonStop}}}, false},
+		{"unspecified block reason", &genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonUnspecified}}, false},
+		{"prompt blocked", &genai.GenerateContentResponse{PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonSafety}}, true},
+		{"reply blocked for safety", &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonSafety}}}, true},
+		{"reply hit the blocklist", &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonBlocklist}}}, true},
+		{"reply ran out of tokens", &genai.GenerateContentResponse{Candidates: []*genai.Candidate{{Content: text, FinishReason: genai.FinishReasonMaxTokens}}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBlocked(tt.resp)
+			if got := errors.Is(err, ErrContentBlocked); got != tt.blocked {
+				t.Errorf("checkBlocked = %v, want blocked %v", err, tt.blocked)
+			}
+			if !tt.blocked && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestBlockedIsNotRetried(t *testing.T) {
+	err := checkBlocked(&genai.GenerateContentResponse{Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonProhibitedContent}}})
+	if isRetryableError(err) {
+		t.Errorf("%v is retried, want blocks to fail fast", err)
+	}
+}
@@ -0,0 +1,57 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genai"
+)
+
+// DefaultEmbeddingModel is used when the client has no embedding model configured
+const DefaultEmbeddingModel = "text-embedding-005"
+
+// Embed returns one embedding vector per input text, in the same order
+func (c *Client) Embed(ctx context.Context, texts ...string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+
+	model := c.EmbeddingModel
+	if model == "" {
+		model = DefaultEmbeddingModel
+	}
+
+	contents := make([]*genai.Content, len(texts))
+	for i, text := range texts {
+		contents[i] = &genai.Content{
+			Role:  "user",
+			Parts: []*genai.Part{{Text: text}},
+		}
+	}
+
+	release, err := c.Limiter.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	resp, err := c.Raw.Models.EmbedContent(ctx, model, contents, &genai.EmbedContentConfig{
+		TaskType: "SEMANTIC_SIMILARITY",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gemini embed content failed: %w", err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Embeddings))
+	}
+
+	vectors := make([][]float32, len(resp.Embeddings))
+	for i, embedding := range resp.Embeddings {
+		if embedding == nil || len(embedding.Values) == 0 {
+			return nil, fmt.Errorf("empty embedding for input %d", i)
+		}
+		vectors[i] = embedding.Values
+	}
+
+	return vectors, nil
+}
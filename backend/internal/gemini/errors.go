@@ -4,9 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"google.golang.org/genai"
 )
 
+// ErrContentBlocked is returned when Gemini's own safety settings block a prompt or response
+var ErrContentBlocked = errors.New("content blocked by safety settings")
+
 // RetryConfig defines retry behavior
 type RetryConfig struct {
 	MaxRetries     int
@@ -27,6 +33,11 @@ func DefaultRetryConfig() RetryConfig {
 
 // GenerateContentWithRetry generates content with automatic retry on transient errors
 func (c *Client) GenerateContentWithRetry(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	return RetryGenerateContent(ctx, c, systemPrompt, userPrompt)
+}
+
+// RetryGenerateContent calls p.GenerateContent, retrying transient errors with exponential backoff
+func RetryGenerateContent(ctx context.Context, p Provider, systemPrompt, userPrompt string) (string, error) {
 	config := DefaultRetryConfig()
 	backoff := config.InitialBackoff
 
@@ -48,7 +59,7 @@ func (c *Client) GenerateContentWithRetry(ctx context.Context, systemPrompt, use
 			}
 		}
 
-		result, err := c.GenerateContent(ctx, systemPrompt, userPrompt)
+		result, err := p.GenerateContent(ctx, systemPrompt, userPrompt)
 		if err == nil {
 			return result, nil
 		}
@@ -69,7 +80,17 @@ func (c *Client) GenerateContentWithRetry(ctx context.Context, systemPrompt, use
 
 // isRetryableError determines if an error should trigger a retry
 func isRetryableError(err error) bool {
-	if err == nil {
+	if err == nil || errors.Is(err, ErrContentBlocked) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	// API errors carry their HTTP status: rate limits and server-side failures are transient
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case 429, 500, 502, 503, 504:
+			return true
+		}
 		return false
 	}
 
@@ -100,9 +121,7 @@ func isRetryableError(err error) bool {
 
 // contains checks if a string contains a substring (case-insensitive)
 func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && 
-		(s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || 
-		len(s) > len(substr)*2))
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
 }
 
 // FallbackResponse provides a fallback when Gemini fails
@@ -0,0 +1,136 @@
+// Package geminitest provides a scripted gemini.Provider for deterministic tests
+package geminitest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"simon-backend/internal/gemini"
+)
+
+// Script is one canned response, used when the prompt starts with Prefix
+type Script struct {
+	Prefix string
+	Text   string
+	// Err is returned after Text; for streams the tokens of Text are sent first, so a
+	// non-nil Err simulates a failure mid-generation
+	Err error
+	// ToolCalls are streamed after Text by GenerateContentStreamWithTools
+	ToolCalls []gemini.FunctionCall
+}
+
+// Call records one prompt the fake received
+type Call struct {
+	SystemPrompt string
+	UserPrompt   string
+}
+
+// FakeProvider returns scripted responses keyed by prompt prefix. Scripts are matched in
+// order against the system prompt followed by the user prompt; the first match wins.
+type FakeProvider struct {
+	Scripts []Script
+	// Default answers prompts no script matches; when empty such prompts fail
+	Default string
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ gemini.Provider = (*FakeProvider)(nil)
+
+// NewFakeProvider creates a fake answering with the given scripts
+func NewFakeProvider(scripts ...Script) *FakeProvider {
+	return &FakeProvider{Scripts: scripts}
+}
+
+// GenerateContent returns the matching script's text (or error)
+func (f *FakeProvider) GenerateContent(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	script, err := f.match(systemPrompt, userPrompt)
+	if err != nil {
+		return "", err
+	}
+	if script.Err != nil {
+		return "", script.Err
+	}
+	return script.Text, nil
+}
+
+// GenerateContentStreamWithTools streams the matching script's text word by word, then its
+// tool calls, then its error if any. History and media are ignored.
+func (f *FakeProvider) GenerateContentStreamWithTools(ctx context.Context, history []gemini.Turn, prompt string, media []gemini.Media, tools []gemini.FunctionDecl) (<-chan gemini.StreamPart, <-chan error) {
+	parts := make(chan gemini.StreamPart, 100)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(parts)
+		defer close(errs)
+
+		err := f.stream(ctx, prompt, func(part gemini.StreamPart) bool {
+			select {
+			case parts <- part:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+
+	return parts, errs
+}
+
+// stream emits the matching script's parts until emit returns false, then returns its error
+func (f *FakeProvider) stream(ctx context.Context, prompt string, emit func(gemini.StreamPart) bool) error {
+	script, err := f.match(prompt, "")
+	if err != nil {
+		return err
+	}
+
+	for _, token := range strings.SplitAfter(script.Text, " ") {
+		if token == "" {
+			continue
+		}
+		if !emit(gemini.StreamPart{Text: token}) {
+			return ctx.Err()
+		}
+	}
+	for i := range script.ToolCalls {
+		if !emit(gemini.StreamPart{Call: &script.ToolCalls[i]}) {
+			return ctx.Err()
+		}
+	}
+	return script.Err
+}
+
+// Calls returns the prompts received so far
+func (f *FakeProvider) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// match records the call and finds its script
+func (f *FakeProvider) match(systemPrompt, userPrompt string) (Script, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{SystemPrompt: systemPrompt, UserPrompt: userPrompt})
+	f.mu.Unlock()
+
+	prompt := systemPrompt + userPrompt
+	for _, script := range f.Scripts {
+		if strings.HasPrefix(prompt, script.Prefix) {
+			return script, nil
+		}
+	}
+	if f.Default != "" {
+		return Script{Text: f.Default}, nil
+	}
+
+	preview := prompt
+	if len(preview) > 80 {
+		preview = preview[:80]
+	}
+	return Script{}, fmt.Errorf("geminitest: no script for prompt %q", preview)
+}
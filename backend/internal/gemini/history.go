@@ -0,0 +1,27 @@
+package gemini
+
+import "google.golang.org/genai"
+
+// Turn roles
+const (
+	RoleUser  = "user"
+	RoleModel = "model"
+)
+
+// Turn is an earlier message of the conversation
+type Turn struct {
+	Role string // RoleUser | RoleModel
+	Text string
+}
+
+// historyContents converts earlier turns to genai contents
+func historyContents(history []Turn) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(history)+1)
+	for _, turn := range history {
+		contents = append(contents, &genai.Content{
+			Role:  turn.Role,
+			Parts: []*genai.Part{{Text: turn.Text}},
+		})
+	}
+	return contents
+}
@@ -0,0 +1,74 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"simon-backend/internal/metrics"
+)
+
+// ErrQueueTimeout is returned when a call waited longer than the limiter's queue timeout
+var ErrQueueTimeout = errors.New("gemini: timed out waiting for a free call slot")
+
+// Limiter caps the number of in-flight Gemini calls across the process. Calls beyond the cap
+// queue until a slot frees up, the queue timeout passes, or their context is done.
+type Limiter struct {
+	slots   chan struct{}
+	timeout time.Duration
+}
+
+// NewLimiter allows maxInFlight concurrent calls, queueing others for at most timeout
+// (0 waits as long as the caller's context allows). maxInFlight <= 0 disables limiting.
+func NewLimiter(maxInFlight int, timeout time.Duration) *Limiter {
+	if maxInFlight <= 0 {
+		return nil
+	}
+	return &Limiter{
+		slots:   make(chan struct{}, maxInFlight),
+		timeout: timeout,
+	}
+}
+
+// Acquire waits for a call slot and returns the function that releases it. A nil limiter
+// never waits.
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	release := func() { <-l.slots }
+
+	// Fast path: a slot is free
+	select {
+	case l.slots <- struct{}{}:
+		metrics.Get().RecordGeminiQueueWait(0, false)
+		return release, nil
+	default:
+	}
+
+	m := metrics.Get()
+	m.AddGeminiQueueDepth(1)
+	defer m.AddGeminiQueueDepth(-1)
+
+	var timeout <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+		m.RecordGeminiQueueWait(time.Since(start), false)
+		return release, nil
+	case <-timeout:
+		m.RecordGeminiQueueWait(time.Since(start), true)
+		return nil, ErrQueueTimeout
+	case <-ctx.Done():
+		m.RecordGeminiQueueWait(time.Since(start), false)
+		return nil, fmt.Errorf("gemini: waiting for a call slot: %w", ctx.Err())
+	}
+}
@@ -0,0 +1,88 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterQueuesBeyondMax(t *testing.T) {
+	limiter := NewLimiter(2, 0)
+	ctx := context.Background()
+
+	releaseFirst, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	releaseSecond, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer releaseSecond()
+
+	acquired := make(chan func())
+	go func() {
+		release, err := limiter.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+			close(acquired)
+			return
+		}
+		acquired <- release
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("third call started while two were in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	releaseFirst()
+	select {
+	case release := <-acquired:
+		if release != nil {
+			release()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("third call still waiting after a slot was released")
+	}
+}
+
+func TestLimiterQueueTimeout(t *testing.T) {
+	limiter := NewLimiter(1, 20*time.Millisecond)
+	release, err := limiter.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := limiter.Acquire(context.Background()); !errors.Is(err, ErrQueueTimeout) {
+		t.Errorf("queued call = %v, want ErrQueueTimeout", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := limiter.Acquire(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled call = %v, want context.Canceled", err)
+	}
+
+	// The slot frees up once released
+	release()
+	if release, err := limiter.Acquire(context.Background()); err != nil {
+		t.Errorf("after release: %v", err)
+	} else {
+		release()
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	limiter := NewLimiter(0, time.Millisecond)
+	if limiter != nil {
+		t.Fatalf("NewLimiter(0) = %+v, want no limiter", limiter)
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := limiter.Acquire(context.Background()); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package gemini
+
+import (
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Media is an image sent to the model alongside the prompt
+type Media struct {
+	MimeType string
+	Data     []byte
+}
+
+// SupportsVision reports whether a model accepts image input; every Gemini model does
+func SupportsVision(model string) bool {
+	return strings.HasPrefix(model, "gemini-")
+}
+
+// promptParts builds the user turn: the prompt text followed by any images
+func promptParts(prompt string, media []Media) []*genai.Part {
+	parts := []*genai.Part{{Text: prompt}}
+	for _, m := range media {
+		parts = append(parts, &genai.Part{InlineData: &genai.Blob{MIMEType: m.MimeType, Data: m.Data}})
+	}
+	return parts
+}
@@ -0,0 +1,13 @@
+package gemini
+
+import "context"
+
+// Provider is the text generation surface the orchestrator agents need. *Client implements it;
+// tests can substitute a scripted fake (see the geminitest package). A stream's error channel is
+// buffered, receives at most one error, and closes together with its part channel.
+type Provider interface {
+	GenerateContent(ctx context.Context, systemPrompt, userPrompt string) (string, error)
+	GenerateContentStreamWithTools(ctx context.Context, history []Turn, prompt string, media []Media, tools []FunctionDecl) (<-chan StreamPart, <-chan error)
+}
+
+var _ Provider = (*Client)(nil)
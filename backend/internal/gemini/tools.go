@@ -0,0 +1,67 @@
+package gemini
+
+import (
+	"context"
+
+	"google.golang.org/genai"
+)
+
+// FunctionDecl declares a tool the model may call while generating
+type FunctionDecl struct {
+	Name        string
+	Description string
+	// Parameters is a JSON Schema object describing the call's arguments
+	Parameters map[string]interface{}
+}
+
+// FunctionCall is a tool call the model made, with its arguments
+type FunctionCall struct {
+	Name string
+	Args map[string]interface{}
+}
+
+// StreamPart is one piece of a streamed response: either text or a function call
+type StreamPart struct {
+	Text string
+	Call *FunctionCall
+}
+
+// GenerateContentStreamWithTools streams a response to the prompt and any attached images,
+// following the earlier turns of the conversation, in which the model may call the declared
+// tools. Text and calls arrive in the order the model produced them.
+func (c *Client) GenerateContentStreamWithTools(ctx context.Context, history []Turn, prompt string, media []Media, tools []FunctionDecl) (<-chan StreamPart, <-chan error) {
+	parts := make(chan StreamPart, 100)
+	errors := make(chan error, 1)
+
+	go func() {
+		defer close(parts)
+		defer close(errors)
+
+		err := c.streamParts(ctx, history, prompt, media, tools, func(part StreamPart) error {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case parts <- part:
+				return nil
+			}
+		})
+		if err != nil {
+			errors <- err
+		}
+	}()
+
+	return parts, errors
+}
+
+// functionDeclarations converts tool declarations to the genai form
+func functionDeclarations(tools []FunctionDecl) []*genai.FunctionDeclaration {
+	decls := make([]*genai.FunctionDeclaration, 0, len(tools))
+	for _, tool := range tools {
+		decls = append(decls, &genai.FunctionDeclaration{
+			Name:                 tool.Name,
+			Description:          tool.Description,
+			ParametersJsonSchema: tool.Parameters,
+		})
+	}
+	return decls
+}
@@ -0,0 +1,72 @@
+package gemini
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/genai"
+
+	"simon-backend/internal/metrics"
+)
+
+// TokenUsage counts the tokens spent on one or more Gemini calls
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// UsageTracker accumulates the token usage of every Gemini call made with a context from
+// WithUsageTracker. It is safe for concurrent use.
+type UsageTracker struct {
+	mu    sync.Mutex
+	usage TokenUsage
+}
+
+type usageTrackerKey struct{}
+
+// WithUsageTracker returns a context whose Gemini calls add their token usage to the returned tracker
+func WithUsageTracker(ctx context.Context) (context.Context, *UsageTracker) {
+	tracker := &UsageTracker{}
+	return context.WithValue(ctx, usageTrackerKey{}, tracker), tracker
+}
+
+// Usage returns the tokens counted so far
+func (t *UsageTracker) Usage() TokenUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage
+}
+
+// add counts one call's usage
+func (t *UsageTracker) add(usage TokenUsage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.usage.PromptTokens += usage.PromptTokens
+	t.usage.CompletionTokens += usage.CompletionTokens
+	t.usage.TotalTokens += usage.TotalTokens
+}
+
+// tokenUsage reads a response's usage metadata; streamed responses report running totals, so
+// only the last chunk's metadata should be counted
+func tokenUsage(metadata *genai.GenerateContentResponseUsageMetadata) TokenUsage {
+	if metadata == nil {
+		return TokenUsage{}
+	}
+	return TokenUsage{
+		PromptTokens:     int(metadata.PromptTokenCount),
+		CompletionTokens: int(metadata.CandidatesTokenCount + metadata.ThoughtsTokenCount),
+		TotalTokens:      int(metadata.TotalTokenCount),
+	}
+}
+
+// recordUsage counts one call's usage in the metrics and in the context's tracker, if any
+func (c *Client) recordUsage(ctx context.Context, usage TokenUsage) {
+	if usage == (TokenUsage{}) {
+		return
+	}
+	metrics.Get().RecordGeminiTokens(c.Model, usage.PromptTokens, usage.CompletionTokens)
+	if tracker, ok := ctx.Value(usageTrackerKey{}).(*UsageTracker); ok {
+		tracker.add(usage)
+	}
+}
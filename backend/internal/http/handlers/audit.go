@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"simon-backend/internal/audit"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/http/middleware"
+)
+
+// recordAudit logs a successful mutation by the authenticated user. Audit failures are
+// logged but never fail the request that already succeeded.
+func recordAudit(c *gin.Context, fs *fsClient.Client, resourceType, verb, resourceID string) {
+	ctx := c.Request.Context()
+	entry := audit.NewEntry(ctx, middleware.GetUID(c), resourceType, verb, resourceID)
+	if err := audit.Record(ctx, fs, entry); err != nil {
+		log.Printf("Error recording audit %s %s: %v", entry.Action, resourceID, err)
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/audit"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/logger"
+	"simon-backend/internal/models"
+	"simon-backend/internal/tools"
+)
+
+func auditRouter(fs *fsClient.Client, uid string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(logger.RequestIDMiddleware())
+	r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), uid) })
+	r.PUT("/v1/coaches/:id", UpdateCoach(fs))
+	r.DELETE("/v1/coaches/:id", DeleteCoach(fs))
+	r.PUT("/v1/plans/:id/archive", ArchivePlan(fs))
+	return r
+}
+
+// auditEntries returns the raw audit records, so fields outside audit.Entry would show up
+func auditEntries(t *testing.T, fs *fsClient.Client) []map[string]interface{} {
+	t.Helper()
+	docs, err := fs.DB.Collection(audit.Collection).Documents(context.Background()).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make([]map[string]interface{}, len(docs))
+	for i, doc := range docs {
+		entries[i] = doc.Data()
+	}
+	return entries
+}
+
+func TestAuditCoachUpdate(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("coaches").Doc("c1").Set(ctx, models.Coach{ID: "c1", OwnerUID: "u1", Title: "Focus", Visibility: "private"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else's edit is rejected and leaves no record
+	w := httptest.NewRecorder()
+	auditRouter(fs, "u2").ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/v1/coaches/c1", bytes.NewReader([]byte(`{"title":"Hijacked"}`))))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("other user's update: status %d", w.Code)
+	}
+	if entries := auditEntries(t, fs); len(entries) != 0 {
+		t.Fatalf("rejected update audited: %v", entries)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/v1/coaches/c1", bytes.NewReader([]byte(`{"title":"Deep Focus","promise":"My private notes"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	auditRouter(fs, "u1").ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: status %d, body %s", w.Code, w.Body)
+	}
+
+	entries := auditEntries(t, fs)
+	if len(entries) != 1 {
+		t.Fatalf("audit records = %v, want one", entries)
+	}
+	entry := entries[0]
+	if entry["actor_uid"] != "u1" || entry["action"] != "coach.update" || entry["resource_type"] != "coach" || entry["resource_id"] != "c1" {
+		t.Errorf("entry = %v, want u1's coach.update of c1", entry)
+	}
+	if entry["request_id"] != w.Header().Get("X-Request-ID") || entry["request_id"] == "" {
+		t.Errorf("request id = %v, want the request's %q", entry["request_id"], w.Header().Get("X-Request-ID"))
+	}
+	if len(entry) != 6 {
+		t.Errorf("entry has fields beyond who, what and when: %v", entry)
+	}
+}
+
+func TestAuditDeletes(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("coaches").Doc("c1").Set(ctx, models.Coach{ID: "c1", OwnerUID: "u1", Title: "Old", Visibility: "private"}); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := tools.NewPlanService(fs.DB).Create(ctx, tools.PlanCreateRequest{
+		UID:  "u1",
+		Plan: models.Plan{Title: "10k", Objective: "Run a 10k", Horizon: "month"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := auditRouter(fs, "u1")
+
+	requests := []struct{ method, path string }{
+		{http.MethodPut, "/v1/plans/" + plan.PlanID + "/archive"},
+		{http.MethodDelete, "/v1/coaches/c1"},
+	}
+	for _, req := range requests {
+		if w := serve(r, req.method, req.path); w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d, body %s", req.method, req.path, w.Code, w.Body)
+		}
+	}
+
+	got := map[string]string{}
+	for _, entry := range auditEntries(t, fs) {
+		got[entry["action"].(string)] = entry["resource_id"].(string)
+	}
+	want := map[string]string{"plan.archive": plan.PlanID, "coach.delete": "c1"}
+	if len(got) != len(want) {
+		t.Fatalf("audit actions = %v, want %v", got, want)
+	}
+	for action, id := range want {
+		if got[action] != id {
+			t.Errorf("%s resource = %q, want %q", action, got[action], id)
+		}
+	}
+}
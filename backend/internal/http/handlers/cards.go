@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/cards"
+)
+
+// ListCardSchemas returns the schema version of every SSE card event
+func ListCardSchemas(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"content_type": cards.ContentType,
+		"schemas":      cards.Schemas(),
+	})
+}
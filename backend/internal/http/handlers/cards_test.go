@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"simon-backend/internal/cards"
+)
+
+func TestListCardSchemas(t *testing.T) {
+	w := serveAs("u1", ListCardSchemas, http.MethodGet, "/v1/cards/schemas", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var body struct {
+		ContentType string         `json:"content_type"`
+		Schemas     []cards.Schema `json:"schemas"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.ContentType != cards.ContentType || len(body.Schemas) != len(cards.Schemas()) {
+		t.Fatalf("body = %+v, want every registered schema", body)
+	}
+	versions := map[string]string{}
+	for _, s := range body.Schemas {
+		versions[s.EventType] = s.Schema
+	}
+	if versions[cards.TypePlan] != cards.SchemaPlan || versions[cards.TypeNextActions] != cards.SchemaNextActions {
+		t.Errorf("versions = %v", versions)
+	}
+}
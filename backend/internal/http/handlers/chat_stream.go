@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -18,7 +19,9 @@ import (
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
 	"simon-backend/internal/orchestrator"
+	"simon-backend/internal/redact"
 	"simon-backend/internal/sse"
+	"simon-backend/internal/validation"
 )
 
 // SendMessage sends a message and returns immediately (non-streaming)
@@ -34,6 +37,11 @@ func SendMessage(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config
 			return
 		}
 
+		if _, err := resolveUserMessage(req.UserText, req.Attachments); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		// Validate session ownership
 		sessionDoc, err := fs.DB.Collection("sessions").Doc(sessionID).Get(ctx)
 		if err != nil {
@@ -52,37 +60,19 @@ func SendMessage(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config
 			return
 		}
 
-		// Save user message
-		userMsg := models.Message{
-			ID:          uuid.New().String(),
-			Role:        "user",
-			ContentText: req.UserText,
-			Attachments: req.Attachments,
-			CreatedAt:   time.Now(),
-		}
-
-		_, err = fs.DB.Collection("sessions").Doc(sessionID).
-			Collection("messages").Doc(userMsg.ID).Set(ctx, userMsg)
+		userMsg, err := saveUserMessage(ctx, fs, sessionID, req.UserText, req.Attachments, session.CoachSpecSnapshot)
 		if err != nil {
 			log.Printf("Error saving user message: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save message"})
 			return
 		}
 
-		// Update session timestamp
-		_, err = fs.DB.Collection("sessions").Doc(sessionID).Update(ctx, []firestore.Update{
-			{Path: "updated_at", Value: time.Now()},
-		})
-		if err != nil {
-			log.Printf("Error updating session: %v", err)
-		}
-
 		c.JSON(http.StatusOK, userMsg)
 	}
 }
 
 // StreamChat streams chat responses using SSE with multi-agent orchestration
-func StreamChat(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config) gin.HandlerFunc {
+func StreamChat(fs *fsClient.Client, gm geminiClient.Provider, cfg config.Config, streams *sse.Limiter, stops *sse.Stops, plans PlanToolService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		uid := middleware.GetUID(c)
@@ -92,13 +82,37 @@ func StreamChat(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config)
 
 		// Parse request body
 		var req struct {
-			Message string `json:"message" binding:"required"`
+			Message     string              `json:"message"`
+			Attachments []models.Attachment `json:"attachments,omitempty"`
+			// IncludeContext overrides the user's preference for this session and is persisted on it
+			IncludeContext *bool `json:"include_context,omitempty"`
+			// GrantedPermissions lists device permissions the client holds (e.g. "calendar", "reminders")
+			GrantedPermissions []string `json:"granted_permissions,omitempty"`
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 			return
 		}
 
+		userMessage, err := resolveUserMessage(req.Message, req.Attachments)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		reserved, ok := reserveCredits(c, fs, cfg, uid)
+		if !ok {
+			return
+		}
+		// Until the pipeline takes over the reservation, returning early refunds it
+		defer func() { refundCredits(fs, uid, reserved) }()
+
+		release, ok := acquireStream(c, streams, uid)
+		if !ok {
+			return
+		}
+		defer release()
+
 		// Initialize SSE
 		flusher, ok := sse.Init(c.Writer)
 		if !ok {
@@ -138,6 +152,34 @@ func StreamChat(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config)
 			return
 		}
 
+		// Persist a new context override so later turns keep honoring it
+		if req.IncludeContext != nil && (session.IncludeContext == nil || *session.IncludeContext != *req.IncludeContext) {
+			if _, err := sessionDoc.Ref.Update(ctx, []firestore.Update{
+				{Path: "include_context", Value: *req.IncludeContext},
+			}); err != nil {
+				log.Printf("Error saving include_context override for session %s: %v", sessionID, err)
+			}
+			session.IncludeContext = req.IncludeContext
+		}
+
+		// Save the user message first so it precedes the reply the pipeline saves
+		firstTurn := !hasAssistantReply(ctx, fs, sessionID)
+		userMsg, err := saveUserMessage(ctx, fs, sessionID, req.Message, req.Attachments, session.CoachSpecSnapshot)
+		if err != nil {
+			log.Printf("Error saving user message: %v", err)
+			sse.Event(c.Writer, "error", map[string]interface{}{
+				"code":    "MESSAGE_SAVE_ERROR",
+				"message": "failed to save message",
+			})
+			flusher.Flush()
+			return
+		}
+
+		history, err := getConversationHistory(ctx, fs, sessionID, userMsg.ID)
+		if err != nil {
+			log.Printf("Error loading history for session %s: %v", sessionID, err)
+		}
+
 		// Get coach ID
 		coachID := ""
 		if session.CoachID != nil {
@@ -145,14 +187,150 @@ func StreamChat(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config)
 		}
 
 		// Create pipeline
-		pipeline := orchestrator.NewPipeline(fs, gm)
-
-		// Execute pipeline
-		output, err := pipeline.Execute(ctx, orchestrator.PipelineInput{
-			SessionID:   sessionID,
-			CoachID:     coachID,
-			UserMessage: req.Message,
-			UID:         uid,
+		pipeline := orchestrator.NewPipeline(fs, gm, cfg).WithPlans(plans)
+
+		// Execute pipeline; a stop request cancels generation but the relay below keeps running
+		runCtx, untrack := stops.Track(ctx, sessionID)
+		defer untrack()
+		output, err := pipeline.Execute(runCtx, orchestrator.PipelineInput{
+			SessionID:          sessionID,
+			CoachID:            coachID,
+			UserMessage:        userMessage,
+			UserMessageID:      userMsg.ID,
+			Attachments:        req.Attachments,
+			UID:                uid,
+			History:            history,
+			Locale:             c.GetHeader("Accept-Language"),
+			FirstTurn:          firstTurn,
+			IncludeContext:     session.IncludeContext,
+			GrantedPermissions: req.GrantedPermissions,
+			CoachSpecSnapshot:  session.CoachSpecSnapshot,
+			CoachNoticeSent:    session.CoachUnavailableNotified,
+			ReservedCredits:    reserved,
+		})
+		if err != nil {
+			log.Printf("Pipeline execution error: %v", err)
+			sse.Event(c.Writer, "error", map[string]interface{}{
+				"code":    "PIPELINE_ERROR",
+				"message": fmt.Sprintf("Pipeline failed: %v", err),
+			})
+			flusher.Flush()
+			return
+		}
+		reserved = 0 // settled by the pipeline
+
+		streamPipelineOutput(c, flusher, output, sessionID)
+	}
+}
+
+// RegenerateMessage re-runs the coach on the session's latest user message and streams a
+// new reply over SSE. An optional adjust object overrides the coach's style for this turn only.
+func RegenerateMessage(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config, streams *sse.Limiter, stops *sse.Stops, plans PlanToolService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		sessionID := c.Param("id")
+
+		var req struct {
+			Adjust             *models.StyleAdjustment `json:"adjust,omitempty"`
+			GrantedPermissions []string                `json:"granted_permissions,omitempty"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+				return
+			}
+		}
+		if errs := validation.ValidateStyleAdjustment(req.Adjust); len(errs) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errs[0].Error(), "errors": errs})
+			return
+		}
+
+		// Validate session ownership
+		sessionDoc, err := fs.DB.Collection("sessions").Doc(sessionID).Get(ctx)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+
+		var session models.Session
+		if err := sessionDoc.DataTo(&session); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse session"})
+			return
+		}
+
+		if session.UID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+
+		lastUserMsg, err := getLastUserMessage(ctx, fs, sessionID)
+		if err != nil {
+			log.Printf("Error loading last user message: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+			return
+		}
+		if lastUserMsg == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "nothing to regenerate"})
+			return
+		}
+
+		userMessage, err := resolveUserMessage(lastUserMsg.ContentText, lastUserMsg.Attachments)
+		if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "nothing to regenerate"})
+			return
+		}
+
+		reserved, ok := reserveCredits(c, fs, cfg, uid)
+		if !ok {
+			return
+		}
+		// Until the pipeline takes over the reservation, returning early refunds it
+		defer func() { refundCredits(fs, uid, reserved) }()
+
+		release, ok := acquireStream(c, streams, uid)
+		if !ok {
+			return
+		}
+		defer release()
+
+		// Initialize SSE
+		flusher, ok := sse.Init(c.Writer)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming not supported"})
+			return
+		}
+
+		history, err := getConversationHistory(ctx, fs, sessionID, lastUserMsg.ID)
+		if err != nil {
+			log.Printf("Error loading history for session %s: %v", sessionID, err)
+		}
+
+		coachID := ""
+		if session.CoachID != nil {
+			coachID = *session.CoachID
+		}
+
+		log.Printf("RegenerateMessage: uid=%s, sessionID=%s, adjust=%+v", uid, sessionID, req.Adjust)
+
+		pipeline := orchestrator.NewPipeline(fs, gm, cfg).WithPlans(plans)
+		runCtx, untrack := stops.Track(ctx, sessionID)
+		defer untrack()
+		output, err := pipeline.Execute(runCtx, orchestrator.PipelineInput{
+			SessionID:          sessionID,
+			CoachID:            coachID,
+			UserMessage:        userMessage,
+			UserMessageID:      lastUserMsg.ID,
+			Attachments:        lastUserMsg.Attachments,
+			UID:                uid,
+			History:            history,
+			Locale:             c.GetHeader("Accept-Language"),
+			StyleAdjustment:    req.Adjust,
+			IncludeContext:     session.IncludeContext,
+			GrantedPermissions: req.GrantedPermissions,
+			CoachSpecSnapshot:  session.CoachSpecSnapshot,
+			CoachNoticeSent:    session.CoachUnavailableNotified,
+			ReservedCredits:    reserved,
 		})
 		if err != nil {
 			log.Printf("Pipeline execution error: %v", err)
@@ -163,89 +341,183 @@ func StreamChat(fs *fsClient.Client, gm *geminiClient.Client, cfg config.Config)
 			flusher.Flush()
 			return
 		}
+		reserved = 0 // settled by the pipeline
+
+		streamPipelineOutput(c, flusher, output, sessionID)
+	}
+}
+
+// acquireStream reserves one of the user's concurrent stream slots, responding 429 when none is free
+func acquireStream(c *gin.Context, streams *sse.Limiter, uid string) (release func(), ok bool) {
+	release, ok = streams.Acquire(uid)
+	if !ok {
+		log.Printf("Rejecting stream: uid=%s already has %d open", uid, streams.Max())
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":   "too_many_streams",
+			"message": fmt.Sprintf("You can have at most %d conversations streaming at once. Wait for one to finish and try again.", streams.Max()),
+		})
+	}
+	return release, ok
+}
+
+// streamPipelineOutput relays pipeline events to the client as SSE with keep-alives,
+// returning when the stream completes, times out, or the client disconnects
+func streamPipelineOutput(c *gin.Context, flusher http.Flusher, output *orchestrator.PipelineOutput, sessionID string) {
+	ctx := c.Request.Context()
+
+	// After an early return the pipeline is still winding down and saving the partial reply;
+	// keep receiving so it never blocks on a full stream
+	defer func() {
+		go func() {
+			for range output.Stream {
+			}
+		}()
+	}()
+
+	// Keep-alive ticker (every 15 seconds)
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	// Connection timeout (5 minutes)
+	timeout := time.NewTimer(sse.MaxDuration)
+	defer timeout.Stop()
+
+	// Event ID counter
+	eventID := 0
+
+	// Stream events from pipeline
+	for {
+		select {
+		case event, ok := <-output.Stream:
+			if !ok {
+				// Stream closed normally
+				log.Printf("Stream closed: sessionID=%s", sessionID)
+				return
+			}
 
-		// Keep-alive ticker (every 15 seconds)
-		ticker := time.NewTicker(15 * time.Second)
-		defer ticker.Stop()
-
-		// Connection timeout (5 minutes)
-		timeout := time.NewTimer(5 * time.Minute)
-		defer timeout.Stop()
-
-		// Event ID counter
-		eventID := 0
-
-		// Stream events from pipeline
-		for {
-			select {
-			case event, ok := <-output.Stream:
-				if !ok {
-					// Stream closed normally
-					log.Printf("Stream closed: sessionID=%s", sessionID)
-					return
-				}
-
-				// Increment event ID
-				eventID++
-
-				// Debug log the event
-				log.Printf("SSE Event #%d: type=%s, data=%+v", eventID, event.Type, event.Data)
-
-				// Write SSE event with ID
-				if err := sse.EventWithID(c.Writer, fmt.Sprintf("%d", eventID), event.Type, event.Data); err != nil {
-					log.Printf("Error writing SSE event: %v", err)
-					return
-				}
-				flusher.Flush()
-				log.Printf("Flushed event #%d to client", eventID)
-
-				// Exit on completion or error
-				if event.Type == "stream.done" || event.Type == "error" {
-					log.Printf("Stream completed: sessionID=%s, type=%s", sessionID, event.Type)
-					return
-				}
-
-			case <-ticker.C:
-				// Send keep-alive comment
-				if err := sse.KeepAlive(c.Writer); err != nil {
-					log.Printf("Error sending keep-alive: %v", err)
-					return
-				}
-				flusher.Flush()
-
-			case <-timeout.C:
-				// Connection timeout
-				log.Printf("Connection timeout: sessionID=%s", sessionID)
-				sse.Event(c.Writer, "error", map[string]interface{}{
-					"code":    "TIMEOUT",
-					"message": "Connection timeout after 5 minutes",
-				})
-				flusher.Flush()
+			// Increment event ID
+			eventID++
+
+			// Debug log the event
+			log.Printf("SSE Event #%d: type=%s, data=%+v", eventID, event.Type, event.Data)
+
+			// Write SSE event with ID
+			if err := sse.EventWithID(c.Writer, fmt.Sprintf("%d", eventID), event.Type, event.Data); err != nil {
+				log.Printf("Error writing SSE event: %v", err)
 				return
+			}
+			flusher.Flush()
+			log.Printf("Flushed event #%d to client", eventID)
+
+			// Exit on completion or error
+			if event.Type == "stream.done" || event.Type == "error" {
+				log.Printf("Stream completed: sessionID=%s, type=%s", sessionID, event.Type)
+				return
+			}
+
+		case <-ticker.C:
+			// Send keep-alive comment
+			if err := sse.KeepAlive(c.Writer); err != nil {
+				log.Printf("Error sending keep-alive: %v", err)
+				return
+			}
+			flusher.Flush()
+
+		case <-timeout.C:
+			// Connection timeout
+			log.Printf("Connection timeout: sessionID=%s", sessionID)
+			sse.Event(c.Writer, "error", map[string]interface{}{
+				"code":    "TIMEOUT",
+				"message": "Connection timeout after 5 minutes",
+			})
+			flusher.Flush()
+			return
+
+		case <-ctx.Done():
+			// Client disconnected
+			log.Printf("Client disconnected: sessionID=%s", sessionID)
+			return
+		}
+	}
+}
+
+// StopStream stops the reply being generated for a session. The stream itself ends with
+// stream.done status "stopped", after saving whatever was generated so far.
+func StopStream(fs *fsClient.Client, stops *sse.Stops) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		sessionID := c.Param("id")
 
-			case <-ctx.Done():
-				// Client disconnected
-				log.Printf("Client disconnected: sessionID=%s", sessionID)
+		session, err := fs.GetSession(ctx, sessionID)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
 				return
 			}
+			log.Printf("Error getting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get session"})
+			return
 		}
+
+		if session.UID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+
+		if !stops.Stop(sessionID) {
+			c.JSON(http.StatusConflict, gin.H{"error": "no reply is being generated"})
+			return
+		}
+
+		log.Printf("Stopped stream: uid=%s, sessionID=%s", uid, sessionID)
+		c.JSON(http.StatusOK, gin.H{"stopped": true})
 	}
 }
 
 // Helper functions
 
-func getConversationHistory(ctx context.Context, fs *fsClient.Client, sessionID string) ([]models.Message, error) {
-	iter := fs.DB.Collection("sessions").Doc(sessionID).
+// imageOnlyInstruction stands in for the user's text when they send only an image
+const imageOnlyInstruction = "Describe and coach on the attached image"
+
+// resolveUserMessage returns the text to send through the pipeline, synthesizing an
+// instruction for image-only messages and rejecting messages with neither text nor images
+func resolveUserMessage(text string, attachments []models.Attachment) (string, error) {
+	if strings.TrimSpace(text) != "" {
+		return text, nil
+	}
+
+	for _, attachment := range attachments {
+		if attachment.Type == models.AttachmentTypeImage {
+			return imageOnlyInstruction, nil
+		}
+	}
+
+	return "", fmt.Errorf("message or image attachment is required")
+}
+
+// historyLookback bounds how many recent messages are loaded as conversation history; the
+// pipeline trims them further to its token budget
+const historyLookback = 100
+
+// getConversationHistory returns the session's messages before the one with beforeID, oldest
+// first. Messages after it, like the reply being regenerated, are left out.
+func getConversationHistory(ctx context.Context, fs *fsClient.Client, sessionID, beforeID string) ([]models.Message, error) {
+	docs, err := fs.DB.Collection("sessions").Doc(sessionID).
 		Collection("messages").
-		OrderBy("created_at", firestore.Asc).
-		Documents(ctx)
-	defer iter.Stop()
+		OrderBy("created_at", firestore.Desc).
+		Limit(historyLookback).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
 
-	var messages []models.Message
-	for {
-		doc, err := iter.Next()
-		if err != nil {
-			break
+	messages := []models.Message{}
+	found := false
+	for _, doc := range docs {
+		if !found {
+			found = doc.Ref.ID == beforeID
+			continue
 		}
 
 		var msg models.Message
@@ -255,9 +527,82 @@ func getConversationHistory(ctx context.Context, fs *fsClient.Client, sessionID
 		messages = append(messages, msg)
 	}
 
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
 	return messages, nil
 }
 
+// regenerateLookback bounds how many recent messages are scanned for the last user message
+const regenerateLookback = 20
+
+// getLastUserMessage returns the most recent user message in a session, or nil if there is none
+func getLastUserMessage(ctx context.Context, fs *fsClient.Client, sessionID string) (*models.Message, error) {
+	docs, err := fs.DB.Collection("sessions").Doc(sessionID).
+		Collection("messages").
+		OrderBy("created_at", firestore.Desc).
+		Limit(regenerateLookback).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		var msg models.Message
+		if err := doc.DataTo(&msg); err != nil {
+			continue
+		}
+		if msg.Role == "user" {
+			return &msg, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// saveUserMessage adds a user message to the session transcript and bumps the session's updated_at.
+// Sensitive data is redacted unless the session's coach spec allows storing it.
+func saveUserMessage(ctx context.Context, fs *fsClient.Client, sessionID, text string, attachments []models.Attachment, spec *models.CoachSpec) (models.Message, error) {
+	text, redacted := redact.ForStorage(text, spec)
+	if redacted > 0 {
+		log.Printf("Redacted %d sensitive value(s) from user message in session %s", redacted, sessionID)
+	}
+
+	msg := models.Message{
+		ID:          uuid.New().String(),
+		Role:        "user",
+		ContentText: text,
+		Attachments: attachments,
+		CreatedAt:   time.Now(),
+	}
+
+	sessionRef := fs.DB.Collection("sessions").Doc(sessionID)
+	if _, err := sessionRef.Collection("messages").Doc(msg.ID).Set(ctx, msg); err != nil {
+		return models.Message{}, err
+	}
+
+	if _, err := sessionRef.Update(ctx, []firestore.Update{
+		{Path: "updated_at", Value: msg.CreatedAt},
+	}); err != nil {
+		log.Printf("Error updating session: %v", err)
+	}
+	return msg, nil
+}
+
+// hasAssistantReply reports whether the coach has already replied in a session.
+// Lookup errors are treated as "replied" so the disclosure isn't repeated mid-conversation.
+func hasAssistantReply(ctx context.Context, fs *fsClient.Client, sessionID string) bool {
+	docs, err := fs.DB.Collection("sessions").Doc(sessionID).
+		Collection("messages").
+		Where("role", "==", "assistant").
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return true
+	}
+	return len(docs) > 0
+}
+
 func buildSystemPrompt(blueprint map[string]interface{}) string {
 	// Default system prompt
 	prompt := `You are a minimalist AI coach. Your style:
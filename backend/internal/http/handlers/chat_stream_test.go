@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
+	"simon-backend/internal/sse"
+)
+
+func TestAcquireStreamRejectsWith429(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	streams := sse.NewLimiter(1)
+	acquire := func() (*httptest.ResponseRecorder, func(), bool) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/sessions/s1/stream", nil)
+		release, ok := acquireStream(c, streams, "u1")
+		return w, release, ok
+	}
+
+	_, release, ok := acquire()
+	if !ok {
+		t.Fatal("first stream rejected")
+	}
+
+	w, _, ok := acquire()
+	if ok || w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second stream: ok %v, status %d; want 429", ok, w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["error"] != "too_many_streams" || body["message"] == "" {
+		t.Errorf("body = %v, want too_many_streams with a message", body)
+	}
+
+	release()
+	if w, release, ok := acquire(); !ok || w.Code != http.StatusOK {
+		t.Errorf("after release: ok %v, status %d; want the slot free", ok, w.Code)
+	} else {
+		release()
+	}
+}
+
+func TestResolveUserMessage(t *testing.T) {
+	image := models.Attachment{Type: "image", MimeType: "image/png"}
+	audio := models.Attachment{Type: "audio", MimeType: "audio/m4a"}
+	tests := []struct {
+		name        string
+		text        string
+		attachments []models.Attachment
+		want        string
+		wantErr     bool
+	}{
+		{"text", "How's my layout?", []models.Attachment{image}, "How's my layout?", false},
+		{"image only", "", []models.Attachment{image}, imageOnlyInstruction, false},
+		{"whitespace and an image", "  \n", []models.Attachment{audio, image}, imageOnlyInstruction, false},
+		{"audio only", "", []models.Attachment{audio}, "", true},
+		{"nothing", " ", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveUserMessage(tt.text, tt.attachments)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("resolveUserMessage = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamChatImageOnly(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coachID := "sage"
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1", Credits: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc(coachID).Set(ctx, models.Coach{
+		ID:         coachID,
+		Visibility: "public",
+		CoachSpec:  &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "design"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1", CoachID: &coachID}); err != nil {
+		t.Fatal(err)
+	}
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "quick_answer", "confidence": 0.9}`},
+		geminitest.Script{Prefix: "You are Sage, a design coach.", Text: "The header is crowding the form. "},
+	)
+	provider.Default = "{}"
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), "u1") })
+	r.POST("/v1/sessions/:id/stream", StreamChat(fs, provider, config.Config{}, sse.NewLimiter(1), sse.NewStops(), DefaultToolServices(fs, nil).Plans))
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/sessions/s1/stream", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(`{"message": ""}`); w.Code != http.StatusBadRequest {
+		t.Errorf("empty message: status %d, want 400", w.Code)
+	}
+	if n := len(provider.Calls()); n != 0 {
+		t.Fatalf("empty message reached the model %d times", n)
+	}
+
+	w := post(`{"attachments": [{"type": "image", "mime_type": "image/png", "storage_path": "u1/layout.png"}]}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "The header is crowding the form.") {
+		t.Fatalf("image-only message: status %d, body %s", w.Code, w.Body)
+	}
+	var routed bool
+	for _, call := range provider.Calls() {
+		if strings.HasPrefix(call.SystemPrompt, "Classify the user's intent") {
+			routed = strings.Contains(call.SystemPrompt+call.UserPrompt, imageOnlyInstruction)
+		}
+	}
+	if !routed {
+		t.Error("the synthesized instruction was not routed")
+	}
+}
+
+func TestStopStream(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, map[string]interface{}{"id": "s1", "uid": "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	stops := sse.NewStops()
+	stop := func(uid, sessionID string) int {
+		t.Helper()
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), uid) })
+		r.POST("/v1/sessions/:id/stop", StopStream(fs, stops))
+		return serve(r, http.MethodPost, "/v1/sessions/"+sessionID+"/stop").Code
+	}
+
+	if code := stop("u1", "missing"); code != http.StatusNotFound {
+		t.Errorf("missing session: status = %d, want 404", code)
+	}
+	if code := stop("u1", "s1"); code != http.StatusConflict {
+		t.Errorf("nothing running: status = %d, want 409", code)
+	}
+
+	streamCtx, done := stops.Track(ctx, "s1")
+	defer done()
+	if code := stop("u2", "s1"); code != http.StatusForbidden {
+		t.Errorf("another user's session: status = %d, want 403", code)
+	}
+	if streamCtx.Err() != nil {
+		t.Fatal("another user stopped the stream")
+	}
+	if code := stop("u1", "s1"); code != http.StatusOK {
+		t.Errorf("running stream: status = %d, want 200", code)
+	}
+	if streamCtx.Err() == nil {
+		t.Error("the stream was not cancelled")
+	}
+}
+
+func TestSaveUserMessageRedacts(t *testing.T) {
+	optOut := &models.CoachSpec{}
+	optOut.Policies.Privacy.StoreSensitiveMemory = true
+	tests := []struct {
+		name string
+		spec *models.CoachSpec
+		want string
+	}{
+		{"redacted", nil, "my card is [REDACTED]"},
+		{"opted out", optOut, "my card is 4111 1111 1111 1111"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := firestoretest.New(t)
+			if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1"}); err != nil {
+				t.Fatal(err)
+			}
+
+			msg, err := saveUserMessage(ctx, fs, "s1", "my card is 4111 1111 1111 1111", nil, tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.ContentText != tt.want {
+				t.Errorf("returned message = %q, want %q", msg.ContentText, tt.want)
+			}
+			doc, err := fs.DB.Collection("sessions").Doc("s1").Collection("messages").Doc(msg.ID).Get(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stored models.Message
+			if err := doc.DataTo(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.ContentText != tt.want {
+				t.Errorf("stored message = %q, want %q", stored.ContentText, tt.want)
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"simon-backend/internal/audit"
+	"simon-backend/internal/config"
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
@@ -12,7 +16,7 @@ import (
 )
 
 // ScheduleCheckin handles POST /v1/checkins
-func ScheduleCheckin(fs *firestore.Client) gin.HandlerFunc {
+func ScheduleCheckin(fs *firestore.Client, checkins CheckinToolService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
 
@@ -27,9 +31,7 @@ func ScheduleCheckin(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
-		checkinService := tools.NewCheckinService(fs.DB)
-
-		resp, err := checkinService.Schedule(c.Request.Context(), tools.CheckinScheduleRequest{
+		resp, err := checkins.Schedule(c.Request.Context(), tools.CheckinScheduleRequest{
 			UID:     uid,
 			CoachID: req.CoachID,
 			Cadence: req.Cadence,
@@ -40,6 +42,7 @@ func ScheduleCheckin(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "checkin", audit.ActionCreate, resp.CheckinID)
 		c.JSON(http.StatusCreated, gin.H{
 			"checkin_id": resp.CheckinID,
 			"status":     resp.Status,
@@ -48,13 +51,11 @@ func ScheduleCheckin(fs *firestore.Client) gin.HandlerFunc {
 }
 
 // ListCheckins handles GET /v1/checkins
-func ListCheckins(fs *firestore.Client) gin.HandlerFunc {
+func ListCheckins(fs *firestore.Client, checkins CheckinToolService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
 
-		checkinService := tools.NewCheckinService(fs.DB)
-
-		resp, err := checkinService.List(c.Request.Context(), tools.CheckinListRequest{
+		resp, err := checkins.List(c.Request.Context(), tools.CheckinListRequest{
 			UID: uid,
 		})
 		if err != nil {
@@ -67,7 +68,7 @@ func ListCheckins(fs *firestore.Client) gin.HandlerFunc {
 }
 
 // UpdateCheckin handles PUT /v1/checkins/:id
-func UpdateCheckin(fs *firestore.Client) gin.HandlerFunc {
+func UpdateCheckin(fs *firestore.Client, checkins CheckinToolService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
 		checkinID := c.Param("id")
@@ -86,9 +87,7 @@ func UpdateCheckin(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
-		checkinService := tools.NewCheckinService(fs.DB)
-
-		resp, err := checkinService.Update(c.Request.Context(), tools.CheckinUpdateRequest{
+		resp, err := checkins.Update(c.Request.Context(), tools.CheckinUpdateRequest{
 			UID:       uid,
 			CheckinID: checkinID,
 			Updates:   req.Updates,
@@ -98,6 +97,7 @@ func UpdateCheckin(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "checkin", audit.ActionUpdate, checkinID)
 		c.JSON(http.StatusOK, gin.H{
 			"status": resp.Status,
 		})
@@ -105,7 +105,7 @@ func UpdateCheckin(fs *firestore.Client) gin.HandlerFunc {
 }
 
 // DeleteCheckin handles DELETE /v1/checkins/:id
-func DeleteCheckin(fs *firestore.Client) gin.HandlerFunc {
+func DeleteCheckin(fs *firestore.Client, checkins CheckinToolService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
 		checkinID := c.Param("id")
@@ -115,16 +115,56 @@ func DeleteCheckin(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
-		checkinService := tools.NewCheckinService(fs.DB)
-
-		if err := checkinService.Delete(c.Request.Context(), uid, checkinID); err != nil {
+		if err := checkins.Delete(c.Request.Context(), uid, checkinID); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		recordAudit(c, fs, "checkin", audit.ActionDelete, checkinID)
 		c.JSON(http.StatusOK, gin.H{
 			"status": "deleted",
 		})
 	}
 }
 
+
+// AcknowledgeCheckin handles POST /v1/checkins/:id/ack
+func AcknowledgeCheckin(fs *firestore.Client, checkins CheckinToolService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := middleware.GetUID(c)
+		checkinID := c.Param("id")
+
+		if err := checkins.Acknowledge(c.Request.Context(), uid, checkinID); err != nil {
+			status, ok := toolErrorStatus(err)
+			if !ok {
+				log.Printf("Error acknowledging checkin %s: %v", checkinID, err)
+				status = http.StatusInternalServerError
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "acknowledged",
+		})
+	}
+}
+
+// RunDueCheckins handles POST /internal/checkins/run.
+// It delivers nudges for due check-ins, holding back ones still in their cooldown window.
+func RunDueCheckins(fs *firestore.Client, cfg config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checkinService := tools.NewCheckinService(fs.DB)
+		cooldown := time.Duration(cfg.CheckinCooldownMinutes) * time.Minute
+
+		result, err := checkinService.RunDue(c.Request.Context(), time.Now(), cooldown)
+		if err != nil {
+			log.Printf("Checkin run failed after %d checkins: %v", result.Scanned, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to run checkins"})
+			return
+		}
+
+		log.Printf("Checkin run: scanned=%d delivered=%d suppressed=%d", result.Scanned, result.Delivered, result.Suppressed)
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	fsClient "simon-backend/internal/firestore"
+	geminiClient "simon-backend/internal/gemini"
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/coach"
+)
+
+// GetCoachSample returns a short example exchange for a public coach (public endpoint).
+// Samples are cached in coach_samples and regenerated only when the coach version changes.
+func GetCoachSample(fs *fsClient.Client, gm geminiClient.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		coachID := c.Param("id")
+
+		doc, err := fs.DB.Collection("coaches").Doc(coachID).Get(ctx)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+				return
+			}
+			log.Printf("Error getting coach: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get coach"})
+			return
+		}
+
+		var coachDoc models.Coach
+		if err := doc.DataTo(&coachDoc); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse coach"})
+			return
+		}
+
+		// Samples are only generated for browsable coaches
+		if coachDoc.Visibility != "public" || coachDoc.Status == models.CoachStatusDeleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+			return
+		}
+		if coachDoc.CoachSpec == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "sample not available for this coach"})
+			return
+		}
+
+		version := coachDoc.SampleVersion()
+		sampleRef := fs.DB.Collection("coach_samples").Doc(coachID)
+
+		// Serve the cached sample if it was generated for this version
+		if sampleDoc, err := sampleRef.Get(ctx); err == nil {
+			var cached models.CoachSample
+			if err := sampleDoc.DataTo(&cached); err == nil && cached.Version == version {
+				c.JSON(http.StatusOK, cached)
+				return
+			}
+		} else if !fsClient.IsNotFound(err) {
+			log.Printf("Error getting coach sample: %v", err)
+		}
+
+		problem := coach.SampleProblem(&coachDoc)
+		reply, err := coach.NewCoachAgent(gm, coach.Options{}).Sample(ctx, coachDoc.CoachSpec, problem)
+		if err != nil {
+			log.Printf("Error generating coach sample: coachID=%s, err=%v", coachID, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to generate sample"})
+			return
+		}
+
+		sample := models.CoachSample{
+			CoachID:   coachID,
+			Version:   version,
+			UserText:  problem,
+			CoachText: reply,
+			CreatedAt: time.Now(),
+		}
+
+		if _, err := sampleRef.Set(ctx, sample); err != nil {
+			log.Printf("Error caching coach sample: %v", err)
+		}
+
+		log.Printf("Generated coach sample: coachID=%s, version=%s", coachID, version)
+		c.JSON(http.StatusOK, sample)
+	}
+}
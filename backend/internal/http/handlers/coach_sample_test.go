@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/models"
+)
+
+func TestGetCoachSampleCachedPerVersion(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coachDoc := models.Coach{
+		ID:         "c1",
+		Title:      "Drill Sergeant",
+		Visibility: "public",
+		CoachSpec: &models.CoachSpec{
+			Version:  "1.0",
+			Identity: models.Identity{Name: "Rex", Niche: "fitness", StarterPrompts: []string{"I skipped the gym again"}},
+			Style:    models.Style{Tone: "blunt", Verbosity: "low"},
+		},
+		UpdatedAt: time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC),
+	}
+	if _, err := fs.DB.Collection("coaches").Doc("c1").Set(ctx, coachDoc); err != nil {
+		t.Fatal(err)
+	}
+	fake := geminitest.NewFakeProvider(geminitest.Script{Prefix: "You are Rex, a fitness coach.", Text: "No excuses. Go tonight."})
+	r := gin.New()
+	r.GET("/v1/coaches/:id/sample", GetCoachSample(fs, fake))
+
+	get := func() models.CoachSample {
+		t.Helper()
+		w := serve(r, http.MethodGet, "/v1/coaches/c1/sample")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+		var sample models.CoachSample
+		if err := json.Unmarshal(w.Body.Bytes(), &sample); err != nil {
+			t.Fatal(err)
+		}
+		return sample
+	}
+
+	sample := get()
+	if sample.UserText != "I skipped the gym again" || sample.CoachText != "No excuses. Go tonight." {
+		t.Errorf("sample = %+v, want the starter prompt and the coach's reply", sample)
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 || !strings.Contains(calls[0].SystemPrompt, "- Tone: blunt") {
+		t.Fatalf("calls = %+v, want one generation in the coach's tone", calls)
+	}
+
+	// Views within the same version are served from the cache
+	if again := get(); again.CoachText != sample.CoachText || again.Version != sample.Version {
+		t.Errorf("second view = %+v, want the cached sample", again)
+	}
+	if n := len(fake.Calls()); n != 1 {
+		t.Errorf("generated %d times, want the cached sample reused", n)
+	}
+
+	// Editing the coach bumps the version and regenerates
+	coachDoc.UpdatedAt = coachDoc.UpdatedAt.Add(time.Hour)
+	if _, err := fs.DB.Collection("coaches").Doc("c1").Set(ctx, coachDoc); err != nil {
+		t.Fatal(err)
+	}
+	if updated := get(); updated.Version == sample.Version {
+		t.Errorf("version = %q after an edit, want a new one", updated.Version)
+	}
+	if n := len(fake.Calls()); n != 2 {
+		t.Errorf("generated %d times, want a regeneration for the new version", n)
+	}
+}
+
+func TestGetCoachSampleHidden(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	spec := &models.CoachSpec{Identity: models.Identity{Name: "Rex", Niche: "fitness"}}
+	coaches := []models.Coach{
+		{ID: "private", Visibility: "private", CoachSpec: spec},
+		{ID: "deleted", Visibility: "public", Status: models.CoachStatusDeleted, CoachSpec: spec},
+		{ID: "no_spec", Visibility: "public"},
+	}
+	for _, coachDoc := range coaches {
+		if _, err := fs.DB.Collection("coaches").Doc(coachDoc.ID).Set(ctx, coachDoc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake := geminitest.NewFakeProvider()
+	fake.Default = "unused"
+	r := gin.New()
+	r.GET("/v1/coaches/:id/sample", GetCoachSample(fs, fake))
+
+	for _, id := range []string{"private", "deleted", "no_spec", "missing"} {
+		if w := serve(r, http.MethodGet, "/v1/coaches/"+id+"/sample"); w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", id, w.Code)
+		}
+	}
+	if n := len(fake.Calls()); n != 0 {
+		t.Errorf("generated %d samples for hidden coaches", n)
+	}
+}
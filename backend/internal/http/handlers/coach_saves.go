@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"simon-backend/internal/audit"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
+)
+
+// maxSavedCoaches bounds how many saved coaches GET /v1/me/saved-coaches returns
+const maxSavedCoaches = 200
+
+// SaveCoach handles POST /v1/coaches/:id/save. Saving twice is a no-op.
+func SaveCoach(fs *fsClient.Client) gin.HandlerFunc {
+	return setCoachSaved(fs, true)
+}
+
+// UnsaveCoach handles DELETE /v1/coaches/:id/save. Unsaving a coach that isn't saved is a no-op.
+func UnsaveCoach(fs *fsClient.Client) gin.HandlerFunc {
+	return setCoachSaved(fs, false)
+}
+
+// setCoachSaved adds or removes the caller's bookmark and returns the coach's current save count
+func setCoachSaved(fs *fsClient.Client, saved bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		coachID := c.Param("id")
+
+		count, changed, err := applyCoachSave(ctx, fs, uid, coachID, saved)
+		if err != nil {
+			if status, ok := coachEngagementStatus(err); ok {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Error updating save: uid=%s, coachID=%s, err=%v", uid, coachID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update save"})
+			return
+		}
+
+		if changed {
+			verb := audit.ActionCreate
+			if !saved {
+				verb = audit.ActionDelete
+			}
+			recordAudit(c, fs, "saved_coach", verb, coachID)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"coach_id": coachID,
+			"saved":    saved,
+			"saves":    count,
+		})
+	}
+}
+
+// applyCoachSave writes or deletes the user's saved_coaches document and adjusts stats.saves
+// in the same transaction. It reports whether anything changed.
+func applyCoachSave(ctx context.Context, fs *fsClient.Client, uid, coachID string, saved bool) (int, bool, error) {
+	coachRef := fs.DB.Collection("coaches").Doc(coachID)
+	saveRef := fs.DB.Collection("users").Doc(uid).Collection("saved_coaches").Doc(coachID)
+
+	var count int
+	var changed bool
+	err := fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		changed = false
+
+		_, err := tx.Get(saveRef)
+		exists := err == nil
+		if err != nil && !fsClient.IsNotFound(err) {
+			return err
+		}
+
+		// Unsaving needs no access check, so users can clear bookmarks of coaches that
+		// were since made private or deleted
+		if !saved {
+			if !exists {
+				return nil
+			}
+			changed = true
+
+			// Transactions must read before writing
+			coachDoc, err := tx.Get(coachRef)
+			if err != nil {
+				if fsClient.IsNotFound(err) {
+					return tx.Delete(saveRef)
+				}
+				return err
+			}
+			var coach models.Coach
+			if err := coachDoc.DataTo(&coach); err != nil {
+				return err
+			}
+			count = coach.Stats.Saves - 1
+
+			if err := tx.Delete(saveRef); err != nil {
+				return err
+			}
+			return tx.Update(coachRef, []firestore.Update{
+				{Path: "stats.saves", Value: firestore.Increment(-1)},
+			})
+		}
+
+		coach, err := loadEngageableCoach(tx, coachRef, uid, true)
+		if err != nil {
+			return err
+		}
+		count = coach.Stats.Saves
+		if exists {
+			return nil
+		}
+
+		if err := tx.Create(saveRef, models.SavedCoach{CoachID: coachID, CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+		count++
+		changed = true
+		return tx.Update(coachRef, []firestore.Update{
+			{Path: "stats.saves", Value: firestore.Increment(1)},
+		})
+	})
+	return count, changed, err
+}
+
+// ListSavedCoaches handles GET /v1/me/saved-coaches, newest save first.
+// Coaches deleted (or made private) since they were saved are skipped.
+func ListSavedCoaches(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+
+		saveDocs, err := fs.DB.Collection("users").Doc(uid).Collection("saved_coaches").
+			OrderBy("created_at", firestore.Desc).
+			Limit(maxSavedCoaches).
+			Documents(ctx).GetAll()
+		if err != nil {
+			log.Printf("Error listing saved coaches for %s: %v", uid, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list saved coaches"})
+			return
+		}
+
+		coaches := []models.Coach{}
+		if len(saveDocs) == 0 {
+			c.JSON(http.StatusOK, coaches)
+			return
+		}
+
+		refs := make([]*firestore.DocumentRef, len(saveDocs))
+		for i, doc := range saveDocs {
+			refs[i] = fs.DB.Collection("coaches").Doc(doc.Ref.ID)
+		}
+
+		coachDocs, err := fs.DB.GetAll(ctx, refs)
+		if err != nil {
+			log.Printf("Error loading saved coaches for %s: %v", uid, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load saved coaches"})
+			return
+		}
+
+		for _, doc := range coachDocs {
+			if !doc.Exists() {
+				continue
+			}
+			var coach models.Coach
+			if err := doc.DataTo(&coach); err != nil {
+				log.Printf("Error parsing coach %s: %v", doc.Ref.ID, err)
+				continue
+			}
+			if coach.Status == models.CoachStatusDeleted || (coach.Visibility == "private" && coach.OwnerUID != uid) {
+				continue
+			}
+			coaches = append(coaches, coach)
+		}
+
+		c.JSON(http.StatusOK, coaches)
+	}
+}
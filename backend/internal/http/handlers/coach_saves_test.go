@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestCoachSaves(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	seedEngagementCoaches(t, fs)
+	r := engagementRouter(fs)
+	listSaved := func() []string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/me/saved-coaches", nil)
+		req.Header.Set("X-Test-UID", "u1")
+		r.ServeHTTP(w, req)
+		var coaches []models.Coach
+		if err := json.Unmarshal(w.Body.Bytes(), &coaches); err != nil {
+			t.Fatalf("status %d, body %s: %v", w.Code, w.Body, err)
+		}
+		var ids []string
+		for _, coach := range coaches {
+			ids = append(ids, coach.ID)
+		}
+		return ids
+	}
+
+	// Saving twice counts once; users may bookmark their own coaches
+	for range 2 {
+		code, body := engage(t, r, "u1", http.MethodPost, "/v1/coaches/public/save")
+		if code != http.StatusOK || body["saves"] != float64(1) || body["saved"] != true {
+			t.Errorf("save: status %d, body %v", code, body)
+		}
+	}
+	if code, body := engage(t, r, "u1", http.MethodPost, "/v1/coaches/mine/save"); code != http.StatusOK || body["saves"] != float64(1) {
+		t.Errorf("save own coach: status %d, body %v", code, body)
+	}
+	if got := coachStats(t, fs, "public").Saves; got != 1 {
+		t.Errorf("stored saves = %d, want 1", got)
+	}
+	if ids := listSaved(); len(ids) != 2 {
+		t.Errorf("saved coaches = %v, want public and mine", ids)
+	}
+
+	tests := []struct {
+		name    string
+		coachID string
+		want    int
+	}{
+		{"someone else's private coach", "secret", http.StatusForbidden},
+		{"deleted coach", "gone", http.StatusNotFound},
+		{"missing coach", "missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := engage(t, r, "u1", http.MethodPost, "/v1/coaches/"+tt.coachID+"/save"); code != tt.want {
+				t.Errorf("status %d, body %v; want %d", code, body, tt.want)
+			}
+		})
+	}
+
+	// A coach made private after it was saved drops out of the list but can still be unsaved
+	if _, err := fs.DB.Collection("coaches").Doc("public").Update(ctx, []firestore.Update{{Path: "visibility", Value: "private"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := listSaved(); len(ids) != 1 || ids[0] != "mine" {
+		t.Errorf("saved coaches = %v, want only mine", ids)
+	}
+	for range 2 {
+		code, body := engage(t, r, "u1", http.MethodDelete, "/v1/coaches/public/save")
+		if code != http.StatusOK || body["saves"] != float64(0) || body["saved"] != false {
+			t.Errorf("unsave: status %d, body %v", code, body)
+		}
+	}
+	if got := coachStats(t, fs, "public").Saves; got != 0 {
+		t.Errorf("stored saves = %d, want 0", got)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/models"
+)
+
+// ReindexCoachSearch rewrites search_tokens on every coach whose stored tokens are missing or
+// stale, so coaches saved before tokens existed show up in search. Safe to re-run.
+func ReindexCoachSearch(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scanned, updated, err := reindexCoachSearch(c.Request.Context(), fs)
+		if err != nil {
+			log.Printf("Coach search reindex failed after %d coaches: %v", scanned, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reindex coaches"})
+			return
+		}
+
+		log.Printf("Coach search reindex: scanned=%d updated=%d", scanned, updated)
+		c.JSON(http.StatusOK, gin.H{
+			"scanned": scanned,
+			"updated": updated,
+		})
+	}
+}
+
+// reindexCoachSearch pages through all coaches by ID, batching token updates per page
+func reindexCoachSearch(ctx context.Context, fs *fsClient.Client) (int, int, error) {
+	scanned, updated := 0, 0
+	var lastDoc *firestore.DocumentSnapshot
+
+	for {
+		query := fs.DB.Collection("coaches").
+			OrderBy(firestore.DocumentID, firestore.Asc).
+			Limit(reconcilePageSize)
+		if lastDoc != nil {
+			query = query.StartAfter(lastDoc)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return scanned, updated, err
+		}
+		if len(docs) == 0 {
+			return scanned, updated, nil
+		}
+
+		batch := fs.DB.Batch()
+		pending := 0
+		for _, doc := range docs {
+			scanned++
+
+			var coach models.Coach
+			if err := doc.DataTo(&coach); err != nil {
+				log.Printf("Error parsing coach %s: %v", doc.Ref.ID, err)
+				continue
+			}
+			tokens := coach.BuildSearchTokens()
+			if slices.Equal(tokens, coach.SearchTokens) {
+				continue
+			}
+
+			batch.Update(doc.Ref, []firestore.Update{{Path: "search_tokens", Value: tokens}})
+			pending++
+		}
+
+		if pending > 0 {
+			if _, err := batch.Commit(ctx); err != nil {
+				return scanned, updated, err
+			}
+			updated += pending
+		}
+
+		if len(docs) < reconcilePageSize {
+			return scanned, updated, nil
+		}
+		lastDoc = docs[len(docs)-1]
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+	"simon-backend/internal/textutil"
+)
+
+func TestCoachMatchesSearch(t *testing.T) {
+	coach := models.Coach{
+		Title:     "İstanbul Run Club 🏃",
+		Promise:   "Weekly plans for your first 10k",
+		Tags:      []string{"fitness", "running"},
+		CoachSpec: &models.CoachSpec{Identity: models.Identity{Niche: "Endurance"}},
+	}
+	coach.SearchTokens = coach.BuildSearchTokens()
+
+	tests := []struct {
+		name string
+		tag  string
+		q    string
+		want bool
+	}{
+		{"title word with a different case", "", "ISTANBUL", true},
+		{"words from title, promise and niche", "", "run 10k endurance", true},
+		{"tag text is searchable", "", "Fitness", true},
+		{"every word must match", "", "run swim", false},
+		{"tag filter applied alongside the query", "running", "club", true},
+		{"foreign tag excluded", "sleep", "club", false},
+		{"partial words don't match", "", "endur", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := coachMatchesSearch(coach, tt.tag, textutil.SearchTokens(tt.q)); got != tt.want {
+				t.Errorf("coachMatchesSearch(tag=%q, q=%q) = %v, want %v", tt.tag, tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListCoachesSearchesTokens(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coaches := []models.Coach{
+		{ID: "kosu", Visibility: "public", Title: "İstanbul Koşu Kulübü 🏃‍♀️🔥", Promise: "İlk 10k koşun için haftalık plan"},
+		{ID: "sleep", Visibility: "public", Title: "Sleep Better 😴", Promise: "Wind down without your phone"},
+		{ID: "hidden", Visibility: "private", Title: "İstanbul Secret Club"},
+	}
+	for _, coach := range coaches {
+		coach.SearchTokens = coach.BuildSearchTokens()
+		if _, err := fs.DB.Collection("coaches").Doc(coach.ID).Set(ctx, coach); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v1/coaches", ListCoaches(fs))
+	search := func(q string) []models.Coach {
+		t.Helper()
+		w := serve(r, http.MethodGet, "/v1/coaches?q="+q)
+		if w.Code != http.StatusOK {
+			t.Fatalf("q=%s: status = %d, body %s", q, w.Code, w.Body)
+		}
+		var found []models.Coach
+		if err := json.Unmarshal(w.Body.Bytes(), &found); err != nil {
+			t.Fatal(err)
+		}
+		return found
+	}
+
+	// Dotless capitals and the emoji-laden title still find the coach
+	found := search("ISTANBUL%20KO%C5%9EU")
+	if len(found) != 1 || found[0].ID != "kosu" {
+		t.Fatalf("search = %+v, want the Turkish coach only", found)
+	}
+	// Emoji are dropped from tokens but kept in what's displayed
+	if found[0].Title != "İstanbul Koşu Kulübü 🏃‍♀️🔥" {
+		t.Errorf("title = %q, want it stored as written", found[0].Title)
+	}
+	if slices.ContainsFunc(found[0].SearchTokens, func(token string) bool { return token == "🔥" || token == "" }) {
+		t.Errorf("tokens = %q, want no emoji or empty tokens", found[0].SearchTokens)
+	}
+
+	if found := search("sleep%F0%9F%98%B4"); len(found) != 1 || found[0].ID != "sleep" {
+		t.Errorf("emoji-glued query = %+v, want the sleep coach", found)
+	}
+	if found := search("%F0%9F%94%A5"); len(found) != 0 {
+		t.Errorf("emoji-only query = %+v, want nothing", found)
+	}
+}
+
+func TestReindexCoachSearch(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	stale := models.Coach{ID: "stale", Title: "Çalışma Odağı ⏱️", SearchTokens: []string{"old"}}
+	current := models.Coach{ID: "current", Title: "Deep Work"}
+	current.SearchTokens = current.BuildSearchTokens()
+	for _, coach := range []models.Coach{stale, current, {ID: "missing", Title: "Morning Pages ✍️"}} {
+		if _, err := fs.DB.Collection("coaches").Doc(coach.ID).Set(ctx, coach); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanned, updated, err := reindexCoachSearch(ctx, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != 3 || updated != 2 {
+		t.Errorf("scanned %d, updated %d; want 3 and 2", scanned, updated)
+	}
+	doc, err := fs.DB.Collection("coaches").Doc("stale").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var coach models.Coach
+	if err := doc.DataTo(&coach); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(coach.SearchTokens, []string{"çalışma", "odağı"}) || coach.Title != "Çalışma Odağı ⏱️" {
+		t.Errorf("reindexed coach = %q with tokens %q", coach.Title, coach.SearchTokens)
+	}
+
+	if _, updated, err := reindexCoachSearch(ctx, fs); err != nil || updated != 0 {
+		t.Errorf("second run updated %d (err %v), want 0", updated, err)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
+	"github.com/gin-gonic/gin"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/models"
+)
+
+// RecomputeCoachStats re-derives a coach's stats from the underlying records and
+// overwrites the stored counters. Safe to re-run; it never increments.
+func RecomputeCoachStats(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		coachID := c.Param("id")
+
+		coachRef := fs.DB.Collection("coaches").Doc(coachID)
+		doc, err := coachRef.Get(ctx)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+				return
+			}
+			log.Printf("Error getting coach %s: %v", coachID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get coach"})
+			return
+		}
+
+		var coach models.Coach
+		if err := doc.DataTo(&coach); err != nil {
+			log.Printf("Error parsing coach %s: %v", coachID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse coach"})
+			return
+		}
+
+		stats, err := deriveCoachStats(ctx, fs, coachID)
+		if err != nil {
+			log.Printf("Error recomputing stats for coach %s: %v", coachID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to recompute stats"})
+			return
+		}
+
+		if stats != coach.Stats {
+			if _, err := coachRef.Update(ctx, []firestore.Update{
+				{Path: "stats", Value: stats},
+			}); err != nil {
+				log.Printf("Error writing stats for coach %s: %v", coachID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update stats"})
+				return
+			}
+			log.Printf("Coach %s stats corrected: %+v -> %+v", coachID, coach.Stats, stats)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"coach_id": coachID,
+			"previous": coach.Stats,
+			"stats":    stats,
+			"changed":  stats != coach.Stats,
+		})
+	}
+}
+
+// deriveCoachStats counts the records each stat is meant to track
+func deriveCoachStats(ctx context.Context, fs *fsClient.Client, coachID string) (models.CoachStats, error) {
+	var stats models.CoachStats
+	var err error
+
+	if stats.Starts, err = countDocuments(ctx, fs.DB.Collection("sessions").Where("coach_id", "==", coachID)); err != nil {
+		return stats, fmt.Errorf("count sessions: %w", err)
+	}
+	if stats.Saves, err = countDocuments(ctx, fs.DB.CollectionGroup("saved_coaches").Where("coach_id", "==", coachID)); err != nil {
+		return stats, fmt.Errorf("count saves: %w", err)
+	}
+	if stats.Upvotes, err = countDocuments(ctx, fs.DB.Collection("coaches").Doc(coachID).Collection("upvotes").Query); err != nil {
+		return stats, fmt.Errorf("count upvotes: %w", err)
+	}
+
+	return stats, nil
+}
+
+// countDocuments runs a server-side count aggregation over a query
+func countDocuments(ctx context.Context, query firestore.Query) (int, error) {
+	result, err := query.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("unexpected count result %T", result["count"])
+	}
+	return int(value.GetIntegerValue()), nil
+}
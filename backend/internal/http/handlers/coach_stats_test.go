@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestRecomputeCoachStats(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/internal/coaches/:id/recompute-stats", RecomputeCoachStats(fs))
+
+	seed := func(path string, data map[string]interface{}) {
+		t.Helper()
+		if _, err := fs.DB.Doc(path).Set(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Counters drifted: starts overcounted, a save never counted, an upvote counted twice
+	seed("coaches/c1", map[string]interface{}{
+		"id":    "c1",
+		"title": "Focus coach",
+		"stats": map[string]interface{}{"starts": 7, "saves": 0, "upvotes": 3},
+	})
+	seed("sessions/s1", map[string]interface{}{"uid": "u1", "coach_id": "c1"})
+	seed("sessions/s2", map[string]interface{}{"uid": "u2", "coach_id": "c1"})
+	seed("sessions/s3", map[string]interface{}{"uid": "u1", "coach_id": "other"})
+	seed("users/u1/saved_coaches/c1", map[string]interface{}{"coach_id": "c1"})
+	seed("users/u2/saved_coaches/other", map[string]interface{}{"coach_id": "other"})
+	seed("coaches/c1/upvotes/u1", map[string]interface{}{"uid": "u1"})
+
+	want := models.CoachStats{Starts: 2, Saves: 1, Upvotes: 1}
+	recompute := func() (models.CoachStats, bool) {
+		t.Helper()
+		w := serve(r, http.MethodPost, "/internal/coaches/c1/recompute-stats")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+		var resp struct {
+			Stats   models.CoachStats `json:"stats"`
+			Changed bool              `json:"changed"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Stats, resp.Changed
+	}
+
+	stats, changed := recompute()
+	if stats != want || !changed {
+		t.Errorf("recompute = %+v (changed %v), want %+v corrected", stats, changed, want)
+	}
+	doc, err := fs.DB.Doc("coaches/c1").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var coach models.Coach
+	if err := doc.DataTo(&coach); err != nil {
+		t.Fatal(err)
+	}
+	if coach.Stats != want {
+		t.Errorf("stored stats = %+v, want %+v", coach.Stats, want)
+	}
+
+	// Running it again finds nothing to correct and writes nothing
+	updated := doc.UpdateTime
+	if stats, changed := recompute(); stats != want || changed {
+		t.Errorf("second recompute = %+v (changed %v), want the same stats unchanged", stats, changed)
+	}
+	doc, err = fs.DB.Doc("coaches/c1").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !doc.UpdateTime.Equal(updated) {
+		t.Error("second recompute rewrote the coach")
+	}
+
+	if w := serve(r, http.MethodPost, "/internal/coaches/missing/recompute-stats"); w.Code != http.StatusNotFound {
+		t.Errorf("missing coach: status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"simon-backend/internal/audit"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
+)
+
+// Coach engagement failures surfaced to the caller
+var (
+	errCoachNotFound     = errors.New("coach not found")
+	errCoachAccessDenied = errors.New("access denied")
+	errOwnCoach          = errors.New("you can't do this on your own coach")
+)
+
+// UpvoteCoach handles POST /v1/coaches/:id/upvote. Voting twice is a no-op.
+func UpvoteCoach(fs *fsClient.Client) gin.HandlerFunc {
+	return setCoachUpvote(fs, true)
+}
+
+// RemoveCoachUpvote handles DELETE /v1/coaches/:id/upvote. Removing a missing vote is a no-op.
+func RemoveCoachUpvote(fs *fsClient.Client) gin.HandlerFunc {
+	return setCoachUpvote(fs, false)
+}
+
+// setCoachUpvote adds or removes the caller's upvote and returns the coach's current count
+func setCoachUpvote(fs *fsClient.Client, upvoted bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		coachID := c.Param("id")
+
+		count, changed, err := applyCoachUpvote(ctx, fs, uid, coachID, upvoted)
+		if err != nil {
+			if status, ok := coachEngagementStatus(err); ok {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Error updating upvote: uid=%s, coachID=%s, err=%v", uid, coachID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update upvote"})
+			return
+		}
+
+		if changed {
+			verb := audit.ActionCreate
+			if !upvoted {
+				verb = audit.ActionDelete
+			}
+			recordAudit(c, fs, "coach_upvote", verb, coachID)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"coach_id": coachID,
+			"upvoted":  upvoted,
+			"upvotes":  count,
+		})
+	}
+}
+
+// applyCoachUpvote writes or deletes the vote document and adjusts stats.upvotes in the same
+// transaction, so the counter always matches the votes. It reports whether anything changed.
+func applyCoachUpvote(ctx context.Context, fs *fsClient.Client, uid, coachID string, upvoted bool) (int, bool, error) {
+	coachRef := fs.DB.Collection("coaches").Doc(coachID)
+	voteRef := coachRef.Collection("upvotes").Doc(uid)
+
+	var count int
+	var changed bool
+	err := fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		changed = false
+
+		coach, err := loadEngageableCoach(tx, coachRef, uid, false)
+		if err != nil {
+			return err
+		}
+		count = coach.Stats.Upvotes
+
+		_, err = tx.Get(voteRef)
+		exists := err == nil
+		if err != nil && !fsClient.IsNotFound(err) {
+			return err
+		}
+		if exists == upvoted {
+			return nil
+		}
+
+		delta := 1
+		if upvoted {
+			err = tx.Create(voteRef, models.CoachUpvote{UID: uid, CreatedAt: time.Now()})
+		} else {
+			delta = -1
+			err = tx.Delete(voteRef)
+		}
+		if err != nil {
+			return err
+		}
+
+		count += delta
+		changed = true
+		return tx.Update(coachRef, []firestore.Update{
+			{Path: "stats.upvotes", Value: firestore.Increment(delta)},
+		})
+	})
+	return count, changed, err
+}
+
+// loadEngageableCoach reads a coach the user may upvote or save: it must exist and be visible
+// to them, and unless allowOwn is set it must not be their own
+func loadEngageableCoach(tx *firestore.Transaction, coachRef *firestore.DocumentRef, uid string, allowOwn bool) (*models.Coach, error) {
+	doc, err := tx.Get(coachRef)
+	if err != nil {
+		if fsClient.IsNotFound(err) {
+			return nil, errCoachNotFound
+		}
+		return nil, err
+	}
+
+	var coach models.Coach
+	if err := doc.DataTo(&coach); err != nil {
+		return nil, err
+	}
+	if coach.Status == models.CoachStatusDeleted {
+		return nil, errCoachNotFound
+	}
+	if coach.OwnerUID == uid {
+		if !allowOwn {
+			return nil, errOwnCoach
+		}
+		return &coach, nil
+	}
+	if coach.Visibility == "private" {
+		return nil, errCoachAccessDenied
+	}
+	return &coach, nil
+}
+
+// coachEngagementStatus maps engagement errors to HTTP statuses
+func coachEngagementStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, errCoachNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, errCoachAccessDenied), errors.Is(err, errOwnCoach):
+		return http.StatusForbidden, true
+	}
+	return 0, false
+}
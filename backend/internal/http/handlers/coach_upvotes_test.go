@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
+)
+
+// engagementRouter serves the upvote and save endpoints for the user named in X-Test-UID
+func engagementRouter(fs *fsClient.Client) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), c.GetHeader("X-Test-UID")) })
+	r.POST("/v1/coaches/:id/upvote", UpvoteCoach(fs))
+	r.DELETE("/v1/coaches/:id/upvote", RemoveCoachUpvote(fs))
+	r.POST("/v1/coaches/:id/save", SaveCoach(fs))
+	r.DELETE("/v1/coaches/:id/save", UnsaveCoach(fs))
+	r.GET("/v1/me/saved-coaches", ListSavedCoaches(fs))
+	return r
+}
+
+// engage sends an engagement request as uid and decodes the JSON response
+func engage(t *testing.T, r *gin.Engine, uid, method, path string) (int, map[string]interface{}) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-Test-UID", uid)
+	r.ServeHTTP(w, req)
+	var body map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+// seedEngagementCoaches stores a public coach, one owned by u1, a private one and a deleted one
+func seedEngagementCoaches(t *testing.T, fs *fsClient.Client) {
+	t.Helper()
+	ctx := context.Background()
+	coaches := []models.Coach{
+		{ID: "public", OwnerUID: "owner", Title: "Focus", Visibility: "public"},
+		{ID: "mine", OwnerUID: "u1", Title: "Mine", Visibility: "public"},
+		{ID: "secret", OwnerUID: "owner", Title: "Secret", Visibility: "private"},
+		{ID: "gone", OwnerUID: "owner", Title: "Gone", Visibility: "public", Status: models.CoachStatusDeleted},
+	}
+	for _, coach := range coaches {
+		if _, err := fs.DB.Collection("coaches").Doc(coach.ID).Set(ctx, coach); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// coachStats reads a coach's stored counters
+func coachStats(t *testing.T, fs *fsClient.Client, coachID string) models.CoachStats {
+	t.Helper()
+	coach, err := fs.GetCoach(context.Background(), coachID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return coach.Stats
+}
+
+func TestCoachUpvotes(t *testing.T) {
+	fs := firestoretest.New(t)
+	seedEngagementCoaches(t, fs)
+	r := engagementRouter(fs)
+
+	// Upvoting twice counts once
+	for range 2 {
+		code, body := engage(t, r, "u1", http.MethodPost, "/v1/coaches/public/upvote")
+		if code != http.StatusOK || body["upvotes"] != float64(1) || body["upvoted"] != true {
+			t.Errorf("upvote: status %d, body %v", code, body)
+		}
+	}
+	if got := coachStats(t, fs, "public").Upvotes; got != 1 {
+		t.Errorf("stored upvotes = %d, want 1", got)
+	}
+	if code, body := engage(t, r, "u2", http.MethodPost, "/v1/coaches/public/upvote"); code != http.StatusOK || body["upvotes"] != float64(2) {
+		t.Errorf("second user's upvote: status %d, body %v", code, body)
+	}
+
+	// Removing it brings the count back, and removing it again is a no-op
+	for range 2 {
+		code, body := engage(t, r, "u1", http.MethodDelete, "/v1/coaches/public/upvote")
+		if code != http.StatusOK || body["upvotes"] != float64(1) || body["upvoted"] != false {
+			t.Errorf("remove upvote: status %d, body %v", code, body)
+		}
+	}
+	engage(t, r, "u2", http.MethodDelete, "/v1/coaches/public/upvote")
+	if got := coachStats(t, fs, "public").Upvotes; got != 0 {
+		t.Errorf("stored upvotes = %d, want 0", got)
+	}
+
+	tests := []struct {
+		name    string
+		coachID string
+		want    int
+	}{
+		{"own coach", "mine", http.StatusForbidden},
+		{"someone else's private coach", "secret", http.StatusForbidden},
+		{"deleted coach", "gone", http.StatusNotFound},
+		{"missing coach", "missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := engage(t, r, "u1", http.MethodPost, "/v1/coaches/"+tt.coachID+"/upvote"); code != tt.want {
+				t.Errorf("status %d, body %v; want %d", code, body, tt.want)
+			}
+		})
+	}
+	for _, coachID := range []string{"mine", "secret"} {
+		if got := coachStats(t, fs, coachID).Upvotes; got != 0 {
+			t.Errorf("%s upvotes = %d after a rejected vote", coachID, got)
+		}
+	}
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -10,13 +14,39 @@ import (
 	"github.com/google/uuid"
 	"google.golang.org/api/iterator"
 
+	"simon-backend/internal/audit"
 	fsClient "simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
+	"simon-backend/internal/textutil"
 	"simon-backend/internal/validation"
 )
 
-// ListCoaches returns a list of coaches (public endpoint)
+// coachSearchScanLimit caps how many public coaches a text search scans
+const coachSearchScanLimit = 500
+
+// maxSearchTerms is Firestore's limit on array-contains-any values
+const maxSearchTerms = 30
+
+// coachSortOrders are the accepted values of ListCoaches' sort param
+var coachSortOrders = map[string]bool{
+	"popular":  true,
+	"trending": true,
+	"newest":   true,
+	"upvotes":  true,
+}
+
+// trendingWindow is how far back trending counts session starts
+const trendingWindow = 7 * 24 * time.Hour
+
+// ListCoaches returns a list of coaches (public endpoint).
+// q searches the words of title, promise, tags and niche case-insensitively: coaches sharing
+// any query word are fetched through search_tokens (up to coachSearchScanLimit), then only
+// those containing every query word are kept. Firestore can't combine that lookup with the
+// tags array-contains filter, so a tag filter is applied in memory while searching.
+// sort orders by popular (all-time starts, the default), trending (sessions started in the
+// last 7 days), newest or upvotes. Ordering is done in memory so it combines with any filter
+// without a composite index per pair.
 func ListCoaches(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
@@ -29,13 +59,23 @@ func ListCoaches(fs *fsClient.Client) gin.HandlerFunc {
 
 		tag := c.Query("tag")
 		featured := c.Query("featured")
+		q := strings.TrimSpace(c.Query("q"))
+		terms := textutil.SearchTokens(q)
+		if len(terms) > maxSearchTerms {
+			terms = terms[:maxSearchTerms]
+		}
+		sortBy := c.DefaultQuery("sort", "popular")
+		if !coachSortOrders[sortBy] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of: popular, trending, newest, upvotes"})
+			return
+		}
 
-		log.Printf("ListCoaches: uid=%s, tag=%s, featured=%s", uid, tag, featured)
+		log.Printf("ListCoaches: uid=%s, tag=%s, featured=%s, q=%s", uid, tag, featured, q)
 
 		// Build query
 		query := fs.DB.Collection("coaches").Where("visibility", "==", "public")
 
-		if tag != "" {
+		if tag != "" && q == "" {
 			query = query.Where("tags", "array-contains", tag)
 		}
 
@@ -43,6 +83,15 @@ func ListCoaches(fs *fsClient.Client) gin.HandlerFunc {
 			query = query.Where("featured", "==", true)
 		}
 
+		if q != "" {
+			// A query with no searchable words (only emoji or punctuation) matches nothing
+			if len(terms) == 0 {
+				c.JSON(http.StatusOK, []models.Coach{})
+				return
+			}
+			query = query.Where("search_tokens", "array-contains-any", terms).Limit(coachSearchScanLimit)
+		}
+
 		// Execute query
 		iter := query.Documents(ctx)
 		defer iter.Stop()
@@ -64,9 +113,27 @@ func ListCoaches(fs *fsClient.Client) gin.HandlerFunc {
 				log.Printf("Error parsing coach %s: %v", doc.Ref.ID, err)
 				continue
 			}
+			if coach.Status == models.CoachStatusDeleted {
+				continue
+			}
+			if q != "" && !coachMatchesSearch(coach, tag, terms) {
+				continue
+			}
 			coaches = append(coaches, coach)
 		}
 
+		var recentStarts map[string]int
+		if sortBy == "trending" {
+			var err error
+			recentStarts, err = countSessionStartsSince(ctx, fs, time.Now().Add(-trendingWindow))
+			if err != nil {
+				log.Printf("Error counting recent starts: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list coaches"})
+				return
+			}
+		}
+		sortCoaches(coaches, sortBy, recentStarts)
+
 		log.Printf("Returning %d coaches", len(coaches))
 		if len(coaches) == 0 {
 			c.JSON(http.StatusOK, []models.Coach{})
@@ -76,6 +143,46 @@ func ListCoaches(fs *fsClient.Client) gin.HandlerFunc {
 	}
 }
 
+// sortCoaches orders coaches in place, most relevant first, breaking ties by ID.
+// recentStarts is only used for the trending order.
+func sortCoaches(coaches []models.Coach, sortBy string, recentStarts map[string]int) {
+	sort.SliceStable(coaches, func(i, j int) bool {
+		a, b := coaches[i], coaches[j]
+		switch sortBy {
+		case "trending":
+			if recentStarts[a.ID] != recentStarts[b.ID] {
+				return recentStarts[a.ID] > recentStarts[b.ID]
+			}
+		case "newest":
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+		case "upvotes":
+			if a.Stats.Upvotes != b.Stats.Upvotes {
+				return a.Stats.Upvotes > b.Stats.Upvotes
+			}
+		}
+		if a.Stats.Starts != b.Stats.Starts {
+			return a.Stats.Starts > b.Stats.Starts
+		}
+		return a.ID < b.ID
+	})
+}
+
+// coachMatchesSearch reports whether a coach found by search_tokens has every search term
+// and, when tag is set, carries that tag
+func coachMatchesSearch(coach models.Coach, tag string, terms []string) bool {
+	if tag != "" && !slices.Contains(coach.Tags, tag) {
+		return false
+	}
+	for _, term := range terms {
+		if !slices.Contains(coach.SearchTokens, term) {
+			return false
+		}
+	}
+	return true
+}
+
 // GetCoach returns a single coach by ID (public endpoint)
 func GetCoach(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -110,6 +217,11 @@ func GetCoach(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
+		// Fill starter prompts for the "try asking…" chips
+		if coach.CoachSpec != nil {
+			coach.CoachSpec.Identity.StarterPrompts = coach.CoachSpec.Identity.ResolvedStarterPrompts()
+		}
+
 		c.JSON(http.StatusOK, coach)
 	}
 }
@@ -127,10 +239,10 @@ func CreateCoach(fs *fsClient.Client) gin.HandlerFunc {
 		}
 
 		// Validate coach including CoachSpec
-		if err := validation.ValidateCoachForCreate(&req); err != nil {
-			errMsg := validation.SanitizeErrorMessage(err)
-			log.Printf("Coach validation failed: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		if errs := validation.ValidateCoachForCreateAll(&req); len(errs) > 0 {
+			errMsg := validation.SanitizeErrorMessage(errs[0])
+			log.Printf("Coach validation failed: %v", errs)
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg, "errors": errs})
 			return
 		}
 
@@ -152,6 +264,7 @@ func CreateCoach(fs *fsClient.Client) gin.HandlerFunc {
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
+		coach.SearchTokens = coach.BuildSearchTokens()
 
 		// Save to Firestore
 		_, err := fs.DB.Collection("coaches").Doc(coach.ID).Set(ctx, coach)
@@ -161,6 +274,7 @@ func CreateCoach(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "coach", audit.ActionCreate, coach.ID)
 		log.Printf("Created coach: uid=%s, coachID=%s, hasCoachSpec=%v", uid, coach.ID, coach.CoachSpec != nil)
 		c.JSON(http.StatusCreated, coach)
 	}
@@ -204,6 +318,7 @@ func ForkCoach(fs *fsClient.Client) gin.HandlerFunc {
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
 		}
+		fork.SearchTokens = fork.BuildSearchTokens()
 
 		// Save to Firestore
 		_, err = fs.DB.Collection("coaches").Doc(fork.ID).Set(ctx, fork)
@@ -213,6 +328,7 @@ func ForkCoach(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "coach", audit.ActionFork, fork.ID)
 		log.Printf("Forked coach: uid=%s, originalID=%s, forkID=%s", uid, coachID, fork.ID)
 		c.JSON(http.StatusCreated, fork)
 	}
@@ -252,10 +368,10 @@ func UpdateCoach(fs *fsClient.Client) gin.HandlerFunc {
 		}
 
 		// Validate update including CoachSpec
-		if err := validation.ValidateCoachForUpdate(&req); err != nil {
-			errMsg := validation.SanitizeErrorMessage(err)
-			log.Printf("Coach update validation failed: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		if errs := validation.ValidateCoachForUpdateAll(&req); len(errs) > 0 {
+			errMsg := validation.SanitizeErrorMessage(errs[0])
+			log.Printf("Coach update validation failed: %v", errs)
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg, "errors": errs})
 			return
 		}
 
@@ -264,22 +380,28 @@ func UpdateCoach(fs *fsClient.Client) gin.HandlerFunc {
 			{Path: "updated_at", Value: time.Now()},
 		}
 
-		// Update fields if provided
+		// Update fields if provided, tracking the result so search tokens match it
+		merged := existing
 		if req.Title != "" {
 			updates = append(updates, firestore.Update{Path: "title", Value: req.Title})
+			merged.Title = req.Title
 		}
 		if req.Promise != "" {
 			updates = append(updates, firestore.Update{Path: "promise", Value: req.Promise})
+			merged.Promise = req.Promise
 		}
 		if req.Tags != nil {
 			updates = append(updates, firestore.Update{Path: "tags", Value: req.Tags})
+			merged.Tags = req.Tags
 		}
 		if req.Blueprint != nil {
 			updates = append(updates, firestore.Update{Path: "blueprint", Value: req.Blueprint})
 		}
 		if req.CoachSpec != nil {
 			updates = append(updates, firestore.Update{Path: "coachSpec", Value: req.CoachSpec})
+			merged.CoachSpec = req.CoachSpec
 		}
+		updates = append(updates, firestore.Update{Path: "search_tokens", Value: merged.BuildSearchTokens()})
 
 		// Apply updates
 		_, err = fs.DB.Collection("coaches").Doc(coachID).Update(ctx, updates)
@@ -302,6 +424,7 @@ func UpdateCoach(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "coach", audit.ActionUpdate, coachID)
 		log.Printf("Updated coach: uid=%s, coachID=%s, hasCoachSpec=%v", uid, coachID, updated.CoachSpec != nil)
 		c.JSON(http.StatusOK, updated)
 	}
@@ -350,7 +473,270 @@ func PublishCoach(fs *fsClient.Client, cfg interface{}) gin.HandlerFunc {
 		coach.Visibility = "public"
 		coach.UpdatedAt = time.Now()
 
+		recordAudit(c, fs, "coach", audit.ActionPublish, coachID)
 		log.Printf("Published coach: uid=%s, coachID=%s", uid, coachID)
 		c.JSON(http.StatusOK, coach)
 	}
 }
+
+// MigrateCoachSpec converts an owned coach's deprecated Blueprint into a CoachSpec and saves
+// it. The Blueprint is left in place so the migration can be rolled back.
+func MigrateCoachSpec(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		coachID := c.Param("id")
+
+		doc, err := fs.DB.Collection("coaches").Doc(coachID).Get(ctx)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+			return
+		}
+
+		var coach models.Coach
+		if err := doc.DataTo(&coach); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse coach"})
+			return
+		}
+
+		if coach.OwnerUID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+		if coach.CoachSpec != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "coach already has a coachSpec"})
+			return
+		}
+		if len(coach.Blueprint) == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "coach has no blueprint to migrate"})
+			return
+		}
+
+		spec := models.BlueprintToCoachSpec(coach)
+		if errs := validation.ValidateCoachSpecAll(spec); len(errs) > 0 {
+			log.Printf("Migrated spec for coach %s failed validation: %v", coachID, errs)
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": validation.SanitizeErrorMessage(errs[0]), "errors": errs})
+			return
+		}
+
+		if _, err := doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "coachSpec", Value: spec},
+			{Path: "updated_at", Value: time.Now()},
+		}); err != nil {
+			log.Printf("Error saving migrated spec: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to migrate coach"})
+			return
+		}
+
+		recordAudit(c, fs, "coach", audit.ActionUpdate, coachID)
+		log.Printf("Migrated blueprint to coachSpec: uid=%s, coachID=%s", uid, coachID)
+		c.JSON(http.StatusOK, gin.H{"coachSpec": spec})
+	}
+}
+
+// DeleteCoach deletes a coach the user owns. A coach that is public or still referenced by
+// sessions is soft-deleted (hidden from the gallery, kept for history); otherwise the
+// document is removed.
+func DeleteCoach(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		coachID := c.Param("id")
+
+		doc, err := fs.DB.Collection("coaches").Doc(coachID).Get(ctx)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+			return
+		}
+
+		var coach models.Coach
+		if err := doc.DataTo(&coach); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse coach"})
+			return
+		}
+
+		if coach.OwnerUID == models.SystemOwnerUID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "built-in coaches can't be deleted"})
+			return
+		}
+		if coach.OwnerUID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+		if coach.Status == models.CoachStatusDeleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+			return
+		}
+
+		referenced := coach.Visibility == "public"
+		if !referenced {
+			sessions, err := fs.DB.Collection("sessions").
+				Where("coach_id", "==", coachID).
+				Limit(1).
+				Documents(ctx).GetAll()
+			if err != nil {
+				log.Printf("Error checking sessions for coach %s: %v", coachID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete coach"})
+				return
+			}
+			referenced = len(sessions) > 0
+		}
+
+		if referenced {
+			_, err = doc.Ref.Update(ctx, []firestore.Update{
+				{Path: "status", Value: models.CoachStatusDeleted},
+				{Path: "updated_at", Value: time.Now()},
+			})
+		} else {
+			_, err = doc.Ref.Delete(ctx)
+		}
+		if err != nil {
+			log.Printf("Error deleting coach: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete coach"})
+			return
+		}
+
+		recordAudit(c, fs, "coach", audit.ActionDelete, coachID)
+		log.Printf("Deleted coach: uid=%s, coachID=%s, soft=%v", uid, coachID, referenced)
+		c.JSON(http.StatusOK, gin.H{"message": "coach deleted"})
+	}
+}
+
+// maxMergeCoaches bounds how many coaches can be merged in one request (Firestore "in" limit)
+const maxMergeCoaches = 30
+
+// mergeBatchSize keeps each write batch under Firestore's 500-write limit
+const mergeBatchSize = 400
+
+// MergeCoaches merges duplicate coaches owned by the user into a target coach.
+// Sessions pointing at the merged coaches are repointed to the target and the
+// merged coaches are soft-deleted. Re-running a partially applied merge is safe.
+func MergeCoaches(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+
+		var req struct {
+			TargetID string   `json:"target_id"`
+			CoachIDs []string `json:"coach_ids"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		mergeIDs := mergeSourceIDs(req.TargetID, req.CoachIDs)
+		if req.TargetID == "" || len(mergeIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target_id and at least one other coach id are required"})
+			return
+		}
+		if len(mergeIDs) > maxMergeCoaches {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many coaches (max %d)", maxMergeCoaches)})
+			return
+		}
+
+		// Verify ownership of the target and every coach being merged
+		refs := []*firestore.DocumentRef{fs.DB.Collection("coaches").Doc(req.TargetID)}
+		for _, id := range mergeIDs {
+			refs = append(refs, fs.DB.Collection("coaches").Doc(id))
+		}
+
+		docs, err := fs.DB.GetAll(ctx, refs)
+		if err != nil {
+			log.Printf("Error loading coaches for merge: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load coaches"})
+			return
+		}
+
+		for i, doc := range docs {
+			if !doc.Exists() {
+				c.JSON(http.StatusNotFound, gin.H{"error": "coach not found", "coach_id": refs[i].ID})
+				return
+			}
+
+			var coach models.Coach
+			if err := doc.DataTo(&coach); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse coach"})
+				return
+			}
+			if coach.OwnerUID != uid {
+				c.JSON(http.StatusForbidden, gin.H{"error": "access denied", "coach_id": refs[i].ID})
+				return
+			}
+			if i == 0 && coach.Status == models.CoachStatusDeleted {
+				c.JSON(http.StatusConflict, gin.H{"error": "target coach has been deleted"})
+				return
+			}
+		}
+
+		// Find the user's sessions that reference a merged coach
+		sessionDocs, err := fs.DB.Collection("sessions").
+			Where("uid", "==", uid).
+			Where("coach_id", "in", mergeIDs).
+			Documents(ctx).GetAll()
+		if err != nil {
+			log.Printf("Error finding sessions to repoint: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find sessions"})
+			return
+		}
+
+		// Repoint sessions first; coaches are deleted last so a failed merge can be retried
+		now := time.Now()
+		writes := make([]func(*firestore.WriteBatch), 0, len(sessionDocs)+len(mergeIDs))
+		for _, doc := range sessionDocs {
+			ref := doc.Ref
+			writes = append(writes, func(b *firestore.WriteBatch) {
+				b.Update(ref, []firestore.Update{{Path: "coach_id", Value: req.TargetID}})
+			})
+		}
+		for _, ref := range refs[1:] {
+			ref := ref
+			writes = append(writes, func(b *firestore.WriteBatch) {
+				b.Update(ref, []firestore.Update{
+					{Path: "status", Value: models.CoachStatusDeleted},
+					{Path: "merged_into", Value: req.TargetID},
+					{Path: "updated_at", Value: now},
+				})
+			})
+		}
+
+		for start := 0; start < len(writes); start += mergeBatchSize {
+			end := start + mergeBatchSize
+			if end > len(writes) {
+				end = len(writes)
+			}
+
+			batch := fs.DB.Batch()
+			for _, write := range writes[start:end] {
+				write(batch)
+			}
+			if _, err := batch.Commit(ctx); err != nil {
+				log.Printf("Error committing coach merge: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge coaches"})
+				return
+			}
+		}
+
+		recordAudit(c, fs, "coach", audit.ActionMerge, req.TargetID)
+		log.Printf("Merged coaches: uid=%s, target=%s, merged=%v, sessions=%d", uid, req.TargetID, mergeIDs, len(sessionDocs))
+		c.JSON(http.StatusOK, gin.H{
+			"target_id":          req.TargetID,
+			"merged_coach_ids":   mergeIDs,
+			"sessions_repointed": len(sessionDocs),
+		})
+	}
+}
+
+// mergeSourceIDs returns the distinct coach ids to merge, excluding the target
+func mergeSourceIDs(targetID string, coachIDs []string) []string {
+	seen := map[string]bool{targetID: true}
+	ids := []string{}
+	for _, id := range coachIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
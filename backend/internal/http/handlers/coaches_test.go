@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestMergeCoaches(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	seed := func(collection, id string, data interface{}) {
+		t.Helper()
+		if _, err := fs.DB.Collection(collection).Doc(id).Set(ctx, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{"focus", "focus-copy", "focus-copy-2"} {
+		seed("coaches", id, models.Coach{ID: id, OwnerUID: "u1", Visibility: "private", Title: "Focus"})
+	}
+	seed("coaches", "theirs", models.Coach{ID: "theirs", OwnerUID: "u2", Visibility: "private", Title: "Focus"})
+	seed("sessions", "s1", map[string]interface{}{"id": "s1", "uid": "u1", "coach_id": "focus-copy"})
+	seed("sessions", "s2", map[string]interface{}{"id": "s2", "uid": "u1", "coach_id": "focus-copy-2"})
+	seed("sessions", "s3", map[string]interface{}{"id": "s3", "uid": "u1", "coach_id": "focus"})
+	seed("sessions", "s4", map[string]interface{}{"id": "s4", "uid": "u2", "coach_id": "theirs"})
+
+	merge := func(body string) int {
+		t.Helper()
+		return serveAs("u1", MergeCoaches(fs), http.MethodPost, "/v1/coaches/merge", []byte(body)).Code
+	}
+	coachIDOf := func(sessionID string) string {
+		t.Helper()
+		doc, err := fs.DB.Collection("sessions").Doc(sessionID).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc.Data()["coach_id"].(string)
+	}
+	coach := func(id string) models.Coach {
+		t.Helper()
+		doc, err := fs.DB.Collection("coaches").Doc(id).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var coach models.Coach
+		if err := doc.DataTo(&coach); err != nil {
+			t.Fatal(err)
+		}
+		return coach
+	}
+
+	// Merging someone else's coach is refused before anything is written
+	if code := merge(`{"target_id":"focus","coach_ids":["focus-copy","theirs"]}`); code != http.StatusForbidden {
+		t.Errorf("merging another user's coach: status = %d, want 403", code)
+	}
+	if got := coachIDOf("s1"); got != "focus-copy" {
+		t.Errorf("refused merge repointed s1 to %s", got)
+	}
+	if code := merge(`{"target_id":"focus","coach_ids":["focus"]}`); code != http.StatusBadRequest {
+		t.Errorf("nothing to merge: status = %d, want 400", code)
+	}
+
+	w := serveAs("u1", MergeCoaches(fs), http.MethodPost, "/v1/coaches/merge",
+		[]byte(`{"target_id":"focus","coach_ids":["focus","focus-copy","focus-copy-2","focus-copy"]}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Merged   []string `json:"merged_coach_ids"`
+		Sessions int      `json:"sessions_repointed"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Merged) != 2 || resp.Sessions != 2 {
+		t.Errorf("response = %s, want two coaches merged and two sessions repointed", w.Body)
+	}
+
+	for _, id := range []string{"s1", "s2", "s3"} {
+		if got := coachIDOf(id); got != "focus" {
+			t.Errorf("session %s coach = %s, want the target", id, got)
+		}
+	}
+	if got := coachIDOf("s4"); got != "theirs" {
+		t.Errorf("another user's session was repointed to %s", got)
+	}
+	for _, id := range []string{"focus-copy", "focus-copy-2"} {
+		if merged := coach(id); merged.Status != models.CoachStatusDeleted || merged.MergedInto != "focus" {
+			t.Errorf("merged coach %s = status %q merged_into %q, want deleted into the target", id, merged.Status, merged.MergedInto)
+		}
+	}
+	if target := coach("focus"); target.Status != "" {
+		t.Errorf("target status = %q, want it kept active", target.Status)
+	}
+
+	// Merging into a coach that was itself merged away is refused
+	if code := merge(`{"target_id":"focus-copy","coach_ids":["focus"]}`); code != http.StatusConflict {
+		t.Errorf("merging into a deleted coach: status = %d, want 409", code)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/models"
+	"simon-backend/internal/validation"
+)
+
+// maxCoachSpecBodyBytes bounds the body accepted by ValidateCoachSpec
+const maxCoachSpecBodyBytes = 256 << 10
+
+// ValidateCoachSpec validates a CoachSpec for the coach builder.
+// With ?section=style the body is just that section and is validated on its own;
+// without a section the body is a full CoachSpec. Violations are returned with 200.
+func ValidateCoachSpec() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxCoachSpecBodyBytes))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		var errs []validation.FieldError
+		if section := c.Query("section"); section != "" {
+			errs, err = validation.ValidateCoachSpecSection(section, body)
+			if errors.Is(err, validation.ErrUnknownSection) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid section JSON"})
+				return
+			}
+		} else {
+			var spec models.CoachSpec
+			if err := json.Unmarshal(body, &spec); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid coachSpec JSON"})
+				return
+			}
+			errs = validation.ValidateCoachSpecAll(&spec)
+		}
+
+		if errs == nil {
+			errs = []validation.FieldError{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"valid":  len(errs) == 0,
+			"errors": errs,
+		})
+	}
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"simon-backend/internal/audit"
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
@@ -48,6 +50,7 @@ func UpdateContext(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "context", audit.ActionUpdate, uid)
 		c.JSON(http.StatusOK, contextVault)
 	}
 }
@@ -75,6 +78,56 @@ func UpdateContextPreference(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "preferences", audit.ActionUpdate, uid)
 		c.JSON(http.StatusOK, gin.H{"include_context": req.IncludeContext})
 	}
 }
+
+type updateQuietHoursRequest struct {
+	Timezone   string             `json:"timezone"`
+	QuietHours *models.QuietHours `json:"quiet_hours"` // null clears quiet hours
+}
+
+// UpdateQuietHours handles PUT /v1/context/quiet-hours
+// Sets the user's timezone and notification quiet hours
+func UpdateQuietHours(fs *firestore.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := middleware.GetUID(c)
+		ctx := c.Request.Context()
+
+		var req updateQuietHoursRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+
+		if req.Timezone != "" {
+			if _, err := time.LoadLocation(req.Timezone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timezone"})
+				return
+			}
+		}
+
+		if req.QuietHours != nil {
+			if err := req.QuietHours.Validate(); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		preferences := map[string]interface{}{
+			"quiet_hours": req.QuietHours,
+		}
+		if req.Timezone != "" {
+			preferences["timezone"] = req.Timezone
+		}
+
+		if err := fs.UpdateUser(ctx, uid, map[string]interface{}{"preferences": preferences}); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update quiet hours"})
+			return
+		}
+
+		recordAudit(c, fs, "preferences", audit.ActionUpdate, uid)
+		c.JSON(http.StatusOK, req)
+	}
+}
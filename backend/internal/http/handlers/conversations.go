@@ -1,57 +1,134 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"google.golang.org/api/iterator"
 
+	"simon-backend/internal/audit"
+	"simon-backend/internal/config"
 	fsClient "simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
 )
 
-// ListSessions returns a list of user's sessions
+const (
+	// sessionsPageSize is the default number of sessions returned by ListSessions
+	sessionsPageSize = 20
+	// maxSessionsPageSize caps the limit a client may request
+	maxSessionsPageSize = 100
+	// messagesPageSize is the default number of messages in a GetSession page
+	messagesPageSize = 50
+	// maxMessagesPageSize caps the message limit a client may request
+	maxMessagesPageSize = 200
+)
+
+// pageLimit reads the ?limit query param, defaulting and capping it; ok is false when it isn't a positive integer
+func pageLimit(c *gin.Context, defaultLimit, maxLimit int) (limit int, ok bool) {
+	l := c.Query("limit")
+	if l == "" {
+		return defaultLimit, true
+	}
+	parsed, err := strconv.Atoi(l)
+	if err != nil || parsed < 1 {
+		return 0, false
+	}
+	return min(parsed, maxLimit), true
+}
+
+// ListSessions returns a page of the user's sessions, most recently updated first.
+// Pass ?start_after=<next_cursor> to fetch the following page and ?limit to size it.
+// Archived sessions are excluded unless ?include_archived=true.
 func ListSessions(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
 		uid := middleware.GetUID(c)
+		includeArchived := c.Query("include_archived") == "true"
 
-		log.Printf("ListSessions: uid=%s", uid)
+		limit, ok := pageLimit(c, sessionsPageSize, maxSessionsPageSize)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+
+		// The cursor is the ID of the last session on the previous page; starting after its
+		// snapshot keeps sessions with equal updated_at in a stable order
+		var lastDoc *firestore.DocumentSnapshot
+		if cursor := c.Query("start_after"); cursor != "" {
+			doc, err := fs.DB.Collection("sessions").Doc(cursor).Get(ctx)
+			if err != nil && !fsClient.IsNotFound(err) {
+				log.Printf("Error loading session cursor %s: %v", cursor, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+				return
+			}
+			if err != nil || doc.Data()["uid"] != uid {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+				return
+			}
+			lastDoc = doc
+		}
 
-		// Query sessions
-		iter := fs.DB.Collection("sessions").
-			Where("uid", "==", uid).
-			OrderBy("updated_at", firestore.Desc).
-			Limit(20).
-			Documents(ctx)
-		defer iter.Stop()
+		log.Printf("ListSessions: uid=%s, limit=%d, includeArchived=%v", uid, limit, includeArchived)
 
-		var sessions []models.Session
-		for {
-			doc, err := iter.Next()
-			if err == iterator.Done {
-				break
+		// Archived is absent on older sessions, so it's filtered in memory; keep paging
+		// until a full page of visible sessions has been collected
+		sessions := []models.Session{}
+		var lastReturned *firestore.DocumentSnapshot
+		exhausted := false
+		for len(sessions) < limit && !exhausted {
+			query := fs.DB.Collection("sessions").
+				Where("uid", "==", uid).
+				OrderBy("updated_at", firestore.Desc).
+				Limit(limit)
+			if lastDoc != nil {
+				query = query.StartAfter(lastDoc)
 			}
+
+			docs, err := query.Documents(ctx).GetAll()
 			if err != nil {
 				log.Printf("Error iterating sessions: %v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
 				return
 			}
 
-			var session models.Session
-			if err := doc.DataTo(&session); err != nil {
-				log.Printf("Error parsing session: %v", err)
-				continue
+			for _, doc := range docs {
+				if len(sessions) == limit {
+					break
+				}
+				var session models.Session
+				if err := doc.DataTo(&session); err != nil {
+					log.Printf("Error parsing session: %v", err)
+					continue
+				}
+				if session.Archived && !includeArchived {
+					continue
+				}
+				sessions = append(sessions, session)
+				lastReturned = doc
+			}
+
+			exhausted = len(docs) < limit
+			if len(docs) > 0 {
+				lastDoc = docs[len(docs)-1]
 			}
-			sessions = append(sessions, session)
 		}
 
-		c.JSON(http.StatusOK, sessions)
+		// A full page may be followed by more sessions; the client stops once next_cursor is null
+		var nextCursor *string
+		if len(sessions) == limit && lastReturned != nil {
+			nextCursor = &lastReturned.Ref.ID
+		}
+
+		c.JSON(http.StatusOK, gin.H{"sessions": sessions, "next_cursor": nextCursor})
 	}
 }
 
@@ -67,11 +144,26 @@ func CreateSession(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
+		if req.Mode != "" && !models.ValidSessionMode(req.Mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be one of: quick, system, deep"})
+			return
+		}
+		mode := req.Mode
+		if mode == "" {
+			mode = models.SessionModeQuick
+		}
+		var snapshot *models.CoachSpec
+
 		// Validate coach exists
 		if req.CoachID != "" {
 			doc, err := fs.DB.Collection("coaches").Doc(req.CoachID).Get(ctx)
 			if err != nil {
-				c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+				if fsClient.IsNotFound(err) {
+					c.JSON(http.StatusNotFound, gin.H{"error": "coach not found"})
+					return
+				}
+				log.Printf("Error getting coach: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get coach"})
 				return
 			}
 
@@ -86,6 +178,20 @@ func CreateSession(fs *fsClient.Client) gin.HandlerFunc {
 				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
 				return
 			}
+
+			// Draft and deleted coaches exist but can't be started
+			if !coach.IsStartable() {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":   "coach_not_startable",
+					"message": "This coach isn't available to start a session",
+				})
+				return
+			}
+
+			if req.Mode == "" {
+				mode = coach.DefaultSessionMode()
+			}
+			snapshot = coach.EffectiveSpec()
 		}
 
 		// Create session
@@ -95,13 +201,15 @@ func CreateSession(fs *fsClient.Client) gin.HandlerFunc {
 		}
 
 		session := models.Session{
-			ID:        uuid.New().String(),
-			UID:       uid,
-			CoachID:   coachIDPtr,
-			Title:     "New Session",
-			Mode:      "quick",
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
+			ID:                uuid.New().String(),
+			UID:               uid,
+			CoachID:           coachIDPtr,
+			Title:             models.DefaultSessionTitle,
+			Mode:              mode,
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+			IncludeContext:    req.IncludeContext,
+			CoachSpecSnapshot: snapshot,
 		}
 
 		// Save to Firestore
@@ -112,12 +220,20 @@ func CreateSession(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
+		if req.CoachID != "" {
+			if err := fs.IncrementCoachStarts(ctx, req.CoachID); err != nil {
+				log.Printf("Error incrementing starts for coach %s: %v", req.CoachID, err)
+			}
+		}
+
+		recordAudit(c, fs, "session", audit.ActionCreate, session.ID)
 		log.Printf("Created session: uid=%s, sessionID=%s, coachID=%s", uid, session.ID, req.CoachID)
 		c.JSON(http.StatusCreated, session)
 	}
 }
 
-// GetSession returns a single session by ID
+// GetSession returns a single session by ID with its most recent messages.
+// Pass ?before=<next_cursor> to load older messages and ?limit to size the page.
 func GetSession(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx := c.Request.Context()
@@ -145,26 +261,53 @@ func GetSession(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
-		// Get messages
-		messagesIter := fs.DB.Collection("sessions").Doc(sessionID).
-			Collection("messages").
-			OrderBy("created_at", firestore.Asc).
-			Documents(ctx)
-		defer messagesIter.Stop()
+		limit, ok := pageLimit(c, messagesPageSize, maxMessagesPageSize)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
 
-		var messages []models.Message
-		for {
-			msgDoc, err := messagesIter.Next()
-			if err == iterator.Done {
-				break
-			}
+		// Page messages newest-first; ?before is the ID of the oldest message already shown. Without
+		// ?limit or ?before the whole transcript is returned, as clients that don't page expect.
+		paged := c.Query("limit") != "" || c.Query("before") != ""
+		messagesRef := fs.DB.Collection("sessions").Doc(sessionID).Collection("messages")
+		query := messagesRef.OrderBy("created_at", firestore.Desc)
+		if paged {
+			query = query.Limit(limit + 1)
+		}
+		if before := c.Query("before"); before != "" {
+			beforeDoc, err := messagesRef.Doc(before).Get(ctx)
 			if err != nil {
-				log.Printf("Error iterating messages: %v", err)
-				break
+				if !fsClient.IsNotFound(err) {
+					log.Printf("Error loading message cursor %s: %v", before, err)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+					return
+				}
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+				return
 			}
+			query = query.StartAfter(beforeDoc)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			log.Printf("Error iterating messages: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load messages"})
+			return
+		}
+
+		// The extra document only tells whether older messages remain
+		var nextCursor *string
+		if paged && len(docs) > limit {
+			docs = docs[:limit]
+			nextCursor = &docs[limit-1].Ref.ID
+		}
 
+		// Return the page oldest-first for display
+		messages := make([]models.Message, 0, len(docs))
+		for i := len(docs) - 1; i >= 0; i-- {
 			var msg models.Message
-			if err := msgDoc.DataTo(&msg); err != nil {
+			if err := docs[i].DataTo(&msg); err != nil {
 				log.Printf("Error parsing message: %v", err)
 				continue
 			}
@@ -172,8 +315,275 @@ func GetSession(fs *fsClient.Client) gin.HandlerFunc {
 		}
 
 		c.JSON(http.StatusOK, gin.H{
-			"session":  session,
-			"messages": messages,
+			"session":     session,
+			"messages":    messages,
+			"next_cursor": nextCursor,
+		})
+	}
+}
+
+// ArchiveSession archives (or, with {"archived": false}, unarchives) a session
+func ArchiveSession(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		sessionID := c.Param("id")
+
+		req := struct {
+			Archived *bool `json:"archived"`
+		}{}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+				return
+			}
+		}
+		archived := req.Archived == nil || *req.Archived
+
+		sessionRef := fs.DB.Collection("sessions").Doc(sessionID)
+		doc, err := sessionRef.Get(ctx)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+				return
+			}
+			log.Printf("Error getting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get session"})
+			return
+		}
+
+		var session models.Session
+		if err := doc.DataTo(&session); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse session"})
+			return
+		}
+
+		if session.UID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+
+		updates := sessionArchiveUpdates(archived, time.Now())
+		if _, err := sessionRef.Update(ctx, updates); err != nil {
+			log.Printf("Error archiving session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to archive session"})
+			return
+		}
+
+		session.Archived = archived
+		session.ArchivedAt = nil
+		if archived {
+			now := time.Now()
+			session.ArchivedAt = &now
+		}
+
+		recordAudit(c, fs, "session", audit.ActionArchive, sessionID)
+		log.Printf("Archived session: uid=%s, sessionID=%s, archived=%v", uid, sessionID, archived)
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// UpdateSession renames a session
+func UpdateSession(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		sessionID := c.Param("id")
+
+		var req struct {
+			Title string `json:"title"`
+		}
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+			return
+		}
+		title := strings.TrimSpace(req.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+		if utf8.RuneCountInString(title) > models.MaxSessionTitleRunes {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("title must be at most %d characters", models.MaxSessionTitleRunes)})
+			return
+		}
+
+		sessionRef := fs.DB.Collection("sessions").Doc(sessionID)
+		doc, err := sessionRef.Get(ctx)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+				return
+			}
+			log.Printf("Error getting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get session"})
+			return
+		}
+
+		var session models.Session
+		if err := doc.DataTo(&session); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse session"})
+			return
+		}
+
+		if session.UID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+
+		now := time.Now()
+		if _, err := sessionRef.Update(ctx, []firestore.Update{
+			{Path: "title", Value: title},
+			{Path: "updated_at", Value: now},
+		}); err != nil {
+			log.Printf("Error renaming session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update session"})
+			return
+		}
+
+		session.Title = title
+		session.UpdatedAt = now
+
+		recordAudit(c, fs, "session", audit.ActionUpdate, sessionID)
+		c.JSON(http.StatusOK, session)
+	}
+}
+
+// DeleteSession permanently deletes a session and its messages
+func DeleteSession(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		sessionID := c.Param("id")
+
+		session, err := fs.GetSession(ctx, sessionID)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+				return
+			}
+			log.Printf("Error getting session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get session"})
+			return
+		}
+
+		if session.UID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+
+		if err := fs.DeleteSession(ctx, sessionID); err != nil {
+			log.Printf("Error deleting session %s: %v", sessionID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete session"})
+			return
+		}
+
+		recordAudit(c, fs, "session", audit.ActionDelete, sessionID)
+		log.Printf("Deleted session: uid=%s, sessionID=%s", uid, sessionID)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// sessionArchiveUpdates builds the updates that set or clear a session's archived flag.
+// updated_at is left alone so archiving doesn't reorder the session list.
+func sessionArchiveUpdates(archived bool, now time.Time) []firestore.Update {
+	if !archived {
+		return []firestore.Update{
+			{Path: "archived", Value: false},
+			{Path: "archived_at", Value: firestore.Delete},
+		}
+	}
+	return []firestore.Update{
+		{Path: "archived", Value: true},
+		{Path: "archived_at", Value: now},
+	}
+}
+
+// AutoArchiveSessions handles POST /internal/sessions/auto-archive.
+// It archives sessions that haven't been updated in cfg.SessionAutoArchiveDays days.
+func AutoArchiveSessions(fs *fsClient.Client, cfg config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		if cfg.SessionAutoArchiveDays <= 0 {
+			c.JSON(http.StatusOK, gin.H{"scanned": 0, "archived": 0, "disabled": true})
+			return
+		}
+
+		now := time.Now()
+		cutoff := sessionArchiveCutoff(now, cfg.SessionAutoArchiveDays)
+
+		scanned, archived, err := archiveStaleSessions(ctx, fs, cutoff, now)
+		if err != nil {
+			log.Printf("Session auto-archive failed after %d sessions: %v", scanned, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to archive sessions"})
+			return
+		}
+
+		log.Printf("Session auto-archive: cutoff=%s scanned=%d archived=%d", cutoff.Format(time.RFC3339), scanned, archived)
+		c.JSON(http.StatusOK, gin.H{
+			"scanned":  scanned,
+			"archived": archived,
 		})
 	}
 }
+
+// sessionArchiveCutoff returns the time before which untouched sessions are archived
+func sessionArchiveCutoff(now time.Time, days int) time.Time {
+	return now.AddDate(0, 0, -days)
+}
+
+// archiveStaleSessions archives every unarchived session last updated before cutoff. Sessions
+// created before archived was always stored lack the field, so archived sessions are skipped
+// after reading rather than filtered out by the query.
+func archiveStaleSessions(ctx context.Context, fs *fsClient.Client, cutoff, now time.Time) (int, int, error) {
+	scanned, archived := 0, 0
+	var lastDoc *firestore.DocumentSnapshot
+
+	for {
+		query := fs.DB.Collection("sessions").
+			Where("updated_at", "<", cutoff).
+			OrderBy("updated_at", firestore.Asc).
+			Limit(reconcilePageSize)
+		if lastDoc != nil {
+			query = query.StartAfter(lastDoc)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return scanned, archived, err
+		}
+		if len(docs) == 0 {
+			return scanned, archived, nil
+		}
+
+		batch := fs.DB.Batch()
+		pending := 0
+		for _, doc := range docs {
+			scanned++
+
+			var session models.Session
+			if err := doc.DataTo(&session); err != nil {
+				log.Printf("Error parsing session %s: %v", doc.Ref.ID, err)
+				continue
+			}
+			if session.Archived {
+				continue
+			}
+
+			batch.Update(doc.Ref, sessionArchiveUpdates(true, now))
+			pending++
+		}
+
+		if pending > 0 {
+			if _, err := batch.Commit(ctx); err != nil {
+				return scanned, archived, err
+			}
+			archived += pending
+		}
+
+		if len(docs) < reconcilePageSize {
+			return scanned, archived, nil
+		}
+		lastDoc = docs[len(docs)-1]
+	}
+}
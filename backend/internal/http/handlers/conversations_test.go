@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
+)
+
+func TestCreateSessionCoachStates(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coaches := []models.Coach{
+		{ID: "active", Visibility: "public", Title: "Active"},
+		{ID: "draft", Visibility: "public", Title: "Draft", Status: models.CoachStatusDraft},
+		{ID: "deleted", Visibility: "public", Title: "Deleted", Status: models.CoachStatusDeleted},
+		{ID: "own_draft", Visibility: "private", OwnerUID: "u1", Title: "My draft", Status: models.CoachStatusDraft},
+		{ID: "private", Visibility: "private", OwnerUID: "u2", Title: "Theirs"},
+	}
+	for _, coach := range coaches {
+		if _, err := fs.DB.Collection("coaches").Doc(coach.ID).Set(ctx, coach); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		coachID  string
+		wantCode int
+		wantErr  string
+	}{
+		{"active", http.StatusCreated, ""},
+		{"draft", http.StatusConflict, "coach_not_startable"},
+		{"deleted", http.StatusConflict, "coach_not_startable"},
+		// Owning a draft doesn't make it startable
+		{"own_draft", http.StatusConflict, "coach_not_startable"},
+		{"missing", http.StatusNotFound, "coach not found"},
+		{"private", http.StatusForbidden, "access denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.coachID, func(t *testing.T) {
+			w := serveAs("u1", CreateSession(fs), http.MethodPost, "/v1/sessions", []byte(`{"coach_id":"`+tt.coachID+`"}`))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantErr == "" {
+				return
+			}
+			var body map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tt.wantErr {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantErr)
+			}
+		})
+	}
+
+	docs, err := fs.DB.Collection("sessions").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Errorf("created %d sessions, want only the active coach's", len(docs))
+	}
+}
+
+func TestStoredAttachmentShapes(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	messages := fs.DB.Collection("sessions").Doc("s1").Collection("messages")
+
+	// A message written before attachments grew mime type and transcript fields
+	if _, err := messages.Doc("old").Set(ctx, map[string]interface{}{
+		"id":           "old",
+		"role":         "user",
+		"content_text": "",
+		"attachments": []interface{}{map[string]interface{}{
+			"type":         "image",
+			"storage_path": "u1/a.jpg",
+			"download_url": "https://cdn/a.jpg",
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	audio := models.Attachment{
+		Type:            models.AttachmentTypeAudio,
+		StoragePath:     "u1/note.m4a",
+		DownloadURL:     "https://cdn/note.m4a",
+		MimeType:        "audio/m4a",
+		Transcript:      "Remind me to stretch after lunch",
+		DurationSeconds: 3.5,
+	}
+	if _, err := messages.Doc("new").Set(ctx, models.Message{ID: "new", Role: "user", Attachments: []models.Attachment{audio}}); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(id string) models.Attachment {
+		t.Helper()
+		doc, err := messages.Doc(id).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var msg models.Message
+		if err := doc.DataTo(&msg); err != nil {
+			t.Fatalf("decoding %s message: %v", id, err)
+		}
+		if len(msg.Attachments) != 1 {
+			t.Fatalf("%s message has %d attachments, want 1", id, len(msg.Attachments))
+		}
+		return msg.Attachments[0]
+	}
+	if got := read("old"); got != (models.Attachment{Type: "image", StoragePath: "u1/a.jpg", DownloadURL: "https://cdn/a.jpg"}) {
+		t.Errorf("old-shape attachment = %+v", got)
+	}
+	if got := read("new"); got != audio {
+		t.Errorf("new-shape attachment = %+v, want %+v", got, audio)
+	}
+}
+
+func TestCreateSessionIncludeContextOverride(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+
+	create := func(body string) *bool {
+		t.Helper()
+		w := serveAs("u1", CreateSession(fs), http.MethodPost, "/v1/sessions", []byte(body))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+		var created models.Session
+		if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+			t.Fatal(err)
+		}
+		doc, err := fs.DB.Collection("sessions").Doc(created.ID).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var stored models.Session
+		if err := doc.DataTo(&stored); err != nil {
+			t.Fatal(err)
+		}
+		return stored.IncludeContext
+	}
+
+	if got := create(`{"include_context":false}`); got == nil || *got {
+		t.Errorf("include_context = %v, want the off override persisted", got)
+	}
+	if got := create(`{}`); got != nil {
+		t.Errorf("include_context = %v, want unset so the user's preference applies", *got)
+	}
+}
+
+func TestCreateSessionDefaultMode(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coaches := []models.Coach{
+		{ID: "decision-matrix", Visibility: "public", Title: "Decision Matrix", CoachSpec: &models.CoachSpec{DefaultMode: models.SessionModeDeep}},
+		{ID: "focus", Visibility: "public", Title: "Focus"},
+	}
+	for _, coach := range coaches {
+		if _, err := fs.DB.Collection("coaches").Doc(coach.ID).Set(ctx, coach); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"deep-default coach", `{"coach_id":"decision-matrix"}`, models.SessionModeDeep},
+		{"client choice wins", `{"coach_id":"decision-matrix","mode":"quick"}`, models.SessionModeQuick},
+		{"coach without a default", `{"coach_id":"focus"}`, models.SessionModeQuick},
+		{"no coach", `{}`, models.SessionModeQuick},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs("u1", CreateSession(fs), http.MethodPost, "/v1/sessions", []byte(tt.body))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			var session models.Session
+			if err := json.Unmarshal(w.Body.Bytes(), &session); err != nil {
+				t.Fatal(err)
+			}
+			if session.Mode != tt.want {
+				t.Errorf("mode = %q, want %q", session.Mode, tt.want)
+			}
+		})
+	}
+
+	if w := serveAs("u1", CreateSession(fs), http.MethodPost, "/v1/sessions", []byte(`{"mode":"marathon"}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown mode: status = %d, want 400", w.Code)
+	}
+}
+
+func TestGetSessionMessagePages(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	batch := fs.DB.Batch()
+	for i := 1; i <= maxMessagesPageSize+1; i++ {
+		id := fmt.Sprintf("m%03d", i)
+		batch.Set(fs.DB.Collection("sessions").Doc("s1").Collection("messages").Doc(id), models.Message{
+			ID: id, Role: "user", ContentText: id, CreatedAt: start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	if _, err := batch.Commit(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(uid, query string) (int, []string, interface{}) {
+		t.Helper()
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), uid) })
+		r.GET("/v1/sessions/:id", GetSession(fs))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/sessions/s1"+query, nil))
+		if w.Code != http.StatusOK {
+			return w.Code, nil, nil
+		}
+		var resp struct {
+			Messages   []models.Message `json:"messages"`
+			NextCursor interface{}      `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(resp.Messages))
+		for i, msg := range resp.Messages {
+			ids[i] = msg.ID
+		}
+		return w.Code, ids, resp.NextCursor
+	}
+
+	// Without paging params the whole transcript comes back, oldest first
+	if code, ids, cursor := get("u1", ""); code != http.StatusOK || len(ids) != maxMessagesPageSize+1 || ids[0] != "m001" || cursor != nil {
+		t.Errorf("full transcript: status %d, %d messages starting %v, cursor %v", code, len(ids), ids[:min(len(ids), 1)], cursor)
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantFirst  string
+		wantLen    int
+		wantCursor interface{}
+	}{
+		{"newest page", "?limit=2", "m200", 2, "m200"},
+		{"before cursor", "?limit=2&before=m200", "m198", 2, "m198"},
+		{"before with the default limit", "?before=m052", "m002", messagesPageSize, "m002"},
+		{"last page", "?before=m003", "m001", 2, nil},
+		{"limit is capped", "?limit=1000", "m002", maxMessagesPageSize, "m002"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, ids, cursor := get("u1", tt.query)
+			if code != http.StatusOK {
+				t.Fatalf("status = %d", code)
+			}
+			if len(ids) != tt.wantLen || ids[0] != tt.wantFirst || cursor != tt.wantCursor {
+				t.Errorf("got %d messages from %s with cursor %v, want %d from %s with cursor %v",
+					len(ids), ids[0], cursor, tt.wantLen, tt.wantFirst, tt.wantCursor)
+			}
+		})
+	}
+
+	for query, want := range map[string]int{"?limit=0": http.StatusBadRequest, "?before=nope": http.StatusBadRequest} {
+		if code, _, _ := get("u1", query); code != want {
+			t.Errorf("%s: status = %d, want %d", query, code, want)
+		}
+	}
+	if code, _, _ := get("u2", ""); code != http.StatusForbidden {
+		t.Errorf("another user's session: status = %d, want 403", code)
+	}
+}
+
+func TestArchiveSession(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1", Title: "Morning plan"}); err != nil {
+		t.Fatal(err)
+	}
+	archive := func(uid, sessionID, body string) int {
+		t.Helper()
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), uid) })
+		r.PUT("/v1/sessions/:id/archive", ArchiveSession(fs))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/v1/sessions/"+sessionID+"/archive", strings.NewReader(body)))
+		return w.Code
+	}
+	stored := func() models.Session {
+		t.Helper()
+		doc, err := fs.DB.Collection("sessions").Doc("s1").Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var session models.Session
+		if err := doc.DataTo(&session); err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+
+	if code := archive("u1", "missing", ""); code != http.StatusNotFound {
+		t.Errorf("missing session: status = %d, want 404", code)
+	}
+	if code := archive("u2", "s1", ""); code != http.StatusForbidden {
+		t.Errorf("another user's session: status = %d, want 403", code)
+	}
+	if stored().Archived {
+		t.Fatal("another user archived the session")
+	}
+
+	// An empty body archives
+	if code := archive("u1", "s1", ""); code != http.StatusOK {
+		t.Fatalf("archive: status = %d, want 200", code)
+	}
+	if session := stored(); !session.Archived || session.ArchivedAt == nil {
+		t.Errorf("after archive: archived=%v archived_at=%v", session.Archived, session.ArchivedAt)
+	}
+
+	if code := archive("u1", "s1", `{"archived": false}`); code != http.StatusOK {
+		t.Fatalf("unarchive: status = %d, want 200", code)
+	}
+	if session := stored(); session.Archived || session.ArchivedAt != nil {
+		t.Errorf("after unarchive: archived=%v archived_at=%v", session.Archived, session.ArchivedAt)
+	}
+}
+
+func TestAutoArchiveSessions(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	now := time.Now()
+	seed := func(id string, idleDays int, archived bool) {
+		t.Helper()
+		if _, err := fs.DB.Collection("sessions").Doc(id).Set(ctx, models.Session{
+			ID:        id,
+			UID:       "u1",
+			UpdatedAt: now.AddDate(0, 0, -idleDays),
+			Archived:  archived,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seed("stale", 45, false)
+	seed("staler", 400, false)
+	seed("fresh", 10, false)
+	seed("already-archived", 200, true)
+	// Stored before archived was always written: the document has no archived field
+	if _, err := fs.DB.Collection("sessions").Doc("legacy").Set(ctx, map[string]interface{}{
+		"id":         "legacy",
+		"uid":        "u1",
+		"updated_at": now.AddDate(0, 0, -90),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(days int) map[string]interface{} {
+		t.Helper()
+		w := serveAs("", AutoArchiveSessions(fs, config.Config{SessionAutoArchiveDays: days}), http.MethodPost, "/internal/sessions/auto-archive", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+		var resp map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := run(0); resp["disabled"] != true || resp["archived"] != float64(0) {
+		t.Errorf("disabled job = %v, want nothing archived", resp)
+	}
+
+	// The already-archived session is read but left alone
+	if resp := run(30); resp["scanned"] != float64(4) || resp["archived"] != float64(3) {
+		t.Errorf("first run = %v, want four stale sessions scanned and three archived", resp)
+	}
+	for id, want := range map[string]bool{"stale": true, "staler": true, "legacy": true, "fresh": false, "already-archived": true} {
+		doc, err := fs.DB.Collection("sessions").Doc(id).Get(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := doc.Data()["archived"]; got != want {
+			t.Errorf("%s: archived = %v, want %v", id, got, want)
+		}
+		// Only sessions archived by this run get an archived_at
+		if _, stamped := doc.Data()["archived_at"]; stamped != (id == "stale" || id == "staler" || id == "legacy") {
+			t.Errorf("%s: archived_at set = %v", id, stamped)
+		}
+	}
+
+	if resp := run(30); resp["archived"] != float64(0) {
+		t.Errorf("rerun = %v, want nothing archived", resp)
+	}
+}
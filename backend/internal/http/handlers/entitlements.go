@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"simon-backend/internal/config"
+	"simon-backend/internal/entitlements"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/http/middleware"
+)
+
+// GetEntitlements handles GET /v1/me/entitlements.
+// Returns what the enforcers will apply to the current user: active entitlements (after
+// expiry), remaining credits, free-tier limits (null for Pro) and tools gated behind Pro.
+func GetEntitlements(fs *fsClient.Client, cfg config.Config) gin.HandlerFunc {
+	policy := entitlements.PolicyFromConfig(cfg)
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+
+		user, err := fs.GetUser(ctx, uid)
+		if err != nil {
+			log.Printf("Error getting user %s: %v", uid, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entitlements.Resolve(user, policy, time.Now()))
+	}
+}
+
+// reserveCredits holds a credit for the reply a non-Pro user is about to get, rejecting the request
+// with 402 when they have none left. The credit is taken in a transaction before streaming, so
+// concurrent requests can't all pass on the same balance; the pipeline settles it once the reply
+// completes. reserved is 0 for Pro users; ok is false once a response has been written.
+func reserveCredits(c *gin.Context, fs *fsClient.Client, cfg config.Config, uid string) (reserved int, ok bool) {
+	ctx := c.Request.Context()
+	user, err := fs.GetUser(ctx, uid)
+	if err != nil {
+		log.Printf("Error getting user %s: %v", uid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+		return 0, false
+	}
+
+	if entitlements.Resolve(user, entitlements.PolicyFromConfig(cfg), time.Now()).Pro {
+		return 0, true
+	}
+
+	// Every reply costs at least one credit; the rest of its cost is charged when it settles
+	held, err := fs.ReserveCredits(ctx, uid, 1)
+	if err != nil {
+		log.Printf("Error reserving credits for user %s: %v", uid, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reserve credits"})
+		return 0, false
+	}
+	if !held {
+		respondOutOfCredits(c)
+		return 0, false
+	}
+	return 1, true
+}
+
+// refundCredits returns credits reserved for a reply that never reached the pipeline
+func refundCredits(fs *fsClient.Client, uid string, reserved int) {
+	if reserved <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := fs.SettleCredits(ctx, uid, reserved, 0); err != nil {
+		log.Printf("Error refunding %d credit(s) to user %s: %v", reserved, uid, err)
+	}
+}
+
+// respondOutOfCredits writes the 402 sent to non-Pro users with no credits left
+func respondOutOfCredits(c *gin.Context) {
+	c.JSON(http.StatusPaymentRequired, gin.H{
+		"error":   "insufficient_credits",
+		"message": "You're out of credits. Upgrade to Pro to keep chatting.",
+	})
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/entitlements"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/logger"
+	"simon-backend/internal/models"
+	"simon-backend/internal/sse"
+	"simon-backend/internal/tools"
+)
+
+func TestGetEntitlementsExpiredSubscription(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	lapsed := time.Now().Add(-48 * time.Hour)
+	// Still marked pro in the cache after the subscription ran out
+	user := models.User{UID: "u1", Credits: 4, SubscriptionCache: &models.SubscriptionCache{
+		Entitlements: map[string]bool{"pro": true},
+		ExpiresDate:  &lapsed,
+		Store:        "app_store",
+	}}
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{FreeTierMomentsPerDay: 3, FreeTierMessagesPerSession: 20, ProOnlyTools: []string{"calendar_event_create"}}
+
+	w := serveAs("u1", GetEntitlements(fs, cfg), http.MethodGet, "/v1/me/entitlements", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var got entitlements.Effective
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Pro || len(got.Entitlements) != 0 || got.ExpiresAt != nil {
+		t.Errorf("resolved %+v, want no pro once expired", got)
+	}
+	if got.Credits != 4 || got.Limits == nil || got.Limits.MomentsPerDay != 3 || got.Limits.MessagesPerSession != 20 {
+		t.Errorf("credits = %d, limits = %v, want 4 credits and the free tier", got.Credits, got.Limits)
+	}
+	if !slices.Equal(got.GatedTools, cfg.ProOnlyTools) {
+		t.Errorf("gated tools = %v, want %v", got.GatedTools, cfg.ProOnlyTools)
+	}
+
+	// The tools enforcer applies the same list
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.PolicyFromConfig(cfg), logger.New())
+	body := []byte(`{"tool_id":"calendar_event_create","input":{"title":"Dentist","start_iso":"2026-04-15T15:00:00Z","end_iso":"2026-04-15T16:00:00Z","idempotency_key":"k1"}}`)
+	if w := serveAs("u1", h.HandleExecute, http.MethodPost, "/v1/tools/execute", body); w.Code != http.StatusForbidden {
+		t.Errorf("gated tool status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestStreamChatReservesCredits(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	renews := time.Now().Add(30 * 24 * time.Hour)
+	users := []models.User{
+		{UID: "free", Credits: 1},
+		{UID: "broke"},
+		{UID: "pro", SubscriptionCache: &models.SubscriptionCache{
+			Entitlements: map[string]bool{"pro": true},
+			Active:       true,
+			ExpiresDate:  &renews,
+			Store:        "app_store",
+		}},
+	}
+	for _, user := range users {
+		if _, err := fs.DB.Collection("users").Doc(user.UID).Set(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.DB.Collection("sessions").Doc("s_"+user.UID).Set(ctx, models.Session{ID: "s_" + user.UID, UID: user.UID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "quick_answer", "confidence": 0.9}`},
+	)
+	provider.Default = "Take the stairs today. "
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), c.GetHeader("X-Test-UID")) })
+	r.POST("/v1/sessions/:id/stream", StreamChat(fs, provider, config.Config{CreditsPerMessage: 1}, sse.NewLimiter(1), sse.NewStops(), DefaultToolServices(fs, nil).Plans))
+	post := func(uid, sessionID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/sessions/"+sessionID+"/stream", strings.NewReader(`{"message": "help me move more"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-UID", uid)
+		r.ServeHTTP(w, req)
+		return w
+	}
+	credits := func(uid string) int {
+		t.Helper()
+		user, err := fs.GetUser(ctx, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.Credits
+	}
+
+	if w := post("broke", "s_broke"); w.Code != http.StatusPaymentRequired || !strings.Contains(w.Body.String(), "insufficient_credits") {
+		t.Errorf("no credits: status %d, body %s", w.Code, w.Body)
+	}
+	if n := len(provider.Calls()); n != 0 {
+		t.Fatalf("a user without credits reached the model %d times", n)
+	}
+
+	if w := post("pro", "s_pro"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Take the stairs today.") {
+		t.Errorf("pro: status %d, body %s", w.Code, w.Body)
+	}
+	if got := credits("pro"); got != 0 {
+		t.Errorf("pro credits = %d, want Pro replies free", got)
+	}
+
+	// A stream that fails before the pipeline runs refunds its reservation
+	if w := post("free", "s_missing"); !strings.Contains(w.Body.String(), "SESSION_NOT_FOUND") {
+		t.Errorf("missing session: body %s", w.Body)
+	}
+	if got := credits("free"); got != 1 {
+		t.Errorf("credits after a failed stream = %d, want the reservation refunded", got)
+	}
+
+	if w := post("free", "s_free"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Take the stairs today.") {
+		t.Errorf("free: status %d, body %s", w.Code, w.Body)
+	}
+	if got := credits("free"); got != 0 {
+		t.Errorf("credits after a reply = %d, want 0", got)
+	}
+	if w := post("free", "s_free"); w.Code != http.StatusPaymentRequired {
+		t.Errorf("after spending the last credit: status %d, want 402", w.Code)
+	}
+}
+
+func TestReserveCreditsConcurrent(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1", Credits: 2}); err != nil {
+		t.Fatal(err)
+	}
+	reserve := func(c *gin.Context) {
+		if reserved, ok := reserveCredits(c, fs, config.Config{}, "u1"); ok {
+			c.JSON(http.StatusOK, gin.H{"reserved": reserved})
+		}
+	}
+
+	const requests = 6
+	codes := make(chan int, requests)
+	var wg sync.WaitGroup
+	for range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serveAs("u1", reserve, http.MethodPost, "/v1/sessions/s1/stream", nil).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	served := 0
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			served++
+		case http.StatusPaymentRequired:
+		default:
+			t.Errorf("status %d", code)
+		}
+	}
+	if served != 2 {
+		t.Errorf("served %d of %d concurrent requests, want one per credit", served, requests)
+	}
+	user, err := fs.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Credits != 0 {
+		t.Errorf("credits = %d, want 0", user.Credits)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"google.golang.org/api/iterator"
 
+	"simon-backend/internal/audit"
 	fsClient "simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/logger"
@@ -47,11 +48,9 @@ func (h *EventsHandler) ListCalendarEvents(c *gin.Context) {
 	}
 	
 	// Parse offset with default 0
-	offset := 0
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	offset, ok := parseOffset(c)
+	if !ok {
+		return
 	}
 
 	h.log.Info(ctx, "ListCalendarEvents", map[string]interface{}{
@@ -145,11 +144,9 @@ func (h *EventsHandler) ListReminders(c *gin.Context) {
 	}
 	
 	// Parse offset with default 0
-	offset := 0
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	offset, ok := parseOffset(c)
+	if !ok {
+		return
 	}
 
 	h.log.Info(ctx, "ListReminders", map[string]interface{}{
@@ -243,11 +240,9 @@ func (h *EventsHandler) ListScheduledNotifications(c *gin.Context) {
 	}
 	
 	// Parse offset with default 0
-	offset := 0
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
+	offset, ok := parseOffset(c)
+	if !ok {
+		return
 	}
 
 	h.log.Info(ctx, "ListScheduledNotifications", map[string]interface{}{
@@ -403,6 +398,8 @@ func (h *EventsHandler) CompleteReminder(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.fs, "reminder", audit.ActionUpdate, reminderID)
+
 	// Get the updated reminder
 	updatedDoc, err := docRef.Get(ctx)
 	if err != nil {
@@ -511,6 +508,8 @@ func (h *EventsHandler) CancelNotification(c *gin.Context) {
 		return
 	}
 
+	recordAudit(c, h.fs, "notification", audit.ActionDelete, notificationID)
+
 	// Get the updated notification
 	updatedDoc, err := docRef.Get(ctx)
 	if err != nil {
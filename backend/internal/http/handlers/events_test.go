@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/logger"
+)
+
+func TestEventsOffsetCap(t *testing.T) {
+	h := NewEventsHandler(firestoretest.New(t), logger.New())
+	lists := map[string]gin.HandlerFunc{
+		"/v1/events/calendar":      h.ListCalendarEvents,
+		"/v1/events/reminders":     h.ListReminders,
+		"/v1/events/notifications": h.ListScheduledNotifications,
+	}
+
+	for path, handler := range lists {
+		t.Run(path, func(t *testing.T) {
+			if w := serveAs("u1", handler, http.MethodGet, path+"?offset=500", nil); w.Code != http.StatusOK {
+				t.Errorf("offset at the cap: status = %d, body %s", w.Code, w.Body)
+			}
+
+			w := serveAs("u1", handler, http.MethodGet, path+"?offset=501", nil)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("offset over the cap: status = %d, want 400", w.Code)
+			}
+			var resp struct {
+				Error   string `json:"error"`
+				Message string `json:"message"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Error != "offset_too_large" || resp.Message == "" {
+				t.Errorf("response = %s, want offset_too_large with guidance to use a cursor", w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/http/middleware"
+)
+
+// serveAs runs a single handler for uid and returns the recorded response
+func serveAs(uid string, handler gin.HandlerFunc, method, target string, body []byte) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	var req *http.Request
+	if body == nil {
+		req = httptest.NewRequest(method, target, nil)
+	} else {
+		req = httptest.NewRequest(method, target, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.Request = req
+	c.Set(string(middleware.UIDKey), uid)
+	handler(c)
+	return w
+}
+
+// serve runs a request through r and returns the recorded response
+func serve(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/api/iterator"
+
+	"simon-backend/internal/cache"
+	"simon-backend/internal/config"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/models"
+)
+
+// leaderboardCacheTTL bounds how stale a cached leaderboard may be
+const leaderboardCacheTTL = 5 * time.Minute
+
+// Leaderboard size limits
+const (
+	defaultLeaderboardLimit = 20
+	maxLeaderboardLimit     = 100
+)
+
+// leaderboardWindows maps the window query param to a lookback (0 means all time)
+var leaderboardWindows = map[string]time.Duration{
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+// LeaderboardWeights weights each coach stat in the composite score
+type LeaderboardWeights struct {
+	Starts  float64 `json:"starts"`
+	Saves   float64 `json:"saves"`
+	Upvotes float64 `json:"upvotes"`
+}
+
+// LeaderboardEntry is a ranked coach
+type LeaderboardEntry struct {
+	Rank    int     `json:"rank"`
+	CoachID string  `json:"coach_id"`
+	Title   string  `json:"title"`
+	Promise string  `json:"promise"`
+	Starts  int     `json:"starts"`
+	Saves   int     `json:"saves"`
+	Upvotes int     `json:"upvotes"`
+	Score   float64 `json:"score"`
+}
+
+// CoachLeaderboard returns public coaches ranked by a weighted score (public endpoint).
+// For week/month windows, starts are counted from sessions created in the window;
+// saves and upvotes have no stored timestamps and use all-time totals.
+func CoachLeaderboard(fs *fsClient.Client, cfg config.Config) gin.HandlerFunc {
+	results := cache.New()
+	weights := LeaderboardWeights{
+		Starts:  float64(cfg.LeaderboardWeightStarts),
+		Saves:   float64(cfg.LeaderboardWeightSaves),
+		Upvotes: float64(cfg.LeaderboardWeightUpvotes),
+	}
+
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		window := c.DefaultQuery("window", "week")
+		lookback, ok := leaderboardWindows[window]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "window must be one of: week, month, all"})
+			return
+		}
+
+		limit := defaultLeaderboardLimit
+		if raw := c.Query("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 1 || parsed > maxLeaderboardLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+				return
+			}
+			limit = parsed
+		}
+
+		value, err := results.GetOrSet(ctx, window, leaderboardCacheTTL, func() (interface{}, error) {
+			var since time.Time
+			if lookback > 0 {
+				since = time.Now().Add(-lookback)
+			}
+			return buildLeaderboard(ctx, fs, weights, since)
+		})
+		if err != nil {
+			log.Printf("Error building leaderboard: window=%s, err=%v", window, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build leaderboard"})
+			return
+		}
+
+		entries := value.([]LeaderboardEntry)
+		if len(entries) > limit {
+			entries = entries[:limit]
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"window":  window,
+			"weights": weights,
+			"coaches": entries,
+		})
+	}
+}
+
+// buildLeaderboard scores every startable public coach; a zero since means all time
+func buildLeaderboard(ctx context.Context, fs *fsClient.Client, weights LeaderboardWeights, since time.Time) ([]LeaderboardEntry, error) {
+	var windowStarts map[string]int
+	if !since.IsZero() {
+		var err error
+		windowStarts, err = countSessionStartsSince(ctx, fs, since)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	iter := fs.DB.Collection("coaches").Where("visibility", "==", "public").Documents(ctx)
+	defer iter.Stop()
+
+	entries := []LeaderboardEntry{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var coach models.Coach
+		if err := doc.DataTo(&coach); err != nil {
+			log.Printf("Error parsing coach %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if !coach.IsStartable() {
+			continue
+		}
+
+		starts := coach.Stats.Starts
+		if windowStarts != nil {
+			starts = windowStarts[coach.ID]
+		}
+
+		entries = append(entries, LeaderboardEntry{
+			CoachID: coach.ID,
+			Title:   coach.Title,
+			Promise: coach.Promise,
+			Starts:  starts,
+			Saves:   coach.Stats.Saves,
+			Upvotes: coach.Stats.Upvotes,
+		})
+	}
+
+	rankLeaderboard(entries, weights)
+	return entries, nil
+}
+
+// rankLeaderboard scores entries and sorts them by score, breaking ties by starts then ID
+func rankLeaderboard(entries []LeaderboardEntry, weights LeaderboardWeights) {
+	for i := range entries {
+		e := &entries[i]
+		e.Score = weights.Starts*float64(e.Starts) +
+			weights.Saves*float64(e.Saves) +
+			weights.Upvotes*float64(e.Upvotes)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		if entries[i].Starts != entries[j].Starts {
+			return entries[i].Starts > entries[j].Starts
+		}
+		return entries[i].CoachID < entries[j].CoachID
+	})
+
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+}
+
+// countSessionStartsSince tallies sessions created since the given time by coach
+func countSessionStartsSince(ctx context.Context, fs *fsClient.Client, since time.Time) (map[string]int, error) {
+	iter := fs.DB.Collection("sessions").
+		Where("created_at", ">=", since).
+		Select("coach_id").
+		Documents(ctx)
+	defer iter.Stop()
+
+	counts := make(map[string]int)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return counts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if coachID, ok := doc.Data()["coach_id"].(string); ok && coachID != "" {
+			counts[coachID]++
+		}
+	}
+}
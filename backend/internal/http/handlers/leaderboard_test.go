@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestRankLeaderboardWeights(t *testing.T) {
+	entries := func() []LeaderboardEntry {
+		return []LeaderboardEntry{
+			{CoachID: "popular", Starts: 50, Saves: 2, Upvotes: 1},
+			{CoachID: "loved", Starts: 5, Saves: 10, Upvotes: 12},
+			{CoachID: "tied-b", Starts: 4},
+			{CoachID: "tied-a", Starts: 4},
+		}
+	}
+	ids := func(entries []LeaderboardEntry) []string {
+		var out []string
+		for i, e := range entries {
+			if e.Rank != i+1 {
+				t.Errorf("%s rank = %d at position %d", e.CoachID, e.Rank, i)
+			}
+			out = append(out, e.CoachID)
+		}
+		return out
+	}
+
+	startsHeavy := entries()
+	rankLeaderboard(startsHeavy, LeaderboardWeights{Starts: 1})
+	if got := ids(startsHeavy); fmt.Sprint(got) != "[popular loved tied-a tied-b]" {
+		t.Errorf("starts-weighted order = %v", got)
+	}
+
+	upvoteHeavy := entries()
+	rankLeaderboard(upvoteHeavy, LeaderboardWeights{Starts: 1, Saves: 3, Upvotes: 5})
+	if got := ids(upvoteHeavy); fmt.Sprint(got) != "[loved popular tied-a tied-b]" {
+		t.Errorf("default-weighted order = %v", got)
+	}
+	// 5*1 + 10*3 + 12*5
+	if upvoteHeavy[0].Score != 95 {
+		t.Errorf("score = %v, want 95", upvoteHeavy[0].Score)
+	}
+}
+
+func TestCoachLeaderboardWindow(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coaches := []models.Coach{
+		{ID: "veteran", Visibility: "public", Title: "Veteran", Stats: models.CoachStats{Starts: 100}},
+		{ID: "rising", Visibility: "public", Title: "Rising", Stats: models.CoachStats{Starts: 4}},
+		{ID: "private", Visibility: "private", Title: "Private", Stats: models.CoachStats{Starts: 500}},
+		{ID: "draft", Visibility: "public", Status: models.CoachStatusDraft, Title: "Draft", Stats: models.CoachStats{Starts: 500}},
+	}
+	for _, coach := range coaches {
+		if _, err := fs.DB.Collection("coaches").Doc(coach.ID).Set(ctx, coach); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	sessions := []struct {
+		coachID string
+		age     time.Duration
+	}{
+		{"rising", time.Hour},
+		{"rising", 2 * 24 * time.Hour},
+		{"rising", 3 * 24 * time.Hour},
+		{"veteran", 10 * 24 * time.Hour},
+		{"veteran", 12 * 24 * time.Hour},
+		{"veteran", 20 * 24 * time.Hour},
+		{"veteran", 25 * 24 * time.Hour},
+		{"veteran", 90 * 24 * time.Hour},
+	}
+	for i, s := range sessions {
+		coachID := s.coachID
+		if _, err := fs.DB.Collection("sessions").Doc(fmt.Sprintf("s%d", i)).Set(ctx, models.Session{
+			ID: fmt.Sprintf("s%d", i), UID: "u1", CoachID: &coachID, CreatedAt: now.Add(-s.age),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/v1/coaches/leaderboard", CoachLeaderboard(fs, config.Config{LeaderboardWeightStarts: 1, LeaderboardWeightSaves: 3, LeaderboardWeightUpvotes: 5}))
+	leaderboard := func(query string) []LeaderboardEntry {
+		t.Helper()
+		w := serve(r, http.MethodGet, "/v1/coaches/leaderboard"+query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", query, w.Code, w.Body)
+		}
+		var resp struct {
+			Coaches []LeaderboardEntry `json:"coaches"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp.Coaches
+	}
+
+	tests := []struct {
+		query  string
+		first  string
+		starts map[string]int
+	}{
+		{"", "rising", map[string]int{"rising": 3, "veteran": 0}},
+		{"?window=week", "rising", map[string]int{"rising": 3, "veteran": 0}},
+		{"?window=month", "veteran", map[string]int{"rising": 3, "veteran": 4}},
+		{"?window=all", "veteran", map[string]int{"rising": 4, "veteran": 100}},
+	}
+	for _, tt := range tests {
+		entries := leaderboard(tt.query)
+		if len(entries) != 2 {
+			t.Fatalf("%s: %d coaches, want only the public startable ones: %+v", tt.query, len(entries), entries)
+		}
+		if entries[0].CoachID != tt.first {
+			t.Errorf("%s: first = %s, want %s", tt.query, entries[0].CoachID, tt.first)
+		}
+		for _, e := range entries {
+			if e.Starts != tt.starts[e.CoachID] {
+				t.Errorf("%s: %s starts = %d, want %d", tt.query, e.CoachID, e.Starts, tt.starts[e.CoachID])
+			}
+		}
+	}
+
+	// Results are cached per window, so a new session doesn't show until the entry expires
+	rising := "rising"
+	if _, err := fs.DB.Collection("sessions").Doc("late").Set(ctx, models.Session{ID: "late", UID: "u1", CoachID: &rising, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if entries := leaderboard("?window=week"); entries[0].Starts != 3 {
+		t.Errorf("cached week starts = %d, want 3", entries[0].Starts)
+	}
+
+	if w := serve(r, http.MethodGet, "/v1/coaches/leaderboard?window=year"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown window status = %d, want 400", w.Code)
+	}
+	if w := serve(r, http.MethodGet, "/v1/coaches/leaderboard?limit=0"); w.Code != http.StatusBadRequest {
+		t.Errorf("limit=0 status = %d, want 400", w.Code)
+	}
+	if entries := leaderboard("?window=all&limit=1"); len(entries) != 1 || entries[0].CoachID != "veteran" {
+		t.Errorf("limit=1 = %+v, want just the leader", entries)
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"simon-backend/internal/metrics"
+)
+
+// GetMetrics handles GET /v1/admin/metrics with a snapshot of in-process metrics
+func GetMetrics(c *gin.Context) {
+	c.JSON(http.StatusOK, metrics.Get().GetStats())
+}
+
+// GetPrometheusMetrics handles GET /metrics in the Prometheus text exposition format
+func GetPrometheusMetrics(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := metrics.Get().WritePrometheus(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render metrics"})
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", buf.Bytes())
+}
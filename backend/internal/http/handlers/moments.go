@@ -2,20 +2,26 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"net/http"
+	"time"
 
+	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 
 	"simon-backend/internal/agent"
 	"simon-backend/internal/config"
-	"simon-backend/internal/firestore"
+	"simon-backend/internal/entitlements"
+	fsClient "simon-backend/internal/firestore"
 	"simon-backend/internal/gemini"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
+	"simon-backend/internal/redact"
 )
 
 type startMomentRequest struct {
 	Prompt string `json:"prompt" binding:"required"`
+	Mode   string `json:"mode,omitempty"` // defaults to the routed coach's default mode
 }
 
 type startMomentResponse struct {
@@ -32,7 +38,7 @@ type startMomentResponse struct {
 // 3. Routes to existing coach or generates new one
 // 4. Creates session
 // 5. Returns session ID and first message
-func StartMoment(fs *firestore.Client, gm *gemini.Client, cfg config.Config) gin.HandlerFunc {
+func StartMoment(fs *fsClient.Client, gm *gemini.Client, cfg config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
 		ctx := c.Request.Context()
@@ -42,23 +48,47 @@ func StartMoment(fs *firestore.Client, gm *gemini.Client, cfg config.Config) gin
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 			return
 		}
+		if req.Mode != "" && !models.ValidSessionMode(req.Mode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be one of: quick, system, deep"})
+			return
+		}
 
 		// Check Pro status or free tier limit
-		// TODO: Implement RevenueCat validation (Week 3)
-		isPro := false // Placeholder
+		var sessionID string
+		user, err := fs.GetUser(ctx, uid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+			return
+		}
+		effective := entitlements.Resolve(user, entitlements.PolicyFromConfig(cfg), time.Now())
+		isPro := effective.Pro
 
 		if !isPro {
-			// Check free tier limit (3 moments per day)
-			count, err := getMomentsCountToday(ctx, fs, uid)
+			if user.Credits <= 0 {
+				respondOutOfCredits(c)
+				return
+			}
+
+			// Check free tier limit
+			day := time.Now().In(user.Preferences.Location()).Format(time.DateOnly)
+			claimed, err := claimMoment(ctx, fs, uid, day, effective.Limits.MomentsPerDay)
 			if err != nil {
+				log.Printf("Error counting moments for user %s: %v", uid, err)
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check moment limit"})
 				return
 			}
 
-			if count >= cfg.FreeTierMomentsPerDay {
+			if !claimed {
 				c.JSON(http.StatusPaymentRequired, gin.H{"error": "free tier limit reached"})
 				return
 			}
+
+			// A moment that fails before its session exists doesn't count
+			defer func() {
+				if sessionID == "" {
+					releaseMoment(fs, uid, day)
+				}
+			}()
 		}
 
 		// Use router agent to classify intent and determine coach
@@ -69,26 +99,49 @@ func StartMoment(fs *firestore.Client, gm *gemini.Client, cfg config.Config) gin
 			return
 		}
 
+		mode := req.Mode
+		var snapshot *models.CoachSpec
+		if coach := loadMomentCoach(ctx, fs, routeResult.CoachID); coach != nil {
+			if mode == "" {
+				mode = coach.DefaultSessionMode()
+			}
+			snapshot = coach.EffectiveSpec()
+		}
+		if mode == "" {
+			mode = models.SessionModeQuick
+		}
+
 		// Create session
 		session := models.Session{
-			UID:       uid,
-			CoachID:   routeResult.CoachID,
-			Title:     routeResult.Title,
-			Mode:      "quick",
-			CreatedAt: models.Now(),
-			UpdatedAt: models.Now(),
+			UID:               uid,
+			CoachID:           routeResult.CoachID,
+			Title:             routeResult.Title,
+			Mode:              mode,
+			CoachSpecSnapshot: snapshot,
+			CreatedAt:         models.Now(),
+			UpdatedAt:         models.Now(),
 		}
 
-		sessionID, err := fs.CreateSession(ctx, session)
+		sessionID, err = fs.CreateSession(ctx, session)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
 			return
 		}
 
+		if routeResult.CoachID != nil && *routeResult.CoachID != "" {
+			if err := fs.IncrementCoachStarts(ctx, *routeResult.CoachID); err != nil {
+				log.Printf("Error incrementing starts for coach %s: %v", *routeResult.CoachID, err)
+			}
+		}
+
 		// Save user's initial message
+		prompt, redacted := redact.ForStorage(req.Prompt, snapshot)
+		if redacted > 0 {
+			log.Printf("Redacted %d sensitive value(s) from user message in session %s", redacted, sessionID)
+		}
 		userMessage := models.Message{
 			Role:        "user",
-			ContentText: req.Prompt,
+			ContentText: prompt,
 			CreatedAt:   models.Now(),
 		}
 
@@ -97,14 +150,6 @@ func StartMoment(fs *firestore.Client, gm *gemini.Client, cfg config.Config) gin
 			return
 		}
 
-		// Increment moment count if not Pro
-		if !isPro {
-			if err := incrementMomentCount(ctx, fs, uid); err != nil {
-				// Log error but don't fail the request
-				c.Error(err)
-			}
-		}
-
 		// Return response
 		response := startMomentResponse{
 			SessionID:    sessionID,
@@ -117,16 +162,70 @@ func StartMoment(fs *firestore.Client, gm *gemini.Client, cfg config.Config) gin
 	}
 }
 
-// getMomentsCountToday returns the number of moments started today by the user
-func getMomentsCountToday(ctx context.Context, fs *firestore.Client, uid string) (int, error) {
-	// TODO: Implement Firestore query to count sessions created today
-	// For now, return 0 (will be implemented with Firestore repos)
-	return 0, nil
+// momentCount is how many moments a user started on one day in their timezone
+type momentCount struct {
+	UID       string    `firestore:"uid"`
+	Day       string    `firestore:"day"`
+	Count     int       `firestore:"count"`
+	UpdatedAt time.Time `firestore:"updated_at"`
+}
+
+// momentCounter is the user's counter document for day (YYYY-MM-DD), keyed "uid:YYYY-MM-DD"
+func momentCounter(fs *fsClient.Client, uid, day string) *firestore.DocumentRef {
+	return fs.DB.Collection("moment_counts").Doc(uid + ":" + day)
+}
+
+// claimMoment counts one more moment for the user on day, reporting false once the day's limit is
+// used up. The check and increment share a transaction so concurrent starts can't overshoot, and
+// deleting sessions doesn't give moments back.
+func claimMoment(ctx context.Context, fs *fsClient.Client, uid, day string, limit int) (bool, error) {
+	ref := momentCounter(fs, uid, day)
+	claimed := false
+	err := fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = false
+		counter := momentCount{UID: uid, Day: day}
+		doc, err := tx.Get(ref)
+		if err != nil && !fsClient.IsNotFound(err) {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&counter); err != nil {
+				return err
+			}
+		}
+
+		if counter.Count >= limit {
+			return nil
+		}
+		claimed = true
+		counter.Count++
+		counter.UpdatedAt = time.Now()
+		return tx.Set(ref, counter)
+	})
+	return claimed, err
+}
+
+// releaseMoment gives back a moment claimed by a start that failed
+func releaseMoment(fs *fsClient.Client, uid, day string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := momentCounter(fs, uid, day).Update(ctx, []firestore.Update{
+		{Path: "count", Value: firestore.Increment(-1)},
+	}); err != nil {
+		log.Printf("Error releasing moment for user %s on %s: %v", uid, day, err)
+	}
 }
 
-// incrementMomentCount increments the moment count for today
-func incrementMomentCount(ctx context.Context, fs *firestore.Client, uid string) error {
-	// TODO: Implement Firestore increment
-	// For now, no-op
-	return nil
+// loadMomentCoach loads the routed coach, or returns nil when there is none or it can't be loaded
+func loadMomentCoach(ctx context.Context, fs *fsClient.Client, coachID *string) *models.Coach {
+	if coachID == nil || *coachID == "" {
+		return nil
+	}
+
+	coach, err := fs.GetCoach(ctx, *coachID)
+	if err != nil {
+		log.Printf("Error loading coach %s for new moment: %v", *coachID, err)
+		return nil
+	}
+	return coach
 }
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestClaimMomentPerLocalDay(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skip(err)
+	}
+	// 23:30 in Auckland (UTC+13), then 15 minutes later, then just past local midnight;
+	// all three fall on the same UTC day
+	times := []time.Time{
+		time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC),
+		time.Date(2026, 10, 17, 10, 45, 0, 0, time.UTC),
+		time.Date(2026, 10, 17, 11, 5, 0, 0, time.UTC),
+	}
+	want := []bool{true, false, true}
+	for i, now := range times {
+		day := now.In(auckland).Format(time.DateOnly)
+		claimed, err := claimMoment(ctx, fs, "u1", day, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claimed != want[i] {
+			t.Errorf("claim at %s (local day %s) = %v, want %v", now.Format(time.RFC3339), day, claimed, want[i])
+		}
+	}
+
+	doc, err := momentCounter(fs, "u1", "2026-10-17").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var counter momentCount
+	if err := doc.DataTo(&counter); err != nil {
+		t.Fatal(err)
+	}
+	if counter.UID != "u1" || counter.Day != "2026-10-17" || counter.Count != 1 {
+		t.Errorf("counter = %+v, want one moment on the 17th", counter)
+	}
+
+	// Releasing a failed start gives the moment back
+	releaseMoment(fs, "u1", "2026-10-17")
+	if claimed, err := claimMoment(ctx, fs, "u1", "2026-10-17", 1); err != nil || !claimed {
+		t.Errorf("claim after release = %v, %v; want the moment available again", claimed, err)
+	}
+}
+
+func TestStartMomentFreeTierLimit(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	users := []models.User{
+		{UID: "u1", Credits: 5, Preferences: models.Preferences{Timezone: "Europe/Istanbul"}},
+		{UID: "broke"},
+	}
+	for _, user := range users {
+		if _, err := fs.DB.Collection("users").Doc(user.UID).Set(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Skip(err)
+	}
+	today := time.Now().In(istanbul).Format(time.DateOnly)
+	for range 2 {
+		if claimed, err := claimMoment(ctx, fs, "u1", today, 2); err != nil || !claimed {
+			t.Fatalf("claim = %v, %v", claimed, err)
+		}
+	}
+	handler := StartMoment(fs, nil, config.Config{FreeTierMomentsPerDay: 2})
+	body := []byte(`{"prompt": "I can't focus this afternoon"}`)
+
+	w := serveAs("u1", handler, http.MethodPost, "/v1/moments/start", body)
+	if w.Code != http.StatusPaymentRequired || !strings.Contains(w.Body.String(), "free tier limit reached") {
+		t.Errorf("over the limit: status %d, body %s", w.Code, w.Body)
+	}
+	w = serveAs("broke", handler, http.MethodPost, "/v1/moments/start", body)
+	if w.Code != http.StatusPaymentRequired || !strings.Contains(w.Body.String(), "insufficient_credits") {
+		t.Errorf("no credits: status %d, body %s", w.Code, w.Body)
+	}
+
+	doc, err := momentCounter(fs, "u1", today).Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := doc.DataAt("count"); count != int64(2) {
+		t.Errorf("count = %v, want refused starts left uncounted", count)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxOffset is the largest offset the offset-paginated list handlers accept. Firestore bills
+// for every skipped document and returns unreliable results for large offsets.
+const maxOffset = 500
+
+// parseOffset reads the offset query param, responding with 400 and returning false when it
+// exceeds maxOffset
+func parseOffset(c *gin.Context) (int, bool) {
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	if offset > maxOffset {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "offset_too_large",
+			"message": fmt.Sprintf("offset may not exceed %d; narrow the query with filters or page with a cursor instead", maxOffset),
+		})
+		return 0, false
+	}
+
+	return offset, true
+}
@@ -1,26 +1,37 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"simon-backend/internal/audit"
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
 	"simon-backend/internal/tools"
 )
 
-// ListPlans returns active plans for the authenticated user
+// ListPlans returns the authenticated user's plans. ?status selects active (the default),
+// completed, archived or all plans.
 func ListPlans(fs *firestore.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
 
+		status := c.DefaultQuery("status", "active")
+		if !tools.PlanListStatuses[status] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of: active, completed, archived, all"})
+			return
+		}
+
 		planService := tools.NewPlanService(fs.DB)
 		
-		resp, err := planService.ListActive(c.Request.Context(), tools.PlanListRequest{
-			UID:   uid,
-			Limit: 10,
+		resp, err := planService.List(c.Request.Context(), tools.PlanListRequest{
+			UID:    uid,
+			Limit:  10,
+			Status: status,
 		})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -37,8 +48,10 @@ func CreatePlan(fs *firestore.Client) gin.HandlerFunc {
 		uid := middleware.GetUID(c)
 
 		var req struct {
-			CoachID string       `json:"coach_id" binding:"required"`
-			Plan    models.Plan  `json:"plan" binding:"required"`
+			CoachID        string       `json:"coach_id" binding:"required"`
+			SessionID      string       `json:"session_id"`
+			Plan           models.Plan  `json:"plan" binding:"required"`
+			IdempotencyKey string       `json:"idempotency_key"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -48,17 +61,30 @@ func CreatePlan(fs *firestore.Client) gin.HandlerFunc {
 
 		planService := tools.NewPlanService(fs.DB)
 		
+		idempotencyKey := req.IdempotencyKey
+		if idempotencyKey == "" {
+			idempotencyKey = tools.PlanIdempotencyKey(req.SessionID, req.Plan.Objective)
+		}
+
 		resp, err := planService.Create(c.Request.Context(), tools.PlanCreateRequest{
-			UID:     uid,
-			CoachID: req.CoachID,
-			Plan:    req.Plan,
+			UID:            uid,
+			CoachID:        req.CoachID,
+			SessionID:      req.SessionID,
+			Plan:           req.Plan,
+			IdempotencyKey: idempotencyKey,
 		})
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusCreated, gin.H{
+		status := http.StatusOK
+		if resp.Status == "created" {
+			status = http.StatusCreated
+			recordAudit(c, fs, "plan", audit.ActionCreate, resp.PlanID)
+		}
+
+		c.JSON(status, gin.H{
 			"plan_id": resp.PlanID,
 			"status":  resp.Status,
 		})
@@ -97,12 +123,19 @@ func UpdatePlan(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "plan", audit.ActionUpdate, planID)
 		c.JSON(http.StatusOK, gin.H{
 			"status": resp.Status,
 		})
 	}
 }
 
+// planResponse is a plan with its completion progress
+type planResponse struct {
+	models.Plan
+	Progress int `json:"progress"` // percent of next actions completed
+}
+
 // GetPlan returns a specific plan by ID
 func GetPlan(fs *firestore.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -133,6 +166,124 @@ func GetPlan(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, plan)
+		c.JSON(http.StatusOK, planResponse{Plan: plan, Progress: tools.PlanProgress(plan)})
+	}
+}
+
+// CompletePlanAction handles PUT /v1/plans/:id/actions/:actionId/complete
+func CompletePlanAction(fs *firestore.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		planService := tools.NewPlanService(fs.DB)
+		completePlanItem(c, fs, "action", func(uid, planID string) (*models.Plan, error) {
+			return planService.CompleteAction(c.Request.Context(), uid, planID, c.Param("actionId"))
+		})
+	}
+}
+
+// CompletePlanMilestone handles PUT /v1/plans/:id/milestones/:milestoneId/complete
+func CompletePlanMilestone(fs *firestore.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		planService := tools.NewPlanService(fs.DB)
+		completePlanItem(c, fs, "milestone", func(uid, planID string) (*models.Plan, error) {
+			return planService.CompleteMilestone(c.Request.Context(), uid, planID, c.Param("milestoneId"))
+		})
+	}
+}
+
+// completePlanItem runs complete for the caller's plan and responds with the updated plan and its progress
+func completePlanItem(c *gin.Context, fs *firestore.Client, item string, complete func(uid, planID string) (*models.Plan, error)) {
+	uid := middleware.GetUID(c)
+	planID := c.Param("id")
+
+	plan, err := complete(uid, planID)
+	if err != nil {
+		if status, ok := toolErrorStatus(err); ok {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Failed to complete plan %s: planID=%s, err=%v", item, planID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update plan"})
+		return
+	}
+
+	recordAudit(c, fs, "plan", audit.ActionUpdate, planID)
+	c.JSON(http.StatusOK, planResponse{Plan: *plan, Progress: tools.PlanProgress(*plan)})
+}
+
+// PinPlan pins a plan to the user's home, unpinning any previously pinned plan
+func PinPlan(fs *firestore.Client) gin.HandlerFunc {
+	return setPlanPinned(fs, true)
+}
+
+// UnpinPlan unpins a plan
+func UnpinPlan(fs *firestore.Client) gin.HandlerFunc {
+	return setPlanPinned(fs, false)
+}
+
+// setPlanPinned handles PUT /v1/plans/:id/pin and /unpin
+func setPlanPinned(fs *firestore.Client, pinned bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := middleware.GetUID(c)
+		planID := c.Param("id")
+
+		planService := tools.NewPlanService(fs.DB)
+		if err := planService.SetPinned(c.Request.Context(), uid, planID, pinned); err != nil {
+			if status, ok := toolErrorStatus(err); ok {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Failed to set plan pinned: planID=%s, pinned=%v, err=%v", planID, pinned, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update plan"})
+			return
+		}
+
+		recordAudit(c, fs, "plan", audit.ActionUpdate, planID)
+		c.JSON(http.StatusOK, gin.H{
+			"plan_id": planID,
+			"pinned":  pinned,
+		})
+	}
+}
+
+// ArchivePlan handles PUT /v1/plans/:id/archive
+func ArchivePlan(fs *firestore.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := middleware.GetUID(c)
+		planID := c.Param("id")
+
+		planService := tools.NewPlanService(fs.DB)
+		if err := planService.Archive(c.Request.Context(), uid, planID); err != nil {
+			if status, ok := toolErrorStatus(err); ok {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Failed to archive plan: planID=%s, err=%v", planID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update plan"})
+			return
+		}
+
+		recordAudit(c, fs, "plan", audit.ActionArchive, planID)
+		c.JSON(http.StatusOK, gin.H{
+			"plan_id": planID,
+			"status":  "archived",
+		})
+	}
+}
+
+// RunRecurringPlans handles POST /internal/plans/recur.
+// It archives recurring plans whose period has ended and creates the next period's plans.
+func RunRecurringPlans(fs *firestore.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		planService := tools.NewPlanService(fs.DB)
+
+		result, err := planService.RunRecurring(c.Request.Context(), time.Now())
+		if err != nil {
+			log.Printf("Recurring plan run failed after %d plans: %v", result.Scanned, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to renew recurring plans"})
+			return
+		}
+
+		log.Printf("Recurring plan run: scanned=%d renewed=%d", result.Scanned, result.Renewed)
+		c.JSON(http.StatusOK, result)
 	}
 }
@@ -14,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"simon-backend/internal/audit"
 	"simon-backend/internal/config"
 	fsClient "simon-backend/internal/firestore"
 	"simon-backend/internal/logger"
@@ -155,7 +156,17 @@ func (h *RevenueCatWebhookHandler) processEvent(ctx context.Context, payload Rev
 	}
 
 	// Update user's subscription cache
-	return h.updateSubscriptionCache(ctx, payload)
+	if err := h.updateSubscriptionCache(ctx, payload); err != nil {
+		return err
+	}
+
+	if uid := payload.Event.AppUserID; uid != "" {
+		entry := audit.NewEntry(ctx, audit.ActorRevenueCat, "subscription", audit.ActionUpdate, uid)
+		if err := audit.Record(ctx, h.fs, entry); err != nil {
+			h.logger.Warning(ctx, "Failed to record subscription audit", map[string]interface{}{"uid": uid, "error": err.Error()})
+		}
+	}
+	return nil
 }
 
 // updateSubscriptionCache updates the user's subscription cache
@@ -165,43 +176,53 @@ func (h *RevenueCatWebhookHandler) updateSubscriptionCache(ctx context.Context,
 		return nil // No user to update
 	}
 
-	// Build entitlements map
-	entitlements := make(map[string]bool)
-	for _, entitlementID := range payload.Event.EntitlementIDs {
-		// Determine if entitlement is active based on event type
-		isActive := h.isEntitlementActive(payload.Event.Type)
-		entitlements[entitlementID] = isActive
-	}
-
 	var expiresDate *time.Time
 	if payload.Event.ExpirationAtMs > 0 {
 		t := time.Unix(payload.Event.ExpirationAtMs/1000, 0)
 		expiresDate = &t
 	}
 
-	subscriptionCache := models.SubscriptionCache{
-		Entitlements:      entitlements,
-		ProductIdentifier: payload.Event.ProductID,
-		ExpiresDate:       expiresDate,
-		PeriodType:        payload.Event.PeriodType,
-		Store:             payload.Event.Store,
-		LastUpdated:       models.Now(),
-	}
+	// Determine if entitlements are active based on event type
+	isActive := h.isEntitlementActive(payload.Event.Type)
 
-	// Update user document
+	// Read-modify-write in a transaction so concurrent events from different stores
+	// don't overwrite each other's state
 	userRef := h.fs.DB.Collection("users").Doc(uid)
-	_, err := userRef.Update(ctx, []firestore.Update{
-		{
-			Path:  "subscription_cache",
-			Value: subscriptionCache,
-		},
-		{
-			Path:  "updated_at",
-			Value: models.Now(),
-		},
-	})
+	return h.fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		subscriptionCache := models.SubscriptionCache{}
+		if user.SubscriptionCache != nil {
+			subscriptionCache = *user.SubscriptionCache
+		}
+
+		subscriptionCache.ApplyStoreEvent(payload.Event.Store, payload.Event.EntitlementIDs, isActive, models.StoreSubscription{
+			ProductIdentifier: payload.Event.ProductID,
+			ExpiresDate:       expiresDate,
+			PeriodType:        payload.Event.PeriodType,
+			LastUpdated:       models.Now(),
+		})
 
-	return err
+		// Update user document
+		return tx.Update(userRef, []firestore.Update{
+			{
+				Path:  "subscription_cache",
+				Value: subscriptionCache,
+			},
+			{
+				Path:  "updated_at",
+				Value: models.Now(),
+			},
+		})
+	})
 }
 
 // isEntitlementActive determines if an entitlement is active based on event type
@@ -235,8 +256,8 @@ func (h *RevenueCatWebhookHandler) isEntitlementActive(eventType string) bool {
 }
 
 // CheckEntitlement checks if a user has a specific entitlement
-func CheckEntitlement(fs *fsClient.Client, uid string, entitlementID string) (bool, error) {
-	userDoc, err := fs.DB.Collection("users").Doc(uid).Get(nil)
+func CheckEntitlement(ctx context.Context, fs *fsClient.Client, uid string, entitlementID string) (bool, error) {
+	userDoc, err := fs.DB.Collection("users").Doc(uid).Get(ctx)
 	if err != nil {
 		return false, err
 	}
@@ -251,18 +272,8 @@ func CheckEntitlement(fs *fsClient.Client, uid string, entitlementID string) (bo
 		return false, nil
 	}
 
-	// Check if entitlement exists and is active
-	if active, ok := user.SubscriptionCache.Entitlements[entitlementID]; ok {
-		// Also check expiration date if present
-		if user.SubscriptionCache.ExpiresDate != nil {
-			if time.Now().After(*user.SubscriptionCache.ExpiresDate) {
-				return false, nil
-			}
-		}
-		return active, nil
-	}
-
-	return false, nil
+	// Check if entitlement is active, ignoring stores whose subscription has expired
+	return user.SubscriptionCache.ActiveEntitlements(time.Now())[entitlementID], nil
 }
 
 // RequiresPro middleware checks if user has pro entitlement
@@ -275,7 +286,7 @@ func RequiresPro(fs *fsClient.Client) gin.HandlerFunc {
 			return
 		}
 
-		hasPro, err := CheckEntitlement(fs, uid.(string), "pro")
+		hasPro, err := CheckEntitlement(c.Request.Context(), fs, uid.(string), "pro")
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check entitlement"})
 			c.Abort()
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/logger"
+	"simon-backend/internal/models"
+)
+
+func revenueCatEvent(eventType, store string, expires time.Time) RevenueCatWebhookPayload {
+	var payload RevenueCatWebhookPayload
+	payload.Event.Type = eventType
+	payload.Event.AppUserID = "u1"
+	payload.Event.EntitlementIDs = []string{"pro"}
+	payload.Event.Store = store
+	payload.Event.ExpirationAtMs = expires.UnixMilli()
+	return payload
+}
+
+func TestSubscriptionCacheAcrossStores(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewRevenueCatWebhookHandler(fs, config.Config{}, logger.New())
+	expires := time.Now().AddDate(0, 1, 0)
+
+	for _, payload := range []RevenueCatWebhookPayload{
+		revenueCatEvent("INITIAL_PURCHASE", "app_store", expires),
+		revenueCatEvent("INITIAL_PURCHASE", "play_store", expires),
+		revenueCatEvent("CANCELLATION", "app_store", expires),
+	} {
+		if err := h.updateSubscriptionCache(ctx, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hasPro, err := CheckEntitlement(ctx, fs, "u1", "pro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasPro {
+		t.Error("app_store cancellation revoked the still-active play_store entitlement")
+	}
+
+	if err := h.updateSubscriptionCache(ctx, revenueCatEvent("EXPIRATION", "play_store", expires)); err != nil {
+		t.Fatal(err)
+	}
+	if hasPro, _ := CheckEntitlement(ctx, fs, "u1", "pro"); hasPro {
+		t.Error("pro still granted after both stores lost it")
+	}
+}
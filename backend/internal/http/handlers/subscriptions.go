@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/models"
+)
+
+// reconcilePageSize bounds how many users are scanned per page
+const reconcilePageSize = 200
+
+// ReconcileSubscriptions handles POST /internal/subscriptions/reconcile.
+// It flips cached entitlements to false for users whose subscription has lapsed
+// without an EXPIRATION webhook arriving.
+func ReconcileSubscriptions(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		scanned, updated, err := reconcileExpiredSubscriptions(ctx, fs, time.Now())
+		if err != nil {
+			log.Printf("Subscription reconcile failed after %d users: %v", scanned, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reconcile subscriptions"})
+			return
+		}
+
+		log.Printf("Subscription reconcile: scanned=%d updated=%d", scanned, updated)
+		c.JSON(http.StatusOK, gin.H{
+			"scanned": scanned,
+			"updated": updated,
+		})
+	}
+}
+
+// reconcileExpiredSubscriptions scans users with a past expires_date and clears stale entitlements.
+// Caches written before the active flag existed match too. Clearing a cache removes its
+// expires_date, so a user is only scanned again after a new purchase lapses.
+func reconcileExpiredSubscriptions(ctx context.Context, fs *fsClient.Client, now time.Time) (int, int, error) {
+	scanned, updated := 0, 0
+	var lastDoc *firestore.DocumentSnapshot
+
+	for {
+		query := fs.DB.Collection("users").
+			Where("subscription_cache.expires_date", "<", now).
+			OrderBy("subscription_cache.expires_date", firestore.Asc).
+			Limit(reconcilePageSize)
+		if lastDoc != nil {
+			query = query.StartAfter(lastDoc)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return scanned, updated, err
+		}
+		if len(docs) == 0 {
+			return scanned, updated, nil
+		}
+
+		for _, doc := range docs {
+			scanned++
+
+			expired, err := expireLapsedUser(ctx, fs, doc.Ref, now)
+			if err != nil {
+				return scanned, updated, err
+			}
+			if expired {
+				updated++
+			}
+		}
+
+		if len(docs) < reconcilePageSize {
+			return scanned, updated, nil
+		}
+		lastDoc = docs[len(docs)-1]
+	}
+}
+
+// expireLapsedUser clears a user's lapsed entitlements in a transaction. The cache is re-read
+// inside it, so a renewal that lands after the scan is never overwritten. It reports whether
+// anything was cleared.
+func expireLapsedUser(ctx context.Context, fs *fsClient.Client, ref *firestore.DocumentRef, now time.Time) (bool, error) {
+	expired := false
+	err := fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		expired = false
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				return nil // Deleted since the scan
+			}
+			return err
+		}
+
+		var user models.User
+		if err := doc.DataTo(&user); err != nil {
+			log.Printf("Error parsing user %s: %v", ref.ID, err)
+			return nil
+		}
+
+		updates := expiredEntitlementUpdates(user.SubscriptionCache, now)
+		if len(updates) == 0 {
+			return nil
+		}
+
+		expired = true
+		return tx.Update(ref, updates)
+	})
+	return expired, err
+}
+
+// expiredEntitlementUpdates clears entitlements that are still marked active past their
+// store's expiry, returning updates for only the stores that lapsed and the derived fields.
+// A cache that grants nothing but still carries a past expires_date gets its derived fields
+// rewritten, which drops it from later scans.
+func expiredEntitlementUpdates(cache *models.SubscriptionCache, now time.Time) []firestore.Update {
+	if cache == nil {
+		return nil
+	}
+
+	stale := cache.ExpiresDate != nil && now.After(*cache.ExpiresDate)
+	stores := cache.ExpireLapsed(now)
+	if len(stores) == 0 && !stale {
+		return nil
+	}
+
+	updates := make([]firestore.Update, 0, len(stores)+5)
+	for _, store := range stores {
+		updates = append(updates, firestore.Update{
+			FieldPath: firestore.FieldPath{"subscription_cache", "stores", store},
+			Value:     cache.Stores[store],
+		})
+	}
+
+	var expires interface{} = firestore.Delete
+	if cache.ExpiresDate != nil {
+		expires = *cache.ExpiresDate
+	}
+	return append(updates,
+		firestore.Update{Path: "subscription_cache.entitlements", Value: cache.Entitlements},
+		firestore.Update{Path: "subscription_cache.expires_date", Value: expires},
+		firestore.Update{Path: "subscription_cache.active", Value: cache.Active},
+		firestore.Update{Path: "subscription_cache.last_updated", Value: cache.LastUpdated},
+		firestore.Update{Path: "updated_at", Value: models.Now()},
+	)
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestReconcileExpiredSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	now := time.Now()
+	lapsed, renewed := now.Add(-48*time.Hour), now.AddDate(0, 1, 0)
+
+	seed := func(uid string, cache *models.SubscriptionCache) {
+		t.Helper()
+		if _, err := fs.DB.Collection("users").Doc(uid).Set(ctx, models.User{UID: uid, SubscriptionCache: cache}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Still marked pro two days after the subscription ran out, with no EXPIRATION webhook
+	seed("expired", &models.SubscriptionCache{Entitlements: map[string]bool{"pro": true}, ExpiresDate: &lapsed, Store: "app_store", Active: true})
+	seed("current", &models.SubscriptionCache{Entitlements: map[string]bool{"pro": true}, ExpiresDate: &renewed, Store: "app_store", Active: true})
+	// Cleared by an older reconciler that left its expires_date behind
+	seed("already-off", &models.SubscriptionCache{Entitlements: map[string]bool{"pro": false}, ExpiresDate: &lapsed})
+	seed("free", nil)
+	// Cached before the active flag existed: the document has no subscription_cache.active at all
+	if _, err := fs.DB.Collection("users").Doc("legacy").Set(ctx, map[string]interface{}{
+		"uid": "legacy",
+		"subscription_cache": map[string]interface{}{
+			"entitlements": map[string]bool{"pro": true},
+			"expires_date": lapsed,
+			"store":        "app_store",
+			"last_updated": lapsed,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	scanned, updated, err := reconcileExpiredSubscriptions(ctx, fs, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != 3 || updated != 3 {
+		t.Errorf("scanned=%d updated=%d, want 3 and 3", scanned, updated)
+	}
+
+	for uid, wantPro := range map[string]bool{"expired": false, "current": true, "already-off": false, "legacy": false} {
+		user, err := fs.GetUser(ctx, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := user.SubscriptionCache.Entitlements["pro"]; got != wantPro {
+			t.Errorf("%s: pro = %v, want %v", uid, got, wantPro)
+		}
+	}
+
+	// Corrected caches no longer carry a past expires_date, so a rerun scans nothing
+	if scanned, updated, err := reconcileExpiredSubscriptions(ctx, fs, now); err != nil || scanned != 0 || updated != 0 {
+		t.Errorf("rerun scanned %d and updated %d (err %v), want 0", scanned, updated, err)
+	}
+}
+
+func TestReconcileExpiredSubscriptionsPages(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	now := time.Now()
+	total := reconcilePageSize + 5
+	for i := 0; i < total; i++ {
+		expires := now.Add(-time.Duration(i+1) * time.Minute)
+		uid := fmt.Sprintf("u%03d", i)
+		if _, err := fs.DB.Collection("users").Doc(uid).Set(ctx, models.User{
+			UID:               uid,
+			SubscriptionCache: &models.SubscriptionCache{Entitlements: map[string]bool{"pro": true}, ExpiresDate: &expires, Active: true},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	scanned, updated, err := reconcileExpiredSubscriptions(ctx, fs, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scanned != total || updated != total {
+		t.Errorf("scanned=%d updated=%d, want %d across two pages", scanned, updated, total)
+	}
+}
+
+func TestExpireLapsedUserKeepsRenewal(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	now := time.Now()
+	lapsed, renewed := now.Add(-time.Hour), now.AddDate(0, 1, 0)
+	ref := fs.DB.Collection("users").Doc("u1")
+	if _, err := ref.Set(ctx, models.User{UID: "u1", SubscriptionCache: &models.SubscriptionCache{Stores: map[string]models.StoreSubscription{
+		"app_store":  {Entitlements: map[string]bool{"pro": true}, ExpiresDate: &lapsed},
+		"play_store": {Entitlements: map[string]bool{"coach_pack": true}, ExpiresDate: &lapsed, ProductIdentifier: "coach_pack_monthly"},
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The App Store renewal webhook lands after the scan read the lapsed cache
+	if _, err := ref.Update(ctx, []firestore.Update{
+		{Path: "subscription_cache.stores.app_store.expires_date", Value: renewed},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := expireLapsedUser(ctx, fs, ref, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expired {
+		t.Fatal("the lapsed play_store entitlement was not cleared")
+	}
+	user, err := fs.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := user.SubscriptionCache
+	if !cache.Stores["app_store"].Entitlements["pro"] || !cache.Entitlements["pro"] {
+		t.Errorf("renewed app_store entitlement revoked: %+v", cache)
+	}
+	if cache.Stores["play_store"].Entitlements["coach_pack"] || cache.Entitlements["coach_pack"] {
+		t.Errorf("lapsed play_store entitlement kept: %+v", cache)
+	}
+	if cache.Stores["play_store"].ProductIdentifier != "coach_pack_monthly" {
+		t.Errorf("play_store state = %+v, want its other fields kept", cache.Stores["play_store"])
+	}
+	if cache.ExpiresDate == nil || !cache.ExpiresDate.Equal(renewed) || !cache.Active {
+		t.Errorf("expires = %v (active %v), want the renewed expiry", cache.ExpiresDate, cache.Active)
+	}
+
+	if _, err := expireLapsedUser(ctx, fs, fs.DB.Collection("users").Doc("deleted"), now); err != nil {
+		t.Errorf("deleted user: %v", err)
+	}
+}
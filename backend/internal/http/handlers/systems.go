@@ -1,35 +1,93 @@
 package handlers
 
 import (
+	"context"
+	"log"
 	"net/http"
 	"time"
 
+	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
 
-	"simon-backend/internal/firestore"
+	fsClient "simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/models"
 )
 
 // ListSystems returns all pinned systems for the authenticated user
-func ListSystems(fs *firestore.Client) gin.HandlerFunc {
+func ListSystems(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_ = middleware.GetUID(c) // TODO: Use for filtering user systems
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
 
-		// TODO: Implement systems repository
-		// Query Firestore for systems where uid == authenticated user
-		// For now, return empty array
-		systems := []models.System{}
+		query := fs.DB.Collection("systems").
+			Where("uid", "==", uid).
+			OrderBy("created_at", firestore.Desc)
+
+		systems, err := querySystems(ctx, query)
+		if err != nil {
+			log.Printf("Error listing systems: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list systems"})
+			return
+		}
+
+		attachSessionTitles(ctx, fs, uid, systems)
+
+		c.JSON(http.StatusOK, systems)
+	}
+}
+
+// ListSessionSystems returns the systems produced by a session
+func ListSessionSystems(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+		sessionID := c.Param("id")
+
+		doc, err := fs.DB.Collection("sessions").Doc(sessionID).Get(ctx)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+
+		var session models.Session
+		if err := doc.DataTo(&session); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse session"})
+			return
+		}
+
+		if session.UID != uid {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+			return
+		}
+
+		query := fs.DB.Collection("systems").
+			Where("uid", "==", uid).
+			Where("source_session_id", "==", sessionID).
+			OrderBy("created_at", firestore.Desc)
+
+		systems, err := querySystems(ctx, query)
+		if err != nil {
+			log.Printf("Error listing session systems: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list systems"})
+			return
+		}
+
+		for i := range systems {
+			systems[i].SourceSessionTitle = session.Title
+		}
 
 		c.JSON(http.StatusOK, systems)
 	}
 }
 
-// CreateSystem creates a new pinned system
-func CreateSystem(fs *firestore.Client) gin.HandlerFunc {
+// CreateSystem creates a new pinned system, optionally linked to the session it came from
+func CreateSystem(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		_ = middleware.GetUID(c) // TODO: Use for ownership
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
 
 		var req models.System
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -48,10 +106,31 @@ func CreateSystem(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
-		// Create system
+		// Systems can only be linked to the caller's own sessions
+		var sourceTitle string
+		if req.SourceSessionID != "" {
+			doc, err := fs.DB.Collection("sessions").Doc(req.SourceSessionID).Get(ctx)
+			if err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+				return
+			}
+
+			var session models.Session
+			if err := doc.DataTo(&session); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse session"})
+				return
+			}
+
+			if session.UID != uid {
+				c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
+				return
+			}
+			sourceTitle = session.Title
+		}
+
 		system := models.System{
 			ID:                 uuid.New().String(),
-			UID:                "", // TODO: Set from uid
+			UID:                uid,
 			Title:              req.Title,
 			Checklist:          req.Checklist,
 			ScheduleSuggestion: req.ScheduleSuggestion,
@@ -60,15 +139,19 @@ func CreateSystem(fs *firestore.Client) gin.HandlerFunc {
 			CreatedAt:          time.Now(),
 		}
 
-		// TODO: Save to Firestore
-		// For now, just return the created system
+		if _, err := fs.DB.Collection("systems").Doc(system.ID).Set(ctx, system); err != nil {
+			log.Printf("Error creating system: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create system"})
+			return
+		}
 
+		system.SourceSessionTitle = sourceTitle
 		c.JSON(http.StatusCreated, system)
 	}
 }
 
 // GetSystem returns a specific system by ID
-func GetSystem(fs *firestore.Client) gin.HandlerFunc {
+func GetSystem(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		_ = middleware.GetUID(c) // TODO: Use for access control
 		systemID := c.Param("id")
@@ -85,7 +168,7 @@ func GetSystem(fs *firestore.Client) gin.HandlerFunc {
 }
 
 // DeleteSystem deletes a system by ID
-func DeleteSystem(fs *firestore.Client) gin.HandlerFunc {
+func DeleteSystem(fs *fsClient.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		_ = middleware.GetUID(c) // TODO: Use for ownership check
 		systemID := c.Param("id")
@@ -101,3 +184,68 @@ func DeleteSystem(fs *firestore.Client) gin.HandlerFunc {
 		c.JSON(http.StatusOK, gin.H{"message": "system deleted"})
 	}
 }
+
+// querySystems runs a systems query and decodes the results
+func querySystems(ctx context.Context, query firestore.Query) ([]models.System, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	systems := []models.System{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var system models.System
+		if err := doc.DataTo(&system); err != nil {
+			log.Printf("Error parsing system: %v", err)
+			continue
+		}
+		systems = append(systems, system)
+	}
+
+	return systems, nil
+}
+
+// attachSessionTitles joins source session titles onto systems with a single batch get
+func attachSessionTitles(ctx context.Context, fs *fsClient.Client, uid string, systems []models.System) {
+	seen := map[string]bool{}
+	var refs []*firestore.DocumentRef
+	for _, system := range systems {
+		if system.SourceSessionID == "" || seen[system.SourceSessionID] {
+			continue
+		}
+		seen[system.SourceSessionID] = true
+		refs = append(refs, fs.DB.Collection("sessions").Doc(system.SourceSessionID))
+	}
+
+	if len(refs) == 0 {
+		return
+	}
+
+	docs, err := fs.DB.GetAll(ctx, refs)
+	if err != nil {
+		log.Printf("Error fetching source sessions: %v", err)
+		return
+	}
+
+	titles := map[string]string{}
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+		var session models.Session
+		if err := doc.DataTo(&session); err != nil || session.UID != uid {
+			continue
+		}
+		titles[doc.Ref.ID] = session.Title
+	}
+
+	for i := range systems {
+		systems[i].SourceSessionTitle = titles[systems[i].SourceSessionID]
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
+)
+
+func systemsRouter(fs *fsClient.Client, uid string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), uid) })
+	r.GET("/v1/systems", ListSystems(fs))
+	r.GET("/v1/systems/:id", GetSystem(fs))
+	r.GET("/v1/sessions/:id/systems", ListSessionSystems(fs))
+	return r
+}
+
+func seedSystems(t *testing.T, fs *fsClient.Client) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+	sessions := []models.Session{
+		{ID: "s1", UID: "u1", Title: "Morning routine"},
+		{ID: "s2", UID: "u2", Title: "Someone else's session"},
+	}
+	for _, session := range sessions {
+		if _, err := fs.DB.Collection("sessions").Doc(session.ID).Set(ctx, session); err != nil {
+			t.Fatal(err)
+		}
+	}
+	systems := []models.System{
+		{ID: "sys_routine", UID: "u1", Title: "Wake up checklist", Checklist: []string{"water"}, SourceSessionID: "s1", CreatedAt: now.Add(-3 * time.Minute)},
+		{ID: "sys_foreign", UID: "u1", Title: "Borrowed", Checklist: []string{"copy"}, SourceSessionID: "s2", CreatedAt: now.Add(-2 * time.Minute)},
+		{ID: "sys_manual", UID: "u1", Title: "Manual", Checklist: []string{"write"}, CreatedAt: now.Add(-time.Minute)},
+		{ID: "sys_other", UID: "u2", Title: "Not mine", Checklist: []string{"x"}, SourceSessionID: "s2", CreatedAt: now},
+	}
+	for _, system := range systems {
+		if _, err := fs.DB.Collection("systems").Doc(system.ID).Set(ctx, system); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestListSystemsJoinsSessionTitles(t *testing.T) {
+	fs := firestoretest.New(t)
+	seedSystems(t, fs)
+
+	w := serve(systemsRouter(fs, "u1"), http.MethodGet, "/v1/systems")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var systems []models.System
+	if err := json.Unmarshal(w.Body.Bytes(), &systems); err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string]string{}
+	for _, system := range systems {
+		titles[system.ID] = system.SourceSessionTitle
+	}
+	want := map[string]string{
+		"sys_routine": "Morning routine",
+		// A session the user doesn't own never leaks its title
+		"sys_foreign": "",
+		"sys_manual":  "",
+	}
+	if len(titles) != len(want) {
+		t.Fatalf("listed %v, want only u1's systems", titles)
+	}
+	for id, title := range want {
+		if got, ok := titles[id]; !ok || got != title {
+			t.Errorf("%s source title = %q, want %q", id, got, title)
+		}
+	}
+	if systems[0].ID != "sys_manual" {
+		t.Errorf("first system = %s, want the newest", systems[0].ID)
+	}
+}
+
+func TestListSessionSystems(t *testing.T) {
+	fs := firestoretest.New(t)
+	seedSystems(t, fs)
+	r := systemsRouter(fs, "u1")
+
+	w := serve(r, http.MethodGet, "/v1/sessions/s1/systems")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var systems []models.System
+	if err := json.Unmarshal(w.Body.Bytes(), &systems); err != nil {
+		t.Fatal(err)
+	}
+	if len(systems) != 1 || systems[0].ID != "sys_routine" || systems[0].SourceSessionTitle != "Morning routine" {
+		t.Errorf("session systems = %+v, want only the routine system with its session title", systems)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{"someone else's session", "/v1/sessions/s2/systems", http.StatusForbidden},
+		{"missing session", "/v1/sessions/s_missing/systems", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serve(r, http.MethodGet, tt.path); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateSystemIsListed(t *testing.T) {
+	fs := firestoretest.New(t)
+	seedSystems(t, fs)
+
+	body := []byte(`{"title":"Evening wind-down","checklist":["dim lights","read"],"source_session_id":"s1","uid":"u2"}`)
+	w := serveAs("u1", CreateSystem(fs), http.MethodPost, "/v1/systems", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var created models.System
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == "" || created.UID != "u1" || created.SourceSessionTitle != "Morning routine" {
+		t.Errorf("created = %+v, want an ID, the caller's uid and the session title", created)
+	}
+
+	w = serve(systemsRouter(fs, "u1"), http.MethodGet, "/v1/sessions/s1/systems")
+	var systems []models.System
+	if err := json.Unmarshal(w.Body.Bytes(), &systems); err != nil {
+		t.Fatal(err)
+	}
+	if len(systems) != 2 || systems[0].ID != created.ID || systems[0].UID != "u1" || systems[0].SourceSessionID != "s1" {
+		t.Errorf("session systems = %+v, want the new system listed first", systems)
+	}
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"someone else's session", `{"title":"Copy","checklist":["x"],"source_session_id":"s2"}`, http.StatusForbidden},
+		{"missing session", `{"title":"Copy","checklist":["x"],"source_session_id":"s_missing"}`, http.StatusNotFound},
+		{"missing checklist", `{"title":"Copy"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := serveAs("u1", CreateSystem(fs), http.MethodPost, "/v1/systems", []byte(tt.body)); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/models"
+	"simon-backend/internal/tools"
+)
+
+// materializedCollections maps client tools to the collection mirroring what they created on device
+var materializedCollections = map[string]string{
+	"calendar_event_create":       "calendar_events",
+	"reminder_create":             "reminders",
+	"local_notification_schedule": "scheduled_notifications",
+}
+
+// materializeToolResult mirrors a successful client tool run into its collection. The document
+// ID is the tool run ID, so a retried result submission updates the same document instead of
+// inserting a duplicate; user-driven state (completed, cancelled) is left untouched on repeats.
+func (h *ToolsHandler) materializeToolResult(ctx context.Context, run models.ToolRun, output map[string]interface{}) error {
+	collection, ok := materializedCollections[run.ToolID]
+	if !ok {
+		return nil
+	}
+
+	var sessionID *string
+	if run.SessionID != "" {
+		sessionID = &run.SessionID
+	}
+	coachID := h.sessionCoachID(ctx, run.SessionID)
+	now := time.Now()
+
+	var doc interface{}
+	var repeatUpdates []firestore.Update
+	switch run.ToolID {
+	case "calendar_event_create":
+		event := models.CalendarEvent{
+			ID:              run.ID,
+			UID:             run.UID,
+			CoachID:         coachID,
+			SessionID:       sessionID,
+			ToolRunID:       run.ID,
+			Title:           inputString(run.Input, "title"),
+			StartISO:        inputString(run.Input, "start_iso"),
+			EndISO:          inputString(run.Input, "end_iso"),
+			Location:        inputStringPtr(run.Input, "location"),
+			Notes:           inputStringPtr(run.Input, "notes"),
+			Alarms:          inputAlarms(run.Input),
+			EventIdentifier: inputStringPtr(output, "event_id"),
+			NativeStatus:    "created",
+			Status:          "upcoming",
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		}
+		doc = event
+		repeatUpdates = []firestore.Update{{Path: "event_identifier", Value: event.EventIdentifier}}
+
+	case "reminder_create":
+		reminder := models.Reminder{
+			ID:                 run.ID,
+			UID:                run.UID,
+			CoachID:            coachID,
+			SessionID:          sessionID,
+			ToolRunID:          run.ID,
+			Title:              inputString(run.Input, "title"),
+			Notes:              inputStringPtr(run.Input, "notes"),
+			DueISO:             inputStringPtr(run.Input, "due_iso"),
+			Priority:           inputInt(run.Input, "priority"),
+			Alarms:             inputAlarms(run.Input),
+			ReminderIdentifier: inputStringPtr(output, "reminder_id"),
+			NativeStatus:       "created",
+			Status:             "pending",
+			CreatedAt:          now,
+			UpdatedAt:          now,
+		}
+		doc = reminder
+		repeatUpdates = []firestore.Update{{Path: "reminder_identifier", Value: reminder.ReminderIdentifier}}
+
+	case "local_notification_schedule":
+		notification := models.ScheduledNotification{
+			ID:                     run.ID,
+			UID:                    run.UID,
+			CoachID:                coachID,
+			SessionID:              sessionID,
+			ToolRunID:              run.ID,
+			Title:                  inputString(run.Input, "title"),
+			Body:                   inputString(run.Input, "body"),
+			Trigger:                quietTrigger(inputTrigger(run.Input), tools.UserPreferences(ctx, h.fs.DB, run.UID), now),
+			DeepLink:               inputDeepLink(run.Input),
+			NotificationIdentifier: inputString(output, "scheduled_id"),
+			NativeStatus:           "scheduled",
+			Status:                 "scheduled",
+			CreatedAt:              now,
+			UpdatedAt:              now,
+		}
+		doc = notification
+		repeatUpdates = []firestore.Update{{Path: "notification_identifier", Value: notification.NotificationIdentifier}}
+	}
+
+	ref := h.fs.DB.Collection(collection).Doc(run.ID)
+	return h.fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if _, err := tx.Get(ref); err != nil {
+			if !fsClient.IsNotFound(err) {
+				return fmt.Errorf("failed to read %s/%s: %w", collection, run.ID, err)
+			}
+			return tx.Create(ref, doc)
+		}
+
+		updates := append(repeatUpdates, firestore.Update{Path: "updated_at", Value: now})
+		return tx.Update(ref, updates)
+	})
+}
+
+// sessionCoachID returns the coach of a session, or "" if there is none
+func (h *ToolsHandler) sessionCoachID(ctx context.Context, sessionID string) string {
+	if sessionID == "" {
+		return ""
+	}
+	doc, err := h.fs.DB.Collection("sessions").Doc(sessionID).Get(ctx)
+	if err != nil {
+		return ""
+	}
+	var session models.Session
+	if err := doc.DataTo(&session); err != nil || session.CoachID == nil {
+		return ""
+	}
+	return *session.CoachID
+}
+
+// inputString reads a string field from a tool input or output map
+func inputString(values map[string]interface{}, key string) string {
+	s, _ := values[key].(string)
+	return s
+}
+
+// inputStringPtr reads an optional string field, returning nil when absent or empty
+func inputStringPtr(values map[string]interface{}, key string) *string {
+	if s := inputString(values, key); s != "" {
+		return &s
+	}
+	return nil
+}
+
+// inputInt reads a numeric field (JSON numbers decode as float64)
+func inputInt(values map[string]interface{}, key string) int {
+	switch n := values[key].(type) {
+	case float64:
+		return int(n)
+	case int:
+		return n
+	case int64:
+		return int(n)
+	}
+	return 0
+}
+
+// inputAlarms converts the tool's alarms ([{lead_minutes}]) to stored alarms; the input was
+// validated when the tool ran, so alarms that don't normalize are dropped
+func inputAlarms(input map[string]interface{}) []models.EventAlarm {
+	alarms, err := tools.NormalizeAlarms(input)
+	if err != nil {
+		return nil
+	}
+	return alarms
+}
+
+// inputTrigger reads a notification trigger from the tool input
+func inputTrigger(input map[string]interface{}) models.NotificationTrigger {
+	raw, _ := input["trigger"].(map[string]interface{})
+	trigger := models.NotificationTrigger{
+		Kind:      inputString(raw, "kind"),
+		FireAtISO: inputStringPtr(raw, "fire_at_iso"),
+	}
+	if _, ok := raw["delay_sec"]; ok {
+		delay := inputInt(raw, "delay_sec")
+		trigger.DelaySec = &delay
+	}
+	return trigger
+}
+
+// quietTrigger shifts a notification trigger out of the user's quiet hours, as check-ins are.
+// A delay is measured from now, the time the result is recorded.
+func quietTrigger(trigger models.NotificationTrigger, prefs models.Preferences, now time.Time) models.NotificationTrigger {
+	switch {
+	case trigger.FireAtISO != nil:
+		fireAt, err := time.Parse(time.RFC3339, *trigger.FireAtISO)
+		if err != nil {
+			return trigger
+		}
+		if shifted := prefs.ApplyQuietHours(fireAt); !shifted.Equal(fireAt) {
+			iso := shifted.Format(time.RFC3339)
+			trigger.FireAtISO = &iso
+		}
+	case trigger.DelaySec != nil:
+		fireAt := now.Add(time.Duration(*trigger.DelaySec) * time.Second)
+		if shifted := prefs.ApplyQuietHours(fireAt); !shifted.Equal(fireAt) {
+			delay := int(shifted.Sub(now) / time.Second)
+			trigger.DelaySec = &delay
+		}
+	}
+	return trigger
+}
+
+// inputDeepLink reads an optional deep link from the tool input
+func inputDeepLink(input map[string]interface{}) *models.DeepLink {
+	raw, _ := input["deep_link"].(map[string]interface{})
+	if url := inputString(raw, "url"); url != "" {
+		return &models.DeepLink{URL: url}
+	}
+	return nil
+}
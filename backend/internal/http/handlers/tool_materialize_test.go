@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"simon-backend/internal/entitlements"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/logger"
+	"simon-backend/internal/models"
+	"simon-backend/internal/tools"
+)
+
+func TestHandleResultMaterializesOnce(t *testing.T) {
+	tests := []struct {
+		toolID     string
+		collection string
+		input      map[string]interface{}
+		outputKey  string
+		identifier string
+	}{
+		{"reminder_create", "reminders", map[string]interface{}{"title": "Call the dentist", "alarms": []interface{}{map[string]interface{}{"lead_minutes": float64(10)}}}, "reminder_id", "reminder_identifier"},
+		{"calendar_event_create", "calendar_events", map[string]interface{}{"title": "Long run", "start_iso": "2026-04-19T07:00:00Z", "end_iso": "2026-04-19T08:30:00Z"}, "event_id", "event_identifier"},
+		{"local_notification_schedule", "scheduled_notifications", map[string]interface{}{"title": "Stretch", "body": "Five minutes", "trigger": map[string]interface{}{"kind": "delay", "delay_sec": float64(600)}}, "scheduled_id", "notification_identifier"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.toolID, func(t *testing.T) {
+			ctx := context.Background()
+			fs := firestoretest.New(t)
+			h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+			runID := "run-" + tt.toolID
+			if _, err := fs.DB.Collection("tool_runs").Doc(runID).Set(ctx, models.ToolRun{
+				ID:             runID,
+				UID:            "u1",
+				ToolID:         tt.toolID,
+				Input:          tt.input,
+				Status:         "approved",
+				ExecutionToken: "token-1",
+				CreatedAt:      time.Now(),
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			submit := func(nativeID string) {
+				t.Helper()
+				body, _ := json.Marshal(ToolResultRequest{
+					ToolRunID:      runID,
+					ExecutionToken: "token-1",
+					Status:         "executed",
+					Output:         map[string]interface{}{tt.outputKey: nativeID},
+				})
+				if w := serveAs("u1", h.HandleResult, http.MethodPost, "/v1/tools/result", body); w.Code != http.StatusOK {
+					t.Fatalf("status = %d, body %s", w.Code, w.Body)
+				}
+			}
+
+			submit("native-1")
+			// The user acts on it before the client retries the submission
+			if _, err := fs.DB.Collection(tt.collection).Doc(runID).Update(ctx, []firestore.Update{{Path: "status", Value: "cancelled"}}); err != nil {
+				t.Fatal(err)
+			}
+			submit("native-2")
+
+			docs, err := fs.DB.Collection(tt.collection).Documents(ctx).GetAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) != 1 {
+				t.Fatalf("%d %s documents after two submissions, want 1", len(docs), tt.collection)
+			}
+			data := docs[0].Data()
+			if docs[0].Ref.ID != runID || data["tool_run_id"] != runID || data["uid"] != "u1" {
+				t.Errorf("document %s = %v, want it keyed by the tool run", docs[0].Ref.ID, data)
+			}
+			if data[tt.identifier] != "native-2" {
+				t.Errorf("%s = %v, want the retried submission's id", tt.identifier, data[tt.identifier])
+			}
+			if data["status"] != "cancelled" {
+				t.Errorf("status = %v, want the user's change kept on retry", data["status"])
+			}
+		})
+	}
+}
+
+func TestHandleResultFailedDoesNotMaterialize(t *testing.T) {
+	ctx := context.Background()
+	fs, server := firestoretest.NewWithServer(t)
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+	if _, err := fs.DB.Collection("tool_runs").Doc("run-1").Set(ctx, models.ToolRun{
+		ID: "run-1", UID: "u1", ToolID: "reminder_create", Input: map[string]interface{}{"title": "x"}, Status: "approved", ExecutionToken: "token-1",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte(`{"tool_run_id":"run-1","execution_token":"token-1","status":"failed","error":"permission denied"}`)
+	if w := serveAs("u1", h.HandleResult, http.MethodPost, "/v1/tools/result", body); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if n := server.Len(); n != 1 {
+		t.Errorf("stored %d documents, want only the tool run", n)
+	}
+}
+
+func TestQuietTrigger(t *testing.T) {
+	prefs := models.Preferences{
+		Timezone:   "Europe/Istanbul",
+		QuietHours: &models.QuietHours{Start: "22:00", End: "07:00"},
+	}
+	now := time.Date(2026, 3, 10, 18, 0, 0, 0, time.UTC) // 21:00 in Istanbul
+
+	iso := func(s string) *string { return &s }
+	delay := func(d int) *int { return &d }
+
+	tests := []struct {
+		name      string
+		trigger   models.NotificationTrigger
+		wantFire  *string
+		wantDelay *int
+	}{
+		{
+			name:     "fire time inside quiet hours moves to the window end",
+			trigger:  models.NotificationTrigger{Kind: "at_datetime", FireAtISO: iso("2026-03-10T23:30:00+03:00")},
+			wantFire: iso("2026-03-11T07:00:00+03:00"),
+		},
+		{
+			name:     "fire time outside quiet hours is kept",
+			trigger:  models.NotificationTrigger{Kind: "at_datetime", FireAtISO: iso("2026-03-10T20:00:00+03:00")},
+			wantFire: iso("2026-03-10T20:00:00+03:00"),
+		},
+		{
+			name:      "delay landing inside quiet hours is extended",
+			trigger:   models.NotificationTrigger{Kind: "after_delay", DelaySec: delay(2 * 3600)},
+			wantDelay: delay(10 * 3600),
+		},
+		{
+			name:      "delay before quiet hours is kept",
+			trigger:   models.NotificationTrigger{Kind: "after_delay", DelaySec: delay(1800)},
+			wantDelay: delay(1800),
+		},
+		{
+			name:     "unparseable fire time is left alone",
+			trigger:  models.NotificationTrigger{Kind: "at_datetime", FireAtISO: iso("tonight")},
+			wantFire: iso("tonight"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := quietTrigger(tt.trigger, prefs, now)
+			if tt.wantFire != nil && (got.FireAtISO == nil || *got.FireAtISO != *tt.wantFire) {
+				t.Errorf("fire_at_iso = %v, want %s", deref(got.FireAtISO), *tt.wantFire)
+			}
+			if tt.wantDelay != nil && (got.DelaySec == nil || *got.DelaySec != *tt.wantDelay) {
+				t.Errorf("delay_sec = %v, want %d", got.DelaySec, *tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestQuietTriggerWithoutQuietHours(t *testing.T) {
+	fireAt := "2026-03-10T23:30:00Z"
+	got := quietTrigger(models.NotificationTrigger{Kind: "at_datetime", FireAtISO: &fireAt}, models.Preferences{}, time.Now())
+	if *got.FireAtISO != fireAt {
+		t.Errorf("fire_at_iso = %s, want unchanged %s", *got.FireAtISO, fireAt)
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return "<nil>"
+	}
+	return *s
+}
@@ -3,31 +3,88 @@ package handlers
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"simon-backend/internal/firestore"
+	"simon-backend/internal/audit"
+	"simon-backend/internal/entitlements"
+	fsClient "simon-backend/internal/firestore"
+	geminiClient "simon-backend/internal/gemini"
 	"simon-backend/internal/logger"
+	"simon-backend/internal/metrics"
 	"simon-backend/internal/models"
 	"simon-backend/internal/tools"
 )
 
+// MemoryToolService is the memory backend used by memory_* server tools
+type MemoryToolService interface {
+	Read(ctx context.Context, req tools.MemoryReadRequest) (*tools.MemoryReadResponse, error)
+	Write(ctx context.Context, req tools.MemoryWriteRequest) error
+}
+
+// PlanToolService is the plan backend used by plan_* server tools
+type PlanToolService interface {
+	Create(ctx context.Context, req tools.PlanCreateRequest) (*tools.PlanCreateResponse, error)
+	Update(ctx context.Context, req tools.PlanUpdateRequest) (*tools.PlanUpdateResponse, error)
+	ListActive(ctx context.Context, req tools.PlanListRequest) (*tools.PlanListResponse, error)
+}
+
+// CheckinToolService is the check-in backend used by checkin_* server tools and the check-in endpoints
+type CheckinToolService interface {
+	Schedule(ctx context.Context, req tools.CheckinScheduleRequest) (*tools.CheckinScheduleResponse, error)
+	List(ctx context.Context, req tools.CheckinListRequest) (*tools.CheckinListResponse, error)
+	Update(ctx context.Context, req tools.CheckinUpdateRequest) (*tools.CheckinUpdateResponse, error)
+	Delete(ctx context.Context, uid, checkinID string) error
+	Acknowledge(ctx context.Context, uid, checkinID string) error
+}
+
+// ToolServices holds the services server tools execute against, constructed once and shared
+type ToolServices struct {
+	Memory   MemoryToolService
+	Plans    PlanToolService
+	Checkins CheckinToolService
+}
+
+// DefaultToolServices builds the Firestore-backed tool services.
+// When gm is non-nil, memory search can also rank by embeddings.
+func DefaultToolServices(fs *fsClient.Client, gm *geminiClient.Client) ToolServices {
+	memory := tools.NewMemoryService(fs.DB)
+	if gm != nil {
+		memory.WithEmbedder(gm)
+	}
+
+	return ToolServices{
+		Memory:   memory,
+		Plans:    tools.NewPlanService(fs.DB),
+		Checkins: tools.NewCheckinService(fs.DB),
+	}
+}
+
 // ToolsHandler handles tool execution endpoints
 type ToolsHandler struct {
-	fs       *firestore.Client
+	fs       *fsClient.Client
 	registry *tools.Registry
+	services ToolServices
+	policy   entitlements.Policy
 	log      *logger.Logger
 }
 
 // NewToolsHandler creates a new tools handler
-func NewToolsHandler(fs *firestore.Client, registry *tools.Registry, log *logger.Logger) *ToolsHandler {
+func NewToolsHandler(fs *fsClient.Client, registry *tools.Registry, services ToolServices, policy entitlements.Policy, log *logger.Logger) *ToolsHandler {
 	return &ToolsHandler{
 		fs:       fs,
 		registry: registry,
+		services: services,
+		policy:   policy,
 		log:      log,
 	}
 }
@@ -37,6 +94,9 @@ type ToolExecuteRequest struct {
 	ToolID    string                 `json:"tool_id"`
 	SessionID string                 `json:"session_id,omitempty"`
 	Input     map[string]interface{} `json:"input"`
+	Reason    string                 `json:"reason,omitempty"`
+	// IdempotencyKey makes retried server tool executions return the first successful result
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // ToolExecuteResponse represents a tool execution response
@@ -45,15 +105,59 @@ type ToolExecuteResponse struct {
 	Status         string                 `json:"status"`
 	ExecutionToken string                 `json:"execution_token,omitempty"`
 	Output         map[string]interface{} `json:"output,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+	Replayed       bool                   `json:"replayed,omitempty"` // true when served from an earlier run with the same idempotency key
+}
+
+// maxBatchItems bounds the number of tools executed in a single batch request
+const maxBatchItems = 20
+
+// ToolBatchItem is a single tool invocation within a batch
+type ToolBatchItem struct {
+	ToolID string                 `json:"tool_id"`
+	Input  map[string]interface{} `json:"input"`
+}
+
+// ToolBatchExecuteRequest represents a batch tool execution request
+type ToolBatchExecuteRequest struct {
+	SessionID string          `json:"session_id,omitempty"`
+	Items     []ToolBatchItem `json:"items"`
+}
+
+// ToolBatchItemResult reports the outcome of one batch item
+type ToolBatchItemResult struct {
+	Index          int                    `json:"index"`
+	ToolID         string                 `json:"tool_id"`
+	Status         string                 `json:"status"` // "pending" | "executed" | "failed"
+	ToolRunID      string                 `json:"tool_run_id,omitempty"`
+	ExecutionToken string                 `json:"execution_token,omitempty"`
+	Output         map[string]interface{} `json:"output,omitempty"`
+	Error          string                 `json:"error,omitempty"`
+	Replayed       bool                   `json:"replayed,omitempty"` // true when served from an earlier run with the same idempotency key
+}
+
+// ToolBatchExecuteResponse represents a batch tool execution response
+type ToolBatchExecuteResponse struct {
+	Items          []ToolBatchItemResult `json:"items"`
+	PartialSuccess bool                  `json:"partial_success"`
 }
 
 // ToolResultRequest represents a tool result submission
 type ToolResultRequest struct {
 	ToolRunID      string                 `json:"tool_run_id"`
 	ExecutionToken string                 `json:"execution_token"`
-	Status         string                 `json:"status"` // "executed" | "failed"
+	Status         string                 `json:"status"` // "executed" | "partial" | "failed"
 	Output         map[string]interface{} `json:"output,omitempty"`
 	Error          string                 `json:"error,omitempty"`
+	// FailedSteps names the parts of a partial result that didn't succeed (e.g. "alarm")
+	FailedSteps []string `json:"failed_steps,omitempty"`
+}
+
+// validResultStatuses are the statuses a client may report for a tool run
+var validResultStatuses = map[string]bool{
+	"executed": true,
+	"partial":  true,
+	"failed":   true,
 }
 
 // ToolResultResponse represents a tool result response
@@ -73,38 +177,140 @@ func (h *ToolsHandler) HandleExecute(c *gin.Context) {
 		return
 	}
 
+	response, execErr := h.executeTool(ctx, uid, req)
+	if execErr != nil {
+		c.JSON(execErr.httpStatus, gin.H{"error": execErr.message})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleExecuteBatch handles POST /v1/tools/execute-batch.
+// Items run independently; a failing item is reported without failing the request.
+func (h *ToolsHandler) HandleExecuteBatch(c *gin.Context) {
+	ctx := c.Request.Context()
+	uid := c.GetString("uid")
+
+	var req ToolBatchExecuteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.log.Error(ctx, "Failed to decode tool batch request", err, nil)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	if len(req.Items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "items is required"})
+		return
+	}
+	if len(req.Items) > maxBatchItems {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many items (max %d)", maxBatchItems)})
+		return
+	}
+
+	results := make([]ToolBatchItemResult, 0, len(req.Items))
+	for i, item := range req.Items {
+		result := ToolBatchItemResult{Index: i, ToolID: item.ToolID}
+
+		response, execErr := h.executeTool(ctx, uid, ToolExecuteRequest{
+			ToolID:    item.ToolID,
+			SessionID: req.SessionID,
+			Input:     item.Input,
+		})
+		if execErr != nil {
+			result.Status = "failed"
+			result.Error = execErr.message
+		} else {
+			result.Status = response.Status
+			result.ToolRunID = response.ToolRunID
+			result.ExecutionToken = response.ExecutionToken
+			result.Output = response.Output
+			result.Error = response.Error
+		}
+
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, ToolBatchExecuteResponse{
+		Items:          results,
+		PartialSuccess: isPartialSuccess(results),
+	})
+}
+
+// isPartialSuccess reports whether a batch had both failed and non-failed items
+func isPartialSuccess(results []ToolBatchItemResult) bool {
+	failed := 0
+	for _, result := range results {
+		if result.Status == "failed" {
+			failed++
+		}
+	}
+	return failed > 0 && failed < len(results)
+}
+
+// withServerInputs copies input, setting the uid field (if the tool has one) to the caller and a
+// missing idempotency_key to the request's key
+func withServerInputs(tool tools.Tool, input map[string]interface{}, uid, idempotencyKey string) map[string]interface{} {
+	completed := make(map[string]interface{}, len(input)+2)
+	for key, value := range input {
+		completed[key] = value
+	}
+	properties, _ := tool.InputSchema["properties"].(map[string]interface{})
+	if _, ok := properties["uid"]; ok {
+		completed["uid"] = uid
+	}
+	if _, ok := completed["idempotency_key"]; !ok && idempotencyKey != "" {
+		completed["idempotency_key"] = idempotencyKey
+	}
+	return completed
+}
+
+// toolExecError is a request-level failure from executeTool
+type toolExecError struct {
+	httpStatus int
+	message    string
+}
+
+// executeTool validates, records, and (for server tools) runs a single tool
+func (h *ToolsHandler) executeTool(ctx context.Context, uid string, req ToolExecuteRequest) (*ToolExecuteResponse, *toolExecError) {
 	// Get tool from registry
 	tool, err := h.registry.GetTool(req.ToolID)
 	if err != nil {
 		h.log.Error(ctx, "Tool not found", err, map[string]interface{}{"tool_id": req.ToolID})
-		c.JSON(http.StatusNotFound, gin.H{"error": "Tool not found"})
-		return
+		return nil, &toolExecError{http.StatusNotFound, "Tool not found"}
 	}
 
-	// Validate input against schema
+	// The session's coach must allow this tool
+	if execErr := h.checkCoachAllowsTool(ctx, uid, req.SessionID, req.ToolID); execErr != nil {
+		return nil, execErr
+	}
+
+	// Validate input against schema; the server supplies the caller's uid and the request's idempotency key
+	req.Input = withServerInputs(tool, req.Input, uid, req.IdempotencyKey)
 	if err := h.registry.ValidateInput(req.ToolID, req.Input); err != nil {
 		h.log.Error(ctx, "Tool input validation failed", err, map[string]interface{}{"tool_id": req.ToolID})
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid input: %v", err)})
-		return
+		return nil, &toolExecError{http.StatusBadRequest, fmt.Sprintf("Invalid input: %v", err)}
 	}
 
 	// Check entitlements (basic check - can be enhanced with RevenueCat)
 	if err := h.checkEntitlements(ctx, uid, req.ToolID); err != nil {
 		h.log.Error(ctx, "Entitlement check failed", err, map[string]interface{}{"uid": uid, "tool_id": req.ToolID})
-		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient entitlements"})
-		return
+		return nil, &toolExecError{http.StatusForbidden, "Insufficient entitlements"}
 	}
 
 	// Check rate limits (basic check - can be enhanced)
 	if err := h.checkRateLimit(ctx, uid, req.ToolID); err != nil {
 		h.log.Error(ctx, "Rate limit exceeded", err, map[string]interface{}{"uid": uid, "tool_id": req.ToolID})
-		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
-		return
+		return nil, &toolExecError{http.StatusTooManyRequests, "Rate limit exceeded"}
 	}
 
 	// Create tool run record
 	toolRunID := generateID("toolrun")
 	executionToken := generateToken()
+	idempotent := req.IdempotencyKey != "" && tool.Owner == tools.ToolOwnerGo
+	if idempotent {
+		toolRunID = idempotentToolRunID(uid, req.ToolID, req.IdempotencyKey)
+	}
 
 	toolRun := models.ToolRun{
 		ID:             toolRunID,
@@ -112,15 +318,36 @@ func (h *ToolsHandler) HandleExecute(c *gin.Context) {
 		ToolID:         req.ToolID,
 		SessionID:      req.SessionID,
 		Input:          req.Input,
+		Reason:         req.Reason,
+		IdempotencyKey: req.IdempotencyKey,
 		Status:         "pending",
 		ExecutionToken: executionToken,
 		CreatedAt:      models.Now(),
 		UpdatedAt:      models.Now(),
 	}
 
+	// Claim the idempotency key before executing so concurrent retries can't both run
+	if idempotent {
+		previous, execErr := h.claimIdempotentRun(ctx, toolRun)
+		if execErr != nil {
+			return nil, execErr
+		}
+		if previous != nil {
+			return &ToolExecuteResponse{
+				ToolRunID: previous.ID,
+				Status:    previous.Status,
+				Output:    previous.Output,
+				Replayed:  true,
+			}, nil
+		}
+	}
+
 	// For server tools, execute immediately
+	var serverErr error
 	if tool.Owner == tools.ToolOwnerGo {
-		output, err := h.executeServerTool(ctx, tool, req.Input, uid)
+		output, err := h.executeServerTool(ctx, tool, req.Input, uid, req.SessionID)
+		serverErr = err
+		metrics.Get().RecordToolExecution(req.ToolID, err == nil)
 		if err != nil {
 			toolRun.Status = "failed"
 			toolRun.Error = err.Error()
@@ -128,18 +355,25 @@ func (h *ToolsHandler) HandleExecute(c *gin.Context) {
 		} else {
 			toolRun.Status = "executed"
 			toolRun.Output = output
+			if !tool.ReadOnly {
+				h.recordToolAudit(ctx, uid, toolRunID)
+			}
 		}
 	}
 
 	// Save tool run
 	if _, err := h.fs.DB.Collection("tool_runs").Doc(toolRunID).Set(ctx, toolRun); err != nil {
 		h.log.Error(ctx, "Failed to save tool run", err, nil)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+		return nil, &toolExecError{http.StatusInternalServerError, "Internal server error"}
+	}
+
+	// Surface caller mistakes (missing, foreign, or invalid resources) as client errors
+	if status, ok := toolErrorStatus(serverErr); ok {
+		return nil, &toolExecError{status, serverErr.Error()}
 	}
 
 	// Build response
-	response := ToolExecuteResponse{
+	response := &ToolExecuteResponse{
 		ToolRunID: toolRunID,
 		Status:    toolRun.Status,
 	}
@@ -152,9 +386,91 @@ func (h *ToolsHandler) HandleExecute(c *gin.Context) {
 	// For server tools, return output
 	if tool.Owner == tools.ToolOwnerGo {
 		response.Output = toolRun.Output
+		response.Error = toolRun.Error
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response, nil
+}
+
+// recordToolAudit logs a server tool run that changed user data
+func (h *ToolsHandler) recordToolAudit(ctx context.Context, uid, toolRunID string) {
+	entry := audit.NewEntry(ctx, uid, "tool_run", audit.ActionExecute, toolRunID)
+	if err := audit.Record(ctx, h.fs, entry); err != nil {
+		h.log.Warning(ctx, "Failed to record tool audit", map[string]interface{}{"tool_run_id": toolRunID, "error": err.Error()})
+	}
+}
+
+// idempotentToolRunID derives the tool run document ID for an idempotency key, scoped to user and tool
+func idempotentToolRunID(uid, toolID, key string) string {
+	sum := sha256.Sum256([]byte(uid + "|" + toolID + "|" + key))
+	return "toolrun_" + hex.EncodeToString(sum[:12])
+}
+
+// claimIdempotentRun reserves run.ID for this execution. It returns the earlier run when that
+// run already succeeded, and a 409 while another request holding the same key is still running.
+// A failed or abandoned earlier run is taken over so the client can retry with the same key.
+func (h *ToolsHandler) claimIdempotentRun(ctx context.Context, run models.ToolRun) (*models.ToolRun, *toolExecError) {
+	ref := h.fs.DB.Collection("tool_runs").Doc(run.ID)
+	claim := run
+	claim.Status = "running"
+
+	var previous *models.ToolRun
+	err := h.fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		previous = nil
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if fsClient.IsNotFound(err) {
+				return tx.Create(ref, claim)
+			}
+			return err
+		}
+
+		var existing models.ToolRun
+		if err := doc.DataTo(&existing); err != nil {
+			return err
+		}
+		switch {
+		case existing.Status == "executed":
+			previous = &existing
+			return nil
+		case existing.Status == "failed", time.Since(existing.UpdatedAt) > idempotencyClaimTTL:
+			// Retry after a failure, or take over a claim abandoned by a crashed request
+			return tx.Set(ref, claim)
+		default:
+			return errIdempotencyInProgress
+		}
+	})
+
+	switch {
+	case err == nil:
+		return previous, nil
+	case errors.Is(err, errIdempotencyInProgress):
+		return nil, &toolExecError{http.StatusConflict, "A request with this idempotency key is already in progress"}
+	default:
+		h.log.Error(ctx, "Failed to claim idempotency key", err, map[string]interface{}{"tool_run_id": run.ID})
+		return nil, &toolExecError{http.StatusInternalServerError, "Internal server error"}
+	}
+}
+
+// idempotencyClaimTTL is how long a running claim blocks retries before it's considered abandoned
+const idempotencyClaimTTL = 2 * time.Minute
+
+var errIdempotencyInProgress = errors.New("idempotent run in progress")
+
+// toolErrorStatus maps typed tool service errors to HTTP statuses
+func toolErrorStatus(err error) (int, bool) {
+	switch {
+	case err == nil:
+		return 0, false
+	case errors.Is(err, tools.ErrNotFound):
+		return http.StatusNotFound, true
+	case errors.Is(err, tools.ErrUnauthorized):
+		return http.StatusForbidden, true
+	case errors.Is(err, tools.ErrValidation):
+		return http.StatusBadRequest, true
+	}
+	return 0, false
 }
 
 // HandleResult handles POST /v1/tools/result
@@ -169,6 +485,11 @@ func (h *ToolsHandler) HandleResult(c *gin.Context) {
 		return
 	}
 
+	if !validResultStatuses[req.Status] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of: executed, partial, failed"})
+		return
+	}
+
 	// Get tool run
 	toolRunDoc, err := h.fs.DB.Collection("tool_runs").Doc(req.ToolRunID).Get(ctx)
 	if err != nil {
@@ -199,24 +520,36 @@ func (h *ToolsHandler) HandleResult(c *gin.Context) {
 	}
 
 	// Update tool run
-	updates := map[string]interface{}{
-		"status":     req.Status,
-		"updated_at": models.Now(),
+	updates := []firestore.Update{
+		{Path: "status", Value: req.Status},
+		{Path: "updated_at", Value: models.Now()},
 	}
 
 	if req.Output != nil {
-		updates["output"] = req.Output
+		updates = append(updates, firestore.Update{Path: "output", Value: req.Output})
 	}
 	if req.Error != "" {
-		updates["error"] = req.Error
+		updates = append(updates, firestore.Update{Path: "error", Value: req.Error})
+	}
+	if len(req.FailedSteps) > 0 {
+		updates = append(updates, firestore.Update{Path: "failed_steps", Value: req.FailedSteps})
 	}
 
-	if _, err := h.fs.DB.Collection("tool_runs").Doc(req.ToolRunID).Set(ctx, updates); err != nil {
+	if _, err := h.fs.DB.Collection("tool_runs").Doc(req.ToolRunID).Update(ctx, updates); err != nil {
 		h.log.Error(ctx, "Failed to update tool run", err, nil)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
 
+	// Mirror what the client created so it shows up in events, reminders and notifications
+	if req.Status == "executed" || req.Status == "partial" {
+		if err := h.materializeToolResult(ctx, toolRun, req.Output); err != nil {
+			h.log.Error(ctx, "Failed to materialize tool result", err, map[string]interface{}{"tool_run_id": req.ToolRunID})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			return
+		}
+	}
+
 	response := ToolResultResponse{
 		Status: "updated",
 	}
@@ -224,20 +557,145 @@ func (h *ToolsHandler) HandleResult(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// maxToolRunsLimit caps the page size HandleListRuns returns
+const maxToolRunsLimit = 100
+
+// HandleListRuns handles GET /v1/tools/runs
+// Query params: status, tool_id, session_id (all optional), limit (default 50, max 100), offset (default 0)
+func (h *ToolsHandler) HandleListRuns(c *gin.Context) {
+	ctx := c.Request.Context()
+	uid := c.GetString("uid")
+
+	// Parse limit with default 50, capped so one request can't pull the whole history
+	limit := 50
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = min(parsedLimit, maxToolRunsLimit)
+		}
+	}
+
+	// Parse offset with default 0
+	offset, ok := parseOffset(c)
+	if !ok {
+		return
+	}
+
+	// Ownership is enforced by always filtering on the caller's uid
+	query := h.fs.DB.Collection("tool_runs").Where("uid", "==", uid)
+
+	// Apply optional filters
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status", "==", status)
+	}
+	if toolID := c.Query("tool_id"); toolID != "" {
+		query = query.Where("tool_id", "==", toolID)
+	}
+	if sessionID := c.Query("session_id"); sessionID != "" {
+		query = query.Where("session_id", "==", sessionID)
+	}
+
+	query = query.OrderBy("created_at", firestore.Desc).Limit(limit)
+	if offset > 0 {
+		query = query.Offset(offset)
+	}
+
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		h.log.Error(ctx, "Failed to list tool runs", err, map[string]interface{}{"uid": uid})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list tool runs"})
+		return
+	}
+
+	toolRuns := []models.ToolRun{}
+	for _, doc := range docs {
+		var toolRun models.ToolRun
+		if err := doc.DataTo(&toolRun); err != nil {
+			h.log.Error(ctx, "Failed to parse tool run", err, map[string]interface{}{"doc_id": doc.Ref.ID})
+			continue
+		}
+		// Execution tokens are only handed out by HandleExecute
+		toolRun.ExecutionToken = ""
+		toolRuns = append(toolRuns, toolRun)
+	}
+
+	c.JSON(http.StatusOK, toolRuns)
+}
+
+// pendingToolRunWindow is how far back HandlePending looks for unconfirmed tool runs
+const pendingToolRunWindow = 24 * time.Hour
+
+// PendingToolRun is a client tool awaiting user confirmation; it never carries the execution token
+type PendingToolRun struct {
+	ToolRunID string                 `json:"tool_run_id"`
+	ToolID    string                 `json:"tool_id"`
+	SessionID string                 `json:"session_id,omitempty"`
+	Reason    string                 `json:"reason,omitempty"`
+	Payload   map[string]interface{} `json:"payload"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// HandlePending handles GET /v1/tools/pending, listing the user's recent client tool runs
+// still awaiting confirmation
+func (h *ToolsHandler) HandlePending(c *gin.Context) {
+	ctx := c.Request.Context()
+	uid := c.GetString("uid")
+
+	docs, err := h.fs.DB.Collection("tool_runs").
+		Where("uid", "==", uid).
+		Where("status", "==", "pending").
+		Where("created_at", ">=", time.Now().Add(-pendingToolRunWindow)).
+		OrderBy("created_at", firestore.Desc).
+		Limit(50).
+		Documents(ctx).GetAll()
+	if err != nil {
+		h.log.Error(ctx, "Failed to list pending tool runs", err, map[string]interface{}{"uid": uid})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	pending := []PendingToolRun{}
+	for _, doc := range docs {
+		var toolRun models.ToolRun
+		if err := doc.DataTo(&toolRun); err != nil {
+			h.log.Error(ctx, "Failed to parse tool run", err, map[string]interface{}{"doc_id": doc.Ref.ID})
+			continue
+		}
+
+		// Only client tools wait on the user; server tools execute immediately
+		tool, err := h.registry.GetTool(toolRun.ToolID)
+		if err != nil || tool.Owner != tools.ToolOwnerIOS {
+			continue
+		}
+
+		pending = append(pending, PendingToolRun{
+			ToolRunID: toolRun.ID,
+			ToolID:    toolRun.ToolID,
+			SessionID: toolRun.SessionID,
+			Reason:    toolRun.Reason,
+			Payload:   toolRun.Input,
+			CreatedAt: toolRun.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tool_runs": pending})
+}
+
 // executeServerTool executes a server-side tool
-func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, input map[string]interface{}, uid string) (map[string]interface{}, error) {
+func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, input map[string]interface{}, uid, sessionID string) (map[string]interface{}, error) {
 	switch tool.ID {
 	case "memory_read":
-		memoryService := tools.NewMemoryService(h.fs.DB)
+		memoryService := h.services.Memory
 		
 		// Parse input
 		query, _ := input["query"].(string)
 		limit, _ := input["limit"].(float64)
+		mode, _ := input["mode"].(string)
 		
 		req := tools.MemoryReadRequest{
 			UID:   uid,
 			Query: query,
 			Limit: int(limit),
+			Mode:  mode,
 		}
 		
 		resp, err := memoryService.Read(ctx, req)
@@ -252,7 +710,7 @@ func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, i
 		return output, nil
 
 	case "memory_write":
-		memoryService := tools.NewMemoryService(h.fs.DB)
+		memoryService := h.services.Memory
 		
 		// Parse input
 		patchData, _ := input["patch"].(map[string]interface{})
@@ -275,7 +733,7 @@ func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, i
 		return map[string]interface{}{"status": "written"}, nil
 
 	case "plan_create":
-		planService := tools.NewPlanService(h.fs.DB)
+		planService := h.services.Plans
 		
 		// Parse input
 		coachID, _ := input["coach_id"].(string)
@@ -287,9 +745,11 @@ func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, i
 		}
 		
 		req := tools.PlanCreateRequest{
-			UID:     uid,
-			CoachID: coachID,
-			Plan:    plan,
+			UID:            uid,
+			CoachID:        coachID,
+			SessionID:      sessionID,
+			Plan:           plan,
+			IdempotencyKey: tools.PlanIdempotencyKey(sessionID, plan.Objective),
 		}
 		
 		resp, err := planService.Create(ctx, req)
@@ -303,7 +763,7 @@ func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, i
 		}, nil
 
 	case "plan_update":
-		planService := tools.NewPlanService(h.fs.DB)
+		planService := h.services.Plans
 		
 		// Parse input
 		planID, _ := input["plan_id"].(string)
@@ -323,7 +783,7 @@ func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, i
 		return map[string]interface{}{"status": resp.Status}, nil
 
 	case "plan_list_active":
-		planService := tools.NewPlanService(h.fs.DB)
+		planService := h.services.Plans
 		
 		// Parse input
 		limit, _ := input["limit"].(float64)
@@ -341,7 +801,7 @@ func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, i
 		return map[string]interface{}{"plans": resp.Plans}, nil
 
 	case "checkin_schedule":
-		checkinService := tools.NewCheckinService(h.fs.DB)
+		checkinService := h.services.Checkins
 		
 		// Parse input
 		coachID, _ := input["coach_id"].(string)
@@ -375,10 +835,90 @@ func (h *ToolsHandler) executeServerTool(ctx context.Context, tool tools.Tool, i
 	}
 }
 
+// checkCoachAllowsTool enforces the CoachSpec tool allow-list of the session's coach.
+// Executions without a session or coach spec are allowed but logged as unscoped.
+func (h *ToolsHandler) checkCoachAllowsTool(ctx context.Context, uid, sessionID, toolID string) *toolExecError {
+	fields := map[string]interface{}{"uid": uid, "tool_id": toolID, "session_id": sessionID}
+
+	if sessionID == "" {
+		h.log.Warning(ctx, "Unscoped tool execution: no session", fields)
+		return nil
+	}
+
+	sessionDoc, err := h.fs.DB.Collection("sessions").Doc(sessionID).Get(ctx)
+	if err != nil {
+		if !fsClient.IsNotFound(err) {
+			h.log.Error(ctx, "Failed to load session for tool scope", err, fields)
+			return &toolExecError{http.StatusInternalServerError, "Internal server error"}
+		}
+		h.log.Warning(ctx, "Unscoped tool execution: session not found", fields)
+		return nil
+	}
+
+	var session models.Session
+	if err := sessionDoc.DataTo(&session); err != nil {
+		h.log.Error(ctx, "Failed to parse session for tool scope", err, fields)
+		return &toolExecError{http.StatusInternalServerError, "Internal server error"}
+	}
+	if session.UID != uid {
+		return &toolExecError{http.StatusForbidden, "Access denied"}
+	}
+	if session.CoachID == nil || *session.CoachID == "" {
+		h.log.Warning(ctx, "Unscoped tool execution: session has no coach", fields)
+		return nil
+	}
+
+	coachDoc, err := h.fs.DB.Collection("coaches").Doc(*session.CoachID).Get(ctx)
+	if err != nil {
+		if !fsClient.IsNotFound(err) {
+			h.log.Error(ctx, "Failed to load coach for tool scope", err, fields)
+			return &toolExecError{http.StatusInternalServerError, "Internal server error"}
+		}
+		h.log.Warning(ctx, "Unscoped tool execution: coach not found", fields)
+		return nil
+	}
+
+	var coach models.Coach
+	if err := coachDoc.DataTo(&coach); err != nil {
+		h.log.Error(ctx, "Failed to parse coach for tool scope", err, fields)
+		return &toolExecError{http.StatusInternalServerError, "Internal server error"}
+	}
+	if coach.CoachSpec == nil {
+		h.log.Warning(ctx, "Unscoped tool execution: coach has no spec", fields)
+		return nil
+	}
+
+	if !coachSpecAllowsTool(coach.CoachSpec, toolID) {
+		return &toolExecError{http.StatusForbidden, "Tool not allowed for this coach"}
+	}
+	return nil
+}
+
+// coachSpecAllowsTool reports whether the spec lists toolID as a client or server tool
+func coachSpecAllowsTool(spec *models.CoachSpec, toolID string) bool {
+	for _, allowed := range spec.ToolsAllowed.ClientTools {
+		if allowed == toolID {
+			return true
+		}
+	}
+	for _, allowed := range spec.ToolsAllowed.ServerTools {
+		if allowed == toolID {
+			return true
+		}
+	}
+	return false
+}
+
 // checkEntitlements checks if user has required entitlements
 func (h *ToolsHandler) checkEntitlements(ctx context.Context, uid, toolID string) error {
-	// Basic implementation - can be enhanced with RevenueCat integration
-	// For now, allow all tools for authenticated users
+	user, err := h.fs.GetUser(ctx, uid)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !entitlements.Resolve(user, h.policy, time.Now()).ToolAllowed(toolID) {
+		return fmt.Errorf("tool %s requires the %s entitlement", toolID, entitlements.Pro)
+	}
 	return nil
 }
 
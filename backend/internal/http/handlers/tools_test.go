@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/entitlements"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/logger"
+	"simon-backend/internal/models"
+	"simon-backend/internal/tools"
+)
+
+// fakeCheckins records the requests it receives in place of the Firestore check-in service
+type fakeCheckins struct {
+	scheduled []tools.CheckinScheduleRequest
+	updated   []tools.CheckinUpdateRequest
+	err       error
+}
+
+func (f *fakeCheckins) Schedule(ctx context.Context, req tools.CheckinScheduleRequest) (*tools.CheckinScheduleResponse, error) {
+	f.scheduled = append(f.scheduled, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &tools.CheckinScheduleResponse{CheckinID: "checkin-fake", Status: "scheduled"}, nil
+}
+
+func (f *fakeCheckins) List(ctx context.Context, req tools.CheckinListRequest) (*tools.CheckinListResponse, error) {
+	return &tools.CheckinListResponse{}, f.err
+}
+
+func (f *fakeCheckins) Update(ctx context.Context, req tools.CheckinUpdateRequest) (*tools.CheckinUpdateResponse, error) {
+	f.updated = append(f.updated, req)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &tools.CheckinUpdateResponse{Status: "updated"}, nil
+}
+
+func (f *fakeCheckins) Delete(ctx context.Context, uid, checkinID string) error {
+	return f.err
+}
+
+func (f *fakeCheckins) Acknowledge(ctx context.Context, uid, checkinID string) error {
+	return f.err
+}
+
+func TestHandleExecuteUsesInjectedCheckinService(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	checkins := &fakeCheckins{}
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{Checkins: checkins}, entitlements.Policy{}, logger.New())
+
+	body := []byte(`{"tool_id":"checkin_schedule","input":{"uid":"someone-else","coach_id":"coach-1","channel":"in_app","cadence":{"kind":"daily","hour":9,"minute":30}}}`)
+	w := serveAs("u1", h.HandleExecute, http.MethodPost, "/v1/tools/execute", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	if len(checkins.scheduled) != 1 {
+		t.Fatalf("Schedule called %d times, want 1", len(checkins.scheduled))
+	}
+	got := checkins.scheduled[0]
+	if got.UID != "u1" || got.CoachID != "coach-1" || got.Cadence.Hour != 9 || got.Cadence.Minute != 30 {
+		t.Errorf("Schedule request = %+v, want the caller's uid and the decoded input", got)
+	}
+
+	var resp ToolExecuteResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != "executed" || resp.Output["checkin_id"] != "checkin-fake" {
+		t.Errorf("response = %+v, want the fake's checkin id", resp)
+	}
+	doc, err := fs.DB.Collection("tool_runs").Doc(resp.ToolRunID).Get(ctx)
+	if err != nil {
+		t.Fatalf("tool run not saved: %v", err)
+	}
+	if status := doc.Data()["status"]; status != "executed" {
+		t.Errorf("saved run status = %v, want executed", status)
+	}
+}
+
+func TestHandleExecuteMapsServiceErrors(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	checkins := &fakeCheckins{err: fmt.Errorf("%w: cron never runs", tools.ErrValidation)}
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{Checkins: checkins}, entitlements.Policy{}, logger.New())
+
+	body := []byte(`{"tool_id":"checkin_schedule","input":{"coach_id":"coach-1","channel":"in_app","cadence":{"kind":"custom_cron","hour":0,"minute":0,"cron":"0 0 31 2 *"}}}`)
+	w := serveAs("u1", h.HandleExecute, http.MethodPost, "/v1/tools/execute", body)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400 for a validation error", w.Code)
+	}
+	if len(checkins.scheduled) != 1 {
+		t.Errorf("Schedule called %d times, want 1", len(checkins.scheduled))
+	}
+}
+
+func TestHandleExecuteRejectsMalformedTimestamp(t *testing.T) {
+	fs, server := firestoretest.NewWithServer(t)
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+
+	body := []byte(`{"tool_id":"calendar_event_create","input":{"title":"Dentist","start_iso":"tomorrow at 3","end_iso":"2026-04-15T16:00:00Z","idempotency_key":"k1"}}`)
+	w := serveAs("u1", h.HandleExecute, http.MethodPost, "/v1/tools/execute", body)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", w.Code)
+	}
+	var resp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Error, `start_iso must be an RFC3339 timestamp`) || !strings.Contains(resp.Error, `"tomorrow at 3"`) {
+		t.Errorf("error = %q, want it to name start_iso and the bad value", resp.Error)
+	}
+	if n := server.Len(); n != 0 {
+		t.Errorf("stored %d documents for a rejected call", n)
+	}
+}
+
+func TestHandleExecutePlanUpdateErrors(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	for _, uid := range []string{"owner", "intruder"} {
+		if _, err := fs.DB.Collection("users").Doc(uid).Set(ctx, models.User{UID: uid}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	services := DefaultToolServices(fs, nil)
+	created, err := services.Plans.Create(ctx, tools.PlanCreateRequest{
+		UID:  "owner",
+		Plan: models.Plan{Title: "10k", Objective: "Run a 10k", Horizon: "month"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewToolsHandler(fs, tools.NewRegistry(), services, entitlements.Policy{}, logger.New())
+
+	tests := []struct {
+		name   string
+		uid    string
+		planID string
+		want   int
+	}{
+		{"someone else's plan", "intruder", created.PlanID, http.StatusForbidden},
+		{"missing plan", "owner", "plan_missing", http.StatusNotFound},
+		{"owner", "owner", created.PlanID, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := []byte(fmt.Sprintf(`{"tool_id":"plan_update","input":{"plan_id":%q,"updates":{"title":"Half marathon"}}}`, tt.planID))
+			w := serveAs(tt.uid, h.HandleExecute, http.MethodPost, "/v1/tools/execute", body)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	doc, err := fs.DB.Collection("plans").Doc(created.PlanID).Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data := doc.Data(); data["uid"] != "owner" || data["title"] != "Half marathon" {
+		t.Errorf("plan = uid %v title %v, want only the owner's update applied", data["uid"], data["title"])
+	}
+}
+
+func TestUpdateCheckinUsesInjectedService(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	checkins := &fakeCheckins{}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), "u1") })
+	r.PUT("/v1/checkins/:id", UpdateCheckin(firestoretest.New(t), checkins))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/v1/checkins/checkin-7", bytes.NewReader([]byte(`{"updates":{"enabled":false}}`)))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if len(checkins.updated) != 1 {
+		t.Fatalf("Update called %d times, want 1", len(checkins.updated))
+	}
+	if got := checkins.updated[0]; got.UID != "u1" || got.CheckinID != "checkin-7" || got.Updates["enabled"] != false {
+		t.Errorf("Update request = %+v", got)
+	}
+}
+
+func TestHandleListRunsPaging(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 130; i++ {
+		id := fmt.Sprintf("run-%03d", i)
+		if _, err := fs.DB.Collection("tool_runs").Doc(id).Set(ctx, models.ToolRun{
+			ID:             id,
+			UID:            "u1",
+			ToolID:         "plan_create",
+			Status:         "executed",
+			ExecutionToken: "secret",
+			CreatedAt:      start.Add(time.Duration(i) * time.Minute),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := fs.DB.Collection("tool_runs").Doc("other").Set(ctx, models.ToolRun{
+		ID: "other", UID: "u2", ToolID: "plan_create", Status: "executed", CreatedAt: start.Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+
+	tests := []struct {
+		name      string
+		query     string
+		wantCount int
+		wantFirst string
+	}{
+		{"default page", "", 50, "run-129"},
+		{"limit capped at 100", "?limit=500", 100, "run-129"},
+		{"limit and offset", "?limit=10&offset=20", 10, "run-109"},
+		{"offset at the maximum", "?offset=500", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveAs("u1", h.HandleListRuns, http.MethodGet, "/v1/tools/runs"+tt.query, nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", w.Code, w.Body)
+			}
+			var runs []models.ToolRun
+			if err := json.Unmarshal(w.Body.Bytes(), &runs); err != nil {
+				t.Fatal(err)
+			}
+			if len(runs) != tt.wantCount {
+				t.Fatalf("got %d runs, want %d", len(runs), tt.wantCount)
+			}
+			if tt.wantCount > 0 && runs[0].ID != tt.wantFirst {
+				t.Errorf("first run = %s, want %s", runs[0].ID, tt.wantFirst)
+			}
+			for _, run := range runs {
+				if run.UID != "u1" {
+					t.Errorf("run %s belongs to %s", run.ID, run.UID)
+				}
+				if run.ExecutionToken != "" {
+					t.Errorf("run %s leaked its execution token", run.ID)
+				}
+			}
+		})
+	}
+
+	w := serveAs("u1", h.HandleListRuns, http.MethodGet, "/v1/tools/runs?offset=501", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("offset 501: status = %d, want 400", w.Code)
+	}
+}
+
+func TestClaimIdempotentRunConcurrent(t *testing.T) {
+	fs := firestoretest.New(t)
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+	run := models.ToolRun{
+		ID:             idempotentToolRunID("u1", "plan_create", "key-1"),
+		UID:            "u1",
+		ToolID:         "plan_create",
+		IdempotencyKey: "key-1",
+		Status:         "pending",
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+
+	const callers = 2
+	var wg sync.WaitGroup
+	claimed := make(chan bool, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			previous, execErr := h.claimIdempotentRun(context.Background(), run)
+			switch {
+			case execErr == nil && previous == nil:
+				claimed <- true
+			case execErr != nil && execErr.httpStatus == http.StatusConflict:
+				claimed <- false
+			default:
+				t.Errorf("claim = (%v, %+v), want a claim or a 409", previous, execErr)
+			}
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+
+	winners := 0
+	for ok := range claimed {
+		if ok {
+			winners++
+		}
+	}
+	if winners != 1 {
+		t.Errorf("%d callers claimed the key, want exactly 1", winners)
+	}
+	runs, err := fs.DB.Collection("tool_runs").Where("idempotency_key", "==", "key-1").Documents(context.Background()).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 1 || runs[0].Data()["status"] != "running" {
+		t.Errorf("got %d runs for the key, want one running claim", len(runs))
+	}
+}
+
+func TestClaimIdempotentRunAfterEarlierRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       string
+		age          time.Duration
+		wantPrevious bool
+		wantStatus   int
+	}{
+		{"executed run is replayed", "executed", 0, true, 0},
+		{"failed run is taken over", "failed", 0, false, 0},
+		{"fresh claim blocks", "running", time.Second, false, http.StatusConflict},
+		{"abandoned claim is taken over", "running", idempotencyClaimTTL + time.Minute, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := firestoretest.New(t)
+			h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+			id := idempotentToolRunID("u1", "plan_create", "key-1")
+			if _, err := fs.DB.Collection("tool_runs").Doc(id).Set(ctx, models.ToolRun{
+				ID:        id,
+				UID:       "u1",
+				ToolID:    "plan_create",
+				Status:    tt.status,
+				Output:    map[string]interface{}{"plan_id": "plan-1"},
+				UpdatedAt: time.Now().Add(-tt.age),
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			previous, execErr := h.claimIdempotentRun(ctx, models.ToolRun{ID: id, UID: "u1", ToolID: "plan_create", UpdatedAt: time.Now()})
+			if tt.wantStatus != 0 {
+				if execErr == nil || execErr.httpStatus != tt.wantStatus {
+					t.Fatalf("error = %+v, want status %d", execErr, tt.wantStatus)
+				}
+				return
+			}
+			if execErr != nil {
+				t.Fatalf("unexpected error %+v", execErr)
+			}
+			if (previous != nil) != tt.wantPrevious {
+				t.Fatalf("previous = %+v, want present=%v", previous, tt.wantPrevious)
+			}
+			if tt.wantPrevious && previous.Output["plan_id"] != "plan-1" {
+				t.Errorf("previous output = %v", previous.Output)
+			}
+		})
+	}
+}
+
+func TestHandlePending(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+	now := time.Now()
+	runs := []models.ToolRun{
+		{ID: "run_pending", UID: "u1", ToolID: "reminder_create", SessionID: "s1", Reason: "So you don't forget the dentist", Input: map[string]interface{}{"title": "Call the dentist"}, Status: "pending", ExecutionToken: "secret-token", CreatedAt: now.Add(-time.Hour)},
+		{ID: "run_executed", UID: "u1", ToolID: "reminder_create", Input: map[string]interface{}{"title": "Done already"}, Status: "executed", ExecutionToken: "secret-token", CreatedAt: now.Add(-time.Hour)},
+		{ID: "run_server", UID: "u1", ToolID: "plan_create", Input: map[string]interface{}{}, Status: "pending", CreatedAt: now.Add(-time.Hour)},
+		{ID: "run_stale", UID: "u1", ToolID: "reminder_create", Input: map[string]interface{}{"title": "Last week"}, Status: "pending", CreatedAt: now.Add(-pendingToolRunWindow - time.Hour)},
+		{ID: "run_other_user", UID: "u2", ToolID: "reminder_create", Input: map[string]interface{}{"title": "Not mine"}, Status: "pending", CreatedAt: now.Add(-time.Hour)},
+	}
+	for _, run := range runs {
+		if _, err := fs.DB.Collection("tool_runs").Doc(run.ID).Set(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := serveAs("u1", h.HandlePending, http.MethodGet, "/v1/tools/pending", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	if strings.Contains(w.Body.String(), "secret-token") || strings.Contains(w.Body.String(), "execution_token") {
+		t.Errorf("response leaks the execution token: %s", w.Body)
+	}
+	var resp struct {
+		ToolRuns []PendingToolRun `json:"tool_runs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.ToolRuns) != 1 {
+		t.Fatalf("pending = %+v, want only the recent pending client tool run", resp.ToolRuns)
+	}
+	got := resp.ToolRuns[0]
+	if got.ToolRunID != "run_pending" || got.ToolID != "reminder_create" || got.SessionID != "s1" ||
+		got.Reason != "So you don't forget the dentist" || got.Payload["title"] != "Call the dentist" {
+		t.Errorf("pending run = %+v", got)
+	}
+}
+
+func TestHandleExecuteBatchReportsFailedItems(t *testing.T) {
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(context.Background(), models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+	execute := func(body string) ToolBatchExecuteResponse {
+		t.Helper()
+		w := serveAs("u1", h.HandleExecuteBatch, http.MethodPost, "/v1/tools/execute-batch", []byte(body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+		var resp ToolBatchExecuteResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp := execute(`{"items":[
+		{"tool_id":"reminder_create","input":{"title":"Call the dentist","idempotency_key":"k1"}},
+		{"tool_id":"no_such_tool","input":{}},
+		{"tool_id":"reminder_create","input":{}}
+	]}`)
+	if !resp.PartialSuccess {
+		t.Error("partial_success = false with one item succeeding and two failing")
+	}
+	if len(resp.Items) != 3 {
+		t.Fatalf("%d item results, want 3", len(resp.Items))
+	}
+	for i, want := range []struct{ toolID, status string }{
+		{"reminder_create", "pending"},
+		{"no_such_tool", "failed"},
+		{"reminder_create", "failed"},
+	} {
+		got := resp.Items[i]
+		if got.Index != i || got.ToolID != want.toolID || got.Status != want.status {
+			t.Errorf("item %d = %+v, want %s %s", i, got, want.toolID, want.status)
+		}
+		if (got.Status == "failed") != (got.Error != "") {
+			t.Errorf("item %d: status %s with error %q", i, got.Status, got.Error)
+		}
+	}
+	if resp.Items[0].ToolRunID == "" || resp.Items[0].ExecutionToken == "" {
+		t.Errorf("pending item = %+v, want a run id and execution token for the client", resp.Items[0])
+	}
+
+	// Nothing succeeding is a plain failure, not a partial one
+	resp = execute(`{"items":[{"tool_id":"no_such_tool","input":{}}]}`)
+	if resp.PartialSuccess || resp.Items[0].Status != "failed" {
+		t.Errorf("all-failed batch = %+v, want failed without partial_success", resp)
+	}
+}
+
+func TestHandleResultPartial(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	h := NewToolsHandler(fs, tools.NewRegistry(), ToolServices{}, entitlements.Policy{}, logger.New())
+	if _, err := fs.DB.Collection("tool_runs").Doc("run-1").Set(ctx, models.ToolRun{
+		ID: "run-1", UID: "u1", ToolID: "calendar_event_create", Status: "pending", ExecutionToken: "token-1",
+		Input: map[string]interface{}{"title": "Long run", "start_iso": "2026-04-19T07:00:00Z", "end_iso": "2026-04-19T08:30:00Z"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The event was created but its alarm couldn't be set
+	body := []byte(`{"tool_run_id":"run-1","execution_token":"token-1","status":"partial","output":{"event_id":"native-1"},"error":"alarm not permitted","failed_steps":["alarm"]}`)
+	if w := serveAs("u1", h.HandleResult, http.MethodPost, "/v1/tools/result", body); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+
+	doc, err := fs.DB.Collection("tool_runs").Doc("run-1").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var run models.ToolRun
+	if err := doc.DataTo(&run); err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != "partial" || run.Error != "alarm not permitted" || len(run.FailedSteps) != 1 || run.FailedSteps[0] != "alarm" {
+		t.Errorf("tool run = %+v, want the partial status, error and failed steps kept", run)
+	}
+	if run.Output["event_id"] != "native-1" {
+		t.Errorf("output = %v, want what the client did create", run.Output)
+	}
+
+	body = []byte(`{"tool_run_id":"run-1","execution_token":"token-1","status":"half_done"}`)
+	if w := serveAs("u1", h.HandleResult, http.MethodPost, "/v1/tools/result", body); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown status: status = %d, want 400", w.Code)
+	}
+}
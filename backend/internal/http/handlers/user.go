@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"simon-backend/internal/audit"
+	"simon-backend/internal/config"
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
 )
 
 // GetMe handles GET /v1/me
@@ -27,7 +32,8 @@ func GetMe(fs *firestore.Client) gin.HandlerFunc {
 }
 
 // InitializeUser handles POST /v1/me/initialize
-// Creates a new user document with initial credits after sign-in
+// Creates a new user document with initial credits after sign-in. Optional onboarding
+// answers seed the context vault and pick a starter coach, once per user.
 func InitializeUser(fs *firestore.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
@@ -37,6 +43,8 @@ func InitializeUser(fs *firestore.Client) gin.HandlerFunc {
 			Email       string `json:"email"`
 			DisplayName string `json:"display_name"`
 			PhotoURL    string `json:"photo_url"`
+			// Onboarding answers are applied the first time they're sent
+			Onboarding *models.OnboardingAnswers `json:"onboarding"`
 		}
 
 		if err := c.ShouldBindJSON(&req); err != nil {
@@ -51,6 +59,16 @@ func InitializeUser(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
+		if req.Onboarding != nil && !req.Onboarding.Empty() && user.OnboardedAt == nil {
+			user, err = fs.CompleteOnboarding(ctx, uid, *req.Onboarding)
+			if err != nil {
+				log.Printf("Error completing onboarding for user %s: %v", uid, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to initialize user"})
+				return
+			}
+			recordAudit(c, fs, "user", audit.ActionUpdate, uid)
+		}
+
 		c.JSON(http.StatusOK, user)
 	}
 }
@@ -74,23 +92,130 @@ func UpdateMe(fs *firestore.Client) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(c, fs, "user", audit.ActionUpdate, uid)
 		c.JSON(http.StatusOK, gin.H{"success": true})
 	}
 }
 
 // DeleteMe handles DELETE /v1/me
-// Deletes all user data (coaches, sessions, systems, context)
-func DeleteMe(fs *firestore.Client) gin.HandlerFunc {
+// Schedules the account for deletion. The account is blocked but recoverable via
+// POST /v1/me/cancel-deletion until the grace window ends; the purge job then deletes
+// all user data (coaches, sessions, systems, context).
+func DeleteMe(fs *firestore.Client, cfg config.Config) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := middleware.GetUID(c)
 		ctx := c.Request.Context()
 
-		// Delete all user data
-		if err := fs.DeleteAllUserData(ctx, uid); err != nil {
+		user, err := fs.GetUser(ctx, uid)
+		if err != nil {
+			if firestore.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+			return
+		}
+
+		// Repeating the request keeps the original deadline
+		if user.PendingDeletion() && user.PurgeAfter != nil {
+			c.JSON(http.StatusAccepted, gin.H{"status": user.Status, "purge_after": user.PurgeAfter})
+			return
+		}
+
+		purgeAfter := time.Now().AddDate(0, 0, cfg.AccountDeletionGraceDays)
+		if err := fs.ScheduleUserDeletion(ctx, uid, purgeAfter); err != nil {
+			log.Printf("Error scheduling deletion for user %s: %v", uid, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user data"})
 			return
 		}
 
+		recordAudit(c, fs, "user", audit.ActionDelete, uid)
+		c.JSON(http.StatusAccepted, gin.H{"status": models.UserStatusPendingDeletion, "purge_after": purgeAfter})
+	}
+}
+
+// CancelDeletion handles POST /v1/me/cancel-deletion
+// Restores an account that is pending deletion
+func CancelDeletion(fs *firestore.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := middleware.GetUID(c)
+		ctx := c.Request.Context()
+
+		user, err := fs.GetUser(ctx, uid)
+		if err != nil {
+			if firestore.IsNotFound(err) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+			return
+		}
+
+		if !user.PendingDeletion() {
+			c.JSON(http.StatusConflict, gin.H{"error": "account is not pending deletion"})
+			return
+		}
+
+		if err := fs.CancelUserDeletion(ctx, uid); err != nil {
+			log.Printf("Error cancelling deletion for user %s: %v", uid, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel deletion"})
+			return
+		}
+
+		recordAudit(c, fs, "user", audit.ActionUpdate, uid)
 		c.JSON(http.StatusOK, gin.H{"success": true})
 	}
 }
+
+// userPurgeLimit bounds how many accounts one purge run deletes; the scheduler picks up the rest
+const userPurgeLimit = 100
+
+// PurgeDeletedUsers handles POST /internal/users/purge
+// Deletes all data for accounts whose deletion grace window has passed
+func PurgeDeletedUsers(fs *firestore.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		now := time.Now()
+
+		docs, err := fs.DB.Collection("users").
+			Where("status", "==", models.UserStatusPendingDeletion).
+			Where("purge_after", "<=", now).
+			Limit(userPurgeLimit).
+			Documents(ctx).GetAll()
+		if err != nil {
+			log.Printf("Error querying users to purge: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to query users"})
+			return
+		}
+
+		purged, failed := 0, 0
+		for _, doc := range docs {
+			uid := doc.Ref.ID
+
+			// Re-read so a cancellation since the query wins
+			user, err := fs.GetUser(ctx, uid)
+			if err != nil || !user.PendingDeletion() || user.PurgeAfter == nil || user.PurgeAfter.After(now) {
+				continue
+			}
+
+			if err := fs.DeleteAllUserData(ctx, uid); err != nil {
+				log.Printf("Error purging user %s: %v", uid, err)
+				failed++
+				continue
+			}
+			purged++
+
+			entry := audit.NewEntry(ctx, audit.ActorAccountPurge, "user", audit.ActionDelete, uid)
+			if err := audit.Record(ctx, fs, entry); err != nil {
+				log.Printf("Error recording audit %s %s: %v", entry.Action, uid, err)
+			}
+		}
+
+		log.Printf("User purge: scanned=%d purged=%d failed=%d", len(docs), purged, failed)
+		c.JSON(http.StatusOK, gin.H{
+			"scanned": len(docs),
+			"purged":  purged,
+			"failed":  failed,
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/config"
+	fsClient "simon-backend/internal/firestore"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/models"
+)
+
+// accountRouter wires the deletion endpoints behind AccountActive, as the v1 group does,
+// plus an ordinary route to observe blocking
+func accountRouter(fs *fsClient.Client, uid string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(string(middleware.UIDKey), uid) })
+	r.Use(middleware.AccountActive(fs))
+	r.DELETE("/v1/me", DeleteMe(fs, config.Config{AccountDeletionGraceDays: 7}))
+	r.POST("/v1/me/cancel-deletion", CancelDeletion(fs))
+	r.GET("/v1/plans", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{}) })
+	return r
+}
+
+func TestAccountDeletionLifecycle(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	r := accountRouter(fs, "u1")
+
+	w := serve(r, http.MethodDelete, "/v1/me")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("DELETE /v1/me: status %d, body %s", w.Code, w.Body)
+	}
+	user, err := fs.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.PendingDeletion() || user.PurgeAfter == nil {
+		t.Fatalf("user = %+v, want pending deletion with a purge time", user)
+	}
+	if days := time.Until(*user.PurgeAfter).Hours() / 24; days < 6.9 || days > 7.1 {
+		t.Errorf("purge after %.1f days, want the 7 day grace window", days)
+	}
+
+	// Normal use is blocked while pending
+	if w := serve(r, http.MethodGet, "/v1/plans"); w.Code != http.StatusForbidden {
+		t.Errorf("GET /v1/plans while pending: status %d, want 403", w.Code)
+	}
+
+	// Repeating the delete keeps the original deadline
+	w = serve(r, http.MethodDelete, "/v1/me")
+	var resp struct {
+		PurgeAfter time.Time `json:"purge_after"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.PurgeAfter.Equal(*user.PurgeAfter) {
+		t.Errorf("repeat delete moved purge_after from %v to %v", user.PurgeAfter, resp.PurgeAfter)
+	}
+
+	if w := serve(r, http.MethodPost, "/v1/me/cancel-deletion"); w.Code != http.StatusOK {
+		t.Fatalf("cancel: status %d, body %s", w.Code, w.Body)
+	}
+	user, _ = fs.GetUser(ctx, "u1")
+	if user.PendingDeletion() || user.PurgeAfter != nil {
+		t.Errorf("user = %+v after cancel, want active", user)
+	}
+	if w := serve(r, http.MethodGet, "/v1/plans"); w.Code != http.StatusOK {
+		t.Errorf("GET /v1/plans after cancel: status %d, want 200", w.Code)
+	}
+	if w := serve(r, http.MethodPost, "/v1/me/cancel-deletion"); w.Code != http.StatusConflict {
+		t.Errorf("second cancel: status %d, want 409", w.Code)
+	}
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	past, future := time.Now().Add(-time.Hour), time.Now().AddDate(0, 0, 3)
+	for uid, purgeAfter := range map[string]time.Time{"due": past, "in-grace": future} {
+		if _, err := fs.DB.Collection("users").Doc(uid).Set(ctx, models.User{
+			UID: uid, Status: models.UserStatusPendingDeletion, PurgeAfter: &purgeAfter,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fs.DB.Collection("sessions").Doc("session-"+uid).Set(ctx, models.Session{ID: "session-" + uid, UID: uid}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := fs.DB.Collection("sessions").Doc("session-due").Collection("messages").Doc("m1").Set(ctx, models.Message{ID: "m1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveAs("", PurgeDeletedUsers(fs), http.MethodPost, "/internal/users/purge", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	var resp struct{ Scanned, Purged, Failed int }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Purged != 1 || resp.Failed != 0 {
+		t.Errorf("response = %+v, want one purge", resp)
+	}
+
+	if _, err := fs.GetUser(ctx, "due"); !fsClient.IsNotFound(err) {
+		t.Errorf("due user still present (err %v)", err)
+	}
+	for _, ref := range []string{"sessions/session-due", "sessions/session-due/messages/m1"} {
+		if _, err := fs.DB.Doc(ref).Get(ctx); !fsClient.IsNotFound(err) {
+			t.Errorf("%s survived the purge (err %v)", ref, err)
+		}
+	}
+	if user, err := fs.GetUser(ctx, "in-grace"); err != nil || !user.PendingDeletion() {
+		t.Errorf("user in grace window = %+v (err %v), want untouched", user, err)
+	}
+	if _, err := fs.DB.Doc("sessions/session-in-grace").Get(ctx); err != nil {
+		t.Errorf("session of user in grace window deleted: %v", err)
+	}
+}
+
+func TestInitializeUserOnboarding(t *testing.T) {
+	fs := firestoretest.New(t)
+	initialize := func(body string) models.User {
+		t.Helper()
+		w := serveAs("u1", InitializeUser(fs), http.MethodPost, "/v1/me/initialize", []byte(body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", w.Code, w.Body)
+		}
+		var user models.User
+		if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	user := initialize(`{"email":"a@example.com","onboarding":{"primary_goal":"Build a morning workout habit","preferred_tone":"direct","biggest_blocker":"I can't stay consistent"}}`)
+	if len(user.ContextVault.Goals) != 1 || user.ContextVault.Goals[0] != "Build a morning workout habit" {
+		t.Errorf("goals = %q, want the primary goal", user.ContextVault.Goals)
+	}
+	if len(user.ContextVault.Constraints) != 1 || user.ContextVault.Constraints[0] != "I can't stay consistent" {
+		t.Errorf("constraints = %q, want the biggest blocker", user.ContextVault.Constraints)
+	}
+	if user.StarterCoachID != "habit-system-coach" {
+		t.Errorf("starter coach = %q, want habit-system-coach", user.StarterCoachID)
+	}
+	if user.Preferences.PreferredTone != "direct" || user.OnboardedAt == nil {
+		t.Errorf("preferences = %+v onboarded_at = %v, want the tone saved and onboarding stamped", user.Preferences, user.OnboardedAt)
+	}
+
+	stored, err := fs.GetUser(context.Background(), "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.StarterCoachID != "habit-system-coach" || len(stored.ContextVault.Goals) != 1 {
+		t.Errorf("stored user = %+v, want the onboarding persisted", stored)
+	}
+
+	// Initializing again, even with different answers, leaves the first onboarding in place
+	again := initialize(`{"onboarding":{"primary_goal":"Decide on a new career"}}`)
+	if again.StarterCoachID != "habit-system-coach" || len(again.ContextVault.Goals) != 1 {
+		t.Errorf("second initialize = %+v, want it unchanged", again)
+	}
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	fsClient "simon-backend/internal/firestore"
+	geminiClient "simon-backend/internal/gemini"
+	"simon-backend/internal/http/middleware"
+	"simon-backend/internal/jsonutil"
+	"simon-backend/internal/models"
+)
+
+// digestMaxSessions bounds how many of the week's sessions feed the digest
+const digestMaxSessions = 30
+
+// weekActivity is the raw material for a weekly digest
+type weekActivity struct {
+	Summaries        []string
+	CompletedActions []string
+	PendingActions   []string
+	Commitments      []models.Commitment
+}
+
+// GetWeeklyDigest handles GET /v1/me/weekly-digest.
+// Summarizes the current week (Monday 00:00 in the user's timezone until now) across
+// sessions, plans and commitments. Pass store=true to keep a copy under the user.
+func GetWeeklyDigest(fs *fsClient.Client, gm *geminiClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		uid := middleware.GetUID(c)
+
+		user, err := fs.GetUser(ctx, uid)
+		if err != nil {
+			log.Printf("Error getting user %s: %v", uid, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get user"})
+			return
+		}
+
+		now := time.Now()
+		weekStart := startOfWeek(now, user.Preferences.Location())
+
+		activity, err := collectWeekActivity(ctx, fs, uid, user, weekStart)
+		if err != nil {
+			log.Printf("Error collecting weekly activity for %s: %v", uid, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load weekly activity"})
+			return
+		}
+
+		review := summarizeWeek(ctx, gm, activity)
+
+		if c.Query("store") == "true" {
+			docID := weekStart.Format("2006-01-02")
+			if _, err := fs.DB.Collection("users").Doc(uid).Collection("weekly_digests").Doc(docID).Set(ctx, map[string]interface{}{
+				"week_start": weekStart,
+				"review":     review,
+				"created_at": models.Now(),
+			}); err != nil {
+				log.Printf("Error storing weekly digest for %s: %v", uid, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store digest"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"week_start": weekStart,
+			"week_end":   now.In(weekStart.Location()),
+			"review":     review,
+		})
+	}
+}
+
+// startOfWeek returns Monday 00:00 of the week containing t, in loc
+func startOfWeek(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	daysSinceMonday := (int(local.Weekday()) + 6) % 7
+	return time.Date(local.Year(), local.Month(), local.Day()-daysSinceMonday, 0, 0, 0, 0, loc)
+}
+
+// collectWeekActivity gathers session summaries, plan actions and commitments since weekStart
+func collectWeekActivity(ctx context.Context, fs *fsClient.Client, uid string, user *models.User, weekStart time.Time) (*weekActivity, error) {
+	activity := &weekActivity{}
+
+	sessionDocs, err := fs.DB.Collection("sessions").
+		Where("uid", "==", uid).
+		Where("updated_at", ">=", weekStart).
+		OrderBy("updated_at", firestore.Desc).
+		Limit(digestMaxSessions).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	for _, doc := range sessionDocs {
+		if summary, err := doc.DataAt("summary.text"); err == nil {
+			if text, ok := summary.(string); ok && strings.TrimSpace(text) != "" {
+				activity.Summaries = append(activity.Summaries, text)
+			}
+		}
+	}
+
+	planDocs, err := fs.DB.Collection("plans").
+		Where("uid", "==", uid).
+		Where("updated_at", ">=", weekStart).
+		OrderBy("updated_at", firestore.Desc).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query plans: %w", err)
+	}
+	for _, doc := range planDocs {
+		var plan models.Plan
+		if err := doc.DataTo(&plan); err != nil {
+			continue
+		}
+		for _, action := range plan.NextActions {
+			switch {
+			case action.Status == "completed" && !action.CompletedAt.Before(weekStart):
+				activity.CompletedActions = append(activity.CompletedActions, action.Title)
+			case action.Status != "completed" && plan.Status == "active":
+				activity.PendingActions = append(activity.PendingActions, action.Title)
+			}
+		}
+	}
+
+	for _, commitment := range user.Commitments {
+		if commitment.Status == "active" || !commitment.CreatedAt.Before(weekStart) {
+			activity.Commitments = append(activity.Commitments, commitment)
+		}
+	}
+
+	return activity, nil
+}
+
+// summarizeWeek asks Gemini for wins/misses/focus, falling back to a digest built
+// directly from the activity when Gemini is unavailable or returns unusable output
+func summarizeWeek(ctx context.Context, gm *geminiClient.Client, activity *weekActivity) models.WeeklyReview {
+	fallback := models.WeeklyReview{
+		Wins:          nonNil(activity.CompletedActions),
+		Misses:        []string{},
+		RootCauses:    []string{},
+		NextWeekFocus: nonNil(activity.PendingActions),
+		Commitments:   activity.Commitments,
+	}
+	if len(fallback.NextWeekFocus) > 3 {
+		fallback.NextWeekFocus = fallback.NextWeekFocus[:3]
+	}
+	if fallback.Commitments == nil {
+		fallback.Commitments = []models.Commitment{}
+	}
+
+	if gm == nil || (len(activity.Summaries) == 0 && len(activity.CompletedActions) == 0 && len(activity.PendingActions) == 0) {
+		return fallback
+	}
+
+	response, err := gm.GenerateContent(ctx, weeklyDigestPrompt, formatWeekActivity(activity))
+	if err != nil {
+		log.Printf("Weekly digest generation failed: %v", err)
+		return fallback
+	}
+
+	var review models.WeeklyReview
+	if err := jsonutil.ParseResponse(response, &review); err != nil {
+		log.Printf("Weekly digest response was not valid JSON: %v", err)
+		return fallback
+	}
+
+	// Completed actions are facts; make sure they're never dropped from wins
+	if len(review.Wins) == 0 {
+		review.Wins = fallback.Wins
+	}
+	review.Misses = nonNil(review.Misses)
+	review.RootCauses = nonNil(review.RootCauses)
+	review.NextWeekFocus = nonNil(review.NextWeekFocus)
+	review.Commitments = fallback.Commitments
+
+	return review
+}
+
+const weeklyDigestPrompt = `You write a concise weekly digest for a coaching app user from their activity this week.
+Return only JSON with this shape:
+{"wins": [string], "misses": [string], "root_causes": [string], "next_week_focus": [string]}
+Rules:
+- Wins come from completed actions and progress mentioned in session summaries.
+- Misses are pending actions or intentions that didn't happen. Don't shame.
+- At most 5 items per list, each under 15 words.
+- next_week_focus has 1-3 items.`
+
+// formatWeekActivity renders activity as the user prompt for the digest
+func formatWeekActivity(activity *weekActivity) string {
+	var b strings.Builder
+	writeList := func(title string, items []string) {
+		b.WriteString(title + ":\n")
+		if len(items) == 0 {
+			b.WriteString("- (none)\n")
+		}
+		for _, item := range items {
+			b.WriteString("- " + item + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	writeList("Session summaries", activity.Summaries)
+	writeList("Completed actions", activity.CompletedActions)
+	writeList("Pending actions", activity.PendingActions)
+
+	commitments := make([]string, len(activity.Commitments))
+	for i, commitment := range activity.Commitments {
+		commitments[i] = fmt.Sprintf("%s (%s)", commitment.Text, commitment.Status)
+	}
+	writeList("Commitments", commitments)
+
+	return b.String()
+}
+
+// nonNil returns an empty slice instead of nil so lists encode as []
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
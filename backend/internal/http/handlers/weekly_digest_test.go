@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestStartOfWeek(t *testing.T) {
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		at   time.Time
+		loc  *time.Location
+		want time.Time
+	}{
+		{"midweek", time.Date(2026, 4, 16, 15, 0, 0, 0, time.UTC), time.UTC, time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC)},
+		{"monday midnight", time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC), time.UTC, time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC)},
+		{"sunday night", time.Date(2026, 4, 19, 23, 59, 0, 0, time.UTC), time.UTC, time.Date(2026, 4, 13, 0, 0, 0, 0, time.UTC)},
+		// Sunday 22:30 UTC is already Monday in Istanbul
+		{"new week in the user's zone", time.Date(2026, 4, 19, 22, 30, 0, 0, time.UTC), istanbul, time.Date(2026, 4, 20, 0, 0, 0, 0, istanbul)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := startOfWeek(tt.at, tt.loc); !got.Equal(tt.want) {
+				t.Errorf("startOfWeek(%s) = %s, want %s", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetWeeklyDigest(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	now := time.Now()
+	lastWeek := now.AddDate(0, 0, -8)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{
+		UID:         "u1",
+		Preferences: models.Preferences{Timezone: "Europe/Istanbul"},
+		Commitments: []models.Commitment{
+			{ID: "c1", Text: "Lights out by 11", Status: "active", CreatedAt: lastWeek},
+			{ID: "c2", Text: "Old abandoned goal", Status: "abandoned", CreatedAt: lastWeek},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("plans").Doc("p1").Set(ctx, models.Plan{
+		ID:     "p1",
+		UID:    "u1",
+		Title:  "10k",
+		Status: "active",
+		NextActions: []models.NextAction{
+			{ID: "action_1", Title: "Run 5k on Tuesday", Status: "completed", CompletedAt: now},
+			{ID: "action_2", Title: "Buy running shoes", Status: "completed", CompletedAt: lastWeek},
+			{ID: "action_3", Title: "Stretch after runs", Status: "pending"},
+		},
+		UpdatedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Someone else's plan never leaks in
+	if _, err := fs.DB.Collection("plans").Doc("p2").Set(ctx, models.Plan{
+		ID: "p2", UID: "u2", Status: "active", UpdatedAt: now,
+		NextActions: []models.NextAction{{Title: "Not mine", Status: "completed", CompletedAt: now}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := serveAs("u1", GetWeeklyDigest(fs, nil), http.MethodGet, "/v1/me/weekly-digest?store=true", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var resp struct {
+		WeekStart time.Time           `json:"week_start"`
+		Review    models.WeeklyReview `json:"review"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(resp.Review.Wins, []string{"Run 5k on Tuesday"}) {
+		t.Errorf("wins = %q, want only this week's completed action", resp.Review.Wins)
+	}
+	if !slices.Equal(resp.Review.NextWeekFocus, []string{"Stretch after runs"}) {
+		t.Errorf("focus = %q, want the pending action", resp.Review.NextWeekFocus)
+	}
+	if len(resp.Review.Commitments) != 1 || resp.Review.Commitments[0].ID != "c1" {
+		t.Errorf("commitments = %+v, want the active one", resp.Review.Commitments)
+	}
+	if _, offset := resp.WeekStart.Zone(); offset != 3*60*60 || resp.WeekStart.Weekday() != time.Monday || resp.WeekStart.Hour() != 0 {
+		t.Errorf("week start = %s, want Monday 00:00 Istanbul time", resp.WeekStart)
+	}
+
+	stored, err := fs.DB.Collection("users").Doc("u1").Collection("weekly_digests").Doc(resp.WeekStart.Format("2006-01-02")).Get(ctx)
+	if err != nil {
+		t.Fatalf("digest not stored: %v", err)
+	}
+	if wins, _ := stored.DataAt("review.wins"); len(wins.([]interface{})) != 1 {
+		t.Errorf("stored wins = %v", wins)
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	fsClient "simon-backend/internal/firestore"
+)
+
+// pendingDeletionAllowed are the routes an account pending deletion can still use
+var pendingDeletionAllowed = map[string]bool{
+	"GET /v1/me":                  true,
+	"DELETE /v1/me":               true,
+	"POST /v1/me/cancel-deletion": true,
+	"GET /v1/me/entitlements":     true,
+}
+
+// AccountActive blocks accounts that are pending deletion, except for the routes needed to
+// inspect or cancel the deletion. Must run after auth. Users without a profile yet pass through.
+func AccountActive(fs *fsClient.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		uid := GetUID(c)
+		if uid == "" || pendingDeletionAllowed[c.Request.Method+" "+c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		user, err := fs.GetUser(c.Request.Context(), uid)
+		if err != nil {
+			if !fsClient.IsNotFound(err) {
+				log.Printf("AccountActive: failed to load user %s: %v", uid, err)
+			}
+			c.Next()
+			return
+		}
+
+		if user.PendingDeletion() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":       "account_pending_deletion",
+				"message":     "This account is scheduled for deletion. Cancel the deletion to keep using it.",
+				"purge_after": user.PurgeAfter,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
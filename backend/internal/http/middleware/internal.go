@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InternalTokenHeader carries the shared secret for scheduler-invoked internal endpoints
+const InternalTokenHeader = "X-Internal-Token"
+
+// AdminTokenHeader carries the shared secret for operator-only admin endpoints
+const AdminTokenHeader = "X-Admin-Token"
+
+// InternalAuth guards internal endpoints (Cloud Scheduler jobs) with a shared secret.
+// When no token is configured the endpoints are disabled.
+func InternalAuth(token string) gin.HandlerFunc {
+	return requireSharedSecret(InternalTokenHeader, token)
+}
+
+// AdminAuth guards admin endpoints (metrics, diagnostics) with a shared secret.
+// When no token is configured the endpoints are disabled.
+func AdminAuth(token string) gin.HandlerFunc {
+	return requireSharedSecret(AdminTokenHeader, token)
+}
+
+// requireSharedSecret rejects requests whose header doesn't match the configured secret
+func requireSharedSecret(header, secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if secret == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "endpoint disabled"})
+			c.Abort()
+			return
+		}
+
+		provided := c.GetHeader(header)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
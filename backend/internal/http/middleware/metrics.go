@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/metrics"
+)
+
+// unmatchedRoute labels requests that hit no route, so stray paths can't grow the metric set
+const unmatchedRoute = "unmatched"
+
+// Metrics times each request and records it under its route template (e.g. /v1/plans/:id)
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.Get().RecordRequest(route, time.Since(start))
+	}
+}
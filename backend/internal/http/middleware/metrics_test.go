@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"simon-backend/internal/metrics"
+)
+
+func requestCount(t *testing.T, route string) int64 {
+	t.Helper()
+	requests := metrics.Get().GetStats()["requests"].(map[string]interface{})
+	stats, ok := requests[route].(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	return stats["count"].(int64)
+}
+
+func TestMetricsRecordsRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Metrics())
+	r.GET("/v1/plans/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	before := requestCount(t, "/v1/plans/:id")
+	for _, id := range []string{"a", "b", "c"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/plans/"+id, nil))
+	}
+
+	if got := requestCount(t, "/v1/plans/:id") - before; got != 3 {
+		t.Errorf("recorded %d requests for /v1/plans/:id, want 3", got)
+	}
+	if got := requestCount(t, "/v1/plans/a"); got != 0 {
+		t.Errorf("recorded %d requests under the raw path, want 0", got)
+	}
+}
+
+func TestMetricsGroupsUnmatchedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Metrics())
+
+	before := requestCount(t, unmatchedRoute)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope/1", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nope/2", nil))
+
+	if got := requestCount(t, unmatchedRoute) - before; got != 2 {
+		t.Errorf("recorded %d unmatched requests, want 2", got)
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
@@ -37,7 +38,17 @@ func NewRateLimiter(rate int, window time.Duration) *RateLimiter {
 	return rl
 }
 
-// Middleware returns a Gin middleware function
+// limitState is a snapshot of a user's bucket after a request
+type limitState struct {
+	allowed    bool
+	remaining  int
+	reset      time.Time     // when the bucket will be full again
+	retryAfter time.Duration // until the next token, set when denied
+}
+
+// Middleware returns a Gin middleware function.
+// Every response carries X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset
+// (unix seconds when the budget is fully restored); 429s also carry Retry-After.
 func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := GetUID(c)
@@ -47,9 +58,14 @@ func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 			return
 		}
 
-		if !rl.allow(uid) {
-			// Calculate retry-after seconds
-			retryAfter := rl.getRetryAfter(uid)
+		state := rl.take(uid, time.Now())
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(rl.rate))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(state.remaining))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(state.reset.Unix(), 10))
+
+		if !state.allowed {
+			retryAfter := strconv.Itoa(ceilSeconds(state.retryAfter))
 
 			c.Header("Retry-After", retryAfter)
 			c.JSON(http.StatusTooManyRequests, gin.H{
@@ -64,64 +80,56 @@ func (rl *RateLimiter) Middleware() gin.HandlerFunc {
 	}
 }
 
-// allow checks if a request is allowed for the given UID
-func (rl *RateLimiter) allow(uid string) bool {
+// take refills the user's bucket and consumes a token if one is available
+func (rl *RateLimiter) take(uid string, now time.Time) limitState {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
+	perToken := rl.window / time.Duration(rl.rate)
+
 	b, exists := rl.buckets[uid]
 	if !exists {
-		// Create new bucket
-		b = &bucket{
-			tokens:     rl.rate - 1, // Consume one token
-			lastRefill: time.Now(),
-		}
+		b = &bucket{tokens: rl.rate, lastRefill: now}
 		rl.buckets[uid] = b
-		return true
 	}
 
-	// Refill tokens based on elapsed time
-	now := time.Now()
-	elapsed := now.Sub(b.lastRefill)
-
-	if elapsed >= rl.window {
-		// Full refill
-		b.tokens = rl.rate - 1
-		b.lastRefill = now
-		return true
+	// Refill whole tokens for the elapsed time, carrying over the remainder so
+	// frequent requests don't starve the refill
+	if elapsed := now.Sub(b.lastRefill); elapsed > 0 && perToken > 0 {
+		tokensToAdd := int(elapsed / perToken)
+		if b.tokens+tokensToAdd >= rl.rate {
+			b.tokens = rl.rate
+			b.lastRefill = now
+		} else if tokensToAdd > 0 {
+			b.tokens += tokensToAdd
+			b.lastRefill = b.lastRefill.Add(time.Duration(tokensToAdd) * perToken)
+		}
 	}
 
-	// Partial refill (linear)
-	tokensToAdd := int(float64(rl.rate) * (elapsed.Seconds() / rl.window.Seconds()))
-	b.tokens = min(b.tokens+tokensToAdd, rl.rate)
-	b.lastRefill = now
-
+	state := limitState{}
 	if b.tokens > 0 {
 		b.tokens--
-		return true
+		state.allowed = true
+	} else {
+		state.retryAfter = perToken - now.Sub(b.lastRefill)
 	}
 
-	return false
-}
-
-// getRetryAfter returns the number of seconds until the next token is available
-func (rl *RateLimiter) getRetryAfter(uid string) string {
-	rl.mu.RLock()
-	defer rl.mu.RUnlock()
-
-	b, exists := rl.buckets[uid]
-	if !exists {
-		return "0"
+	state.remaining = b.tokens
+	state.reset = now
+	if missing := rl.rate - b.tokens; missing > 0 {
+		state.reset = b.lastRefill.Add(time.Duration(missing) * perToken)
 	}
 
-	elapsed := time.Since(b.lastRefill)
-	remaining := rl.window - elapsed
+	return state
+}
 
-	if remaining <= 0 {
-		return "0"
+// ceilSeconds rounds a duration up to whole seconds, with a minimum of 1
+func ceilSeconds(d time.Duration) int {
+	seconds := int((d + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		return 1
 	}
-
-	return remaining.Round(time.Second).String()
+	return seconds
 }
 
 // cleanup removes old buckets to prevent memory leaks
@@ -140,10 +148,3 @@ func (rl *RateLimiter) cleanup() {
 		rl.mu.Unlock()
 	}
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
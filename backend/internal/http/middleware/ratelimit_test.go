@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	// Three requests per three minutes: one token every minute
+	rl := &RateLimiter{buckets: make(map[string]*bucket), rate: 3, window: 3 * time.Minute}
+	t0 := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name       string
+		at         time.Duration
+		allowed    bool
+		remaining  int
+		reset      time.Duration
+		retryAfter time.Duration
+	}{
+		{"first request", 0, true, 2, time.Minute, 0},
+		{"second request", 10 * time.Second, true, 1, 2 * time.Minute, 0},
+		{"third request", 20 * time.Second, true, 0, 3 * time.Minute, 0},
+		{"over the limit", 30 * time.Second, false, 0, 3 * time.Minute, 30 * time.Second},
+		{"one token refilled partway through the window", 70 * time.Second, true, 0, 4 * time.Minute, 0},
+		{"denied until the next token", 80 * time.Second, false, 0, 4 * time.Minute, 40 * time.Second},
+		{"fully refilled", 10 * time.Minute, true, 2, 11 * time.Minute, 0},
+	}
+
+	for _, step := range steps {
+		got := rl.take("u1", t0.Add(step.at))
+		if got.allowed != step.allowed || got.remaining != step.remaining {
+			t.Errorf("%s: allowed=%v remaining=%d, want %v and %d", step.name, got.allowed, got.remaining, step.allowed, step.remaining)
+		}
+		if want := t0.Add(step.reset); !got.reset.Equal(want) {
+			t.Errorf("%s: reset = %v, want %v", step.name, got.reset, want)
+		}
+		if got.retryAfter != step.retryAfter {
+			t.Errorf("%s: retryAfter = %v, want %v", step.name, got.retryAfter, step.retryAfter)
+		}
+	}
+
+	// Buckets are per user
+	if got := rl.take("u2", t0.Add(30*time.Second)); !got.allowed || got.remaining != 2 {
+		t.Errorf("another user's first request = %+v, want allowed with 2 remaining", got)
+	}
+}
+
+func TestRateLimiterHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rl := &RateLimiter{buckets: make(map[string]*bucket), rate: 2, window: time.Minute}
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(string(UIDKey), "u1") }, rl.Middleware())
+	r.GET("/v1/coaches", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	var w *httptest.ResponseRecorder
+	for i, wantRemaining := range []string{"1", "0"} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/coaches", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200", i+1, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != wantRemaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %s, want %s", i+1, got, wantRemaining)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: X-RateLimit-Limit = %s, want 2", i+1, got)
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Errorf("request %d: Retry-After set on an allowed request", i+1)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/coaches", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request: status = %d, want 429", w.Code)
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 || retryAfter > 30 {
+		t.Errorf("Retry-After = %q, want whole seconds until the next token", w.Header().Get("Retry-After"))
+	}
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset < time.Now().Unix() {
+		t.Errorf("X-RateLimit-Reset = %q, want a future unix time", w.Header().Get("X-RateLimit-Reset"))
+	}
+}
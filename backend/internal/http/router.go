@@ -6,11 +6,13 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"simon-backend/internal/config"
+	"simon-backend/internal/entitlements"
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/gemini"
 	"simon-backend/internal/http/handlers"
 	"simon-backend/internal/http/middleware"
 	"simon-backend/internal/logger"
+	"simon-backend/internal/sse"
 	"simon-backend/internal/tools"
 )
 
@@ -29,6 +31,7 @@ func New(cfg config.Config, fs *firestore.Client, gm *gemini.Client) (*gin.Engin
 	log := logger.New()
 	r.Use(logger.RequestIDMiddleware())
 	r.Use(logger.LoggingMiddleware(log))
+	r.Use(middleware.Metrics())
 	
 	r.Use(middleware.CORS())
 
@@ -42,7 +45,32 @@ func New(cfg config.Config, fs *firestore.Client, gm *gemini.Client) (*gin.Engin
 	
 	// Public coach browsing (no auth required)
 	r.GET("/v1/coaches", handlers.ListCoaches(fs))
+	r.GET("/v1/coaches/leaderboard", handlers.CoachLeaderboard(fs, cfg))
 	r.GET("/v1/coaches/:id", handlers.GetCoach(fs))
+	r.GET("/v1/coaches/:id/sample", handlers.GetCoachSample(fs, gm))
+
+	// Internal endpoints invoked by Cloud Scheduler (shared-secret auth)
+	internal := r.Group("/internal")
+	internal.Use(middleware.InternalAuth(cfg.InternalAPIToken))
+	{
+		internal.POST("/subscriptions/reconcile", handlers.ReconcileSubscriptions(fs))
+		internal.POST("/sessions/auto-archive", handlers.AutoArchiveSessions(fs, cfg))
+		internal.POST("/coaches/:id/recompute-stats", handlers.RecomputeCoachStats(fs))
+		internal.POST("/coaches/reindex-search", handlers.ReindexCoachSearch(fs))
+		internal.POST("/checkins/run", handlers.RunDueCheckins(fs, cfg))
+		internal.POST("/plans/recur", handlers.RunRecurringPlans(fs))
+		internal.POST("/users/purge", handlers.PurgeDeletedUsers(fs))
+	}
+
+	// Admin endpoints for operators (shared-secret auth)
+	admin := r.Group("/v1/admin")
+	admin.Use(middleware.AdminAuth(cfg.AdminAPIToken))
+	{
+		admin.GET("/metrics", handlers.GetMetrics)
+	}
+
+	// Prometheus scrape endpoint (same admin token)
+	r.GET("/metrics", middleware.AdminAuth(cfg.AdminAPIToken), handlers.GetPrometheusMetrics)
 
 	// Initialize auth middleware
 	authMW, err := middleware.NewFirebaseAuth()
@@ -58,30 +86,54 @@ func New(cfg config.Config, fs *firestore.Client, gm *gemini.Client) (*gin.Engin
 	v1 := r.Group("/v1")
 	v1.Use(authMW)
 	v1.Use(rateLimiter.Middleware())
+	v1.Use(middleware.AccountActive(fs))
 	{
 		// User endpoints
 		v1.GET("/me", handlers.GetMe(fs))
 		v1.POST("/me/initialize", handlers.InitializeUser(fs))
+		v1.GET("/me/weekly-digest", handlers.GetWeeklyDigest(fs, gm))
+		v1.GET("/me/entitlements", handlers.GetEntitlements(fs, cfg))
+		v1.GET("/me/saved-coaches", handlers.ListSavedCoaches(fs))
 		v1.PUT("/me", handlers.UpdateMe(fs))
-		v1.DELETE("/me", handlers.DeleteMe(fs))
+		v1.DELETE("/me", handlers.DeleteMe(fs, cfg))
+		v1.POST("/me/cancel-deletion", handlers.CancelDeletion(fs))
 
 		// Context endpoints
 		v1.GET("/context", handlers.GetContext(fs))
 		v1.PUT("/context", handlers.UpdateContext(fs))
 		v1.PUT("/context/preference", handlers.UpdateContextPreference(fs))
+		v1.PUT("/context/quiet-hours", handlers.UpdateQuietHours(fs))
 
 		// Coach endpoints (to be implemented in Week 1 Day 5-7)
 		v1.POST("/coaches", handlers.CreateCoach(fs))
 		v1.PUT("/coaches/:id", handlers.UpdateCoach(fs))
+		v1.DELETE("/coaches/:id", handlers.DeleteCoach(fs))
+		v1.POST("/coaches/merge", handlers.MergeCoaches(fs))
 		v1.POST("/coaches/:id/fork", handlers.ForkCoach(fs))
 		v1.POST("/coaches/:id/publish", handlers.PublishCoach(fs, cfg))
+		v1.POST("/coaches/:id/migrate-spec", handlers.MigrateCoachSpec(fs))
+		v1.POST("/coaches/:id/upvote", handlers.UpvoteCoach(fs))
+		v1.DELETE("/coaches/:id/upvote", handlers.RemoveCoachUpvote(fs))
+		v1.POST("/coaches/:id/save", handlers.SaveCoach(fs))
+		v1.DELETE("/coaches/:id/save", handlers.UnsaveCoach(fs))
+		v1.POST("/coachspec/validate", handlers.ValidateCoachSpec())
+
+		toolServices := handlers.DefaultToolServices(fs, gm)
 
 		// Session endpoints (to be implemented in Week 1 Day 5-7)
+		streams := sse.NewLimiter(cfg.MaxStreamsPerUser)
+		stops := sse.NewStops()
 		v1.GET("/sessions", handlers.ListSessions(fs))
 		v1.POST("/sessions", handlers.CreateSession(fs))
 		v1.GET("/sessions/:id", handlers.GetSession(fs))
+		v1.PUT("/sessions/:id", handlers.UpdateSession(fs))
+		v1.DELETE("/sessions/:id", handlers.DeleteSession(fs))
+		v1.PUT("/sessions/:id/archive", handlers.ArchiveSession(fs))
 		v1.POST("/sessions/:id/messages", handlers.SendMessage(fs, gm, cfg))
-		v1.POST("/sessions/:id/stream", handlers.StreamChat(fs, gm, cfg))
+		v1.POST("/sessions/:id/stream", handlers.StreamChat(fs, gm, cfg, streams, stops, toolServices.Plans))
+		v1.POST("/sessions/:id/stop", handlers.StopStream(fs, stops))
+		v1.POST("/sessions/:id/regenerate", handlers.RegenerateMessage(fs, gm, cfg, streams, stops, toolServices.Plans))
+		v1.GET("/sessions/:id/systems", handlers.ListSessionSystems(fs))
 
 		// Moment endpoints (to be implemented in Week 2)
 		v1.POST("/moments/start", handlers.StartMoment(fs, gm, cfg))
@@ -93,21 +145,33 @@ func New(cfg config.Config, fs *firestore.Client, gm *gemini.Client) (*gin.Engin
 		v1.DELETE("/systems/:id", handlers.DeleteSystem(fs))
 		
 		// Tool endpoints
-		toolsHandler := handlers.NewToolsHandler(fs, tools.NewRegistry(), log)
+		toolsHandler := handlers.NewToolsHandler(fs, tools.NewRegistry(), toolServices, entitlements.PolicyFromConfig(cfg), log)
 		v1.POST("/tools/execute", toolsHandler.HandleExecute)
+		v1.POST("/tools/execute-batch", toolsHandler.HandleExecuteBatch)
 		v1.POST("/tools/result", toolsHandler.HandleResult)
+		v1.GET("/tools/pending", toolsHandler.HandlePending)
+		v1.GET("/tools/runs", toolsHandler.HandleListRuns)
 		
+		// Card schema versions (client gates rendering on these)
+		v1.GET("/cards/schemas", handlers.ListCardSchemas)
+
 		// Plan endpoints
 		v1.GET("/plans", handlers.ListPlans(fs))
 		v1.POST("/plans", handlers.CreatePlan(fs))
 		v1.GET("/plans/:id", handlers.GetPlan(fs))
 		v1.PUT("/plans/:id", handlers.UpdatePlan(fs))
+		v1.PUT("/plans/:id/pin", handlers.PinPlan(fs))
+		v1.PUT("/plans/:id/unpin", handlers.UnpinPlan(fs))
+		v1.PUT("/plans/:id/archive", handlers.ArchivePlan(fs))
+		v1.PUT("/plans/:id/actions/:actionId/complete", handlers.CompletePlanAction(fs))
+		v1.PUT("/plans/:id/milestones/:milestoneId/complete", handlers.CompletePlanMilestone(fs))
 		
 		// Check-in endpoints
-		v1.POST("/checkins", handlers.ScheduleCheckin(fs))
-		v1.GET("/checkins", handlers.ListCheckins(fs))
-		v1.PUT("/checkins/:id", handlers.UpdateCheckin(fs))
-		v1.DELETE("/checkins/:id", handlers.DeleteCheckin(fs))
+		v1.POST("/checkins", handlers.ScheduleCheckin(fs, toolServices.Checkins))
+		v1.GET("/checkins", handlers.ListCheckins(fs, toolServices.Checkins))
+		v1.PUT("/checkins/:id", handlers.UpdateCheckin(fs, toolServices.Checkins))
+		v1.DELETE("/checkins/:id", handlers.DeleteCheckin(fs, toolServices.Checkins))
+		v1.POST("/checkins/:id/ack", handlers.AcknowledgeCheckin(fs, toolServices.Checkins))
 		
 		// Event endpoints
 		eventsHandler := handlers.NewEventsHandler(fs, log)
@@ -0,0 +1,108 @@
+package jsonutil
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrNoJSON is returned when no JSON object or array can be found
+var ErrNoJSON = errors.New("no JSON object or array found")
+
+// ExtractJSON pulls the JSON payload out of a model response. It strips markdown code
+// fences and skips any surrounding prose, returning the first balanced object or array
+// that is valid JSON.
+func ExtractJSON(raw string) ([]byte, error) {
+	if fenced := stripFence(raw); fenced != raw {
+		if payload, err := firstJSON(fenced); err == nil {
+			return payload, nil
+		}
+	}
+	return firstJSON(raw)
+}
+
+// ParseResponse decodes the JSON in a model response into v, tolerating surrounding whitespace,
+// markdown code fences and prose
+func ParseResponse(response string, v interface{}) error {
+	payload, err := ExtractJSON(strings.TrimSpace(response))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payload, v)
+}
+
+// firstJSON returns the first balanced, valid JSON object or array in text
+func firstJSON(text string) ([]byte, error) {
+	for start := 0; start < len(text); start++ {
+		if text[start] != '{' && text[start] != '[' {
+			continue
+		}
+		end := balancedEnd(text, start)
+		if end < 0 {
+			continue
+		}
+		if candidate := text[start : end+1]; json.Valid([]byte(candidate)) {
+			return []byte(candidate), nil
+		}
+	}
+
+	return nil, ErrNoJSON
+}
+
+// stripFence returns the contents of the first ``` fence in s, or s unchanged if there is none
+func stripFence(s string) string {
+	open := strings.Index(s, "```")
+	if open < 0 {
+		return s
+	}
+	body := s[open+3:]
+	// Drop the info string (e.g. "json") on the opening line
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 && !strings.ContainsAny(body[:newline], "{[") {
+		body = body[newline+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return body
+}
+
+// balancedEnd returns the index of the bracket closing the one at start, or -1.
+// Brackets inside string literals are ignored.
+func balancedEnd(s string, start int) int {
+	var stack []byte
+	inString := false
+	escaped := false
+
+	for i := start; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+
+		switch ch {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '}')
+		case '[':
+			stack = append(stack, ']')
+		case '}', ']':
+			if len(stack) == 0 || stack[len(stack)-1] != ch {
+				return -1
+			}
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,80 @@
+package jsonutil
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"clean object", `{"route":"deep_session","confidence":0.8}`, `{"route":"deep_session","confidence":0.8}`},
+		{"clean array", `["Walk after lunch"]`, `["Walk after lunch"]`},
+		{"json fence", "```json\n{\"route\":\"quick_nudge\"}\n```", `{"route":"quick_nudge"}`},
+		{"bare fence", "```\n[1, 2]\n```", `[1, 2]`},
+		{"fence on one line", "```{\"a\":1}```", `{"a":1}`},
+		{"prose before", `Sure! Here is the classification: {"route":"scheduling"}`, `{"route":"scheduling"}`},
+		{"prose around fence", "Here you go:\n```json\n{\"route\":\"review_retro\"}\n```\nLet me know!", `{"route":"review_retro"}`},
+		{"prose after", "{\"a\":{\"b\":[1]}} hope that helps {not json}", `{"a":{"b":[1]}}`},
+		{"brackets in strings", `Result: {"title":"Fix } and ] in \"quotes\""}`, `{"title":"Fix } and ] in \"quotes\""}`},
+		{"skips invalid candidate", `Options [a, b] then {"ok":true}`, `{"ok":true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractJSON(tt.raw)
+			if err != nil {
+				t.Fatalf("ExtractJSON(%q): %v", tt.raw, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ExtractJSON(%q) = %s, want %s", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractJSONNoPayload(t *testing.T) {
+	for _, raw := range []string{"", "I couldn't classify that.", "```json\n```", `{"unterminated": [1, 2}`} {
+		if got, err := ExtractJSON(raw); !errors.Is(err, ErrNoJSON) {
+			t.Errorf("ExtractJSON(%q) = %s, %v; want ErrNoJSON", raw, got, err)
+		}
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	type route struct {
+		Route      string  `json:"route"`
+		Confidence float64 `json:"confidence"`
+	}
+	for name, response := range map[string]string{
+		"unfenced":          `{"route":"deep_session","confidence":0.7}`,
+		"padded":            "\n\t  {\"route\":\"deep_session\",\"confidence\":0.7}  \n",
+		"fenced":            "```json\n{\"route\":\"deep_session\",\"confidence\":0.7}\n```",
+		"fenced with prose": "Classification:\n```JSON\n{\"route\":\"deep_session\",\"confidence\":0.7}\n```",
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got route
+			if err := ParseResponse(response, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Route != "deep_session" || got.Confidence != 0.7 {
+				t.Errorf("parsed %+v", got)
+			}
+		})
+	}
+
+	var commitments []string
+	if err := ParseResponse("```\n[\"Walk daily\", \"Sleep by 11\"]\n```", &commitments); err != nil || len(commitments) != 2 {
+		t.Errorf("array: %v (err %v)", commitments, err)
+	}
+
+	var wrongShape []string
+	if err := ParseResponse(`{"route":"deep_session"}`, &wrongShape); err == nil {
+		t.Error("decoded an object into a slice")
+	}
+	if err := ParseResponse("no json here", &wrongShape); !errors.Is(err, ErrNoJSON) {
+		t.Errorf("error = %v, want ErrNoJSON", err)
+	}
+}
@@ -133,6 +133,11 @@ func WithRequestID(ctx context.Context, requestID string) context.Context {
 	return context.WithValue(ctx, requestIDKey, requestID)
 }
 
+// RequestID returns the request ID set by RequestIDMiddleware, or "" outside a request
+func RequestID(ctx context.Context) string {
+	return getRequestID(ctx)
+}
+
 // WithUID adds UID to context
 func WithUID(ctx context.Context, uid string) context.Context {
 	return context.WithValue(ctx, uidKey, uid)
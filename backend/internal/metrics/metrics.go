@@ -2,6 +2,8 @@ package metrics
 
 import (
 	"context"
+	"math"
+	"sort"
 	"sync"
 	"time"
 )
@@ -12,7 +14,8 @@ type Metrics struct {
 	
 	// Request metrics
 	requestCount    map[string]int64
-	requestDuration map[string][]time.Duration
+	requestDuration map[string]*histogram
+	recentDurations map[string][]time.Duration // last maxRecentDurations, for percentiles
 	
 	// Pipeline metrics
 	pipelineSteps   map[string]time.Duration
@@ -26,9 +29,22 @@ type Metrics struct {
 	sseConnections  int64
 	sseDisconnects  int64
 	sseErrors       int64
+	sseRejected     int64
 	
 	// Error metrics
 	errorsByType    map[string]int64
+
+	// Coach replies breaking their formatting limits, by rule
+	formattingViolations map[string]int64
+
+	// Gemini call limiter metrics
+	geminiQueueDepth    int64
+	geminiQueueWait     *histogram
+	geminiQueueTimeouts int64
+
+	// Gemini token usage by model
+	geminiPromptTokens     map[string]int64
+	geminiCompletionTokens map[string]int64
 }
 
 var (
@@ -41,11 +57,18 @@ func Get() *Metrics {
 	once.Do(func() {
 		instance = &Metrics{
 			requestCount:    make(map[string]int64),
-			requestDuration: make(map[string][]time.Duration),
+			requestDuration: make(map[string]*histogram),
+			recentDurations: make(map[string][]time.Duration),
 			pipelineSteps:   make(map[string]time.Duration),
 			toolExecutions:  make(map[string]int64),
 			toolErrors:      make(map[string]int64),
 			errorsByType:    make(map[string]int64),
+
+			formattingViolations: make(map[string]int64),
+			geminiQueueWait: newHistogram(requestDurationBuckets),
+
+			geminiPromptTokens:     make(map[string]int64),
+			geminiCompletionTokens: make(map[string]int64),
 		}
 	})
 	return instance
@@ -57,11 +80,18 @@ func (m *Metrics) RecordRequest(endpoint string, duration time.Duration) {
 	defer m.mu.Unlock()
 	
 	m.requestCount[endpoint]++
-	m.requestDuration[endpoint] = append(m.requestDuration[endpoint], duration)
 	
-	// Keep only last 1000 durations per endpoint
-	if len(m.requestDuration[endpoint]) > 1000 {
-		m.requestDuration[endpoint] = m.requestDuration[endpoint][1:]
+	hist, ok := m.requestDuration[endpoint]
+	if !ok {
+		hist = newHistogram(requestDurationBuckets)
+		m.requestDuration[endpoint] = hist
+	}
+	hist.observe(duration.Seconds())
+	
+	// Keep only the most recent durations per endpoint for percentiles
+	m.recentDurations[endpoint] = append(m.recentDurations[endpoint], duration)
+	if len(m.recentDurations[endpoint]) > maxRecentDurations {
+		m.recentDurations[endpoint] = m.recentDurations[endpoint][1:]
 	}
 }
 
@@ -116,6 +146,14 @@ func (m *Metrics) RecordSSEError() {
 	m.sseErrors++
 }
 
+// RecordSSERejected records a stream refused because the user hit the concurrent-stream limit
+func (m *Metrics) RecordSSERejected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sseRejected++
+}
+
 // RecordError records an error by type
 func (m *Metrics) RecordError(errorType string) {
 	m.mu.Lock()
@@ -124,6 +162,42 @@ func (m *Metrics) RecordError(errorType string) {
 	m.errorsByType[errorType]++
 }
 
+// RecordFormattingViolation records a coach reply that broke a formatting rule
+func (m *Metrics) RecordFormattingViolation(rule string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.formattingViolations[rule]++
+}
+
+// AddGeminiQueueDepth adjusts the number of Gemini calls waiting for a slot
+func (m *Metrics) AddGeminiQueueDepth(delta int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.geminiQueueDepth += delta
+}
+
+// RecordGeminiQueueWait records how long a Gemini call waited for a slot
+func (m *Metrics) RecordGeminiQueueWait(wait time.Duration, timedOut bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.geminiQueueWait.observe(wait.Seconds())
+	if timedOut {
+		m.geminiQueueTimeouts++
+	}
+}
+
+// RecordGeminiTokens adds one call's prompt and completion token counts for model
+func (m *Metrics) RecordGeminiTokens(model string, promptTokens, completionTokens int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.geminiPromptTokens[model] += int64(promptTokens)
+	m.geminiCompletionTokens[model] += int64(completionTokens)
+}
+
 // GetStats returns current metrics statistics
 func (m *Metrics) GetStats() map[string]interface{} {
 	m.mu.RLock()
@@ -134,14 +208,18 @@ func (m *Metrics) GetStats() map[string]interface{} {
 	// Request stats
 	requestStats := make(map[string]interface{})
 	for endpoint, count := range m.requestCount {
-		durations := m.requestDuration[endpoint]
-		avg := calculateAverage(durations)
-		p95 := calculatePercentile(durations, 0.95)
+		var avg time.Duration
+		if hist := m.requestDuration[endpoint]; hist != nil && hist.count > 0 {
+			avg = time.Duration(hist.sum / float64(hist.count) * float64(time.Second))
+		}
+		recent := m.recentDurations[endpoint]
 		
 		requestStats[endpoint] = map[string]interface{}{
 			"count":   count,
 			"avg_ms":  avg.Milliseconds(),
-			"p95_ms":  p95.Milliseconds(),
+			"p50_ms":  calculatePercentile(recent, 0.50).Milliseconds(),
+			"p95_ms":  calculatePercentile(recent, 0.95).Milliseconds(),
+			"p99_ms":  calculatePercentile(recent, 0.99).Milliseconds(),
 		}
 	}
 	stats["requests"] = requestStats
@@ -173,43 +251,69 @@ func (m *Metrics) GetStats() map[string]interface{} {
 		"connections": m.sseConnections,
 		"disconnects": m.sseDisconnects,
 		"errors":      m.sseErrors,
+		"rejected":    m.sseRejected,
 		"active":      m.sseConnections - m.sseDisconnects,
 	}
 	
-	// Error stats
-	stats["errors"] = m.errorsByType
-	
-	return stats
-}
+	// Error stats (copied so callers can read them after the lock is released)
+	errorStats := make(map[string]int64, len(m.errorsByType))
+	for errorType, count := range m.errorsByType {
+		errorStats[errorType] = count
+	}
+	stats["errors"] = errorStats
 
-// calculateAverage calculates the average duration
-func calculateAverage(durations []time.Duration) time.Duration {
-	if len(durations) == 0 {
-		return 0
+	formattingStats := make(map[string]int64, len(m.formattingViolations))
+	for rule, count := range m.formattingViolations {
+		formattingStats[rule] = count
 	}
-	
-	var total time.Duration
-	for _, d := range durations {
-		total += d
+	stats["formatting_violations"] = formattingStats
+
+	// Gemini limiter stats
+	var avgWait time.Duration
+	if m.geminiQueueWait.count > 0 {
+		avgWait = time.Duration(m.geminiQueueWait.sum / float64(m.geminiQueueWait.count) * float64(time.Second))
+	}
+	stats["gemini_queue"] = map[string]interface{}{
+		"depth":       m.geminiQueueDepth,
+		"waits":       m.geminiQueueWait.count,
+		"avg_wait_ms": avgWait.Milliseconds(),
+		"timeouts":    m.geminiQueueTimeouts,
 	}
+
+	// Gemini token usage stats
+	tokenStats := make(map[string]interface{}, len(m.geminiPromptTokens))
+	for model, prompt := range m.geminiPromptTokens {
+		tokenStats[model] = map[string]interface{}{
+			"prompt":     prompt,
+			"completion": m.geminiCompletionTokens[model],
+		}
+	}
+	stats["gemini_tokens"] = tokenStats
 	
-	return total / time.Duration(len(durations))
+	return stats
 }
 
-// calculatePercentile calculates the percentile duration
+// calculatePercentile returns the nearest-rank percentile (0-1) of durations.
+// It sorts a copy, so the stored slice keeps its arrival order.
 func calculatePercentile(durations []time.Duration, percentile float64) time.Duration {
 	if len(durations) == 0 {
 		return 0
 	}
 	
-	// Simple percentile calculation (not sorting for performance)
-	// In production, use a proper percentile library
-	index := int(float64(len(durations)) * percentile)
-	if index >= len(durations) {
-		index = len(durations) - 1
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	
+	// Nearest rank: the smallest value with at least percentile of samples at or below it
+	rank := int(math.Ceil(percentile * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
 	}
 	
-	return durations[index]
+	return sorted[rank-1]
 }
 
 // Timer helps measure duration
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCalculatePercentile(t *testing.T) {
+	// 1ms..100ms, stored newest first so the input isn't already sorted
+	hundred := make([]time.Duration, 100)
+	for i := range hundred {
+		hundred[i] = time.Duration(100-i) * time.Millisecond
+	}
+
+	tests := []struct {
+		name       string
+		durations  []time.Duration
+		percentile float64
+		want       time.Duration
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single sample p50", []time.Duration{7 * time.Millisecond}, 0.5, 7 * time.Millisecond},
+		{"single sample p99", []time.Duration{7 * time.Millisecond}, 0.99, 7 * time.Millisecond},
+		{"p0 is the minimum", hundred, 0, 1 * time.Millisecond},
+		{"p50", hundred, 0.5, 50 * time.Millisecond},
+		{"p90", hundred, 0.9, 90 * time.Millisecond},
+		{"p95", hundred, 0.95, 95 * time.Millisecond},
+		{"p99", hundred, 0.99, 99 * time.Millisecond},
+		{"p100 is the maximum", hundred, 1, 100 * time.Millisecond},
+		{"between ranks rounds up", []time.Duration{1, 2, 3}, 0.5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculatePercentile(tt.durations, tt.percentile); got != tt.want {
+				t.Errorf("calculatePercentile(p=%v) = %v, want %v", tt.percentile, got, tt.want)
+			}
+		})
+	}
+
+	if hundred[0] != 100*time.Millisecond || hundred[99] != time.Millisecond {
+		t.Error("calculatePercentile reordered the caller's samples")
+	}
+}
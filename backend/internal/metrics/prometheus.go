@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxRecentDurations bounds the per-endpoint window used for percentiles
+const maxRecentDurations = 1000
+
+// requestDurationBuckets are the histogram upper bounds for request durations, in seconds
+var requestDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// histogram is a cumulative Prometheus-style histogram; it never drops samples
+type histogram struct {
+	bounds []float64
+	counts []uint64 // per-bucket (non-cumulative) counts; values above the last bound only count toward count
+	sum    float64
+	count  uint64
+}
+
+// newHistogram creates an empty histogram with the given bucket upper bounds
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// observe records one value
+func (h *histogram) observe(value float64) {
+	h.sum += value
+	h.count++
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// WritePrometheus renders the metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+
+	writeHeader(bw, "simon_requests_total", "counter", "Total HTTP requests by endpoint.")
+	for _, endpoint := range sortedKeys(m.requestCount) {
+		fmt.Fprintf(bw, "simon_requests_total{endpoint=%s} %d\n", quoteLabel(endpoint), m.requestCount[endpoint])
+	}
+
+	writeHeader(bw, "simon_request_duration_seconds", "histogram", "HTTP request duration by endpoint.")
+	for _, endpoint := range sortedKeys(m.requestDuration) {
+		writeHistogram(bw, "simon_request_duration_seconds", "endpoint="+quoteLabel(endpoint), m.requestDuration[endpoint])
+	}
+
+	writeHeader(bw, "simon_pipeline_step_duration_seconds", "gauge", "Duration of the most recent run of each pipeline step.")
+	for _, step := range sortedKeys(m.pipelineSteps) {
+		fmt.Fprintf(bw, "simon_pipeline_step_duration_seconds{step=%s} %s\n", quoteLabel(step), formatFloat(m.pipelineSteps[step].Seconds()))
+	}
+
+	writeHeader(bw, "simon_pipeline_errors_total", "counter", "Total pipeline errors.")
+	fmt.Fprintf(bw, "simon_pipeline_errors_total %d\n", m.pipelineErrors)
+
+	writeHeader(bw, "simon_tool_executions_total", "counter", "Total tool executions by tool.")
+	for _, toolID := range sortedKeys(m.toolExecutions) {
+		fmt.Fprintf(bw, "simon_tool_executions_total{tool_id=%s} %d\n", quoteLabel(toolID), m.toolExecutions[toolID])
+	}
+
+	writeHeader(bw, "simon_tool_errors_total", "counter", "Total failed tool executions by tool.")
+	for _, toolID := range sortedKeys(m.toolErrors) {
+		fmt.Fprintf(bw, "simon_tool_errors_total{tool_id=%s} %d\n", quoteLabel(toolID), m.toolErrors[toolID])
+	}
+
+	writeHeader(bw, "simon_sse_connections_total", "counter", "Total SSE connections opened.")
+	fmt.Fprintf(bw, "simon_sse_connections_total %d\n", m.sseConnections)
+	writeHeader(bw, "simon_sse_disconnects_total", "counter", "Total SSE connections closed.")
+	fmt.Fprintf(bw, "simon_sse_disconnects_total %d\n", m.sseDisconnects)
+	writeHeader(bw, "simon_sse_errors_total", "counter", "Total SSE errors.")
+	fmt.Fprintf(bw, "simon_sse_errors_total %d\n", m.sseErrors)
+	writeHeader(bw, "simon_sse_rejected_total", "counter", "Total SSE connections refused by the per-user stream limit.")
+	fmt.Fprintf(bw, "simon_sse_rejected_total %d\n", m.sseRejected)
+	writeHeader(bw, "simon_sse_active", "gauge", "Currently open SSE connections.")
+	fmt.Fprintf(bw, "simon_sse_active %d\n", m.sseConnections-m.sseDisconnects)
+
+	writeHeader(bw, "simon_errors_total", "counter", "Total errors by type.")
+	for _, errorType := range sortedKeys(m.errorsByType) {
+		fmt.Fprintf(bw, "simon_errors_total{type=%s} %d\n", quoteLabel(errorType), m.errorsByType[errorType])
+	}
+
+	writeHeader(bw, "simon_formatting_violations_total", "counter", "Coach replies that broke their formatting limits, by rule.")
+	for _, rule := range sortedKeys(m.formattingViolations) {
+		fmt.Fprintf(bw, "simon_formatting_violations_total{rule=%s} %d\n", quoteLabel(rule), m.formattingViolations[rule])
+	}
+
+	writeHeader(bw, "simon_gemini_queue_depth", "gauge", "Gemini calls waiting for a free slot.")
+	fmt.Fprintf(bw, "simon_gemini_queue_depth %d\n", m.geminiQueueDepth)
+
+	writeHeader(bw, "simon_gemini_queue_wait_seconds", "histogram", "Time Gemini calls waited for a free slot.")
+	writeHistogram(bw, "simon_gemini_queue_wait_seconds", "", m.geminiQueueWait)
+
+	writeHeader(bw, "simon_gemini_queue_timeouts_total", "counter", "Gemini calls that gave up waiting for a slot.")
+	fmt.Fprintf(bw, "simon_gemini_queue_timeouts_total %d\n", m.geminiQueueTimeouts)
+
+	writeHeader(bw, "simon_gemini_prompt_tokens_total", "counter", "Gemini prompt tokens by model.")
+	for _, model := range sortedKeys(m.geminiPromptTokens) {
+		fmt.Fprintf(bw, "simon_gemini_prompt_tokens_total{model=%s} %d\n", quoteLabel(model), m.geminiPromptTokens[model])
+	}
+
+	writeHeader(bw, "simon_gemini_completion_tokens_total", "counter", "Gemini completion tokens by model.")
+	for _, model := range sortedKeys(m.geminiCompletionTokens) {
+		fmt.Fprintf(bw, "simon_gemini_completion_tokens_total{model=%s} %d\n", quoteLabel(model), m.geminiCompletionTokens[model])
+	}
+
+	return bw.Flush()
+}
+
+// writeHeader writes the HELP and TYPE lines for a metric family
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeHistogram writes the bucket, sum and count series of one histogram; labels may be empty
+func writeHistogram(w io.Writer, name, labels string, hist *histogram) {
+	prefix := ""
+	if labels != "" {
+		prefix = labels + ","
+	}
+
+	var cumulative uint64
+	for i, bound := range hist.bounds {
+		cumulative += hist.counts[i]
+		fmt.Fprintf(w, "%s_bucket{%sle=%q} %d\n", name, prefix, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, hist.count)
+
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(w, "%s_sum%s %s\n", name, labels, formatFloat(hist.sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", name, labels, hist.count)
+}
+
+// labelEscaper escapes backslashes, quotes and newlines in label values
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel renders a quoted, escaped label value
+func quoteLabel(value string) string {
+	return `"` + labelEscaper.Replace(value) + `"`
+}
+
+// formatFloat renders a float the way Prometheus expects (shortest representation)
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sortedKeys returns map keys in order so the output is stable between scrapes
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"strings"
+
+	"simon-backend/internal/textutil"
+)
+
+// EffectiveSpec returns the coach's CoachSpec, deriving one from the Blueprint for older coaches
+func (c Coach) EffectiveSpec() *CoachSpec {
+	if c.CoachSpec != nil {
+		return c.CoachSpec
+	}
+	return BlueprintToCoachSpec(c)
+}
+
+// BlueprintToCoachSpec builds a full CoachSpec from a coach's deprecated Blueprint. Tone, rules,
+// framework and safety flags carry over; everything the blueprint never described gets the same
+// conservative defaults as the built-in coaches. The coach itself is not modified.
+func BlueprintToCoachSpec(coach Coach) *CoachSpec {
+	blueprint := coach.Blueprint
+	style := blueprintMap(blueprint, "style")
+	rules := blueprintMap(blueprint, "rules")
+	framework := blueprintMap(blueprint, "framework")
+	safety := blueprintMap(blueprint, "safety")
+
+	tone := blueprintString(style, "tone", "calm_direct")
+	answerShape := blueprintString(rules, "defaultAnswerShape", "")
+	noMedicalLegal := blueprintBool(safety, "noMedicalLegalClaims", true)
+
+	spec := &CoachSpec{
+		Version: "1.0",
+		Identity: Identity{
+			Name:      textutil.TruncateSafe(coach.Title, 100),
+			Tagline:   textutil.TruncateSafe(firstNonEmpty(coach.Promise, coach.Title), 200),
+			Niche:     blueprintNiche(coach.Tags),
+			Audience:  []string{"general"},
+			Languages: []string{"en"},
+			Persona: Persona{
+				Archetype:  "coach",
+				Voice:      tone,
+				Boundaries: blueprintBoundaries(safety, noMedicalLegal),
+			},
+		},
+		Style: Style{
+			Tone:      tone,
+			Verbosity: blueprintVerbosity(answerShape),
+			Formatting: Formatting{
+				MaxBullets:               5,
+				MaxSentencesPerParagraph: 2,
+				AlwaysEndWith:            []string{"one_next_action"},
+				UseEmoji:                 "sparingly",
+				AllowedMarkdown:          []string{"bullet_list", "numbered_list", "bold"},
+			},
+			InteractionRules: InteractionRules{
+				AskOneQuestionAtATime: blueprintBool(rules, "alwaysAskOneClarifyingQuestionFirst", false) ||
+					blueprintString(style, "question_style", "") == "single_question_first",
+				ConfirmBeforeScheduling: true,
+				AvoidMotivationalFluff:  true,
+				ReflectUserLanguage:     true,
+			},
+		},
+		Policies: Policies{
+			Refusals: Refusals{
+				Medical:         noMedicalLegal,
+				Legal:           noMedicalLegal,
+				FinancialAdvice: "general_only",
+				SelfHarm:        "escalate_support",
+			},
+			Privacy: Privacy{
+				StoreSensitiveMemory: false,
+				RedactPatterns:       []string{"password", "api_key", "credit_card"},
+			},
+			Safety: Safety{
+				NoManipulation: true,
+				NoGuilt:        true,
+				NoShaming:      true,
+			},
+		},
+		ToolsAllowed: ToolsAllowed{
+			ClientTools:              []string{"local_notification_schedule", "calendar_event_create", "reminder_create"},
+			ServerTools:              []string{"memory_write", "plan_list_active"},
+			RequiresUserConfirmation: []string{"local_notification_schedule", "calendar_event_create", "reminder_create"},
+		},
+		Outputs: Outputs{
+			Schemas: OutputSchemas{
+				Plan:         blueprintSchema("title", "objective", "horizon", "milestones", "next_actions"),
+				NextAction:   blueprintSchema("id", "title", "duration_min", "energy", "when"),
+				WeeklyReview: blueprintSchema("wins", "misses", "root_causes", "next_week_focus", "commitments"),
+			},
+			RenderingHints: RenderingHints{
+				PrimaryCard:         "next_actions",
+				MaxCardsPerResponse: 2,
+			},
+		},
+		DefaultMode: blueprintMode(answerShape),
+	}
+
+	if blueprintBool(rules, "respectContextVault", true) {
+		spec.ToolsAllowed.ServerTools = append(spec.ToolsAllowed.ServerTools, "memory_read")
+	}
+	if blueprintBool(rules, "offerSystemWhenUseful", false) {
+		spec.ToolsAllowed.ServerTools = append(spec.ToolsAllowed.ServerTools, "plan_create", "plan_update")
+	}
+
+	if steps := blueprintSteps(framework); len(steps) > 0 {
+		name := blueprintString(framework, "name", coach.Title)
+		spec.Methods.Frameworks = []Framework{{
+			ID:    firstNonEmpty(blueprintSlug(name), "framework"),
+			Name:  name,
+			Goal:  firstNonEmpty(coach.Promise, name),
+			Steps: steps,
+		}}
+		spec.Methods.DefaultProtocols.DeepSession.Phases = steps
+	}
+
+	return spec
+}
+
+// blueprintSchema describes an object card schema requiring the given (otherwise unconstrained) fields
+func blueprintSchema(required ...string) SchemaDefinition {
+	properties := make(map[string]interface{}, len(required))
+	for _, field := range required {
+		properties[field] = map[string]interface{}{}
+	}
+	return SchemaDefinition{Type: "object", Required: required, Properties: properties}
+}
+
+// blueprintMap returns a nested blueprint section, or nil
+func blueprintMap(m map[string]interface{}, key string) map[string]interface{} {
+	section, _ := m[key].(map[string]interface{})
+	return section
+}
+
+// blueprintString returns a non-empty string field, or fallback
+func blueprintString(m map[string]interface{}, key, fallback string) string {
+	if value, ok := m[key].(string); ok && strings.TrimSpace(value) != "" {
+		return strings.TrimSpace(value)
+	}
+	return fallback
+}
+
+// blueprintBool returns a boolean field, or fallback when it is absent
+func blueprintBool(m map[string]interface{}, key string, fallback bool) bool {
+	if value, ok := m[key].(bool); ok {
+		return value
+	}
+	return fallback
+}
+
+// blueprintSteps returns the framework step labels; steps may be {"label": ...} maps or plain strings
+func blueprintSteps(framework map[string]interface{}) []string {
+	raw, _ := framework["steps"].([]interface{})
+	steps := []string{}
+	for _, step := range raw {
+		switch s := step.(type) {
+		case string:
+			if strings.TrimSpace(s) != "" {
+				steps = append(steps, strings.TrimSpace(s))
+			}
+		case map[string]interface{}:
+			if label := blueprintString(s, "label", ""); label != "" {
+				steps = append(steps, label)
+			}
+		}
+	}
+	return steps
+}
+
+// blueprintBoundaries turns the blueprint safety flags into persona boundaries
+func blueprintBoundaries(safety map[string]interface{}, noMedicalLegal bool) []string {
+	boundaries := []string{}
+	if noMedicalLegal {
+		boundaries = append(boundaries, "no medical advice", "no legal advice")
+	}
+	if blueprintBool(safety, "encourageProfessionalHelpWhenNeeded", true) {
+		boundaries = append(boundaries, "suggest professional help when appropriate")
+	}
+	return boundaries
+}
+
+// blueprintVerbosity maps the blueprint's default answer shape to a verbosity
+func blueprintVerbosity(answerShape string) string {
+	switch answerShape {
+	case "system":
+		return "medium"
+	case "deep":
+		return "high"
+	default:
+		return "low"
+	}
+}
+
+// blueprintMode maps the blueprint's default answer shape to a default session mode
+func blueprintMode(answerShape string) string {
+	switch answerShape {
+	case "system":
+		return SessionModeSystem
+	case "deep":
+		return SessionModeDeep
+	default:
+		return ""
+	}
+}
+
+// blueprintNiche derives a niche from the coach's first tag
+func blueprintNiche(tags []string) string {
+	for _, tag := range tags {
+		if slug := blueprintSlug(tag); slug != "" {
+			return slug
+		}
+	}
+	return "general"
+}
+
+// blueprintSlug lowercases s and joins its words with underscores
+func blueprintSlug(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}), "_")
+}
+
+// firstNonEmpty returns the first non-blank value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,16 @@
+package models
+
+import "simon-backend/internal/textutil"
+
+// BuildSearchTokens returns the folded words of the coach's title, promise, tags and niche,
+// stored as search_tokens so directory search can match them with array-contains-any
+func (c Coach) BuildSearchTokens() []string {
+	text := c.Title + " " + c.Promise
+	for _, tag := range c.Tags {
+		text += " " + tag
+	}
+	if c.CoachSpec != nil {
+		text += " " + c.CoachSpec.Identity.Niche
+	}
+	return textutil.SearchTokens(text)
+}
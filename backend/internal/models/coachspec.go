@@ -1,6 +1,16 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"simon-backend/internal/textutil"
+)
+
+// Starter prompt limits
+const (
+	MaxStarterPrompts     = 5
+	MaxStarterPromptRunes = 120
+)
 
 // CoachSpec defines the structured specification for a coach's behavior, style, and capabilities
 type CoachSpec struct {
@@ -11,6 +21,15 @@ type CoachSpec struct {
 	Policies  Policies       `firestore:"policies" json:"policies"`
 	ToolsAllowed ToolsAllowed `firestore:"tools_allowed" json:"tools_allowed"`
 	Outputs   Outputs        `firestore:"outputs" json:"outputs"`
+	// DefaultMode is the session mode ("quick" | "system" | "deep") used when the client doesn't choose one
+	DefaultMode string `firestore:"default_mode,omitempty" json:"default_mode,omitempty"`
+	// AcceptsImages set to false makes the coach ignore image attachments; unset accepts them
+	AcceptsImages *bool `firestore:"accepts_images,omitempty" json:"accepts_images,omitempty"`
+}
+
+// ImagesAccepted reports whether image attachments reach the coach
+func (s CoachSpec) ImagesAccepted() bool {
+	return s.AcceptsImages == nil || *s.AcceptsImages
 }
 
 // Identity defines the coach's identity and positioning
@@ -23,6 +42,26 @@ type Identity struct {
 	Outcomes          []string `firestore:"outcomes" json:"outcomes"`
 	Languages         []string `firestore:"languages" json:"languages"`
 	Persona           Persona  `firestore:"persona" json:"persona"`
+	StarterPrompts    []string `firestore:"starterPrompts,omitempty" json:"starterPrompts,omitempty"` // "try asking…" chips
+}
+
+// ResolvedStarterPrompts returns the explicit starter prompts, or derives them from ProblemStatements
+func (i Identity) ResolvedStarterPrompts() []string {
+	if len(i.StarterPrompts) > 0 {
+		return i.StarterPrompts
+	}
+
+	prompts := []string{}
+	for _, statement := range i.ProblemStatements {
+		if len(prompts) == MaxStarterPrompts {
+			break
+		}
+		if statement == "" {
+			continue
+		}
+		prompts = append(prompts, textutil.TruncateSafe(statement, MaxStarterPromptRunes))
+	}
+	return prompts
 }
 
 // Persona defines the coach's personality and boundaries
@@ -40,6 +79,12 @@ type Style struct {
 	InteractionRules InteractionRules `firestore:"interactionRules" json:"interactionRules"`
 }
 
+// StyleAdjustment overrides a coach's style for a single regenerated reply; it is never persisted
+type StyleAdjustment struct {
+	Verbosity string `json:"verbosity,omitempty"` // "low" | "medium" | "high"
+	Tone      string `json:"tone,omitempty"`      // "warmer" | "more_direct" | "less_formal" | "more_formal"
+}
+
 // Formatting defines formatting constraints for coach responses
 type Formatting struct {
 	MaxBullets               int      `firestore:"maxBullets" json:"maxBullets"`
@@ -72,6 +117,40 @@ type Framework struct {
 	WhenToUse []string `firestore:"whenToUse" json:"whenToUse"`
 }
 
+// FrameworkTriggers is the canonical vocabulary for Framework.WhenToUse, shared with the router
+// and with clients that offer the triggers as choices
+var FrameworkTriggers = []string{
+	"feeling_stuck",
+	"overwhelmed",
+	"procrastinating",
+	"feeling_scattered",
+	"sunday_review",
+	"end_of_week",
+	"multiple_options",
+	"complex_decision",
+	"life_decision",
+	"career_choice",
+	"stuck_on_project",
+	"perfectionism",
+	"need_momentum",
+	"blank_page",
+	"building_new_habit",
+	"habit_not_sticking",
+	"self_doubt",
+	"imposter_syndrome",
+	"fear_of_failure",
+}
+
+// IsFrameworkTrigger reports whether trigger is in FrameworkTriggers
+func IsFrameworkTrigger(trigger string) bool {
+	for _, known := range FrameworkTriggers {
+		if known == trigger {
+			return true
+		}
+	}
+	return false
+}
+
 // DefaultProtocols defines default coaching protocols for different session types
 type DefaultProtocols struct {
 	QuickNudge  Protocol `firestore:"quickNudge" json:"quickNudge"`
@@ -97,6 +176,10 @@ type Refusals struct {
 	Legal           bool   `firestore:"legal" json:"legal"`
 	FinancialAdvice string `firestore:"financial_advice" json:"financial_advice"` // "general_only" or other values
 	SelfHarm        string `firestore:"self_harm" json:"self_harm"`               // "escalate_support" or other values
+	// Messages overrides refusal wording per kind ("medical", "legal", "financial"); self-harm always uses a vetted template
+	Messages map[string]string `firestore:"messages,omitempty" json:"messages,omitempty"`
+	// Sensitivity is "standard" (default; refuse when the advice is asked for or given) or "strict" (also on topic words)
+	Sensitivity string `firestore:"sensitivity,omitempty" json:"sensitivity,omitempty"`
 }
 
 // Privacy defines privacy and data handling policies
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestResolvedStarterPrompts(t *testing.T) {
+	long := strings.Repeat("é", MaxStarterPromptRunes+10)
+
+	tests := []struct {
+		name     string
+		identity Identity
+		want     []string
+	}{
+		{
+			name:     "nothing to derive from",
+			identity: Identity{},
+			want:     []string{},
+		},
+		{
+			name: "derived from problem statements",
+			identity: Identity{ProblemStatements: []string{
+				"I keep procrastinating on deep work",
+				"",
+				"My mornings have no structure",
+			}},
+			want: []string{"I keep procrastinating on deep work", "My mornings have no structure"},
+		},
+		{
+			name:     "derivation stops at the limit",
+			identity: Identity{ProblemStatements: []string{"a", "b", "c", "d", "e", "f", "g"}},
+			want:     []string{"a", "b", "c", "d", "e"},
+		},
+		{
+			name: "explicit prompts win over problem statements",
+			identity: Identity{
+				ProblemStatements: []string{"I keep procrastinating on deep work"},
+				StarterPrompts:    []string{"Help me plan tomorrow", "What should I cut this week?"},
+			},
+			want: []string{"Help me plan tomorrow", "What should I cut this week?"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.identity.ResolvedStarterPrompts()
+			if got == nil || !slices.Equal(got, tt.want) {
+				t.Errorf("ResolvedStarterPrompts() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A derived prompt is cut to the rune limit without splitting a rune
+	got := Identity{ProblemStatements: []string{long}}.ResolvedStarterPrompts()
+	if len(got) != 1 || !utf8.ValidString(got[0]) || utf8.RuneCountInString(got[0]) > MaxStarterPromptRunes {
+		t.Errorf("long statement derived %q, want at most %d whole runes", got, MaxStarterPromptRunes)
+	}
+}
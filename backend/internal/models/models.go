@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // Coach represents an AI coach configuration
 type Coach struct {
@@ -13,8 +16,44 @@ type Coach struct {
 	Blueprint  map[string]interface{} `firestore:"blueprint" json:"blueprint"` // Deprecated: use CoachSpec instead
 	CoachSpec  *CoachSpec             `firestore:"coachSpec,omitempty" json:"coachSpec,omitempty"`
 	Stats      CoachStats             `firestore:"stats" json:"stats"`
-	CreatedAt  time.Time              `firestore:"created_at" json:"created_at"`
-	UpdatedAt  time.Time              `firestore:"updated_at" json:"updated_at"`
+	Status     string                 `firestore:"status,omitempty" json:"status,omitempty"`           // "" (active) | "draft" | "deleted"
+	MergedInto string                 `firestore:"merged_into,omitempty" json:"merged_into,omitempty"` // set when deleted by a merge
+	// SearchTokens index the coach for directory search; see BuildSearchTokens
+	SearchTokens []string  `firestore:"search_tokens,omitempty" json:"-"`
+	CreatedAt    time.Time `firestore:"created_at" json:"created_at"`
+	UpdatedAt    time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// Coach lifecycle states; an empty status means the coach is active
+const (
+	CoachStatusDraft   = "draft"
+	CoachStatusDeleted = "deleted"
+)
+
+// SystemOwnerUID owns the built-in coaches seeded for everyone
+const SystemOwnerUID = "system"
+
+// IsStartable reports whether new sessions may be started with the coach
+func (c Coach) IsStartable() bool {
+	return c.Status != CoachStatusDraft && c.Status != CoachStatusDeleted
+}
+
+// CoachSample is a cached example exchange shown on a coach's directory card
+type CoachSample struct {
+	CoachID   string    `firestore:"coach_id" json:"coach_id"`
+	Version   string    `firestore:"version" json:"version"` // coach version the sample was generated for
+	UserText  string    `firestore:"user_text" json:"user_text"`
+	CoachText string    `firestore:"coach_text" json:"coach_text"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+}
+
+// SampleVersion identifies the coach revision a sample was generated from
+func (c Coach) SampleVersion() string {
+	specVersion := ""
+	if c.CoachSpec != nil {
+		specVersion = c.CoachSpec.Version
+	}
+	return fmt.Sprintf("%s@%d", specVersion, c.UpdatedAt.Unix())
 }
 
 // CoachStats tracks coach usage metrics
@@ -24,15 +63,61 @@ type CoachStats struct {
 	Upvotes int `firestore:"upvotes" json:"upvotes"`
 }
 
-// Session represents a coaching conversation
-type Session struct {
-	ID        string    `firestore:"id" json:"id"`
+// CoachUpvote records one user's upvote, stored at coaches/{coachID}/upvotes/{uid}
+type CoachUpvote struct {
 	UID       string    `firestore:"uid" json:"uid"`
-	CoachID   *string   `firestore:"coach_id,omitempty" json:"coach_id,omitempty"`
-	Title     string    `firestore:"title" json:"title"`
-	Mode      string    `firestore:"mode" json:"mode"` // "quick" | "system" | "deep"
 	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
-	UpdatedAt time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// SavedCoach bookmarks a coach for a user, stored at users/{uid}/saved_coaches/{coachID}
+type SavedCoach struct {
+	CoachID   string    `firestore:"coach_id" json:"coach_id"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
+}
+
+// Session represents a coaching conversation
+type Session struct {
+	ID         string     `firestore:"id" json:"id"`
+	UID        string     `firestore:"uid" json:"uid"`
+	CoachID    *string    `firestore:"coach_id,omitempty" json:"coach_id,omitempty"`
+	Title      string     `firestore:"title" json:"title"`
+	Mode       string     `firestore:"mode" json:"mode"` // "quick" | "system" | "deep"
+	CreatedAt  time.Time  `firestore:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `firestore:"updated_at" json:"updated_at"`
+	Archived   bool       `firestore:"archived" json:"archived"`
+	ArchivedAt *time.Time `firestore:"archived_at,omitempty" json:"archived_at,omitempty"`
+	// IncludeContext overrides Preferences.IncludeContext for this session when set
+	IncludeContext *bool `firestore:"include_context,omitempty" json:"include_context,omitempty"`
+	// CoachSpecSnapshot is the coach's spec when the session started; used if the coach goes away
+	CoachSpecSnapshot *CoachSpec `firestore:"coach_spec_snapshot,omitempty" json:"-"`
+	// CoachUnavailableNotified is set once the user has been told the coach is no longer available
+	CoachUnavailableNotified bool `firestore:"coach_unavailable_notified,omitempty" json:"-"`
+}
+
+// DefaultSessionTitle is the title of a session nobody has named yet
+const DefaultSessionTitle = "New Session"
+
+// MaxSessionTitleRunes bounds session titles, whether set by the user or generated
+const MaxSessionTitleRunes = 80
+
+// Session modes
+const (
+	SessionModeQuick  = "quick"
+	SessionModeSystem = "system"
+	SessionModeDeep   = "deep"
+)
+
+// ValidSessionMode reports whether mode is one of the session modes
+func ValidSessionMode(mode string) bool {
+	return mode == SessionModeQuick || mode == SessionModeSystem || mode == SessionModeDeep
+}
+
+// DefaultSessionMode is the mode a session with this coach opens in when the client doesn't pick one
+func (c Coach) DefaultSessionMode() string {
+	if c.CoachSpec != nil && c.CoachSpec.DefaultMode != "" {
+		return c.CoachSpec.DefaultMode
+	}
+	return SessionModeQuick
 }
 
 // Message represents a single message in a conversation
@@ -45,12 +130,28 @@ type Message struct {
 }
 
 // Attachment represents a file attachment
+//
+// Fields after DownloadURL were added later and are all omitempty. Older stored
+// attachments (image-only, three fields) decode with them left zero, and new fields are
+// never required, so no backfill is needed: readers treat an empty MimeType as unknown
+// and an empty Transcript as "not transcribed".
 type Attachment struct {
-	Type        string `firestore:"type" json:"type"` // "image"
+	Type        string `firestore:"type" json:"type"` // "image" | "audio"
 	StoragePath string `firestore:"storage_path" json:"storage_path"`
 	DownloadURL string `firestore:"download_url" json:"download_url"`
+
+	MimeType        string  `firestore:"mime_type,omitempty" json:"mime_type,omitempty"` // e.g. "image/jpeg", "audio/m4a"
+	SizeBytes       int64   `firestore:"size_bytes,omitempty" json:"size_bytes,omitempty"`
+	Transcript      string  `firestore:"transcript,omitempty" json:"transcript,omitempty"`             // audio only
+	DurationSeconds float64 `firestore:"duration_seconds,omitempty" json:"duration_seconds,omitempty"` // audio only
 }
 
+// Attachment types
+const (
+	AttachmentTypeImage = "image"
+	AttachmentTypeAudio = "audio"
+)
+
 // System represents a pinned system/routine
 type System struct {
 	ID                 string    `firestore:"id" json:"id"`
@@ -60,6 +161,7 @@ type System struct {
 	ScheduleSuggestion string    `firestore:"schedule_suggestion,omitempty" json:"schedule_suggestion,omitempty"`
 	Metrics            []string  `firestore:"metrics,omitempty" json:"metrics,omitempty"`
 	SourceSessionID    string    `firestore:"source_session_id" json:"source_session_id"`
+	SourceSessionTitle string    `firestore:"-" json:"source_session_title,omitempty"` // joined at read time
 	CreatedAt          time.Time `firestore:"created_at" json:"created_at"`
 }
 
@@ -80,7 +182,9 @@ type UserContext struct {
 
 // CreateSessionRequest represents the request to create a new session
 type CreateSessionRequest struct {
-	CoachID string `json:"coach_id"`
+	CoachID        string `json:"coach_id"`
+	Mode           string `json:"mode,omitempty"` // defaults to the coach's default mode
+	IncludeContext *bool  `json:"include_context,omitempty"`
 }
 
 // SendMessageRequest represents the request to send a message
@@ -104,25 +208,45 @@ type User struct {
 	ContextVault      UserContext        `firestore:"context_vault" json:"context_vault"`
 	Preferences       Preferences        `firestore:"preferences" json:"preferences"`
 	MemorySummary     string             `firestore:"memory_summary,omitempty" json:"memory_summary,omitempty"`
+	MemoryInsightHash string             `firestore:"memory_insight_hash,omitempty" json:"-"` // last insight folded into MemorySummary
 	Commitments       []Commitment       `firestore:"commitments,omitempty" json:"commitments,omitempty"`
 	SubscriptionCache *SubscriptionCache `firestore:"subscription_cache,omitempty" json:"subscription_cache,omitempty"`
+	Status            string             `firestore:"status,omitempty" json:"status,omitempty"`           // "" (active) | "pending_deletion"
+	PurgeAfter        *time.Time         `firestore:"purge_after,omitempty" json:"purge_after,omitempty"` // set while pending deletion
+	OnboardedAt       *time.Time         `firestore:"onboarded_at,omitempty" json:"onboarded_at,omitempty"`
+	StarterCoachID    string             `firestore:"starter_coach_id,omitempty" json:"starter_coach_id,omitempty"` // recommended from onboarding answers
 	CreatedAt         time.Time          `firestore:"created_at" json:"created_at"`
 	UpdatedAt         time.Time          `firestore:"updated_at" json:"updated_at"`
 }
 
+// UserStatusPendingDeletion marks an account scheduled for purge; it is blocked until then
+const UserStatusPendingDeletion = "pending_deletion"
+
+// PendingDeletion reports whether the account is scheduled for purge
+func (u User) PendingDeletion() bool {
+	return u.Status == UserStatusPendingDeletion
+}
+
 // SubscriptionCache represents cached subscription data from RevenueCat
 type SubscriptionCache struct {
 	Entitlements      map[string]bool `firestore:"entitlements" json:"entitlements"`
 	ProductIdentifier string          `firestore:"product_identifier,omitempty" json:"product_identifier,omitempty"`
 	ExpiresDate       *time.Time      `firestore:"expires_date,omitempty" json:"expires_date,omitempty"`
 	PeriodType        string          `firestore:"period_type,omitempty" json:"period_type,omitempty"` // "trial" | "intro" | "normal"
-	Store             string          `firestore:"store,omitempty" json:"store,omitempty"`             // "app_store" | "play_store"
+	Store             string          `firestore:"store,omitempty" json:"store,omitempty"`             // "app_store" | "play_store" (most recent event)
 	LastUpdated       time.Time       `firestore:"last_updated" json:"last_updated"`
+	// Active is set while any entitlement is granted; caches written before it existed lack it
+	Active bool `firestore:"active" json:"active"`
+	// Stores tracks entitlements per store; Entitlements is the OR across stores
+	Stores map[string]StoreSubscription `firestore:"stores,omitempty" json:"stores,omitempty"`
 }
 
 // Preferences represents user preferences
 type Preferences struct {
-	IncludeContext bool `firestore:"include_context" json:"include_context"`
+	IncludeContext bool        `firestore:"include_context" json:"include_context"`
+	Timezone       string      `firestore:"timezone,omitempty" json:"timezone,omitempty"` // IANA name, e.g. "Europe/Istanbul"
+	QuietHours     *QuietHours `firestore:"quiet_hours,omitempty" json:"quiet_hours,omitempty"`
+	PreferredTone  string      `firestore:"preferred_tone,omitempty" json:"preferred_tone,omitempty"` // from onboarding, e.g. "gentle"
 }
 
 // Commitment represents a user commitment
@@ -144,8 +268,28 @@ type Plan struct {
 	Milestones  []Milestone  `firestore:"milestones,omitempty" json:"milestones,omitempty"`
 	NextActions []NextAction `firestore:"next_actions,omitempty" json:"next_actions,omitempty"`
 	Status      string       `firestore:"status" json:"status"` // "active" | "completed" | "archived"
-	CreatedAt   time.Time    `firestore:"created_at" json:"created_at"`
-	UpdatedAt   time.Time    `firestore:"updated_at" json:"updated_at"`
+	SessionID   string       `firestore:"session_id,omitempty" json:"session_id,omitempty"`
+	// Pinned marks the user's focus plan shown on home; at most one plan per user is pinned
+	Pinned bool `firestore:"pinned" json:"pinned"`
+	// Recurrence makes the plan regenerate from a template at each period boundary
+	Recurrence *PlanRecurrence `firestore:"recurrence,omitempty" json:"recurrence,omitempty"`
+	// PreviousPlanID links a regenerated plan to the plan of the period before
+	PreviousPlanID string `firestore:"previous_plan_id,omitempty" json:"previous_plan_id,omitempty"`
+	// IdempotencyKey deduplicates repeated create calls (e.g. planner + confirmed tool call)
+	IdempotencyKey string    `firestore:"idempotency_key,omitempty" json:"-"`
+	CreatedAt      time.Time `firestore:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// PlanRecurrence describes how a recurring plan regenerates
+type PlanRecurrence struct {
+	Kind string `firestore:"kind" json:"kind"` // "daily" | "weekly" | "monthly"
+	// CarryOverIncomplete moves the period's unfinished actions into the next plan
+	CarryOverIncomplete bool `firestore:"carry_over_incomplete" json:"carry_over_incomplete"`
+	// TemplateActions are the next actions every period starts with
+	TemplateActions []NextAction `firestore:"template_actions,omitempty" json:"template_actions,omitempty"`
+	// PeriodEnd is when the plan is archived and the next period's plan created
+	PeriodEnd time.Time `firestore:"period_end" json:"period_end"`
 }
 
 // Milestone represents a plan milestone
@@ -155,6 +299,7 @@ type Milestone struct {
 	Description string    `firestore:"description,omitempty" json:"description,omitempty"`
 	DueDate     time.Time `firestore:"due_date,omitempty" json:"due_date,omitempty"`
 	Status      string    `firestore:"status" json:"status"` // "pending" | "in_progress" | "completed"
+	CompletedAt time.Time `firestore:"completed_at,omitempty" json:"completed_at,omitempty"`
 }
 
 // NextAction represents an actionable task
@@ -177,16 +322,31 @@ type When struct {
 
 // Checkin represents a scheduled check-in
 type Checkin struct {
-	ID        string          `firestore:"id" json:"id"`
-	UID       string          `firestore:"uid" json:"uid"`
-	CoachID   string          `firestore:"coach_id" json:"coach_id"`
-	Cadence   CheckinCadence  `firestore:"cadence" json:"cadence"`
-	Channel   string          `firestore:"channel" json:"channel"` // "in_app" | "local_notification_proposal"
-	NextRunAt time.Time       `firestore:"next_run_at" json:"next_run_at"`
-	LastRunAt *time.Time      `firestore:"last_run_at,omitempty" json:"last_run_at,omitempty"`
-	Status    string          `firestore:"status" json:"status"` // "active" | "paused" | "deleted"
-	CreatedAt time.Time       `firestore:"created_at" json:"created_at"`
-	UpdatedAt time.Time       `firestore:"updated_at" json:"updated_at"`
+	ID        string         `firestore:"id" json:"id"`
+	UID       string         `firestore:"uid" json:"uid"`
+	CoachID   string         `firestore:"coach_id" json:"coach_id"`
+	Cadence   CheckinCadence `firestore:"cadence" json:"cadence"`
+	Channel   string         `firestore:"channel" json:"channel"` // "in_app" | "local_notification_proposal"
+	NextRunAt time.Time      `firestore:"next_run_at" json:"next_run_at"`
+	LastRunAt *time.Time     `firestore:"last_run_at,omitempty" json:"last_run_at,omitempty"`
+	Status    string         `firestore:"status" json:"status"` // "active" | "paused" | "deleted"
+	CreatedAt time.Time      `firestore:"created_at" json:"created_at"`
+	UpdatedAt time.Time      `firestore:"updated_at" json:"updated_at"`
+
+	// Nudge delivery state, maintained by the check-in executor
+	AcknowledgedAt *time.Time `firestore:"acknowledged_at,omitempty" json:"acknowledged_at,omitempty"` // last time the user opened or dismissed a nudge
+	LastNudge      string     `firestore:"last_nudge,omitempty" json:"last_nudge,omitempty"`
+	NudgeIndex     int        `firestore:"nudge_index,omitempty" json:"-"` // position in the QuickNudge template rotation
+}
+
+// Nudge is a delivered check-in prompt, stored at users/{uid}/nudges/{id}
+type Nudge struct {
+	ID        string    `firestore:"id" json:"id"`
+	CheckinID string    `firestore:"checkin_id" json:"checkin_id"`
+	CoachID   string    `firestore:"coach_id" json:"coach_id"`
+	Channel   string    `firestore:"channel" json:"channel"`
+	Text      string    `firestore:"text" json:"text"`
+	CreatedAt time.Time `firestore:"created_at" json:"created_at"`
 }
 
 // CheckinCadence represents the schedule for check-ins
@@ -200,17 +360,20 @@ type CheckinCadence struct {
 
 // ToolRun represents a tool execution record
 type ToolRun struct {
-	ID              string                 `firestore:"id" json:"id"`
-	UID             string                 `firestore:"uid" json:"uid"`
-	ToolID          string                 `firestore:"tool_id" json:"tool_id"`
-	SessionID       string                 `firestore:"session_id,omitempty" json:"session_id,omitempty"`
-	Input           map[string]interface{} `firestore:"input" json:"input"`
-	Output          map[string]interface{} `firestore:"output,omitempty" json:"output,omitempty"`
-	Status          string                 `firestore:"status" json:"status"` // "pending" | "approved" | "declined" | "executed" | "failed"
-	ExecutionToken  string                 `firestore:"execution_token,omitempty" json:"execution_token,omitempty"`
-	Error           string                 `firestore:"error,omitempty" json:"error,omitempty"`
-	CreatedAt       time.Time              `firestore:"created_at" json:"created_at"`
-	UpdatedAt       time.Time              `firestore:"updated_at" json:"updated_at"`
+	ID             string                 `firestore:"id" json:"id"`
+	UID            string                 `firestore:"uid" json:"uid"`
+	ToolID         string                 `firestore:"tool_id" json:"tool_id"`
+	SessionID      string                 `firestore:"session_id,omitempty" json:"session_id,omitempty"`
+	Input          map[string]interface{} `firestore:"input" json:"input"`
+	Reason         string                 `firestore:"reason,omitempty" json:"reason,omitempty"` // why the coach proposed the tool
+	IdempotencyKey string                 `firestore:"idempotency_key,omitempty" json:"idempotency_key,omitempty"`
+	Output         map[string]interface{} `firestore:"output,omitempty" json:"output,omitempty"`
+	Status         string                 `firestore:"status" json:"status"` // "pending" | "running" | "approved" | "declined" | "executed" | "partial" | "failed"
+	ExecutionToken string                 `firestore:"execution_token,omitempty" json:"execution_token,omitempty"`
+	Error          string                 `firestore:"error,omitempty" json:"error,omitempty"`
+	FailedSteps    []string               `firestore:"failed_steps,omitempty" json:"failed_steps,omitempty"` // set on "partial" results
+	CreatedAt      time.Time              `firestore:"created_at" json:"created_at"`
+	UpdatedAt      time.Time              `firestore:"updated_at" json:"updated_at"`
 }
 
 // WeeklyReview represents a weekly review structured output
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAttachmentJSONShapes(t *testing.T) {
+	// Stored before the schema grew: image-only, three fields
+	var old Attachment
+	if err := json.Unmarshal([]byte(`{"type":"image","storage_path":"u1/a.jpg","download_url":"https://cdn/a.jpg"}`), &old); err != nil {
+		t.Fatalf("old-shape attachment: %v", err)
+	}
+	if old != (Attachment{Type: AttachmentTypeImage, StoragePath: "u1/a.jpg", DownloadURL: "https://cdn/a.jpg"}) {
+		t.Errorf("old-shape attachment = %+v", old)
+	}
+	encoded, err := json.Marshal(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(encoded), `{"type":"image","storage_path":"u1/a.jpg","download_url":"https://cdn/a.jpg"}`; got != want {
+		t.Errorf("old-shape attachment encodes as %s, want %s", got, want)
+	}
+
+	audio := Attachment{
+		Type:            AttachmentTypeAudio,
+		StoragePath:     "u1/note.m4a",
+		DownloadURL:     "https://cdn/note.m4a",
+		MimeType:        "audio/m4a",
+		SizeBytes:       48213,
+		Transcript:      "Remind me to stretch after lunch",
+		DurationSeconds: 3.5,
+	}
+	encoded, err = json.Marshal(audio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Attachment
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded != audio {
+		t.Errorf("new-shape attachment round-tripped to %+v, want %+v", decoded, audio)
+	}
+
+	// A reader that predates the new fields ignores them
+	var legacy struct {
+		Type        string `json:"type"`
+		StoragePath string `json:"storage_path"`
+		DownloadURL string `json:"download_url"`
+	}
+	if err := json.Unmarshal(encoded, &legacy); err != nil || legacy.StoragePath != audio.StoragePath {
+		t.Errorf("old reader decoded %+v (%v)", legacy, err)
+	}
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"strings"
+
+	"simon-backend/internal/textutil"
+)
+
+// OnboardingAnswers are the optional answers a new user gives when initializing their account
+type OnboardingAnswers struct {
+	PrimaryGoal    string `json:"primary_goal,omitempty"`
+	PreferredTone  string `json:"preferred_tone,omitempty"`
+	BiggestBlocker string `json:"biggest_blocker,omitempty"`
+}
+
+// DefaultStarterCoachID is recommended when the answers don't point to a niche
+const DefaultStarterCoachID = "focus-sprint-coach"
+
+// starterCoaches maps each built-in coach to the keywords (matched as word prefixes) that suggest it,
+// in the order ties are broken
+var starterCoaches = []struct {
+	CoachID  string
+	Keywords []string
+}{
+	{"focus-sprint-coach", []string{"focus", "distract", "procrastinat", "productiv", "attention", "deep work", "deadline"}},
+	{"habit-system-coach", []string{"habit", "routine", "health", "exercis", "sleep", "consisten", "fitness", "workout"}},
+	{"weekly-review-coach", []string{"plan", "review", "organi", "overwhelm", "priorit", "week", "busy"}},
+	{"decision-matrix-coach", []string{"decid", "decision", "choice", "choos", "option", "career", "unsure"}},
+	{"creative-output-coach", []string{"creat", "writ", "art", "design", "ship", "side project", "music", "book"}},
+	{"confidence-builder-coach", []string{"confiden", "fear", "doubt", "anxi", "imposter", "self-esteem", "courage", "afraid"}},
+}
+
+// Normalize trims the answers and caps their length
+func (a OnboardingAnswers) Normalize() OnboardingAnswers {
+	return OnboardingAnswers{
+		PrimaryGoal:    textutil.TruncateSafe(strings.TrimSpace(a.PrimaryGoal), 200),
+		PreferredTone:  textutil.TruncateSafe(strings.TrimSpace(a.PreferredTone), 50),
+		BiggestBlocker: textutil.TruncateSafe(strings.TrimSpace(a.BiggestBlocker), 200),
+	}
+}
+
+// Empty reports whether no question was answered
+func (a OnboardingAnswers) Empty() bool {
+	return a.Normalize() == OnboardingAnswers{}
+}
+
+// SeedContext adds the goal and blocker to the vault, skipping entries it already holds
+func (a OnboardingAnswers) SeedContext(vault UserContext) UserContext {
+	a = a.Normalize()
+	vault.Goals = appendUnique(vault.Goals, a.PrimaryGoal)
+	vault.Constraints = appendUnique(vault.Constraints, a.BiggestBlocker)
+	return vault
+}
+
+// RecommendStarterCoach picks the built-in coach whose niche best matches the goal and blocker
+func (a OnboardingAnswers) RecommendStarterCoach() string {
+	words := strings.Fields(strings.ToLower(a.PrimaryGoal + " " + a.BiggestBlocker))
+	text := " " + strings.Join(words, " ")
+
+	best, bestScore := DefaultStarterCoachID, 0
+	for _, starter := range starterCoaches {
+		score := 0
+		for _, keyword := range starter.Keywords {
+			if strings.Contains(text, " "+keyword) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = starter.CoachID, score
+		}
+	}
+	return best
+}
+
+// appendUnique appends value unless it is empty or already present (case-insensitively)
+func appendUnique(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	for _, existing := range values {
+		if strings.EqualFold(existing, value) {
+			return values
+		}
+	}
+	return append(values, value)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestRecommendStarterCoach(t *testing.T) {
+	tests := []struct {
+		answers OnboardingAnswers
+		want    string
+	}{
+		{OnboardingAnswers{PrimaryGoal: "Stop procrastinating on my thesis", BiggestBlocker: "I get distracted by my phone"}, "focus-sprint-coach"},
+		{OnboardingAnswers{PrimaryGoal: "Build a morning routine", BiggestBlocker: "I can't stay consistent"}, "habit-system-coach"},
+		{OnboardingAnswers{PrimaryGoal: "Decide whether to switch careers"}, "decision-matrix-coach"},
+		{OnboardingAnswers{PrimaryGoal: "Finish writing my book"}, "creative-output-coach"},
+		{OnboardingAnswers{BiggestBlocker: "Imposter syndrome and fear of failing"}, "confidence-builder-coach"},
+		{OnboardingAnswers{PrimaryGoal: "I feel overwhelmed and can't prioritize"}, "weekly-review-coach"},
+		// Keywords match word prefixes only: "start" doesn't suggest the creative coach via "art"
+		{OnboardingAnswers{PrimaryGoal: "Start feeling better"}, DefaultStarterCoachID},
+		{OnboardingAnswers{}, DefaultStarterCoachID},
+	}
+	for _, tt := range tests {
+		if got := tt.answers.RecommendStarterCoach(); got != tt.want {
+			t.Errorf("RecommendStarterCoach(%+v) = %s, want %s", tt.answers, got, tt.want)
+		}
+	}
+}
+
+func TestSeedContext(t *testing.T) {
+	answers := OnboardingAnswers{PrimaryGoal: "  Run a half marathon ", BiggestBlocker: "No time after work"}
+	vault := answers.SeedContext(UserContext{Goals: []string{"Read more"}})
+	if want := []string{"Read more", "Run a half marathon"}; !slices.Equal(vault.Goals, want) {
+		t.Errorf("goals = %q, want %q", vault.Goals, want)
+	}
+	if want := []string{"No time after work"}; !slices.Equal(vault.Constraints, want) {
+		t.Errorf("constraints = %q, want %q", vault.Constraints, want)
+	}
+
+	// Seeding again adds nothing
+	again := answers.SeedContext(vault)
+	if len(again.Goals) != 2 || len(again.Constraints) != 1 {
+		t.Errorf("reseeded vault = %+v, want no duplicates", again)
+	}
+
+	if !(OnboardingAnswers{PrimaryGoal: "   "}).Empty() || answers.Empty() {
+		t.Error("Empty() misreports blank or answered onboarding")
+	}
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuietHours is a daily window, in the user's timezone, during which check-ins and
+// notifications must not fire. The window may wrap midnight (e.g. 22:00–07:00).
+type QuietHours struct {
+	Start string `firestore:"start" json:"start"` // "HH:MM"
+	End   string `firestore:"end" json:"end"`     // "HH:MM"
+}
+
+// Validate checks the window uses HH:MM times and isn't empty
+func (q QuietHours) Validate() error {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return fmt.Errorf("quiet_hours.start: %w", err)
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return fmt.Errorf("quiet_hours.end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("quiet_hours start and end must differ")
+	}
+	return nil
+}
+
+// Shift moves t to the end of the quiet window when it falls inside it
+func (q QuietHours) Shift(t time.Time, loc *time.Location) time.Time {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return t
+	}
+	end, err := parseClock(q.End)
+	if err != nil || start == end {
+		return t
+	}
+
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	endToday := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+
+	if start < end {
+		// Same-day window, e.g. 13:00–15:00
+		if minute >= start && minute < end {
+			return endToday
+		}
+		return t
+	}
+
+	// Window wraps midnight, e.g. 22:00–07:00
+	if minute >= start {
+		return endToday.AddDate(0, 0, 1)
+	}
+	if minute < end {
+		return endToday
+	}
+	return t
+}
+
+// Location returns the user's timezone, defaulting to UTC
+func (p Preferences) Location() *time.Location {
+	if p.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// ApplyQuietHours shifts a fire time out of the user's quiet hours, if any are set
+func (p Preferences) ApplyQuietHours(t time.Time) time.Time {
+	if p.QuietHours == nil {
+		return t
+	}
+	return p.QuietHours.Shift(t, p.Location())
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(s string) (int, error) {
+	parsed, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("must be HH:MM, got %q", s)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursShift(t *testing.T) {
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	overnight := QuietHours{Start: "22:00", End: "07:00"}
+	lunch := QuietHours{Start: "13:00", End: "15:00"}
+	at := func(loc *time.Location, day, hour, minute int) time.Time {
+		return time.Date(2026, 5, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name  string
+		quiet QuietHours
+		loc   *time.Location
+		t     time.Time
+		want  time.Time
+	}{
+		{"2am inside an overnight window", overnight, time.UTC, at(time.UTC, 2, 2, 0), at(time.UTC, 2, 7, 0)},
+		{"late evening moves to the next morning", overnight, time.UTC, at(time.UTC, 1, 23, 30), at(time.UTC, 2, 7, 0)},
+		{"exactly at the start is quiet", overnight, time.UTC, at(time.UTC, 1, 22, 0), at(time.UTC, 2, 7, 0)},
+		{"exactly at the end is allowed", overnight, time.UTC, at(time.UTC, 2, 7, 0), at(time.UTC, 2, 7, 0)},
+		{"just before the start is allowed", overnight, time.UTC, at(time.UTC, 1, 21, 59), at(time.UTC, 1, 21, 59)},
+		{"midday outside an overnight window", overnight, time.UTC, at(time.UTC, 1, 12, 0), at(time.UTC, 1, 12, 0)},
+		{"inside a same-day window", lunch, time.UTC, at(time.UTC, 1, 14, 10), at(time.UTC, 1, 15, 0)},
+		{"same-day window start boundary", lunch, time.UTC, at(time.UTC, 1, 13, 0), at(time.UTC, 1, 15, 0)},
+		{"same-day window end boundary", lunch, time.UTC, at(time.UTC, 1, 15, 0), at(time.UTC, 1, 15, 0)},
+		// 23:30 UTC is 02:30 the next day in Istanbul (UTC+3)
+		{"window applies in the user's timezone", overnight, istanbul, at(time.UTC, 1, 23, 30), at(istanbul, 2, 7, 0)},
+		// 20:00 UTC is 23:00 in Istanbul: quiet there, not in UTC
+		{"quiet locally but not in UTC", overnight, istanbul, at(time.UTC, 1, 20, 0), at(istanbul, 2, 7, 0)},
+		{"quiet in UTC but not locally", overnight, istanbul, at(time.UTC, 1, 5, 0), at(time.UTC, 1, 5, 0)},
+		{"unparsable window is ignored", QuietHours{Start: "late", End: "07:00"}, time.UTC, at(time.UTC, 2, 2, 0), at(time.UTC, 2, 2, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.quiet.Shift(tt.t, tt.loc); !got.Equal(tt.want) {
+				t.Errorf("Shift(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyQuietHours(t *testing.T) {
+	fireAt := time.Date(2026, 5, 2, 2, 0, 0, 0, time.UTC)
+
+	if got := (Preferences{}).ApplyQuietHours(fireAt); !got.Equal(fireAt) {
+		t.Errorf("no quiet hours: %v, want unchanged", got)
+	}
+
+	prefs := Preferences{QuietHours: &QuietHours{Start: "22:00", End: "07:00"}}
+	if got, want := prefs.ApplyQuietHours(fireAt), time.Date(2026, 5, 2, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("2am check-in = %v, want shifted to %v", got, want)
+	}
+
+	// An unknown timezone falls back to UTC
+	prefs.Timezone = "Mars/Olympus_Mons"
+	if got, want := prefs.ApplyQuietHours(fireAt), time.Date(2026, 5, 2, 7, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("unknown timezone: %v, want %v", got, want)
+	}
+}
+
+func TestQuietHoursValidate(t *testing.T) {
+	for _, quiet := range []QuietHours{{"22:00", "07:00"}, {"13:00", "15:00"}} {
+		if err := quiet.Validate(); err != nil {
+			t.Errorf("%+v: %v", quiet, err)
+		}
+	}
+	for _, quiet := range []QuietHours{{"22:00", "22:00"}, {"25:00", "07:00"}, {"22:00", "7am"}, {"", ""}} {
+		if err := quiet.Validate(); err == nil {
+			t.Errorf("%+v: want an error", quiet)
+		}
+	}
+}
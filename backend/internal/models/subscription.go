@@ -0,0 +1,161 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// StoreSubscription is the subscription state reported by a single store
+type StoreSubscription struct {
+	Entitlements      map[string]bool `firestore:"entitlements" json:"entitlements"`
+	ProductIdentifier string          `firestore:"product_identifier,omitempty" json:"product_identifier,omitempty"`
+	ExpiresDate       *time.Time      `firestore:"expires_date,omitempty" json:"expires_date,omitempty"`
+	PeriodType        string          `firestore:"period_type,omitempty" json:"period_type,omitempty"`
+	LastUpdated       time.Time       `firestore:"last_updated" json:"last_updated"`
+}
+
+// unknownStore keys state from events that don't name a store
+const unknownStore = "unknown"
+
+// ApplyStoreEvent records an entitlement change from one store and recomputes the
+// effective entitlements, so an event from one store never revokes another store's purchase
+func (c *SubscriptionCache) ApplyStoreEvent(store string, entitlementIDs []string, active bool, update StoreSubscription) {
+	if store == "" {
+		store = unknownStore
+	}
+	c.seedLegacyStore()
+
+	state, ok := c.Stores[store]
+	if !ok || state.Entitlements == nil {
+		state.Entitlements = make(map[string]bool)
+	}
+	for _, entitlementID := range entitlementIDs {
+		state.Entitlements[entitlementID] = active
+	}
+	state.ProductIdentifier = update.ProductIdentifier
+	state.ExpiresDate = update.ExpiresDate
+	state.PeriodType = update.PeriodType
+	state.LastUpdated = update.LastUpdated
+	c.Stores[store] = state
+
+	c.Store = store
+	c.ProductIdentifier = update.ProductIdentifier
+	c.PeriodType = update.PeriodType
+	c.LastUpdated = update.LastUpdated
+	c.recompute()
+}
+
+// ExpireLapsed turns off entitlements for stores whose subscription has expired.
+// It returns the stores it changed, nil if nothing changed. A past expires_date left behind by a
+// cache that no longer grants anything is cleared too, so the cache stops matching expiry scans.
+func (c *SubscriptionCache) ExpireLapsed(now time.Time) []string {
+	stale := c.ExpiresDate != nil && now.After(*c.ExpiresDate)
+
+	c.seedLegacyStore()
+
+	var expired []string
+	for store, state := range c.Stores {
+		if state.ExpiresDate == nil || !now.After(*state.ExpiresDate) {
+			continue
+		}
+		changed := false
+		for entitlementID, active := range state.Entitlements {
+			if active {
+				state.Entitlements[entitlementID] = false
+				changed = true
+			}
+		}
+		if changed {
+			c.Stores[store] = state
+			expired = append(expired, store)
+		}
+	}
+
+	if len(expired) > 0 {
+		sort.Strings(expired)
+		c.LastUpdated = now
+	}
+	if len(expired) > 0 || stale {
+		c.recompute()
+	}
+	return expired
+}
+
+// ActiveEntitlements returns the entitlements still in effect at now, treating stores whose
+// subscription has expired as granting nothing. It doesn't modify the cache.
+func (c *SubscriptionCache) ActiveEntitlements(now time.Time) map[string]bool {
+	active := make(map[string]bool)
+
+	if c.Stores == nil {
+		// Cached before per-store tracking: a single expiry covers everything
+		if c.ExpiresDate != nil && now.After(*c.ExpiresDate) {
+			return active
+		}
+		for entitlementID, granted := range c.Entitlements {
+			if granted {
+				active[entitlementID] = true
+			}
+		}
+		return active
+	}
+
+	for _, state := range c.Stores {
+		if state.ExpiresDate != nil && now.After(*state.ExpiresDate) {
+			continue
+		}
+		for entitlementID, granted := range state.Entitlements {
+			if granted {
+				active[entitlementID] = true
+			}
+		}
+	}
+	return active
+}
+
+// seedLegacyStore moves entitlements cached before per-store tracking into Stores
+func (c *SubscriptionCache) seedLegacyStore() {
+	if c.Stores != nil {
+		return
+	}
+	c.Stores = make(map[string]StoreSubscription)
+	if len(c.Entitlements) == 0 {
+		return
+	}
+
+	store := c.Store
+	if store == "" {
+		store = unknownStore
+	}
+	entitlements := make(map[string]bool, len(c.Entitlements))
+	for entitlementID, active := range c.Entitlements {
+		entitlements[entitlementID] = active
+	}
+	c.Stores[store] = StoreSubscription{
+		Entitlements:      entitlements,
+		ProductIdentifier: c.ProductIdentifier,
+		ExpiresDate:       c.ExpiresDate,
+		PeriodType:        c.PeriodType,
+		LastUpdated:       c.LastUpdated,
+	}
+}
+
+// recompute derives the effective entitlements (OR across stores), whether any is active,
+// and the latest expiry among stores that still grant something
+func (c *SubscriptionCache) recompute() {
+	c.Entitlements = make(map[string]bool)
+	c.ExpiresDate = nil
+	c.Active = false
+
+	for _, state := range c.Stores {
+		grants := false
+		for entitlementID, active := range state.Entitlements {
+			c.Entitlements[entitlementID] = c.Entitlements[entitlementID] || active
+			grants = grants || active
+		}
+		c.Active = c.Active || grants
+		if grants && state.ExpiresDate != nil && (c.ExpiresDate == nil || state.ExpiresDate.After(*c.ExpiresDate)) {
+			expires := *state.ExpiresDate
+			c.ExpiresDate = &expires
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestApplyStoreEventKeepsOtherStores(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	appStoreExpiry := now.AddDate(0, 0, 3)
+	playStoreExpiry := now.AddDate(0, 1, 0)
+
+	var cache SubscriptionCache
+	cache.ApplyStoreEvent("app_store", []string{"pro"}, true, StoreSubscription{ExpiresDate: &appStoreExpiry, LastUpdated: now})
+	cache.ApplyStoreEvent("play_store", []string{"pro"}, true, StoreSubscription{ExpiresDate: &playStoreExpiry, LastUpdated: now})
+
+	// Cancelling on iOS leaves the Android purchase in force
+	cache.ApplyStoreEvent("app_store", []string{"pro"}, false, StoreSubscription{LastUpdated: now.Add(time.Hour)})
+
+	if !cache.Entitlements["pro"] || !cache.Active {
+		t.Error("app_store cancellation revoked the play_store entitlement")
+	}
+	if cache.Stores["app_store"].Entitlements["pro"] || !cache.Stores["play_store"].Entitlements["pro"] {
+		t.Errorf("per-store state = %+v", cache.Stores)
+	}
+	if cache.ExpiresDate == nil || !cache.ExpiresDate.Equal(playStoreExpiry) {
+		t.Errorf("expires = %v, want the play_store expiry", cache.ExpiresDate)
+	}
+	if cache.Store != "app_store" {
+		t.Errorf("store = %q, want the most recent event's store", cache.Store)
+	}
+
+	// Losing it on both stores revokes it
+	cache.ApplyStoreEvent("play_store", []string{"pro"}, false, StoreSubscription{LastUpdated: now.Add(2 * time.Hour)})
+	if cache.Entitlements["pro"] || cache.Active {
+		t.Error("entitlement still granted after both stores cancelled")
+	}
+	if cache.ExpiresDate != nil {
+		t.Errorf("expires = %v, want none once nothing is granted", cache.ExpiresDate)
+	}
+}
+
+func TestApplyStoreEventSeedsLegacyCache(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	expires := now.AddDate(0, 1, 0)
+	// Cached before per-store tracking, from an App Store purchase
+	cache := SubscriptionCache{
+		Entitlements: map[string]bool{"pro": true},
+		ExpiresDate:  &expires,
+		Store:        "app_store",
+	}
+
+	cache.ApplyStoreEvent("play_store", []string{"pro"}, false, StoreSubscription{LastUpdated: now})
+
+	if !cache.Entitlements["pro"] {
+		t.Error("a play_store event revoked the legacy app_store entitlement")
+	}
+	if state, ok := cache.Stores["app_store"]; !ok || !state.Entitlements["pro"] {
+		t.Errorf("legacy entitlement not moved to app_store: %+v", cache.Stores)
+	}
+}
+
+func TestApplyStoreEventWithoutStore(t *testing.T) {
+	var cache SubscriptionCache
+	cache.ApplyStoreEvent("", []string{"pro"}, true, StoreSubscription{})
+	if _, ok := cache.Stores[unknownStore]; !ok || !cache.Entitlements["pro"] {
+		t.Errorf("cache = %+v, want the entitlement under the unknown store", cache)
+	}
+}
+
+func TestActiveEntitlements(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		cache SubscriptionCache
+		want  bool
+	}{
+		{"legacy, unexpired", SubscriptionCache{Entitlements: map[string]bool{"pro": true}, ExpiresDate: &future}, true},
+		{"legacy, expired", SubscriptionCache{Entitlements: map[string]bool{"pro": true}, ExpiresDate: &past}, false},
+		{"one store expired, other current", SubscriptionCache{Stores: map[string]StoreSubscription{
+			"app_store":  {Entitlements: map[string]bool{"pro": true}, ExpiresDate: &past},
+			"play_store": {Entitlements: map[string]bool{"pro": true}, ExpiresDate: &future},
+		}}, true},
+		{"every store expired", SubscriptionCache{Stores: map[string]StoreSubscription{
+			"app_store": {Entitlements: map[string]bool{"pro": true}, ExpiresDate: &past},
+		}}, false},
+		{"no expiry", SubscriptionCache{Stores: map[string]StoreSubscription{
+			"play_store": {Entitlements: map[string]bool{"pro": true}},
+		}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cache.ActiveEntitlements(now)["pro"]; got != tt.want {
+				t.Errorf("pro = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpireLapsed(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	cache := SubscriptionCache{Stores: map[string]StoreSubscription{
+		"app_store":  {Entitlements: map[string]bool{"pro": true}, ExpiresDate: &past},
+		"play_store": {Entitlements: map[string]bool{"coach_pack": true}, ExpiresDate: &future},
+	}}
+
+	if expired := cache.ExpireLapsed(now); !slices.Equal(expired, []string{"app_store"}) {
+		t.Fatalf("expired stores = %v, want only app_store", expired)
+	}
+	if cache.Entitlements["pro"] || !cache.Entitlements["coach_pack"] {
+		t.Errorf("entitlements = %v, want only the lapsed store cleared", cache.Entitlements)
+	}
+	if cache.ExpiresDate == nil || !cache.ExpiresDate.Equal(future) {
+		t.Errorf("expires = %v, want the remaining store's expiry", cache.ExpiresDate)
+	}
+	if !cache.LastUpdated.Equal(now) {
+		t.Errorf("last updated = %v, want %v", cache.LastUpdated, now)
+	}
+	if expired := cache.ExpireLapsed(now); expired != nil {
+		t.Errorf("second ExpireLapsed expired %v, want nothing", expired)
+	}
+}
+
+func TestExpireLapsedClearsStaleExpiry(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	// Revoked before the reconciler cleared expiries; nothing is granted, but the date is still set
+	cache := SubscriptionCache{Entitlements: map[string]bool{"pro": false}, ExpiresDate: &past, Store: "app_store"}
+
+	if expired := cache.ExpireLapsed(now); expired != nil {
+		t.Errorf("expired stores = %v, want none", expired)
+	}
+	if cache.ExpiresDate != nil || cache.Active {
+		t.Errorf("expires = %v, active = %v; want the stale expiry cleared", cache.ExpiresDate, cache.Active)
+	}
+}
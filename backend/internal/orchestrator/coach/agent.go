@@ -2,20 +2,29 @@ package coach
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
 	"simon-backend/internal/gemini"
 	"simon-backend/internal/models"
 	orchestratorContext "simon-backend/internal/orchestrator/context"
+	"simon-backend/internal/tools"
 )
 
 // CoachOutput represents the output from the coach agent
 type CoachOutput struct {
+	// MessageID identifies the reply in its message.final event
+	MessageID      string
 	MessageText    string
 	ToolRequests   []ToolRequest
 	StructuredData map[string]interface{}
+	// Interrupted is true when the stream failed after some text was generated; MessageText is partial
+	Interrupted bool
+	// Stopped is true when the interruption was the turn being cancelled (a stop request or disconnect)
+	Stopped bool
 }
 
 // ToolRequest represents a tool execution request
@@ -25,6 +34,8 @@ type ToolRequest struct {
 	RequiresConfirmation  bool
 	Reason                string
 	Payload               map[string]interface{}
+	// Confidence (0.0-1.0) that the user wants this tool run; 0 when the proposer didn't report one
+	Confidence            float64
 }
 
 // SSEEvent represents a server-sent event
@@ -33,15 +44,33 @@ type SSEEvent struct {
 	Data map[string]interface{}
 }
 
+// Options configures the coach agent
+type Options struct {
+	// CoalesceInterval is the longest buffered tokens wait before being flushed as one delta.
+	// Zero disables coalescing and emits one message.delta per token.
+	CoalesceInterval time.Duration
+	// CoalesceChars flushes the buffer early once it holds at least this many bytes
+	CoalesceChars int
+
+	// Preamble is prepended to every system prompt regardless of the coach spec
+	Preamble string
+	// Footer is appended to every system prompt regardless of the coach spec
+	Footer string
+}
+
 // CoachAgent generates coaching responses using CoachSpec
 type CoachAgent struct {
-	geminiClient *gemini.Client
+	geminiClient gemini.Provider
+	tools        *tools.Registry
+	opts         Options
 }
 
 // NewCoachAgent creates a new coach agent
-func NewCoachAgent(gm *gemini.Client) *CoachAgent {
+func NewCoachAgent(gm gemini.Provider, opts Options) *CoachAgent {
 	return &CoachAgent{
 		geminiClient: gm,
+		tools:        tools.NewRegistry(),
+		opts:         opts,
 	}
 }
 
@@ -52,8 +81,25 @@ func (ca *CoachAgent) Generate(
 	contextPacket *orchestratorContext.ContextPacket,
 	stream chan<- SSEEvent,
 ) (*CoachOutput, error) {
-	// Build system prompt from CoachSpec
-	systemPrompt := ca.buildSystemPrompt(contextPacket.CoachSpec, contextPacket.User, contextPacket.ActivePlans)
+	// Build system prompt from CoachSpec, layering any one-turn style adjustment on top
+	spec := adjustedSpec(contextPacket.CoachSpec, contextPacket.StyleAdjustment)
+	user := contextPacket.User
+	if !contextPacket.IncludeContext {
+		user = nil
+	}
+	framework := selectFramework(spec.Methods.Frameworks, userMessage, contextPacket.RouteName)
+	systemPrompt := ca.buildSystemPrompt(spec, user, contextPacket.ActivePlans, framework) +
+		styleAdjustmentPrompt(contextPacket.StyleAdjustment)
+
+	// Turns dropped from the history to fit the budget reach the coach as a summary
+	if contextPacket.HistorySummary != "" {
+		systemPrompt += "\n\nSummary of the earlier part of this conversation:\n" + contextPacket.HistorySummary
+	}
+
+	// Surface the disclosure once, at the start of a conversation
+	if contextPacket.FirstTurn && ca.opts.Preamble != "" {
+		systemPrompt += fmt.Sprintf("\n\nThis is the first message of the conversation. Open your reply with this disclosure, in one short sentence: %q", ca.opts.Preamble)
+	}
 
 	// Combine system prompt with user message
 	fullPrompt := systemPrompt + "\n\nUser: " + userMessage
@@ -67,76 +113,162 @@ func (ca *CoachAgent) Generate(
 		},
 	}
 
-	// Generate streaming response from Gemini
+	// Generate streaming response from Gemini; the model proposes tools as function calls
 	fullText := ""
-	tokenChan, errChan := ca.geminiClient.GenerateContentStream(ctx, fullPrompt)
+	var calls []gemini.FunctionCall
+	partChan, errChan := ca.geminiClient.GenerateContentStreamWithTools(ctx, contextPacket.History, fullPrompt, contextPacket.Images, toolDeclarations(ca.tools, spec))
+
+	// Coalesce bursty tokens into fewer message.delta events
+	coalescer := newTokenCoalescer(ca.opts, func(delta string) {
+		stream <- SSEEvent{
+			Type: "message.delta",
+			Data: map[string]interface{}{
+				"role":  "assistant",
+				"delta": delta,
+			},
+		}
+	})
+
+	var flushTick <-chan time.Time
+	if coalescer.enabled() {
+		ticker := time.NewTicker(coalescer.interval)
+		defer ticker.Stop()
+		flushTick = ticker.C
+	}
 
 	// Stream tokens
 	for {
 		select {
-		case token, ok := <-tokenChan:
+		case part, ok := <-partChan:
 			if !ok {
-				// Stream finished
+				// Stream finished; surface any error sent before the channels closed
+				if err := <-errChan; err != nil {
+					return ca.interrupted(ctx, fullText, coalescer, stream, err)
+				}
 				goto streamDone
 			}
-			fullText += token
-			stream <- SSEEvent{
-				Type: "message.delta",
-				Data: map[string]interface{}{
-					"role":  "assistant",
-					"delta": token,
-				},
+			if part.Call != nil {
+				calls = append(calls, *part.Call)
+				continue
 			}
+			fullText += part.Text
+			coalescer.add(part.Text)
 
-		case err := <-errChan:
-			if err != nil {
-				return nil, fmt.Errorf("gemini stream failed: %w", err)
-			}
+		case <-flushTick:
+			coalescer.tick()
+
+		case <-ctx.Done():
+			// Stopped or disconnected; don't drain tokens already buffered
+			return ca.interrupted(ctx, fullText, coalescer, stream, ctx.Err())
 		}
 	}
 
 streamDone:
+	coalescer.flush()
 
 	// Send message.final event
+	messageID := generateMessageID()
 	stream <- SSEEvent{
 		Type: "message.final",
 		Data: map[string]interface{}{
-			"message_id":   generateMessageID(),
+			"message_id":   messageID,
 			"role":         "assistant",
 			"text":         fullText,
 			"render_hints": map[string]interface{}{"max_cards": 3},
 		},
 	}
 
-	// Parse tool requests from response (if any)
-	toolRequests := ca.parseToolRequests(fullText, contextPacket.CoachSpec)
-	for _, toolReq := range toolRequests {
-		stream <- SSEEvent{
-			Type: "tool.request",
-			Data: map[string]interface{}{
-				"request_id":            toolReq.RequestID,
-				"tool":                  toolReq.Tool,
-				"requires_confirmation": toolReq.RequiresConfirmation,
-				"reason":                toolReq.Reason,
-				"payload":               toolReq.Payload,
-			},
-		}
-	}
+	// Tool requests come from the model's function calls; the pipeline emits them after safety screening
+	toolRequests := ca.toolRequestsFromCalls(calls, contextPacket)
 
 	return &CoachOutput{
+		MessageID:    messageID,
 		MessageText:  fullText,
 		ToolRequests: toolRequests,
 	}, nil
 }
 
+// interrupted handles a stream error. Before any text arrives (or when content is blocked) the
+// error is returned as-is; otherwise the partial reply is finalized so the user keeps what they saw.
+// A cancelled turn was stopped on purpose, so it gets no interruption notice.
+func (ca *CoachAgent) interrupted(ctx context.Context, fullText string, coalescer *tokenCoalescer, stream chan<- SSEEvent, err error) (*CoachOutput, error) {
+	if strings.TrimSpace(fullText) == "" || errors.Is(err, gemini.ErrContentBlocked) {
+		return nil, fmt.Errorf("gemini stream failed: %w", err)
+	}
+	stopped := ctx.Err() != nil
+	log.Printf("Coach stream interrupted after %d bytes (stopped=%t): %v", len(fullText), stopped, err)
+
+	coalescer.flush()
+	messageID := generateMessageID()
+	final := map[string]interface{}{
+		"message_id":   messageID,
+		"role":         "assistant",
+		"text":         fullText,
+		"interrupted":  true,
+		"render_hints": map[string]interface{}{"max_cards": 3},
+	}
+	if stopped {
+		final["stopped"] = true
+	}
+	stream <- SSEEvent{Type: "message.final", Data: final}
+	if stopped {
+		return &CoachOutput{MessageID: messageID, MessageText: fullText, Interrupted: true, Stopped: true}, nil
+	}
+
+	stream <- SSEEvent{
+		Type: "policy.notice",
+		Data: map[string]interface{}{
+			"kind":    "generation_interrupted",
+			"message": "The response was interrupted before it finished. You can ask me to continue.",
+		},
+	}
+
+	return &CoachOutput{
+		MessageID:   messageID,
+		MessageText: fullText,
+		Interrupted: true,
+	}, nil
+}
+
+// Reformat rewrites a reply to fit the spec's formatting limits, keeping its content and voice
+func (ca *CoachAgent) Reformat(ctx context.Context, text string, formatting models.Formatting) (string, error) {
+	var limits strings.Builder
+	if formatting.MaxBullets > 0 {
+		limits.WriteString(fmt.Sprintf("- At most %d bullet points in total\n", formatting.MaxBullets))
+	}
+	if formatting.MaxSentencesPerParagraph > 0 {
+		limits.WriteString(fmt.Sprintf("- At most %d sentences per paragraph\n", formatting.MaxSentencesPerParagraph))
+	}
+
+	prompt := fmt.Sprintf(`Rewrite this coaching reply so it fits these limits:
+%s
+Keep its meaning, tone and language. Merge or drop the least important points rather than splitting them into more paragraphs. Return only the rewritten reply.
+
+Reply:
+%s`, limits.String(), text)
+
+	revised, err := ca.geminiClient.GenerateContent(ctx, prompt, "")
+	if err != nil {
+		return "", fmt.Errorf("reformat failed: %w", err)
+	}
+	return strings.TrimSpace(revised), nil
+}
+
 // buildSystemPrompt constructs the system prompt from CoachSpec
 func (ca *CoachAgent) buildSystemPrompt(
 	spec *models.CoachSpec,
 	user *models.User,
 	plans []models.Plan,
+	framework *models.Framework,
 ) string {
 	var prompt strings.Builder
 
+	// Platform-wide preamble (applies to every coach)
+	if ca.opts.Preamble != "" {
+		prompt.WriteString(ca.opts.Preamble)
+		prompt.WriteString("\n\n")
+	}
+
 	// Identity
 	prompt.WriteString(fmt.Sprintf("You are %s, a %s coach.\n\n",
 		spec.Identity.Name,
@@ -151,6 +283,12 @@ func (ca *CoachAgent) buildSystemPrompt(
 	prompt.WriteString(fmt.Sprintf("- Tone: %s\n", spec.Style.Tone))
 	prompt.WriteString(fmt.Sprintf("- Verbosity: %s\n", spec.Style.Verbosity))
 
+	if spec.Style.Formatting.MaxBullets > 0 {
+		prompt.WriteString(fmt.Sprintf("- Use at most %d bullet points\n", spec.Style.Formatting.MaxBullets))
+	}
+	if spec.Style.Formatting.MaxSentencesPerParagraph > 0 {
+		prompt.WriteString(fmt.Sprintf("- Keep paragraphs to at most %d sentences\n", spec.Style.Formatting.MaxSentencesPerParagraph))
+	}
 	if len(spec.Style.Formatting.AlwaysEndWith) > 0 {
 		prompt.WriteString(fmt.Sprintf("- Always end with: %v\n", spec.Style.Formatting.AlwaysEndWith))
 	}
@@ -188,8 +326,14 @@ func (ca *CoachAgent) buildSystemPrompt(
 		prompt.WriteString("\n")
 	}
 
-	// Methods/Frameworks
-	if len(spec.Methods.Frameworks) > 0 {
+	// Methods/Frameworks: the one matching this turn if any, otherwise all of them
+	if framework != nil {
+		prompt.WriteString(fmt.Sprintf("Use the %s framework for this reply (%s):\n", framework.Name, framework.Goal))
+		for i, step := range framework.Steps {
+			prompt.WriteString(fmt.Sprintf("%d. %s\n", i+1, step))
+		}
+		prompt.WriteString("\n")
+	} else if len(spec.Methods.Frameworks) > 0 {
 		prompt.WriteString("Available frameworks:\n")
 		for _, fw := range spec.Methods.Frameworks {
 			prompt.WriteString(fmt.Sprintf("- %s: %s\n", fw.Name, fw.Goal))
@@ -207,6 +351,7 @@ func (ca *CoachAgent) buildSystemPrompt(
 		for _, tool := range allTools {
 			prompt.WriteString(fmt.Sprintf("- %s\n", tool))
 		}
+		prompt.WriteString("Only call a tool when the user asked for it or agreed to it, and fill in every required argument.\n")
 		prompt.WriteString("\n")
 	}
 
@@ -226,42 +371,13 @@ func (ca *CoachAgent) buildSystemPrompt(
 	// Final instructions
 	prompt.WriteString("Respond naturally but follow the style guidelines. Be calm, direct, and actionable.")
 
-	return prompt.String()
-}
-
-// parseToolRequests extracts tool requests from the response text
-func (ca *CoachAgent) parseToolRequests(text string, spec *models.CoachSpec) []ToolRequest {
-	// Simple heuristic-based parsing
-	// In production, this would use structured output from Gemini
-	requests := []ToolRequest{}
-
-	// Check for calendar mentions
-	if strings.Contains(strings.ToLower(text), "calendar") || strings.Contains(strings.ToLower(text), "schedule") {
-		if ca.isToolAllowed("calendar_event_create", spec) {
-			requests = append(requests, ToolRequest{
-				RequestID:            generateRequestID(),
-				Tool:                 "calendar_event_create",
-				RequiresConfirmation: true,
-				Reason:               "Schedule the discussed action",
-				Payload:              map[string]interface{}{},
-			})
-		}
+	// Platform-wide compliance footer (applies to every coach)
+	if ca.opts.Footer != "" {
+		prompt.WriteString("\n\n")
+		prompt.WriteString(ca.opts.Footer)
 	}
 
-	// Check for reminder mentions
-	if strings.Contains(strings.ToLower(text), "remind") {
-		if ca.isToolAllowed("reminder_create", spec) {
-			requests = append(requests, ToolRequest{
-				RequestID:            generateRequestID(),
-				Tool:                 "reminder_create",
-				RequiresConfirmation: true,
-				Reason:               "Create a reminder for this action",
-				Payload:              map[string]interface{}{},
-			})
-		}
-	}
-
-	return requests
+	return prompt.String()
 }
 
 // isToolAllowed checks if a tool is allowed by the CoachSpec
@@ -0,0 +1,95 @@
+package coach
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/models"
+	orchestratorContext "simon-backend/internal/orchestrator/context"
+)
+
+// systemPrompt renders the coach prompt for spec with no plans or framework
+func systemPrompt(ca *CoachAgent, spec *models.CoachSpec, user *models.User) string {
+	return ca.buildSystemPrompt(spec, user, nil, nil)
+}
+
+func TestBuildSystemPromptPreambleAndFooter(t *testing.T) {
+	const (
+		preamble = "Simon is an AI coach, not a licensed professional."
+		footer   = "Encourage the user to consult a licensed professional for medical decisions."
+	)
+	ca := &CoachAgent{opts: Options{Preamble: preamble, Footer: footer}}
+
+	specs := map[string]*models.CoachSpec{
+		"empty spec": {},
+		"full spec": {
+			Identity: models.Identity{Name: "Focus", Niche: "productivity", Tagline: "One thing at a time"},
+			Style:    models.Style{Tone: "direct", Verbosity: "low"},
+			Policies: models.Policies{Refusals: models.Refusals{Medical: true}},
+		},
+	}
+	for name, spec := range specs {
+		prompt := systemPrompt(ca, spec, nil)
+		if !strings.HasPrefix(prompt, preamble+"\n\n") {
+			t.Errorf("%s: prompt does not open with the preamble:\n%s", name, prompt)
+		}
+		if !strings.HasSuffix(prompt, "\n\n"+footer) {
+			t.Errorf("%s: prompt does not end with the footer:\n%s", name, prompt)
+		}
+	}
+
+	// Environments can turn both off
+	prompt := systemPrompt(&CoachAgent{}, specs["full spec"], nil)
+	if strings.Contains(prompt, preamble) || strings.Contains(prompt, footer) {
+		t.Errorf("prompt without a configured preamble or footer:\n%s", prompt)
+	}
+}
+
+func TestBuildSystemPromptContextVault(t *testing.T) {
+	ca := &CoachAgent{}
+	spec := &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "mindset"}}
+	user := &models.User{ContextVault: models.UserContext{
+		Values: []string{"family first"},
+		Goals:  []string{"run a marathon"},
+	}}
+
+	withContext := systemPrompt(ca, spec, user)
+	if !strings.Contains(withContext, "family first") || !strings.Contains(withContext, "run a marathon") {
+		t.Errorf("prompt with the user's context omits the vault:\n%s", withContext)
+	}
+	if withheld := systemPrompt(ca, spec, nil); strings.Contains(withheld, "family first") || strings.Contains(withheld, "run a marathon") {
+		t.Errorf("prompt without the user's context leaks the vault:\n%s", withheld)
+	}
+}
+
+func TestGenerateOmitsExcludedContext(t *testing.T) {
+	fake := geminitest.NewFakeProvider(geminitest.Script{Prefix: "You are Sage", Text: "Let's look at this together."})
+	agent := NewCoachAgent(fake, Options{})
+	packet := &orchestratorContext.ContextPacket{
+		CoachSpec: &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "mindset"}},
+		User: &models.User{ContextVault: models.UserContext{
+			Values: []string{"family first"},
+			Goals:  []string{"run a marathon"},
+		}},
+	}
+
+	generate := func(include bool) string {
+		t.Helper()
+		packet.IncludeContext = include
+		if _, err := agent.Generate(context.Background(), "I need to talk something through", packet, make(chan SSEEvent, 100)); err != nil {
+			t.Fatal(err)
+		}
+		calls := fake.Calls()
+		return calls[len(calls)-1].SystemPrompt
+	}
+
+	if prompt := generate(true); !strings.Contains(prompt, "run a marathon") {
+		t.Errorf("included context missing from the prompt:\n%s", prompt)
+	}
+	// A session that turned context off keeps the vault out even though the user has one
+	if prompt := generate(false); strings.Contains(prompt, "run a marathon") || strings.Contains(prompt, "family first") {
+		t.Errorf("excluded context reached the prompt:\n%s", prompt)
+	}
+}
@@ -0,0 +1,84 @@
+package coach
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// tokenCoalescer batches bursty Gemini tokens into fewer message.delta events
+type tokenCoalescer struct {
+	interval  time.Duration
+	maxChars  int
+	buf       strings.Builder
+	lastFlush time.Time
+	emit      func(delta string)
+}
+
+func newTokenCoalescer(opts Options, emit func(delta string)) *tokenCoalescer {
+	return &tokenCoalescer{
+		interval:  opts.CoalesceInterval,
+		maxChars:  opts.CoalesceChars,
+		lastFlush: time.Now(),
+		emit:      emit,
+	}
+}
+
+// enabled reports whether tokens are buffered at all
+func (tc *tokenCoalescer) enabled() bool {
+	return tc.interval > 0
+}
+
+// add buffers a token and flushes when the size or time threshold is reached
+func (tc *tokenCoalescer) add(token string) {
+	tc.buf.WriteString(token)
+	if !tc.enabled() ||
+		(tc.maxChars > 0 && tc.buf.Len() >= tc.maxChars) ||
+		time.Since(tc.lastFlush) >= tc.interval {
+		tc.flushComplete()
+	}
+}
+
+// tick flushes buffered text that has waited at least one interval
+func (tc *tokenCoalescer) tick() {
+	if time.Since(tc.lastFlush) >= tc.interval {
+		tc.flushComplete()
+	}
+}
+
+// flushComplete emits buffered text up to its last whole rune, holding back a rune whose
+// bytes are split across tokens until the rest of it arrives
+func (tc *tokenCoalescer) flushComplete() {
+	text := tc.buf.String()
+	n := completeRunesLen(text)
+	tc.lastFlush = time.Now()
+	if n == 0 {
+		return
+	}
+	tc.emit(text[:n])
+	tc.buf.Reset()
+	tc.buf.WriteString(text[n:])
+}
+
+// flush emits any buffered text as a single delta
+func (tc *tokenCoalescer) flush() {
+	tc.lastFlush = time.Now()
+	if tc.buf.Len() == 0 {
+		return
+	}
+	tc.emit(tc.buf.String())
+	tc.buf.Reset()
+}
+
+// completeRunesLen returns the length of s without a trailing incomplete UTF-8 sequence
+func completeRunesLen(s string) int {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			if utf8.FullRuneInString(s[i:]) {
+				return len(s)
+			}
+			return i
+		}
+	}
+	return len(s)
+}
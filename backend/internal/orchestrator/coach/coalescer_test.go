@@ -0,0 +1,131 @@
+package coach
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// Coalescer test steps besides tokens
+const (
+	waitInterval = "<wait>" // let the flush interval elapse
+	tickStep     = "<tick>" // a ticker fire
+)
+
+func runCoalescer(opts Options, steps []string) []string {
+	var deltas []string
+	tc := newTokenCoalescer(opts, func(delta string) { deltas = append(deltas, delta) })
+	for _, step := range steps {
+		switch step {
+		case waitInterval:
+			tc.lastFlush = time.Now().Add(-tc.interval)
+		case tickStep:
+			tc.tick()
+		default:
+			tc.add(step)
+		}
+	}
+	tc.flush()
+	return deltas
+}
+
+func TestTokenCoalescer(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		steps []string
+		want  []string
+	}{
+		{
+			name:  "flushes once the buffer reaches the size limit",
+			opts:  Options{CoalesceInterval: time.Hour, CoalesceChars: 8},
+			steps: []string{"Hel", "lo ", "wor", "ld!"},
+			want:  []string{"Hello wor", "ld!"},
+		},
+		{
+			name:  "flushes on the first token after the interval",
+			opts:  Options{CoalesceInterval: 50 * time.Millisecond},
+			steps: []string{"a", "b", waitInterval, "c", "d"},
+			want:  []string{"abc", "d"},
+		},
+		{
+			name:  "a tick flushes text that waited an interval",
+			opts:  Options{CoalesceInterval: 50 * time.Millisecond},
+			steps: []string{"a", "b", tickStep, waitInterval, tickStep, "c"},
+			want:  []string{"ab", "c"},
+		},
+		{
+			name:  "the final flush emits the rest",
+			opts:  Options{CoalesceInterval: time.Hour, CoalesceChars: 100},
+			steps: []string{"One ", "short ", "reply"},
+			want:  []string{"One short reply"},
+		},
+		{
+			name:  "the size limit counts bytes of multi-byte tokens",
+			opts:  Options{CoalesceInterval: time.Hour, CoalesceChars: 4},
+			steps: []string{"çay ", "için ", "🙂"},
+			want:  []string{"çay ", "için ", "🙂"},
+		},
+		{
+			name:  "a rune split across tokens waits for its last byte",
+			opts:  Options{CoalesceInterval: time.Hour, CoalesceChars: 2},
+			steps: []string{"g\xc3", "\xbcl", "\xf0\x9f", "\x99", "\x82!"},
+			want:  []string{"g", "ül", "🙂!"},
+		},
+		{
+			name:  "a rune split across the interval boundary",
+			opts:  Options{CoalesceInterval: 50 * time.Millisecond},
+			steps: []string{"ça", "\xc4", waitInterval, tickStep, "\xb1 "},
+			want:  []string{"ça", "ı "},
+		},
+		{
+			name:  "disabled holds back a split rune",
+			opts:  Options{},
+			steps: []string{"\xc3", "\xbc", "!"},
+			want:  []string{"ü", "!"},
+		},
+		{
+			name:  "disabled emits every token",
+			opts:  Options{},
+			steps: []string{"a", "b", "c"},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name:  "nothing buffered emits nothing",
+			opts:  Options{CoalesceInterval: time.Hour},
+			steps: []string{waitInterval, tickStep},
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := runCoalescer(tt.opts, tt.steps)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("deltas = %q, want %q", got, tt.want)
+			}
+			for _, delta := range got {
+				if !utf8.ValidString(delta) {
+					t.Errorf("delta %q is not valid UTF-8", delta)
+				}
+			}
+		})
+	}
+}
+
+func TestTokenCoalescerPreservesText(t *testing.T) {
+	var tokens []string
+	for i := 0; i < 500; i++ {
+		tokens = append(tokens, "word ")
+	}
+
+	deltas := runCoalescer(Options{CoalesceInterval: time.Hour, CoalesceChars: 40}, tokens)
+	if len(deltas) >= len(tokens)/4 {
+		t.Errorf("%d tokens coalesced into %d deltas, want far fewer", len(tokens), len(deltas))
+	}
+	if got, want := strings.Join(deltas, ""), strings.Join(tokens, ""); got != want {
+		t.Errorf("coalesced text differs from the streamed text")
+	}
+}
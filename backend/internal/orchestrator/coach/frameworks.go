@@ -0,0 +1,95 @@
+package coach
+
+import (
+	"strings"
+
+	"simon-backend/internal/models"
+)
+
+// triggerPhrases are the message phrases that signal each framework trigger
+var triggerPhrases = map[string][]string{
+	"feeling_stuck":      {"stuck", "don't know where to start", "dont know where to start", "can't get started", "cant get started"},
+	"overwhelmed":        {"overwhelm", "too much to do", "too many things", "drowning"},
+	"procrastinating":    {"procrastinat", "putting off", "keep avoiding", "keep delaying"},
+	"feeling_scattered":  {"scattered", "all over the place", "unfocused", "distracted"},
+	"sunday_review":      {"review my week", "weekly review", "plan my week", "sunday"},
+	"end_of_week":        {"end of the week", "this week went", "how my week", "last week"},
+	"multiple_options":   {"options", "choose between", "which one", "pros and cons"},
+	"complex_decision":   {"decision", "decide", "trade-off", "tradeoff"},
+	"life_decision":      {"move to", "get married", "break up", "life decision", "big decision"},
+	"career_choice":      {"job offer", "career", "quit my job", "new job", "promotion"},
+	"stuck_on_project":   {"my project", "this project", "side project", "stalled"},
+	"perfectionism":      {"perfect", "not good enough", "polish"},
+	"need_momentum":      {"momentum", "motivation", "get going", "kickstart"},
+	"blank_page":         {"blank page", "where do i begin", "from scratch", "first draft"},
+	"building_new_habit": {"new habit", "start a habit", "build a habit", "routine", "every day"},
+	"habit_not_sticking": {"habit", "keep skipping", "fell off", "streak", "not sticking"},
+	"self_doubt":         {"doubt myself", "not sure i can", "self-doubt", "self doubt"},
+	"imposter_syndrome":  {"imposter", "impostor", "fraud", "don't belong", "dont belong"},
+	"fear_of_failure":    {"afraid to fail", "fear of failing", "fear of failure", "scared to fail", "what if i fail"},
+}
+
+// routeTriggers are the framework triggers each router route hints at
+var routeTriggers = map[string][]string{
+	"quick_nudge":   {"feeling_stuck", "procrastinating", "need_momentum", "blank_page"},
+	"deep_session":  {"complex_decision", "life_decision", "career_choice", "self_doubt", "imposter_syndrome", "fear_of_failure"},
+	"make_a_system": {"building_new_habit", "habit_not_sticking", "overwhelmed", "feeling_scattered"},
+	"review_retro":  {"sunday_review", "end_of_week"},
+}
+
+// Framework match scoring: a phrase in the message is strong evidence, the route a weak hint
+const (
+	messageTriggerScore = 2
+	routeTriggerScore   = 1
+	// minFrameworkScore needs at least one message match; the route alone never selects
+	minFrameworkScore = messageTriggerScore
+)
+
+// selectFramework returns the framework whose WhenToUse triggers best match the message and
+// route, or nil when none matches strongly or the best match is tied
+func selectFramework(frameworks []models.Framework, message, routeName string) *models.Framework {
+	if len(frameworks) == 0 {
+		return nil
+	}
+
+	message = strings.ToLower(message)
+	routeHints := map[string]bool{}
+	for _, trigger := range routeTriggers[routeName] {
+		routeHints[trigger] = true
+	}
+
+	best, bestScore, tied := -1, 0, false
+	for i, framework := range frameworks {
+		score := 0
+		for _, trigger := range framework.WhenToUse {
+			if messageMatchesTrigger(message, trigger) {
+				score += messageTriggerScore
+			}
+			if routeHints[trigger] {
+				score += routeTriggerScore
+			}
+		}
+
+		switch {
+		case score > bestScore:
+			best, bestScore, tied = i, score, false
+		case score == bestScore && score > 0:
+			tied = true
+		}
+	}
+
+	if best < 0 || tied || bestScore < minFrameworkScore {
+		return nil
+	}
+	return &frameworks[best]
+}
+
+// messageMatchesTrigger reports whether the lowercased message contains a phrase for trigger
+func messageMatchesTrigger(message, trigger string) bool {
+	for _, phrase := range triggerPhrases[trigger] {
+		if strings.Contains(message, phrase) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package coach
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"simon-backend/internal/models"
+)
+
+// sampleInstructions keeps directory previews short enough for a card
+const sampleInstructions = "This reply is a short preview shown in the coach directory. " +
+	"Reply to the user's first message in at most 3 sentences, in your usual tone and style. " +
+	"Do not use tools or mention that this is a preview."
+
+// SampleProblem picks a representative problem statement to seed a preview exchange
+func SampleProblem(coach *models.Coach) string {
+	if coach.CoachSpec != nil {
+		if prompts := coach.CoachSpec.Identity.ResolvedStarterPrompts(); len(prompts) > 0 {
+			return prompts[0]
+		}
+	}
+	if coach.Promise != "" {
+		return fmt.Sprintf("I'd like help with this: %s", strings.TrimSuffix(coach.Promise, "."))
+	}
+	return "I'm stuck and don't know where to start."
+}
+
+// Sample generates a single non-streaming coach reply to problem for a directory preview
+func (ca *CoachAgent) Sample(ctx context.Context, spec *models.CoachSpec, problem string) (string, error) {
+	systemPrompt := ca.buildSystemPrompt(spec, nil, nil, nil) + "\n\n" + sampleInstructions
+
+	reply, err := ca.geminiClient.GenerateContent(ctx, systemPrompt, fmt.Sprintf("User: %s", problem))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate sample: %w", err)
+	}
+
+	return strings.TrimSpace(reply), nil
+}
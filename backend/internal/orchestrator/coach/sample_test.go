@@ -0,0 +1,65 @@
+package coach
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/models"
+)
+
+func TestSampleProblem(t *testing.T) {
+	tests := []struct {
+		name  string
+		coach models.Coach
+		want  string
+	}{
+		{"starter prompt", models.Coach{Promise: "Sleep better.", CoachSpec: &models.CoachSpec{Identity: models.Identity{
+			StarterPrompts:    []string{"I keep snoozing my alarm"},
+			ProblemStatements: []string{"I can't fall asleep"},
+		}}}, "I keep snoozing my alarm"},
+		{"problem statement", models.Coach{CoachSpec: &models.CoachSpec{Identity: models.Identity{
+			ProblemStatements: []string{"", "I can't fall asleep"},
+		}}}, "I can't fall asleep"},
+		{"promise", models.Coach{Promise: "Sleep better.", CoachSpec: &models.CoachSpec{}}, "I'd like help with this: Sleep better"},
+		{"nothing to go on", models.Coach{}, "I'm stuck and don't know where to start."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SampleProblem(&tt.coach); got != tt.want {
+				t.Errorf("SampleProblem = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampleUsesCoachStyle(t *testing.T) {
+	fake := geminitest.NewFakeProvider(geminitest.Script{Prefix: "You are Rex, a fitness coach.", Text: "  Drop and give me twenty.\n"})
+	spec := &models.CoachSpec{
+		Identity: models.Identity{Name: "Rex", Niche: "fitness"},
+		Style:    models.Style{Tone: "blunt", Verbosity: "low"},
+	}
+
+	reply, err := NewCoachAgent(fake, Options{}).Sample(context.Background(), spec, "I skipped the gym again")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Drop and give me twenty." {
+		t.Errorf("reply = %q, want the trimmed model text", reply)
+	}
+
+	calls := fake.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("made %d calls, want 1", len(calls))
+	}
+	system := calls[0].SystemPrompt
+	for _, want := range []string{"- Tone: blunt", "- Verbosity: low", sampleInstructions} {
+		if !strings.Contains(system, want) {
+			t.Errorf("system prompt missing %q", want)
+		}
+	}
+	if calls[0].UserPrompt != "User: I skipped the gym again" {
+		t.Errorf("user prompt = %q", calls[0].UserPrompt)
+	}
+}
@@ -0,0 +1,66 @@
+package coach
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"simon-backend/internal/gemini"
+	"simon-backend/internal/models"
+	orchestratorContext "simon-backend/internal/orchestrator/context"
+)
+
+// schedulingTools are the tools ProposeSchedule may propose
+var schedulingTools = []string{"calendar_event_create", "reminder_create", "local_notification_schedule"}
+
+// ProposeSchedule runs a short extraction of the item the user asked to schedule, so the proposal
+// can reach the client while the coach's reply is still streaming. It returns no requests when the
+// message names nothing concrete enough to schedule or the coach allows none of the tools.
+func (ca *CoachAgent) ProposeSchedule(ctx context.Context, userMessage string, contextPacket *orchestratorContext.ContextPacket) ([]ToolRequest, error) {
+	spec := schedulingSpec(contextPacket.CoachSpec)
+	decls := toolDeclarations(ca.tools, spec)
+	if len(decls) == 0 {
+		return nil, nil
+	}
+
+	location := time.UTC
+	if contextPacket.User != nil && contextPacket.User.Preferences.Timezone != "" {
+		if loc, err := time.LoadLocation(contextPacket.User.Preferences.Timezone); err == nil {
+			location = loc
+		}
+	}
+
+	prompt := fmt.Sprintf(`The user wants to schedule something. Call exactly one tool for the item they asked for, resolving relative times against the current time. If the message doesn't name a concrete item and time, call no tool.
+
+Current time: %s (%s)
+
+User: %s`, time.Now().In(location).Format(time.RFC3339), location, userMessage)
+
+	parts, errs := ca.geminiClient.GenerateContentStreamWithTools(ctx, nil, prompt, nil, decls)
+	var calls []gemini.FunctionCall
+	for part := range parts {
+		if part.Call != nil {
+			calls = append(calls, *part.Call)
+		}
+	}
+	if err := <-errs; err != nil {
+		return nil, fmt.Errorf("schedule extraction failed: %w", err)
+	}
+
+	packet := *contextPacket
+	packet.CoachSpec = spec
+	return ca.toolRequestsFromCalls(calls, &packet), nil
+}
+
+// schedulingSpec narrows a spec's allowed tools to the scheduling tools
+func schedulingSpec(spec *models.CoachSpec) *models.CoachSpec {
+	narrowed := *spec
+	narrowed.ToolsAllowed.ClientTools = nil
+	narrowed.ToolsAllowed.ServerTools = nil
+	for _, tool := range schedulingTools {
+		if containsString(spec.ToolsAllowed.ClientTools, tool) {
+			narrowed.ToolsAllowed.ClientTools = append(narrowed.ToolsAllowed.ClientTools, tool)
+		}
+	}
+	return &narrowed
+}
@@ -0,0 +1,54 @@
+package coach
+
+import (
+	"strings"
+
+	"simon-backend/internal/models"
+)
+
+// toneAdjustmentRules describes each one-turn tone shift to the model
+var toneAdjustmentRules = map[string]string{
+	"warmer":      "Be noticeably warmer and more encouraging than usual",
+	"more_direct": "Be more direct: lead with the answer and cut hedging",
+	"less_formal": "Use a relaxed, conversational register",
+	"more_formal": "Use a more formal, professional register",
+}
+
+// verbosityAdjustmentRules describes each one-turn verbosity level to the model
+var verbosityAdjustmentRules = map[string]string{
+	"low":    "Keep this reply short: at most 3 sentences or 3 bullets",
+	"medium": "Keep this reply moderately detailed",
+	"high":   "Give a fuller, more detailed reply than usual",
+}
+
+// adjustedSpec returns spec with the adjustment's verbosity applied, leaving spec untouched
+func adjustedSpec(spec *models.CoachSpec, adjust *models.StyleAdjustment) *models.CoachSpec {
+	if spec == nil || adjust == nil || adjust.Verbosity == "" {
+		return spec
+	}
+
+	adjusted := *spec
+	adjusted.Style.Verbosity = adjust.Verbosity
+	return &adjusted
+}
+
+// styleAdjustmentPrompt renders the one-turn adjustment as explicit instructions
+func styleAdjustmentPrompt(adjust *models.StyleAdjustment) string {
+	if adjust == nil {
+		return ""
+	}
+
+	var rules []string
+	if rule, ok := verbosityAdjustmentRules[adjust.Verbosity]; ok {
+		rules = append(rules, "- "+rule)
+	}
+	if rule, ok := toneAdjustmentRules[adjust.Tone]; ok {
+		rules = append(rules, "- "+rule)
+	}
+	if len(rules) == 0 {
+		return ""
+	}
+
+	return "\n\nThe user asked to regenerate your last reply with these adjustments (this reply only):\n" +
+		strings.Join(rules, "\n")
+}
@@ -0,0 +1,100 @@
+package coach
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/models"
+	orchestratorContext "simon-backend/internal/orchestrator/context"
+)
+
+func TestAdjustedSpec(t *testing.T) {
+	spec := &models.CoachSpec{Style: models.Style{Tone: "calm", Verbosity: "high"}}
+
+	if got := adjustedSpec(spec, nil); got != spec {
+		t.Error("no adjustment copied the spec")
+	}
+	if got := adjustedSpec(spec, &models.StyleAdjustment{Tone: "warmer"}); got != spec {
+		t.Error("a tone-only adjustment copied the spec; tone is applied through the prompt")
+	}
+
+	got := adjustedSpec(spec, &models.StyleAdjustment{Verbosity: "low"})
+	if got.Style.Verbosity != "low" || got.Style.Tone != "calm" {
+		t.Errorf("adjusted style = %+v, want low verbosity and the coach's tone", got.Style)
+	}
+	if spec.Style.Verbosity != "high" {
+		t.Errorf("coach verbosity changed to %q; adjustments are one-turn only", spec.Style.Verbosity)
+	}
+}
+
+func TestStyleAdjustmentPrompt(t *testing.T) {
+	tests := []struct {
+		name   string
+		adjust *models.StyleAdjustment
+		want   []string
+	}{
+		{"none", nil, nil},
+		{"unknown values", &models.StyleAdjustment{Verbosity: "tiny", Tone: "sarcastic"}, nil},
+		{"verbosity", &models.StyleAdjustment{Verbosity: "low"}, []string{verbosityAdjustmentRules["low"]}},
+		{"both", &models.StyleAdjustment{Verbosity: "high", Tone: "less_formal"}, []string{verbosityAdjustmentRules["high"], toneAdjustmentRules["less_formal"]}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := styleAdjustmentPrompt(tt.adjust)
+			if len(tt.want) == 0 {
+				if got != "" {
+					t.Errorf("prompt = %q, want none", got)
+				}
+				return
+			}
+			if !strings.Contains(got, "this reply only") {
+				t.Errorf("prompt %q doesn't scope the adjustment to this reply", got)
+			}
+			for _, rule := range tt.want {
+				if !strings.Contains(got, "- "+rule) {
+					t.Errorf("prompt %q missing %q", got, rule)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateWithVerbosityAdjustment(t *testing.T) {
+	fake := geminitest.NewFakeProvider(geminitest.Script{Prefix: "You are Sage, a mindset coach.", Text: "Start with one small step. "})
+	agent := NewCoachAgent(fake, Options{})
+	spec := &models.CoachSpec{
+		Identity: models.Identity{Name: "Sage", Niche: "mindset"},
+		Style:    models.Style{Tone: "calm", Verbosity: "high"},
+	}
+
+	generate := func(adjust *models.StyleAdjustment) string {
+		t.Helper()
+		stream := make(chan SSEEvent, 100)
+		packet := &orchestratorContext.ContextPacket{CoachSpec: spec, StyleAdjustment: adjust}
+		if _, err := agent.Generate(context.Background(), "how do I get started?", packet, stream); err != nil {
+			t.Fatal(err)
+		}
+		calls := fake.Calls()
+		return calls[len(calls)-1].SystemPrompt
+	}
+
+	normal := generate(nil)
+	concise := generate(&models.StyleAdjustment{Verbosity: "low"})
+
+	if !strings.Contains(normal, "- Verbosity: high") || strings.Contains(normal, verbosityAdjustmentRules["low"]) {
+		t.Errorf("default prompt doesn't use the coach's verbosity:\n%s", normal)
+	}
+	if !strings.Contains(concise, "- Verbosity: low") || !strings.Contains(concise, verbosityAdjustmentRules["low"]) {
+		t.Errorf("adjusted prompt doesn't ask for a concise reply:\n%s", concise)
+	}
+	if strings.Contains(concise, "- Verbosity: high") {
+		t.Error("adjusted prompt still carries the coach's default verbosity")
+	}
+
+	// The next turn goes back to the coach's own style
+	if again := generate(nil); again != normal {
+		t.Error("the adjustment leaked into the following turn")
+	}
+}
@@ -0,0 +1,180 @@
+package coach
+
+import (
+	"log"
+	"sort"
+
+	"simon-backend/internal/gemini"
+	"simon-backend/internal/models"
+	orchestratorContext "simon-backend/internal/orchestrator/context"
+	"simon-backend/internal/tools"
+)
+
+// toolDescriptions tell the model when each tool is worth proposing
+var toolDescriptions = map[string]string{
+	"local_notification_schedule": "Schedule a local notification on the user's device, e.g. a nudge to start a planned action.",
+	"calendar_event_create":       "Add an event to the user's calendar for a specific time block they agreed to. Times are RFC3339.",
+	"reminder_create":             "Create a reminder in the user's reminders list for a task they want to remember.",
+	"share_sheet_export":          "Open the share sheet so the user can export content from this conversation.",
+	"memory_read":                 "Look up what the user has previously shared (values, goals, commitments).",
+	"memory_write":                "Remember something durable the user shared, such as a commitment or preference.",
+	"plan_create":                 "Save a structured plan the user agreed to.",
+	"plan_update":                 "Update one of the user's existing plans.",
+	"plan_list_active":            "List the user's active plans.",
+	"checkin_schedule":            "Schedule a recurring check-in with the user.",
+}
+
+// reasonParam is the argument every declared tool takes to explain the proposal to the user
+const reasonParam = "reason"
+
+// confidenceParam is the optional argument in which the model reports how sure it is that the
+// user wants the tool run; it becomes ToolRequest.Confidence
+const confidenceParam = "confidence"
+
+// serverFilledParams are tool inputs the server knows better than the model; they are hidden
+// from the declarations and filled in when the call becomes a tool request
+var serverFilledParams = map[string]bool{
+	"uid":             true,
+	"coach_id":        true,
+	"idempotency_key": true,
+}
+
+// toolDeclarations declares the tools the coach spec allows, in a stable order
+func toolDeclarations(registry *tools.Registry, spec *models.CoachSpec) []gemini.FunctionDecl {
+	allowed := append(append([]string{}, spec.ToolsAllowed.ClientTools...), spec.ToolsAllowed.ServerTools...)
+	sort.Strings(allowed)
+
+	decls := []gemini.FunctionDecl{}
+	seen := map[string]bool{}
+	for _, id := range allowed {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		tool, err := registry.GetTool(id)
+		if err != nil {
+			continue
+		}
+		decls = append(decls, gemini.FunctionDecl{
+			Name:        id,
+			Description: toolDescriptions[id],
+			Parameters:  toolParameters(tool.InputSchema),
+		})
+	}
+	return decls
+}
+
+// toolParameters copies a tool's input schema without the server-filled fields, adding the reason argument
+func toolParameters(schema map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		for name, prop := range props {
+			if !serverFilledParams[name] {
+				properties[name] = prop
+			}
+		}
+	}
+	properties[reasonParam] = map[string]interface{}{
+		"type":        "string",
+		"description": "One short sentence telling the user why this helps",
+	}
+	properties[confidenceParam] = map[string]interface{}{
+		"type":        "number",
+		"description": "How sure you are, from 0 to 1, that the user wants this done now",
+	}
+
+	required := []string{}
+	if req, ok := schema["required"].([]string); ok {
+		for _, name := range req {
+			if !serverFilledParams[name] {
+				required = append(required, name)
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// toolRequestsFromCalls turns the model's function calls into tool requests. Calls to tools the
+// coach spec doesn't allow, or whose completed payload fails the tool's input schema, are dropped.
+func (ca *CoachAgent) toolRequestsFromCalls(calls []gemini.FunctionCall, contextPacket *orchestratorContext.ContextPacket) []ToolRequest {
+	spec := contextPacket.CoachSpec
+	requests := []ToolRequest{}
+	for _, call := range calls {
+		if !ca.isToolAllowed(call.Name, spec) {
+			log.Printf("Dropping call to disallowed tool: %s", call.Name)
+			continue
+		}
+		tool, err := ca.tools.GetTool(call.Name)
+		if err != nil {
+			log.Printf("Dropping call to unknown tool: %s", call.Name)
+			continue
+		}
+
+		requestID := generateRequestID()
+		payload, reason, confidence := toolPayload(tool, call.Args, requestID, contextPacket)
+		if err := ca.tools.ValidateInput(call.Name, payload); err != nil {
+			log.Printf("Dropping call to %s with invalid payload: %v", call.Name, err)
+			continue
+		}
+
+		requests = append(requests, ToolRequest{
+			RequestID:            requestID,
+			Tool:                 call.Name,
+			RequiresConfirmation: tool.RequiresConfirmation || containsString(spec.ToolsAllowed.RequiresUserConfirmation, call.Name),
+			Reason:               reason,
+			Payload:              payload,
+			Confidence:           confidence,
+		})
+	}
+	return requests
+}
+
+// toolPayload builds a tool's input from the model's arguments, filling the server-known fields
+// the tool's schema declares. The reason and confidence arguments are returned separately; a
+// confidence outside 0-1 is treated as unreported (0).
+func toolPayload(tool tools.Tool, args map[string]interface{}, requestID string, contextPacket *orchestratorContext.ContextPacket) (map[string]interface{}, string, float64) {
+	payload := make(map[string]interface{}, len(args)+len(serverFilledParams))
+	reason := ""
+	confidence := 0.0
+	for key, value := range args {
+		switch {
+		case key == reasonParam:
+			reason, _ = value.(string)
+		case key == confidenceParam:
+			if c, ok := value.(float64); ok && c > 0 && c <= 1 {
+				confidence = c
+			}
+		case !serverFilledParams[key]:
+			payload[key] = value
+		}
+	}
+
+	properties, _ := tool.InputSchema["properties"].(map[string]interface{})
+	if _, ok := properties["idempotency_key"]; ok {
+		payload["idempotency_key"] = requestID
+	}
+	if _, ok := properties["uid"]; ok && contextPacket.User != nil {
+		payload["uid"] = contextPacket.User.UID
+	}
+	if _, ok := properties["coach_id"]; ok && contextPacket.CoachID != "" {
+		payload["coach_id"] = contextPacket.CoachID
+	}
+
+	return payload, reason, confidence
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package coach
+
+import (
+	"testing"
+
+	"simon-backend/internal/gemini"
+	"simon-backend/internal/models"
+	orchestratorContext "simon-backend/internal/orchestrator/context"
+)
+
+func planCreateCall(args map[string]interface{}) gemini.FunctionCall {
+	full := map[string]interface{}{
+		"plan":   map[string]interface{}{"title": "Run a 10k", "objective": "Finish under an hour", "horizon": "8 weeks"},
+		"reason": "You asked for a training plan",
+	}
+	for k, v := range args {
+		full[k] = v
+	}
+	return gemini.FunctionCall{Name: "plan_create", Args: full}
+}
+
+func TestToolRequestsFromCallsConfidence(t *testing.T) {
+	agent := NewCoachAgent(nil, Options{})
+	packet := &orchestratorContext.ContextPacket{
+		User:      &models.User{UID: "u1"},
+		CoachID:   "coach-1",
+		CoachSpec: &models.CoachSpec{ToolsAllowed: models.ToolsAllowed{ServerTools: []string{"plan_create"}}},
+	}
+
+	tests := []struct {
+		name       string
+		confidence interface{}
+		want       float64
+	}{
+		{"reported", 0.92, 0.92},
+		{"missing", nil, 0},
+		{"above one is ignored", 3.0, 0},
+		{"negative is ignored", -0.4, 0},
+		{"wrong type is ignored", "high", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := map[string]interface{}{}
+			if tt.confidence != nil {
+				args[confidenceParam] = tt.confidence
+			}
+			requests := agent.toolRequestsFromCalls([]gemini.FunctionCall{planCreateCall(args)}, packet)
+			if len(requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(requests))
+			}
+			req := requests[0]
+			if req.Confidence != tt.want {
+				t.Errorf("Confidence = %v, want %v", req.Confidence, tt.want)
+			}
+			if _, ok := req.Payload[confidenceParam]; ok {
+				t.Errorf("confidence leaked into the tool payload: %v", req.Payload)
+			}
+			if req.Payload["uid"] != "u1" || req.Payload["coach_id"] != "coach-1" {
+				t.Errorf("server-filled fields missing from payload: %v", req.Payload)
+			}
+			if req.Reason != "You asked for a training plan" {
+				t.Errorf("Reason = %q", req.Reason)
+			}
+		})
+	}
+}
+
+func TestToolParametersDeclareOptionalConfidence(t *testing.T) {
+	params := toolParameters(map[string]interface{}{
+		"required":   []string{"uid", "title"},
+		"properties": map[string]interface{}{"uid": map[string]interface{}{"type": "string"}, "title": map[string]interface{}{"type": "string"}},
+	})
+
+	properties := params["properties"].(map[string]interface{})
+	if _, ok := properties[confidenceParam]; !ok {
+		t.Fatalf("confidence not declared: %v", properties)
+	}
+	if _, ok := properties["uid"]; ok {
+		t.Errorf("server-filled uid should be hidden from the model")
+	}
+	for _, name := range params["required"].([]string) {
+		if name == confidenceParam || name == "uid" {
+			t.Errorf("%s should not be required", name)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package context
 import (
 	"context"
 	"fmt"
+	"log"
 
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/gemini"
@@ -17,6 +18,28 @@ type ContextPacket struct {
 	ActivePlans   []models.Plan
 	RecentSummary string
 	RetrievalHits []MemoryHit
+	// CoachID is the session's coach; empty for coachless sessions
+	CoachID string
+	// RouteName is the router's classification of this turn (e.g. "review_retro")
+	RouteName string
+	// CoachUnavailable is true when the session's coach was deleted or is no longer visible to
+	// the user; CoachSpec is then the session's snapshot (or the default spec)
+	CoachUnavailable bool
+
+	// StyleAdjustment overrides the coach's style for this turn only
+	StyleAdjustment *models.StyleAdjustment
+	// FirstTurn is true when the coach hasn't replied in this session yet
+	FirstTurn bool
+	// IncludeContext controls whether the user's context vault reaches the prompt
+	IncludeContext bool
+	// GrantedPermissions are the device permissions the client reported; nil if unreported
+	GrantedPermissions []string
+	// Images are the user's image attachments for this turn, sent to the model with the prompt
+	Images []gemini.Media
+	// History holds the session's earlier turns that fit the token budget, oldest first
+	History []gemini.Turn
+	// HistorySummary condenses the earlier turns that didn't fit the budget
+	HistorySummary string
 }
 
 // MemoryHit represents a memory search result
@@ -30,11 +53,11 @@ type MemoryHit struct {
 // ContextBuilder builds context packets for coaching sessions
 type ContextBuilder struct {
 	fs           *firestore.Client
-	geminiClient *gemini.Client
+	geminiClient gemini.Provider
 }
 
 // NewContextBuilder creates a new context builder
-func NewContextBuilder(fs *firestore.Client, gm *gemini.Client) *ContextBuilder {
+func NewContextBuilder(fs *firestore.Client, gm gemini.Provider) *ContextBuilder {
 	return &ContextBuilder{
 		fs:           fs,
 		geminiClient: gm,
@@ -42,8 +65,8 @@ func NewContextBuilder(fs *firestore.Client, gm *gemini.Client) *ContextBuilder
 }
 
 // Build constructs a complete context packet
-func (cb *ContextBuilder) Build(ctx context.Context, uid string, coachID string, route *router.Route) (*ContextPacket, error) {
-	packet := &ContextPacket{}
+func (cb *ContextBuilder) Build(ctx context.Context, uid string, coachID string, snapshot *models.CoachSpec, route *router.Route) (*ContextPacket, error) {
+	packet := &ContextPacket{CoachID: coachID, RouteName: route.Name}
 
 	// Fetch user
 	user, err := cb.getUserDoc(ctx, uid)
@@ -51,14 +74,21 @@ func (cb *ContextBuilder) Build(ctx context.Context, uid string, coachID string,
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	packet.User = user
+	packet.IncludeContext = user.Preferences.IncludeContext
 
-	// Fetch coach spec
-	coachSpec, err := cb.getCoachSpec(ctx, coachID)
+	// Fetch coach spec, falling back to the session's snapshot when the coach is gone
+	coachSpec, available, err := cb.getCoachSpec(ctx, uid, coachID)
 	if err != nil {
-		// Use default coach spec if not found
+		log.Printf("Failed to load coach %s, using default spec: %v", coachID, err)
+	}
+	if coachSpec == nil {
+		coachSpec = snapshot
+	}
+	if coachSpec == nil {
 		coachSpec = cb.getDefaultCoachSpec()
 	}
 	packet.CoachSpec = coachSpec
+	packet.CoachUnavailable = !available
 
 	// Fetch context based on route needs
 	for _, key := range route.ContextKeys {
@@ -97,17 +127,27 @@ func (cb *ContextBuilder) getUserDoc(ctx context.Context, uid string) (*models.U
 	return user, nil
 }
 
-// getCoachSpec fetches the coach specification
-func (cb *ContextBuilder) getCoachSpec(ctx context.Context, coachID string) (*models.CoachSpec, error) {
+// getCoachSpec fetches the coach specification. available is false when the coach was deleted
+// or is private to someone else; the spec is nil if the user may no longer see it.
+func (cb *ContextBuilder) getCoachSpec(ctx context.Context, uid, coachID string) (*models.CoachSpec, bool, error) {
+	if coachID == "" {
+		return nil, true, nil
+	}
+
 	coach, err := cb.fs.GetCoach(ctx, coachID)
 	if err != nil {
-		return nil, err
+		if firestore.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, true, err
+	}
+
+	if coach.Visibility == "private" && coach.OwnerUID != uid {
+		return nil, false, nil
 	}
 
-	// Extract CoachSpec from coach
-	// For now, return a basic spec based on blueprint
-	// TODO: Update when CoachSpec field is added to Coach model
-	return cb.blueprintToCoachSpec(coach.Blueprint), nil
+	// A soft-deleted coach's own spec is still its last known spec
+	return coach.EffectiveSpec(), coach.Status != models.CoachStatusDeleted, nil
 }
 
 // getActivePlans fetches active plans for the user
@@ -188,19 +228,3 @@ func (cb *ContextBuilder) getDefaultCoachSpec() *models.CoachSpec {
 		},
 	}
 }
-
-// blueprintToCoachSpec converts old blueprint format to CoachSpec
-func (cb *ContextBuilder) blueprintToCoachSpec(blueprint map[string]interface{}) *models.CoachSpec {
-	// For backward compatibility, convert blueprint to CoachSpec
-	// This is a temporary solution until all coaches use CoachSpec
-	spec := cb.getDefaultCoachSpec()
-
-	// Extract style if present
-	if style, ok := blueprint["style"].(map[string]interface{}); ok {
-		if tone, ok := style["tone"].(string); ok {
-			spec.Style.Tone = tone
-		}
-	}
-
-	return spec
-}
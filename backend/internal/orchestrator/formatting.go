@@ -0,0 +1,50 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/metrics"
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/coach"
+)
+
+// checkFormatting records a reply that breaks its coach's formatting limits and, in enforce mode,
+// rewrites it once to fit. The rewrite is sent as a revised message.final for the same message
+// and returned; otherwise the reply text is returned unchanged.
+func (p *Pipeline) checkFormatting(ctx context.Context, output *coach.CoachOutput, spec *models.CoachSpec, stream chan<- SSEEvent) string {
+	if p.formattingMode == config.FormattingOff {
+		return output.MessageText
+	}
+
+	violations := p.safetyFilter.CheckFormatting(output.MessageText, spec.Style.Formatting)
+	if len(violations) == 0 {
+		return output.MessageText
+	}
+	for _, violation := range violations {
+		metrics.Get().RecordFormattingViolation(violation.Rule)
+		log.Printf("Coach reply broke formatting: coach=%q, rule=%s, count=%d, limit=%d", spec.Identity.Name, violation.Rule, violation.Count, violation.Limit)
+	}
+	if p.formattingMode != config.FormattingEnforce {
+		return output.MessageText
+	}
+
+	revised, err := p.coachAgent.Reformat(ctx, output.MessageText, spec.Style.Formatting)
+	if err != nil || revised == "" {
+		log.Printf("Formatting rewrite failed, keeping the original reply: %v", err)
+		return output.MessageText
+	}
+
+	stream <- SSEEvent{
+		Type: "message.final",
+		Data: map[string]interface{}{
+			"message_id":   output.MessageID,
+			"role":         "assistant",
+			"text":         revised,
+			"revised":      true,
+			"render_hints": map[string]interface{}{"max_cards": 3},
+		},
+	}
+	return revised
+}
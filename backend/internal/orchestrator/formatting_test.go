@@ -0,0 +1,82 @@
+package orchestrator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"simon-backend/internal/config"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/metrics"
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/coach"
+	"simon-backend/internal/orchestrator/safety"
+)
+
+// sentenceViolations reads how many replies have broken the sentence limit so far
+func sentenceViolations() int64 {
+	stats, _ := metrics.Get().GetStats()["formatting_violations"].(map[string]int64)
+	return stats[safety.RuleMaxSentencesPerParagraph]
+}
+
+func TestCheckFormatting(t *testing.T) {
+	const long = "Start with ten minutes. Do it after coffee. Track it on paper. Review it on Sunday."
+	const revised = "Start with ten minutes after coffee. Track it and review it on Sunday."
+	spec := &models.CoachSpec{Identity: models.Identity{Name: "Pace"}}
+	spec.Style.Formatting = models.Formatting{MaxSentencesPerParagraph: 2}
+
+	tests := []struct {
+		name        string
+		mode        string
+		text        string
+		script      *geminitest.Script
+		want        string
+		wantRewrite bool
+		wantCounted bool
+	}{
+		{name: "off", mode: config.FormattingOff, text: long, want: long},
+		{name: "warn", mode: config.FormattingWarn, text: long, want: long, wantCounted: true},
+		{name: "enforce rewrites", mode: config.FormattingEnforce, text: long, script: &geminitest.Script{Prefix: "Rewrite this coaching reply", Text: "  " + revised + "\n"}, want: revised, wantRewrite: true, wantCounted: true},
+		{name: "enforce keeps a compliant reply", mode: config.FormattingEnforce, text: "Ask Dr. Lee, e.g. on Monday. Then rest.", want: "Ask Dr. Lee, e.g. on Monday. Then rest."},
+		{name: "enforce keeps the reply when the rewrite fails", mode: config.FormattingEnforce, text: long, want: long, wantCounted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var scripts []geminitest.Script
+			if tt.script != nil {
+				scripts = append(scripts, *tt.script)
+			}
+			provider := geminitest.NewFakeProvider(scripts...)
+			pipeline := NewPipeline(firestoretest.New(t), provider, config.Config{FormattingMode: tt.mode})
+			stream := make(chan SSEEvent, 1)
+			before := sentenceViolations()
+
+			got := pipeline.checkFormatting(context.Background(), &coach.CoachOutput{MessageID: "m1", MessageText: tt.text}, spec, stream)
+			close(stream)
+
+			if got != tt.want {
+				t.Errorf("reply = %q, want %q", got, tt.want)
+			}
+			if counted := sentenceViolations() > before; counted != tt.wantCounted {
+				t.Errorf("violation recorded = %v, want %v", counted, tt.wantCounted)
+			}
+			calls := provider.Calls()
+			if tt.mode != config.FormattingEnforce || !tt.wantCounted {
+				if len(calls) != 0 {
+					t.Errorf("asked the model %d times, want no rewrite", len(calls))
+				}
+			} else if len(calls) != 1 || !strings.Contains(calls[0].SystemPrompt, "At most 2 sentences per paragraph") {
+				t.Errorf("rewrite calls = %+v, want one with the sentence limit", calls)
+			}
+
+			event, sent := <-stream
+			if sent != tt.wantRewrite {
+				t.Fatalf("revised message.final sent = %v, want %v", sent, tt.wantRewrite)
+			}
+			if sent && (event.Type != "message.final" || event.Data["message_id"] != "m1" || event.Data["text"] != revised || event.Data["revised"] != true) {
+				t.Errorf("revised event = %+v", event)
+			}
+		})
+	}
+}
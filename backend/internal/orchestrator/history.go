@@ -0,0 +1,65 @@
+package orchestrator
+
+import (
+	"context"
+	"log"
+	"strings"
+	"unicode/utf8"
+
+	"simon-backend/internal/gemini"
+	"simon-backend/internal/models"
+)
+
+// conversationHistory turns the session transcript into model turns, keeping the newest turns
+// that fit the token budget. Older turns are condensed into a summary by the memory agent; if
+// that fails they are dropped. A zero budget sends no history at all.
+func (p *Pipeline) conversationHistory(ctx context.Context, transcript []models.Message) ([]gemini.Turn, string) {
+	if p.historyTokenBudget <= 0 {
+		return nil, ""
+	}
+
+	recent, older := splitHistory(transcript, p.historyTokenBudget)
+	if len(older) == 0 {
+		return recent, ""
+	}
+
+	summary, err := p.memoryAgent.SummarizeHistory(ctx, older)
+	if err != nil {
+		log.Printf("Failed to summarize %d earlier turns: %v", len(older), err)
+		return recent, ""
+	}
+	return recent, summary
+}
+
+// splitHistory returns the newest turns whose estimated tokens fit the budget, oldest first,
+// and the older messages that didn't fit. Messages without text are skipped.
+func splitHistory(transcript []models.Message, budget int) ([]gemini.Turn, []models.Message) {
+	used := 0
+	start := len(transcript)
+	for start > 0 {
+		cost := estimateTokens(transcript[start-1].ContentText)
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+
+	turns := []gemini.Turn{}
+	for _, msg := range transcript[start:] {
+		if strings.TrimSpace(msg.ContentText) == "" {
+			continue
+		}
+		role := gemini.RoleUser
+		if msg.Role == "assistant" {
+			role = gemini.RoleModel
+		}
+		turns = append(turns, gemini.Turn{Role: role, Text: msg.ContentText})
+	}
+	return turns, transcript[:start]
+}
+
+// estimateTokens approximates a text's token count at four characters per token
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
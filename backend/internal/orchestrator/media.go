@@ -0,0 +1,93 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"simon-backend/internal/gemini"
+	"simon-backend/internal/models"
+)
+
+// maxImageBytes bounds a single image attachment sent to the model
+const maxImageBytes = 10 << 20
+
+// imageHosts are the storage hosts image attachments may be fetched from; download URLs come
+// from the client, so anything else is refused rather than fetched
+var imageHosts = map[string]bool{
+	"firebasestorage.googleapis.com": true,
+	"storage.googleapis.com":         true,
+}
+
+// imageClient fetches attachment bytes
+var imageClient = &http.Client{Timeout: 15 * time.Second}
+
+// hasImages reports whether any attachment is an image
+func hasImages(attachments []models.Attachment) bool {
+	for _, attachment := range attachments {
+		if attachment.Type == models.AttachmentTypeImage {
+			return true
+		}
+	}
+	return false
+}
+
+// loadImages downloads the image attachments so they can be sent inline with the prompt
+func loadImages(ctx context.Context, attachments []models.Attachment) ([]gemini.Media, error) {
+	media := []gemini.Media{}
+	for _, attachment := range attachments {
+		if attachment.Type != models.AttachmentTypeImage {
+			continue
+		}
+		image, err := loadImage(ctx, attachment)
+		if err != nil {
+			return nil, err
+		}
+		media = append(media, image)
+	}
+	return media, nil
+}
+
+// loadImage downloads one image attachment from storage
+func loadImage(ctx context.Context, attachment models.Attachment) (gemini.Media, error) {
+	u, err := url.Parse(attachment.DownloadURL)
+	if err != nil || u.Scheme != "https" || !imageHosts[u.Hostname()] {
+		return gemini.Media{}, fmt.Errorf("image %s is not in app storage", attachment.StoragePath)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return gemini.Media{}, err
+	}
+	resp, err := imageClient.Do(req)
+	if err != nil {
+		return gemini.Media{}, fmt.Errorf("failed to fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return gemini.Media{}, fmt.Errorf("failed to fetch image: status %d", resp.StatusCode)
+	}
+
+	mimeType := attachment.MimeType
+	if mimeType == "" {
+		mimeType = strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return gemini.Media{}, fmt.Errorf("attachment is not an image (%s)", mimeType)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageBytes+1))
+	if err != nil {
+		return gemini.Media{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	if len(data) > maxImageBytes {
+		return gemini.Media{}, fmt.Errorf("image is larger than %d MB", maxImageBytes>>20)
+	}
+
+	return gemini.Media{MimeType: mimeType, Data: data}, nil
+}
@@ -2,36 +2,46 @@ package memory
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	firestoreClient "simon-backend/internal/firestore"
 	"simon-backend/internal/gemini"
+	"simon-backend/internal/jsonutil"
+	"simon-backend/internal/models"
 	"simon-backend/internal/orchestrator/coach"
+	"simon-backend/internal/redact"
+	"simon-backend/internal/textutil"
 )
 
 // MemoryAgent handles async session summarization and memory updates
 type MemoryAgent struct {
 	fs           *firestoreClient.Client
-	geminiClient *gemini.Client
+	geminiClient gemini.Provider
 }
 
 // NewMemoryAgent creates a new memory agent
-func NewMemoryAgent(fs *firestoreClient.Client, gm *gemini.Client) *MemoryAgent {
+func NewMemoryAgent(fs *firestoreClient.Client, gm gemini.Provider) *MemoryAgent {
 	return &MemoryAgent{
 		fs:           fs,
 		geminiClient: gm,
 	}
 }
 
-// Update performs async memory update after a coaching session
+// Update performs async memory update after a coaching session. The summary and commitments are
+// redacted of sensitive data unless spec allows storing it.
 func (ma *MemoryAgent) Update(
 	ctx context.Context,
 	sessionID string,
 	uid string,
 	output *coach.CoachOutput,
+	spec *models.CoachSpec,
 ) error {
 	// Generate session summary
 	summary, err := ma.generateSummary(ctx, output.MessageText)
@@ -46,6 +56,16 @@ func (ma *MemoryAgent) Update(
 		commitments = []string{}
 	}
 
+	summary, redacted := redact.ForStorage(summary, spec)
+	for i, commitment := range commitments {
+		var n int
+		commitments[i], n = redact.ForStorage(commitment, spec)
+		redacted += n
+	}
+	if redacted > 0 {
+		log.Printf("Redacted %d sensitive value(s) from memory update for session %s", redacted, sessionID)
+	}
+
 	// Update session document with summary
 	if err := ma.updateSessionSummary(ctx, sessionID, summary); err != nil {
 		return fmt.Errorf("failed to update session: %w", err)
@@ -58,6 +78,13 @@ func (ma *MemoryAgent) Update(
 		}
 	}
 
+	// Fold the session into the user's overall memory; it is shared across coaches, so built-in
+	// patterns are redacted even when this coach stores sensitive memory
+	insight, _ := redact.Text(summary, nil)
+	if err := ma.UpdateMemorySummary(ctx, uid, insight); err != nil {
+		return fmt.Errorf("failed to update memory summary: %w", err)
+	}
+
 	return nil
 }
 
@@ -104,21 +131,17 @@ Only include explicit commitments. If none, return empty array [].`, coachText)
 		return nil, err
 	}
 
-	// Simple parsing - in production would use proper JSON parsing
+	var extracted []string
+	if err := jsonutil.ParseResponse(response, &extracted); err != nil {
+		return nil, fmt.Errorf("failed to parse commitments: %w", err)
+	}
+
 	commitments := []string{}
-	
-	// Remove brackets and quotes, split by comma
-	cleaned := strings.Trim(response, "[]")
-	if len(cleaned) > 0 {
-		parts := strings.Split(cleaned, ",")
-		for _, part := range parts {
-			commitment := strings.Trim(strings.Trim(part, " "), "\"")
-			if len(commitment) > 0 {
-				commitments = append(commitments, commitment)
-			}
+	for _, commitment := range extracted {
+		if commitment = strings.TrimSpace(commitment); commitment != "" {
+			commitments = append(commitments, commitment)
 		}
 	}
-
 	return commitments, nil
 }
 
@@ -143,6 +166,96 @@ func (ma *MemoryAgent) updateSessionSummary(ctx context.Context, sessionID strin
 	return err
 }
 
+// TitleSession replaces a session's default title with a short generated one. Sessions the
+// user has renamed, or that were already titled, are left alone.
+func (ma *MemoryAgent) TitleSession(ctx context.Context, sessionID, userMessage, replyText string) error {
+	if sessionID == "" {
+		return nil
+	}
+	sessionRef := ma.fs.DB.Collection("sessions").Doc(sessionID)
+	if !ma.hasDefaultTitle(ctx, sessionRef) {
+		return nil
+	}
+
+	prompt := fmt.Sprintf(`Write a title of at most 6 words for this coaching conversation. Reply with the title only, without quotes.
+
+User: %s
+
+Coach: %s`, textutil.TruncateSafe(userMessage, 500), textutil.TruncateSafe(replyText, 1000))
+
+	response, err := ma.geminiClient.GenerateContent(ctx, prompt, "")
+	if err != nil {
+		return err
+	}
+	title := cleanTitle(response)
+	if title == "" {
+		return nil
+	}
+
+	// Re-checked in a transaction so a rename made while generating wins
+	return ma.fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(sessionRef)
+		if err != nil {
+			return err
+		}
+		if current, _ := doc.DataAt("title"); current != models.DefaultSessionTitle {
+			return nil
+		}
+		return tx.Update(sessionRef, []firestore.Update{{Path: "title", Value: title}})
+	})
+}
+
+// hasDefaultTitle reports whether the session still has the default title
+func (ma *MemoryAgent) hasDefaultTitle(ctx context.Context, sessionRef *firestore.DocumentRef) bool {
+	doc, err := sessionRef.Get(ctx)
+	if err != nil {
+		return false
+	}
+	title, _ := doc.DataAt("title")
+	return title == models.DefaultSessionTitle
+}
+
+// cleanTitle keeps the first line of a generated title, without wrapping quotes or a trailing period
+func cleanTitle(response string) string {
+	title := strings.TrimSpace(response)
+	if i := strings.IndexByte(title, '\n'); i >= 0 {
+		title = title[:i]
+	}
+	title = strings.Trim(strings.TrimSpace(title), `"'*`)
+	title = strings.TrimSpace(strings.TrimSuffix(title, "."))
+	return textutil.TruncateSafe(title, models.MaxSessionTitleRunes)
+}
+
+// maxHistoryTranscriptRunes bounds the transcript sent for summarization; its oldest part is cut
+const maxHistoryTranscriptRunes = 20000
+
+// SummarizeHistory condenses earlier turns of a conversation that no longer fit in the prompt
+func (ma *MemoryAgent) SummarizeHistory(ctx context.Context, messages []models.Message) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		speaker := "User"
+		if msg.Role == "assistant" {
+			speaker = "Coach"
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", speaker, textutil.TruncateSafe(msg.ContentText, 1000))
+	}
+	text := transcript.String()
+	if runes := []rune(text); len(runes) > maxHistoryTranscriptRunes {
+		text = string(runes[len(runes)-maxHistoryTranscriptRunes:])
+	}
+
+	prompt := fmt.Sprintf(`Summarize this earlier part of a coaching conversation in at most 6 lines. Keep the facts the user shared, decisions made and commitments, so the coach can continue without rereading it.
+
+%s
+Summary:`, text)
+
+	summary, err := ma.geminiClient.GenerateContent(ctx, prompt, "")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(summary), nil
+}
+
 // updateUserCommitments adds commitments to user document
 func (ma *MemoryAgent) updateUserCommitments(ctx context.Context, uid string, commitments []string) error {
 	// Convert commitments to structured format
@@ -167,16 +280,39 @@ func (ma *MemoryAgent) updateUserCommitments(ctx context.Context, uid string, co
 	return err
 }
 
-// UpdateMemorySummary updates the user's overall memory summary
+// maxMemorySummaryRunes bounds the stored memory summary so it can't grow unbounded
+const maxMemorySummaryRunes = 1200
+
+// maxMemorySummaryAttempts bounds how often a summary is regenerated when another update lands first
+const maxMemorySummaryAttempts = 3
+
+// errMemorySummaryChanged aborts a memory summary write whose base summary is out of date
+var errMemorySummaryChanged = errors.New("memory summary changed while it was being updated")
+
+// UpdateMemorySummary folds a new insight into the user's overall memory summary, skipping an
+// insight identical to the last one applied. The summary is generated outside the transaction so
+// Firestore retries don't repeat the model call; the transaction only writes it if the summary it
+// was built from is still current, and otherwise it is regenerated from the newer one.
 func (ma *MemoryAgent) UpdateMemorySummary(ctx context.Context, uid string, newInsight string) error {
-	// Get current user
-	user, err := ma.fs.GetUser(ctx, uid)
-	if err != nil {
-		return err
+	newInsight = strings.TrimSpace(newInsight)
+	if newInsight == "" {
+		return nil
 	}
+	insightHash := hashInsight(newInsight)
+	userRef := ma.fs.DB.Collection("users").Doc(uid)
 
-	// Generate updated summary
-	prompt := fmt.Sprintf(`Update this user's memory summary with new insight.
+	for attempt := 1; ; attempt++ {
+		user, err := ma.fs.GetUser(ctx, uid)
+		if err != nil {
+			return err
+		}
+
+		// Already incorporated (e.g. a retried pipeline)
+		if user.MemoryInsightHash == insightHash {
+			return nil
+		}
+
+		prompt := fmt.Sprintf(`Update this user's memory summary with new insight.
 
 Current summary:
 %s
@@ -184,24 +320,56 @@ Current summary:
 New insight:
 %s
 
-Generate an updated summary (max 3-4 sentences) that incorporates the new insight.`, 
-		user.MemorySummary, 
-		newInsight)
+Generate an updated summary (max 3-4 sentences) that incorporates the new insight.`,
+			user.MemorySummary,
+			newInsight)
 
-	updatedSummary, err := ma.geminiClient.GenerateContent(ctx, prompt, "")
-	if err != nil {
-		return err
-	}
+		updatedSummary, err := ma.geminiClient.GenerateContent(ctx, prompt, "")
+		if err != nil {
+			return err
+		}
+		updatedSummary = textutil.TruncateSafe(strings.TrimSpace(updatedSummary), maxMemorySummaryRunes)
 
-	// Update user document
-	_, err = ma.fs.DB.Collection("users").Doc(uid).Update(ctx, []firestore.Update{
-		{
-			Path:  "memory_summary",
-			Value: strings.TrimSpace(updatedSummary),
-		},
-	})
+		err = ma.fs.DB.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			doc, err := tx.Get(userRef)
+			if err != nil {
+				return err
+			}
 
-	return err
+			var current models.User
+			if err := doc.DataTo(&current); err != nil {
+				return err
+			}
+
+			if current.MemoryInsightHash == insightHash {
+				return nil
+			}
+			if current.MemorySummary != user.MemorySummary {
+				return errMemorySummaryChanged
+			}
+
+			return tx.Update(userRef, []firestore.Update{
+				{
+					Path:  "memory_summary",
+					Value: updatedSummary,
+				},
+				{
+					Path:  "memory_insight_hash",
+					Value: insightHash,
+				},
+			})
+		})
+		if !errors.Is(err, errMemorySummaryChanged) || attempt == maxMemorySummaryAttempts {
+			return err
+		}
+	}
+}
+
+// hashInsight identifies an insight independent of case and surrounding whitespace
+func hashInsight(insight string) string {
+	normalized := strings.Join(strings.Fields(strings.ToLower(insight)), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
 }
 
 // Helper function to generate commitment ID
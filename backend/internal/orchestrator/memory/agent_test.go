@@ -0,0 +1,221 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/coach"
+)
+
+func TestExtractCommitmentsParsesWrappedJSON(t *testing.T) {
+	tests := map[string]string{
+		"unfenced": `["Walk after lunch", "  ", "Lights out by 11"]`,
+		"fenced":   "```json\n[\"Walk after lunch\", \"  \", \"Lights out by 11\"]\n```",
+		"prose":    "Here are the commitments:\n[\"Walk after lunch\", \"  \", \"Lights out by 11\"]",
+	}
+	for name, response := range tests {
+		t.Run(name, func(t *testing.T) {
+			agent := NewMemoryAgent(nil, geminitest.NewFakeProvider(geminitest.Script{Prefix: "Extract specific commitments", Text: response}))
+			got, err := agent.extractCommitments(context.Background(), "Coach: so you'll walk after lunch?")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"Walk after lunch", "Lights out by 11"}; !slices.Equal(got, want) {
+				t.Errorf("commitments = %q, want %q", got, want)
+			}
+		})
+	}
+
+	agent := NewMemoryAgent(nil, geminitest.NewFakeProvider(geminitest.Script{Prefix: "Extract specific commitments", Text: "No commitments this time."}))
+	if _, err := agent.extractCommitments(context.Background(), "Coach: see you soon"); err == nil {
+		t.Error("prose-only reply parsed as commitments")
+	}
+}
+
+func TestUpdateRedactsSummaryAndCommitments(t *testing.T) {
+	optOut := &models.CoachSpec{}
+	optOut.Policies.Privacy.StoreSensitiveMemory = true
+	tests := []struct {
+		name            string
+		spec            *models.CoachSpec
+		wantSummary     string
+		wantCommitments []string
+	}{
+		{"redacted", nil, "Shared [REDACTED] to log in.", []string{"Rotate [REDACTED]", "Walk daily"}},
+		{"opted out", optOut, "Shared password: hunter2 to log in.", []string{"Rotate token: abc123", "Walk daily"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := firestoretest.New(t)
+			if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1"}); err != nil {
+				t.Fatal(err)
+			}
+			provider := geminitest.NewFakeProvider(
+				geminitest.Script{Prefix: "Summarize this coaching session", Text: "Shared password: hunter2 to log in."},
+				geminitest.Script{Prefix: "Extract specific commitments", Text: `["Rotate token: abc123", "Walk daily"]`},
+				geminitest.Script{Prefix: "Update this user's memory summary", Text: "Tidying up their logins."},
+			)
+			agent := NewMemoryAgent(fs, provider)
+
+			if err := agent.Update(ctx, "s1", "u1", &coach.CoachOutput{MessageText: "Coach: let's tidy up"}, tt.spec); err != nil {
+				t.Fatal(err)
+			}
+
+			doc, err := fs.DB.Collection("sessions").Doc("s1").Get(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary, _ := doc.DataAt("summary.text"); summary != tt.wantSummary {
+				t.Errorf("stored summary = %q, want %q", summary, tt.wantSummary)
+			}
+			user, err := fs.GetUser(ctx, "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for _, commitment := range user.Commitments {
+				texts = append(texts, commitment.Text)
+			}
+			if !slices.Equal(texts, tt.wantCommitments) {
+				t.Errorf("stored commitments = %q, want %q", texts, tt.wantCommitments)
+			}
+
+			// The user-wide memory is shared across coaches and never keeps sensitive values
+			if user.MemorySummary != "Tidying up their logins." {
+				t.Errorf("memory summary = %q", user.MemorySummary)
+			}
+			calls := provider.Calls()
+			if prompt := calls[len(calls)-1].SystemPrompt; !strings.Contains(prompt, "Shared [REDACTED] to log in.") {
+				t.Errorf("memory summary prompt = %q, want the redacted session summary", prompt)
+			}
+		})
+	}
+}
+
+func TestUpdateMemorySummaryIdempotent(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1", MemorySummary: "Runs in the mornings."}); err != nil {
+		t.Fatal(err)
+	}
+	provider := geminitest.NewFakeProvider(geminitest.Script{
+		Prefix: "Update this user's memory summary",
+		Text:   " Runs in the mornings. Struggles with late-night scrolling. ",
+	})
+	agent := NewMemoryAgent(fs, provider)
+
+	// A retried pipeline applies the same insight again, with different spacing
+	for _, insight := range []string{"Struggles with late-night scrolling", "  struggles with  late-night scrolling\n"} {
+		if err := agent.UpdateMemorySummary(ctx, "u1", insight); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(provider.Calls()); n != 1 {
+		t.Errorf("summarized %d times, want the repeated insight incorporated once", n)
+	}
+	user, err := fs.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.MemorySummary != "Runs in the mornings. Struggles with late-night scrolling." {
+		t.Errorf("summary = %q", user.MemorySummary)
+	}
+	if !strings.Contains(provider.Calls()[0].SystemPrompt, "Runs in the mornings.") {
+		t.Error("the current summary wasn't passed in to be updated")
+	}
+
+	if err := agent.UpdateMemorySummary(ctx, "u1", "Wants to sleep by 11"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(provider.Calls()); n != 2 {
+		t.Errorf("summarized %d times, want a new insight incorporated", n)
+	}
+}
+
+// racingProvider lets another memory update land while the first summary is being generated
+type racingProvider struct {
+	*geminitest.FakeProvider
+	race func()
+}
+
+func (p *racingProvider) GenerateContent(ctx context.Context, systemPrompt, userPrompt string) (string, error) {
+	if p.race != nil {
+		p.race()
+		p.race = nil
+	}
+	return p.FakeProvider.GenerateContent(ctx, systemPrompt, userPrompt)
+}
+
+func TestUpdateMemorySummaryRegeneratesFromNewerSummary(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	userRef := fs.DB.Collection("users").Doc("u1")
+	if _, err := userRef.Set(ctx, models.User{UID: "u1", MemorySummary: "Runs in the mornings."}); err != nil {
+		t.Fatal(err)
+	}
+	provider := &racingProvider{
+		FakeProvider: geminitest.NewFakeProvider(geminitest.Script{
+			Prefix: "Update this user's memory summary",
+			Text:   "Runs in the mornings. Drinks coffee late. Wants to sleep by 11.",
+		}),
+		race: func() {
+			if _, err := userRef.Set(ctx, map[string]interface{}{"memory_summary": "Runs in the mornings. Drinks coffee late."}, firestore.MergeAll); err != nil {
+				t.Error(err)
+			}
+		},
+	}
+	agent := NewMemoryAgent(fs, provider)
+
+	if err := agent.UpdateMemorySummary(ctx, "u1", "Wants to sleep by 11"); err != nil {
+		t.Fatal(err)
+	}
+	calls := provider.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("summarized %d times, want one regeneration after the concurrent update", len(calls))
+	}
+	if !strings.Contains(calls[1].SystemPrompt, "Drinks coffee late.") {
+		t.Error("the regenerated summary wasn't built from the newer one")
+	}
+	user, err := fs.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.MemorySummary != "Runs in the mornings. Drinks coffee late. Wants to sleep by 11." {
+		t.Errorf("summary = %q", user.MemorySummary)
+	}
+}
+
+func TestUpdateMemorySummaryBounded(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	agent := NewMemoryAgent(fs, geminitest.NewFakeProvider(geminitest.Script{
+		Prefix: "Update this user's memory summary",
+		Text:   strings.Repeat("Koşu sabahları. ", 200),
+	}))
+
+	if err := agent.UpdateMemorySummary(ctx, "u1", "Sabah koşusu"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := fs.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := utf8.RuneCountInString(user.MemorySummary); n > maxMemorySummaryRunes || !utf8.ValidString(user.MemorySummary) {
+		t.Errorf("summary is %d runes (valid UTF-8 %v), want at most %d", n, utf8.ValidString(user.MemorySummary), maxMemorySummaryRunes)
+	}
+}
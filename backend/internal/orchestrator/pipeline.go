@@ -2,8 +2,16 @@ package orchestrator
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	fs "cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+
+	"simon-backend/internal/cards"
+	"simon-backend/internal/config"
 	"simon-backend/internal/firestore"
 	"simon-backend/internal/gemini"
 	"simon-backend/internal/models"
@@ -13,6 +21,8 @@ import (
 	"simon-backend/internal/orchestrator/planner"
 	"simon-backend/internal/orchestrator/router"
 	"simon-backend/internal/orchestrator/safety"
+	"simon-backend/internal/redact"
+	"simon-backend/internal/tools"
 )
 
 // SSEEvent represents a server-sent event (alias to coach.SSEEvent)
@@ -20,12 +30,28 @@ type SSEEvent = coach.SSEEvent
 
 // Pipeline orchestrates the multi-agent coaching flow
 type Pipeline struct {
+	fs             *firestore.Client
 	router         *router.RouterAgent
 	contextBuilder *orchestratorContext.ContextBuilder
 	coachAgent     *coach.CoachAgent
 	plannerAgent   *planner.PlannerAgent
 	safetyFilter   *safety.SafetyFilter
 	memoryAgent    *memory.MemoryAgent
+	plans          PlanCreator
+
+	creditsPerMessage int
+	tokensPerCredit   int
+	// visionEnabled is whether the configured model accepts image attachments
+	visionEnabled bool
+	// historyTokenBudget bounds the earlier turns sent with each message
+	historyTokenBudget int
+	// formattingMode is what happens to replies breaking their coach's formatting limits
+	formattingMode string
+}
+
+// PlanCreator saves the plans the planner extracts
+type PlanCreator interface {
+	Create(ctx context.Context, req tools.PlanCreateRequest) (*tools.PlanCreateResponse, error)
 }
 
 // PipelineInput contains the input for pipeline execution
@@ -33,9 +59,40 @@ type PipelineInput struct {
 	SessionID   string
 	CoachID     string
 	UserMessage string
+	Attachments []models.Attachment
 	UID         string
+	// UserMessageID is the stored message this turn replies to; a plan is saved once per message
+	UserMessageID string
+	// History is the session's transcript before this message, oldest first
+	History []models.Message
+	// Locale is the client's Accept-Language, used to pick regional crisis resources
+	Locale string
+
+	// StyleAdjustment overrides the coach's style for this turn only (regenerate)
+	StyleAdjustment *models.StyleAdjustment
+	// FirstTurn is true when the coach hasn't replied in this session yet
+	FirstTurn bool
+	// IncludeContext overrides the user's include_context preference when set
+	IncludeContext *bool
+	// GrantedPermissions are the device permissions the client reported (e.g. "calendar"); nil if unreported
+	GrantedPermissions []string
+	// CoachSpecSnapshot is the session's copy of the coach spec, used if the coach is gone
+	CoachSpecSnapshot *models.CoachSpec
+	// CoachNoticeSent is true once the user has been told the session's coach is unavailable
+	CoachNoticeSent bool
+	// ReservedCredits were taken from a non-Pro user's balance before the turn started. A completed
+	// reply is charged against them and the rest refunded; any other outcome refunds them all.
+	ReservedCredits int
 }
 
+// Pipeline stages reported in stage events as each step begins
+const (
+	StageRouting         = "routing"
+	StageBuildingContext = "building_context"
+	StageGenerating      = "generating"
+	StageExtractingPlan  = "extracting_plan"
+)
+
 // PipelineOutput contains the output stream and session data
 type PipelineOutput struct {
 	Stream      chan SSEEvent
@@ -43,17 +100,45 @@ type PipelineOutput struct {
 }
 
 // NewPipeline creates a new orchestration pipeline
-func NewPipeline(fs *firestore.Client, gm *gemini.Client) *Pipeline {
+func NewPipeline(fs *firestore.Client, gm gemini.Provider, cfg config.Config) *Pipeline {
+	coachOpts := coach.Options{
+		CoalesceInterval: time.Duration(cfg.StreamCoalesceMillis) * time.Millisecond,
+		CoalesceChars:    cfg.StreamCoalesceChars,
+		Preamble:         cfg.SystemPreamble,
+		Footer:           cfg.ComplianceFooter,
+	}
+
+	crisis, err := safety.LoadCrisisDirectory(cfg.CrisisResourcesFile)
+	if err != nil {
+		log.Printf("Failed to load crisis resources, using the built-in list: %v", err)
+		crisis, _ = safety.LoadCrisisDirectory("")
+	}
+
 	return &Pipeline{
+		fs:             fs,
 		router:         router.NewRouterAgent(gm),
 		contextBuilder: orchestratorContext.NewContextBuilder(fs, gm),
-		coachAgent:     coach.NewCoachAgent(gm),
+		coachAgent:     coach.NewCoachAgent(gm, coachOpts),
 		plannerAgent:   planner.NewPlannerAgent(gm),
-		safetyFilter:   safety.NewSafetyFilter(),
+		safetyFilter:   safety.NewSafetyFilter(safety.ConfidencePolicy{Default: cfg.ToolAutoConfidence, PerTool: cfg.ToolAutoConfidenceOverrides}, crisis),
 		memoryAgent:    memory.NewMemoryAgent(fs, gm),
+		plans:          tools.NewPlanService(fs.DB),
+
+		creditsPerMessage: cfg.CreditsPerMessage,
+		tokensPerCredit:   cfg.TokensPerCredit,
+		visionEnabled:     gemini.SupportsVision(cfg.ModelID),
+
+		historyTokenBudget: cfg.HistoryTokenBudget,
+		formattingMode:     cfg.FormattingMode,
 	}
 }
 
+// WithPlans saves extracted plans through plans instead of the default Firestore service
+func (p *Pipeline) WithPlans(plans PlanCreator) *Pipeline {
+	p.plans = plans
+	return p
+}
+
 // Execute runs the full multi-agent pipeline
 func (p *Pipeline) Execute(ctx context.Context, input PipelineInput) (*PipelineOutput, error) {
 	stream := make(chan SSEEvent, 100)
@@ -61,9 +146,42 @@ func (p *Pipeline) Execute(ctx context.Context, input PipelineInput) (*PipelineO
 	go func() {
 		defer close(stream)
 
+		// Every Gemini call this turn adds to the usage reported just before stream.done
+		ctx, usage := gemini.WithUsageTracker(ctx)
+
+		// Credits reserved for this turn are refunded unless the reply completes and is charged below
+		settled := input.ReservedCredits <= 0
+		defer func() {
+			if !settled {
+				p.settleCredits(ctx, input.UID, input.ReservedCredits, 0)
+			}
+		}()
+		done := func(status string) {
+			tokens := usage.Usage()
+			stream <- SSEEvent{
+				Type: "usage",
+				Data: map[string]interface{}{
+					"prompt_tokens":     tokens.PromptTokens,
+					"completion_tokens": tokens.CompletionTokens,
+					"total_tokens":      tokens.TotalTokens,
+				},
+			}
+			stream <- SSEEvent{Type: "stream.done", Data: map[string]interface{}{"status": status}}
+		}
+
 		// Step 1: Router Agent - Classify intent
+		stream <- stageEvent(StageRouting, nil)
 		route, err := p.router.Classify(ctx, input.UserMessage, input.UID)
 		if err != nil {
+			if ctx.Err() != nil {
+				done("stopped")
+				return
+			}
+			if errors.Is(err, gemini.ErrContentBlocked) {
+				stream <- blockedNotice()
+				done("blocked")
+				return
+			}
 			stream <- SSEEvent{
 				Type: "error",
 				Data: map[string]interface{}{
@@ -74,9 +192,19 @@ func (p *Pipeline) Execute(ctx context.Context, input PipelineInput) (*PipelineO
 			return
 		}
 
+		stream <- stageEvent(StageRouting, map[string]interface{}{
+			"route":      route.Name,
+			"confidence": route.Confidence,
+		})
+
 		// Step 2: Context Builder - Fetch relevant context
-		contextPacket, err := p.contextBuilder.Build(ctx, input.UID, input.CoachID, route)
+		stream <- stageEvent(StageBuildingContext, nil)
+		contextPacket, err := p.contextBuilder.Build(ctx, input.UID, input.CoachID, input.CoachSpecSnapshot, route)
 		if err != nil {
+			if ctx.Err() != nil {
+				done("stopped")
+				return
+			}
 			stream <- SSEEvent{
 				Type: "error",
 				Data: map[string]interface{}{
@@ -87,9 +215,59 @@ func (p *Pipeline) Execute(ctx context.Context, input PipelineInput) (*PipelineO
 			return
 		}
 
-		// Step 3: Coach Agent - Generate streaming response
-		coachOutput, err := p.coachAgent.Generate(ctx, input.UserMessage, contextPacket, stream)
+		// Tell the user once that their coach is gone; the session keeps its last known spec
+		if contextPacket.CoachUnavailable && !input.CoachNoticeSent {
+			stream <- SSEEvent{
+				Type: "policy.notice",
+				Data: map[string]interface{}{
+					"kind":    "coach_unavailable",
+					"message": "The original coach for this conversation is no longer available. I'll keep coaching you the same way.",
+				},
+			}
+			p.markCoachNoticeSent(ctx, input.SessionID)
+		}
+
+		// A user who may be at risk gets crisis resources instead of coaching
+		if p.safetyFilter.NeedsEscalation(input.UserMessage, contextPacket.CoachSpec, input.Locale) {
+			escalation := p.safetyFilter.Escalation(contextPacket.CoachSpec, input.Locale)
+			stream <- escalationEvent(escalation)
+			if err := p.saveAssistantMessage(ctx, input.SessionID, escalation.Message, contextPacket.CoachSpec); err != nil {
+				log.Printf("Failed to save escalation message: sessionID=%s, err=%v", input.SessionID, err)
+			}
+			done("escalated")
+			return
+		}
+
+		contextPacket.StyleAdjustment = input.StyleAdjustment
+		contextPacket.FirstTurn = input.FirstTurn
+		if input.IncludeContext != nil {
+			contextPacket.IncludeContext = *input.IncludeContext
+		}
+		contextPacket.GrantedPermissions = input.GrantedPermissions
+		contextPacket.History, contextPacket.HistorySummary = p.conversationHistory(ctx, input.History)
+		if hasImages(input.Attachments) {
+			contextPacket.Images = p.turnImages(ctx, input.Attachments, contextPacket.CoachSpec, stream)
+		}
+
+		// Step 3: Coach Agent - Generate streaming response. Scheduling turns also extract the
+		// item to schedule in parallel, so its proposal doesn't wait for the whole reply.
+		stream <- stageEvent(StageGenerating, nil)
+		var earlyProposals <-chan []coach.ToolRequest
+		if route.Name == "scheduling" {
+			earlyProposals = p.proposeScheduleEarly(ctx, input.UserMessage, contextPacket)
+		}
+		coachOutput, proposed, err := p.generateReply(ctx, input, contextPacket, route, stream, earlyProposals)
 		if err != nil {
+			// Stopped before the coach produced any text; there's nothing to keep
+			if ctx.Err() != nil {
+				done("stopped")
+				return
+			}
+			if errors.Is(err, gemini.ErrContentBlocked) {
+				stream <- blockedNotice()
+				done("blocked")
+				return
+			}
 			stream <- SSEEvent{
 				Type: "error",
 				Data: map[string]interface{}{
@@ -100,8 +278,25 @@ func (p *Pipeline) Execute(ctx context.Context, input PipelineInput) (*PipelineO
 			return
 		}
 
+		// A reply cut off mid-stream (or stopped by the user) is kept as-is; planning and tools
+		// would act on half a thought
+		if coachOutput.Interrupted {
+			if err := p.saveAssistantMessage(ctx, input.SessionID, coachOutput.MessageText, contextPacket.CoachSpec); err != nil {
+				log.Printf("Failed to save partial assistant message: sessionID=%s, err=%v", input.SessionID, err)
+			}
+			if coachOutput.Stopped {
+				done("stopped")
+			} else {
+				done("interrupted")
+			}
+			return
+		}
+
+		coachOutput.MessageText = p.checkFormatting(ctx, coachOutput, contextPacket.CoachSpec, stream)
+
 		// Step 4: Planner Agent - Extract structured outputs (if needed)
 		if route.NeedsPlanner {
+			stream <- stageEvent(StageExtractingPlan, nil)
 			plannerOutput, err := p.plannerAgent.Generate(ctx, coachOutput, contextPacket.CoachSpec)
 			if err != nil {
 				// Non-fatal error, log but continue
@@ -112,42 +307,49 @@ func (p *Pipeline) Execute(ctx context.Context, input PipelineInput) (*PipelineO
 						"message": "Could not extract structured plan",
 					},
 				}
+			} else if plannerOutput.Plan == nil && len(plannerOutput.NextActions) == 0 && plannerOutput.WeeklyReview == nil {
+				// Nothing to organize; lets the client clear any pending card UI
+				stream <- SSEEvent{
+					Type: "planner.empty",
+					Data: map[string]interface{}{
+						"route": route.Name,
+					},
+				}
 			} else {
-				// Emit structured cards
+				// Emit structured cards; the plan is saved first so the card can reference it
 				if plannerOutput.Plan != nil {
+					data := cards.Data(cards.SchemaPlan, "plan", plannerOutput.Plan)
+					if planID := p.savePlan(ctx, input, plannerOutput.Plan); planID != "" {
+						data["plan_id"] = planID
+					}
 					stream <- SSEEvent{
-						Type: "card.plan",
-						Data: map[string]interface{}{
-							"schema": "Plan.v1",
-							"plan":   plannerOutput.Plan,
-						},
+						Type: cards.TypePlan,
+						Data: data,
 					}
 				}
 
 				if len(plannerOutput.NextActions) > 0 {
 					stream <- SSEEvent{
-						Type: "card.next_actions",
-						Data: map[string]interface{}{
-							"schema": "NextAction.v1",
-							"items":  plannerOutput.NextActions,
-						},
+						Type: cards.TypeNextActions,
+						Data: cards.Data(cards.SchemaNextActions, "items", plannerOutput.NextActions),
 					}
 				}
 
 				if plannerOutput.WeeklyReview != nil {
 					stream <- SSEEvent{
-						Type: "card.weekly_review",
-						Data: map[string]interface{}{
-							"schema": "WeeklyReview.v1",
-							"review": plannerOutput.WeeklyReview,
-						},
+						Type: cards.TypeWeeklyReview,
+						Data: cards.Data(cards.SchemaWeeklyReview, "review", plannerOutput.WeeklyReview),
 					}
 				}
 			}
 		}
 
-		// Step 5: Safety Filter - Validate output
-		if err := p.safetyFilter.Validate(ctx, coachOutput, contextPacket.CoachSpec); err != nil {
+		// Step 5: Safety Filter - Validate output. A reply that turned to self-harm has already
+		// streamed, so the crisis resources follow it.
+		var refusal *safety.RefusalError
+		if err := p.safetyFilter.Validate(ctx, coachOutput, contextPacket.CoachSpec, input.Locale); errors.As(err, &refusal) && refusal.Kind == safety.RefusalSelfHarm {
+			stream <- escalationEvent(p.safetyFilter.Escalation(contextPacket.CoachSpec, input.Locale))
+		} else if err != nil {
 			stream <- SSEEvent{
 				Type: "policy.notice",
 				Data: map[string]interface{}{
@@ -157,24 +359,299 @@ func (p *Pipeline) Execute(ctx context.Context, input PipelineInput) (*PipelineO
 			}
 		}
 
+		// Tools already proposed early aren't proposed twice
+		toolRequests := []coach.ToolRequest{}
+		for _, toolReq := range coachOutput.ToolRequests {
+			if !proposed[toolReq.Tool] {
+				toolRequests = append(toolRequests, toolReq)
+			}
+		}
+		p.proposeTools(stream, toolRequests, route, contextPacket)
+
 		// Step 6: Memory Agent - Update user memory asynchronously
 		go func() {
-			if err := p.memoryAgent.Update(context.Background(), input.SessionID, input.UID, coachOutput); err != nil {
+			if err := p.memoryAgent.TitleSession(context.Background(), input.SessionID, input.UserMessage, coachOutput.MessageText); err != nil {
+				log.Printf("Session title generation failed: sessionID=%s, err=%v", input.SessionID, err)
+			}
+			if err := p.memoryAgent.Update(context.Background(), input.SessionID, input.UID, coachOutput, contextPacket.CoachSpec); err != nil {
 				// Log error but don't fail the request
 				fmt.Printf("Memory update failed: %v\n", err)
 			}
 		}()
 
-		// Send completion event
-		stream <- SSEEvent{
-			Type: "stream.done",
-			Data: map[string]interface{}{
-				"status": "ok",
-			},
+		if err := p.saveAssistantMessage(ctx, input.SessionID, coachOutput.MessageText, contextPacket.CoachSpec); err != nil {
+			log.Printf("Failed to save assistant message: sessionID=%s, err=%v", input.SessionID, err)
 		}
+
+		if !settled {
+			p.settleCredits(ctx, input.UID, input.ReservedCredits, p.replyCost(usage.Usage()))
+			settled = true
+		}
+
+		// Send completion event
+		done("ok")
 	}()
 
 	return &PipelineOutput{
 		Stream: stream,
 	}, nil
 }
+
+// generateReply runs the coach agent, relaying its events to stream. Scheduling proposals that
+// arrive on early are emitted between the coach's deltas as soon as they're ready, and always
+// before message.final; the returned set holds the tools proposed that way.
+func (p *Pipeline) generateReply(
+	ctx context.Context,
+	input PipelineInput,
+	contextPacket *orchestratorContext.ContextPacket,
+	route *router.Route,
+	stream chan<- SSEEvent,
+	early <-chan []coach.ToolRequest,
+) (*coach.CoachOutput, map[string]bool, error) {
+	proposed := map[string]bool{}
+	if early == nil {
+		output, err := p.coachAgent.Generate(ctx, input.UserMessage, contextPacket, stream)
+		return output, proposed, err
+	}
+
+	events := make(chan SSEEvent, 100)
+	var output *coach.CoachOutput
+	var err error
+	go func() {
+		defer close(events)
+		output, err = p.coachAgent.Generate(ctx, input.UserMessage, contextPacket, events)
+	}()
+
+	propose := func(requests []coach.ToolRequest) {
+		for _, req := range p.proposeTools(stream, requests, route, contextPacket) {
+			proposed[req.Tool] = true
+		}
+		early = nil
+	}
+
+	for {
+		select {
+		case requests := <-early:
+			propose(requests)
+
+		case event, ok := <-events:
+			if !ok {
+				// Channel closed, so the goroutine's writes to output and err are visible
+				return output, proposed, err
+			}
+			// Hold the final message until the proposal is out, so it never trails the reply
+			if event.Type == "message.final" && early != nil {
+				propose(<-early)
+			}
+			stream <- event
+		}
+	}
+}
+
+// proposeScheduleEarly extracts the scheduling proposal in the background; the channel receives
+// exactly one (possibly empty) result
+func (p *Pipeline) proposeScheduleEarly(ctx context.Context, userMessage string, contextPacket *orchestratorContext.ContextPacket) <-chan []coach.ToolRequest {
+	result := make(chan []coach.ToolRequest, 1)
+	go func() {
+		requests, err := p.coachAgent.ProposeSchedule(ctx, userMessage, contextPacket)
+		if err != nil {
+			log.Printf("Early schedule extraction failed: %v", err)
+		}
+		result <- requests
+	}()
+	return result
+}
+
+// proposeTools screens tool requests and emits the ones the client can run as tool.request
+// events, asking for missing device permissions instead of proposing the rest. It returns the
+// requests that were emitted.
+func (p *Pipeline) proposeTools(stream chan<- SSEEvent, requests []coach.ToolRequest, route *router.Route, contextPacket *orchestratorContext.ContextPacket) []coach.ToolRequest {
+	toolRequests := p.safetyFilter.GateToolConfidence(requests, route.Confidence)
+	toolRequests, permissionNotices := p.safetyFilter.ScreenToolPermissions(toolRequests, contextPacket.GrantedPermissions)
+	for _, notice := range permissionNotices {
+		stream <- SSEEvent{
+			Type: "policy.notice",
+			Data: map[string]interface{}{
+				"kind":        "permission_required",
+				"tool":        notice.Tool,
+				"permissions": notice.Missing,
+				"message":     notice.Message(),
+			},
+		}
+	}
+	for _, toolReq := range toolRequests {
+		stream <- SSEEvent{
+			Type: "tool.request",
+			Data: map[string]interface{}{
+				"request_id":            toolReq.RequestID,
+				"tool":                  toolReq.Tool,
+				"requires_confirmation": toolReq.RequiresConfirmation,
+				"reason":                toolReq.Reason,
+				"payload":               toolReq.Payload,
+			},
+		}
+	}
+	return toolRequests
+}
+
+// turnImages loads the turn's images for the coach. Coaches (or models) that can't take images,
+// and images that fail to load, get a policy.notice and the turn continues with text alone.
+func (p *Pipeline) turnImages(ctx context.Context, attachments []models.Attachment, spec *models.CoachSpec, stream chan<- SSEEvent) []gemini.Media {
+	if !p.visionEnabled || !spec.ImagesAccepted() {
+		stream <- SSEEvent{
+			Type: "policy.notice",
+			Data: map[string]interface{}{
+				"kind":    "images_unsupported",
+				"message": "This coach can't look at images, so I'll reply to your text only.",
+			},
+		}
+		return nil
+	}
+
+	images, err := loadImages(ctx, attachments)
+	if err != nil {
+		log.Printf("Failed to load image attachments: %v", err)
+		stream <- SSEEvent{
+			Type: "policy.notice",
+			Data: map[string]interface{}{
+				"kind":    "image_unavailable",
+				"message": "I couldn't open your image, so I'll reply to your text only.",
+			},
+		}
+		return nil
+	}
+	return images
+}
+
+// saveAssistantMessage stores an assistant reply in the session transcript, redacting sensitive
+// data unless spec allows storing it. It outlives the request context so a reply cut short by a
+// disconnect is still saved.
+func (p *Pipeline) saveAssistantMessage(ctx context.Context, sessionID, text string, spec *models.CoachSpec) error {
+	if sessionID == "" {
+		return nil
+	}
+
+	text, redacted := redact.ForStorage(text, spec)
+	if redacted > 0 {
+		log.Printf("Redacted %d sensitive value(s) from assistant message in session %s", redacted, sessionID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	msg := models.Message{
+		ID:          uuid.New().String(),
+		Role:        "assistant",
+		ContentText: text,
+		CreatedAt:   time.Now(),
+	}
+	sessionRef := p.fs.DB.Collection("sessions").Doc(sessionID)
+	if _, err := sessionRef.Collection("messages").Doc(msg.ID).Set(ctx, msg); err != nil {
+		return err
+	}
+
+	if _, err := sessionRef.Update(ctx, []fs.Update{
+		{Path: "updated_at", Value: msg.CreatedAt},
+	}); err != nil {
+		log.Printf("Failed to update session timestamp: sessionID=%s, err=%v", sessionID, err)
+	}
+	return nil
+}
+
+// savePlan stores a plan the planner extracted and returns its ID, or "" if it couldn't be saved.
+// The plan is keyed on the user message it answers, so a retried or regenerated turn gets the
+// existing plan back instead of a duplicate, however the model words it this time. If the user has
+// archived that plan it stays archived and "" is returned, so the card doesn't link to it.
+func (p *Pipeline) savePlan(ctx context.Context, input PipelineInput, plan *models.Plan) string {
+	if input.SessionID == "" {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	resp, err := p.plans.Create(ctx, tools.PlanCreateRequest{
+		UID:            input.UID,
+		CoachID:        input.CoachID,
+		SessionID:      input.SessionID,
+		Plan:           *plan,
+		IdempotencyKey: tools.PlanTurnIdempotencyKey(input.SessionID, input.UserMessageID),
+	})
+	if err != nil {
+		log.Printf("Failed to save planner plan: sessionID=%s, err=%v", input.SessionID, err)
+		return ""
+	}
+	if resp.Status == "archived" {
+		log.Printf("Planner plan was archived by the user: sessionID=%s, planID=%s", input.SessionID, resp.PlanID)
+		return ""
+	}
+	plan.ID = resp.PlanID
+	return resp.PlanID
+}
+
+// replyCost is what a completed reply costs in credits
+func (p *Pipeline) replyCost(usage gemini.TokenUsage) int {
+	cost := p.creditsPerMessage
+	if p.tokensPerCredit > 0 {
+		cost = (usage.TotalTokens + p.tokensPerCredit - 1) / p.tokensPerCredit
+	}
+	return max(cost, 0)
+}
+
+// settleCredits charges cost against the credits reserved for a turn, refunding the rest. It
+// outlives the request context so a client disconnecting at the last moment is still charged.
+func (p *Pipeline) settleCredits(ctx context.Context, uid string, reserved, cost int) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+
+	if _, err := p.fs.SettleCredits(ctx, uid, reserved, cost); err != nil {
+		log.Printf("Failed to settle credits: uid=%s, reserved=%d, cost=%d, err=%v", uid, reserved, cost, err)
+	}
+}
+
+// markCoachNoticeSent records that the coach-unavailable notice was shown for the session
+func (p *Pipeline) markCoachNoticeSent(ctx context.Context, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+	if _, err := p.fs.DB.Collection("sessions").Doc(sessionID).Update(ctx, []fs.Update{
+		{Path: "coach_unavailable_notified", Value: true},
+	}); err != nil {
+		log.Printf("Failed to mark coach notice sent: sessionID=%s, err=%v", sessionID, err)
+	}
+}
+
+// stageEvent builds a stage event, which tells the client what the pipeline is working on before
+// (and between) the coach's tokens. The routing stage is reported again once classified, with
+// the route and its confidence.
+func stageEvent(stage string, details map[string]interface{}) SSEEvent {
+	data := map[string]interface{}{"stage": stage}
+	for key, value := range details {
+		data[key] = value
+	}
+	return SSEEvent{Type: "stage", Data: data}
+}
+
+// escalationEvent builds the safety.escalation event carrying the support message and the
+// crisis resources for the user's region
+func escalationEvent(escalation safety.Escalation) SSEEvent {
+	return SSEEvent{
+		Type: "safety.escalation",
+		Data: map[string]interface{}{
+			"message":   escalation.Message,
+			"region":    escalation.Region,
+			"resources": escalation.Resources,
+		},
+	}
+}
+
+// blockedNotice builds the user-safe notice sent when Gemini blocks content
+func blockedNotice() SSEEvent {
+	return SSEEvent{
+		Type: "policy.notice",
+		Data: map[string]interface{}{
+			"kind":    "content_blocked",
+			"message": "I can't help with that.",
+		},
+	}
+}
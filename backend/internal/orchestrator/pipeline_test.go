@@ -0,0 +1,947 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"simon-backend/internal/cards"
+	"simon-backend/internal/config"
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/gemini"
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/safety"
+	"simon-backend/internal/tools"
+)
+
+const reviewReply = "Solid week. You shipped the launch on Thursday but skipped both long runs. "
+
+func TestPipelineWeeklyReview(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coachID := "retro-coach"
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc(coachID).Set(ctx, models.Coach{
+		ID:         coachID,
+		Visibility: "public",
+		Title:      "Retro",
+		CoachSpec: &models.CoachSpec{
+			Identity:     models.Identity{Name: "Retro", Niche: "weekly review"},
+			ToolsAllowed: models.ToolsAllowed{ServerTools: []string{"memory_write"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{
+		ID: "s1", UID: "u1", CoachID: &coachID, Title: "Friday retro", CreatedAt: time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{
+			Prefix: "Classify the user's intent",
+			Text:   `{"route": "review_retro", "confidence": 0.92, "needs_planner": true}`,
+		},
+		geminitest.Script{
+			Prefix: "You are Retro, a weekly review coach.",
+			Text:   reviewReply,
+			ToolCalls: []gemini.FunctionCall{{
+				Name: "memory_write",
+				Args: map[string]interface{}{
+					"patch":      map[string]interface{}{"commitments_add": []interface{}{"Two long runs next week"}},
+					"reason":     "So I can check in on your runs next Friday",
+					"confidence": 0.9,
+				},
+			}},
+		},
+		geminitest.Script{
+			Prefix: "Extract structured data from this coaching response.",
+			Text: "```json\n" + `{"WeeklyReview": {
+				"wins": ["Shipped the launch"],
+				"misses": ["Skipped both long runs"],
+				"root_causes": ["Launch week crunch"],
+				"next_week_focus": ["Protect Saturday mornings"],
+				"commitments": []
+			}}` + "\n```",
+		},
+	)
+
+	pipeline := NewPipeline(fs, provider, config.Config{})
+	out, err := pipeline.Execute(ctx, PipelineInput{
+		SessionID:   "s1",
+		CoachID:     coachID,
+		UID:         "u1",
+		UserMessage: "review my week",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var events []SSEEvent
+	byType := map[string]SSEEvent{}
+	for event := range out.Stream {
+		events = append(events, event)
+		byType[event.Type] = event
+	}
+
+	// The coach's deltas are collapsed; everything else must arrive in this order
+	want := []string{
+		"stage", "stage", "stage", "stage", "stream.open", "message.delta", "message.final",
+		"stage", cards.TypeWeeklyReview, "tool.request", "usage", "stream.done",
+	}
+	var got []string
+	for _, event := range events {
+		if event.Type == "message.delta" && len(got) > 0 && got[len(got)-1] == "message.delta" {
+			continue
+		}
+		got = append(got, event.Type)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("events = %v, want %v", got, want)
+		}
+	}
+
+	if route := events[1].Data["route"]; route != "review_retro" {
+		t.Errorf("classified route = %v, want review_retro", route)
+	}
+	if text := byType["message.final"].Data["text"]; text != reviewReply {
+		t.Errorf("final text = %q, want %q", text, reviewReply)
+	}
+
+	card := byType[cards.TypeWeeklyReview].Data
+	if card["schema"] != cards.SchemaWeeklyReview {
+		t.Errorf("card schema = %v, want %s", card["schema"], cards.SchemaWeeklyReview)
+	}
+	review, ok := card["review"].(*models.WeeklyReview)
+	if !ok || len(review.Wins) != 1 || review.Wins[0] != "Shipped the launch" || review.NextWeekFocus[0] != "Protect Saturday mornings" {
+		t.Errorf("card review = %#v", card["review"])
+	}
+
+	tool := byType["tool.request"].Data
+	if tool["tool"] != "memory_write" || tool["reason"] != "So I can check in on your runs next Friday" {
+		t.Errorf("tool.request = %v", tool)
+	}
+	if payload, _ := tool["payload"].(map[string]interface{}); payload["uid"] != "u1" {
+		t.Errorf("tool payload = %v, want the caller's uid filled in", tool["payload"])
+	}
+	if status := byType["stream.done"].Data["status"]; status != "ok" {
+		t.Errorf("stream.done status = %v, want ok", status)
+	}
+
+	docs, err := fs.DB.Collection("sessions").Doc("s1").Collection("messages").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Fatalf("saved %d messages, want 1", len(docs))
+	}
+	var saved models.Message
+	if err := docs[0].DataTo(&saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Role != "assistant" || saved.ContentText != reviewReply {
+		t.Errorf("saved message = %+v, want the assistant reply", saved)
+	}
+}
+
+func TestPipelineRouterBlocked(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	provider := geminitest.NewFakeProvider(geminitest.Script{
+		Prefix: "Classify the user's intent",
+		Err:    gemini.ErrContentBlocked,
+	})
+
+	out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{UID: "u1", UserMessage: "review my week"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	var last SSEEvent
+	for event := range out.Stream {
+		types = append(types, event.Type)
+		last = event
+	}
+	if last.Type != "stream.done" || last.Data["status"] != "blocked" {
+		t.Errorf("events = %v ending with %v, want a blocked stream.done", types, last.Data)
+	}
+	if len(provider.Calls()) != 1 {
+		t.Errorf("provider called %d times, want only the router", len(provider.Calls()))
+	}
+}
+
+// heldCoachProvider holds the coach's reply stream until release is closed, so a test can see
+// what the pipeline emits while the reply is still being generated
+type heldCoachProvider struct {
+	*geminitest.FakeProvider
+	coachPrefix string
+	release     chan struct{}
+}
+
+func (p *heldCoachProvider) GenerateContentStreamWithTools(ctx context.Context, history []gemini.Turn, prompt string, media []gemini.Media, tools []gemini.FunctionDecl) (<-chan gemini.StreamPart, <-chan error) {
+	if strings.HasPrefix(prompt, p.coachPrefix) {
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+		}
+	}
+	return p.FakeProvider.GenerateContentStreamWithTools(ctx, history, prompt, media, tools)
+}
+
+func TestPipelineSchedulingProposesEarly(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	coachID := "calendar-coach"
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc(coachID).Set(ctx, models.Coach{
+		ID:         coachID,
+		Visibility: "public",
+		Title:      "Tempo",
+		CoachSpec: &models.CoachSpec{
+			Identity:     models.Identity{Name: "Tempo", Niche: "scheduling"},
+			ToolsAllowed: models.ToolsAllowed{ClientTools: []string{"reminder_create"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reminder := gemini.FunctionCall{
+		Name: "reminder_create",
+		Args: map[string]interface{}{"title": "Call the dentist", "due_iso": "2026-04-16T09:00:00Z", "confidence": 0.95},
+	}
+	provider := &heldCoachProvider{
+		FakeProvider: geminitest.NewFakeProvider(
+			geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "scheduling", "confidence": 0.95, "needs_planner": false}`},
+			geminitest.Script{Prefix: "The user wants to schedule something.", ToolCalls: []gemini.FunctionCall{reminder}},
+			// The coach proposes the same reminder again at the end of its reply
+			geminitest.Script{Prefix: "You are Tempo, a scheduling coach.", Text: "Done, I'll remind you tomorrow morning. ", ToolCalls: []gemini.FunctionCall{reminder}},
+		),
+		coachPrefix: "You are Tempo, a scheduling coach.",
+		release:     make(chan struct{}),
+	}
+	provider.Default = "{}"
+
+	out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{
+		CoachID:            coachID,
+		UID:                "u1",
+		UserMessage:        "remind me to call the dentist tomorrow at 9",
+		GrantedPermissions: []string{"reminders"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	var proposal SSEEvent
+	for proposal.Type == "" {
+		select {
+		case event, ok := <-out.Stream:
+			if !ok {
+				t.Fatalf("stream ended without a proposal: %v", types)
+			}
+			types = append(types, event.Type)
+			if event.Type == "message.final" {
+				t.Fatalf("message.final before the proposal: %v", types)
+			}
+			if event.Type == "tool.request" {
+				proposal = event
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no proposal while the coach reply was held; events so far %v", types)
+		}
+	}
+	if proposal.Data["tool"] != "reminder_create" {
+		t.Errorf("proposal = %v, want reminder_create", proposal.Data)
+	}
+	if payload, _ := proposal.Data["payload"].(map[string]interface{}); payload["title"] != "Call the dentist" {
+		t.Errorf("payload = %v", proposal.Data["payload"])
+	}
+
+	close(provider.release)
+	requests := 1
+	sawFinal := false
+	for event := range out.Stream {
+		types = append(types, event.Type)
+		switch event.Type {
+		case "tool.request":
+			requests++
+		case "message.final":
+			sawFinal = true
+		}
+	}
+	if !sawFinal {
+		t.Errorf("no message.final after release: %v", types)
+	}
+	if requests != 1 {
+		t.Errorf("reminder proposed %d times, want once: %v", requests, types)
+	}
+}
+
+func TestPipelinePlannerEmpty(t *testing.T) {
+	tests := []struct {
+		name      string
+		planner   geminitest.Script
+		wantEmpty bool
+		wantWarn  bool
+	}{
+		{"nothing extracted", geminitest.Script{Text: "```json\n{}\n```"}, true, false},
+		{"actions extracted", geminitest.Script{Text: `{"NextActions": [{"id": "a1", "title": "Write three pages", "status": "pending"}]}`}, false, false},
+		{"planner failed", geminitest.Script{Err: gemini.ErrQueueTimeout}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := firestoretest.New(t)
+			if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fs.DB.Collection("coaches").Doc("sage").Set(ctx, models.Coach{
+				ID:         "sage",
+				Visibility: "public",
+				Title:      "Sage",
+				CoachSpec:  &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "mindset"}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			tt.planner.Prefix = "Extract structured data from this coaching response."
+			provider := geminitest.NewFakeProvider(
+				geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "deep_session", "confidence": 0.8, "needs_planner": true}`},
+				geminitest.Script{Prefix: "You are Sage, a mindset coach.", Text: "That sounds heavy. What felt hardest about today? "},
+				tt.planner,
+			)
+
+			out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{CoachID: "sage", UID: "u1", UserMessage: "rough day"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var types []string
+			var empty, warning, done SSEEvent
+			for event := range out.Stream {
+				types = append(types, event.Type)
+				switch {
+				case event.Type == "planner.empty":
+					empty = event
+				case event.Type == "policy.notice" && event.Data["kind"] == "planner_warning":
+					warning = event
+				case event.Type == "stream.done":
+					done = event
+				case event.Type == "error":
+					t.Errorf("error event %v", event.Data)
+				}
+			}
+
+			if (empty.Type != "") != tt.wantEmpty {
+				t.Errorf("planner.empty emitted = %v, want %v: %v", empty.Type != "", tt.wantEmpty, types)
+			}
+			if tt.wantEmpty && empty.Data["route"] != "deep_session" {
+				t.Errorf("planner.empty data = %v, want the route", empty.Data)
+			}
+			if (warning.Type != "") != tt.wantWarn {
+				t.Errorf("planner warning emitted = %v, want %v: %v", warning.Type != "", tt.wantWarn, types)
+			}
+			if done.Data["status"] != "ok" {
+				t.Errorf("stream.done = %v, want ok", done.Data)
+			}
+		})
+	}
+}
+
+func TestPipelineCoachBlocked(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"blocked before any text", ""},
+		{"blocked mid-reply", "Here is how you could "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := firestoretest.New(t)
+			if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fs.DB.Collection("coaches").Doc("sage").Set(ctx, models.Coach{
+				ID:         "sage",
+				Visibility: "public",
+				CoachSpec:  &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "mindset"}},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			provider := geminitest.NewFakeProvider(
+				geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "deep_session", "confidence": 0.8, "needs_planner": true}`},
+				geminitest.Script{Prefix: "You are Sage, a mindset coach.", Text: tt.text, Err: gemini.ErrContentBlocked},
+			)
+
+			out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{CoachID: "sage", UID: "u1", UserMessage: "something off limits"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var types []string
+			var notice, done SSEEvent
+			for event := range out.Stream {
+				types = append(types, event.Type)
+				switch event.Type {
+				case "policy.notice":
+					notice = event
+				case "stream.done":
+					done = event
+				case "error", "message.final":
+					t.Errorf("unexpected %s event %v", event.Type, event.Data)
+				}
+			}
+
+			if notice.Data["kind"] != "content_blocked" || notice.Data["message"] != "I can't help with that." {
+				t.Errorf("notice = %v, want the user-safe blocked notice: %v", notice.Data, types)
+			}
+			if done.Data["status"] != "blocked" {
+				t.Errorf("stream.done = %v, want blocked", done.Data)
+			}
+			for _, call := range provider.Calls() {
+				if strings.HasPrefix(call.SystemPrompt, "Extract structured data") {
+					t.Error("planner ran on a blocked reply")
+				}
+			}
+		})
+	}
+}
+
+func TestPipelinePlanCardsUseRegisteredSchemas(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc("pace").Set(ctx, models.Coach{
+		ID:         "pace",
+		Visibility: "public",
+		CoachSpec:  &models.CoachSpec{Identity: models.Identity{Name: "Pace", Niche: "running"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "make_a_system", "confidence": 0.9, "needs_planner": true}`},
+		geminitest.Script{Prefix: "You are Pace, a running coach.", Text: "Three easy runs a week, building to 10k. "},
+		geminitest.Script{Prefix: "Extract structured data from this coaching response.", Text: `{
+			"Plan": {"title": "10k in 8 weeks", "objective": "Run a 10k", "horizon": "month"},
+			"NextActions": [{"id": "a1", "title": "Easy 3k on Tuesday", "status": "pending"}]
+		}`},
+	)
+
+	out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{CoachID: "pace", UID: "u1", UserMessage: "help me train for a 10k"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	byType := map[string]SSEEvent{}
+	var types []string
+	for event := range out.Stream {
+		types = append(types, event.Type)
+		byType[event.Type] = event
+	}
+
+	for eventType, schema := range map[string]string{cards.TypePlan: cards.SchemaPlan, cards.TypeNextActions: cards.SchemaNextActions} {
+		card, ok := byType[eventType]
+		if !ok {
+			t.Errorf("no %s event in %v", eventType, types)
+			continue
+		}
+		if card.Data["schema"] != schema || card.Data["content_type"] != cards.ContentType {
+			t.Errorf("%s data = %v, want schema %s and the card content type", eventType, card.Data, schema)
+		}
+	}
+	if byType[cards.TypePlan].Data["plan"] == nil || byType[cards.TypeNextActions].Data["items"] == nil {
+		t.Error("card payloads missing")
+	}
+}
+
+// recordingPlans counts the plans the pipeline saves through an injected service
+type recordingPlans struct {
+	*tools.PlanService
+	requests []tools.PlanCreateRequest
+}
+
+func (r *recordingPlans) Create(ctx context.Context, req tools.PlanCreateRequest) (*tools.PlanCreateResponse, error) {
+	r.requests = append(r.requests, req)
+	return r.PlanService.Create(ctx, req)
+}
+
+func TestPipelineRetriedTurnReusesPlan(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc("pace").Set(ctx, models.Coach{
+		ID:         "pace",
+		Visibility: "public",
+		CoachSpec:  &models.CoachSpec{Identity: models.Identity{Name: "Pace", Niche: "running"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	plans := &recordingPlans{PlanService: tools.NewPlanService(fs.DB)}
+
+	// Each attempt words the plan differently, as a retried or regenerated reply would
+	run := func(messageID, objective string) string {
+		t.Helper()
+		provider := geminitest.NewFakeProvider(
+			geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "make_a_system", "confidence": 0.9, "needs_planner": true}`},
+			geminitest.Script{Prefix: "You are Pace, a running coach.", Text: "Three easy runs a week, building to 10k. "},
+			geminitest.Script{Prefix: "Extract structured data from this coaching response.", Text: fmt.Sprintf(`{
+				"Plan": {"title": "10k in 8 weeks", "objective": %q, "horizon": "month"}
+			}`, objective)},
+		)
+		out, err := NewPipeline(fs, provider, config.Config{}).WithPlans(plans).Execute(ctx, PipelineInput{
+			SessionID:     "s1",
+			CoachID:       "pace",
+			UserMessageID: messageID,
+			UID:           "u1",
+			UserMessage:   "help me train for a 10k",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		planID := ""
+		for event := range out.Stream {
+			if event.Type == cards.TypePlan {
+				planID, _ = event.Data["plan_id"].(string)
+			}
+		}
+		return planID
+	}
+
+	first := run("m1", "Run a 10k")
+	retried := run("m1", "Finish a 10k race in eight weeks")
+	if first == "" || retried != first {
+		t.Errorf("plan ids = %q then %q, want the retried turn to reuse the first plan", first, retried)
+	}
+	if next := run("m2", "Run a 10k"); next == "" || next == first {
+		t.Errorf("plan id for the next message = %q, want a new plan", next)
+	}
+
+	if len(plans.requests) != 3 {
+		t.Errorf("saved %d plans through the injected service, want 3", len(plans.requests))
+	}
+	docs, err := fs.DB.Collection("plans").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Errorf("stored %d plans, want one per user message", len(docs))
+	}
+}
+
+func TestPipelineToolMissingPermission(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc("pace").Set(ctx, models.Coach{
+		ID:         "pace",
+		Visibility: "public",
+		CoachSpec: &models.CoachSpec{
+			Identity:     models.Identity{Name: "Pace", Niche: "running"},
+			ToolsAllowed: models.ToolsAllowed{ClientTools: []string{"reminder_create"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "quick_nudge", "confidence": 0.9}`},
+		geminitest.Script{
+			Prefix: "You are Pace, a running coach.",
+			Text:   "I'll set a reminder for your long run. ",
+			ToolCalls: []gemini.FunctionCall{{
+				Name: "reminder_create",
+				Args: map[string]interface{}{"title": "Long run", "reason": "So Saturday's run doesn't slip"},
+			}},
+		},
+	)
+	provider.Default = "[]"
+
+	out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{
+		CoachID:            "pace",
+		UID:                "u1",
+		UserMessage:        "remind me about my long run",
+		GrantedPermissions: []string{"calendar"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	var notice SSEEvent
+	for event := range out.Stream {
+		types = append(types, event.Type)
+		switch {
+		case event.Type == "tool.request":
+			t.Errorf("doomed tool.request emitted: %v", event.Data)
+		case event.Type == "policy.notice" && event.Data["kind"] == "permission_required":
+			notice = event
+		}
+	}
+
+	if notice.Type == "" {
+		t.Fatalf("no permission notice in %v", types)
+	}
+	if notice.Data["tool"] != "reminder_create" || !slices.Equal(notice.Data["permissions"].([]string), []string{"reminders"}) {
+		t.Errorf("notice = %v, want reminders requested for reminder_create", notice.Data)
+	}
+}
+
+func TestPipelineCoachInterrupted(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc("sage").Set(ctx, models.Coach{
+		ID:         "sage",
+		Visibility: "public",
+		CoachSpec:  &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "mindset"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	const partial = "Start small. Then "
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "deep_session", "confidence": 0.8, "needs_planner": true}`},
+		geminitest.Script{Prefix: "You are Sage, a mindset coach.", Text: partial, Err: errors.New("connection reset")},
+	)
+
+	out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{SessionID: "s1", CoachID: "sage", UID: "u1", UserMessage: "where do I begin?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final, notice, done SSEEvent
+	for event := range out.Stream {
+		switch event.Type {
+		case "message.final":
+			final = event
+		case "policy.notice":
+			notice = event
+		case "stream.done":
+			done = event
+		case "error":
+			t.Errorf("unexpected error event %v", event.Data)
+		}
+	}
+
+	if final.Data["text"] != partial || final.Data["interrupted"] != true {
+		t.Errorf("message.final = %v, want the partial reply marked interrupted", final.Data)
+	}
+	if notice.Data["kind"] != "generation_interrupted" {
+		t.Errorf("notice = %v, want generation_interrupted", notice.Data)
+	}
+	if done.Data["status"] != "interrupted" {
+		t.Errorf("stream.done = %v, want interrupted", done.Data)
+	}
+
+	docs, err := fs.DB.Collection("sessions").Doc("s1").Collection("messages").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Data()["role"] != "assistant" || docs[0].Data()["content_text"] != partial {
+		t.Errorf("saved messages = %d, want the partial assistant reply", len(docs))
+	}
+	for _, call := range provider.Calls() {
+		if strings.HasPrefix(call.SystemPrompt, "Extract structured data") {
+			t.Error("planner ran on an interrupted reply")
+		}
+	}
+}
+
+func TestPipelineDeletedCoachUsesSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, map[string]interface{}{"uid": "u1", "coach_id": "sage"}); err != nil {
+		t.Fatal(err)
+	}
+	// The coach was deleted after the session started; only the session's snapshot remains
+	snapshot := &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "mindset"}}
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "quick_chat", "confidence": 0.9, "needs_planner": false}`},
+		geminitest.Script{Prefix: "You are Sage, a mindset coach.", Text: "Let's pick up where we left off. "},
+	)
+	pipeline := NewPipeline(fs, provider, config.Config{})
+
+	turn := func(noticeSent bool) (notices []string) {
+		t.Helper()
+		out, err := pipeline.Execute(ctx, PipelineInput{
+			SessionID:         "s1",
+			CoachID:           "sage",
+			UID:               "u1",
+			UserMessage:       "hi again",
+			CoachSpecSnapshot: snapshot,
+			CoachNoticeSent:   noticeSent,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for event := range out.Stream {
+			switch event.Type {
+			case "policy.notice":
+				notices = append(notices, fmt.Sprint(event.Data["kind"]))
+			case "error":
+				t.Errorf("unexpected error event %v", event.Data)
+			}
+		}
+		return notices
+	}
+
+	if notices := turn(false); !slices.Contains(notices, "coach_unavailable") {
+		t.Errorf("notices = %v, want the coach_unavailable notice", notices)
+	}
+	doc, err := fs.DB.Collection("sessions").Doc("s1").Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Data()["coach_unavailable_notified"] != true {
+		t.Error("session not marked as notified")
+	}
+	if notices := turn(true); slices.Contains(notices, "coach_unavailable") {
+		t.Errorf("notices = %v, want the notice only once", notices)
+	}
+
+	for _, call := range provider.Calls() {
+		if strings.HasPrefix(call.SystemPrompt, "You are ") && !strings.HasPrefix(call.SystemPrompt, "You are Sage, a mindset coach.") {
+			t.Errorf("coach prompt = %q, want the snapshotted spec", call.SystemPrompt[:40])
+		}
+	}
+}
+
+// stallingCoachProvider streams the first part of the coach's reply and then stalls until the
+// turn is cancelled, closing cancelled once it sees the cancellation
+type stallingCoachProvider struct {
+	*geminitest.FakeProvider
+	coachPrefix string
+	partial     string
+	cancelled   chan struct{}
+}
+
+func (p *stallingCoachProvider) GenerateContentStreamWithTools(ctx context.Context, history []gemini.Turn, prompt string, media []gemini.Media, tools []gemini.FunctionDecl) (<-chan gemini.StreamPart, <-chan error) {
+	if !strings.HasPrefix(prompt, p.coachPrefix) {
+		return p.FakeProvider.GenerateContentStreamWithTools(ctx, history, prompt, media, tools)
+	}
+	parts := make(chan gemini.StreamPart)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(parts)
+		select {
+		case parts <- gemini.StreamPart{Text: p.partial}:
+		case <-ctx.Done():
+		}
+		<-ctx.Done()
+		close(p.cancelled)
+		errs <- ctx.Err()
+	}()
+	return parts, errs
+}
+
+func TestPipelineStopped(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("coaches").Doc("sage").Set(ctx, models.Coach{
+		ID:         "sage",
+		Visibility: "public",
+		CoachSpec:  &models.CoachSpec{Identity: models.Identity{Name: "Sage", Niche: "mindset"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	const partial = "Start with one page. "
+	provider := &stallingCoachProvider{
+		FakeProvider: geminitest.NewFakeProvider(
+			geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "deep_session", "confidence": 0.8, "needs_planner": true}`},
+		),
+		coachPrefix: "You are Sage, a mindset coach.",
+		partial:     partial,
+		cancelled:   make(chan struct{}),
+	}
+
+	streamCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	out, err := NewPipeline(fs, provider, config.Config{}).Execute(streamCtx, PipelineInput{SessionID: "s1", CoachID: "sage", UID: "u1", UserMessage: "how do I start writing?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var final, done SSEEvent
+	for event := range out.Stream {
+		switch event.Type {
+		case "message.delta":
+			// The user presses stop once the reply has started
+			stop()
+		case "message.final":
+			final = event
+		case "policy.notice":
+			t.Errorf("unexpected notice %v on a stopped reply", event.Data)
+		case "stream.done":
+			done = event
+		case "error":
+			t.Errorf("unexpected error event %v", event.Data)
+		}
+	}
+
+	select {
+	case <-provider.cancelled:
+	case <-time.After(time.Second):
+		t.Error("the coach stream was not cancelled")
+	}
+	if final.Data["text"] != partial || final.Data["stopped"] != true {
+		t.Errorf("message.final = %v, want the partial reply marked stopped", final.Data)
+	}
+	if done.Data["status"] != "stopped" {
+		t.Errorf("stream.done = %v, want stopped", done.Data)
+	}
+
+	docs, err := fs.DB.Collection("sessions").Doc("s1").Collection("messages").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Data()["role"] != "assistant" || docs[0].Data()["content_text"] != partial {
+		t.Errorf("saved messages = %d, want the partial assistant reply", len(docs))
+	}
+	for _, call := range provider.Calls() {
+		if strings.HasPrefix(call.SystemPrompt, "Extract structured data") {
+			t.Error("planner ran on a stopped reply")
+		}
+	}
+}
+
+func TestSaveAssistantMessageRedacts(t *testing.T) {
+	optOut := &models.CoachSpec{}
+	optOut.Policies.Privacy.StoreSensitiveMemory = true
+	tests := []struct {
+		name string
+		spec *models.CoachSpec
+		want string
+	}{
+		{"redacted", nil, "Noted, your ssn [REDACTED] is saved."},
+		{"opted out", optOut, "Noted, your ssn 123-45-6789 is saved."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fs := firestoretest.New(t)
+			if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1"}); err != nil {
+				t.Fatal(err)
+			}
+			pipeline := NewPipeline(fs, geminitest.NewFakeProvider(), config.Config{})
+
+			if err := pipeline.saveAssistantMessage(ctx, "s1", "Noted, your ssn 123-45-6789 is saved.", tt.spec); err != nil {
+				t.Fatal(err)
+			}
+			docs, err := fs.DB.Collection("sessions").Doc("s1").Collection("messages").Documents(ctx).GetAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(docs) != 1 {
+				t.Fatalf("stored %d messages, want 1", len(docs))
+			}
+			var stored models.Message
+			if err := docs[0].DataTo(&stored); err != nil {
+				t.Fatal(err)
+			}
+			if stored.ContentText != tt.want {
+				t.Errorf("stored message = %q, want %q", stored.ContentText, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineSelfHarmEscalates(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.DB.Collection("sessions").Doc("s1").Set(ctx, models.Session{ID: "s1", UID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	spec := &models.CoachSpec{Identity: models.Identity{Name: "Pace", Niche: "running"}}
+	spec.Policies.Refusals.SelfHarm = "escalate_support"
+	if _, err := fs.DB.Collection("coaches").Doc("pace").Set(ctx, models.Coach{ID: "pace", Visibility: "public", CoachSpec: spec}); err != nil {
+		t.Fatal(err)
+	}
+	provider := geminitest.NewFakeProvider(
+		geminitest.Script{Prefix: "Classify the user's intent", Text: `{"route": "quick_answer", "confidence": 0.9}`},
+		geminitest.Script{Prefix: "You are Pace, a running coach.", Text: "Let's plan your long run. "},
+	)
+
+	out, err := NewPipeline(fs, provider, config.Config{}).Execute(ctx, PipelineInput{
+		SessionID:   "s1",
+		CoachID:     "pace",
+		UID:         "u1",
+		UserMessage: "I want to die, nothing helps",
+		Locale:      "en-GB,en;q=0.9",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	var escalation, doneEvent SSEEvent
+	for event := range out.Stream {
+		types = append(types, event.Type)
+		switch event.Type {
+		case "safety.escalation":
+			escalation = event
+		case "stream.done":
+			doneEvent = event
+		case "stream.open", "message.delta", "message.final":
+			t.Errorf("coaching output %s after a self-harm message", event.Type)
+		}
+	}
+	if tail := types[max(len(types)-3, 0):]; !slices.Equal(tail, []string{"safety.escalation", "usage", "stream.done"}) {
+		t.Errorf("events = %v, want the turn to end right after the escalation", types)
+	}
+
+	if escalation.Data["region"] != "GB" {
+		t.Errorf("escalation region = %v, want GB", escalation.Data["region"])
+	}
+	resources, _ := escalation.Data["resources"].([]safety.CrisisResource)
+	if len(resources) == 0 || resources[0].Name != "Samaritans" {
+		t.Errorf("escalation resources = %+v, want the UK helpline", escalation.Data["resources"])
+	}
+	message, _ := escalation.Data["message"].(string)
+	if message == "" {
+		t.Error("escalation has no support message")
+	}
+	if doneEvent.Data["status"] != "escalated" {
+		t.Errorf("stream.done status = %v, want escalated", doneEvent.Data["status"])
+	}
+	for _, call := range provider.Calls() {
+		if strings.HasPrefix(call.SystemPrompt, "You are Pace") {
+			t.Error("the coach was asked to reply")
+		}
+	}
+
+	docs, err := fs.DB.Collection("sessions").Doc("s1").Collection("messages").Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 || docs[0].Data()["content_text"] != message {
+		t.Errorf("stored %d messages, want only the support message", len(docs))
+	}
+}
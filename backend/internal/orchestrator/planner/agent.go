@@ -2,10 +2,10 @@ package planner
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"simon-backend/internal/gemini"
+	"simon-backend/internal/jsonutil"
 	"simon-backend/internal/models"
 	"simon-backend/internal/orchestrator/coach"
 )
@@ -19,11 +19,11 @@ type PlannerOutput struct {
 
 // PlannerAgent extracts structured data from coaching responses
 type PlannerAgent struct {
-	geminiClient *gemini.Client
+	geminiClient gemini.Provider
 }
 
 // NewPlannerAgent creates a new planner agent
-func NewPlannerAgent(gm *gemini.Client) *PlannerAgent {
+func NewPlannerAgent(gm gemini.Provider) *PlannerAgent {
 	return &PlannerAgent{
 		geminiClient: gm,
 	}
@@ -46,7 +46,7 @@ func (pa *PlannerAgent) Generate(
 
 	// Parse JSON response
 	var output PlannerOutput
-	if err := json.Unmarshal([]byte(response), &output); err != nil {
+	if err := jsonutil.ParseResponse(response, &output); err != nil {
 		// Try to extract individual components
 		output = pa.fallbackExtraction(response)
 	}
@@ -153,8 +153,10 @@ func (pa *PlannerAgent) validateNextActions(actions []models.NextAction, spec *m
 			actions[i].Energy = "medium"
 		}
 
-		// Ensure when.kind is valid
-		if actions[i].When.Kind != "now" && actions[i].When.Kind != "today_window" && actions[i].When.Kind != "schedule_exact" {
+		// Ensure when.kind is valid; the model often omits when altogether
+		if actions[i].When == nil {
+			actions[i].When = &models.When{Kind: "now"}
+		} else if actions[i].When.Kind != "now" && actions[i].When.Kind != "today_window" && actions[i].When.Kind != "schedule_exact" {
 			actions[i].When.Kind = "now"
 		}
 	}
@@ -197,7 +199,7 @@ Max 7 actions. If none found, return empty array [].`, coachText)
 	}
 
 	var actions []models.NextAction
-	if err := json.Unmarshal([]byte(response), &actions); err != nil {
+	if err := jsonutil.ParseResponse(response, &actions); err != nil {
 		return []models.NextAction{}, nil
 	}
 
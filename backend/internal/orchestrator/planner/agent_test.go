@@ -0,0 +1,48 @@
+package planner
+
+import (
+	"context"
+	"testing"
+
+	"simon-backend/internal/gemini/geminitest"
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/coach"
+)
+
+func TestExtractNextActionsParsesWrappedJSON(t *testing.T) {
+	actions := `[{"id": "a1", "title": "Book a physio slot", "duration_min": 10, "energy": "low", "when": {"kind": "today_window"}}]`
+	for name, response := range map[string]string{
+		"unfenced": actions,
+		"fenced":   "```json\n" + actions + "\n```",
+	} {
+		t.Run(name, func(t *testing.T) {
+			agent := NewPlannerAgent(geminitest.NewFakeProvider(geminitest.Script{Prefix: "Extract next actions", Text: response}))
+			got, err := agent.ExtractNextActions(context.Background(), "Book the physio today.")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || got[0].Title != "Book a physio slot" || got[0].DurationMin != 10 {
+				t.Errorf("actions = %+v", got)
+			}
+		})
+	}
+}
+
+func TestGenerateFillsActionDefaults(t *testing.T) {
+	agent := NewPlannerAgent(geminitest.NewFakeProvider(geminitest.Script{
+		Prefix: "Extract structured data from this coaching response.",
+		Text:   `{"NextActions": [{"title": "Write three pages"}, {"title": "Walk", "energy": "extreme", "when": {"kind": "someday"}}]}`,
+	}))
+	out, err := agent.Generate(context.Background(), &coach.CoachOutput{MessageText: "Write three pages, then walk."}, &models.CoachSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.NextActions) != 2 {
+		t.Fatalf("actions = %+v", out.NextActions)
+	}
+	for i, action := range out.NextActions {
+		if action.ID == "" || action.Energy != "medium" || action.When == nil || action.When.Kind != "now" {
+			t.Errorf("action %d = %+v (when %+v), want defaults filled in", i, action, action.When)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package router
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"strings"
 
 	"simon-backend/internal/gemini"
+	"simon-backend/internal/jsonutil"
 )
 
 // Route represents the classified routing decision
@@ -20,23 +22,29 @@ type Route struct {
 
 // RouterAgent classifies user intent and determines routing
 type RouterAgent struct {
-	geminiClient *gemini.Client
+	geminiClient gemini.Provider
 }
 
 // NewRouterAgent creates a new router agent
-func NewRouterAgent(gm *gemini.Client) *RouterAgent {
+func NewRouterAgent(gm gemini.Provider) *RouterAgent {
 	return &RouterAgent{
 		geminiClient: gm,
 	}
 }
 
-// Classify analyzes the user message and returns routing decision
+// Classify analyzes the user message and returns routing decision. Transient Gemini errors are
+// retried; once retries run out (or the reply can't be parsed) the default route is used.
+// Blocked content and cancellation are returned as errors.
 func (r *RouterAgent) Classify(ctx context.Context, userMessage string, uid string) (*Route, error) {
 	prompt := r.buildClassificationPrompt(userMessage)
 
-	response, err := r.geminiClient.GenerateContent(ctx, prompt, "")
+	response, err := gemini.RetryGenerateContent(ctx, r.geminiClient, prompt, "")
 	if err != nil {
-		return nil, fmt.Errorf("gemini classification failed: %w", err)
+		if errors.Is(err, gemini.ErrContentBlocked) || ctx.Err() != nil {
+			return nil, fmt.Errorf("gemini classification failed: %w", err)
+		}
+		log.Printf("Router classification failed, using default route: %v", err)
+		return r.getDefaultRoute(), nil
 	}
 
 	// Parse JSON response
@@ -46,8 +54,9 @@ func (r *RouterAgent) Classify(ctx context.Context, userMessage string, uid stri
 		NeedsPlanner bool    `json:"needs_planner"`
 	}
 
-	if err := json.Unmarshal([]byte(response), &rawRoute); err != nil {
+	if err := jsonutil.ParseResponse(response, &rawRoute); err != nil {
 		// Fallback to default route
+		log.Printf("Router returned unparseable classification, using default route: %v", err)
 		return r.getDefaultRoute(), nil
 	}
 
@@ -0,0 +1,39 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"simon-backend/internal/gemini/geminitest"
+)
+
+func TestClassifyParsesWrappedJSON(t *testing.T) {
+	tests := map[string]string{
+		"clean":  `{"route": "make_a_system", "confidence": 0.9, "needs_planner": true}`,
+		"fenced": "```json\n{\"route\": \"make_a_system\", \"confidence\": 0.9, \"needs_planner\": true}\n```",
+		"prose":  "Here is the classification:\n{\"route\": \"make_a_system\", \"confidence\": 0.9, \"needs_planner\": true}",
+	}
+	for name, response := range tests {
+		t.Run(name, func(t *testing.T) {
+			agent := NewRouterAgent(geminitest.NewFakeProvider(geminitest.Script{Prefix: "Classify the user's intent", Text: response}))
+			route, err := agent.Classify(context.Background(), "help me build a morning routine", "u1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if route.Name != "make_a_system" || route.Confidence != 0.9 || !route.NeedsPlanner {
+				t.Errorf("route = %+v, want the model's classification rather than the default", route)
+			}
+		})
+	}
+}
+
+func TestClassifyFallsBackOnProse(t *testing.T) {
+	agent := NewRouterAgent(geminitest.NewFakeProvider(geminitest.Script{Prefix: "Classify the user's intent", Text: "This sounds like a deep session."}))
+	route, err := agent.Classify(context.Background(), "I feel stuck", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if route.Name != "quick_nudge" {
+		t.Errorf("route = %q, want the default route", route.Name)
+	}
+}
@@ -0,0 +1,45 @@
+package safety
+
+import (
+	"simon-backend/internal/orchestrator/coach"
+	"simon-backend/internal/tools"
+)
+
+// ConfidencePolicy sets how sure the coach must be before a server tool runs without asking
+type ConfidencePolicy struct {
+	Default float64
+	PerTool map[string]float64
+}
+
+// Threshold returns the minimum auto-run confidence for toolID
+func (p ConfidencePolicy) Threshold(toolID string) float64 {
+	if threshold, ok := p.PerTool[toolID]; ok {
+		return threshold
+	}
+	return p.Default
+}
+
+// GateToolConfidence marks server tool proposals below their confidence threshold as needing
+// confirmation, so weak or ambiguous intents are asked about rather than auto-executed.
+// Requests without their own confidence use fallback (the router's confidence in the turn).
+// Read-only tools always run.
+func (sf *SafetyFilter) GateToolConfidence(requests []coach.ToolRequest, fallback float64) []coach.ToolRequest {
+	gated := make([]coach.ToolRequest, len(requests))
+	for i, req := range requests {
+		gated[i] = req
+
+		tool, err := sf.registry.GetTool(req.Tool)
+		if err != nil || tool.Category != tools.ToolCategoryServer || tool.ReadOnly || req.RequiresConfirmation {
+			continue
+		}
+
+		confidence := req.Confidence
+		if confidence <= 0 {
+			confidence = fallback
+		}
+		if confidence < sf.confidence.Threshold(req.Tool) {
+			gated[i].RequiresConfirmation = true
+		}
+	}
+	return gated
+}
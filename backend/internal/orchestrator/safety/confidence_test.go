@@ -0,0 +1,36 @@
+package safety
+
+import (
+	"testing"
+
+	"simon-backend/internal/orchestrator/coach"
+)
+
+func TestGateToolConfidence(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{Default: 0.7, PerTool: map[string]float64{"checkin_schedule": 0.9}}, nil)
+
+	tests := []struct {
+		name     string
+		req      coach.ToolRequest
+		fallback float64
+		want     bool
+	}{
+		{"own confidence above threshold runs", coach.ToolRequest{Tool: "plan_create", Confidence: 0.8}, 0.1, false},
+		{"own confidence below threshold asks", coach.ToolRequest{Tool: "plan_create", Confidence: 0.5}, 0.99, true},
+		{"unreported confidence uses the route's", coach.ToolRequest{Tool: "plan_create"}, 0.75, false},
+		{"unreported and weak route asks", coach.ToolRequest{Tool: "plan_create"}, 0.4, true},
+		{"per-tool threshold", coach.ToolRequest{Tool: "checkin_schedule", Confidence: 0.85}, 1, true},
+		{"read-only tools always run", coach.ToolRequest{Tool: "plan_list_active", Confidence: 0.1}, 0, false},
+		{"client tools are left alone", coach.ToolRequest{Tool: "share_sheet_export", Confidence: 0.1}, 0, false},
+		{"already confirming stays confirming", coach.ToolRequest{Tool: "plan_create", Confidence: 1, RequiresConfirmation: true}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gated := sf.GateToolConfidence([]coach.ToolRequest{tt.req}, tt.fallback)
+			if gated[0].RequiresConfirmation != tt.want {
+				t.Errorf("RequiresConfirmation = %v, want %v", gated[0].RequiresConfirmation, tt.want)
+			}
+		})
+	}
+}
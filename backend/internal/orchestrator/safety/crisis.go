@@ -0,0 +1,104 @@
+package safety
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// CrisisResource is a hotline or service offered when a user may be at risk of self-harm
+type CrisisResource struct {
+	Name  string `json:"name"`
+	Phone string `json:"phone,omitempty"`
+	// Text is a number that accepts text messages
+	Text string `json:"text,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// CrisisDirectory maps ISO 3166 region codes (e.g. "US") to that region's crisis resources.
+// The "default" entry covers every region without its own.
+type CrisisDirectory map[string][]CrisisResource
+
+// crisisDefaultRegion keys the resources used when no region matches
+const crisisDefaultRegion = "default"
+
+// defaultCrisisDirectory is used unless CRISIS_RESOURCES_FILE replaces it
+var defaultCrisisDirectory = CrisisDirectory{
+	crisisDefaultRegion: {
+		{Name: "Find A Helpline", URL: "https://findahelpline.com"},
+		{Name: "Your local emergency number"},
+	},
+	"US": {{Name: "988 Suicide & Crisis Lifeline", Phone: "988", Text: "988", URL: "https://988lifeline.org"}},
+	"CA": {{Name: "9-8-8 Suicide Crisis Helpline", Phone: "988", Text: "988", URL: "https://988.ca"}},
+	"GB": {{Name: "Samaritans", Phone: "116 123", URL: "https://www.samaritans.org"}},
+	"IE": {{Name: "Samaritans", Phone: "116 123", URL: "https://www.samaritans.org"}},
+	"AU": {{Name: "Lifeline", Phone: "13 11 14", URL: "https://www.lifeline.org.au"}},
+	"DE": {{Name: "TelefonSeelsorge", Phone: "0800 111 0 111", URL: "https://www.telefonseelsorge.de"}},
+	"FR": {{Name: "3114 Numéro national de prévention du suicide", Phone: "3114", URL: "https://3114.fr"}},
+	"ES": {{Name: "Línea 024 de atención a la conducta suicida", Phone: "024"}},
+	"TR": {{Name: "Acil Çağrı Merkezi", Phone: "112"}},
+}
+
+var (
+	crisisMu          sync.Mutex
+	crisisDirectories = map[string]CrisisDirectory{}
+)
+
+// LoadCrisisDirectory returns the crisis resources in the JSON file at path, or the built-in
+// directory when path is empty. Files are read once and cached; a file without a "default"
+// entry keeps the built-in one.
+func LoadCrisisDirectory(path string) (CrisisDirectory, error) {
+	if path == "" {
+		return defaultCrisisDirectory, nil
+	}
+
+	crisisMu.Lock()
+	defer crisisMu.Unlock()
+
+	if directory, ok := crisisDirectories[path]; ok {
+		return directory, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read crisis resources: %w", err)
+	}
+	var directory CrisisDirectory
+	if err := json.Unmarshal(data, &directory); err != nil {
+		return nil, fmt.Errorf("failed to parse crisis resources %s: %w", path, err)
+	}
+	if len(directory[crisisDefaultRegion]) == 0 {
+		directory[crisisDefaultRegion] = defaultCrisisDirectory[crisisDefaultRegion]
+	}
+
+	crisisDirectories[path] = directory
+	return directory, nil
+}
+
+// Lookup picks the resources for the user's region: the first region named explicitly by locale
+// (an Accept-Language value such as "tr-TR,tr;q=0.9") or, failing that, by one of the coach's
+// languages ("pt-BR"). A bare language never picks a region, since "en" or "es" says nothing about
+// where the user is; without an explicit region the international "default" resources are used.
+func (d CrisisDirectory) Lookup(locale string, languages []string) (string, []CrisisResource) {
+	tags, _, _ := language.ParseAcceptLanguage(locale)
+	for _, lang := range languages {
+		if tag, err := language.Parse(lang); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+
+	for _, tag := range tags {
+		region, confidence := tag.Region()
+		if confidence != language.Exact {
+			continue
+		}
+		if resources := d[strings.ToUpper(region.String())]; len(resources) > 0 {
+			return region.String(), resources
+		}
+	}
+	return crisisDefaultRegion, d[crisisDefaultRegion]
+}
@@ -0,0 +1,57 @@
+package safety
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCrisisDirectoryLookup(t *testing.T) {
+	tests := []struct {
+		name      string
+		locale    string
+		languages []string
+		want      string
+	}{
+		{"explicit region", "tr-TR,tr;q=0.9,en;q=0.8", nil, "TR"},
+		{"region from a later preference", "fr,en-GB;q=0.8", nil, "GB"},
+		{"lowercase region", "en-au", nil, "AU"},
+		{"bare english", "en", nil, "default"},
+		{"bare spanish", "es", nil, "default"},
+		{"region without resources", "pt-BR,pt;q=0.9", nil, "default"},
+		{"coach language names a region", "", []string{"de", "en-CA"}, "CA"},
+		{"locale region wins over the coach's", "en-IE", []string{"en-US"}, "IE"},
+		{"bare coach languages", "", []string{"es", "tr"}, "default"},
+		{"no locale", "", nil, "default"},
+		{"garbage locale", "!!", nil, "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			region, resources := defaultCrisisDirectory.Lookup(tt.locale, tt.languages)
+			if region != tt.want {
+				t.Errorf("Lookup(%q, %v) region = %q, want %q", tt.locale, tt.languages, region, tt.want)
+			}
+			if len(resources) == 0 {
+				t.Error("no resources")
+			}
+		})
+	}
+}
+
+func TestLoadCrisisDirectoryKeepsDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crisis.json")
+	if err := os.WriteFile(path, []byte(`{"NZ": [{"name": "Need to talk?", "phone": "1737", "text": "1737"}]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	directory, err := LoadCrisisDirectory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if region, resources := directory.Lookup("en-NZ", nil); region != "NZ" || resources[0].Phone != "1737" {
+		t.Errorf("NZ lookup = %q, %+v", region, resources)
+	}
+	if region, resources := directory.Lookup("en-US", nil); region != "default" || len(resources) == 0 {
+		t.Errorf("a region missing from the file = %q, %+v; want the built-in default", region, resources)
+	}
+}
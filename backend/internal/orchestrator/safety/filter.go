@@ -3,140 +3,111 @@ package safety
 import (
 	"context"
 	"fmt"
-	"regexp"
 	"strings"
 
 	"simon-backend/internal/models"
 	"simon-backend/internal/orchestrator/coach"
+	"simon-backend/internal/redact"
+	"simon-backend/internal/textutil"
+	"simon-backend/internal/tools"
 )
 
 // SafetyFilter enforces policy boundaries and safety constraints
 type SafetyFilter struct {
-	sensitivePatterns []*regexp.Regexp
+	registry   *tools.Registry
+	confidence ConfidencePolicy
+	crisis     CrisisDirectory
 }
 
-// NewSafetyFilter creates a new safety filter
-func NewSafetyFilter() *SafetyFilter {
-	// Compile sensitive data patterns
-	patterns := []*regexp.Regexp{
-		regexp.MustCompile(`(?i)password[:\s]+\S+`),
-		regexp.MustCompile(`(?i)api[_\s]?key[:\s]+\S+`),
-		regexp.MustCompile(`\b\d{4}[\s-]?\d{4}[\s-]?\d{4}[\s-]?\d{4}\b`), // Credit card
-		regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),                        // SSN
-		regexp.MustCompile(`(?i)secret[:\s]+\S+`),
-		regexp.MustCompile(`(?i)token[:\s]+\S+`),
-	}
+// RefusalError is returned by Validate when a reply crosses one of the coach's refusal policies
+type RefusalError struct {
+	Kind    string
+	Message string
+}
+
+func (e *RefusalError) Error() string {
+	return e.Message
+}
 
+// NewSafetyFilter creates a new safety filter; crisis supplies the resources for self-harm escalation
+func NewSafetyFilter(confidence ConfidencePolicy, crisis CrisisDirectory) *SafetyFilter {
 	return &SafetyFilter{
-		sensitivePatterns: patterns,
+		registry:   tools.NewRegistry(),
+		confidence: confidence,
+		crisis:     crisis,
 	}
 }
 
-// Validate checks if the coach output violates any policies
+// Validate checks if the coach output violates any policies. lang is the conversation's active
+// language (an ISO 639 code or Accept-Language value); refusal keywords are matched in it too.
 func (sf *SafetyFilter) Validate(
 	ctx context.Context,
 	output *coach.CoachOutput,
 	spec *models.CoachSpec,
+	lang string,
 ) error {
 	// Check refusal policies
-	if err := sf.checkRefusalPolicies(output.MessageText, spec); err != nil {
+	if err := sf.checkRefusalPolicies(output.MessageText, spec, lang); err != nil {
 		return err
 	}
 
-	// Check privacy rules
-	if err := sf.checkPrivacyRules(output.MessageText, spec); err != nil {
-		return err
-	}
-
-	// Check tool consent requirements
+	// Check tool consent requirements. Sensitive data, the coach's redact patterns included, is
+	// redacted when the reply is stored rather than refused here.
 	if err := sf.checkToolConsent(output.ToolRequests, spec); err != nil {
 		return err
 	}
 
-	// Check for sensitive data
-	if sf.containsSensitiveData(output.MessageText) {
-		return fmt.Errorf("Response contains sensitive data and cannot be stored")
-	}
-
 	return nil
 }
 
-// checkRefusalPolicies enforces refusal boundaries
-func (sf *SafetyFilter) checkRefusalPolicies(text string, spec *models.CoachSpec) error {
-	lowerText := strings.ToLower(text)
-
-	// Medical advice check
-	if spec.Policies.Refusals.Medical {
-		medicalKeywords := []string{
-			"diagnose", "diagnosis", "prescribe", "prescription",
-			"medication", "treatment", "cure", "disease",
-			"symptom", "medical condition", "doctor should",
-		}
+// checkRefusalPolicies enforces refusal boundaries, matching the rules of every language in play
+// at the coach's sensitivity
+func (sf *SafetyFilter) checkRefusalPolicies(text string, spec *models.CoachSpec, lang string) error {
+	folded := textutil.FoldForSearch(text)
+	languages := keywordLanguages(lang, spec)
 
-		for _, keyword := range medicalKeywords {
-			if strings.Contains(lowerText, keyword) {
-				return fmt.Errorf("I can't provide medical advice. Please consult a healthcare professional")
-			}
-		}
+	checks := []struct {
+		kind    string
+		enabled bool
+	}{
+		{RefusalMedical, spec.Policies.Refusals.Medical},
+		{RefusalLegal, spec.Policies.Refusals.Legal},
+		{RefusalFinancial, spec.Policies.Refusals.FinancialAdvice == "none"},
+		{RefusalSelfHarm, spec.Policies.Refusals.SelfHarm == "escalate_support"},
 	}
-
-	// Legal advice check
-	if spec.Policies.Refusals.Legal {
-		legalKeywords := []string{
-			"legal advice", "lawsuit", "sue", "attorney",
-			"lawyer", "court", "legal rights", "contract law",
-		}
-
-		for _, keyword := range legalKeywords {
-			if strings.Contains(lowerText, keyword) {
-				return fmt.Errorf("I can't provide legal advice. Please consult a lawyer")
-			}
-		}
-	}
-
-	// Financial advice check
-	if spec.Policies.Refusals.FinancialAdvice == "none" {
-		financialKeywords := []string{
-			"invest in", "stock pick", "buy stock", "sell stock",
-			"financial advice", "portfolio", "trading",
-		}
-
-		for _, keyword := range financialKeywords {
-			if strings.Contains(lowerText, keyword) {
-				return fmt.Errorf("I can't provide financial advice. Please consult a financial advisor")
-			}
+	for _, check := range checks {
+		if check.enabled && matchesKeywords(folded, check.kind, languages, spec.Policies.Refusals.Sensitivity) {
+			return &RefusalError{Kind: check.kind, Message: refusalMessage(check.kind, spec)}
 		}
 	}
 
-	// Self-harm check
-	if spec.Policies.Refusals.SelfHarm == "escalate_support" {
-		harmKeywords := []string{
-			"kill myself", "end my life", "suicide", "self-harm",
-			"hurt myself", "want to die",
-		}
+	return nil
+}
 
-		for _, keyword := range harmKeywords {
-			if strings.Contains(lowerText, keyword) {
-				return fmt.Errorf("I'm concerned about your safety. Please reach out to a crisis helpline or mental health professional immediately")
-			}
-		}
+// NeedsEscalation reports whether text mentions self-harm, in English or the active language
+// (lang) or one of the coach's languages, and the coach escalates it to support
+func (sf *SafetyFilter) NeedsEscalation(text string, spec *models.CoachSpec, lang string) bool {
+	if spec.Policies.Refusals.SelfHarm != "escalate_support" {
+		return false
 	}
+	return matchesKeywords(textutil.FoldForSearch(text), RefusalSelfHarm, keywordLanguages(lang, spec), spec.Policies.Refusals.Sensitivity)
+}
 
-	return nil
+// Escalation is the support offered instead of coaching when a user may be at risk
+type Escalation struct {
+	Message   string
+	Region    string
+	Resources []CrisisResource
 }
 
-// checkPrivacyRules enforces privacy constraints
-func (sf *SafetyFilter) checkPrivacyRules(text string, spec *models.CoachSpec) error {
-	if !spec.Policies.Privacy.StoreSensitiveMemory {
-		// Check for redact patterns
-		for _, pattern := range spec.Policies.Privacy.RedactPatterns {
-			if strings.Contains(strings.ToLower(text), strings.ToLower(pattern)) {
-				return fmt.Errorf("Response contains sensitive pattern '%s' and cannot be stored", pattern)
-			}
-		}
+// Escalation builds the support message and the crisis resources for the user's locale
+func (sf *SafetyFilter) Escalation(spec *models.CoachSpec, locale string) Escalation {
+	region, resources := sf.crisis.Lookup(locale, spec.Identity.Languages)
+	return Escalation{
+		Message:   refusalMessage(RefusalSelfHarm, spec),
+		Region:    region,
+		Resources: resources,
 	}
-
-	return nil
 }
 
 // checkToolConsent ensures client tools require confirmation
@@ -165,16 +136,6 @@ func (sf *SafetyFilter) checkToolConsent(requests []coach.ToolRequest, spec *mod
 	return nil
 }
 
-// containsSensitiveData checks for sensitive patterns
-func (sf *SafetyFilter) containsSensitiveData(text string) bool {
-	for _, pattern := range sf.sensitivePatterns {
-		if pattern.MatchString(text) {
-			return true
-		}
-	}
-	return false
-}
-
 // isToolAllowed checks if a tool is in the allowed list
 func (sf *SafetyFilter) isToolAllowed(tool string, spec *models.CoachSpec) bool {
 	allTools := append(spec.ToolsAllowed.ClientTools, spec.ToolsAllowed.ServerTools...)
@@ -186,20 +147,16 @@ func (sf *SafetyFilter) isToolAllowed(tool string, spec *models.CoachSpec) bool
 	return false
 }
 
-// RedactSensitiveData removes sensitive information from text
-func (sf *SafetyFilter) RedactSensitiveData(text string) string {
-	redacted := text
-
-	for _, pattern := range sf.sensitivePatterns {
-		redacted = pattern.ReplaceAllString(redacted, "[REDACTED]")
-	}
-
+// RedactSensitiveData removes sensitive information from text, using the built-in patterns and
+// the coach's own
+func (sf *SafetyFilter) RedactSensitiveData(text string, spec *models.CoachSpec) string {
+	redacted, _ := redact.Text(text, spec.Policies.Privacy.RedactPatterns)
 	return redacted
 }
 
 // ValidateMemoryWrite checks if memory write is safe
-func (sf *SafetyFilter) ValidateMemoryWrite(content string) error {
-	if sf.containsSensitiveData(content) {
+func (sf *SafetyFilter) ValidateMemoryWrite(content string, spec *models.CoachSpec) error {
+	if redact.Contains(content, spec.Policies.Privacy.RedactPatterns) {
 		return fmt.Errorf("Memory write contains sensitive data")
 	}
 
@@ -0,0 +1,104 @@
+package safety
+
+import (
+	"regexp"
+	"strings"
+
+	"simon-backend/internal/models"
+)
+
+// Formatting rules a reply can break
+const (
+	RuleMaxBullets               = "max_bullets"
+	RuleMaxSentencesPerParagraph = "max_sentences_per_paragraph"
+)
+
+var (
+	bulletLine  = regexp.MustCompile(`^\s*(?:[-*•]|\d+[.)])\s+\S`)
+	headingLine = regexp.MustCompile(`^\s*#{1,6}\s`)
+	// sentenceEnd matches terminal punctuation, with any closing quotes or brackets, followed by
+	// whitespace or the end of the paragraph
+	sentenceEnd = regexp.MustCompile(`[.!?…]+["'”’)\]]*(?:\s+|$)`)
+)
+
+// abbreviations end in a period without ending the sentence (compared lowercased, without the
+// final period). Ones that often close a sentence too, like "etc." or "a.m.", aren't listed.
+var abbreviations = map[string]bool{
+	"dr": true, "mr": true, "mrs": true, "ms": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "vs": true, "cf": true, "e.g": true, "i.e": true,
+}
+
+// FormattingViolation is one formatting limit a reply exceeded
+type FormattingViolation struct {
+	Rule  string
+	Count int
+	Limit int
+}
+
+// CheckFormatting counts the bullets and the sentences in each paragraph of text against the
+// spec's formatting limits; a limit of 0 is unset. Bullet items don't count as paragraphs.
+func (sf *SafetyFilter) CheckFormatting(text string, formatting models.Formatting) []FormattingViolation {
+	bullets := 0
+	longest := 0
+	var paragraph []string
+
+	endParagraph := func() {
+		if n := countSentences(strings.Join(paragraph, " ")); n > longest {
+			longest = n
+		}
+		paragraph = paragraph[:0]
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case bulletLine.MatchString(line):
+			bullets++
+			endParagraph()
+		case strings.TrimSpace(line) == "" || headingLine.MatchString(line):
+			endParagraph()
+		default:
+			paragraph = append(paragraph, strings.TrimSpace(line))
+		}
+	}
+	endParagraph()
+
+	var violations []FormattingViolation
+	if formatting.MaxBullets > 0 && bullets > formatting.MaxBullets {
+		violations = append(violations, FormattingViolation{Rule: RuleMaxBullets, Count: bullets, Limit: formatting.MaxBullets})
+	}
+	if formatting.MaxSentencesPerParagraph > 0 && longest > formatting.MaxSentencesPerParagraph {
+		violations = append(violations, FormattingViolation{Rule: RuleMaxSentencesPerParagraph, Count: longest, Limit: formatting.MaxSentencesPerParagraph})
+	}
+	return violations
+}
+
+// countSentences counts the sentences in a paragraph; trailing text without terminal
+// punctuation counts as one more. A period after an abbreviation ("Dr.", "e.g.") doesn't end one.
+func countSentences(paragraph string) int {
+	paragraph = strings.TrimSpace(paragraph)
+	if paragraph == "" {
+		return 0
+	}
+	count, last := 0, 0
+	for _, end := range sentenceEnd.FindAllStringIndex(paragraph, -1) {
+		if paragraph[end[0]] == '.' && strings.TrimSpace(paragraph[end[0]+1:end[1]]) == "" && endsWithAbbreviation(paragraph[:end[0]]) {
+			continue
+		}
+		count++
+		last = end[1]
+	}
+	if count == 0 || last < len(paragraph) {
+		count++
+	}
+	return count
+}
+
+// endsWithAbbreviation reports whether text ends with a known abbreviation, its period trimmed
+func endsWithAbbreviation(text string) bool {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return false
+	}
+	word := strings.TrimLeft(fields[len(fields)-1], `("'“‘[`)
+	return abbreviations[strings.ToLower(word)]
+}
@@ -0,0 +1,71 @@
+package safety
+
+import (
+	"reflect"
+	"testing"
+
+	"simon-backend/internal/models"
+)
+
+func TestCountSentences(t *testing.T) {
+	tests := []struct {
+		name      string
+		paragraph string
+		want      int
+	}{
+		{"empty", "   ", 0},
+		{"one", "Run today.", 1},
+		{"no terminal punctuation", "Run today", 1},
+		{"trailing fragment", "Run today. Then rest", 2},
+		{"mixed punctuation", "Ready? Go! Breathe… Done.", 4},
+		{"closing quotes", `She said "stop." Then she left.`, 2},
+		{"closing bracket", "Rest (you earned it.) Then stretch.", 2},
+		{"ellipsis", "Wait... then go.", 2},
+		{"decimal", "Run 3.5 km today.", 1},
+		{"title", "Ask Dr. Lee about it. Then book a slot.", 2},
+		{"e.g.", "Pick a cue, e.g. your morning coffee. Attach the habit to it.", 2},
+		{"i.e. in brackets", "Go early (i.e. before work). It sticks better.", 2},
+		{"abbreviation ends the paragraph", "Book a session with Dr.", 1},
+		{"etc. still ends a sentence", "Pack shoes, water, etc. Then leave.", 2},
+		{"question after an abbreviation", "Did you ask Dr. Lee? Ask today.", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countSentences(tt.paragraph); got != tt.want {
+				t.Errorf("countSentences(%q) = %d, want %d", tt.paragraph, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckFormatting(t *testing.T) {
+	limits := models.Formatting{MaxBullets: 2, MaxSentencesPerParagraph: 2}
+	tests := []struct {
+		name       string
+		text       string
+		formatting models.Formatting
+		want       []FormattingViolation
+	}{
+		{"within limits", "Start small. Keep it daily.\n\n- Walk\n- Stretch", limits, nil},
+		{"too many bullets", "- Walk\n* Stretch\n1. Sleep\n2) Eat", limits, []FormattingViolation{{Rule: RuleMaxBullets, Count: 4, Limit: 2}}},
+		{"long paragraph", "One. Two. Three.\n\nFour.", limits, []FormattingViolation{{Rule: RuleMaxSentencesPerParagraph, Count: 3, Limit: 2}}},
+		{"paragraph wrapped over lines", "One.\nTwo.\nThree.", limits, []FormattingViolation{{Rule: RuleMaxSentencesPerParagraph, Count: 3, Limit: 2}}},
+		{"bullets split paragraphs", "One. Two.\n- Walk\nThree. Four.", limits, nil},
+		{"headings split paragraphs", "One. Two.\n## Next\nThree. Four.", limits, nil},
+		{"bullet text isn't a paragraph", "- One. Two. Three.", limits, nil},
+		{"abbreviations", "See Dr. Lee, e.g. on Monday. Then rest.", limits, nil},
+		{"both", "- a\n- b\n- c\n\nOne. Two. Three.", limits, []FormattingViolation{
+			{Rule: RuleMaxBullets, Count: 3, Limit: 2},
+			{Rule: RuleMaxSentencesPerParagraph, Count: 3, Limit: 2},
+		}},
+		{"unset limits", "- a\n- b\n- c\n\nOne. Two. Three.", models.Formatting{}, nil},
+	}
+	sf := &SafetyFilter{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sf.CheckFormatting(tt.text, tt.formatting); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckFormatting(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package safety
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/language"
+
+	"simon-backend/internal/models"
+	"simon-backend/internal/textutil"
+)
+
+// Refusal sensitivities (Policies.Refusals.Sensitivity)
+const (
+	// SensitivityStandard refuses only when the advice itself is asked for or given
+	SensitivityStandard = "standard"
+	// SensitivityStrict also refuses on bare topic words like "treatment" or "court"
+	SensitivityStrict = "strict"
+)
+
+// refusalRules are what trips one refusal policy in one language
+type refusalRules struct {
+	// patterns are regular expressions for asking for or giving the advice ("what medication
+	// should i", "you should sue"); they always apply
+	patterns []string
+	// terms are topic words that only trip the policy at strict sensitivity
+	terms []string
+}
+
+// refusalKeywords are the refusal rules by ISO 639-1 language, matched against folded
+// (lowercase, NFC) text. English is always checked; other sets are added for the user's and the
+// coach's languages. Self-harm phrases are patterns, so they apply at every sensitivity.
+// Patterns are folded before compiling, so they must not use uppercase escapes like \S or \W.
+var refusalKeywords = map[string]map[string]refusalRules{
+	"en": {
+		RefusalMedical: {
+			patterns: []string{
+				`\bdiagnos(e|is|ing) (me|my|you|your)\b`,
+				`\b(your|my) diagnosis (is|would be)\b`,
+				`\b(what|which) (medication|medicine|meds|drug|pills?|dose|dosage) (should|do|can) (i|you)\b`,
+				`\bshould i (take|stop taking|start taking|increase|decrease|lower) (my )?(medication|medicine|meds|pills|dose|dosage)\b`,
+				`\byou (should|need to|must|could) (take|start taking|stop taking) (\w+ )?(mg|medication|medicine|meds|pills|antidepressants|antibiotics)\b`,
+				`\b(i|we) (can|would|will) prescribe\b`,
+				`\byou (probably|likely|might|may) have (a |an )?(\w+ )?(disorder|disease|infection|syndrome)\b`,
+				`\bmedical (advice|diagnosis)\b`,
+			},
+			terms: []string{
+				"diagnose", "diagnosis", "prescribe", "prescription",
+				"medication", "treatment", "cure", "disease",
+				"symptom", "medical condition", "doctor should",
+			},
+		},
+		RefusalLegal: {
+			patterns: []string{
+				`\blegal advice\b`,
+				`\b(should|can|could) (i|you|we) sue\b`,
+				`\byou (should|could|can) sue\b`,
+				`\bfile (a )?lawsuit\b`,
+				`\b(my|your) legal rights\b`,
+				`\b(is|was) (it|this|that) (legal|illegal)\b`,
+				`\bplead (guilty|not guilty|no contest)\b`,
+				`\bbreach of contract\b`,
+			},
+			terms: []string{
+				"legal advice", "lawsuit", "sue", "attorney",
+				"lawyer", "court", "legal rights", "contract law",
+			},
+		},
+		RefusalFinancial: {
+			patterns: []string{
+				`\bfinancial advice\b`,
+				`\b(should i|you should|i recommend|i'd recommend) (buy|sell|short|invest in) (\w+ )?(stocks?|shares|crypto|bitcoin|options|bonds|etfs?|funds?)\b`,
+				`\b(should i|you should|i recommend) invest(ing)? (my|your) (savings|money|retirement)\b`,
+				`\bstock (picks?|tips?)\b`,
+				`\bguaranteed returns?\b`,
+			},
+			terms: []string{
+				"invest in", "stock pick", "buy stock", "sell stock",
+				"financial advice", "portfolio", "trading",
+			},
+		},
+		RefusalSelfHarm: {
+			patterns: []string{
+				"kill myself", "end my life", "suicide", "self-harm",
+				"hurt myself", "want to die",
+			},
+		},
+	},
+	"tr": {
+		RefusalMedical: {
+			patterns: []string{
+				`hangi (ilacı|ilaçları|hapı) (almalıyım|kullanmalıyım|içmeliyim)`,
+				`(bana|sana) (teşhis|tanı) koy`,
+				`ilaç (önerir misin|önerebilir misin|öner)`,
+				`dozu(mu|nu)? (artırmalı|azaltmalı)`,
+				`tıbbi tavsiye`,
+			},
+			terms: []string{
+				"teşhis", "tanı koy", "reçete", "ilaç",
+				"tedavi", "hastalık", "belirti", "semptom",
+			},
+		},
+		RefusalLegal: {
+			patterns: []string{
+				`hukuki tavsiye`,
+				`dava (açmalı|açabilir|açmam|açmalısın)`,
+				`yasal haklarım`,
+			},
+			terms: []string{
+				"hukuki tavsiye", "dava", "avukat", "mahkeme", "yasal hak",
+			},
+		},
+		RefusalFinancial: {
+			patterns: []string{
+				`finansal tavsiye`,
+				`yatırım tavsiyesi`,
+				`hangi hisse(yi|leri)? (almalıyım|alayım|satmalıyım)`,
+				`(hisse|kripto|bitcoin|altın) (almalı|satmalı)`,
+			},
+			terms: []string{
+				"yatırım yap", "hisse al", "hisse sat", "finansal tavsiye", "portföy",
+			},
+		},
+		RefusalSelfHarm: {
+			patterns: []string{
+				"kendimi öldür", "intihar", "canıma kıy", "hayatıma son",
+				"ölmek istiyorum", "kendime zarar",
+			},
+		},
+	},
+	"es": {
+		RefusalMedical: {
+			patterns: []string{
+				`(qué|que|cuál|cual) (medicamento|medicina|pastilla|dosis)s? (debo|debería|tengo que) tomar`,
+				`diagnosticarme`,
+				`mi diagnóstico (es|sería)`,
+				`(debes|deberías|tienes que) tomar ([^ ]+ )?(mg|medicamentos?|pastillas|antidepresivos|antibióticos)`,
+				`(consejo|asesoría) médic[oa]`,
+			},
+			terms: []string{
+				"diagnosticar", "diagnóstico", "recetar", "receta médica",
+				"medicamento", "tratamiento", "enfermedad", "síntoma",
+			},
+		},
+		RefusalLegal: {
+			patterns: []string{
+				`(asesoría|consejo) legal`,
+				`(debo|debería|puedo) demandar`,
+				`(deberías|puedes) demandar`,
+				`mis derechos legales`,
+			},
+			terms: []string{
+				"asesoría legal", "consejo legal", "demanda", "abogado",
+				"tribunal", "derechos legales",
+			},
+		},
+		RefusalFinancial: {
+			patterns: []string{
+				`(asesoría|consejo) financier[oa]`,
+				`(debo|debería|deberías) (comprar|vender) (acciones|bitcoin|cripto)`,
+				`(debo|debería|deberías) invertir (en|mis|tus) (acciones|bitcoin|cripto|ahorros)`,
+			},
+			terms: []string{
+				"invertir en", "comprar acciones", "vender acciones",
+				"asesoría financiera", "consejo financiero", "cartera de inversión",
+			},
+		},
+		RefusalSelfHarm: {
+			patterns: []string{
+				"suicidarme", "suicidio", "quitarme la vida", "matarme",
+				"hacerme daño", "autolesión", "quiero morir",
+			},
+		},
+	},
+	"de": {
+		RefusalMedical: {
+			patterns: []string{
+				`welche (medikamente?|tabletten|dosis) soll(te)? ich`,
+				`(mich|sie|dich) diagnostizieren`,
+				`(sie sollten|du solltest) ([^ ]+ )?(mg|medikamente?|tabletten|antidepressiva|antibiotika) (nehmen|einnehmen)`,
+				`medizinische(n)? rat`,
+			},
+			terms: []string{
+				"diagnose", "diagnostizieren", "verschreiben", "rezept",
+				"medikament", "behandlung", "krankheit", "symptom",
+			},
+		},
+		RefusalLegal: {
+			patterns: []string{
+				`rechtsberatung`,
+				`rechtlichen rat`,
+				`(soll|sollte|kann) ich ([^ ]+ )?verklagen`,
+				`meine rechte vor gericht`,
+			},
+			terms: []string{
+				"rechtsberatung", "klage", "verklagen", "anwalt", "gericht",
+			},
+		},
+		RefusalFinancial: {
+			patterns: []string{
+				`finanzberatung`,
+				`anlageberatung`,
+				`(soll|sollte) ich ([^ ]+ )?(aktien|bitcoin|krypto) (kaufen|verkaufen)`,
+			},
+			terms: []string{
+				"investieren in", "aktien kaufen", "aktien verkaufen", "finanzberatung", "portfolio",
+			},
+		},
+		RefusalSelfHarm: {
+			patterns: []string{
+				"mich umbringen", "suizid", "selbstmord", "mir das leben nehmen",
+				"mich verletzen", "selbstverletzung", "sterben will",
+			},
+		},
+	},
+	"fr": {
+		RefusalMedical: {
+			patterns: []string{
+				`(quel|quels|quelle) (médicament|traitement|dose|dosage)s? (dois|devrais)-je`,
+				`diagnostiquer (mon|ma|mes)`,
+				`(vous devriez|tu devrais|il faut) prendre ([^ ]+ )?(mg|médicaments?|comprimés|antidépresseurs|antibiotiques)`,
+				`conseil médical`,
+			},
+			terms: []string{
+				"diagnostiquer", "diagnostic", "prescrire", "ordonnance",
+				"médicament", "traitement", "maladie", "symptôme",
+			},
+		},
+		RefusalLegal: {
+			patterns: []string{
+				`conseil juridique`,
+				`(dois|puis|devrais)-je (le |la |les )?poursuivre`,
+				`porter plainte contre`,
+			},
+			terms: []string{
+				"conseil juridique", "procès", "poursuivre en justice", "avocat", "tribunal",
+			},
+		},
+		RefusalFinancial: {
+			patterns: []string{
+				`conseil financier`,
+				`conseil en investissement`,
+				`(dois|devrais)-je (acheter|vendre) des (actions|bitcoins?|cryptos?)`,
+			},
+			terms: []string{
+				"investir dans", "acheter des actions", "vendre des actions", "conseil financier", "portefeuille",
+			},
+		},
+		RefusalSelfHarm: {
+			patterns: []string{
+				"me suicider", "suicide", "mettre fin à mes jours", "me tuer",
+				"me faire du mal", "automutilation", "envie de mourir",
+			},
+		},
+	},
+}
+
+// refusalMatcher is the compiled form of refusalRules
+type refusalMatcher struct {
+	patterns *regexp.Regexp
+	terms    []string
+}
+
+// refusalMatchers holds the compiled rules, by language and refusal kind
+var refusalMatchers = compileRefusalRules(refusalKeywords)
+
+// compileRefusalRules folds every rule like the text it's matched against and joins each set's
+// patterns into one expression
+func compileRefusalRules(rules map[string]map[string]refusalRules) map[string]map[string]refusalMatcher {
+	compiled := make(map[string]map[string]refusalMatcher, len(rules))
+	for lang, kinds := range rules {
+		compiled[lang] = make(map[string]refusalMatcher, len(kinds))
+		for kind, set := range kinds {
+			var matcher refusalMatcher
+			if len(set.patterns) > 0 {
+				alternatives := make([]string, len(set.patterns))
+				for i, pattern := range set.patterns {
+					alternatives[i] = "(?:" + textutil.FoldForSearch(pattern) + ")"
+				}
+				matcher.patterns = regexp.MustCompile(strings.Join(alternatives, "|"))
+			}
+			for _, term := range set.terms {
+				matcher.terms = append(matcher.terms, textutil.FoldForSearch(term))
+			}
+			compiled[lang][kind] = matcher
+		}
+	}
+	return compiled
+}
+
+// keywordLanguages returns the keyword sets to check: English, the active language (an ISO 639
+// code or an Accept-Language value), and the coach's languages
+func keywordLanguages(active string, spec *models.CoachSpec) []string {
+	tags, _, _ := language.ParseAcceptLanguage(active)
+	for _, lang := range spec.Identity.Languages {
+		if tag, err := language.Parse(lang); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+
+	languages := []string{"en"}
+	seen := map[string]bool{"en": true}
+	for _, tag := range tags {
+		base, _ := tag.Base()
+		code := base.String()
+		if _, ok := refusalMatchers[code]; ok && !seen[code] {
+			seen[code] = true
+			languages = append(languages, code)
+		}
+	}
+	return languages
+}
+
+// matchesKeywords reports whether folded text trips the rules for kind in any of languages.
+// Topic terms only count at strict sensitivity.
+func matchesKeywords(folded, kind string, languages []string, sensitivity string) bool {
+	for _, lang := range languages {
+		matcher := refusalMatchers[lang][kind]
+		if matcher.patterns != nil && matcher.patterns.MatchString(folded) {
+			return true
+		}
+		if sensitivity != SensitivityStrict {
+			continue
+		}
+		for _, term := range matcher.terms {
+			if strings.Contains(folded, term) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/coach"
+)
+
+func refusingSpec(languages ...string) *models.CoachSpec {
+	return &models.CoachSpec{
+		Identity: models.Identity{Languages: languages},
+		Policies: models.Policies{Refusals: models.Refusals{
+			Medical:         true,
+			Legal:           true,
+			FinancialAdvice: "none",
+			SelfHarm:        "escalate_support",
+		}},
+	}
+}
+
+func TestKeywordLanguages(t *testing.T) {
+	tests := []struct {
+		name      string
+		active    string
+		languages []string
+		want      []string
+	}{
+		{"english only", "", nil, []string{"en"}},
+		{"active language", "tr", nil, []string{"en", "tr"}},
+		{"accept-language header", "es-MX,es;q=0.9,en;q=0.8", nil, []string{"en", "es"}},
+		{"coach languages", "", []string{"de", "fr"}, []string{"en", "de", "fr"}},
+		{"active and coach, deduplicated", "tr-TR", []string{"tr", "es"}, []string{"en", "tr", "es"}},
+		{"no keyword set", "ja", []string{"ko"}, []string{"en"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := keywordLanguages(tt.active, refusingSpec(tt.languages...)); !slices.Equal(got, tt.want) {
+				t.Errorf("keywordLanguages(%q, %v) = %v, want %v", tt.active, tt.languages, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRefusesInActiveLanguage(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	tests := []struct {
+		name      string
+		text      string
+		lang      string
+		languages []string
+		want      string
+	}{
+		{"english self-harm", "Sometimes I want to die.", "", nil, RefusalSelfHarm},
+		{"english medication", "Which medication should I take for this?", "en", nil, RefusalMedical},
+		{"turkish self-harm", "Son zamanlarda intihar etmeyi düşünüyorum.", "tr", nil, RefusalSelfHarm},
+		{"turkish uppercase dotted I", "İNTİHAR etmeyi düşünüyorum.", "tr", nil, RefusalSelfHarm},
+		{"turkish medication", "Hangi ilacı almalıyım?", "tr-TR", nil, RefusalMedical},
+		{"spanish via coach language", "A veces quiero morir.", "", []string{"es"}, RefusalSelfHarm},
+		{"spanish legal", "¿Debería demandar a mi jefe?", "es", nil, RefusalLegal},
+		{"german financial", "Soll ich jetzt Aktien kaufen?", "de", nil, RefusalFinancial},
+		{"spanish dosage", "Deberías tomar 20 mg cada noche.", "es", nil, RefusalMedical},
+		{"french medication", "Tu devrais prendre des antidépresseurs.", "fr", nil, RefusalMedical},
+		{"turkish without turkish in play", "Son zamanlarda intihar etmeyi düşünüyorum.", "", nil, ""},
+		{"turkish small talk", "Bugün koşuya çıktım, harika hissediyorum.", "tr", nil, ""},
+		{"turkish topic word at standard sensitivity", "Tedavi sürecim iyi gidiyor.", "tr", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := sf.Validate(context.Background(), &coach.CoachOutput{MessageText: tt.text}, refusingSpec(tt.languages...), tt.lang)
+			var refusal *RefusalError
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("refused %q: %v", tt.text, err)
+			case tt.want != "" && (!errors.As(err, &refusal) || refusal.Kind != tt.want):
+				t.Errorf("Validate(%q) = %v, want a %s refusal", tt.text, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictSensitivityMatchesTopicTerms(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	spec := refusingSpec()
+	spec.Policies.Refusals.Sensitivity = SensitivityStrict
+
+	err := sf.Validate(context.Background(), &coach.CoachOutput{MessageText: "Tedavi sürecim iyi gidiyor."}, spec, "tr")
+	var refusal *RefusalError
+	if !errors.As(err, &refusal) || refusal.Kind != RefusalMedical {
+		t.Errorf("strict Turkish topic word: %v, want a medical refusal", err)
+	}
+}
+
+func TestNeedsEscalationLocalized(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	if !sf.NeedsEscalation("Ya no aguanto más, quiero quitarme la vida", refusingSpec(), "es") {
+		t.Error("Spanish self-harm message not escalated")
+	}
+	if !sf.NeedsEscalation("I want to hurt myself", refusingSpec(), "tr") {
+		t.Error("English keywords not checked alongside the active language")
+	}
+
+	spec := refusingSpec()
+	spec.Policies.Refusals.SelfHarm = "refuse"
+	if sf.NeedsEscalation("quiero morir", spec, "es") {
+		t.Error("escalated although the coach doesn't escalate self-harm")
+	}
+}
@@ -0,0 +1,58 @@
+package safety
+
+import (
+	"fmt"
+	"strings"
+
+	"simon-backend/internal/orchestrator/coach"
+)
+
+// PermissionNotice describes a tool proposal withheld until the user grants device permissions
+type PermissionNotice struct {
+	Tool    string
+	Missing []string
+}
+
+// Message is the user-facing ask shown in place of the tool request
+func (n PermissionNotice) Message() string {
+	return fmt.Sprintf("To do this, allow Simon access to your %s in Settings.", strings.Join(n.Missing, " and "))
+}
+
+// ScreenToolPermissions splits tool proposals into those the client can run and those that
+// would fail for lack of a device permission (per the tool registry). A nil granted list
+// means the client didn't report its permissions, so every proposal passes.
+func (sf *SafetyFilter) ScreenToolPermissions(requests []coach.ToolRequest, granted []string) ([]coach.ToolRequest, []PermissionNotice) {
+	if granted == nil {
+		return requests, nil
+	}
+
+	grantedSet := make(map[string]bool, len(granted))
+	for _, permission := range granted {
+		grantedSet[permission] = true
+	}
+
+	allowed := make([]coach.ToolRequest, 0, len(requests))
+	var notices []PermissionNotice
+	for _, req := range requests {
+		tool, err := sf.registry.GetTool(req.Tool)
+		if err != nil {
+			// Unknown tools are rejected by the consent check; nothing to screen here
+			allowed = append(allowed, req)
+			continue
+		}
+
+		var missing []string
+		for _, permission := range tool.PermissionDependencies {
+			if !grantedSet[permission] {
+				missing = append(missing, permission)
+			}
+		}
+		if len(missing) > 0 {
+			notices = append(notices, PermissionNotice{Tool: req.Tool, Missing: missing})
+			continue
+		}
+		allowed = append(allowed, req)
+	}
+
+	return allowed, notices
+}
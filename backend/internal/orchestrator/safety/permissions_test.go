@@ -0,0 +1,57 @@
+package safety
+
+import (
+	"slices"
+	"testing"
+
+	"simon-backend/internal/orchestrator/coach"
+)
+
+func TestScreenToolPermissions(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	requests := []coach.ToolRequest{
+		{RequestID: "r1", Tool: "reminder_create"},
+		{RequestID: "r2", Tool: "calendar_event_create"},
+		{RequestID: "r3", Tool: "plan_create"},
+		{RequestID: "r4", Tool: "not_a_tool"},
+	}
+
+	tests := []struct {
+		name        string
+		granted     []string
+		wantAllowed []string
+		wantNotices map[string][]string
+	}{
+		{"permissions not reported", nil, []string{"r1", "r2", "r3", "r4"}, nil},
+		{"reminders missing", []string{"calendar"}, []string{"r2", "r3", "r4"}, map[string][]string{"reminder_create": {"reminders"}}},
+		{"nothing granted", []string{}, []string{"r3", "r4"}, map[string][]string{"reminder_create": {"reminders"}, "calendar_event_create": {"calendar"}}},
+		{"everything granted", []string{"calendar", "reminders"}, []string{"r1", "r2", "r3", "r4"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, notices := sf.ScreenToolPermissions(requests, tt.granted)
+			var ids []string
+			for _, req := range allowed {
+				ids = append(ids, req.RequestID)
+			}
+			if !slices.Equal(ids, tt.wantAllowed) {
+				t.Errorf("allowed = %v, want %v", ids, tt.wantAllowed)
+			}
+			if len(notices) != len(tt.wantNotices) {
+				t.Fatalf("notices = %+v, want %v", notices, tt.wantNotices)
+			}
+			for _, notice := range notices {
+				if !slices.Equal(notice.Missing, tt.wantNotices[notice.Tool]) {
+					t.Errorf("%s missing %v, want %v", notice.Tool, notice.Missing, tt.wantNotices[notice.Tool])
+				}
+			}
+		})
+	}
+}
+
+func TestPermissionNoticeMessage(t *testing.T) {
+	notice := PermissionNotice{Tool: "reminder_create", Missing: []string{"reminders", "notifications"}}
+	if got, want := notice.Message(), "To do this, allow Simon access to your reminders and notifications in Settings."; got != want {
+		t.Errorf("Message = %q, want %q", got, want)
+	}
+}
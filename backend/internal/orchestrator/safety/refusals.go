@@ -0,0 +1,84 @@
+package safety
+
+import (
+	"strings"
+
+	"simon-backend/internal/models"
+)
+
+// Refusal kinds, also the keys of Policies.Refusals.Messages
+const (
+	RefusalMedical   = "medical"
+	RefusalLegal     = "legal"
+	RefusalFinancial = "financial"
+	RefusalSelfHarm  = "self_harm"
+)
+
+// refusalVoice groups persona voices that share a refusal template
+type refusalVoice string
+
+const (
+	voiceDefault refusalVoice = "default"
+	voiceWarm    refusalVoice = "warm"
+	voiceDirect  refusalVoice = "direct"
+)
+
+// refusalTemplates keeps refusals in character; every template still names the right professional
+var refusalTemplates = map[refusalVoice]map[string]string{
+	voiceDefault: {
+		RefusalMedical:   "I can't provide medical advice. Please consult a healthcare professional",
+		RefusalLegal:     "I can't provide legal advice. Please consult a lawyer",
+		RefusalFinancial: "I can't provide financial advice. Please consult a financial advisor",
+		RefusalSelfHarm:  "I'm concerned about your safety. Please reach out to a crisis helpline or mental health professional immediately",
+	},
+	voiceWarm: {
+		RefusalMedical:   "I care about how you're feeling, and this deserves more than I can offer. A healthcare professional is the right person to help with this, and I'm here for everything else",
+		RefusalLegal:     "I hear how much this matters to you. A lawyer is the right person to guide you here, and I'm happy to help you prepare for that conversation",
+		RefusalFinancial: "I want you to feel confident about this, so I'll be honest: a financial advisor is the right person for this decision. I'm glad to help you think through your questions for them",
+		RefusalSelfHarm:  "I'm really glad you told me, and I'm worried about your safety. Please reach out to a crisis helpline or a mental health professional right now. You don't have to go through this alone",
+	},
+	voiceDirect: {
+		RefusalMedical:   "That's a medical question, and it's outside what I do. Talk to a healthcare professional",
+		RefusalLegal:     "That's a legal question, and it's outside what I do. Talk to a lawyer",
+		RefusalFinancial: "That's financial advice, and it's outside what I do. Talk to a financial advisor",
+		RefusalSelfHarm:  "Your safety comes first. Contact a crisis helpline or a mental health professional now",
+	},
+}
+
+// voiceKeywords map persona voice/tone words to a template
+var voiceKeywords = map[refusalVoice][]string{
+	voiceWarm:   {"warm", "gentle", "kind", "supportive", "compassionate", "caring", "encouraging"},
+	voiceDirect: {"direct", "blunt", "no-nonsense", "concise", "straightforward", "terse"},
+}
+
+// refusalMessage returns the refusal for kind in the coach's voice. A message authored in
+// Policies.Refusals.Messages wins, except for self-harm, which always uses a vetted template.
+func refusalMessage(kind string, spec *models.CoachSpec) string {
+	if spec != nil && kind != RefusalSelfHarm {
+		if msg := strings.TrimSpace(spec.Policies.Refusals.Messages[kind]); msg != "" {
+			return msg
+		}
+	}
+
+	if msg, ok := refusalTemplates[voiceFor(spec)][kind]; ok {
+		return msg
+	}
+	return refusalTemplates[voiceDefault][kind]
+}
+
+// voiceFor picks the refusal template matching the coach's persona voice and tone
+func voiceFor(spec *models.CoachSpec) refusalVoice {
+	if spec == nil {
+		return voiceDefault
+	}
+
+	described := strings.ToLower(spec.Identity.Persona.Voice + " " + spec.Style.Tone)
+	for _, voice := range []refusalVoice{voiceWarm, voiceDirect} {
+		for _, keyword := range voiceKeywords[voice] {
+			if strings.Contains(described, keyword) {
+				return voice
+			}
+		}
+	}
+	return voiceDefault
+}
@@ -0,0 +1,124 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"simon-backend/internal/models"
+	"simon-backend/internal/orchestrator/coach"
+)
+
+// benignReplies used to trip the bare-keyword filter
+var benignReplies = []string{
+	"Let's prepare for your court appearance on Monday: arrive early and bring your notes.",
+	"Missing deadlines is a symptom; the cause is the meeting load.",
+	"Your treatment of the design feedback was generous.",
+	"Add the launch to your portfolio once it ships.",
+	"You're trading evening scrolling for a walk, nice.",
+	"Your lawyer friend's advice about boundaries sounds right.",
+}
+
+func TestStandardSensitivityAllowsBenignReplies(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	for _, text := range benignReplies {
+		if err := sf.Validate(context.Background(), &coach.CoachOutput{MessageText: text}, refusingSpec(), "en"); err != nil {
+			t.Errorf("refused %q: %v", text, err)
+		}
+	}
+}
+
+func TestStrictSensitivityRefusesTopics(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	spec := refusingSpec()
+	spec.Policies.Refusals.Sensitivity = SensitivityStrict
+	for _, text := range benignReplies {
+		if err := sf.Validate(context.Background(), &coach.CoachOutput{MessageText: text}, spec, "en"); err == nil {
+			t.Errorf("strict sensitivity allowed %q", text)
+		}
+	}
+}
+
+func TestStandardSensitivityRefusesAdvice(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	tests := map[string]string{
+		"You probably have an anxiety disorder.":        RefusalMedical,
+		"Should I stop taking my meds before the trip?": RefusalMedical,
+		"You should take 50 mg before bed.":             RefusalMedical,
+		"Honestly, you should sue your landlord.":       RefusalLegal,
+		"Is it legal to record my boss?":                RefusalLegal,
+		"You should buy Bitcoin before the halving.":    RefusalFinancial,
+		"Should I invest my savings in index funds?":    RefusalFinancial,
+		"Here are my stock picks for the week.":         RefusalFinancial,
+	}
+	for text, want := range tests {
+		err := sf.Validate(context.Background(), &coach.CoachOutput{MessageText: text}, refusingSpec(), "en")
+		var refusal *RefusalError
+		if !errors.As(err, &refusal) || refusal.Kind != want {
+			t.Errorf("Validate(%q) = %v, want a %s refusal", text, err, want)
+		}
+	}
+}
+
+func TestDisabledPoliciesDontRefuse(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	spec := refusingSpec()
+	spec.Policies.Refusals.Legal = false
+	spec.Policies.Refusals.FinancialAdvice = "general_only"
+	for _, text := range []string{"You should sue your landlord.", "You should buy Bitcoin now."} {
+		if err := sf.Validate(context.Background(), &coach.CoachOutput{MessageText: text}, spec, "en"); err != nil {
+			t.Errorf("refused %q with the policy off: %v", text, err)
+		}
+	}
+}
+
+func TestRefusalMessageVoice(t *testing.T) {
+	withVoice := func(voice, tone string, messages map[string]string) *models.CoachSpec {
+		spec := refusingSpec()
+		spec.Identity.Persona.Voice = voice
+		spec.Style.Tone = tone
+		spec.Policies.Refusals.Messages = messages
+		return spec
+	}
+	tests := []struct {
+		name string
+		kind string
+		spec *models.CoachSpec
+		want string
+	}{
+		{"no spec", RefusalLegal, nil, refusalTemplates[voiceDefault][RefusalLegal]},
+		{"neutral coach", RefusalLegal, withVoice("analytical", "neutral", nil), refusalTemplates[voiceDefault][RefusalLegal]},
+		{"warm tone", RefusalMedical, withVoice("", "Warm and encouraging", nil), refusalTemplates[voiceWarm][RefusalMedical]},
+		{"gentle persona voice", RefusalFinancial, withVoice("gentle", "", nil), refusalTemplates[voiceWarm][RefusalFinancial]},
+		{"blunt coach", RefusalMedical, withVoice("blunt", "", nil), refusalTemplates[voiceDirect][RefusalMedical]},
+		{"authored message wins", RefusalLegal, withVoice("warm", "", map[string]string{RefusalLegal: " Not my lane, friend. Ask a lawyer. "}), "Not my lane, friend. Ask a lawyer."},
+		{"blank authored message", RefusalLegal, withVoice("warm", "", map[string]string{RefusalLegal: "  "}), refusalTemplates[voiceWarm][RefusalLegal]},
+		{"self-harm ignores authored messages", RefusalSelfHarm, withVoice("warm", "", map[string]string{RefusalSelfHarm: "Cheer up!"}), refusalTemplates[voiceWarm][RefusalSelfHarm]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refusalMessage(tt.kind, tt.spec); got != tt.want {
+				t.Errorf("refusalMessage = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarmCoachRefusesInCharacter(t *testing.T) {
+	sf := NewSafetyFilter(ConfidencePolicy{}, nil)
+	spec := refusingSpec()
+	spec.Style.Tone = "warm"
+
+	err := sf.Validate(context.Background(), &coach.CoachOutput{MessageText: "You probably have an anxiety disorder."}, spec, "en")
+	var refusal *RefusalError
+	if !errors.As(err, &refusal) {
+		t.Fatalf("Validate = %v, want a refusal", err)
+	}
+	if refusal.Message != refusalTemplates[voiceWarm][RefusalMedical] {
+		t.Errorf("refusal = %q, want the warm medical template", refusal.Message)
+	}
+	if !strings.Contains(refusal.Message, "healthcare professional") {
+		t.Errorf("warm refusal %q no longer points to a professional", refusal.Message)
+	}
+}
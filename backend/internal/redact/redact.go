@@ -0,0 +1,59 @@
+// Package redact strips sensitive data (card numbers, SSNs, credentials) from text before it's stored.
+package redact
+
+import (
+	"regexp"
+
+	"simon-backend/internal/models"
+)
+
+// Placeholder replaces each sensitive value
+const Placeholder = "[REDACTED]"
+
+// builtinPatterns match sensitive values whatever the coach
+var builtinPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)password[:\s]+\S+`),
+	regexp.MustCompile(`(?i)api[_\s]?key[:\s]+\S+`),
+	regexp.MustCompile(`\b\d{4}[\s-]?\d{4}[\s-]?\d{4}[\s-]?\d{4}\b`), // Credit card
+	regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),                      // SSN
+	regexp.MustCompile(`(?i)secret[:\s]+\S+`),
+	regexp.MustCompile(`(?i)token[:\s]+\S+`),
+}
+
+// Contains reports whether text holds sensitive data, per the built-in patterns and a coach's
+// custom ones (Privacy.RedactPatterns)
+func Contains(text string, custom []string) bool {
+	for _, pattern := range defaultRegistry.Patterns(custom) {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Text replaces each sensitive value in text with Placeholder and returns how many it replaced.
+// custom are a coach's own patterns, applied after the built-in ones.
+func Text(text string, custom []string) (string, int) {
+	count := 0
+	for _, pattern := range defaultRegistry.Patterns(custom) {
+		matches := len(pattern.FindAllStringIndex(text, -1))
+		if matches == 0 {
+			continue
+		}
+		count += matches
+		text = pattern.ReplaceAllString(text, Placeholder)
+	}
+	return text, count
+}
+
+// ForStorage returns text as it should be persisted under the coach's privacy policy: redacted,
+// unless the coach opted into Privacy.StoreSensitiveMemory. A nil spec gets the built-in patterns.
+func ForStorage(text string, spec *models.CoachSpec) (string, int) {
+	if spec == nil {
+		return Text(text, nil)
+	}
+	if spec.Policies.Privacy.StoreSensitiveMemory {
+		return text, 0
+	}
+	return Text(text, spec.Policies.Privacy.RedactPatterns)
+}
@@ -0,0 +1,67 @@
+package redact
+
+import (
+	"testing"
+
+	"simon-backend/internal/models"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        string
+		want      string
+		wantCount int
+	}{
+		{"password", "my password: hunter2 ok", "my [REDACTED] ok", 1},
+		{"api key", "use API key sk-123 here", "use [REDACTED] here", 1},
+		{"api_key", "api_key:abc123", "[REDACTED]", 1},
+		{"card with spaces", "card 4111 1111 1111 1111 on file", "card [REDACTED] on file", 1},
+		{"card with dashes", "4111-1111-1111-1111", "[REDACTED]", 1},
+		{"ssn", "ssn 123-45-6789.", "ssn [REDACTED].", 1},
+		{"secret", "Secret: swordfish", "[REDACTED]", 1},
+		{"token", "token abc.def", "[REDACTED]", 1},
+		{"several values", "password: a and 123-45-6789 and token: b", "[REDACTED] and [REDACTED] and [REDACTED]", 3},
+		{"clean text", "Run 5k on Tuesday at 7", "Run 5k on Tuesday at 7", 0},
+		{"short digit runs", "call 555-1234 about 12 runs", "call 555-1234 about 12 runs", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, count := Text(tt.in, nil)
+			if got != tt.want || count != tt.wantCount {
+				t.Errorf("Text(%q) = %q, %d; want %q, %d", tt.in, got, count, tt.want, tt.wantCount)
+			}
+			if contains := Contains(tt.in, nil); contains != (tt.wantCount > 0) {
+				t.Errorf("Contains(%q) = %v", tt.in, contains)
+			}
+		})
+	}
+}
+
+func TestForStorage(t *testing.T) {
+	const text = "password: hunter2, acct 991"
+	custom := &models.CoachSpec{}
+	custom.Policies.Privacy.RedactPatterns = []string{"acct"}
+	optOut := &models.CoachSpec{}
+	optOut.Policies.Privacy.StoreSensitiveMemory = true
+	optOut.Policies.Privacy.RedactPatterns = []string{"acct"}
+
+	tests := []struct {
+		name      string
+		spec      *models.CoachSpec
+		want      string
+		wantCount int
+	}{
+		{"nil spec uses the built-in patterns", nil, "[REDACTED] acct 991", 1},
+		{"default spec", &models.CoachSpec{}, "[REDACTED] acct 991", 1},
+		{"custom patterns apply after the built-in ones", custom, "[REDACTED] [REDACTED]", 2},
+		{"store sensitive memory opts out", optOut, text, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, count := ForStorage(text, tt.spec); got != tt.want || count != tt.wantCount {
+				t.Errorf("ForStorage = %q, %d; want %q, %d", got, count, tt.want, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package redact
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxCachedPatterns bounds the registry; past it the cache starts over
+const maxCachedPatterns = 1000
+
+// Compile compiles one of a coach's redact patterns. A pattern wrapped in slashes ("/acct-\d+/")
+// is a regular expression; anything else is a literal term, matched case-insensitively together
+// with the value that follows it ("account_number: 12345"). Patterns that match empty text are
+// rejected, since they would insert a marker between every character of a message.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	var re *regexp.Regexp
+	var err error
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err = regexp.Compile(pattern[1 : len(pattern)-1])
+	} else {
+		re, err = regexp.Compile(`(?i)` + regexp.QuoteMeta(pattern) + `(?:[:=\s]+\S+)?`)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if re.MatchString("") {
+		return nil, ErrMatchesEmpty
+	}
+	return re, nil
+}
+
+// ErrMatchesEmpty is returned by Compile for patterns that match empty text
+var ErrMatchesEmpty = errors.New("pattern matches empty text")
+
+// Registry caches compiled coach patterns so they aren't recompiled for every message
+type Registry struct {
+	mu       sync.RWMutex
+	compiled map[string]*regexp.Regexp // nil for patterns that don't compile
+}
+
+// defaultRegistry backs the package-level helpers
+var defaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{compiled: make(map[string]*regexp.Regexp)}
+}
+
+// Patterns returns the built-in patterns followed by the compiled custom ones. Custom patterns
+// that don't compile (specs saved before they were validated) are logged once and skipped.
+func (r *Registry) Patterns(custom []string) []*regexp.Regexp {
+	if len(custom) == 0 {
+		return builtinPatterns
+	}
+
+	patterns := make([]*regexp.Regexp, len(builtinPatterns), len(builtinPatterns)+len(custom))
+	copy(patterns, builtinPatterns)
+	for _, pattern := range custom {
+		if pattern == "" {
+			continue
+		}
+		if re := r.compile(pattern); re != nil {
+			patterns = append(patterns, re)
+		}
+	}
+	return patterns
+}
+
+// compile returns the cached form of pattern, compiling it on first use
+func (r *Registry) compile(pattern string) *regexp.Regexp {
+	r.mu.RLock()
+	re, ok := r.compiled[pattern]
+	r.mu.RUnlock()
+	if ok {
+		return re
+	}
+
+	re, err := Compile(pattern)
+	if err != nil {
+		log.Printf("Skipping invalid redact pattern %q: %v", pattern, err)
+		re = nil
+	}
+
+	r.mu.Lock()
+	if len(r.compiled) >= maxCachedPatterns {
+		r.compiled = make(map[string]*regexp.Regexp)
+	}
+	r.compiled[pattern] = re
+	r.mu.Unlock()
+	return re
+}
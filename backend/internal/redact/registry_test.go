@@ -0,0 +1,85 @@
+package redact
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"literal takes the value after it", "account_number", "my Account_Number: 12345 ok", "my [REDACTED] ok", false},
+		{"literal is quoted", "a.c", "abc a.c", "abc [REDACTED]", false},
+		{"regex", `/acct-\d+/`, "acct-42 and acct-x", "[REDACTED] and acct-x", false},
+		{"regex is case sensitive", `/acct-\d+/`, "ACCT-42", "ACCT-42", false},
+		{"invalid regex", "/acct-(/", "", "", true},
+		{"empty regex", "//", "", "", true},
+		{"star", "/a*/", "", "", true},
+		{"lazy dot", "/.*?/", "", "", true},
+		{"optional group", "/(acct)?/", "", "", true},
+		{"single slash is a literal", "/", "path / here", "path [REDACTED]", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := Compile(tt.pattern)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Compile(%q) accepted the pattern", tt.pattern)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.pattern, err)
+			}
+			if got := re.ReplaceAllString(tt.in, Placeholder); got != tt.want {
+				t.Errorf("replaced %q = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Compile("/x?/"); !errors.Is(err, ErrMatchesEmpty) {
+		t.Errorf("Compile(/x?/) error = %v, want ErrMatchesEmpty", err)
+	}
+}
+
+func TestRegistryPatterns(t *testing.T) {
+	r := NewRegistry()
+	if got := r.Patterns(nil); len(got) != len(builtinPatterns) {
+		t.Fatalf("no custom patterns: got %d, want the %d built-in ones", len(got), len(builtinPatterns))
+	}
+
+	got := r.Patterns([]string{"acct", "", "/acct-(/", "//", `/id-\d+/`})
+	if len(got) != len(builtinPatterns)+2 {
+		t.Fatalf("got %d patterns, want the built-in ones plus acct and id", len(got))
+	}
+	if got[len(got)-2].String() != `(?i)acct(?:[:=\s]+\S+)?` || got[len(got)-1].String() != `id-\d+` {
+		t.Errorf("custom patterns = %v, %v", got[len(got)-2], got[len(got)-1])
+	}
+
+	// Compiled and rejected patterns are both cached
+	again := r.Patterns([]string{"acct", "/acct-(/", "//"})
+	if again[len(again)-1] != got[len(got)-2] {
+		t.Error("acct was recompiled instead of served from the cache")
+	}
+	for _, pattern := range []string{"/acct-(/", "//"} {
+		if re, ok := r.compiled[pattern]; !ok || re != nil {
+			t.Errorf("%q cached as %v, %v; want a nil entry", pattern, re, ok)
+		}
+	}
+}
+
+func TestRegistryCacheIsBounded(t *testing.T) {
+	r := NewRegistry()
+	for i := range maxCachedPatterns + 1 {
+		r.compile(fmt.Sprintf("term%d", i))
+	}
+	if len(r.compiled) != 1 {
+		t.Errorf("cache holds %d patterns after overflowing, want 1", len(r.compiled))
+	}
+}
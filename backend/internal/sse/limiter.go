@@ -0,0 +1,51 @@
+package sse
+
+import (
+	"sync"
+
+	"simon-backend/internal/metrics"
+)
+
+// Limiter caps how many streams each user may hold open at once
+type Limiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[string]int
+}
+
+// NewLimiter creates a limiter allowing max concurrent streams per user; 0 disables the limit
+func NewLimiter(max int) *Limiter {
+	return &Limiter{max: max, active: make(map[string]int)}
+}
+
+// Max returns the per-user stream limit
+func (l *Limiter) Max() int {
+	return l.max
+}
+
+// Acquire reserves a stream slot for uid. It returns false when uid already holds the maximum;
+// otherwise the returned release frees the slot and must be called once the stream closes.
+func (l *Limiter) Acquire(uid string) (release func(), ok bool) {
+	l.mu.Lock()
+	if l.max > 0 && l.active[uid] >= l.max {
+		l.mu.Unlock()
+		metrics.Get().RecordSSERejected()
+		return nil, false
+	}
+	l.active[uid]++
+	l.mu.Unlock()
+	metrics.Get().RecordSSEConnection()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.active[uid]--
+			if l.active[uid] <= 0 {
+				delete(l.active, uid)
+			}
+			l.mu.Unlock()
+			metrics.Get().RecordSSEDisconnect()
+		})
+	}, true
+}
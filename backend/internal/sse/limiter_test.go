@@ -0,0 +1,101 @@
+package sse
+
+import (
+	"sync"
+	"testing"
+
+	"simon-backend/internal/metrics"
+)
+
+func sseStat(key string) int64 {
+	return metrics.Get().GetStats()["sse"].(map[string]interface{})[key].(int64)
+}
+
+func TestLimiterRejectsBeyondMax(t *testing.T) {
+	activeBefore, rejectedBefore := sseStat("active"), sseStat("rejected")
+	l := NewLimiter(2)
+
+	first, ok := l.Acquire("u1")
+	if !ok {
+		t.Fatal("first stream rejected")
+	}
+	second, ok := l.Acquire("u1")
+	if !ok {
+		t.Fatal("second stream rejected")
+	}
+	if _, ok := l.Acquire("u1"); ok {
+		t.Fatal("third stream allowed with a limit of 2")
+	}
+	if got := sseStat("active") - activeBefore; got != 2 {
+		t.Errorf("active connections = %d, want 2", got)
+	}
+	if got := sseStat("rejected") - rejectedBefore; got != 1 {
+		t.Errorf("rejected = %d, want 1", got)
+	}
+
+	// Another user has their own slots
+	other, ok := l.Acquire("u2")
+	if !ok {
+		t.Fatal("a different user was rejected")
+	}
+	other()
+
+	// Closing a stream frees its slot, and releasing twice frees only one
+	first()
+	first()
+	third, ok := l.Acquire("u1")
+	if !ok {
+		t.Fatal("slot not freed after a stream closed")
+	}
+	if _, ok := l.Acquire("u1"); ok {
+		t.Error("double release freed two slots")
+	}
+
+	second()
+	third()
+	if got := sseStat("active") - activeBefore; got != 0 {
+		t.Errorf("active connections = %d after every stream closed, want 0", got)
+	}
+	if len(l.active) != 0 {
+		t.Errorf("active = %v, want users dropped once idle", l.active)
+	}
+}
+
+func TestLimiterZeroIsUnlimited(t *testing.T) {
+	l := NewLimiter(0)
+	for i := 0; i < 50; i++ {
+		release, ok := l.Acquire("u1")
+		if !ok {
+			t.Fatalf("stream %d rejected without a limit", i+1)
+		}
+		defer release()
+	}
+}
+
+func TestLimiterConcurrentAcquire(t *testing.T) {
+	l := NewLimiter(3)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		releases []func()
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if release, ok := l.Acquire("u1"); ok {
+				mu.Lock()
+				releases = append(releases, release)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(releases) != 3 {
+		t.Errorf("%d concurrent streams admitted, want 3", len(releases))
+	}
+	for _, release := range releases {
+		release()
+	}
+}
@@ -3,11 +3,26 @@ package sse
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 )
 
-// Init initializes SSE headers and returns a flusher
+// MaxDuration is how long a stream may stay open before the server ends it
+const MaxDuration = 5 * time.Minute
+
+// writeGrace is the slack past MaxDuration before the write deadline cuts the connection, so the
+// stream can still send its timeout event
+const writeGrace = 30 * time.Second
+
+// Init initializes SSE headers and returns a flusher. It also extends the write deadline past
+// the server's WriteTimeout, which is sized for ordinary requests and would otherwise cut a
+// stream off mid-reply; MaxDuration bounds the stream instead.
 func Init(w http.ResponseWriter) (http.Flusher, bool) {
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(MaxDuration + writeGrace)); err != nil {
+		log.Printf("Failed to extend SSE write deadline: %v", err)
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
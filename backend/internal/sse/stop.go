@@ -0,0 +1,55 @@
+package sse
+
+import (
+	"context"
+	"sync"
+)
+
+// Stops lets a client stop a session's running streams. Streams are tracked in memory, so a
+// stop only reaches streams served by this instance.
+type Stops struct {
+	mu      sync.Mutex
+	next    int
+	running map[string]map[int]context.CancelFunc
+}
+
+// NewStops creates an empty stream registry
+func NewStops() *Stops {
+	return &Stops{running: make(map[string]map[int]context.CancelFunc)}
+}
+
+// Track registers a stream for sessionID. The returned context is cancelled when Stop is called
+// for the session; done unregisters the stream and must be called once it ends.
+func (s *Stops) Track(ctx context.Context, sessionID string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.next++
+	id := s.next
+	if s.running[sessionID] == nil {
+		s.running[sessionID] = make(map[int]context.CancelFunc)
+	}
+	s.running[sessionID][id] = cancel
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		delete(s.running[sessionID], id)
+		if len(s.running[sessionID]) == 0 {
+			delete(s.running, sessionID)
+		}
+		s.mu.Unlock()
+		cancel()
+	}
+}
+
+// Stop cancels every stream running for sessionID, reporting whether there were any
+func (s *Stops) Stop(sessionID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cancel := range s.running[sessionID] {
+		cancel()
+	}
+	return len(s.running[sessionID]) > 0
+}
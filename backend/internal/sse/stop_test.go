@@ -0,0 +1,49 @@
+package sse
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStopsCancelsTrackedStreams(t *testing.T) {
+	stops := NewStops()
+	if stops.Stop("s1") {
+		t.Error("Stop reported a stream for a session with none running")
+	}
+
+	first, doneFirst := stops.Track(context.Background(), "s1")
+	second, doneSecond := stops.Track(context.Background(), "s1")
+	other, doneOther := stops.Track(context.Background(), "s2")
+	defer doneOther()
+
+	if !stops.Stop("s1") {
+		t.Fatal("Stop found no stream for s1")
+	}
+	for i, ctx := range []context.Context{first, second} {
+		if ctx.Err() == nil {
+			t.Errorf("stream %d of s1 was not cancelled", i+1)
+		}
+	}
+	if other.Err() != nil {
+		t.Error("stopping s1 cancelled a stream of s2")
+	}
+
+	// Finished streams are no longer tracked
+	doneFirst()
+	doneSecond()
+	if stops.Stop("s1") {
+		t.Error("Stop reported streams that had already finished")
+	}
+}
+
+func TestStopsDoneCancels(t *testing.T) {
+	stops := NewStops()
+	ctx, done := stops.Track(context.Background(), "s1")
+	done()
+	if ctx.Err() == nil {
+		t.Error("done left the stream's context running")
+	}
+	if stops.Stop("s1") {
+		t.Error("Stop reported a stream after done")
+	}
+}
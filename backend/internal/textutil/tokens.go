@@ -0,0 +1,58 @@
+package textutil
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/language"
+	"golang.org/x/text/unicode/norm"
+)
+
+// FoldForSearch normalizes text for case-insensitive matching: NFC composition, then Unicode
+// lowercasing without language-specific rules, so text folds the same whatever its locale.
+// The combining dot left behind by lowercasing a Turkish dotted capital I ("İ") is dropped
+// so "İstanbul" and "istanbul" fold alike.
+func FoldForSearch(s string) string {
+	folded := cases.Lower(language.Und).String(norm.NFC.String(s))
+	return norm.NFC.String(strings.ReplaceAll(folded, "i\u0307", "i"))
+}
+
+// SearchTokens splits text into unique, folded search tokens. Words are runs of letters,
+// digits and the marks attached to them, so emoji, punctuation and symbols separate words and never end up in a
+// token; an apostrophe between letters stays inside the word ("don't").
+func SearchTokens(s string) []string {
+	runes := []rune(FoldForSearch(s))
+	tokens := []string{}
+	seen := map[string]bool{}
+	var word []rune
+
+	flush := func() {
+		if len(word) > 0 && !seen[string(word)] {
+			seen[string(word)] = true
+			tokens = append(tokens, string(word))
+		}
+		word = word[:0]
+	}
+
+	for i, r := range runes {
+		switch {
+		case unicode.Is(unicode.Variation_Selector, r):
+			// Emoji presentation selectors (U+FE0F) are marks but never part of a word
+			continue
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || (unicode.IsMark(r) && len(word) > 0):
+			word = append(word, r)
+		case isApostrophe(r) && len(word) > 0 && i+1 < len(runes) && unicode.IsLetter(runes[i+1]):
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’'
+}
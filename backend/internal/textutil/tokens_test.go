@@ -0,0 +1,49 @@
+package textutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		// Folding is language-neutral, so a dotless capital I lowers to a dotted i
+		{"turkish dotted capitals fold", "İstanbul'da Işık Koçu", []string{"istanbul'da", "işık", "koçu"}},
+		{"emoji separate words and are dropped", "Focus🔥Sprint 🚀 Coach", []string{"focus", "sprint", "coach"}},
+		{"duplicates collapse after folding", "Sleep SLEEP sleep better", []string{"sleep", "better"}},
+		{"punctuation splits", "run/walk, 5k-plan!", []string{"run", "walk", "5k", "plan"}},
+		{"decomposed accents compose", "Cafe\u0301 Cre\u0300me", []string{"café", "crème"}},
+		{"apostrophe only inside words", "'don't' rock'n", []string{"don't", "rock'n"}},
+		{"emoji presentation selectors are dropped", "Odak ⏱️ Saati ❤️", []string{"odak", "saati"}},
+		{"zwj sequences are dropped", "Run 🏃‍♀️Club", []string{"run", "club"}},
+		{"only emoji yields nothing", "🔥🚀 ✨", []string{}},
+		{"empty", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SearchTokens(tt.in); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchTokens(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFoldForSearch(t *testing.T) {
+	tests := map[string]string{
+		"İSTANBUL":   "istanbul",
+		"ISTANBUL":   "istanbul",
+		"ÇAĞRI":      "çağri",
+		"Cafe\u0301": "café",
+		"Focus 🔥":    "focus 🔥",
+	}
+	for in, want := range tests {
+		if got := FoldForSearch(in); got != want {
+			t.Errorf("FoldForSearch(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package textutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ellipsis is appended to truncated text
+const ellipsis = "…"
+
+// TruncateSafe shortens s to at most maxRunes runes without splitting a code point.
+// It prefers cutting on a word boundary and closes markdown left dangling by the cut
+// (code fences, inline code, bold, strikethrough) so snippets still render cleanly.
+func TruncateSafe(s string, maxRunes int) string {
+	if maxRunes <= 0 {
+		return ""
+	}
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+
+	runes := []rune(s)
+	budget := maxRunes - utf8.RuneCountInString(ellipsis)
+
+	// Closing markers count against the budget, so shrink until everything fits
+	for budget > 0 {
+		cut := cutAtWordBoundary(runes[:budget])
+		closers := markdownClosers(cut)
+		if utf8.RuneCountInString(cut)+utf8.RuneCountInString(ellipsis)+utf8.RuneCountInString(closers) <= maxRunes {
+			return cut + ellipsis + closers
+		}
+		budget -= utf8.RuneCountInString(closers)
+	}
+
+	return string(runes[:maxRunes])
+}
+
+// cutAtWordBoundary backs off to the last whitespace when one is reasonably close to the end
+func cutAtWordBoundary(runes []rune) string {
+	minKeep := len(runes) * 4 / 5
+	for i := len(runes) - 1; i >= minKeep; i-- {
+		if unicode.IsSpace(runes[i]) {
+			return strings.TrimRightFunc(string(runes[:i]), trimmable)
+		}
+	}
+	return strings.TrimRightFunc(string(runes), trimmable)
+}
+
+func trimmable(r rune) bool {
+	return unicode.IsSpace(r) || r == ',' || r == ';' || r == ':'
+}
+
+// markdownClosers returns the markers needed to close markdown opened but not closed in s
+func markdownClosers(s string) string {
+	var closers strings.Builder
+
+	// Code fences: everything after an unclosed fence is code, so close it and stop
+	if strings.Count(s, "```")%2 == 1 {
+		closers.WriteString("\n```")
+		return closers.String()
+	}
+
+	// Ignore fenced blocks when counting inline markers
+	inline := stripFencedBlocks(s)
+
+	if strings.Count(inline, "`")%2 == 1 {
+		closers.WriteString("`")
+		return closers.String()
+	}
+	if strings.Count(inline, "**")%2 == 1 {
+		closers.WriteString("**")
+	}
+	if strings.Count(inline, "~~")%2 == 1 {
+		closers.WriteString("~~")
+	}
+
+	return closers.String()
+}
+
+// stripFencedBlocks removes complete ``` fenced blocks from s
+func stripFencedBlocks(s string) string {
+	parts := strings.Split(s, "```")
+	var out strings.Builder
+	for i, part := range parts {
+		if i%2 == 0 {
+			out.WriteString(part)
+		}
+	}
+	return out.String()
+}
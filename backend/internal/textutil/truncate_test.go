@@ -0,0 +1,51 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncateSafe(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want string
+	}{
+		{"turkish on a word boundary", "Günaydın! Bugün çok güzel bir gün", 12, "Günaydın!…"},
+		{"emoji are never split", "🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥", 5, "🔥🔥🔥🔥…"},
+		{"cjk without spaces", "日本語のテキストを切り詰める", 6, "日本語のテ…"},
+		{"accents stay whole", "Café crème brûlée", 6, "Café…"},
+		{"bold is closed", "Run **three times** a week and stretch", 18, "Run **three tim…**"},
+		{"inline code is closed", "Use `git rebase --interactive` to tidy commits", 15, "Use `git reba…`"},
+		{"code fence is closed", "Steps:\n```\ngo test ./...\ngo vet ./...\n```", 20, "Steps:\n```\ngo…\n```"},
+		{"strikethrough is closed", "~~old plan~~ new plan here", 8, "~~old…~~"},
+		{"short text unchanged", "short", 10, "short"},
+		{"exact fit unchanged", "çğış", 4, "çğış"},
+		{"no room for the ellipsis", "abcdef", 1, "a"},
+		{"zero", "abc", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateSafe(tt.in, tt.max)
+			if got != tt.want {
+				t.Errorf("TruncateSafe(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateSafeNeverSplitsCodePoints(t *testing.T) {
+	// Mixed widths: 1-byte ASCII, 2-byte Turkish and accented, 3-byte CJK and 4-byte emoji
+	s := strings.Repeat("a ı 語 🚀 é ", 20)
+	for max := 1; max <= utf8.RuneCountInString(s); max++ {
+		got := TruncateSafe(s, max)
+		if !utf8.ValidString(got) {
+			t.Fatalf("TruncateSafe(_, %d) = %q is not valid UTF-8", max, got)
+		}
+		if n := utf8.RuneCountInString(got); n > max {
+			t.Fatalf("TruncateSafe(_, %d) has %d runes", max, n)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"simon-backend/internal/models"
+)
+
+// MaxAlarmLeadMinutes is the longest an alarm may fire before its event (four weeks)
+const MaxAlarmLeadMinutes = 4 * 7 * 24 * 60
+
+// Alarm kinds stored on calendar events and reminders
+const (
+	AlarmKindAtDatetime    = "at_datetime"
+	AlarmKindMinutesBefore = "minutes_before"
+)
+
+// NormalizeAlarms converts a tool input's alarms into stored alarms. Each item is either the tool
+// schema shape ({lead_minutes}) or an explicit {kind, minutes_before | fire_at_iso}. Lead times
+// must be whole minutes between 0 and MaxAlarmLeadMinutes; fire times must be RFC3339.
+func NormalizeAlarms(input map[string]interface{}) ([]models.EventAlarm, error) {
+	value, ok := input["alarms"]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	raw, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("alarms must be an array")
+	}
+
+	alarms := make([]models.EventAlarm, 0, len(raw))
+	for i, item := range raw {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("alarms[%d] must be an object", i)
+		}
+		alarm, err := normalizeAlarm(fields)
+		if err != nil {
+			return nil, fmt.Errorf("alarms[%d]: %w", i, err)
+		}
+		alarms = append(alarms, alarm)
+	}
+	return alarms, nil
+}
+
+// normalizeAlarm validates one alarm item and converts it to an EventAlarm
+func normalizeAlarm(fields map[string]interface{}) (models.EventAlarm, error) {
+	kind, _ := fields["kind"].(string)
+	if kind == "" {
+		if _, ok := fields["fire_at_iso"]; ok {
+			kind = AlarmKindAtDatetime
+		} else {
+			kind = AlarmKindMinutesBefore
+		}
+	}
+
+	switch kind {
+	case AlarmKindMinutesBefore:
+		key := "lead_minutes"
+		if _, ok := fields[key]; !ok {
+			key = "minutes_before"
+		}
+		minutes, err := leadMinutes(fields[key])
+		if err != nil {
+			return models.EventAlarm{}, fmt.Errorf("%s %w", key, err)
+		}
+		return models.EventAlarm{Kind: AlarmKindMinutesBefore, MinutesBefore: minutes}, nil
+
+	case AlarmKindAtDatetime:
+		fireAt, _ := fields["fire_at_iso"].(string)
+		if _, err := time.Parse(time.RFC3339, fireAt); err != nil {
+			return models.EventAlarm{}, fmt.Errorf("fire_at_iso must be an RFC3339 timestamp (e.g. 2025-01-15T15:00:00Z), got %q", fireAt)
+		}
+		return models.EventAlarm{Kind: AlarmKindAtDatetime, FireAtISO: fireAt}, nil
+
+	default:
+		return models.EventAlarm{}, fmt.Errorf("unknown alarm kind: %s", kind)
+	}
+}
+
+// leadMinutes reads a lead time in whole minutes (JSON numbers decode as float64)
+func leadMinutes(value interface{}) (int, error) {
+	var minutes float64
+	switch n := value.(type) {
+	case float64:
+		minutes = n
+	case int:
+		minutes = float64(n)
+	case int64:
+		minutes = float64(n)
+	case nil:
+		return 0, fmt.Errorf("is required")
+	default:
+		return 0, fmt.Errorf("must be a number")
+	}
+
+	if minutes != math.Trunc(minutes) {
+		return 0, fmt.Errorf("must be a whole number of minutes")
+	}
+	if minutes < 0 || minutes > MaxAlarmLeadMinutes {
+		return 0, fmt.Errorf("must be between 0 and %d", MaxAlarmLeadMinutes)
+	}
+	return int(minutes), nil
+}
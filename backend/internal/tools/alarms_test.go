@@ -0,0 +1,74 @@
+package tools
+
+import (
+	"testing"
+
+	"simon-backend/internal/models"
+)
+
+func TestNormalizeAlarms(t *testing.T) {
+	alarms, err := NormalizeAlarms(map[string]interface{}{
+		"alarms": []interface{}{
+			map[string]interface{}{"lead_minutes": float64(10)},
+			map[string]interface{}{"kind": "minutes_before", "minutes_before": float64(0)},
+			map[string]interface{}{"fire_at_iso": "2026-04-15T08:30:00+03:00"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.EventAlarm{
+		{Kind: AlarmKindMinutesBefore, MinutesBefore: 10},
+		{Kind: AlarmKindMinutesBefore, MinutesBefore: 0},
+		{Kind: AlarmKindAtDatetime, FireAtISO: "2026-04-15T08:30:00+03:00"},
+	}
+	if len(alarms) != len(want) {
+		t.Fatalf("alarms = %+v, want %+v", alarms, want)
+	}
+	for i := range want {
+		if alarms[i] != want[i] {
+			t.Errorf("alarms[%d] = %+v, want %+v", i, alarms[i], want[i])
+		}
+	}
+
+	if alarms, err := NormalizeAlarms(map[string]interface{}{"title": "No alarms"}); err != nil || alarms != nil {
+		t.Errorf("without alarms: %v (err %v), want none", alarms, err)
+	}
+}
+
+func TestNormalizeAlarmsRejects(t *testing.T) {
+	tests := map[string]interface{}{
+		"negative lead":        []interface{}{map[string]interface{}{"lead_minutes": float64(-5)}},
+		"lead over four weeks": []interface{}{map[string]interface{}{"lead_minutes": float64(MaxAlarmLeadMinutes + 1)}},
+		"fractional lead":      []interface{}{map[string]interface{}{"lead_minutes": 2.5}},
+		"lead as text":         []interface{}{map[string]interface{}{"lead_minutes": "10"}},
+		"missing lead":         []interface{}{map[string]interface{}{"kind": "minutes_before"}},
+		"free-text fire time":  []interface{}{map[string]interface{}{"fire_at_iso": "tomorrow at 9"}},
+		"unknown kind":         []interface{}{map[string]interface{}{"kind": "on_arrival"}},
+		"item not an object":   []interface{}{float64(10)},
+		"alarms not an array":  map[string]interface{}{"lead_minutes": float64(10)},
+	}
+	for name, alarms := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got, err := NormalizeAlarms(map[string]interface{}{"alarms": alarms}); err == nil {
+				t.Errorf("accepted %v as %+v", alarms, got)
+			}
+		})
+	}
+}
+
+func TestValidateInputRejectsNegativeAlarm(t *testing.T) {
+	input := map[string]interface{}{
+		"title":           "Dentist",
+		"idempotency_key": "k1",
+		"alarms":          []interface{}{map[string]interface{}{"lead_minutes": float64(-10)}},
+	}
+	if err := NewRegistry().ValidateInput("reminder_create", input); err == nil {
+		t.Error("reminder_create accepted a negative lead time")
+	}
+
+	input["alarms"] = []interface{}{map[string]interface{}{"lead_minutes": float64(10)}}
+	if err := NewRegistry().ValidateInput("reminder_create", input); err != nil {
+		t.Errorf("reminder_create rejected a 10 minute lead: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"simon-backend/internal/models"
+)
+
+// checkinRunPageSize bounds how many due check-ins are processed per query page
+const checkinRunPageSize = 200
+
+// defaultNudges rotate for coaches without a QuickNudge template
+var defaultNudges = []string{
+	"Quick check-in: what's the one thing you want to move forward today?",
+	"How's it going? What's the smallest next step you could take right now?",
+	"Checking in. Anything getting in the way that we should talk through?",
+}
+
+// CheckinRunResult summarizes one executor pass
+type CheckinRunResult struct {
+	Scanned    int `json:"scanned"`
+	Delivered  int `json:"delivered"`
+	Suppressed int `json:"suppressed"`
+}
+
+// errCheckinNotDue aborts a run transaction when another executor already advanced the check-in
+var errCheckinNotDue = errors.New("checkin no longer due")
+
+// RunDue fires every active check-in whose next run has passed. A nudge is skipped, and the
+// check-in simply rescheduled, while the previous nudge is unacknowledged and younger than cooldown.
+func (s *CheckinService) RunDue(ctx context.Context, now time.Time, cooldown time.Duration) (CheckinRunResult, error) {
+	var result CheckinRunResult
+	templates := map[string][]string{}
+	var lastDoc *firestore.DocumentSnapshot
+
+	for {
+		query := s.fs.Collection("checkins").
+			Where("status", "==", "active").
+			Where("next_run_at", "<=", now).
+			OrderBy("next_run_at", firestore.Asc).
+			Limit(checkinRunPageSize)
+		if lastDoc != nil {
+			query = query.StartAfter(lastDoc)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return result, fmt.Errorf("failed to query due checkins: %w", err)
+		}
+		if len(docs) == 0 {
+			return result, nil
+		}
+
+		for _, doc := range docs {
+			result.Scanned++
+
+			var checkin models.Checkin
+			if err := doc.DataTo(&checkin); err != nil {
+				log.Printf("Error parsing checkin %s: %v", doc.Ref.ID, err)
+				continue
+			}
+
+			template, ok := templates[checkin.CoachID]
+			if !ok {
+				template = s.nudgeTemplate(ctx, checkin.CoachID)
+				templates[checkin.CoachID] = template
+			}
+
+			delivered, err := s.runCheckin(ctx, doc.Ref, template, now, cooldown)
+			switch {
+			case errors.Is(err, errCheckinNotDue):
+			case err != nil:
+				log.Printf("Error running checkin %s: %v", doc.Ref.ID, err)
+			case delivered:
+				result.Delivered++
+			default:
+				result.Suppressed++
+			}
+		}
+
+		if len(docs) < checkinRunPageSize {
+			return result, nil
+		}
+		lastDoc = docs[len(docs)-1]
+	}
+}
+
+// runCheckin delivers (or suppresses) one nudge and schedules the next run. It runs in a
+// transaction so overlapping executor passes can't deliver the same run twice.
+func (s *CheckinService) runCheckin(ctx context.Context, ref *firestore.DocumentRef, template []string, now time.Time, cooldown time.Duration) (bool, error) {
+	delivered := false
+
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		delivered = false
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		var checkin models.Checkin
+		if err := doc.DataTo(&checkin); err != nil {
+			return err
+		}
+		if checkin.Status != "active" || checkin.NextRunAt.After(now) {
+			return errCheckinNotDue
+		}
+
+		prefs := UserPreferences(ctx, s.fs, checkin.UID)
+		updates := []firestore.Update{
+			{Path: "next_run_at", Value: prefs.ApplyQuietHours(s.calculateNextRun(checkin.Cadence, now, prefs.Location()))},
+			{Path: "updated_at", Value: now},
+		}
+
+		if inCheckinCooldown(checkin, now, cooldown) {
+			return tx.Update(ref, updates)
+		}
+
+		text, nextIndex := nextNudge(template, checkin.NudgeIndex, checkin.LastNudge)
+		nudgeRef := s.fs.Collection("users").Doc(checkin.UID).Collection("nudges").NewDoc()
+		if err := tx.Create(nudgeRef, models.Nudge{
+			ID:        nudgeRef.ID,
+			CheckinID: checkin.ID,
+			CoachID:   checkin.CoachID,
+			Channel:   checkin.Channel,
+			Text:      text,
+			CreatedAt: now,
+		}); err != nil {
+			return err
+		}
+
+		updates = append(updates,
+			firestore.Update{Path: "last_run_at", Value: now},
+			firestore.Update{Path: "last_nudge", Value: text},
+			firestore.Update{Path: "nudge_index", Value: nextIndex},
+		)
+		delivered = true
+		return tx.Update(ref, updates)
+	})
+
+	return delivered, err
+}
+
+// inCheckinCooldown reports whether the last nudge is still unacknowledged and recent
+func inCheckinCooldown(checkin models.Checkin, now time.Time, cooldown time.Duration) bool {
+	if checkin.LastRunAt == nil || cooldown <= 0 {
+		return false
+	}
+	acknowledged := checkin.AcknowledgedAt != nil && !checkin.AcknowledgedAt.Before(*checkin.LastRunAt)
+	return !acknowledged && now.Sub(*checkin.LastRunAt) < cooldown
+}
+
+// nextNudge picks the nudge at index in the rotation, skipping an exact repeat of the last one,
+// and returns the index to use next time
+func nextNudge(template []string, index int, last string) (string, int) {
+	if len(template) == 0 {
+		template = defaultNudges
+	}
+	if index < 0 {
+		index = 0
+	}
+
+	text := template[index%len(template)]
+	if text == last && len(template) > 1 {
+		index++
+		text = template[index%len(template)]
+	}
+	return text, (index + 1) % len(template)
+}
+
+// nudgeTemplate loads the coach's QuickNudge template, or nil if it has none
+func (s *CheckinService) nudgeTemplate(ctx context.Context, coachID string) []string {
+	if coachID == "" {
+		return nil
+	}
+
+	doc, err := s.fs.Collection("coaches").Doc(coachID).Get(ctx)
+	if err != nil {
+		return nil
+	}
+
+	var coach models.Coach
+	if err := doc.DataTo(&coach); err != nil || coach.CoachSpec == nil {
+		return nil
+	}
+	return coach.CoachSpec.Methods.DefaultProtocols.QuickNudge.Template
+}
+
+// Acknowledge records that the user has seen the check-in's latest nudge
+func (s *CheckinService) Acknowledge(ctx context.Context, uid, checkinID string) error {
+	checkinDoc, err := s.fs.Collection("checkins").Doc(checkinID).Get(ctx)
+	if err != nil {
+		return lookupError("checkin", err)
+	}
+
+	var checkin models.Checkin
+	if err := checkinDoc.DataTo(&checkin); err != nil {
+		return fmt.Errorf("failed to parse checkin: %w", err)
+	}
+
+	if checkin.UID != uid {
+		return fmt.Errorf("%w: checkin belongs to different user", ErrUnauthorized)
+	}
+
+	now := models.Now()
+	if _, err := checkinDoc.Ref.Update(ctx, []firestore.Update{
+		{Path: "acknowledged_at", Value: now},
+		{Path: "updated_at", Value: now},
+	}); err != nil {
+		return fmt.Errorf("failed to acknowledge checkin: %w", err)
+	}
+
+	return nil
+}
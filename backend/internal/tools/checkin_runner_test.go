@@ -0,0 +1,149 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestNextNudge(t *testing.T) {
+	template := []string{"a", "b", "c"}
+	tests := []struct {
+		name      string
+		template  []string
+		index     int
+		last      string
+		want      string
+		wantIndex int
+	}{
+		{"first run", template, 0, "", "a", 1},
+		{"rotates", template, 1, "a", "b", 2},
+		{"wraps", template, 2, "b", "c", 0},
+		{"skips an exact repeat", template, 1, "b", "c", 0},
+		{"single template may repeat", []string{"a"}, 0, "a", "a", 0},
+		{"no template uses defaults", nil, 0, "", defaultNudges[0], 1},
+		{"negative index", template, -3, "", "a", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, next := nextNudge(tt.template, tt.index, tt.last)
+			if text != tt.want || next != tt.wantIndex {
+				t.Errorf("nextNudge = %q, %d; want %q, %d", text, next, tt.want, tt.wantIndex)
+			}
+		})
+	}
+}
+
+func TestInCheckinCooldown(t *testing.T) {
+	lastRun := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+	before, after := lastRun.Add(-time.Hour), lastRun.Add(time.Hour)
+	tests := []struct {
+		name     string
+		checkin  models.Checkin
+		now      time.Time
+		cooldown time.Duration
+		want     bool
+	}{
+		{"never run", models.Checkin{}, lastRun, 12 * time.Hour, false},
+		{"unacknowledged and recent", models.Checkin{LastRunAt: &lastRun}, lastRun.Add(6 * time.Hour), 12 * time.Hour, true},
+		{"acknowledged an older nudge", models.Checkin{LastRunAt: &lastRun, AcknowledgedAt: &before}, lastRun.Add(6 * time.Hour), 12 * time.Hour, true},
+		{"acknowledged", models.Checkin{LastRunAt: &lastRun, AcknowledgedAt: &after}, lastRun.Add(6 * time.Hour), 12 * time.Hour, false},
+		{"cooldown passed", models.Checkin{LastRunAt: &lastRun}, lastRun.Add(12 * time.Hour), 12 * time.Hour, false},
+		{"cooldown disabled", models.Checkin{LastRunAt: &lastRun}, lastRun.Add(time.Minute), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inCheckinCooldown(tt.checkin, tt.now, tt.cooldown); got != tt.want {
+				t.Errorf("inCheckinCooldown = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunDueCooldownAndRotation(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	svc := NewCheckinService(fs.DB)
+	template := []string{"How did the run go?", "Shoes on yet?", "One lap counts."}
+	coachSpec := &models.CoachSpec{}
+	coachSpec.Methods.DefaultProtocols.QuickNudge.Template = template
+	if _, err := fs.DB.Collection("coaches").Doc("pace").Set(ctx, models.Coach{ID: "pace", CoachSpec: coachSpec}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+	if _, err := fs.DB.Collection("checkins").Doc("c1").Set(ctx, models.Checkin{
+		ID:        "c1",
+		UID:       "u1",
+		CoachID:   "pace",
+		Cadence:   models.CheckinCadence{Kind: "daily", Hour: 9},
+		Channel:   "in_app",
+		NextRunAt: start,
+		Status:    "active",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	const cooldown = 36 * time.Hour
+
+	run := func(now time.Time) CheckinRunResult {
+		t.Helper()
+		result, err := svc.RunDue(ctx, now, cooldown)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	acknowledge := func() {
+		t.Helper()
+		if err := svc.Acknowledge(ctx, "u1", "c1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := run(start); got.Delivered != 1 {
+		t.Fatalf("first run = %+v, want one delivery", got)
+	}
+	// A back-to-back pass finds nothing due
+	if got := run(start); got.Delivered != 0 || got.Scanned != 0 {
+		t.Errorf("back-to-back run = %+v, want nothing delivered", got)
+	}
+	// The next day's nudge is held back while the first is unacknowledged
+	if got := run(start.Add(24 * time.Hour)); got.Delivered != 0 || got.Suppressed != 1 {
+		t.Errorf("run in cooldown = %+v, want the nudge suppressed", got)
+	}
+	if next := getCheckin(t, svc, "c1").NextRunAt; !next.Equal(start.Add(48 * time.Hour)) {
+		t.Errorf("next run = %s, want a suppressed check-in still rescheduled", next)
+	}
+
+	for day := 2; day <= 4; day++ {
+		acknowledge()
+		if got := run(start.Add(time.Duration(day) * 24 * time.Hour)); got.Delivered != 1 {
+			t.Fatalf("day %d run = %+v, want a delivery once acknowledged", day, got)
+		}
+	}
+
+	docs, err := fs.DB.Collection("users").Doc("u1").Collection("nudges").OrderBy("created_at", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var texts []string
+	for _, doc := range docs {
+		var nudge models.Nudge
+		if err := doc.DataTo(&nudge); err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, nudge.Text)
+	}
+	want := []string{template[0], template[1], template[2], template[0]}
+	if !slices.Equal(texts, want) {
+		t.Errorf("nudges = %q, want the template rotated", texts)
+	}
+	if checkin := getCheckin(t, svc, "c1"); checkin.LastNudge != template[0] || checkin.LastRunAt == nil || !checkin.LastRunAt.Equal(start.Add(96*time.Hour)) {
+		t.Errorf("checkin = %+v, want the last delivery tracked", checkin)
+	}
+}
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -58,15 +59,8 @@ type CheckinUpdateResponse struct {
 
 // Schedule creates a new check-in schedule
 func (s *CheckinService) Schedule(ctx context.Context, req CheckinScheduleRequest) (*CheckinScheduleResponse, error) {
-	// Validate cadence
-	validKinds := map[string]bool{
-		"daily":       true,
-		"weekdays":    true,
-		"weekly":      true,
-		"custom_cron": true,
-	}
-	if !validKinds[req.Cadence.Kind] {
-		return nil, fmt.Errorf("invalid cadence kind: %s", req.Cadence.Kind)
+	if err := validateCadence(req.Cadence); err != nil {
+		return nil, err
 	}
 
 	// Validate channel
@@ -75,23 +69,16 @@ func (s *CheckinService) Schedule(ctx context.Context, req CheckinScheduleReques
 		"local_notification_proposal":  true,
 	}
 	if !validChannels[req.Channel] {
-		return nil, fmt.Errorf("invalid channel: %s", req.Channel)
-	}
-
-	// Validate hour and minute
-	if req.Cadence.Hour < 0 || req.Cadence.Hour > 23 {
-		return nil, fmt.Errorf("invalid hour: %d (must be 0-23)", req.Cadence.Hour)
-	}
-	if req.Cadence.Minute < 0 || req.Cadence.Minute > 59 {
-		return nil, fmt.Errorf("invalid minute: %d (must be 0-59)", req.Cadence.Minute)
+		return nil, invalidf("invalid channel: %s", req.Channel)
 	}
 
 	// Generate checkin ID
 	checkinRef := s.fs.Collection("checkins").NewDoc()
 	checkinID := checkinRef.ID
 
-	// Calculate next run time
-	nextRunAt := s.calculateNextRun(req.Cadence, time.Now())
+	// Calculate next run time in the user's timezone, outside quiet hours
+	prefs := UserPreferences(ctx, s.fs, req.UID)
+	nextRunAt := prefs.ApplyQuietHours(s.calculateNextRun(req.Cadence, time.Now(), prefs.Location()))
 
 	// Create checkin document
 	checkin := models.Checkin{
@@ -157,7 +144,7 @@ func (s *CheckinService) Update(ctx context.Context, req CheckinUpdateRequest) (
 	// Verify checkin ownership
 	checkinDoc, err := s.fs.Collection("checkins").Doc(req.CheckinID).Get(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("checkin not found: %w", err)
+		return nil, lookupError("checkin", err)
 	}
 
 	var checkin models.Checkin
@@ -166,7 +153,7 @@ func (s *CheckinService) Update(ctx context.Context, req CheckinUpdateRequest) (
 	}
 
 	if checkin.UID != req.UID {
-		return nil, fmt.Errorf("unauthorized: checkin belongs to different user")
+		return nil, fmt.Errorf("%w: checkin belongs to different user", ErrUnauthorized)
 	}
 
 	// Build Firestore updates
@@ -179,6 +166,28 @@ func (s *CheckinService) Update(ctx context.Context, req CheckinUpdateRequest) (
 
 	// Add user-provided updates
 	for key, value := range req.Updates {
+		if err := checkUpdatePath("checkin", key, checkinUpdatablePaths); err != nil {
+			return nil, err
+		}
+		if key == "status" {
+			if status, _ := value.(string); status != "active" && status != "paused" {
+				return nil, invalidf("invalid checkin status: %v", value)
+			}
+		}
+		if key == "cadence" {
+			// A new cadence moves the next run in the same write, so it never fires on the old one
+			cadence, err := decodeCadence(value)
+			if err != nil {
+				return nil, err
+			}
+			prefs := UserPreferences(ctx, s.fs, req.UID)
+			nextRunAt := prefs.ApplyQuietHours(s.calculateNextRun(cadence, time.Now(), prefs.Location()))
+			updates = append(updates,
+				firestore.Update{Path: "cadence", Value: cadence},
+				firestore.Update{Path: "next_run_at", Value: nextRunAt},
+			)
+			continue
+		}
 		updates = append(updates, firestore.Update{
 			Path:  key,
 			Value: value,
@@ -200,7 +209,7 @@ func (s *CheckinService) Delete(ctx context.Context, uid, checkinID string) erro
 	// Verify checkin ownership
 	checkinDoc, err := s.fs.Collection("checkins").Doc(checkinID).Get(ctx)
 	if err != nil {
-		return fmt.Errorf("checkin not found: %w", err)
+		return lookupError("checkin", err)
 	}
 
 	var checkin models.Checkin
@@ -209,7 +218,7 @@ func (s *CheckinService) Delete(ctx context.Context, uid, checkinID string) erro
 	}
 
 	if checkin.UID != uid {
-		return fmt.Errorf("unauthorized: checkin belongs to different user")
+		return fmt.Errorf("%w: checkin belongs to different user", ErrUnauthorized)
 	}
 
 	// Soft delete by setting status to deleted
@@ -231,17 +240,74 @@ func (s *CheckinService) Delete(ctx context.Context, uid, checkinID string) erro
 	return nil
 }
 
-// calculateNextRun calculates the next run time based on cadence
-func (s *CheckinService) calculateNextRun(cadence models.CheckinCadence, from time.Time) time.Time {
-	// Get user's timezone (default to UTC for now)
-	loc := time.UTC
+// validateCadence checks a cadence's kind, time of day and, for custom_cron, its expression
+func validateCadence(cadence models.CheckinCadence) error {
+	validKinds := map[string]bool{
+		"daily":       true,
+		"weekdays":    true,
+		"weekly":      true,
+		"custom_cron": true,
+	}
+	if !validKinds[cadence.Kind] {
+		return invalidf("invalid cadence kind: %s", cadence.Kind)
+	}
+	if cadence.Kind == "custom_cron" {
+		schedule, err := parseCron(cadence.Cron)
+		if err != nil {
+			return invalidf("invalid cron expression %q: %v", cadence.Cron, err)
+		}
+		if _, ok := schedule.next(time.Now()); !ok {
+			return invalidf("invalid cron expression %q: never runs", cadence.Cron)
+		}
+	}
+
+	// Validate hour and minute
+	if cadence.Hour < 0 || cadence.Hour > 23 {
+		return invalidf("invalid hour: %d (must be 0-23)", cadence.Hour)
+	}
+	if cadence.Minute < 0 || cadence.Minute > 59 {
+		return invalidf("invalid minute: %d (must be 0-59)", cadence.Minute)
+	}
+	return nil
+}
+
+// decodeCadence reads a cadence from an update value (decoded JSON) and validates it
+func decodeCadence(value interface{}) (models.CheckinCadence, error) {
+	var cadence models.CheckinCadence
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return cadence, invalidf("invalid cadence: %v", err)
+	}
+	if err := json.Unmarshal(raw, &cadence); err != nil {
+		return cadence, invalidf("invalid cadence: %v", err)
+	}
+	return cadence, validateCadence(cadence)
+}
+
+// UserPreferences loads a user's preferences, falling back to defaults if unavailable
+func UserPreferences(ctx context.Context, fs *firestore.Client, uid string) models.Preferences {
+	doc, err := fs.Collection("users").Doc(uid).Get(ctx)
+	if err != nil {
+		return models.Preferences{}
+	}
 
+	var user models.User
+	if err := doc.DataTo(&user); err != nil {
+		return models.Preferences{}
+	}
+
+	return user.Preferences
+}
+
+// calculateNextRun calculates the next run time based on cadence in the given timezone
+func (s *CheckinService) calculateNextRun(cadence models.CheckinCadence, from time.Time, loc *time.Location) time.Time {
 	// Start with today at the specified time
 	now := from.In(loc)
 	nextRun := time.Date(now.Year(), now.Month(), now.Day(), cadence.Hour, cadence.Minute, 0, 0, loc)
 
-	// If the time has already passed today, start from tomorrow
-	if nextRun.Before(now) {
+	// If the time has already passed (or is now) today, start from tomorrow; a run executing
+	// exactly on its slot must not schedule that same slot again
+	if !nextRun.After(now) {
 		nextRun = nextRun.AddDate(0, 0, 1)
 	}
 
@@ -281,8 +347,13 @@ func (s *CheckinService) calculateNextRun(cadence models.CheckinCadence, from ti
 		}
 
 	case "custom_cron":
-		// TODO: Implement cron parsing
-		// For now, default to daily
+		// The cron expression carries its own time of day; Hour/Minute are ignored.
+		// Malformed expressions are rejected at Schedule time, so fall back to daily here.
+		if schedule, err := parseCron(cadence.Cron); err == nil {
+			if cronRun, ok := schedule.next(now); ok {
+				nextRun = cronRun
+			}
+		}
 	}
 
 	return nextRun
@@ -0,0 +1,94 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestCheckinUpdateCadenceRecomputesNextRun(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	svc := NewCheckinService(fs.DB)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{
+		UID:         "u1",
+		Preferences: models.Preferences{Timezone: "Europe/Istanbul"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	scheduled, err := svc.Schedule(ctx, CheckinScheduleRequest{
+		UID:     "u1",
+		Cadence: models.CheckinCadence{Kind: "daily", Hour: 9},
+		Channel: "in_app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Weekly retro on Sundays at 18:00, sent as decoded JSON like a client update
+	if _, err := svc.Update(ctx, CheckinUpdateRequest{
+		UID:       "u1",
+		CheckinID: scheduled.CheckinID,
+		Updates: map[string]interface{}{
+			"cadence": map[string]interface{}{"kind": "custom_cron", "cron": "0 18 * * 0"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkin := getCheckin(t, svc, scheduled.CheckinID)
+	if checkin.Cadence.Kind != "custom_cron" || checkin.Cadence.Cron != "0 18 * * 0" {
+		t.Errorf("cadence = %+v, want the cron cadence", checkin.Cadence)
+	}
+	loc, _ := time.LoadLocation("Europe/Istanbul")
+	next := checkin.NextRunAt.In(loc)
+	if next.Weekday() != time.Sunday || next.Hour() != 18 || next.Minute() != 0 {
+		t.Errorf("next_run_at = %s, want a Sunday at 18:00 Istanbul time", next)
+	}
+	if !next.After(time.Now()) || next.After(time.Now().AddDate(0, 0, 7)) {
+		t.Errorf("next_run_at = %s, want within the coming week", next)
+	}
+}
+
+func TestCheckinUpdateRejectsInvalidCadence(t *testing.T) {
+	ctx := context.Background()
+	svc := NewCheckinService(firestoretest.New(t).DB)
+	scheduled, err := svc.Schedule(ctx, CheckinScheduleRequest{
+		UID:     "u1",
+		Cadence: models.CheckinCadence{Kind: "daily", Hour: 9},
+		Channel: "in_app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := getCheckin(t, svc, scheduled.CheckinID)
+
+	for name, cadence := range map[string]interface{}{
+		"malformed cron":    map[string]interface{}{"kind": "custom_cron", "cron": "61 * * * *"},
+		"cron never runs":   map[string]interface{}{"kind": "custom_cron", "cron": "0 0 31 2 *"},
+		"unknown kind":      map[string]interface{}{"kind": "hourly"},
+		"hour out of range": map[string]interface{}{"kind": "daily", "hour": 24},
+		"not an object":     "daily",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := svc.Update(ctx, CheckinUpdateRequest{
+				UID:       "u1",
+				CheckinID: scheduled.CheckinID,
+				Updates:   map[string]interface{}{"cadence": cadence},
+			})
+			if !errors.Is(err, ErrValidation) {
+				t.Fatalf("Update error = %v, want ErrValidation", err)
+			}
+		})
+	}
+
+	after := getCheckin(t, svc, scheduled.CheckinID)
+	if after.Cadence.Kind != before.Cadence.Kind || !after.NextRunAt.Equal(before.NextRunAt) {
+		t.Errorf("rejected updates changed the check-in: %+v -> %+v", before, after)
+	}
+}
@@ -0,0 +1,172 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+	// domAny/dowAny record "*" so day matching follows cron's OR rule when both are restricted
+	domAny bool
+	dowAny bool
+}
+
+// cronField describes the allowed range of one cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7}, // 0 and 7 are both Sunday
+}
+
+// cronSearchDays bounds the search for the next run (covers leap-day schedules)
+const cronSearchDays = 366 * 5
+
+// parseCron parses a standard five-field cron expression, supporting "*", values,
+// ranges ("1-5"), lists ("1,3,5") and steps ("*/15", "9-17/2")
+func parseCron(expr string) (*cronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("expected 5 fields (minute hour day-of-month month day-of-week), got %d", len(parts))
+	}
+
+	sets := make([]map[int]bool, len(cronFields))
+	for i, field := range cronFields {
+		set, err := parseCronField(parts[i], field)
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+
+	// Normalize Sunday to 0
+	if sets[4][7] {
+		sets[4][0] = true
+		delete(sets[4], 7)
+	}
+
+	return &cronSchedule{
+		minutes:     sets[0],
+		hours:       sets[1],
+		daysOfMonth: sets[2],
+		months:      sets[3],
+		daysOfWeek:  sets[4],
+		domAny:      parts[2] == "*",
+		dowAny:      parts[4] == "*",
+	}, nil
+}
+
+// parseCronField expands one comma-separated cron field into the set of values it matches
+func parseCronField(expr string, field cronField) (map[int]bool, error) {
+	set := make(map[int]bool)
+
+	for _, item := range strings.Split(expr, ",") {
+		rangeExpr, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangeExpr = item[:i]
+			parsed, err := strconv.Atoi(item[i+1:])
+			if err != nil || parsed < 1 {
+				return nil, fmt.Errorf("invalid step in %s field: %q", field.name, item)
+			}
+			step = parsed
+		}
+
+		lo, hi := field.min, field.max
+		switch {
+		case rangeExpr == "*":
+		case strings.Contains(rangeExpr, "-"):
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || lo > hi {
+				return nil, fmt.Errorf("invalid range in %s field: %q", field.name, item)
+			}
+		default:
+			value, err := strconv.Atoi(rangeExpr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value in %s field: %q", field.name, item)
+			}
+			lo = value
+			if step == 1 {
+				hi = value
+			}
+		}
+
+		if lo < field.min || hi > field.max {
+			return nil, fmt.Errorf("%s field out of range %d-%d: %q", field.name, field.min, field.max, item)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+// next returns the first matching minute strictly after from, in from's location
+func (cs *cronSchedule) next(from time.Time) (time.Time, bool) {
+	loc := from.Location()
+	start := from.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+
+	for i := 0; i < cronSearchDays; i++ {
+		if cs.matchesDay(day) {
+			for hour := 0; hour < 24; hour++ {
+				if !cs.hours[hour] {
+					continue
+				}
+				for minute := 0; minute < 60; minute++ {
+					if !cs.minutes[minute] {
+						continue
+					}
+					candidate := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+					// Skip times that don't exist on this day (DST gaps normalize to another hour)
+					if candidate.Hour() != hour || candidate.Before(start) {
+						continue
+					}
+					return candidate, true
+				}
+			}
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return time.Time{}, false
+}
+
+// matchesDay applies cron's day rule: when both day fields are restricted, either may match
+func (cs *cronSchedule) matchesDay(day time.Time) bool {
+	if !cs.months[int(day.Month())] {
+		return false
+	}
+
+	domMatch := cs.daysOfMonth[day.Day()]
+	dowMatch := cs.daysOfWeek[int(day.Weekday())]
+
+	switch {
+	case cs.domAny && cs.dowAny:
+		return true
+	case cs.domAny:
+		return dowMatch
+	case cs.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"testing"
+	"time"
+
+	"simon-backend/internal/models"
+)
+
+func TestParseCronRejectsMalformed(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"0 18 * *",
+		"0 18 * * 0 2026",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) accepted a malformed expression", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday 2026-04-15 09:07
+	from := time.Date(2026, 4, 15, 9, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 18 * * 0", time.Date(2026, 4, 19, 18, 0, 0, 0, time.UTC)},
+		{"0 18 * * 7", time.Date(2026, 4, 19, 18, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2026, 4, 15, 9, 15, 0, 0, time.UTC)},
+		{"7 9 * * *", time.Date(2026, 4, 16, 9, 7, 0, 0, time.UTC)},
+		{"0 9-17/4 * * 1-5", time.Date(2026, 4, 15, 13, 0, 0, 0, time.UTC)},
+		{"30 8 1,15 * *", time.Date(2026, 5, 1, 8, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: the 1st of the month or any Friday
+		{"0 12 1 * 5", time.Date(2026, 4, 17, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			schedule, err := parseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := schedule.next(from)
+			if !ok || !got.Equal(tt.want) {
+				t.Errorf("next = %s (ok %v), want %s", got, ok, tt.want)
+			}
+		})
+	}
+
+	schedule, err := parseCron("0 0 31 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := schedule.next(from); ok {
+		t.Errorf("Feb 31 schedule found a next run at %s", got)
+	}
+}
+
+func TestCalculateNextRunCustomCron(t *testing.T) {
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := &CheckinService{}
+	cadence := models.CheckinCadence{Kind: "custom_cron", Cron: "0 18 * * 0", Hour: 9}
+
+	tests := []struct {
+		name string
+		from time.Time
+		want time.Time
+	}{
+		{"earlier in the week", time.Date(2026, 4, 15, 9, 0, 0, 0, istanbul), time.Date(2026, 4, 19, 18, 0, 0, 0, istanbul)},
+		{"Sunday before the retro", time.Date(2026, 4, 19, 17, 59, 0, 0, istanbul), time.Date(2026, 4, 19, 18, 0, 0, 0, istanbul)},
+		{"exactly at the retro", time.Date(2026, 4, 19, 18, 0, 0, 0, istanbul), time.Date(2026, 4, 26, 18, 0, 0, 0, istanbul)},
+		// 16:00 UTC Sunday is already 19:00 in Istanbul, so this week's retro has passed
+		{"past in the user's zone", time.Date(2026, 4, 19, 16, 0, 0, 0, time.UTC), time.Date(2026, 4, 26, 18, 0, 0, 0, istanbul)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := svc.calculateNextRun(cadence, tt.from, istanbul); !got.Equal(tt.want) {
+				t.Errorf("next run = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sentinel errors returned (wrapped) by the tool services so callers can map them to HTTP statuses
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrValidation   = errors.New("invalid input")
+)
+
+// invalidf builds an ErrValidation-wrapping error
+func invalidf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrValidation, fmt.Sprintf(format, args...))
+}
+
+// lookupError wraps a document read failure, classifying missing documents as ErrNotFound
+func lookupError(what string, err error) error {
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("%s %w", what, ErrNotFound)
+	}
+	return fmt.Errorf("failed to get %s: %w", what, err)
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"simon-backend/internal/models"
+)
+
+// getCheckin reads a check-in straight from the store
+func getCheckin(t *testing.T, svc *CheckinService, id string) models.Checkin {
+	t.Helper()
+	doc, err := svc.fs.Collection("checkins").Doc(id).Get(context.Background())
+	if err != nil {
+		t.Fatalf("get checkin: %v", err)
+	}
+	var checkin models.Checkin
+	if err := doc.DataTo(&checkin); err != nil {
+		t.Fatalf("parse checkin: %v", err)
+	}
+	return checkin
+}
+
+// getPlan reads a plan straight from the store
+func getPlan(t *testing.T, svc *PlanService, id string) models.Plan {
+	t.Helper()
+	doc, err := svc.fs.Collection("plans").Doc(id).Get(context.Background())
+	if err != nil {
+		t.Fatalf("get plan %s: %v", id, err)
+	}
+	var plan models.Plan
+	if err := doc.DataTo(&plan); err != nil {
+		t.Fatal(err)
+	}
+	return plan
+}
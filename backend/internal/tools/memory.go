@@ -3,17 +3,24 @@ package tools
 import (
 	"context"
 	"fmt"
+	"log"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"simon-backend/internal/models"
+	"simon-backend/internal/textutil"
 )
 
+// maxSnippetRunes bounds the length of memory hit snippets
+const maxSnippetRunes = 280
+
 // MemoryService handles memory read/write operations
 type MemoryService struct {
-	fs *firestore.Client
+	fs       *firestore.Client
+	embedder Embedder
 }
 
 // NewMemoryService creates a new memory service
@@ -21,11 +28,18 @@ func NewMemoryService(fs *firestore.Client) *MemoryService {
 	return &MemoryService{fs: fs}
 }
 
+// WithEmbedder enables semantic and hybrid search; entries are embedded as they are written
+func (s *MemoryService) WithEmbedder(embedder Embedder) *MemoryService {
+	s.embedder = embedder
+	return s
+}
+
 // MemoryReadRequest represents a memory read request
 type MemoryReadRequest struct {
 	UID   string `json:"uid"`
 	Query string `json:"query"`
 	Limit int    `json:"limit"`
+	Mode  string `json:"mode,omitempty"` // "keyword" (default) | "semantic" | "hybrid"
 }
 
 // MemoryReadResponse represents a memory read response
@@ -59,7 +73,7 @@ func (s *MemoryService) Read(ctx context.Context, req MemoryReadRequest) (*Memor
 	// Fetch user document
 	userDoc, err := s.fs.Collection("users").Doc(req.UID).Get(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get user: %w", err)
+		return nil, lookupError("user", err)
 	}
 
 	var user models.User
@@ -67,55 +81,52 @@ func (s *MemoryService) Read(ctx context.Context, req MemoryReadRequest) (*Memor
 		return nil, fmt.Errorf("failed to parse user: %w", err)
 	}
 
-	hits := []MemoryHit{}
-	queryLower := strings.ToLower(req.Query)
-
-	// Search in memory summary
-	if user.MemorySummary != "" && strings.Contains(strings.ToLower(user.MemorySummary), queryLower) {
-		hits = append(hits, MemoryHit{
-			Type:    "session_summary",
-			ID:      "memory_summary",
-			Snippet: user.MemorySummary,
-			Score:   0.8,
-		})
-	}
-
-	// Search in commitments
-	for _, commitment := range user.Commitments {
-		if strings.Contains(strings.ToLower(commitment.Text), queryLower) {
-			hits = append(hits, MemoryHit{
-				Type:    "commitment",
-				ID:      commitment.ID,
-				Snippet: commitment.Text,
-				Score:   0.7,
-			})
+	entries := memoryEntries(user)
+	terms := queryTerms(req.Query)
+
+	// Semantic scores are best-effort; without them every entry is keyword scored
+	var semantic map[int]float64
+	if req.Mode == MemoryModeSemantic || req.Mode == MemoryModeHybrid {
+		if s.embedder != nil && len(terms) > 0 && len(entries) > 0 {
+			semantic, err = s.semanticScores(ctx, req.UID, req.Query, entries)
+			if err != nil {
+				log.Printf("Semantic memory search failed for %s, using keywords: %v", req.UID, err)
+				semantic = nil
+			}
 		}
 	}
 
-	// Search in values
-	for _, value := range user.ContextVault.Values {
-		if strings.Contains(strings.ToLower(value), queryLower) {
-			hits = append(hits, MemoryHit{
-				Type:    "preference",
-				ID:      "value",
-				Snippet: value,
-				Score:   0.6,
-			})
+	hits := []MemoryHit{}
+	for i, entry := range entries {
+		score := scoreText(entry.text, terms)
+		if similarity, ok := semantic[i]; ok {
+			if similarity < minSemanticScore {
+				similarity = 0
+			}
+			if req.Mode == MemoryModeHybrid {
+				score = (score + similarity) / 2
+			} else {
+				score = similarity
+			}
 		}
-	}
 
-	// Search in goals
-	for _, goal := range user.ContextVault.Goals {
-		if strings.Contains(strings.ToLower(goal), queryLower) {
-			hits = append(hits, MemoryHit{
-				Type:    "preference",
-				ID:      "goal",
-				Snippet: goal,
-				Score:   0.6,
-			})
+		// An empty query matches everything
+		if score == 0 && len(terms) > 0 {
+			continue
 		}
+		hits = append(hits, MemoryHit{
+			Type:    entry.hitType,
+			ID:      entry.id,
+			Snippet: textutil.TruncateSafe(entry.text, maxSnippetRunes),
+			Score:   score,
+		})
 	}
 
+	// Most relevant first; ties keep source order
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+
 	// Limit results
 	limit := req.Limit
 	if limit == 0 {
@@ -149,20 +160,20 @@ func (s *MemoryService) Write(ctx context.Context, req MemoryWriteRequest) error
 		textLower := strings.ToLower(commitment.Text)
 		for _, pattern := range sensitivePatterns {
 			if strings.Contains(textLower, pattern) {
-				return fmt.Errorf("rejected: contains sensitive pattern '%s'", pattern)
+				return invalidf("rejected: contains sensitive pattern '%s'", pattern)
 			}
 		}
 
 		// Check for credit card patterns (16 digits)
 		creditCardRegex := regexp.MustCompile(`\b\d{4}[\s-]?\d{4}[\s-]?\d{4}[\s-]?\d{4}\b`)
 		if creditCardRegex.MatchString(commitment.Text) {
-			return fmt.Errorf("rejected: contains credit card number")
+			return invalidf("rejected: contains credit card number")
 		}
 
 		// Check for SSN patterns (XXX-XX-XXXX)
 		ssnRegex := regexp.MustCompile(`\b\d{3}[-]?\d{2}[-]?\d{4}\b`)
 		if ssnRegex.MatchString(commitment.Text) {
-			return fmt.Errorf("rejected: contains SSN")
+			return invalidf("rejected: contains SSN")
 		}
 	}
 
@@ -217,5 +228,16 @@ func (s *MemoryService) Write(ctx context.Context, req MemoryWriteRequest) error
 		return fmt.Errorf("failed to update user memory: %w", err)
 	}
 
+	// Embed new commitments for semantic search; Read backfills anything missed here
+	if len(req.Patch.CommitmentsAdd) > 0 {
+		texts := make([]string, len(req.Patch.CommitmentsAdd))
+		for i, c := range req.Patch.CommitmentsAdd {
+			texts[i] = c.Text
+		}
+		if err := s.storeEmbeddings(ctx, req.UID, "commitment", texts); err != nil {
+			log.Printf("Failed to embed commitments for %s: %v", req.UID, err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"simon-backend/internal/models"
+)
+
+// Memory search modes
+const (
+	MemoryModeKeyword  = "keyword"
+	MemoryModeSemantic = "semantic"
+	MemoryModeHybrid   = "hybrid"
+)
+
+// minSemanticScore is the cosine similarity below which a semantic match is ignored
+const minSemanticScore = 0.55
+
+// maxEmbedBackfill bounds how many missing entry vectors Read computes in one call
+const maxEmbedBackfill = 50
+
+// Embedder turns text into embedding vectors (gemini.Client satisfies this)
+type Embedder interface {
+	Embed(ctx context.Context, texts ...string) ([][]float32, error)
+}
+
+// memoryEmbedding is stored at users/{uid}/memory_embeddings/{id}
+type memoryEmbedding struct {
+	Kind      string             `firestore:"kind"` // "commitment" | "goal" | "value" | "session_summary"
+	Text      string             `firestore:"text"`
+	Vector    firestore.Vector32 `firestore:"vector"`
+	CreatedAt time.Time          `firestore:"created_at,serverTimestamp"`
+}
+
+// memoryEmbeddingID keys an embedding by its source text so edits produce a fresh vector
+func memoryEmbeddingID(kind, text string) string {
+	sum := sha256.Sum256([]byte(kind + "|" + text))
+	return kind + "_" + hex.EncodeToString(sum[:12])
+}
+
+// memoryEntry is a searchable piece of user memory
+type memoryEntry struct {
+	hitType string
+	id      string
+	kind    string
+	text    string
+}
+
+// memoryEntries lists everything Read searches, in result tie-break order
+func memoryEntries(user models.User) []memoryEntry {
+	entries := []memoryEntry{}
+	if user.MemorySummary != "" {
+		entries = append(entries, memoryEntry{"session_summary", "memory_summary", "session_summary", user.MemorySummary})
+	}
+	for _, commitment := range user.Commitments {
+		entries = append(entries, memoryEntry{"commitment", commitment.ID, "commitment", commitment.Text})
+	}
+	for _, value := range user.ContextVault.Values {
+		entries = append(entries, memoryEntry{"preference", "value", "value", value})
+	}
+	for _, goal := range user.ContextVault.Goals {
+		entries = append(entries, memoryEntry{"preference", "goal", "goal", goal})
+	}
+	return entries
+}
+
+// storeEmbeddings embeds texts and saves their vectors under the user
+func (s *MemoryService) storeEmbeddings(ctx context.Context, uid, kind string, texts []string) error {
+	if s.embedder == nil || len(texts) == 0 {
+		return nil
+	}
+
+	vectors, err := s.embedder.Embed(ctx, texts...)
+	if err != nil {
+		return err
+	}
+
+	batch := s.fs.Batch()
+	for i, text := range texts {
+		batch.Set(s.embeddingsRef(uid).Doc(memoryEmbeddingID(kind, text)), memoryEmbedding{
+			Kind:   kind,
+			Text:   text,
+			Vector: vectors[i],
+		})
+	}
+	_, err = batch.Commit(ctx)
+	return err
+}
+
+// semanticScores returns cosine similarity between the query and each entry, keyed by
+// entry index. Entries without a stored vector are embedded and saved on the way, up to
+// maxEmbedBackfill per call; the rest are left out and fall back to keyword scoring.
+func (s *MemoryService) semanticScores(ctx context.Context, uid, query string, entries []memoryEntry) (map[int]float64, error) {
+	refs := make([]*firestore.DocumentRef, len(entries))
+	for i, entry := range entries {
+		refs[i] = s.embeddingsRef(uid).Doc(memoryEmbeddingID(entry.kind, entry.text))
+	}
+
+	docs, err := s.fs.GetAll(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load memory embeddings: %w", err)
+	}
+
+	vectors := make(map[int][]float32, len(entries))
+	missing := []int{}
+	for i, doc := range docs {
+		if !doc.Exists() {
+			if len(missing) < maxEmbedBackfill {
+				missing = append(missing, i)
+			}
+			continue
+		}
+		var stored memoryEmbedding
+		if err := doc.DataTo(&stored); err != nil {
+			continue
+		}
+		vectors[i] = stored.Vector
+	}
+
+	// Embed the query together with any entries that are missing a vector
+	texts := []string{query}
+	for _, i := range missing {
+		texts = append(texts, entries[i].text)
+	}
+	embedded, err := s.embedder.Embed(ctx, texts...)
+	if err != nil {
+		return nil, err
+	}
+	queryVector := embedded[0]
+
+	if len(missing) > 0 {
+		batch := s.fs.Batch()
+		for j, i := range missing {
+			vectors[i] = embedded[j+1]
+			batch.Set(refs[i], memoryEmbedding{
+				Kind:   entries[i].kind,
+				Text:   entries[i].text,
+				Vector: embedded[j+1],
+			})
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			log.Printf("Failed to save backfilled memory embeddings for %s: %v", uid, err)
+		}
+	}
+
+	scores := make(map[int]float64, len(vectors))
+	for i, vector := range vectors {
+		scores[i] = cosineSimilarity(queryVector, vector)
+	}
+	return scores, nil
+}
+
+func (s *MemoryService) embeddingsRef(uid string) *firestore.CollectionRef {
+	return s.fs.Collection("users").Doc(uid).Collection("memory_embeddings")
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0 if they are incomparable
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
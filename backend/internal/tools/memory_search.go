@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scoring weights for memory search: a whole-word match counts fully, a substring match partially
+const (
+	wholeWordWeight = 1.0
+	substringWeight = 0.5
+)
+
+// queryTerms splits a search query into lowercase, de-duplicated terms
+func queryTerms(query string) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// scoreText rates how well text matches the query terms, in [0, 1].
+// Each matched term contributes by weight and (dampened) frequency, and the
+// total is scaled by the fraction of terms matched so broader matches rank first.
+// Returns 0 when no term matches.
+func scoreText(text string, terms []string) float64 {
+	if len(terms) == 0 {
+		return 0
+	}
+
+	textLower := strings.ToLower(text)
+	matched := 0
+	total := 0.0
+	for _, term := range terms {
+		whole, partial := countOccurrences(textLower, term)
+		if whole+partial == 0 {
+			continue
+		}
+		matched++
+
+		weight := substringWeight
+		if whole > 0 {
+			weight = wholeWordWeight
+		}
+		// Grows with repeated mentions but saturates below 1
+		frequency := 1 - 1/(1+math.Log1p(float64(whole+partial)))
+		total += weight * frequency
+	}
+	if matched == 0 {
+		return 0
+	}
+
+	coverage := float64(matched) / float64(len(terms))
+	return coverage * total / float64(matched)
+}
+
+// countOccurrences counts non-overlapping occurrences of term in text, split into
+// whole-word matches and matches embedded in a longer word
+func countOccurrences(text, term string) (whole, partial int) {
+	offset := 0
+	for {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			return whole, partial
+		}
+		start := offset + i
+		end := start + len(term)
+		if isWordBoundary(text, start, end) {
+			whole++
+		} else {
+			partial++
+		}
+		offset = end
+	}
+}
+
+// isWordBoundary reports whether text[start:end] is not flanked by letters or digits
+func isWordBoundary(text string, start, end int) bool {
+	if start > 0 {
+		r, _ := utf8.DecodeLastRuneInString(text[:start])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	if end < len(text) {
+		r, _ := utf8.DecodeRuneInString(text[end:])
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
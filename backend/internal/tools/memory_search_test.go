@@ -0,0 +1,45 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestQueryTerms(t *testing.T) {
+	if got, want := queryTerms("  Morning WORKOUT morning "), []string{"morning", "workout"}; !slices.Equal(got, want) {
+		t.Errorf("queryTerms = %q, want %q", got, want)
+	}
+	if got := queryTerms(" "); len(got) != 0 {
+		t.Errorf("blank query terms = %q, want none", got)
+	}
+}
+
+func TestScoreText(t *testing.T) {
+	terms := queryTerms("morning workout")
+	tests := []struct {
+		name          string
+		higher, lower string
+	}{
+		{"more terms matched", "Morning workout before work", "Workout three times a week"},
+		{"whole word over substring", "Log each workout", "Track my workouts"},
+		{"repeated mentions", "Workout, then a second workout", "Workout three times a week"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			higher, lower := scoreText(tt.higher, terms), scoreText(tt.lower, terms)
+			if higher <= lower {
+				t.Errorf("score(%q) = %v, want above score(%q) = %v", tt.higher, higher, tt.lower, lower)
+			}
+			if higher > 1 || lower <= 0 {
+				t.Errorf("scores %v and %v, want both in (0, 1]", higher, lower)
+			}
+		})
+	}
+
+	if got := scoreText("Read before bed", terms); got != 0 {
+		t.Errorf("unmatched text scored %v, want 0", got)
+	}
+	if got := scoreText("anything", nil); got != 0 {
+		t.Errorf("empty query scored %v, want 0", got)
+	}
+}
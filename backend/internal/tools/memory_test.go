@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestMemoryReadTruncatesSnippets(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	summary := strings.Repeat("Koşu planına sadık kaldı 🏃 ", 30)
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, models.User{UID: "u1", MemorySummary: summary}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := NewMemoryService(fs.DB).Read(ctx, MemoryReadRequest{UID: "u1", Query: "koşu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Hits) != 1 {
+		t.Fatalf("hits = %+v, want the memory summary", resp.Hits)
+	}
+	snippet := resp.Hits[0].Snippet
+	if !utf8.ValidString(snippet) || utf8.RuneCountInString(snippet) > maxSnippetRunes || !strings.HasSuffix(snippet, "…") {
+		t.Errorf("snippet = %q (%d runes), want a valid, ellipsized snippet of at most %d runes", snippet, utf8.RuneCountInString(snippet), maxSnippetRunes)
+	}
+}
+
+func TestMemoryReadRanksByRelevance(t *testing.T) {
+	ctx := context.Background()
+	fs := firestoretest.New(t)
+	user := models.User{
+		UID: "u1",
+		Commitments: []models.Commitment{
+			{ID: "c1", Text: "Stretch every morning"},
+			{ID: "c2", Text: "Track my workouts"},
+			{ID: "c3", Text: "Morning workout before work"},
+			{ID: "c4", Text: "Read before bed"},
+		},
+		ContextVault: models.UserContext{Goals: []string{"Never skip a workout"}},
+	}
+	if _, err := fs.DB.Collection("users").Doc("u1").Set(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	svc := NewMemoryService(fs.DB)
+
+	read := func(query string, limit int) []string {
+		t.Helper()
+		resp, err := svc.Read(ctx, MemoryReadRequest{UID: "u1", Query: query, Limit: limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var snippets []string
+		for i, hit := range resp.Hits {
+			if i > 0 && hit.Score > resp.Hits[i-1].Score {
+				t.Errorf("%q: hits not sorted by score: %+v", query, resp.Hits)
+			}
+			snippets = append(snippets, hit.Snippet)
+		}
+		return snippets
+	}
+
+	// Both terms beat one; a whole-word match beats a substring one
+	got := read("morning workout", 0)
+	want := []string{"Morning workout before work", "Stretch every morning", "Never skip a workout", "Track my workouts"}
+	if !slices.Equal(got, want) {
+		t.Errorf("morning workout = %q, want %q", got, want)
+	}
+
+	if got := read("morning workout", 2); !slices.Equal(got, want[:2]) {
+		t.Errorf("limit 2 = %q, want the two best hits %q", got, want[:2])
+	}
+	if got := read("swimming", 0); len(got) != 0 {
+		t.Errorf("unmatched query = %q, want no hits", got)
+	}
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"simon-backend/internal/models"
+)
+
+// PlanProgress returns the percentage of a plan's next actions that are completed, 0 for a plan
+// without actions
+func PlanProgress(plan models.Plan) int {
+	if len(plan.NextActions) == 0 {
+		return 0
+	}
+	completed := 0
+	for _, action := range plan.NextActions {
+		if action.Status == "completed" {
+			completed++
+		}
+	}
+	return completed * 100 / len(plan.NextActions)
+}
+
+// planFinished reports whether every action and milestone of a plan is completed. Recurring plans
+// never finish early: they're archived when their period is renewed.
+func planFinished(plan models.Plan) bool {
+	if plan.Recurrence != nil || len(plan.NextActions)+len(plan.Milestones) == 0 {
+		return false
+	}
+	for _, action := range plan.NextActions {
+		if action.Status != "completed" {
+			return false
+		}
+	}
+	for _, milestone := range plan.Milestones {
+		if milestone.Status != "completed" {
+			return false
+		}
+	}
+	return true
+}
+
+// CompleteAction marks one of a plan's next actions completed and returns the updated plan.
+// Completing an action that's already done keeps its original completion time.
+func (s *PlanService) CompleteAction(ctx context.Context, uid, planID, actionID string) (*models.Plan, error) {
+	return s.completeItem(ctx, uid, planID, func(plan *models.Plan, now time.Time) (firestore.Update, error) {
+		for i := range plan.NextActions {
+			action := &plan.NextActions[i]
+			if action.ID != actionID {
+				continue
+			}
+			if action.Status != "completed" {
+				action.Status = "completed"
+				action.CompletedAt = now
+			}
+			return firestore.Update{Path: "next_actions", Value: plan.NextActions}, nil
+		}
+		return firestore.Update{}, fmt.Errorf("action %w", ErrNotFound)
+	})
+}
+
+// CompleteMilestone marks one of a plan's milestones completed and returns the updated plan
+func (s *PlanService) CompleteMilestone(ctx context.Context, uid, planID, milestoneID string) (*models.Plan, error) {
+	return s.completeItem(ctx, uid, planID, func(plan *models.Plan, now time.Time) (firestore.Update, error) {
+		for i := range plan.Milestones {
+			milestone := &plan.Milestones[i]
+			if milestone.ID != milestoneID {
+				continue
+			}
+			if milestone.Status != "completed" {
+				milestone.Status = "completed"
+				milestone.CompletedAt = now
+			}
+			return firestore.Update{Path: "milestones", Value: plan.Milestones}, nil
+		}
+		return firestore.Update{}, fmt.Errorf("milestone %w", ErrNotFound)
+	})
+}
+
+// completeItem applies complete to the user's plan and writes back the array it changed.
+// Firestore can't update one element of an array in place, so the array is read, modified and
+// rewritten whole inside a transaction; a concurrent change to the plan retries rather than
+// being overwritten. An active plan whose every action and milestone is done is archived.
+func (s *PlanService) completeItem(ctx context.Context, uid, planID string, complete func(*models.Plan, time.Time) (firestore.Update, error)) (*models.Plan, error) {
+	ref := s.fs.Collection("plans").Doc(planID)
+	var plan models.Plan
+
+	err := s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return lookupError("plan", err)
+		}
+
+		plan = models.Plan{}
+		if err := doc.DataTo(&plan); err != nil {
+			return fmt.Errorf("failed to parse plan: %w", err)
+		}
+		if plan.UID != uid {
+			return fmt.Errorf("%w: plan belongs to different user", ErrUnauthorized)
+		}
+
+		now := models.Now()
+		update, err := complete(&plan, now)
+		if err != nil {
+			return err
+		}
+		plan.UpdatedAt = now
+
+		updates := []firestore.Update{
+			update,
+			{Path: "updated_at", Value: now},
+		}
+		if plan.Status == "active" && planFinished(plan) {
+			plan.Status = "archived"
+			plan.Pinned = false
+			updates = append(updates,
+				firestore.Update{Path: "status", Value: plan.Status},
+				firestore.Update{Path: "pinned", Value: false},
+			)
+		}
+		return tx.Update(ref, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
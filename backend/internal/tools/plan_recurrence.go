@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"simon-backend/internal/models"
+)
+
+// planRecurrencePageSize bounds how many due recurring plans are processed per query page
+const planRecurrencePageSize = 200
+
+// maxPlanNextActions caps the next actions of a plan, regenerated ones included
+const maxPlanNextActions = 12
+
+// validRecurrenceKinds are the periods a recurring plan can regenerate on
+var validRecurrenceKinds = map[string]bool{
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+}
+
+// PlanRecurrenceResult summarizes one recurring-plan pass
+type PlanRecurrenceResult struct {
+	Scanned int `json:"scanned"`
+	Renewed int `json:"renewed"`
+}
+
+// errPlanNotDue aborts a renewal transaction when another pass already renewed the plan
+var errPlanNotDue = errors.New("plan no longer due")
+
+// prepareRecurrence validates a new plan's recurrence and sets its first period end. Without
+// explicit template actions, the plan's own next actions become the template.
+func prepareRecurrence(plan *models.Plan, now time.Time, loc *time.Location) error {
+	recurrence := plan.Recurrence
+	if !validRecurrenceKinds[recurrence.Kind] {
+		return invalidf("invalid recurrence kind: %s (must be daily, weekly, or monthly)", recurrence.Kind)
+	}
+	if len(recurrence.TemplateActions) > maxPlanNextActions {
+		return invalidf("too many template actions (max %d, got %d)", maxPlanNextActions, len(recurrence.TemplateActions))
+	}
+	if len(recurrence.TemplateActions) == 0 {
+		recurrence.TemplateActions = freshActions(plan.NextActions)
+	}
+	recurrence.PeriodEnd = nextPeriodBoundary(recurrence.Kind, now, loc)
+	return nil
+}
+
+// nextPeriodBoundary returns the local midnight starting the period after the one containing t:
+// tomorrow for daily plans, next Monday for weekly ones, the 1st of next month for monthly ones
+func nextPeriodBoundary(kind string, t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	switch kind {
+	case "weekly":
+		days := (8 - int(midnight.Weekday())) % 7
+		if days == 0 {
+			days = 7
+		}
+		return midnight.AddDate(0, 0, days)
+	case "monthly":
+		return time.Date(local.Year(), local.Month()+1, 1, 0, 0, 0, 0, loc)
+	default:
+		return midnight.AddDate(0, 0, 1)
+	}
+}
+
+// RunRecurring renews every active recurring plan whose period has ended: the plan is archived
+// and the next period's plan is created from its template.
+func (s *PlanService) RunRecurring(ctx context.Context, now time.Time) (PlanRecurrenceResult, error) {
+	var result PlanRecurrenceResult
+	var lastDoc *firestore.DocumentSnapshot
+
+	for {
+		query := s.fs.Collection("plans").
+			Where("status", "==", "active").
+			Where("recurrence.period_end", "<=", now).
+			OrderBy("recurrence.period_end", firestore.Asc).
+			Limit(planRecurrencePageSize)
+		if lastDoc != nil {
+			query = query.StartAfter(lastDoc)
+		}
+
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return result, fmt.Errorf("failed to query due recurring plans: %w", err)
+		}
+		if len(docs) == 0 {
+			return result, nil
+		}
+
+		for _, doc := range docs {
+			result.Scanned++
+
+			err := s.renewPlan(ctx, doc.Ref, now)
+			switch {
+			case errors.Is(err, errPlanNotDue):
+			case err != nil:
+				log.Printf("Error renewing plan %s: %v", doc.Ref.ID, err)
+			default:
+				result.Renewed++
+			}
+		}
+
+		if len(docs) < planRecurrencePageSize {
+			return result, nil
+		}
+		lastDoc = docs[len(docs)-1]
+	}
+}
+
+// renewPlan archives one due plan and creates its successor. It runs in a transaction so
+// overlapping passes can't renew the same period twice.
+func (s *PlanService) renewPlan(ctx context.Context, ref *firestore.DocumentRef, now time.Time) error {
+	nextRef := s.fs.Collection("plans").NewDoc()
+
+	return s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		var plan models.Plan
+		if err := doc.DataTo(&plan); err != nil {
+			return err
+		}
+		if plan.Status != "active" || plan.Recurrence == nil || plan.Recurrence.PeriodEnd.After(now) {
+			return errPlanNotDue
+		}
+
+		loc := UserPreferences(ctx, s.fs, plan.UID).Location()
+		if err := tx.Create(nextRef, renewedPlan(plan, nextRef.ID, now, loc)); err != nil {
+			return err
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: "archived"},
+			{Path: "pinned", Value: false},
+			{Path: "updated_at", Value: now},
+		})
+	})
+}
+
+// renewedPlan builds the next period's plan: the template actions, followed by the previous
+// period's unfinished actions when the recurrence carries them over
+func renewedPlan(prev models.Plan, id string, now time.Time, loc *time.Location) models.Plan {
+	recurrence := *prev.Recurrence
+	recurrence.PeriodEnd = nextPeriodBoundary(recurrence.Kind, now, loc)
+
+	actions := freshActions(recurrence.TemplateActions)
+	if recurrence.CarryOverIncomplete {
+		seen := map[string]bool{}
+		for _, action := range actions {
+			seen[strings.ToLower(action.Title)] = true
+		}
+		for _, action := range prev.NextActions {
+			if action.Status == "completed" || seen[strings.ToLower(action.Title)] {
+				continue
+			}
+			seen[strings.ToLower(action.Title)] = true
+			actions = append(actions, freshActions([]models.NextAction{action})...)
+		}
+	}
+	if len(actions) > maxPlanNextActions {
+		actions = actions[:maxPlanNextActions]
+	}
+	for i := range actions {
+		actions[i].ID = fmt.Sprintf("action_%d", i+1)
+	}
+
+	return models.Plan{
+		ID:             id,
+		UID:            prev.UID,
+		CoachID:        prev.CoachID,
+		Title:          prev.Title,
+		Objective:      prev.Objective,
+		Horizon:        prev.Horizon,
+		NextActions:    actions,
+		Status:         "active",
+		SessionID:      prev.SessionID,
+		Pinned:         prev.Pinned,
+		Recurrence:     &recurrence,
+		PreviousPlanID: prev.ID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// freshActions copies actions as pending, dropping completion and scheduled times that
+// belonged to an earlier period
+func freshActions(actions []models.NextAction) []models.NextAction {
+	fresh := make([]models.NextAction, 0, len(actions))
+	for _, action := range actions {
+		fresh = append(fresh, models.NextAction{
+			ID:          action.ID,
+			Title:       action.Title,
+			DurationMin: action.DurationMin,
+			Energy:      action.Energy,
+			Status:      "pending",
+		})
+	}
+	return fresh
+}
@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestNextPeriodBoundary(t *testing.T) {
+	istanbul, err := time.LoadLocation("Europe/Istanbul")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		kind string
+		at   time.Time
+		want time.Time
+	}{
+		{"weekly from a Wednesday", "weekly", time.Date(2026, 4, 15, 10, 0, 0, 0, time.UTC), time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC)},
+		{"weekly from Monday midnight", "weekly", time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 27, 0, 0, 0, 0, time.UTC)},
+		{"weekly late Sunday", "weekly", time.Date(2026, 4, 19, 23, 30, 0, 0, time.UTC), time.Date(2026, 4, 20, 0, 0, 0, 0, time.UTC)},
+		{"daily", "daily", time.Date(2026, 4, 15, 23, 59, 0, 0, time.UTC), time.Date(2026, 4, 16, 0, 0, 0, 0, time.UTC)},
+		{"monthly across a short month", "monthly", time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"monthly across the year", "monthly", time.Date(2026, 12, 5, 12, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// 22:30 UTC Sunday is already Monday 01:30 in Istanbul
+		{"weekly in the user's zone", "weekly", time.Date(2026, 4, 19, 22, 30, 0, 0, time.UTC), time.Date(2026, 4, 27, 0, 0, 0, 0, istanbul)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := time.UTC
+			if tt.want.Location() != time.UTC {
+				loc = tt.want.Location()
+			}
+			if got := nextPeriodBoundary(tt.kind, tt.at, loc); !got.Equal(tt.want) {
+				t.Errorf("nextPeriodBoundary(%s, %s) = %s, want %s", tt.kind, tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunRecurringRenewsWeeklyPlan(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPlanService(firestoretest.New(t).DB)
+	created, err := svc.Create(ctx, PlanCreateRequest{
+		UID: "u1",
+		Plan: models.Plan{
+			Title:     "Training week",
+			Objective: "Run three times a week",
+			Horizon:   "week",
+			NextActions: []models.NextAction{
+				{Title: "Long run", DurationMin: 60, Energy: "high"},
+				{Title: "Meal prep", DurationMin: 45, Energy: "medium"},
+			},
+			Recurrence: &models.PlanRecurrence{Kind: "weekly", CarryOverIncomplete: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := getPlan(t, svc, created.PlanID)
+	if len(first.Recurrence.TemplateActions) != 2 || first.Recurrence.PeriodEnd.Weekday() != time.Monday {
+		t.Fatalf("recurrence = %+v, want the plan's actions as template ending on a Monday", first.Recurrence)
+	}
+
+	// During the week: the long run got done, meal prep didn't, and a one-off was added
+	actions := first.NextActions
+	actions[0].Status = "completed"
+	actions = append(actions, models.NextAction{ID: "action_3", Title: "Fix bike light", Status: "pending"})
+	if _, err := svc.fs.Collection("plans").Doc(first.ID).Update(ctx, []firestore.Update{{Path: "next_actions", Value: actions}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, err := svc.RunRecurring(ctx, first.Recurrence.PeriodEnd.Add(-time.Minute)); err != nil || result.Renewed != 0 {
+		t.Fatalf("before the boundary: %+v (err %v), want nothing renewed", result, err)
+	}
+
+	boundary := first.Recurrence.PeriodEnd.Add(time.Hour)
+	result, err := svc.RunRecurring(ctx, boundary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Scanned != 1 || result.Renewed != 1 {
+		t.Fatalf("result = %+v, want one renewal", result)
+	}
+
+	if old := getPlan(t, svc, first.ID); old.Status != "archived" {
+		t.Errorf("previous period status = %q, want archived", old.Status)
+	}
+
+	active, err := svc.ListActive(ctx, PlanListRequest{UID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active.Plans) != 1 {
+		t.Fatalf("%d active plans, want the renewed one only", len(active.Plans))
+	}
+	next := active.Plans[0]
+	if next.PreviousPlanID != first.ID || next.Title != first.Title {
+		t.Errorf("renewed plan = %+v, want it linked to %s", next, first.ID)
+	}
+	var titles []string
+	for i, action := range next.NextActions {
+		titles = append(titles, action.Title)
+		if action.Status != "pending" {
+			t.Errorf("action %q status = %q, want pending", action.Title, action.Status)
+		}
+		if want := fmt.Sprintf("action_%d", i+1); action.ID != want {
+			t.Errorf("action %q id = %q, want %q", action.Title, action.ID, want)
+		}
+	}
+	// Template actions, then the unfinished one-off; meal prep isn't carried over twice
+	if len(titles) != 3 || titles[0] != "Long run" || titles[1] != "Meal prep" || titles[2] != "Fix bike light" {
+		t.Errorf("renewed actions = %v", titles)
+	}
+	if !next.Recurrence.PeriodEnd.After(boundary) || next.Recurrence.PeriodEnd.Sub(first.Recurrence.PeriodEnd) != 7*24*time.Hour {
+		t.Errorf("next period end = %s, want a week after %s", next.Recurrence.PeriodEnd, first.Recurrence.PeriodEnd)
+	}
+
+	// A second pass at the same time finds nothing due
+	if result, err := svc.RunRecurring(ctx, boundary); err != nil || result.Renewed != 0 {
+		t.Errorf("rerun: %+v (err %v), want nothing renewed", result, err)
+	}
+}
+
+func TestRunRecurringWithoutCarryOver(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPlanService(firestoretest.New(t).DB)
+	created, err := svc.Create(ctx, PlanCreateRequest{
+		UID: "u1",
+		Plan: models.Plan{
+			Title:       "Daily reset",
+			Objective:   "Tidy desk each evening",
+			Horizon:     "today",
+			NextActions: []models.NextAction{{Title: "Inbox zero"}},
+			Recurrence: &models.PlanRecurrence{
+				Kind:            "daily",
+				TemplateActions: []models.NextAction{{Title: "Clear desk"}},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := getPlan(t, svc, created.PlanID)
+
+	if _, err := svc.RunRecurring(ctx, first.Recurrence.PeriodEnd); err != nil {
+		t.Fatal(err)
+	}
+	active, err := svc.ListActive(ctx, PlanListRequest{UID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active.Plans) != 1 || len(active.Plans[0].NextActions) != 1 || active.Plans[0].NextActions[0].Title != "Clear desk" {
+		t.Errorf("renewed plans = %+v, want just the template action", active.Plans)
+	}
+}
+
+func TestCreateRejectsInvalidRecurrence(t *testing.T) {
+	svc := NewPlanService(firestoretest.New(t).DB)
+	_, err := svc.Create(context.Background(), PlanCreateRequest{
+		UID:  "u1",
+		Plan: models.Plan{Title: "x", Objective: "y", Horizon: "week", Recurrence: &models.PlanRecurrence{Kind: "hourly"}},
+	})
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("error = %v, want ErrValidation", err)
+	}
+}
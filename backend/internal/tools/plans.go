@@ -2,10 +2,14 @@ package tools
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"strings"
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	fsClient "simon-backend/internal/firestore"
 	"simon-backend/internal/models"
 )
 
@@ -21,14 +25,19 @@ func NewPlanService(fs *firestore.Client) *PlanService {
 
 // PlanCreateRequest represents a plan creation request
 type PlanCreateRequest struct {
-	UID     string       `json:"uid"`
-	CoachID string       `json:"coach_id"`
-	Plan    models.Plan  `json:"plan"`
+	UID       string       `json:"uid"`
+	CoachID   string       `json:"coach_id"`
+	SessionID string       `json:"session_id,omitempty"`
+	Plan      models.Plan  `json:"plan"`
+	// IdempotencyKey is optional; creates sharing a key return the existing plan
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // PlanCreateResponse represents a plan creation response
 type PlanCreateResponse struct {
 	PlanID string `json:"plan_id"`
+	// Status is "created", or for a repeated idempotency key "exists", or "archived" when the
+	// user has since archived the plan the key created
 	Status string `json:"status"`
 }
 
@@ -48,6 +57,16 @@ type PlanUpdateResponse struct {
 type PlanListRequest struct {
 	UID   string `json:"uid"`
 	Limit int    `json:"limit"`
+	// Status is "active" (the default), "completed", "archived" or "all"
+	Status string `json:"status,omitempty"`
+}
+
+// PlanListStatuses are the statuses plans can be listed by
+var PlanListStatuses = map[string]bool{
+	"active":    true,
+	"completed": true,
+	"archived":  true,
+	"all":       true,
 }
 
 // PlanListResponse represents a plan list response
@@ -59,10 +78,10 @@ type PlanListResponse struct {
 func (s *PlanService) Create(ctx context.Context, req PlanCreateRequest) (*PlanCreateResponse, error) {
 	// Validate plan constraints
 	if len(req.Plan.NextActions) > 12 {
-		return nil, fmt.Errorf("too many next actions (max 12, got %d)", len(req.Plan.NextActions))
+		return nil, invalidf("too many next actions (max 12, got %d)", len(req.Plan.NextActions))
 	}
 	if len(req.Plan.Milestones) > 8 {
-		return nil, fmt.Errorf("too many milestones (max 8, got %d)", len(req.Plan.Milestones))
+		return nil, invalidf("too many milestones (max 8, got %d)", len(req.Plan.Milestones))
 	}
 
 	// Validate horizon
@@ -73,19 +92,22 @@ func (s *PlanService) Create(ctx context.Context, req PlanCreateRequest) (*PlanC
 		"quarter": true,
 	}
 	if !validHorizons[req.Plan.Horizon] {
-		return nil, fmt.Errorf("invalid horizon: %s (must be today, week, month, or quarter)", req.Plan.Horizon)
+		return nil, invalidf("invalid horizon: %s (must be today, week, month, or quarter)", req.Plan.Horizon)
 	}
 
 	// Validate required fields
 	if req.Plan.Title == "" {
-		return nil, fmt.Errorf("plan title is required")
+		return nil, invalidf("plan title is required")
 	}
 	if req.Plan.Objective == "" {
-		return nil, fmt.Errorf("plan objective is required")
+		return nil, invalidf("plan objective is required")
 	}
 
-	// Generate plan ID
+	// Generate plan ID (deterministic when an idempotency key is supplied)
 	planRef := s.fs.Collection("plans").NewDoc()
+	if req.IdempotencyKey != "" {
+		planRef = s.fs.Collection("plans").Doc(idempotentPlanID(req.UID, req.IdempotencyKey))
+	}
 	planID := planRef.ID
 
 	// Set plan fields
@@ -93,7 +115,11 @@ func (s *PlanService) Create(ctx context.Context, req PlanCreateRequest) (*PlanC
 	plan.ID = planID
 	plan.UID = req.UID
 	plan.CoachID = req.CoachID
+	plan.SessionID = req.SessionID
+	plan.IdempotencyKey = req.IdempotencyKey
 	plan.Status = "active"
+	plan.Pinned = false // pinning goes through SetPinned so only one plan is pinned
+	plan.PreviousPlanID = ""
 	plan.CreatedAt = models.Now()
 	plan.UpdatedAt = models.Now()
 
@@ -116,8 +142,22 @@ func (s *PlanService) Create(ctx context.Context, req PlanCreateRequest) (*PlanC
 		}
 	}
 
+	if plan.Recurrence != nil {
+		if err := prepareRecurrence(&plan, plan.CreatedAt, UserPreferences(ctx, s.fs, req.UID).Location()); err != nil {
+			return nil, err
+		}
+	}
+
 	// Create plan document
-	if _, err := planRef.Set(ctx, plan); err != nil {
+	if req.IdempotencyKey != "" {
+		// Create fails if the document exists, so concurrent duplicates collapse to one plan
+		if _, err := planRef.Create(ctx, plan); err != nil {
+			if fsClient.IsAlreadyExists(err) {
+				return existingPlanResponse(ctx, planRef)
+			}
+			return nil, fmt.Errorf("failed to create plan: %w", err)
+		}
+	} else if _, err := planRef.Set(ctx, plan); err != nil {
 		return nil, fmt.Errorf("failed to create plan: %w", err)
 	}
 
@@ -127,12 +167,54 @@ func (s *PlanService) Create(ctx context.Context, req PlanCreateRequest) (*PlanC
 	}, nil
 }
 
+// existingPlanResponse reports the plan an idempotent create collided with. An archived plan
+// gets its own status so a retry isn't mistaken for the live plan.
+func existingPlanResponse(ctx context.Context, ref *firestore.DocumentRef) (*PlanCreateResponse, error) {
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing plan: %w", err)
+	}
+
+	status := "exists"
+	if doc.Data()["status"] == "archived" {
+		status = "archived"
+	}
+	return &PlanCreateResponse{
+		PlanID: ref.ID,
+		Status: status,
+	}, nil
+}
+
+// PlanIdempotencyKey builds an idempotency key from a session id and plan objective
+func PlanIdempotencyKey(sessionID, objective string) string {
+	if sessionID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(objective))))
+	return sessionID + ":" + hex.EncodeToString(sum[:8])
+}
+
+// PlanTurnIdempotencyKey builds an idempotency key for the plan extracted from one user message,
+// so a retried or regenerated reply to that message reuses its plan however the model words it
+func PlanTurnIdempotencyKey(sessionID, messageID string) string {
+	if sessionID == "" || messageID == "" {
+		return ""
+	}
+	return sessionID + ":msg:" + messageID
+}
+
+// idempotentPlanID derives a stable plan document ID scoped to the user
+func idempotentPlanID(uid, key string) string {
+	sum := sha256.Sum256([]byte(uid + "|" + key))
+	return "plan_" + hex.EncodeToString(sum[:12])
+}
+
 // Update updates an existing plan
 func (s *PlanService) Update(ctx context.Context, req PlanUpdateRequest) (*PlanUpdateResponse, error) {
 	// Verify plan ownership
 	planDoc, err := s.fs.Collection("plans").Doc(req.PlanID).Get(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("plan not found: %w", err)
+		return nil, lookupError("plan", err)
 	}
 
 	var plan models.Plan
@@ -141,7 +223,7 @@ func (s *PlanService) Update(ctx context.Context, req PlanUpdateRequest) (*PlanU
 	}
 
 	if plan.UID != req.UID {
-		return nil, fmt.Errorf("unauthorized: plan belongs to different user")
+		return nil, fmt.Errorf("%w: plan belongs to different user", ErrUnauthorized)
 	}
 
 	// Build Firestore updates
@@ -154,15 +236,26 @@ func (s *PlanService) Update(ctx context.Context, req PlanUpdateRequest) (*PlanU
 
 	// Add user-provided updates
 	for key, value := range req.Updates {
+		if key == "pinned" {
+			return nil, invalidf("pinned can only be changed via pin/unpin")
+		}
+		if err := checkUpdatePath("plan", key, planUpdatablePaths); err != nil {
+			return nil, err
+		}
+		if key == "status" {
+			if status, _ := value.(string); status != "active" && status != "completed" && status != "archived" {
+				return nil, invalidf("invalid plan status: %v", value)
+			}
+		}
 		// Validate constraints for specific fields
 		if key == "next_actions" {
 			if actions, ok := value.([]interface{}); ok && len(actions) > 12 {
-				return nil, fmt.Errorf("too many next actions (max 12)")
+				return nil, invalidf("too many next actions (max 12)")
 			}
 		}
 		if key == "milestones" {
 			if milestones, ok := value.([]interface{}); ok && len(milestones) > 8 {
-				return nil, fmt.Errorf("too many milestones (max 8)")
+				return nil, invalidf("too many milestones (max 8)")
 			}
 		}
 
@@ -184,21 +277,47 @@ func (s *PlanService) Update(ctx context.Context, req PlanUpdateRequest) (*PlanU
 
 // ListActive returns active plans for a user
 func (s *PlanService) ListActive(ctx context.Context, req PlanListRequest) (*PlanListResponse, error) {
+	req.Status = "active"
+	return s.List(ctx, req)
+}
+
+// List returns a user's plans with the requested status, newest first
+func (s *PlanService) List(ctx context.Context, req PlanListRequest) (*PlanListResponse, error) {
 	limit := req.Limit
 	if limit == 0 {
 		limit = 10
 	}
+	status := req.Status
+	if status == "" {
+		status = "active"
+	}
+	if !PlanListStatuses[status] {
+		return nil, invalidf("invalid plan status: %s (must be active, completed, archived, or all)", status)
+	}
 
-	query := s.fs.Collection("plans").
-		Where("uid", "==", req.UID).
-		Where("status", "==", "active").
-		OrderBy("created_at", firestore.Desc).
-		Limit(limit)
+	query := s.fs.Collection("plans").Where("uid", "==", req.UID)
+	if status != "all" {
+		query = query.Where("status", "==", status)
+	}
+	query = query.OrderBy("created_at", firestore.Desc).Limit(limit)
 
 	iter := query.Documents(ctx)
 	defer iter.Stop()
 
+	// The pinned plan comes first even if it's older than the rest of the page; only active
+	// plans are ever pinned
+	var pinned *models.Plan
+	if status == "active" || status == "all" {
+		var err error
+		if pinned, err = s.pinnedPlan(ctx, req.UID); err != nil {
+			return nil, err
+		}
+	}
+
 	plans := []models.Plan{}
+	if pinned != nil {
+		plans = append(plans, *pinned)
+	}
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
@@ -213,14 +332,123 @@ func (s *PlanService) ListActive(ctx context.Context, req PlanListRequest) (*Pla
 			return nil, fmt.Errorf("failed to parse plan: %w", err)
 		}
 
+		if pinned != nil && plan.ID == pinned.ID {
+			continue
+		}
 		plans = append(plans, plan)
 	}
+	if len(plans) > limit {
+		plans = plans[:limit]
+	}
 
 	return &PlanListResponse{
 		Plans: plans,
 	}, nil
 }
 
+// pinnedPlan returns the user's pinned active plan, or nil
+func (s *PlanService) pinnedPlan(ctx context.Context, uid string) (*models.Plan, error) {
+	docs, err := s.fs.Collection("plans").
+		Where("uid", "==", uid).
+		Where("pinned", "==", true).
+		Where("status", "==", "active").
+		Limit(1).
+		Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pinned plan: %w", err)
+	}
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	var plan models.Plan
+	if err := docs[0].DataTo(&plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// SetPinned pins or unpins one of the user's plans. Pinning unpins any other pinned plan in the
+// same transaction, so a user never has more than one.
+func (s *PlanService) SetPinned(ctx context.Context, uid, planID string, pinned bool) error {
+	ref := s.fs.Collection("plans").Doc(planID)
+	return s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return lookupError("plan", err)
+		}
+
+		var plan models.Plan
+		if err := doc.DataTo(&plan); err != nil {
+			return fmt.Errorf("failed to parse plan: %w", err)
+		}
+		if plan.UID != uid {
+			return fmt.Errorf("%w: plan belongs to different user", ErrUnauthorized)
+		}
+
+		now := models.Now()
+		if !pinned {
+			return tx.Update(ref, []firestore.Update{
+				{Path: "pinned", Value: false},
+				{Path: "updated_at", Value: now},
+			})
+		}
+
+		if plan.Status != "active" {
+			return invalidf("only active plans can be pinned")
+		}
+
+		// All reads happen before the writes, as transactions require
+		others, err := tx.Documents(s.fs.Collection("plans").
+			Where("uid", "==", uid).
+			Where("pinned", "==", true)).GetAll()
+		if err != nil {
+			return fmt.Errorf("failed to get pinned plans: %w", err)
+		}
+
+		for _, other := range others {
+			if other.Ref.ID == planID {
+				continue
+			}
+			if err := tx.Update(other.Ref, []firestore.Update{
+				{Path: "pinned", Value: false},
+				{Path: "updated_at", Value: now},
+			}); err != nil {
+				return err
+			}
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "pinned", Value: true},
+			{Path: "updated_at", Value: now},
+		})
+	})
+}
+
+// Archive archives one of the user's plans, unpinning it
+func (s *PlanService) Archive(ctx context.Context, uid, planID string) error {
+	ref := s.fs.Collection("plans").Doc(planID)
+	return s.fs.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return lookupError("plan", err)
+		}
+
+		var plan models.Plan
+		if err := doc.DataTo(&plan); err != nil {
+			return fmt.Errorf("failed to parse plan: %w", err)
+		}
+		if plan.UID != uid {
+			return fmt.Errorf("%w: plan belongs to different user", ErrUnauthorized)
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: "archived"},
+			{Path: "pinned", Value: false},
+			{Path: "updated_at", Value: models.Now()},
+		})
+	})
+}
+
 // ValidateAgainstCoachSpec validates a plan against CoachSpec output schema
 func (s *PlanService) ValidateAgainstCoachSpec(plan models.Plan, coachSpec *models.CoachSpec) error {
 	if coachSpec == nil {
@@ -234,7 +462,7 @@ func (s *PlanService) ValidateAgainstCoachSpec(plan models.Plan, coachSpec *mode
 	if props, ok := planSchema.Properties["milestones"].(map[string]interface{}); ok {
 		if maxItems, ok := props["maxItems"].(float64); ok {
 			if len(plan.Milestones) > int(maxItems) {
-				return fmt.Errorf("too many milestones (max %d per CoachSpec)", int(maxItems))
+				return invalidf("too many milestones (max %d per CoachSpec)", int(maxItems))
 			}
 		}
 	}
@@ -242,7 +470,7 @@ func (s *PlanService) ValidateAgainstCoachSpec(plan models.Plan, coachSpec *mode
 	if props, ok := planSchema.Properties["next_actions"].(map[string]interface{}); ok {
 		if maxItems, ok := props["maxItems"].(float64); ok {
 			if len(plan.NextActions) > int(maxItems) {
-				return fmt.Errorf("too many next actions (max %d per CoachSpec)", int(maxItems))
+				return invalidf("too many next actions (max %d per CoachSpec)", int(maxItems))
 			}
 		}
 	}
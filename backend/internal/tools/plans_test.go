@@ -0,0 +1,279 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestPlanIdempotencyKey(t *testing.T) {
+	key := PlanIdempotencyKey("s1", "Run a 10k")
+	if key == "" {
+		t.Fatal("empty key for a session plan")
+	}
+	if got := PlanIdempotencyKey("s1", "  run a 10K "); got != key {
+		t.Errorf("objective case and spacing changed the key: %q vs %q", got, key)
+	}
+	if got := PlanIdempotencyKey("s2", "Run a 10k"); got == key {
+		t.Error("different sessions share a key")
+	}
+	if got := PlanIdempotencyKey("s1", "Sleep by 11"); got == key {
+		t.Error("different objectives share a key")
+	}
+	if got := PlanIdempotencyKey("", "Run a 10k"); got != "" {
+		t.Errorf("key without a session = %q, want none", got)
+	}
+}
+
+func TestPlanTurnIdempotencyKey(t *testing.T) {
+	key := PlanTurnIdempotencyKey("s1", "m1")
+	if key == "" || key == PlanTurnIdempotencyKey("s1", "m2") || key == PlanTurnIdempotencyKey("s2", "m1") {
+		t.Errorf("turn keys aren't unique per session and message: %q", key)
+	}
+	if got := PlanTurnIdempotencyKey("s1", ""); got != "" {
+		t.Errorf("key without a message = %q, want none", got)
+	}
+}
+
+func TestPlanCreateIdempotent(t *testing.T) {
+	ctx := context.Background()
+	fs, server := firestoretest.NewWithServer(t)
+	svc := NewPlanService(fs.DB)
+	plan := models.Plan{Title: "10k plan", Objective: "Run a 10k", Horizon: "month"}
+	key := PlanIdempotencyKey("s1", plan.Objective)
+
+	// The planner and a confirmed plan_create call in the same turn
+	first, err := svc.Create(ctx, PlanCreateRequest{UID: "u1", SessionID: "s1", Plan: plan, IdempotencyKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Create(ctx, PlanCreateRequest{UID: "u1", SessionID: "s1", Plan: plan, IdempotencyKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Status != "created" || second.Status != "exists" || first.PlanID != second.PlanID {
+		t.Errorf("creates = %+v then %+v, want one plan returned twice", first, second)
+	}
+	if n := server.Len(); n != 1 {
+		t.Errorf("stored %d plans, want 1", n)
+	}
+
+	// Another user with the same key gets their own plan
+	other, err := svc.Create(ctx, PlanCreateRequest{UID: "u2", SessionID: "s1", Plan: plan, IdempotencyKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.PlanID == first.PlanID {
+		t.Error("idempotency keys leak across users")
+	}
+}
+
+func TestPlanCreateAfterArchive(t *testing.T) {
+	ctx := context.Background()
+	fs, server := firestoretest.NewWithServer(t)
+	svc := NewPlanService(fs.DB)
+	plan := models.Plan{Title: "10k plan", Objective: "Run a 10k", Horizon: "month"}
+	key := PlanIdempotencyKey("s1", plan.Objective)
+
+	first, err := svc.Create(ctx, PlanCreateRequest{UID: "u1", SessionID: "s1", Plan: plan, IdempotencyKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Archive(ctx, "u1", first.PlanID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A retry after the user archived the plan says so rather than reporting it as live
+	retry, err := svc.Create(ctx, PlanCreateRequest{UID: "u1", SessionID: "s1", Plan: plan, IdempotencyKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retry.Status != "archived" || retry.PlanID != first.PlanID {
+		t.Errorf("retry = %+v, want the archived plan %s reported as archived", retry, first.PlanID)
+	}
+	if got := getPlan(t, svc, first.PlanID).Status; got != "archived" {
+		t.Errorf("plan status = %q, want it left archived", got)
+	}
+	if n := server.Len(); n != 1 {
+		t.Errorf("stored %d plans, want 1", n)
+	}
+}
+
+func TestPlanCreateConcurrentDuplicates(t *testing.T) {
+	fs, server := firestoretest.NewWithServer(t)
+	svc := NewPlanService(fs.DB)
+	plan := models.Plan{Title: "Sleep plan", Objective: "Sleep by 11", Horizon: "week"}
+	key := PlanIdempotencyKey("s1", plan.Objective)
+
+	var wg sync.WaitGroup
+	statuses := make(chan string, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := svc.Create(context.Background(), PlanCreateRequest{UID: "u1", SessionID: "s1", Plan: plan, IdempotencyKey: key})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			statuses <- resp.Status
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	created := 0
+	for status := range statuses {
+		if status == "created" {
+			created++
+		}
+	}
+	if created != 1 || server.Len() != 1 {
+		t.Errorf("created %d times with %d stored plans, want exactly one", created, server.Len())
+	}
+}
+
+func TestPlanCreateWithoutKeyAlwaysCreates(t *testing.T) {
+	fs, server := firestoretest.NewWithServer(t)
+	svc := NewPlanService(fs.DB)
+	plan := models.Plan{Title: "Focus", Objective: "Deep work mornings", Horizon: "week"}
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Create(context.Background(), PlanCreateRequest{UID: "u1", Plan: plan}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := server.Len(); n != 2 {
+		t.Errorf("stored %d plans, want 2 without an idempotency key", n)
+	}
+}
+
+// createPlans creates one active plan per objective for uid, oldest first, and returns their IDs
+func createPlans(t *testing.T, svc *PlanService, uid string, objectives ...string) []string {
+	t.Helper()
+	ids := make([]string, len(objectives))
+	for i, objective := range objectives {
+		resp, err := svc.Create(context.Background(), PlanCreateRequest{
+			UID:  uid,
+			Plan: models.Plan{Title: objective, Objective: objective, Horizon: "week"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = resp.PlanID
+		time.Sleep(2 * time.Millisecond) // distinct created_at for ordering
+	}
+	return ids
+}
+
+func activeOrder(t *testing.T, svc *PlanService, uid string) ([]string, []bool) {
+	t.Helper()
+	resp, err := svc.ListActive(context.Background(), PlanListRequest{UID: uid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	var pinned []bool
+	for _, plan := range resp.Plans {
+		ids = append(ids, plan.ID)
+		pinned = append(pinned, plan.Pinned)
+	}
+	return ids, pinned
+}
+
+func TestPlanPinning(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPlanService(firestoretest.New(t).DB)
+	ids := createPlans(t, svc, "u1", "oldest", "middle", "newest")
+	oldest, middle, newest := ids[0], ids[1], ids[2]
+
+	order, _ := activeOrder(t, svc, "u1")
+	if !slices.Equal(order, []string{newest, middle, oldest}) {
+		t.Fatalf("unpinned order = %v, want newest first", order)
+	}
+
+	if err := svc.SetPinned(ctx, "u1", oldest, true); err != nil {
+		t.Fatal(err)
+	}
+	order, pinned := activeOrder(t, svc, "u1")
+	if !slices.Equal(order, []string{oldest, newest, middle}) || !pinned[0] {
+		t.Errorf("order = %v (pinned %v), want the pinned plan first", order, pinned)
+	}
+
+	// Pinning a second plan unpins the first
+	if err := svc.SetPinned(ctx, "u1", middle, true); err != nil {
+		t.Fatal(err)
+	}
+	order, pinned = activeOrder(t, svc, "u1")
+	if !slices.Equal(order, []string{middle, newest, oldest}) || !pinned[0] || pinned[1] || pinned[2] {
+		t.Errorf("order = %v (pinned %v), want only the second plan pinned, first", order, pinned)
+	}
+
+	if err := svc.SetPinned(ctx, "u1", middle, false); err != nil {
+		t.Fatal(err)
+	}
+	order, pinned = activeOrder(t, svc, "u1")
+	if !slices.Equal(order, []string{newest, middle, oldest}) || pinned[0] || pinned[1] || pinned[2] {
+		t.Errorf("order = %v (pinned %v) after unpin, want newest first and nothing pinned", order, pinned)
+	}
+}
+
+func TestPlanPinningErrors(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPlanService(firestoretest.New(t).DB)
+	ids := createPlans(t, svc, "u1", "mine", "done")
+	if err := svc.Archive(ctx, "u1", ids[1]); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		uid    string
+		planID string
+		want   error
+	}{
+		{"someone else's plan", "u2", ids[0], ErrUnauthorized},
+		{"archived plan", "u1", ids[1], ErrValidation},
+		{"missing plan", "u1", "plan_missing", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := svc.SetPinned(ctx, tt.uid, tt.planID, true); !errors.Is(err, tt.want) {
+				t.Errorf("SetPinned error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanPinningConcurrent(t *testing.T) {
+	svc := NewPlanService(firestoretest.New(t).DB)
+	ids := createPlans(t, svc, "u1", "a", "b", "c")
+
+	var wg sync.WaitGroup
+	for _, id := range ids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := svc.SetPinned(context.Background(), "u1", id, true); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, pinned := activeOrder(t, svc, "u1")
+	count := 0
+	for _, p := range pinned {
+		if p {
+			count++
+		}
+	}
+	if count != 1 || !pinned[0] {
+		t.Errorf("pinned = %v, want exactly one pinned plan, listed first", pinned)
+	}
+}
@@ -27,6 +27,7 @@ type Tool struct {
 	Owner                  ToolOwner
 	Category               ToolCategory
 	RequiresConfirmation   bool
+	ReadOnly               bool // never changes user data, so it's always safe to run without asking
 	PermissionDependencies []string
 	InputSchema            map[string]interface{}
 	OutputSchema           map[string]interface{}
@@ -98,16 +99,39 @@ func (r *Registry) ValidateInput(toolID string, input map[string]interface{}) er
 	}
 	
 	// Basic validation - check required fields
-	required, ok := tool.InputSchema["required"].([]interface{})
-	if ok {
+	for _, fieldName := range requiredFields(tool.InputSchema) {
+		if _, exists := input[fieldName]; !exists {
+			return fmt.Errorf("missing required field: %s", fieldName)
+		}
+	}
+
+	// Timestamps must be real RFC3339 values, not free text like "tomorrow at 3"
+	if err := validateTimestamps(input); err != nil {
+		return err
+	}
+
+	// Alarms must be a lead time or fire time the client can actually schedule
+	if _, err := NormalizeAlarms(input); err != nil {
+		return err
+	}
+	
+	return nil
+}
+
+// requiredFields lists a schema's required fields; schemas built in Go use []string, decoded ones []interface{}
+func requiredFields(schema map[string]interface{}) []string {
+	switch required := schema["required"].(type) {
+	case []string:
+		return required
+	case []interface{}:
+		fields := make([]string, 0, len(required))
 		for _, field := range required {
-			fieldName := field.(string)
-			if _, exists := input[fieldName]; !exists {
-				return fmt.Errorf("missing required field: %s", fieldName)
+			if name, ok := field.(string); ok {
+				fields = append(fields, name)
 			}
 		}
+		return fields
 	}
-	
 	return nil
 }
 
@@ -196,7 +220,7 @@ func (r *Registry) registerClientTools() {
 					"items": map[string]interface{}{
 						"type": "object",
 						"properties": map[string]interface{}{
-							"lead_minutes": map[string]interface{}{"type": "integer"},
+							"lead_minutes": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": MaxAlarmLeadMinutes},
 						},
 					},
 				},
@@ -232,7 +256,7 @@ func (r *Registry) registerClientTools() {
 					"items": map[string]interface{}{
 						"type": "object",
 						"properties": map[string]interface{}{
-							"lead_minutes": map[string]interface{}{"type": "integer"},
+							"lead_minutes": map[string]interface{}{"type": "integer", "minimum": 0, "maximum": MaxAlarmLeadMinutes},
 						},
 					},
 				},
@@ -287,6 +311,7 @@ func (r *Registry) registerServerTools() {
 		Owner:                  ToolOwnerGo,
 		Category:               ToolCategoryServer,
 		RequiresConfirmation:   false,
+		ReadOnly:               true,
 		PermissionDependencies: []string{},
 		InputSchema: map[string]interface{}{
 			"type": "object",
@@ -295,6 +320,10 @@ func (r *Registry) registerServerTools() {
 				"uid":   map[string]interface{}{"type": "string"},
 				"query": map[string]interface{}{"type": "string"},
 				"limit": map[string]interface{}{"type": "integer"},
+				"mode": map[string]interface{}{
+					"type": "string",
+					"enum": []string{MemoryModeKeyword, MemoryModeSemantic, MemoryModeHybrid},
+				},
 			},
 		},
 		OutputSchema: map[string]interface{}{
@@ -368,6 +397,7 @@ func (r *Registry) registerServerTools() {
 						"horizon":      map[string]interface{}{"type": "string"},
 						"milestones":   map[string]interface{}{"type": "array"},
 						"next_actions": map[string]interface{}{"type": "array"},
+						"recurrence":   map[string]interface{}{"type": "object"},
 					},
 				},
 			},
@@ -411,6 +441,7 @@ func (r *Registry) registerServerTools() {
 		Owner:                  ToolOwnerGo,
 		Category:               ToolCategoryServer,
 		RequiresConfirmation:   false,
+		ReadOnly:               true,
 		PermissionDependencies: []string{},
 		InputSchema: map[string]interface{}{
 			"type": "object",
@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// isoFieldSuffix marks tool input fields that must hold RFC3339 timestamps
+// (start_iso, end_iso, due_iso, fire_at_iso, ...)
+const isoFieldSuffix = "_iso"
+
+// validateTimestamps checks every *_iso field in input, including nested objects and
+// arrays, and requires end_iso to fall after start_iso when both are present
+func validateTimestamps(input map[string]interface{}) error {
+	if err := walkTimestamps("", input); err != nil {
+		return err
+	}
+
+	start, hasStart := input["start_iso"].(string)
+	end, hasEnd := input["end_iso"].(string)
+	if hasStart && hasEnd {
+		startAt, _ := time.Parse(time.RFC3339, start)
+		endAt, _ := time.Parse(time.RFC3339, end)
+		if !endAt.After(startAt) {
+			return fmt.Errorf("end_iso must be after start_iso")
+		}
+	}
+
+	return nil
+}
+
+// walkTimestamps validates *_iso fields under value, reporting the dotted path of the first bad one
+func walkTimestamps(path string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		// Sorted so the reported field is deterministic
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+
+			if strings.HasSuffix(key, isoFieldSuffix) {
+				if err := validateISOField(fieldPath, v[key]); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := walkTimestamps(fieldPath, v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			if err := walkTimestamps(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateISOField requires a non-empty RFC3339 string; null means the field is unset
+func validateISOField(path string, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s must be a string", path)
+	}
+	if _, err := time.Parse(time.RFC3339, s); err != nil {
+		return fmt.Errorf("%s must be an RFC3339 timestamp (e.g. 2025-01-15T15:00:00Z), got %q", path, s)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTimestampsAccepts(t *testing.T) {
+	tests := map[string]map[string]interface{}{
+		"utc event":         {"start_iso": "2026-04-15T15:00:00Z", "end_iso": "2026-04-15T16:00:00Z"},
+		"offset event":      {"start_iso": "2026-04-15T15:00:00+03:00", "end_iso": "2026-04-15T13:30:00Z"},
+		"fractional second": {"due_iso": "2026-04-15T15:00:00.250Z"},
+		"null due":          {"due_iso": nil},
+		"nested trigger":    {"trigger": map[string]interface{}{"kind": "at_datetime", "fire_at_iso": "2026-04-15T09:00:00Z"}},
+		"no timestamps":     {"title": "Stretch"},
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := validateTimestamps(input); err != nil {
+				t.Errorf("rejected %v: %v", input, err)
+			}
+		})
+	}
+}
+
+func TestValidateTimestampsRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]interface{}
+		field string
+	}{
+		{"free text", map[string]interface{}{"start_iso": "tomorrow at 3", "end_iso": "2026-04-15T16:00:00Z"}, "start_iso"},
+		{"date only", map[string]interface{}{"due_iso": "2026-04-15"}, "due_iso"},
+		{"missing zone", map[string]interface{}{"end_iso": "2026-04-15T16:00:00"}, "end_iso"},
+		{"empty", map[string]interface{}{"due_iso": ""}, "due_iso"},
+		{"not a string", map[string]interface{}{"due_iso": float64(1744729200)}, "due_iso"},
+		{"impossible date", map[string]interface{}{"due_iso": "2026-02-30T10:00:00Z"}, "due_iso"},
+		{"nested", map[string]interface{}{"trigger": map[string]interface{}{"fire_at_iso": "9am"}}, "trigger.fire_at_iso"},
+		{"in an array", map[string]interface{}{"alarms": []interface{}{map[string]interface{}{"fire_at_iso": "soon"}}}, "alarms[0].fire_at_iso"},
+		{"end before start", map[string]interface{}{"start_iso": "2026-04-15T16:00:00Z", "end_iso": "2026-04-15T15:00:00Z"}, "end_iso must be after start_iso"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTimestamps(tt.input)
+			if err == nil {
+				t.Fatalf("accepted %v", tt.input)
+			}
+			if !strings.HasPrefix(err.Error(), tt.field) {
+				t.Errorf("error = %q, want it to name %s", err, tt.field)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package tools
+
+// immutablePaths are identity and audit fields no update may touch
+var immutablePaths = map[string]bool{
+	"id":         true,
+	"uid":        true,
+	"created_at": true,
+}
+
+// planUpdatablePaths are the plan fields a client may change through PlanService.Update
+var planUpdatablePaths = map[string]bool{
+	"title":        true,
+	"objective":    true,
+	"horizon":      true,
+	"milestones":   true,
+	"next_actions": true,
+	"status":       true,
+}
+
+// checkinUpdatablePaths are the check-in fields a client may change through CheckinService.Update
+var checkinUpdatablePaths = map[string]bool{
+	"cadence": true,
+	"channel": true,
+	"status":  true,
+}
+
+// checkUpdatePath rejects an update path outside a resource's allow-list. Paths are matched
+// exactly, so dotted paths into nested fields are rejected too.
+func checkUpdatePath(resource, path string, allowed map[string]bool) error {
+	if immutablePaths[path] {
+		return invalidf("%s cannot be changed", path)
+	}
+	if !allowed[path] {
+		return invalidf("%s field %q cannot be updated", resource, path)
+	}
+	return nil
+}
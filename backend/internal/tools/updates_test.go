@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"simon-backend/internal/firestore/firestoretest"
+	"simon-backend/internal/models"
+)
+
+func TestPlanUpdateAllowList(t *testing.T) {
+	ctx := context.Background()
+	svc := NewPlanService(firestoretest.New(t).DB)
+	id := createPlans(t, svc, "u1", "Run a 10k")[0]
+
+	if _, err := svc.Update(ctx, PlanUpdateRequest{UID: "u1", PlanID: id, Updates: map[string]interface{}{"title": "Run a half marathon"}}); err != nil {
+		t.Fatalf("title update: %v", err)
+	}
+	if plan := getPlan(t, svc, id); plan.Title != "Run a half marathon" {
+		t.Errorf("title = %q, want the update applied", plan.Title)
+	}
+
+	for _, path := range []string{"uid", "id", "created_at", "pinned", "recurrence", "title.en"} {
+		t.Run(path, func(t *testing.T) {
+			_, err := svc.Update(ctx, PlanUpdateRequest{UID: "u1", PlanID: id, Updates: map[string]interface{}{
+				"title": "Sneaked in",
+				path:    "u2",
+			}})
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("update of %s: error = %v, want ErrValidation", path, err)
+			}
+		})
+	}
+	// A rejected path rejects the whole update
+	if plan := getPlan(t, svc, id); plan.UID != "u1" || plan.Title != "Run a half marathon" {
+		t.Errorf("plan = uid %q title %q after rejected updates", plan.UID, plan.Title)
+	}
+}
+
+func TestCheckinUpdateAllowList(t *testing.T) {
+	ctx := context.Background()
+	svc := NewCheckinService(firestoretest.New(t).DB)
+	scheduled, err := svc.Schedule(ctx, CheckinScheduleRequest{
+		UID:     "u1",
+		Cadence: models.CheckinCadence{Kind: "daily", Hour: 9},
+		Channel: "in_app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := scheduled.CheckinID
+
+	if _, err := svc.Update(ctx, CheckinUpdateRequest{UID: "u1", CheckinID: id, Updates: map[string]interface{}{"channel": "local_notification_proposal"}}); err != nil {
+		t.Fatalf("channel update: %v", err)
+	}
+	if checkin := getCheckin(t, svc, id); checkin.Channel != "local_notification_proposal" {
+		t.Errorf("channel = %q, want the update applied", checkin.Channel)
+	}
+
+	// Check-ins have no title, so it's outside their allow-list like the identity fields
+	for _, path := range []string{"uid", "id", "created_at", "title", "next_run_at", "nudge_index"} {
+		t.Run(path, func(t *testing.T) {
+			_, err := svc.Update(ctx, CheckinUpdateRequest{UID: "u1", CheckinID: id, Updates: map[string]interface{}{path: "u2"}})
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("update of %s: error = %v, want ErrValidation", path, err)
+			}
+		})
+	}
+	if checkin := getCheckin(t, svc, id); checkin.UID != "u1" {
+		t.Errorf("uid = %q after rejected updates", checkin.UID)
+	}
+
+	if _, err := svc.Update(ctx, CheckinUpdateRequest{UID: "u1", CheckinID: id, Updates: map[string]interface{}{"status": "deleted"}}); !errors.Is(err, ErrValidation) {
+		t.Errorf("status deleted: error = %v, want ErrValidation", err)
+	}
+}
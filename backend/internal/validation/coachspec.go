@@ -1,121 +1,218 @@
 package validation
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"simon-backend/internal/models"
+	"simon-backend/internal/redact"
 )
 
+// FieldError describes a single validation failure at a machine-readable path
+type FieldError struct {
+	Path    string `json:"path"`    // e.g. "coachSpec.identity.name"
+	Message string `json:"message"` // e.g. "name is required"
+	context string // human-readable section prefix used by Error()
+}
+
+// Error formats the field error the way ValidateCoachSpec always has
+func (e FieldError) Error() string {
+	if e.context == "" {
+		return e.Message
+	}
+	return e.context + ": " + e.Message
+}
+
+// fieldErrors accumulates validation failures
+type fieldErrors []FieldError
+
+// add records a failure at path with a formatted message
+func (errs *fieldErrors) add(path, format string, args ...interface{}) {
+	*errs = append(*errs, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// nest prefixes each error's path and context with a parent section
+func nest(section string, errs []FieldError) []FieldError {
+	nested := make([]FieldError, len(errs))
+	for i, e := range errs {
+		e.Path = section + "." + e.Path
+		if e.context == "" {
+			e.context = section
+		} else {
+			e.context = section + ": " + e.context
+		}
+		nested[i] = e
+	}
+	return nested
+}
+
 // ValidateCoachSpec validates a CoachSpec structure
-// Returns an error if validation fails, nil if valid
+// Returns the first error if validation fails, nil if valid
 func ValidateCoachSpec(spec *models.CoachSpec) error {
+	if errs := ValidateCoachSpecAll(spec); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateCoachSpecAll validates a CoachSpec and returns every violation found
+func ValidateCoachSpecAll(spec *models.CoachSpec) []FieldError {
 	if spec == nil {
 		// CoachSpec is optional, so nil is valid
 		return nil
 	}
 
+	var errs []FieldError
+
 	// Validate version
 	if spec.Version == "" {
-		return fmt.Errorf("coachSpec.version is required")
+		errs = append(errs, FieldError{Path: "coachSpec.version", Message: "coachSpec.version is required"})
 	}
 
-	// Validate Identity
-	if err := validateIdentity(&spec.Identity); err != nil {
-		return fmt.Errorf("coachSpec.identity: %w", err)
+	if spec.DefaultMode != "" && !models.ValidSessionMode(spec.DefaultMode) {
+		errs = append(errs, FieldError{Path: "coachSpec.default_mode", Message: "coachSpec.default_mode must be one of: quick, system, deep"})
 	}
 
-	// Validate Style
-	if err := validateStyle(&spec.Style); err != nil {
-		return fmt.Errorf("coachSpec.style: %w", err)
-	}
+	errs = append(errs, nest("coachSpec.identity", validateIdentity(&spec.Identity))...)
+	errs = append(errs, nest("coachSpec.style", validateStyle(&spec.Style))...)
+	errs = append(errs, nest("coachSpec.methods", validateMethods(&spec.Methods))...)
+	errs = append(errs, nest("coachSpec.policies", validatePolicies(&spec.Policies))...)
+	errs = append(errs, nest("coachSpec.tools_allowed", validateToolsAllowed(&spec.ToolsAllowed))...)
+	errs = append(errs, nest("coachSpec.outputs", validateOutputs(&spec.Outputs))...)
 
-	// Validate Methods
-	if err := validateMethods(&spec.Methods); err != nil {
-		return fmt.Errorf("coachSpec.methods: %w", err)
-	}
+	return errs
+}
 
-	// Validate Policies
-	if err := validatePolicies(&spec.Policies); err != nil {
-		return fmt.Errorf("coachSpec.policies: %w", err)
-	}
+// ErrUnknownSection is returned by ValidateCoachSpecSection for an unrecognized section name
+var ErrUnknownSection = errors.New("section must be one of: identity, style, methods, policies, tools, outputs")
 
-	// Validate ToolsAllowed
-	if err := validateToolsAllowed(&spec.ToolsAllowed); err != nil {
-		return fmt.Errorf("coachSpec.tools_allowed: %w", err)
-	}
+// ValidateCoachSpecSection validates a single CoachSpec section in isolation, so a
+// half-filled draft can be checked without errors from sections not yet written.
+// A non-nil error means the section name or JSON was invalid, not that validation failed.
+func ValidateCoachSpecSection(section string, data []byte) ([]FieldError, error) {
+	var errs []FieldError
 
-	// Validate Outputs
-	if err := validateOutputs(&spec.Outputs); err != nil {
-		return fmt.Errorf("coachSpec.outputs: %w", err)
+	switch section {
+	case "identity":
+		var identity models.Identity
+		if err := json.Unmarshal(data, &identity); err != nil {
+			return nil, fmt.Errorf("invalid identity: %w", err)
+		}
+		errs = nest("coachSpec.identity", validateIdentity(&identity))
+	case "style":
+		var style models.Style
+		if err := json.Unmarshal(data, &style); err != nil {
+			return nil, fmt.Errorf("invalid style: %w", err)
+		}
+		errs = nest("coachSpec.style", validateStyle(&style))
+	case "methods":
+		var methods models.Methods
+		if err := json.Unmarshal(data, &methods); err != nil {
+			return nil, fmt.Errorf("invalid methods: %w", err)
+		}
+		errs = nest("coachSpec.methods", validateMethods(&methods))
+	case "policies":
+		var policies models.Policies
+		if err := json.Unmarshal(data, &policies); err != nil {
+			return nil, fmt.Errorf("invalid policies: %w", err)
+		}
+		errs = nest("coachSpec.policies", validatePolicies(&policies))
+	case "tools", "tools_allowed":
+		var tools models.ToolsAllowed
+		if err := json.Unmarshal(data, &tools); err != nil {
+			return nil, fmt.Errorf("invalid tools: %w", err)
+		}
+		errs = nest("coachSpec.tools_allowed", validateToolsAllowed(&tools))
+	case "outputs":
+		var outputs models.Outputs
+		if err := json.Unmarshal(data, &outputs); err != nil {
+			return nil, fmt.Errorf("invalid outputs: %w", err)
+		}
+		errs = nest("coachSpec.outputs", validateOutputs(&outputs))
+	default:
+		return nil, ErrUnknownSection
 	}
 
-	return nil
+	return errs, nil
 }
 
-func validateIdentity(identity *models.Identity) error {
+func validateIdentity(identity *models.Identity) []FieldError {
+	var errs fieldErrors
+
 	if identity.Name == "" {
-		return fmt.Errorf("name is required")
+		errs.add("name", "name is required")
 	}
 	if len(identity.Name) > 100 {
-		return fmt.Errorf("name must be <= 100 characters")
+		errs.add("name", "name must be <= 100 characters")
 	}
 
 	if identity.Tagline == "" {
-		return fmt.Errorf("tagline is required")
+		errs.add("tagline", "tagline is required")
 	}
 	if len(identity.Tagline) > 200 {
-		return fmt.Errorf("tagline must be <= 200 characters")
+		errs.add("tagline", "tagline must be <= 200 characters")
 	}
 
 	if identity.Niche == "" {
-		return fmt.Errorf("niche is required")
+		errs.add("niche", "niche is required")
 	}
 
 	if len(identity.Audience) == 0 {
-		return fmt.Errorf("audience must have at least one entry")
+		errs.add("audience", "audience must have at least one entry")
 	}
 
 	if len(identity.Languages) == 0 {
-		return fmt.Errorf("languages must have at least one entry")
+		errs.add("languages", "languages must have at least one entry")
 	}
 
 	// Validate Persona
 	if identity.Persona.Archetype == "" {
-		return fmt.Errorf("persona.archetype is required")
+		errs.add("persona.archetype", "persona.archetype is required")
 	}
 	if identity.Persona.Voice == "" {
-		return fmt.Errorf("persona.voice is required")
+		errs.add("persona.voice", "persona.voice is required")
 	}
 
-	return nil
+	// Validate starter prompts
+	if len(identity.StarterPrompts) > models.MaxStarterPrompts {
+		errs.add("starterPrompts", "starterPrompts must have at most %d entries", models.MaxStarterPrompts)
+	}
+	for i, prompt := range identity.StarterPrompts {
+		if strings.TrimSpace(prompt) == "" {
+			errs.add(fmt.Sprintf("starterPrompts[%d]", i), "starterPrompts[%d] cannot be empty", i)
+		}
+		if utf8.RuneCountInString(prompt) > models.MaxStarterPromptRunes {
+			errs.add(fmt.Sprintf("starterPrompts[%d]", i), "starterPrompts[%d] must be <= %d characters", i, models.MaxStarterPromptRunes)
+		}
+	}
+
+	return errs
 }
 
-func validateStyle(style *models.Style) error {
-	if style.Tone == "" {
-		return fmt.Errorf("tone is required")
-	}
+func validateStyle(style *models.Style) []FieldError {
+	var errs fieldErrors
 
-	if style.Verbosity == "" {
-		return fmt.Errorf("verbosity is required")
+	if style.Tone == "" {
+		errs.add("tone", "tone is required")
 	}
 
 	// Validate verbosity values
-	validVerbosity := map[string]bool{
-		"low":    true,
-		"medium": true,
-		"high":   true,
-	}
-	if !validVerbosity[style.Verbosity] {
-		return fmt.Errorf("verbosity must be one of: low, medium, high")
+	if style.Verbosity == "" {
+		errs.add("verbosity", "verbosity is required")
+	} else if !validVerbosity[style.Verbosity] {
+		errs.add("verbosity", "verbosity must be one of: low, medium, high")
 	}
 
 	// Validate Formatting
 	if style.Formatting.MaxBullets < 0 {
-		return fmt.Errorf("formatting.maxBullets must be >= 0")
+		errs.add("formatting.maxBullets", "formatting.maxBullets must be >= 0")
 	}
 	if style.Formatting.MaxSentencesPerParagraph < 0 {
-		return fmt.Errorf("formatting.maxSentencesPerParagraph must be >= 0")
+		errs.add("formatting.maxSentencesPerParagraph", "formatting.maxSentencesPerParagraph must be >= 0")
 	}
 
 	// Validate allowed markdown
@@ -127,36 +224,86 @@ func validateStyle(style *models.Style) error {
 		"code":          true,
 		"heading":       true,
 	}
-	for _, md := range style.Formatting.AllowedMarkdown {
+	for i, md := range style.Formatting.AllowedMarkdown {
 		if !validMarkdown[md] {
-			return fmt.Errorf("formatting.allowedMarkdown contains invalid value: %s", md)
+			errs.add(fmt.Sprintf("formatting.allowedMarkdown[%d]", i), "formatting.allowedMarkdown contains invalid value: %s", md)
 		}
 	}
 
-	return nil
+	return errs
+}
+
+// validVerbosity lists the allowed style.verbosity values
+var validVerbosity = map[string]bool{
+	"low":    true,
+	"medium": true,
+	"high":   true,
+}
+
+// validToneAdjustments lists the one-turn tone shifts accepted by ValidateStyleAdjustment
+var validToneAdjustments = map[string]bool{
+	"warmer":      true,
+	"more_direct": true,
+	"less_formal": true,
+	"more_formal": true,
+}
+
+// ValidateStyleAdjustment validates a one-turn style override against the style enums
+func ValidateStyleAdjustment(adjust *models.StyleAdjustment) []FieldError {
+	if adjust == nil {
+		return nil
+	}
+
+	var errs fieldErrors
+	if adjust.Verbosity != "" && !validVerbosity[adjust.Verbosity] {
+		errs.add("adjust.verbosity", "verbosity must be one of: low, medium, high")
+	}
+	if adjust.Tone != "" && !validToneAdjustments[adjust.Tone] {
+		errs.add("adjust.tone", "tone must be one of: warmer, more_direct, less_formal, more_formal")
+	}
+	if adjust.Verbosity == "" && adjust.Tone == "" {
+		errs.add("adjust", "adjust must set verbosity or tone")
+	}
+	return errs
 }
 
-func validateMethods(methods *models.Methods) error {
+func validateMethods(methods *models.Methods) []FieldError {
+	var errs fieldErrors
+
 	// Frameworks are optional, but if present, validate them
 	for i, framework := range methods.Frameworks {
+		path := fmt.Sprintf("frameworks[%d]", i)
 		if framework.ID == "" {
-			return fmt.Errorf("frameworks[%d].id is required", i)
+			errs.add(path+".id", "frameworks[%d].id is required", i)
 		}
 		if framework.Name == "" {
-			return fmt.Errorf("frameworks[%d].name is required", i)
+			errs.add(path+".name", "frameworks[%d].name is required", i)
 		}
 		if framework.Goal == "" {
-			return fmt.Errorf("frameworks[%d].goal is required", i)
+			errs.add(path+".goal", "frameworks[%d].goal is required", i)
 		}
 		if len(framework.Steps) == 0 {
-			return fmt.Errorf("frameworks[%d].steps must have at least one entry", i)
+			errs.add(path+".steps", "frameworks[%d].steps must have at least one entry", i)
+		}
+		for j, trigger := range framework.WhenToUse {
+			if !models.IsFrameworkTrigger(trigger) {
+				errs.add(fmt.Sprintf("%s.whenToUse[%d]", path, j), "frameworks[%d].whenToUse contains unknown trigger: %s", i, trigger)
+			}
 		}
 	}
 
-	return nil
+	return errs
 }
 
-func validatePolicies(policies *models.Policies) error {
+// Limits on a coach's redact patterns, which run against every message it stores
+const (
+	maxRedactPatterns     = 20
+	maxRedactPatternRunes = 200
+)
+
+func validatePolicies(policies *models.Policies) []FieldError {
+	var errs fieldErrors
+
 	// Validate financial_advice values
 	if policies.Refusals.FinancialAdvice != "" {
 		validFinancialAdvice := map[string]bool{
@@ -164,7 +311,7 @@ func validatePolicies(policies *models.Policies) error {
 			"none":         true,
 		}
 		if !validFinancialAdvice[policies.Refusals.FinancialAdvice] {
-			return fmt.Errorf("refusals.financial_advice must be one of: general_only, none")
+			errs.add("refusals.financial_advice", "refusals.financial_advice must be one of: general_only, none")
 		}
 	}
 
@@ -175,21 +322,62 @@ func validatePolicies(policies *models.Policies) error {
 			"refuse":           true,
 		}
 		if !validSelfHarm[policies.Refusals.SelfHarm] {
-			return fmt.Errorf("refusals.self_harm must be one of: escalate_support, refuse")
+			errs.add("refusals.self_harm", "refusals.self_harm must be one of: escalate_support, refuse")
+		}
+	}
+
+	// Validate sensitivity values
+	if policies.Refusals.Sensitivity != "" {
+		validSensitivity := map[string]bool{
+			"standard": true,
+			"strict":   true,
+		}
+		if !validSensitivity[policies.Refusals.Sensitivity] {
+			errs.add("refusals.sensitivity", "refusals.sensitivity must be one of: standard, strict")
 		}
 	}
 
-	// Validate redact patterns
+	// Validate refusal message overrides
+	validRefusalMessages := map[string]bool{
+		"medical":   true,
+		"legal":     true,
+		"financial": true,
+	}
+	kinds := make([]string, 0, len(policies.Refusals.Messages))
+	for kind := range policies.Refusals.Messages {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		msg := policies.Refusals.Messages[kind]
+		if !validRefusalMessages[kind] {
+			errs.add("refusals.messages."+kind, "refusals.messages must use one of: medical, legal, financial")
+		} else if utf8.RuneCountInString(msg) > 300 {
+			errs.add("refusals.messages."+kind, "refusals.messages.%s must be <= 300 characters", kind)
+		}
+	}
+
+	// Validate redact patterns; "/.../" patterns are regular expressions
+	if len(policies.Privacy.RedactPatterns) > maxRedactPatterns {
+		errs.add("privacy.redactPatterns", "privacy.redactPatterns must have at most %d entries", maxRedactPatterns)
+	}
 	for i, pattern := range policies.Privacy.RedactPatterns {
+		path := fmt.Sprintf("privacy.redactPatterns[%d]", i)
 		if pattern == "" {
-			return fmt.Errorf("privacy.redactPatterns[%d] cannot be empty", i)
+			errs.add(path, "privacy.redactPatterns[%d] cannot be empty", i)
+		} else if utf8.RuneCountInString(pattern) > maxRedactPatternRunes {
+			errs.add(path, "privacy.redactPatterns[%d] must be <= %d characters", i, maxRedactPatternRunes)
+		} else if _, err := redact.Compile(pattern); err != nil {
+			errs.add(path, "privacy.redactPatterns[%d] is not a valid redact pattern: %v", i, err)
 		}
 	}
 
-	return nil
+	return errs
 }
 
-func validateToolsAllowed(tools *models.ToolsAllowed) error {
+func validateToolsAllowed(tools *models.ToolsAllowed) []FieldError {
+	var errs fieldErrors
+
 	// Define valid tool IDs
 	validClientTools := map[string]bool{
 		"local_notification_schedule": true,
@@ -199,25 +387,25 @@ func validateToolsAllowed(tools *models.ToolsAllowed) error {
 	}
 
 	validServerTools := map[string]bool{
-		"memory_read":       true,
-		"memory_write":      true,
-		"plan_create":       true,
-		"plan_update":       true,
-		"plan_list_active":  true,
-		"checkin_schedule":  true,
+		"memory_read":      true,
+		"memory_write":     true,
+		"plan_create":      true,
+		"plan_update":      true,
+		"plan_list_active": true,
+		"checkin_schedule": true,
 	}
 
 	// Validate client tools
-	for _, tool := range tools.ClientTools {
+	for i, tool := range tools.ClientTools {
 		if !validClientTools[tool] {
-			return fmt.Errorf("client_tools contains invalid tool: %s", tool)
+			errs.add(fmt.Sprintf("client_tools[%d]", i), "client_tools contains invalid tool: %s", tool)
 		}
 	}
 
 	// Validate server tools
-	for _, tool := range tools.ServerTools {
+	for i, tool := range tools.ServerTools {
 		if !validServerTools[tool] {
-			return fmt.Errorf("server_tools contains invalid tool: %s", tool)
+			errs.add(fmt.Sprintf("server_tools[%d]", i), "server_tools contains invalid tool: %s", tool)
 		}
 	}
 
@@ -227,126 +415,136 @@ func validateToolsAllowed(tools *models.ToolsAllowed) error {
 		clientToolsMap[tool] = true
 	}
 
-	for _, tool := range tools.RequiresUserConfirmation {
+	for i, tool := range tools.RequiresUserConfirmation {
 		if !clientToolsMap[tool] {
-			return fmt.Errorf("requires_user_confirmation contains tool not in client_tools: %s", tool)
+			errs.add(fmt.Sprintf("requires_user_confirmation[%d]", i), "requires_user_confirmation contains tool not in client_tools: %s", tool)
 		}
 	}
 
-	return nil
+	return errs
 }
 
-func validateOutputs(outputs *models.Outputs) error {
+func validateOutputs(outputs *models.Outputs) []FieldError {
+	var errs []FieldError
+
 	// Validate schemas
-	if err := validateSchemaDefinition("Plan", &outputs.Schemas.Plan); err != nil {
-		return fmt.Errorf("schemas.Plan: %w", err)
-	}
-	if err := validateSchemaDefinition("NextAction", &outputs.Schemas.NextAction); err != nil {
-		return fmt.Errorf("schemas.NextAction: %w", err)
-	}
-	if err := validateSchemaDefinition("WeeklyReview", &outputs.Schemas.WeeklyReview); err != nil {
-		return fmt.Errorf("schemas.WeeklyReview: %w", err)
-	}
+	errs = append(errs, nest("schemas.Plan", validateSchemaDefinition("Plan", &outputs.Schemas.Plan))...)
+	errs = append(errs, nest("schemas.NextAction", validateSchemaDefinition("NextAction", &outputs.Schemas.NextAction))...)
+	errs = append(errs, nest("schemas.WeeklyReview", validateSchemaDefinition("WeeklyReview", &outputs.Schemas.WeeklyReview))...)
+
+	hints := fieldErrors{}
 
 	// Validate rendering hints
 	if outputs.RenderingHints.PrimaryCard != "" {
 		validPrimaryCards := map[string]bool{
-			"next_actions":   true,
-			"plan":           true,
-			"weekly_review":  true,
+			"next_actions":  true,
+			"plan":          true,
+			"weekly_review": true,
 		}
 		if !validPrimaryCards[outputs.RenderingHints.PrimaryCard] {
-			return fmt.Errorf("rendering_hints.primaryCard must be one of: next_actions, plan, weekly_review")
+			hints.add("rendering_hints.primaryCard", "rendering_hints.primaryCard must be one of: next_actions, plan, weekly_review")
 		}
 	}
 
 	if outputs.RenderingHints.MaxCardsPerResponse < 0 {
-		return fmt.Errorf("rendering_hints.maxCardsPerResponse must be >= 0")
+		hints.add("rendering_hints.maxCardsPerResponse", "rendering_hints.maxCardsPerResponse must be >= 0")
 	}
 
-	return nil
+	return append(errs, hints...)
 }
 
-func validateSchemaDefinition(name string, schema *models.SchemaDefinition) error {
-	if schema.Type == "" {
-		return fmt.Errorf("type is required")
-	}
+func validateSchemaDefinition(name string, schema *models.SchemaDefinition) []FieldError {
+	var errs fieldErrors
 
 	// Validate type values
 	validTypes := map[string]bool{
-		"object": true,
-		"array":  true,
-		"string": true,
-		"number": true,
+		"object":  true,
+		"array":   true,
+		"string":  true,
+		"number":  true,
 		"boolean": true,
 		"integer": true,
 	}
+	if schema.Type == "" {
+		errs.add("type", "type is required")
+		return errs
+	}
 	if !validTypes[schema.Type] {
-		return fmt.Errorf("type must be one of: object, array, string, number, boolean, integer")
+		errs.add("type", "type must be one of: object, array, string, number, boolean, integer")
 	}
 
 	// For object types, properties should be defined
 	if schema.Type == "object" && len(schema.Properties) == 0 {
-		return fmt.Errorf("object type must have properties defined")
+		errs.add("properties", "object type must have properties defined")
 	}
 
 	// Validate required fields exist in properties
 	if schema.Type == "object" {
-		for _, requiredField := range schema.Required {
+		for i, requiredField := range schema.Required {
 			if _, exists := schema.Properties[requiredField]; !exists {
-				return fmt.Errorf("required field '%s' not found in properties", requiredField)
+				errs.add(fmt.Sprintf("required[%d]", i), "required field '%s' not found in properties", requiredField)
 			}
 		}
 	}
 
-	return nil
+	return errs
 }
 
 // ValidateCoachForCreate validates a coach before creation
 func ValidateCoachForCreate(coach *models.Coach) error {
+	if errs := ValidateCoachForCreateAll(coach); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateCoachForCreateAll validates a coach before creation and returns every violation
+func ValidateCoachForCreateAll(coach *models.Coach) []FieldError {
+	var errs fieldErrors
+
 	// Basic field validation
 	if coach.Title == "" || len(coach.Title) > 60 {
-		return fmt.Errorf("title must be 1-60 characters")
+		errs.add("title", "title must be 1-60 characters")
 	}
 
 	if len(coach.Promise) > 140 {
-		return fmt.Errorf("promise must be <= 140 characters")
+		errs.add("promise", "promise must be <= 140 characters")
 	}
 
 	// Validate CoachSpec if present
-	if coach.CoachSpec != nil {
-		if err := ValidateCoachSpec(coach.CoachSpec); err != nil {
-			return err
-		}
-	}
+	all := append([]FieldError(errs), ValidateCoachSpecAll(coach.CoachSpec)...)
 
 	// At least one of Blueprint or CoachSpec must be present
 	if coach.Blueprint == nil && coach.CoachSpec == nil {
-		return fmt.Errorf("either blueprint or coachSpec must be provided")
+		all = append(all, FieldError{Path: "coachSpec", Message: "either blueprint or coachSpec must be provided"})
 	}
 
-	return nil
+	return all
 }
 
 // ValidateCoachForUpdate validates a coach before update
 func ValidateCoachForUpdate(coach *models.Coach) error {
+	if errs := ValidateCoachForUpdateAll(coach); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateCoachForUpdateAll validates a coach before update and returns every violation
+func ValidateCoachForUpdateAll(coach *models.Coach) []FieldError {
+	var errs fieldErrors
+
 	// Basic field validation
 	if coach.Title != "" && len(coach.Title) > 60 {
-		return fmt.Errorf("title must be <= 60 characters")
+		errs.add("title", "title must be <= 60 characters")
 	}
 
 	if len(coach.Promise) > 140 {
-		return fmt.Errorf("promise must be <= 140 characters")
+		errs.add("promise", "promise must be <= 140 characters")
 	}
 
 	// Validate CoachSpec if present
-	if coach.CoachSpec != nil {
-		if err := ValidateCoachSpec(coach.CoachSpec); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return append([]FieldError(errs), ValidateCoachSpecAll(coach.CoachSpec)...)
 }
 
 // SanitizeErrorMessage returns a user-friendly error message
@@ -0,0 +1,241 @@
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"simon-backend/internal/models"
+)
+
+// completeSpec returns a spec that passes validation; tests break one field at a time
+func completeSpec() *models.CoachSpec {
+	object := models.SchemaDefinition{Type: "object", Properties: map[string]interface{}{"title": map[string]interface{}{"type": "string"}}}
+	return &models.CoachSpec{
+		Version: "1.0",
+		Identity: models.Identity{
+			Name:      "Focus Coach",
+			Tagline:   "One thing at a time",
+			Niche:     "productivity",
+			Audience:  []string{"founders"},
+			Languages: []string{"en"},
+			Persona:   models.Persona{Archetype: "mentor", Voice: "calm"},
+		},
+		Style: models.Style{Tone: "warm", Verbosity: "medium"},
+		Outputs: models.Outputs{Schemas: models.OutputSchemas{
+			Plan:         object,
+			NextAction:   object,
+			WeeklyReview: object,
+		}},
+	}
+}
+
+func TestValidateCoachSpecAllReportsEveryProblem(t *testing.T) {
+	if errs := ValidateCoachSpecAll(completeSpec()); len(errs) != 0 {
+		t.Fatalf("complete spec has errors: %v", errs)
+	}
+
+	spec := completeSpec()
+	spec.Identity.Name = ""
+	spec.Style.Verbosity = "extreme"
+	spec.ToolsAllowed.ServerTools = []string{"plan_create", "delete_account"}
+
+	errs := ValidateCoachSpecAll(spec)
+	want := []FieldError{
+		{Path: "coachSpec.identity.name", Message: "name is required"},
+		{Path: "coachSpec.style.verbosity", Message: "verbosity must be one of: low, medium, high"},
+		{Path: "coachSpec.tools_allowed.server_tools[1]", Message: "server_tools contains invalid tool: delete_account"},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors %v, want %d", len(errs), errs, len(want))
+	}
+	for i := range want {
+		if errs[i].Path != want[i].Path || errs[i].Message != want[i].Message {
+			t.Errorf("errs[%d] = {%s %s}, want {%s %s}", i, errs[i].Path, errs[i].Message, want[i].Path, want[i].Message)
+		}
+	}
+
+	// The single-error form still reports the first problem with its section
+	if err := ValidateCoachSpec(spec); err == nil || err.Error() != "coachSpec.identity: name is required" {
+		t.Errorf("ValidateCoachSpec = %v, want the identity error", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"errors": errs[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(body); got != `{"errors":[{"path":"coachSpec.identity.name","message":"name is required"}]}` {
+		t.Errorf("response shape = %s", got)
+	}
+}
+
+func TestValidateCoachSpecAllNil(t *testing.T) {
+	if errs := ValidateCoachSpecAll(nil); errs != nil {
+		t.Errorf("nil spec: %v, want no errors", errs)
+	}
+}
+
+func TestValidateCoachSpecSection(t *testing.T) {
+	errs, err := ValidateCoachSpecSection("style", []byte(`{"tone":"","verbosity":"low","formatting":{"maxBullets":-1}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := map[string]bool{}
+	for _, e := range errs {
+		paths[e.Path] = true
+	}
+	if len(errs) != 2 || !paths["coachSpec.style.tone"] || !paths["coachSpec.style.formatting.maxBullets"] {
+		t.Errorf("style errors = %v, want tone and maxBullets only", errs)
+	}
+
+	if _, err := ValidateCoachSpecSection("pricing", []byte(`{}`)); !errors.Is(err, ErrUnknownSection) {
+		t.Errorf("unknown section error = %v, want ErrUnknownSection", err)
+	}
+	if _, err := ValidateCoachSpecSection("identity", []byte(`{"name": 5}`)); err == nil {
+		t.Error("malformed section JSON accepted")
+	}
+}
+
+func TestValidateCoachForCreateAll(t *testing.T) {
+	spec := completeSpec()
+	spec.Version = ""
+	errs := ValidateCoachForCreateAll(&models.Coach{Title: "", CoachSpec: spec})
+	if len(errs) != 2 || errs[0].Path != "title" || errs[1].Path != "coachSpec.version" {
+		t.Errorf("errors = %v, want title then coachSpec.version", errs)
+	}
+
+	errs = ValidateCoachForCreateAll(&models.Coach{Title: "No spec"})
+	if len(errs) != 1 || errs[0].Path != "coachSpec" {
+		t.Errorf("errors = %v, want the missing spec", errs)
+	}
+}
+
+func TestValidateRefusalSensitivity(t *testing.T) {
+	for sensitivity, valid := range map[string]bool{"": true, "standard": true, "strict": true, "paranoid": false} {
+		spec := completeSpec()
+		spec.Policies.Refusals.Sensitivity = sensitivity
+		errs := ValidateCoachSpecAll(spec)
+		if valid && len(errs) != 0 {
+			t.Errorf("sensitivity %q: %v", sensitivity, errs)
+		}
+		if !valid && (len(errs) != 1 || errs[0].Path != "coachSpec.policies.refusals.sensitivity") {
+			t.Errorf("sensitivity %q: errors = %v, want the sensitivity rejected", sensitivity, errs)
+		}
+	}
+}
+
+func TestValidateRefusalMessages(t *testing.T) {
+	spec := completeSpec()
+	spec.Policies.Refusals.Messages = map[string]string{"medical": "Let's keep this one for your doctor."}
+	if errs := ValidateCoachSpecAll(spec); len(errs) != 0 {
+		t.Errorf("valid override: %v", errs)
+	}
+
+	spec.Policies.Refusals.Messages = map[string]string{
+		"self_harm": "Cheer up!",
+		"legal":     strings.Repeat("a", 301),
+	}
+	errs := ValidateCoachSpecAll(spec)
+	if len(errs) != 2 || errs[0].Path != "coachSpec.policies.refusals.messages.legal" || errs[1].Path != "coachSpec.policies.refusals.messages.self_harm" {
+		t.Errorf("errors = %v, want the long legal message and the self_harm override rejected", errs)
+	}
+}
+
+func TestValidateStyleAdjustment(t *testing.T) {
+	tests := []struct {
+		name   string
+		adjust *models.StyleAdjustment
+		want   []string
+	}{
+		{"none", nil, nil},
+		{"verbosity", &models.StyleAdjustment{Verbosity: "low"}, nil},
+		{"tone", &models.StyleAdjustment{Tone: "warmer"}, nil},
+		{"empty", &models.StyleAdjustment{}, []string{"adjust"}},
+		{"unknown values", &models.StyleAdjustment{Verbosity: "tiny", Tone: "warm"}, []string{"adjust.verbosity", "adjust.tone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateStyleAdjustment(tt.adjust)
+			var paths []string
+			for _, e := range errs {
+				paths = append(paths, e.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("errors = %v, want paths %v", errs, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateStarterPrompts(t *testing.T) {
+	tests := []struct {
+		name    string
+		prompts []string
+		want    []string
+	}{
+		{"none", nil, nil},
+		{"within limits", []string{"Help me plan tomorrow", strings.Repeat("ü", models.MaxStarterPromptRunes)}, nil},
+		{"blank", []string{"Help me plan tomorrow", "  "}, []string{"coachSpec.identity.starterPrompts[1]"}},
+		{"too long", []string{strings.Repeat("a", models.MaxStarterPromptRunes+1)}, []string{"coachSpec.identity.starterPrompts[0]"}},
+		{"too many", []string{"a", "b", "c", "d", "e", "f"}, []string{"coachSpec.identity.starterPrompts"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := completeSpec()
+			spec.Identity.StarterPrompts = tt.prompts
+			var paths []string
+			for _, e := range ValidateCoachSpecAll(spec) {
+				paths = append(paths, e.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("error paths = %v, want %v", paths, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultMode(t *testing.T) {
+	for mode, valid := range map[string]bool{"": true, "quick": true, "system": true, "deep": true, "marathon": false} {
+		spec := completeSpec()
+		spec.DefaultMode = mode
+		errs := ValidateCoachSpecAll(spec)
+		if valid && len(errs) != 0 {
+			t.Errorf("mode %q: %v", mode, errs)
+		}
+		if !valid && (len(errs) != 1 || errs[0].Path != "coachSpec.default_mode") {
+			t.Errorf("mode %q: errors = %v, want the mode rejected", mode, errs)
+		}
+	}
+}
+
+func TestValidateRedactPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		want     []string
+	}{
+		{"none", nil, nil},
+		{"literal and regex", []string{"account_number", `/acct-\d+/`}, nil},
+		{"empty", []string{""}, []string{"coachSpec.policies.privacy.redactPatterns[0]"}},
+		{"invalid regex", []string{"acct", "/acct-(/"}, []string{"coachSpec.policies.privacy.redactPatterns[1]"}},
+		{"matches empty text", []string{"//", "/a*/", "/.*?/"}, []string{
+			"coachSpec.policies.privacy.redactPatterns[0]",
+			"coachSpec.policies.privacy.redactPatterns[1]",
+			"coachSpec.policies.privacy.redactPatterns[2]",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := completeSpec()
+			spec.Policies.Privacy.RedactPatterns = tt.patterns
+			var paths []string
+			for _, e := range ValidateCoachSpecAll(spec) {
+				paths = append(paths, e.Path)
+			}
+			if strings.Join(paths, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("error paths = %v, want %v", paths, tt.want)
+			}
+		})
+	}
+}